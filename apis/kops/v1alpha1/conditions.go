@@ -0,0 +1,493 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Condition types.
+const (
+	// TypeClusterValidated indicates whether a kops cluster passed
+	// validation.
+	TypeClusterValidated xpv1.ConditionType = "ClusterValidated"
+
+	// TypeCertificatesExpiring indicates whether a certificate of a kops
+	// cluster is about to expire.
+	TypeCertificatesExpiring xpv1.ConditionType = "CertificatesExpiring"
+
+	// TypeRollingUpdatePending indicates whether instances of a kops cluster
+	// need to be replaced to pick up its current configuration.
+	TypeRollingUpdatePending xpv1.ConditionType = "RollingUpdatePending"
+
+	// TypeInfrastructureMissing indicates whether the instances of a kops
+	// cluster are all gone from the cloud while its state remains.
+	TypeInfrastructureMissing xpv1.ConditionType = "InfrastructureMissing"
+
+	// TypePreflightChecked indicates whether a kops cluster passed the checks
+	// run before it is first applied.
+	TypePreflightChecked xpv1.ConditionType = "PreflightChecked"
+
+	// TypeVersionSkew indicates whether the state of a kops cluster was
+	// written by a newer version of kops than the provider's.
+	TypeVersionSkew xpv1.ConditionType = "VersionSkew"
+
+	// TypeDeprecatedFields indicates whether the spec of a kops cluster uses
+	// fields that kops deprecated, or that its Kubernetes version removed.
+	TypeDeprecatedFields xpv1.ConditionType = "DeprecatedFields"
+
+	// TypeMissingSubnets indicates whether instance groups of a kops cluster
+	// are in subnets its cluster spec doesn't have.
+	TypeMissingSubnets xpv1.ConditionType = "MissingSubnets"
+
+	// TypeExternallyModified indicates whether the state of a kops cluster
+	// was changed outside of the provider since the provider last applied
+	// it, e.g. with kops edit cluster.
+	TypeExternallyModified xpv1.ConditionType = "ExternallyModified"
+
+	// TypeInsufficientCapacity indicates whether instance groups of a kops
+	// cluster can't launch instances for lack of capacity in the cloud.
+	TypeInsufficientCapacity xpv1.ConditionType = "InsufficientCapacity"
+
+	// TypeFailing indicates whether the last operation on a kops cluster
+	// failed, or the cluster failed validation. Its reason classifies the
+	// failure so that monitoring can alert on classes of failure.
+	TypeFailing xpv1.ConditionType = "Failing"
+
+	// TypeReconcilePaused indicates whether the provider stopped reconciling
+	// a Kops resource that failed the same way too many times in a row.
+	TypeReconcilePaused xpv1.ConditionType = "ReconcilePaused"
+)
+
+// Reasons a kops cluster is or is not validated.
+const (
+	ReasonValidationSucceeded xpv1.ConditionReason = "ValidationSucceeded"
+	ReasonValidationFailed    xpv1.ConditionReason = "ValidationFailed"
+	ReasonValidationError     xpv1.ConditionReason = "ValidationError"
+	ReasonValidationSkipped   xpv1.ConditionReason = "ValidationSkipped"
+)
+
+// Reasons a kops cluster's certificates are or are not expiring.
+const (
+	ReasonCertificatesValid    xpv1.ConditionReason = "CertificatesValid"
+	ReasonCertificatesExpiring xpv1.ConditionReason = "CertificatesExpiring"
+)
+
+// Reasons a kops cluster does or does not need a rolling update.
+const (
+	ReasonNodesUpToDate        xpv1.ConditionReason = "NodesUpToDate"
+	ReasonRollingUpdatePending xpv1.ConditionReason = "RollingUpdatePending"
+	ReasonRollingUpdateUnknown xpv1.ConditionReason = "RollingUpdateUnknown"
+)
+
+// Reasons a kops cluster's infrastructure is or is not missing.
+const (
+	ReasonInfrastructurePresent xpv1.ConditionReason = "InfrastructurePresent"
+	ReasonInfrastructureMissing xpv1.ConditionReason = "InfrastructureMissing"
+)
+
+// Reasons a kops cluster did or did not pass its preflight checks.
+const (
+	ReasonPreflightSucceeded   xpv1.ConditionReason = "PreflightSucceeded"
+	ReasonAssetsUnavailable    xpv1.ConditionReason = "AssetsUnavailable"
+	ReasonPermissionsMissing   xpv1.ConditionReason = "PermissionsMissing"
+	ReasonDNSMisconfigured     xpv1.ConditionReason = "DNSMisconfigured"
+	ReasonCertificateNotIssued xpv1.ConditionReason = "CertificateNotIssued"
+)
+
+// Reasons the state of a kops cluster is or is not compatible with the
+// provider's version of kops.
+const (
+	ReasonVersionCompatible xpv1.ConditionReason = "VersionCompatible"
+	ReasonVersionSkew       xpv1.ConditionReason = "VersionSkew"
+)
+
+// Reasons the spec of a kops cluster does or does not use deprecated fields.
+const (
+	ReasonSpecCurrent      xpv1.ConditionReason = "SpecCurrent"
+	ReasonDeprecatedFields xpv1.ConditionReason = "DeprecatedFields"
+)
+
+// Reasons instance groups of a kops cluster are or are not in subnets the
+// cluster doesn't have.
+const (
+	ReasonSubnetsFound   xpv1.ConditionReason = "SubnetsFound"
+	ReasonSubnetsMissing xpv1.ConditionReason = "SubnetsMissing"
+)
+
+// Reasons the state of a kops cluster was or was not changed outside of the
+// provider.
+const (
+	ReasonStateAsApplied          xpv1.ConditionReason = "StateAsApplied"
+	ReasonStateModifiedExternally xpv1.ConditionReason = "StateModifiedExternally"
+)
+
+// Reasons instance groups of a kops cluster do or do not lack capacity.
+const (
+	ReasonCapacityAvailable    xpv1.ConditionReason = "CapacityAvailable"
+	ReasonInsufficientCapacity xpv1.ConditionReason = "InsufficientCapacity"
+)
+
+// Reasons a kops cluster is or is not failing. A cluster failing validation
+// is failing with ReasonValidationFailed.
+const (
+	ReasonNotFailing             xpv1.ConditionReason = "NotFailing"
+	ReasonStateStoreUnreachable  xpv1.ConditionReason = "StateStoreUnreachable"
+	ReasonCloudAuthFailure       xpv1.ConditionReason = "CloudAuthFailure"
+	ReasonApplyFailed            xpv1.ConditionReason = "ApplyFailed"
+	ReasonDeleteBlocked          xpv1.ConditionReason = "DeleteBlocked"
+	ReasonObserveFailed          xpv1.ConditionReason = "ObserveFailed"
+	ReasonApplyThrottled         xpv1.ConditionReason = "ApplyThrottled"
+	ReasonCertificatePending     xpv1.ConditionReason = "CertificatePending"
+	ReasonPaused                 xpv1.ConditionReason = "Paused"
+	ReasonWaitingForDependencies xpv1.ConditionReason = "WaitingForDependencies"
+	ReasonDrainingNodes          xpv1.ConditionReason = "DrainingNodes"
+	ReasonReadOnly               xpv1.ConditionReason = "ReadOnly"
+)
+
+// Reasons reconciling a kops cluster is or is not paused.
+const (
+	ReasonCircuitClosed xpv1.ConditionReason = "CircuitClosed"
+	ReasonCircuitOpen   xpv1.ConditionReason = "CircuitOpen"
+)
+
+// ClusterValidated returns a condition that indicates the kops cluster passed
+// validation.
+func ClusterValidated() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeClusterValidated,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonValidationSucceeded,
+	}
+}
+
+// ClusterValidationFailed returns a condition that indicates the kops cluster
+// was validated and reported failures.
+func ClusterValidationFailed(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeClusterValidated,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonValidationFailed,
+		Message:            msg,
+	}
+}
+
+// ClusterValidationError returns a condition that indicates the kops cluster
+// could not be validated.
+func ClusterValidationError(err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeClusterValidated,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonValidationError,
+		Message:            err.Error(),
+	}
+}
+
+// ClusterValidationSkipped returns a condition that indicates the kops cluster
+// was not validated because its API server is not reachable by the provider.
+func ClusterValidationSkipped(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeClusterValidated,
+		Status:             corev1.ConditionUnknown,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonValidationSkipped,
+		Message:            msg,
+	}
+}
+
+// CertificatesValid returns a condition that indicates the certificates of
+// the kops cluster are not about to expire.
+func CertificatesValid() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeCertificatesExpiring,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonCertificatesValid,
+	}
+}
+
+// CertificatesExpiring returns a condition that indicates a certificate of
+// the kops cluster is about to expire.
+func CertificatesExpiring(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeCertificatesExpiring,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonCertificatesExpiring,
+		Message:            msg,
+	}
+}
+
+// NodesUpToDate returns a condition that indicates every instance of the kops
+// cluster runs its current configuration.
+func NodesUpToDate() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeRollingUpdatePending,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNodesUpToDate,
+	}
+}
+
+// RollingUpdatePending returns a condition that indicates instances of the
+// kops cluster need to be replaced to pick up its current configuration.
+func RollingUpdatePending(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeRollingUpdatePending,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonRollingUpdatePending,
+		Message:            msg,
+	}
+}
+
+// RollingUpdateUnknown returns a condition that indicates whether instances
+// of the kops cluster need a rolling update could not be determined.
+func RollingUpdateUnknown(err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeRollingUpdatePending,
+		Status:             corev1.ConditionUnknown,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonRollingUpdateUnknown,
+		Message:            err.Error(),
+	}
+}
+
+// InfrastructurePresent returns a condition that indicates the kops cluster
+// has instances in the cloud.
+func InfrastructurePresent() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeInfrastructureMissing,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonInfrastructurePresent,
+	}
+}
+
+// InfrastructureMissing returns a condition that indicates every instance of
+// the kops cluster is gone from the cloud while its state remains.
+func InfrastructureMissing(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeInfrastructureMissing,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonInfrastructureMissing,
+		Message:            msg,
+	}
+}
+
+// PreflightSucceeded returns a condition that indicates the kops cluster
+// passed the checks run before it is first applied.
+func PreflightSucceeded() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypePreflightChecked,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonPreflightSucceeded,
+	}
+}
+
+// PreflightFailed returns a condition that indicates the kops cluster failed
+// a check run before it is first applied, for the supplied reason.
+func PreflightFailed(reason xpv1.ConditionReason, msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypePreflightChecked,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            msg,
+	}
+}
+
+// VersionCompatible returns a condition that indicates the state of the kops
+// cluster can be safely written by the provider's version of kops.
+func VersionCompatible() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeVersionSkew,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonVersionCompatible,
+	}
+}
+
+// VersionSkew returns a condition that indicates the state of the kops
+// cluster was written by a newer version of kops than the provider's.
+func VersionSkew(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeVersionSkew,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonVersionSkew,
+		Message:            msg,
+	}
+}
+
+// SpecCurrent returns a condition that indicates the spec of the kops cluster
+// uses no deprecated fields.
+func SpecCurrent() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDeprecatedFields,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonSpecCurrent,
+	}
+}
+
+// DeprecatedFields returns a condition that indicates the spec of the kops
+// cluster uses fields that kops deprecated, or that its Kubernetes version
+// removed.
+func DeprecatedFields(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeDeprecatedFields,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDeprecatedFields,
+		Message:            msg,
+	}
+}
+
+// SubnetsFound returns a condition that indicates every subnet the instance
+// groups of the kops cluster are in is in its cluster spec.
+func SubnetsFound() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeMissingSubnets,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonSubnetsFound,
+	}
+}
+
+// StateAsApplied returns a condition that indicates the state of the kops
+// cluster is as the provider last applied it.
+func StateAsApplied() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeExternallyModified,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonStateAsApplied,
+	}
+}
+
+// StateModifiedExternally returns a condition that indicates the state of the
+// kops cluster was changed outside of the provider since it last applied it.
+func StateModifiedExternally(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeExternallyModified,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonStateModifiedExternally,
+		Message:            msg,
+	}
+}
+
+// SubnetsMissing returns a condition that indicates instance groups of the
+// kops cluster are in subnets its cluster spec doesn't have.
+func SubnetsMissing(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeMissingSubnets,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonSubnetsMissing,
+		Message:            msg,
+	}
+}
+
+// CapacityAvailable returns a condition that indicates no instance group of
+// the kops cluster failed to launch instances for lack of capacity.
+func CapacityAvailable() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeInsufficientCapacity,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonCapacityAvailable,
+	}
+}
+
+// InsufficientCapacity returns a condition that indicates instance groups of
+// the kops cluster failed to launch instances for lack of capacity.
+func InsufficientCapacity(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeInsufficientCapacity,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonInsufficientCapacity,
+		Message:            msg,
+	}
+}
+
+// NotFailing returns a condition that indicates the last operation on the
+// kops cluster succeeded.
+func NotFailing() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeFailing,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNotFailing,
+	}
+}
+
+// Failing returns a condition that indicates the kops cluster is failing for
+// the supplied class of reason.
+func Failing(reason xpv1.ConditionReason, msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeFailing,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            msg,
+	}
+}
+
+// Waiting returns a condition that indicates the last operation on the kops
+// cluster did not fail, but could not start yet for the supplied reason.
+func Waiting(reason xpv1.ConditionReason, msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeFailing,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            msg,
+	}
+}
+
+// CircuitClosed returns a condition that indicates the kops cluster is being
+// reconciled.
+func CircuitClosed() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeReconcilePaused,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonCircuitClosed,
+	}
+}
+
+// CircuitOpen returns a condition that indicates reconciling the kops cluster
+// is paused after it failed the same way too many times in a row.
+func CircuitOpen(msg string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeReconcilePaused,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonCircuitOpen,
+		Message:            msg,
+	}
+}
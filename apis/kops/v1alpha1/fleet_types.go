@@ -0,0 +1,183 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// LabelKeyFleet is the label a Kops resource created by a KopsFleet carries,
+// set to the name of the KopsFleet.
+const LabelKeyFleet = "kops.crossplane.io/fleet"
+
+// AnnotationKeyFleetSpecHash is the annotation a Kops resource created by a
+// KopsFleet carries, set to the hash of the spec the fleet last rendered for
+// it.
+const AnnotationKeyFleetSpecHash = "kops.crossplane.io/fleet-spec-hash"
+
+// A KopsFleetTemplate is the spec shared by the Kops resources of a fleet.
+type KopsFleetTemplate struct {
+	// ProviderConfigReference specifies the provider config the Kops
+	// resources of the fleet use.
+	// +kubebuilder:default={"name": "default"}
+	// +optional
+	ProviderConfigReference *xpv1.Reference `json:"providerConfigRef,omitempty"`
+
+	// DeletionPolicy specifies what happens to the clusters of the fleet when
+	// their Kops resources are deleted.
+	// +kubebuilder:validation:Enum=Orphan;Delete
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy xpv1.DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// ForProvider are the parameters of every cluster of the fleet, before
+	// their overrides.
+	ForProvider KopsParameters `json:"forProvider"`
+}
+
+// FleetClusterParameters are the overrides of a cluster of a fleet.
+type FleetClusterParameters struct {
+	// Name is the name of the Kops resource of the cluster, and its external
+	// name.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Region overrides the region of the cluster.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Zones overrides the zones of the cluster, which must be in its region.
+	// +optional
+	Zones []string `json:"zones,omitempty"`
+
+	// NetworkCIDR overrides the network CIDR of the cluster.
+	// +optional
+	NetworkCIDR string `json:"networkCIDR,omitempty"`
+
+	// WriteConnectionSecretToReference is the secret the connection details
+	// of the cluster are written to.
+	// +optional
+	WriteConnectionSecretToReference *xpv1.SecretReference `json:"writeConnectionSecretToRef,omitempty"`
+}
+
+// A KopsFleetSpec defines the desired state of a KopsFleet.
+type KopsFleetSpec struct {
+	// Template is the spec shared by the Kops resources of the fleet. When
+	// it changes, the fields it sets are merged into the spec of existing
+	// Kops resources; fields it doesn't set are left as they are.
+	Template KopsFleetTemplate `json:"template"`
+
+	// Clusters of the fleet. A Kops resource is created for each cluster,
+	// and deleted once the cluster is removed from the fleet.
+	// +listType=map
+	// +listMapKey=name
+	Clusters []FleetClusterParameters `json:"clusters"`
+
+	// MaxUnavailable is how many clusters of the fleet may be unavailable,
+	// i.e. not ready or not yet applied, before changes to the template stop
+	// being rolled out to further clusters.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	MaxUnavailable int32 `json:"maxUnavailable,omitempty"`
+}
+
+// A FleetClusterObservation is the observed state of a cluster of a fleet.
+type FleetClusterObservation struct {
+	// Name is the name of the Kops resource of the cluster.
+	Name string `json:"name"`
+
+	// Ready is whether the cluster is ready.
+	Ready bool `json:"ready,omitempty"`
+
+	// Updated is whether the cluster has the current spec of the fleet.
+	Updated bool `json:"updated,omitempty"`
+}
+
+// A KopsFleetStatus represents the observed state of a KopsFleet.
+type KopsFleetStatus struct {
+	xpv1.ConditionedStatus `json:",inline"`
+
+	// Total is the number of clusters of the fleet.
+	Total int32 `json:"total,omitempty"`
+
+	// Ready is the number of ready clusters of the fleet.
+	Ready int32 `json:"ready,omitempty"`
+
+	// Updated is the number of clusters of the fleet that have its current
+	// spec.
+	Updated int32 `json:"updated,omitempty"`
+
+	// Clusters are the observed states of the clusters of the fleet.
+	// +optional
+	Clusters []FleetClusterObservation `json:"clusters,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A KopsFleet stamps out Kops clusters from a shared template with
+// per-cluster overrides, and rolls out changes to the template across them
+// in stages.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="CLUSTERS",type="integer",JSONPath=".status.total"
+// +kubebuilder:printcolumn:name="AVAILABLE",type="integer",JSONPath=".status.ready"
+// +kubebuilder:printcolumn:name="UPDATED",type="integer",JSONPath=".status.updated"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,kops}
+type KopsFleet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KopsFleetSpec   `json:"spec"`
+	Status KopsFleetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KopsFleetList contains a list of KopsFleet
+type KopsFleetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KopsFleet `json:"items"`
+}
+
+// GetCondition of this KopsFleet.
+func (in *KopsFleet) GetCondition(ct xpv1.ConditionType) xpv1.Condition {
+	return in.Status.GetCondition(ct)
+}
+
+// SetConditions of this KopsFleet.
+func (in *KopsFleet) SetConditions(c ...xpv1.Condition) {
+	in.Status.SetConditions(c...)
+}
+
+// KopsFleet type metadata.
+var (
+	KopsFleetKind             = reflect.TypeOf(KopsFleet{}).Name()
+	KopsFleetGroupKind        = schema.GroupKind{Group: Group, Kind: KopsFleetKind}.String()
+	KopsFleetKindAPIVersion   = KopsFleetKind + "." + SchemeGroupVersion.String()
+	KopsFleetGroupVersionKind = SchemeGroupVersion.WithKind(KopsFleetKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&KopsFleet{}, &KopsFleetList{})
+}
@@ -20,16 +20,1285 @@ import (
 	"reflect"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/kops/pkg/apis/kops"
 )
 
+// AnnotationKeyDump requests a diagnostic bundle of a Kops cluster. A bundle
+// is collected whenever the value of the annotation differs from the request
+// of the last bundle, e.g. set it to the current time to collect a new one.
+const AnnotationKeyDump = "kops.crossplane.io/dump"
+
+// AnnotationKeyEtcdBackup requests a backup of the etcd clusters of a Kops
+// cluster. etcd-manager takes backups on its own schedule and can't be asked
+// for one remotely, so a request completes once every etcd cluster has been
+// backed up after the value of the annotation last changed.
+const AnnotationKeyEtcdBackup = "kops.crossplane.io/etcd-backup"
+
+// AnnotationKeyEtcdRestore requests that the etcd clusters of a Kops cluster
+// are restored from a backup. The value is either a backup name, or latest,
+// applied to every etcd cluster, or a comma separated list of cluster=backup
+// pairs, e.g. main=latest,events=latest. A restore is started whenever the
+// value differs from the request of the last restore. Restoring replaces every
+// control plane instance at once, so the API server is unavailable until the
+// restore completes.
+const AnnotationKeyEtcdRestore = "kops.crossplane.io/etcd-restore"
+
+// AnnotationKeyAdopt allows a Kops resource to take over a Kops cluster that
+// the state store records as managed by another Kops resource, e.g. one that
+// was deleted with the Orphan deletion policy. The value must be the UID of
+// the recorded Kops resource, so that a cluster is never taken over from a
+// resource other than the intended one.
+const AnnotationKeyAdopt = "kops.crossplane.io/adopt"
+
+// AnnotationKeyDeletePreview requests a preview of the cloud resources that
+// deleting a Kops cluster would delete, without deleting anything. A preview
+// is listed whenever the value of the annotation differs from the request of
+// the last preview, e.g. set it to the current time to list a new one.
+const AnnotationKeyDeletePreview = "kops.crossplane.io/delete-preview"
+
+// AnnotationKeyResume resumes reconciling a Kops resource that was paused
+// after failing the same way too many times in a row. The provider removes
+// the annotation once it resumes. Changing the spec resumes it too.
+const AnnotationKeyResume = "kops.crossplane.io/resume"
+
+// AnnotationKeyPaused pauses reconciling a Kops resource when set to "true".
+// Its cluster is neither created, updated nor deleted while it is paused, and
+// its connection details are only refreshed if the provider is configured to.
+const AnnotationKeyPaused = "crossplane.io/paused"
+
 // KopsObservation are the observable fields of a Kops.
 type KopsObservation struct {
 	ProvisioningState string `json:"provisioningState,omitempty"`
 	ID                string `json:"id,omitempty"`
 	Name              string `json:"name,omitempty"`
+
+	// APIEndpoint is the URL of the Kubernetes API server of the cluster.
+	APIEndpoint string `json:"apiEndpoint,omitempty"`
+
+	// ServiceAccountIssuer is the issuer URL of the cluster's service
+	// account tokens.
+	ServiceAccountIssuer string `json:"serviceAccountIssuer,omitempty"`
+
+	// OIDCDiscoveryURL is the OIDC discovery document URL of the service
+	// account issuer.
+	OIDCDiscoveryURL string `json:"oidcDiscoveryURL,omitempty"`
+
+	// CACertificateFingerprint is the SHA-256 fingerprint of the primary
+	// cluster CA certificate.
+	CACertificateFingerprint string `json:"caCertificateFingerprint,omitempty"`
+
+	// CAKeysetFingerprint is the SHA-256 fingerprint of the cluster CA
+	// keyset, which changes when the CA is rotated.
+	CAKeysetFingerprint string `json:"caKeysetFingerprint,omitempty"`
+
+	// CACertificateExpiry is the expiry of the primary cluster CA
+	// certificate.
+	CACertificateExpiry *metav1.Time `json:"caCertificateExpiry,omitempty"`
+
+	// ClientCertificateExpiry is the expiry of the client certificate of the
+	// last published kubeconfig.
+	ClientCertificateExpiry *metav1.Time `json:"clientCertificateExpiry,omitempty"`
+
+	// Validation is the result of the last kops cluster validation.
+	Validation *ValidationObservation `json:"validation,omitempty"`
+
+	// Nodes summarizes the readiness of the nodes of the cluster by role.
+	Nodes *NodeReadiness `json:"nodes,omitempty"`
+
+	// Infrastructure identifies the cloud infrastructure of the cluster.
+	Infrastructure *InfrastructureObservation `json:"infrastructure,omitempty"`
+
+	// Etcd is the health of the etcd clusters of the cluster.
+	Etcd []EtcdClusterObservation `json:"etcd,omitempty"`
+
+	// Dump is the last diagnostic bundle collected for the cluster.
+	Dump *DumpObservation `json:"dump,omitempty"`
+
+	// DeletePreview is the last requested preview of the cloud resources
+	// deleting the cluster would delete.
+	DeletePreview *DeletePreviewObservation `json:"deletePreview,omitempty"`
+
+	// EtcdBackup is the last requested backup of the etcd clusters.
+	EtcdBackup *EtcdBackupObservation `json:"etcdBackup,omitempty"`
+
+	// EtcdRestore is the last requested restore of the etcd clusters.
+	EtcdRestore *EtcdRestoreObservation `json:"etcdRestore,omitempty"`
+
+	// TerraformOutputPath is the path Terraform was last rendered to.
+	TerraformOutputPath string `json:"terraformOutputPath,omitempty"`
+
+	// StateBackupPath is the path the state of the cluster was last backed
+	// up to before it was updated or deleted.
+	StateBackupPath string `json:"stateBackupPath,omitempty"`
+
+	// LastAppliedTime is the time the cluster was last applied to the cloud.
+	LastAppliedTime *metav1.Time `json:"lastAppliedTime,omitempty"`
+
+	// LastAppliedGeneration is the generation of the Kops resource that was
+	// last applied to the cloud.
+	LastAppliedGeneration int64 `json:"lastAppliedGeneration,omitempty"`
+
+	// LastAppliedSpecHash is the SHA-256 hash of the forProvider parameters
+	// that were last applied to the cloud.
+	LastAppliedSpecHash string `json:"lastAppliedSpecHash,omitempty"`
+
+	// DryRunGeneration is the generation of the Kops resource that was last
+	// applied as a dry run while the provider was read-only.
+	DryRunGeneration int64 `json:"dryRunGeneration,omitempty"`
+
+	// KopsVersion is the version of the kops library the cluster was last
+	// applied with.
+	KopsVersion string `json:"kopsVersion,omitempty"`
+
+	// StateKopsVersion is the version of kops that last applied the cluster,
+	// as recorded in its state store. It may differ from KopsVersion if the
+	// cluster was also applied outside of the provider.
+	StateKopsVersion string `json:"stateKopsVersion,omitempty"`
+
+	// Phase is the phase of the lifecycle of the cluster.
+	Phase ClusterPhase `json:"phase,omitempty"`
+
+	// PhaseTransitionTime is the time the cluster entered its phase.
+	PhaseTransitionTime *metav1.Time `json:"phaseTransitionTime,omitempty"`
+
+	// IncompleteApply is the last apply of the cluster that did not
+	// complete, because it failed or because the provider was stopped while
+	// it ran. The cluster is applied again, even if its state is up to date,
+	// until an apply completes.
+	IncompleteApply *ApplyRecord `json:"incompleteApply,omitempty"`
+
+	// WrittenState is the state of the cluster the provider last wrote to
+	// the state store, to tell changes made outside of the provider apart.
+	WrittenState *WrittenStateObservation `json:"writtenState,omitempty"`
+
+	// ApplyProgress is the progress of the running apply of the cluster, or
+	// of the last apply if it did not complete. It is only reported while no
+	// other cluster is being applied by the same provider.
+	ApplyProgress *ApplyProgressObservation `json:"applyProgress,omitempty"`
+
+	// EncryptionConfig is the encryption config last written to the
+	// cluster's kops secret store.
+	EncryptionConfig *EncryptionConfigObservation `json:"encryptionConfig,omitempty"`
+
+	// PendingReplacements are the instance groups whose changes that are yet
+	// to be applied replace their instances. Changes to the size, warm pool
+	// or mixed instances policy of an instance group alone don't.
+	PendingReplacements []string `json:"pendingReplacements,omitempty"`
+
+	// OnDemandFallbacks are the spot instance groups currently running on
+	// on-demand instances for lack of spot capacity.
+	OnDemandFallbacks []OnDemandFallbackObservation `json:"onDemandFallbacks,omitempty"`
+
+	// GPUPools is the readiness of the nodes with Nvidia GPUs of each
+	// instance group, if GPU nodes are verified.
+	GPUPools []GPUPoolObservation `json:"gpuPools,omitempty"`
+
+	// InstanceConnections are how to reach the instances of each instance
+	// group, if instance connection hints are published.
+	InstanceConnections []InstanceGroupConnectionObservation `json:"instanceConnections,omitempty"`
+
+	// DockerConfig is the Docker config last written to the cluster's kops
+	// secret store.
+	DockerConfig *DockerConfigObservation `json:"dockerConfig,omitempty"`
+
+	// CNI is the networking config last applied to the cluster.
+	CNI *CNIObservation `json:"cni,omitempty"`
+
+	// APIServerSANs are the additional subject alternative names of the API
+	// server certificate last applied to the cluster.
+	APIServerSANs *APIServerSANsObservation `json:"apiServerSANs,omitempty"`
+
+	// TrackedImages are the latest images of the instance groups whose
+	// image is tracked.
+	TrackedImages []TrackedImageObservation `json:"trackedImages,omitempty"`
+
+	// Upgrade is the upgrade the kops channel of the cluster recommends, if
+	// upgrades are recommended.
+	Upgrade *UpgradeObservation `json:"upgrade,omitempty"`
+
+	// Cost is the estimated cost of the cloud resources of the cluster, if
+	// cost estimation is enabled.
+	Cost *CostObservation `json:"cost,omitempty"`
+
+	// StateReplica is the last replication of the state of the cluster to
+	// its replica state bucket, if the state is replicated.
+	StateReplica *StateReplicaObservation `json:"stateReplica,omitempty"`
+
+	// PreDelete is the progress of what is done to the cluster before its
+	// cloud resources are deleted, once deleting it started.
+	PreDelete *PreDeleteObservation `json:"preDelete,omitempty"`
+}
+
+// A CostObservation is the approximate on-demand cost of the instances and
+// volumes of a cluster. Prices are decimal strings in US dollars.
+type CostObservation struct {
+	// HourlyUSD is the estimated hourly cost of the cluster.
+	HourlyUSD string `json:"hourlyUSD"`
+
+	// InstanceGroups are the estimated hourly costs of the instance groups
+	// of the cluster.
+	InstanceGroups []InstanceGroupCost `json:"instanceGroups,omitempty"`
+
+	// Time is the time the cost was last estimated.
+	Time metav1.Time `json:"time"`
+}
+
+// An InstanceGroupCost is the estimated hourly cost of an instance group,
+// including the root volumes of its instances.
+type InstanceGroupCost struct {
+	// Name is the name of the instance group.
+	Name string `json:"name"`
+
+	// Instances is the number of running instances of the instance group.
+	Instances int32 `json:"instances"`
+
+	// HourlyUSD is the estimated hourly cost of the instance group.
+	HourlyUSD string `json:"hourlyUSD"`
+}
+
+// An UpgradeUrgency is how urgently a kops channel recommends an upgrade.
+type UpgradeUrgency string
+
+// Upgrade urgencies.
+const (
+	// UpgradeUrgencyNone means the cluster runs the recommended versions.
+	UpgradeUrgencyNone UpgradeUrgency = "None"
+
+	// UpgradeUrgencyRecommended means the channel recommends newer versions.
+	UpgradeUrgencyRecommended UpgradeUrgency = "Recommended"
+
+	// UpgradeUrgencyRequired means the channel no longer supports the
+	// versions the cluster runs.
+	UpgradeUrgencyRequired UpgradeUrgency = "Required"
+)
+
+// An UpgradeObservation is the upgrade a kops channel recommends for a
+// cluster, as kops upgrade cluster would report it.
+type UpgradeObservation struct {
+	// Channel is the kops channel the versions were evaluated against.
+	Channel string `json:"channel"`
+
+	// KubernetesVersion is the Kubernetes version the channel recommends
+	// upgrading to, if the cluster runs an older one.
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+
+	// KopsVersion is the kops version the channel recommends upgrading the
+	// provider to, if the provider uses an older one.
+	KopsVersion string `json:"kopsVersion,omitempty"`
+
+	// Urgency is how urgently the channel recommends the upgrade.
+	Urgency UpgradeUrgency `json:"urgency"`
+
+	// LastCheckedTime is the time the channel was last evaluated.
+	LastCheckedTime metav1.Time `json:"lastCheckedTime"`
+}
+
+// A TrackedImageObservation is the latest image of an instance group whose
+// image is tracked.
+type TrackedImageObservation struct {
+	// InstanceGroup is the name of the instance group.
+	InstanceGroup string `json:"instanceGroup"`
+
+	// Image is the latest image of the instance group's source.
+	Image string `json:"image"`
+
+	// LastCheckedTime is the time the source was last checked.
+	LastCheckedTime metav1.Time `json:"lastCheckedTime"`
+
+	// LastChangedTime is the time a newer image was last found.
+	LastChangedTime metav1.Time `json:"lastChangedTime"`
+}
+
+// A CNIObservation is the networking config of a cluster, and its rollout to
+// the nodes.
+type CNIObservation struct {
+	// Provider is the networking provider, e.g. cilium.
+	Provider string `json:"provider"`
+
+	// Hash is the SHA-256 hash of the networking config.
+	Hash string `json:"hash"`
+
+	// PendingInstances are the IDs of the node instances that still run
+	// with the previous networking config.
+	PendingInstances []string `json:"pendingInstances,omitempty"`
+
+	// LastReplacedTime is the time a node instance was last replaced to
+	// restart its CNI agent.
+	LastReplacedTime *metav1.Time `json:"lastReplacedTime,omitempty"`
+}
+
+// An APIServerSANsObservation is the additional subject alternative names of
+// the API server certificate of a cluster, and their rollout to its control
+// plane instances.
+type APIServerSANsObservation struct {
+	// SANs are the additional subject alternative names.
+	SANs []string `json:"sans,omitempty"`
+
+	// PendingInstances are the IDs of the control plane instances whose API
+	// server certificate still lacks the current subject alternative names.
+	PendingInstances []string `json:"pendingInstances,omitempty"`
+
+	// LastReplacedTime is the time a control plane instance was last
+	// replaced to reissue its API server certificate.
+	LastReplacedTime *metav1.Time `json:"lastReplacedTime,omitempty"`
+}
+
+// A StateReplicaObservation is the last replication of the state of a cluster
+// to a replica state bucket.
+type StateReplicaObservation struct {
+	// Path is the path the state was replicated to.
+	Path string `json:"path"`
+
+	// Files is the number of files replicated.
+	Files int `json:"files"`
+
+	// LastReplicatedTime is the time the state was last replicated.
+	LastReplicatedTime *metav1.Time `json:"lastReplicatedTime,omitempty"`
+
+	// LastReplicatedGeneration is the generation of the Kops resource whose
+	// apply was last replicated.
+	LastReplicatedGeneration int64 `json:"lastReplicatedGeneration,omitempty"`
+}
+
+// A CNIHealth is the rollout of the agent DaemonSet of the networking
+// provider of a cluster.
+type CNIHealth struct {
+	// DaemonSet is the name of the agent DaemonSet in kube-system.
+	DaemonSet string `json:"daemonSet"`
+
+	// Desired is the number of nodes that should run the agent.
+	Desired int32 `json:"desired"`
+
+	// Ready is the number of nodes running a ready agent.
+	Ready int32 `json:"ready"`
+
+	// Updated is the number of nodes running the current agent.
+	Updated int32 `json:"updated"`
+
+	// Healthy is true if every node runs a ready, current agent.
+	Healthy bool `json:"healthy"`
+}
+
+// A DockerConfigObservation is the Docker config of a cluster, and its
+// rollout to the nodes.
+type DockerConfigObservation struct {
+	// Hash is the SHA-256 hash of the Docker config.
+	Hash string `json:"hash"`
+
+	// PendingInstances are the IDs of the node instances that still run with
+	// the previous Docker config.
+	PendingInstances []string `json:"pendingInstances,omitempty"`
+
+	// LastReplacedTime is the time a node instance was last replaced to
+	// load the Docker config.
+	LastReplacedTime *metav1.Time `json:"lastReplacedTime,omitempty"`
+}
+
+// An InstanceGroupConnectionObservation is how to reach the instances of an
+// instance group.
+type InstanceGroupConnectionObservation struct {
+	// InstanceGroup is the name of the instance group.
+	InstanceGroup string `json:"instanceGroup"`
+
+	// Instances of the instance group.
+	Instances []InstanceConnection `json:"instances,omitempty"`
+}
+
+// An InstanceConnection is how to reach an instance.
+type InstanceConnection struct {
+	// ID of the instance.
+	ID string `json:"id"`
+
+	// SSMTarget is the target of SSM sessions to the instance, as in aws ssm
+	// start-session --target, on AWS.
+	SSMTarget string `json:"ssmTarget,omitempty"`
+
+	// PrivateIP of the instance.
+	PrivateIP string `json:"privateIP,omitempty"`
+
+	// Node is the name of the node of the instance, once it joined the
+	// cluster.
+	Node string `json:"node,omitempty"`
+}
+
+// A GPUPoolObservation is the readiness of the nodes with Nvidia GPUs of an
+// instance group.
+type GPUPoolObservation struct {
+	// InstanceGroup is the name of the instance group.
+	InstanceGroup string `json:"instanceGroup"`
+
+	// Nodes is the number of nodes with Nvidia GPUs.
+	Nodes int `json:"nodes"`
+
+	// Ready is the number of those nodes whose GPUs are allocatable.
+	Ready int `json:"ready"`
+
+	// GPUs is the number of allocatable GPUs of the ready nodes.
+	GPUs int64 `json:"gpus"`
+
+	// Summary is Ready and Nodes formatted as ready/nodes.
+	Summary string `json:"summary"`
+}
+
+// An OnDemandFallbackObservation is a spot instance group that fell back to
+// on-demand instances.
+type OnDemandFallbackObservation struct {
+	// InstanceGroup is the name of the instance group.
+	InstanceGroup string `json:"instanceGroup"`
+
+	// Since is when the instance group fell back to on-demand instances.
+	Since metav1.Time `json:"since"`
+
+	// Message is the status of the scaling activity that failed for lack of
+	// spot capacity.
+	Message string `json:"message,omitempty"`
+}
+
+// An EncryptionConfigObservation is the encryption config of a cluster, and
+// the rollout of a changed config to its control plane.
+type EncryptionConfigObservation struct {
+	// Hash is the SHA-256 hash of the encryption config.
+	Hash string `json:"hash"`
+
+	// PendingInstances are the IDs of the control plane instances that
+	// still run with the previous encryption config.
+	PendingInstances []string `json:"pendingInstances,omitempty"`
+
+	// LastReplacedTime is the time a control plane instance was last
+	// replaced to load the encryption config.
+	LastReplacedTime *metav1.Time `json:"lastReplacedTime,omitempty"`
+}
+
+// An ApplyRecord records an apply of a cluster to the cloud.
+type ApplyRecord struct {
+	// Operation is the operation the apply was part of, either create or
+	// update.
+	Operation string `json:"operation"`
+
+	// Generation is the generation of the Kops resource that was applied.
+	Generation int64 `json:"generation,omitempty"`
+
+	// StartedTime is the time the apply started.
+	StartedTime metav1.Time `json:"startedTime"`
+}
+
+// A WrittenStateObservation is the state of a cluster the provider last wrote
+// to its state store.
+type WrittenStateObservation struct {
+	// Hash is the SHA-256 hash of the cluster and instance group specs.
+	Hash string `json:"hash"`
+
+	// SpecHash is the SHA-256 hash of the forProvider parameters the state
+	// was written for.
+	SpecHash string `json:"specHash"`
+}
+
+// An ApplyProgressObservation is the progress of the tasks kops runs to apply
+// a cluster to the cloud.
+type ApplyProgressObservation struct {
+	// DoneTasks is the number of tasks that are done.
+	DoneTasks int `json:"doneTasks"`
+
+	// TotalTasks is the number of tasks of the apply.
+	TotalTasks int `json:"totalTasks"`
+
+	// CurrentTask is the task that was started last, e.g.
+	// AutoScalingGroup/nodes-us-east-1a.example.com.
+	CurrentTask string `json:"currentTask,omitempty"`
+
+	// UpdatedTime is the time the progress was last updated.
+	UpdatedTime metav1.Time `json:"updatedTime"`
+}
+
+// A ClusterPhase is a phase of the lifecycle of a cluster.
+type ClusterPhase string
+
+// Phases of the lifecycle of a cluster.
+const (
+	// ClusterPhasePending means the cluster is not in its state store yet.
+	ClusterPhasePending ClusterPhase = "Pending"
+
+	// ClusterPhaseProvisioning means the cluster was registered in its state
+	// store and is being applied to the cloud for the first time.
+	ClusterPhaseProvisioning ClusterPhase = "Provisioning"
+
+	// ClusterPhaseValidating means the cluster was applied to the cloud and
+	// is waiting to pass validation.
+	ClusterPhaseValidating ClusterPhase = "Validating"
+
+	// ClusterPhaseReady means the cluster passed validation.
+	ClusterPhaseReady ClusterPhase = "Ready"
+
+	// ClusterPhaseUpdating means changes to the cluster are being applied to
+	// the cloud.
+	ClusterPhaseUpdating ClusterPhase = "Updating"
+
+	// ClusterPhaseDeleting means the cluster is being deleted.
+	ClusterPhaseDeleting ClusterPhase = "Deleting"
+)
+
+// A ValidationFailure is a failure reported by kops cluster validation.
+type ValidationFailure struct {
+	Kind          string `json:"kind,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Message       string `json:"message"`
+	InstanceGroup string `json:"instanceGroup,omitempty"`
+}
+
+// A ValidationObservation is the result of a kops cluster validation.
+type ValidationObservation struct {
+	// Failures are the failures reported by the validation.
+	Failures []ValidationFailure `json:"failures,omitempty"`
+
+	// Nodes is the number of nodes that were validated.
+	Nodes int `json:"nodes"`
+
+	// NotReadyNodes are the names of the nodes that are not ready.
+	NotReadyNodes []string `json:"notReadyNodes,omitempty"`
+
+	// CNI is the health of the CNI agents, if the networking provider of
+	// the cluster has any.
+	CNI *CNIHealth `json:"cni,omitempty"`
+
+	// HealthProbes are the results of the health probes of the cluster.
+	HealthProbes []HealthProbeResult `json:"healthProbes,omitempty"`
+}
+
+// A HealthProbeResult is the result of a health probe of a cluster.
+type HealthProbeResult struct {
+	// Name of the probe.
+	Name string `json:"name"`
+
+	// Healthy is true if the probe passed.
+	Healthy bool `json:"healthy"`
+
+	// Message tells why the probe failed.
+	Message string `json:"message,omitempty"`
+}
+
+// A DumpObservation is a diagnostic bundle collected for a cluster.
+type DumpObservation struct {
+	// Request is the value of the dump annotation the bundle was collected
+	// for.
+	Request string `json:"request"`
+
+	// Path is the state store path the bundle was written to.
+	Path string `json:"path"`
+
+	// Time is the time the bundle was collected.
+	Time metav1.Time `json:"time"`
+}
+
+// A DeletePreviewObservation lists the cloud resources that deleting a
+// cluster would delete.
+type DeletePreviewObservation struct {
+	// Request is the value of the delete preview annotation the preview was
+	// listed for.
+	Request string `json:"request"`
+
+	// Time is the time the preview was listed.
+	Time metav1.Time `json:"time"`
+
+	// Resources are the cloud resources of the cluster. Deleting the cluster
+	// deletes every one of them that isn't retained, unless the cluster is
+	// applied with Terraform.
+	// +optional
+	Resources []DeletePreviewResource `json:"resources,omitempty"`
+}
+
+// A DeletePreviewResource is a cloud resource of a cluster.
+type DeletePreviewResource struct {
+	// Type is the type of the resource, e.g. vpc or autoscaling-group.
+	Type string `json:"type"`
+
+	// ID is the cloud ID of the resource.
+	ID string `json:"id"`
+
+	// Name is the name of the resource.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Shared is whether the resource is shared with other clusters, like a
+	// VPC that kops didn't create.
+	// +optional
+	Shared bool `json:"shared,omitempty"`
+
+	// Retained is whether the resource is kept when the cluster is deleted
+	// because its lifecycle override leaves it to another system.
+	// +optional
+	Retained bool `json:"retained,omitempty"`
+}
+
+// A PreDeleteObservation is the progress of what is done to a cluster before
+// its cloud resources are deleted.
+type PreDeleteObservation struct {
+	// StartedTime is the time deleting the cluster started.
+	StartedTime metav1.Time `json:"startedTime"`
+
+	// Notified is true once the pre-delete webhook accepted the
+	// notification.
+	// +optional
+	Notified bool `json:"notified,omitempty"`
+
+	// Drained is true once the nodes were drained, or draining them timed
+	// out or could not be done.
+	// +optional
+	Drained bool `json:"drained,omitempty"`
+
+	// RemainingPods is the number of pods that were still to be evicted
+	// when the nodes were last drained.
+	// +optional
+	RemainingPods int `json:"remainingPods,omitempty"`
+}
+
+// An EtcdBackupObservation is a requested backup of the etcd clusters of a
+// cluster.
+type EtcdBackupObservation struct {
+	// Request is the value of the etcd backup annotation.
+	Request string `json:"request"`
+
+	// Time is the time the request was observed.
+	Time metav1.Time `json:"time"`
+
+	// Backups are the names of the first backups taken after the request,
+	// by etcd cluster.
+	Backups map[string]string `json:"backups,omitempty"`
+
+	// Completed is true once every etcd cluster has been backed up.
+	Completed bool `json:"completed"`
+}
+
+// An EtcdRestorePhase is a phase of an etcd restore.
+type EtcdRestorePhase string
+
+// Etcd restore phases.
+const (
+	// EtcdRestorePhaseRollingControlPlane means the restore commands were
+	// written and the control plane instances are to be replaced so that
+	// etcd-manager picks them up.
+	EtcdRestorePhaseRollingControlPlane EtcdRestorePhase = "RollingControlPlane"
+
+	// EtcdRestorePhaseVerifying means the control plane was replaced and the
+	// cluster is awaited to validate with healthy etcd clusters.
+	EtcdRestorePhaseVerifying EtcdRestorePhase = "Verifying"
+
+	// EtcdRestorePhaseCompleted means the restored cluster validated.
+	EtcdRestorePhaseCompleted EtcdRestorePhase = "Completed"
+)
+
+// An EtcdRestoreObservation is a requested restore of the etcd clusters of a
+// cluster.
+type EtcdRestoreObservation struct {
+	// Request is the value of the etcd restore annotation.
+	Request string `json:"request"`
+
+	// Phase is the phase of the restore.
+	Phase EtcdRestorePhase `json:"phase"`
+
+	// Backups are the names of the backups restored, by etcd cluster.
+	Backups map[string]string `json:"backups,omitempty"`
+
+	// Time is the time the restore was started.
+	Time metav1.Time `json:"time"`
+}
+
+// An EtcdMemberObservation is the health of an etcd member.
+type EtcdMemberObservation struct {
+	// Node is the name of the control plane node the member runs on.
+	Node string `json:"node"`
+
+	// Healthy is true if the etcd-manager of the member is ready.
+	Healthy bool `json:"healthy"`
+}
+
+// An EtcdClusterObservation is the health of an etcd cluster managed by
+// etcd-manager.
+type EtcdClusterObservation struct {
+	// Name is the name of the etcd cluster, e.g. main or events.
+	Name string `json:"name"`
+
+	// Members is the number of desired members of the etcd cluster.
+	Members int `json:"members"`
+
+	// HealthyMembers is the number of members that are healthy.
+	HealthyMembers int `json:"healthyMembers"`
+
+	// MemberStatus is the health of each running member.
+	MemberStatus []EtcdMemberObservation `json:"memberStatus,omitempty"`
+
+	// LastBackupTime is the time of the latest backup in the backup store.
+	LastBackupTime *metav1.Time `json:"lastBackupTime,omitempty"`
+}
+
+// An InfrastructureObservation identifies the cloud infrastructure kops
+// created or adopted for a cluster, so that other resources can reference it.
+type InfrastructureObservation struct {
+	// VPCID is the ID of the VPC of the cluster.
+	VPCID string `json:"vpcID,omitempty"`
+
+	// SubnetIDs are the IDs of the subnets of the cluster.
+	SubnetIDs []string `json:"subnetIDs,omitempty"`
+
+	// SecurityGroupIDs are the IDs of the security groups of the cluster.
+	SecurityGroupIDs []string `json:"securityGroupIDs,omitempty"`
+
+	// APILoadBalancerDNSName is the DNS name of the load balancer in front of
+	// the Kubernetes API server, if any.
+	APILoadBalancerDNSName string `json:"apiLoadBalancerDNSName,omitempty"`
+
+	// APILoadBalancerARN is the ARN of the load balancer in front of the
+	// Kubernetes API server, if it is a network load balancer. Classic load
+	// balancers have no ARN.
+	APILoadBalancerARN string `json:"apiLoadBalancerARN,omitempty"`
+
+	// APILoadBalancerHostedZoneID is the ID of the hosted zone of the DNS
+	// name of the API load balancer, used to create alias records to it.
+	APILoadBalancerHostedZoneID string `json:"apiLoadBalancerHostedZoneID,omitempty"`
+
+	// BastionLoadBalancerDNSName is the DNS name of the load balancer in
+	// front of the bastion, if the cluster has one.
+	BastionLoadBalancerDNSName string `json:"bastionLoadBalancerDNSName,omitempty"`
+
+	// InstanceProfileARNs are the ARNs of the IAM instance profiles of the
+	// instance groups of the cluster.
+	InstanceProfileARNs []string `json:"instanceProfileARNs,omitempty"`
+
+	// DNSZoneID is the ID of the hosted zone kops created the DNS records of
+	// the cluster in, if any.
+	DNSZoneID string `json:"dnsZoneID,omitempty"`
+
+	// DNSRecords are the DNS records kops created for the cluster.
+	DNSRecords []DNSRecordObservation `json:"dnsRecords,omitempty"`
+}
+
+// A DNSRecordObservation is a DNS record kops created for a cluster.
+type DNSRecordObservation struct {
+	// Name of the record, without a trailing dot.
+	Name string `json:"name"`
+
+	// Type of the record, e.g. A.
+	Type string `json:"type"`
+}
+
+// A RoleReadiness is the number of ready and desired nodes of a role.
+type RoleReadiness struct {
+	Ready   int `json:"ready"`
+	Desired int `json:"desired"`
+
+	// Summary is Ready and Desired formatted as ready/desired.
+	Summary string `json:"summary"`
+}
+
+// A NodeReadiness summarizes the readiness of the nodes of a cluster by role.
+type NodeReadiness struct {
+	// ControlPlane nodes are the master and API server nodes.
+	ControlPlane RoleReadiness `json:"controlPlane"`
+
+	// Workers are the regular nodes.
+	Workers RoleReadiness `json:"workers"`
+}
+
+// ConnectionSecretFormat is the layout of the connection details published
+// for a Kops cluster.
+type ConnectionSecretFormat string
+
+// Supported connection secret formats.
+const (
+	// ConnectionSecretFormatCrossplane publishes the kubeconfig under the
+	// standard Crossplane "kubeconfig" key.
+	ConnectionSecretFormatCrossplane ConnectionSecretFormat = "Crossplane"
+
+	// ConnectionSecretFormatClusterAPI additionally publishes the kubeconfig
+	// under the "value" key used by Cluster API <cluster>-kubeconfig secrets.
+	ConnectionSecretFormatClusterAPI ConnectionSecretFormat = "ClusterAPI"
+)
+
+// An ApplyTarget is how changes to a Kops cluster are applied to the cloud.
+type ApplyTarget string
+
+// Supported apply targets.
+const (
+	// ApplyTargetDirect applies changes to the cloud directly.
+	ApplyTargetDirect ApplyTarget = "direct"
+
+	// ApplyTargetTerraform renders changes as Terraform to be reviewed and
+	// applied by an external Terraform pipeline.
+	ApplyTargetTerraform ApplyTarget = "terraform"
+)
+
+// A TaskLifecycle is how kops treats the cloud resources of a kind of task.
+// +kubebuilder:validation:Enum=Sync;Ignore;WarnIfInsufficientAccess;ExistsAndValidates;ExistsAndWarnIfChanges
+type TaskLifecycle string
+
+// Supported task lifecycles.
+const (
+	// TaskLifecycleSync creates and updates the resources. This is the
+	// default.
+	TaskLifecycleSync TaskLifecycle = "Sync"
+
+	// TaskLifecycleIgnore leaves the resources alone.
+	TaskLifecycleIgnore TaskLifecycle = "Ignore"
+
+	// TaskLifecycleWarnIfInsufficientAccess creates and updates the
+	// resources, but only warns if they can't be read.
+	TaskLifecycleWarnIfInsufficientAccess TaskLifecycle = "WarnIfInsufficientAccess"
+
+	// TaskLifecycleExistsAndValidates requires the resources to exist as
+	// specified.
+	TaskLifecycleExistsAndValidates TaskLifecycle = "ExistsAndValidates"
+
+	// TaskLifecycleExistsAndWarnIfChanges requires the resources to exist,
+	// and only warns if they differ from their spec.
+	TaskLifecycleExistsAndWarnIfChanges TaskLifecycle = "ExistsAndWarnIfChanges"
+)
+
+// A ReachabilityMode is how the provider reaches the API server of a Kops
+// cluster to validate it.
+type ReachabilityMode string
+
+// Supported reachability modes.
+const (
+	// ReachabilityDirect connects to the API server directly.
+	ReachabilityDirect ReachabilityMode = "direct"
+
+	// ReachabilitySkipValidation never connects to the API server. The
+	// cluster is considered available once it exists.
+	ReachabilitySkipValidation ReachabilityMode = "skipValidation"
+
+	// ReachabilityViaProxy connects to the API server through a proxy.
+	ReachabilityViaProxy ReachabilityMode = "viaProxy"
+
+	// ReachabilityAssumeReachable connects to the API server directly, but
+	// considers the cluster available if it can't be reached. Failures
+	// reported by a validation that did reach it still count.
+	ReachabilityAssumeReachable ReachabilityMode = "assumeReachable"
+
+	// ReachabilityCloudOnly never connects to the API server, like
+	// skipValidation, and replaces instances for rollouts without waiting
+	// for the cluster to validate in between, like kops rolling-update
+	// --cloudonly. It recovers clusters whose control plane is broken.
+	ReachabilityCloudOnly ReachabilityMode = "cloudOnly"
+)
+
+// ReachabilityParameters configure how the provider reaches the API server
+// of a Kops cluster, e.g. one that is only reachable from inside its VPC.
+type ReachabilityParameters struct {
+	// Mode is how the API server is reached.
+	// +kubebuilder:validation:Enum=direct;skipValidation;viaProxy;assumeReachable;cloudOnly
+	// +kubebuilder:default=direct
+	// +optional
+	Mode ReachabilityMode `json:"mode,omitempty"`
+
+	// ProxyURL is the URL of the HTTP or SOCKS5 proxy the API server is
+	// reached through in viaProxy mode, e.g. socks5://10.0.0.10:1080.
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty"`
+
+	// TokenSecretRef refers to a bearer token, e.g. of a service account in
+	// the cluster, the API server is authenticated to with instead of a
+	// client certificate the provider issues from the cluster's keystore.
+	// The server and CA are still read from the state store.
+	// +optional
+	TokenSecretRef *xpv1.SecretKeySelector `json:"tokenSecretRef,omitempty"`
+
+	// KubeconfigSecretRef refers to a kubeconfig the API server is reached
+	// and authenticated to with instead of a client certificate the
+	// provider issues from the cluster's keystore. Its current context is
+	// used.
+	// +optional
+	KubeconfigSecretRef *xpv1.SecretKeySelector `json:"kubeconfigSecretRef,omitempty"`
+}
+
+// An ImageSource is where the latest image of an instance group is looked
+// up.
+type ImageSource string
+
+// Supported image sources.
+const (
+	// ImageSourceChannel is the image the kops channel of the cluster lists
+	// for its cloud, Kubernetes version and architecture.
+	ImageSourceChannel ImageSource = "channel"
+
+	// ImageSourceSSMParameter is the AMI ID stored in an AWS SSM parameter.
+	ImageSourceSSMParameter ImageSource = "ssmParameter"
+)
+
+// ImageTrackingParameters keep the image of an instance group at the latest
+// image of a source. A newer image replaces the instances of the instance
+// group like any other change to its image.
+type ImageTrackingParameters struct {
+	// InstanceGroup is the name of the instance group.
+	InstanceGroup string `json:"instanceGroup"`
+
+	// Source is where the latest image is looked up.
+	// +kubebuilder:validation:Enum=channel;ssmParameter
+	Source ImageSource `json:"source"`
+
+	// SSMParameter is the name of the SSM parameter holding the latest AMI
+	// ID in ssmParameter mode, e.g.
+	// /aws/service/canonical/ubuntu/server/22.04/stable/current/amd64/hvm/ebs-gp2/ami-id.
+	// +optional
+	SSMParameter string `json:"ssmParameter,omitempty"`
+
+	// Architecture is the architecture of the image looked up in the
+	// channel.
+	// +kubebuilder:validation:Enum=amd64;arm64
+	// +kubebuilder:default=amd64
+	// +optional
+	Architecture string `json:"architecture,omitempty"`
+
+	// Interval is how often the source is checked for a newer image.
+	// Defaults to 24h.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// PreflightParameters configure the optional checks run before a Kops cluster
+// is first applied.
+type PreflightParameters struct {
+	// Permissions verifies that the provider's cloud credentials allow every
+	// action needed to create the cluster, and that the state bucket can be
+	// written to. Simulating permissions is only supported on AWS, and needs
+	// iam:SimulatePrincipalPolicy and sts:GetCallerIdentity.
+	// +optional
+	Permissions bool `json:"permissions,omitempty"`
+
+	// Quotas compares the cloud quotas of the provider's account with the
+	// vCPUs, elastic IPs and autoscaling groups the cluster needs, and warns
+	// about those that are too low. Quotas never fail the preflight checks.
+	// Checking quotas is only supported on AWS, and needs
+	// servicequotas:GetServiceQuota, ec2:DescribeInstances,
+	// ec2:DescribeInstanceTypes, ec2:DescribeAddresses,
+	// ec2:DescribeAccountAttributes and autoscaling:DescribeAccountLimits.
+	// +optional
+	Quotas bool `json:"quotas,omitempty"`
+}
+
+// AddonParameters declare an addon of a Kops cluster.
+type AddonParameters struct {
+	// Name of the addon, unique within the cluster.
+	Name string `json:"name"`
+
+	// Manifest of the addon, as one or more YAML documents.
+	Manifest string `json:"manifest"`
+
+	// Namespace the objects of the addon are created in, if they don't
+	// specify one.
+	// +optional
+	Namespace *string `json:"namespace,omitempty"`
+
+	// Selector matches the labels of the objects of the addon. Defaults to
+	// k8s-addon: <name>.
+	// +optional
+	Selector map[string]string `json:"selector,omitempty"`
+}
+
+// A FileAssetParameters is a file written to the instances of a cluster,
+// sourced from a key of either a ConfigMap or a Secret.
+type FileAssetParameters struct {
+	// Name of the file asset, unique within the cluster.
+	Name string `json:"name"`
+
+	// Path the file is written to on the instances.
+	Path string `json:"path"`
+
+	// Roles of the instances the file is written to. Defaults to every
+	// role.
+	// +optional
+	Roles []kops.InstanceGroupRole `json:"roles,omitempty"`
+
+	// ConfigMapRef selects the ConfigMap key holding the content of the
+	// file.
+	// +optional
+	ConfigMapRef *ConfigMapKeySelector `json:"configMapRef,omitempty"`
+
+	// SecretRef selects the Secret key holding the content of the file.
+	// +optional
+	SecretRef *xpv1.SecretKeySelector `json:"secretRef,omitempty"`
+}
+
+// EgressProxyParameters configure the HTTP proxy the instances of a cluster
+// reach the internet through, e.g. in a corporate network.
+type EgressProxyParameters struct {
+	// Host of the proxy, without a scheme or credentials.
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// Port of the proxy.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int `json:"port"`
+
+	// Excludes are hosts, domains and CIDRs reached without the proxy, in
+	// addition to those kops always excludes, like the cluster's own
+	// networks and the cloud metadata service.
+	// +optional
+	Excludes []string `json:"excludes,omitempty"`
+
+	// CredentialsSecretRef selects the Secret key holding the user:password
+	// the proxy is authenticated to with.
+	// +optional
+	CredentialsSecretRef *xpv1.SecretKeySelector `json:"credentialsSecretRef,omitempty"`
+}
+
+// A ConfigMapKeySelector selects a key of a ConfigMap.
+type ConfigMapKeySelector struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Namespace of the ConfigMap.
+	Namespace string `json:"namespace"`
+
+	// Key within the ConfigMap.
+	Key string `json:"key"`
+}
+
+// A CertificateReference refers to a cluster scoped managed resource that
+// represents a certificate.
+type CertificateReference struct {
+	// APIVersion of the referenced resource.
+	// +kubebuilder:default="acm.aws.crossplane.io/v1beta1"
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Kind of the referenced resource.
+	// +kubebuilder:default=Certificate
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the referenced resource.
+	Name string `json:"name"`
+}
+
+// An InstanceGroupPatchType is the type of a patch of an instance group.
+type InstanceGroupPatchType string
+
+// Supported instance group patch types.
+const (
+	// InstanceGroupPatchJSON6902 is a JSON patch as defined by RFC 6902.
+	InstanceGroupPatchJSON6902 InstanceGroupPatchType = "JSON6902"
+
+	// InstanceGroupPatchStrategicMerge is a strategic merge patch. Instance
+	// group specs don't declare merge keys, so lists are replaced.
+	InstanceGroupPatchStrategicMerge InstanceGroupPatchType = "StrategicMerge"
+)
+
+// An InstanceGroupPatch patches the spec of an instance group of a Kops,
+// whether it is listed in instanceGroupSpec or generated.
+type InstanceGroupPatch struct {
+	// InstanceGroup is the name of the instance group to patch.
+	// +kubebuilder:validation:MinLength=1
+	InstanceGroup string `json:"instanceGroup"`
+
+	// Type of the patch.
+	// +kubebuilder:validation:Enum=JSON6902;StrategicMerge
+	// +kubebuilder:default=StrategicMerge
+	// +optional
+	Type InstanceGroupPatchType `json:"type,omitempty"`
+
+	// Patch of the spec of the instance group, in YAML or JSON. The paths
+	// of JSON6902 patches are relative to the spec, e.g. /maxSize.
+	// +kubebuilder:validation:MinLength=1
+	Patch string `json:"patch"`
+}
+
+// A HealthProbe is a check a cluster must pass, in addition to kops
+// validation, to be ready. Exactly one of Deployment and HTTPGet must be set.
+type HealthProbe struct {
+	// Name of the probe, reported in validation failures.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Deployment is a Deployment of the cluster that must be available.
+	// +optional
+	Deployment *DeploymentProbe `json:"deployment,omitempty"`
+
+	// HTTPGet is a URL that must return 200 when the provider gets it.
+	// +optional
+	HTTPGet *HTTPGetProbe `json:"httpGet,omitempty"`
+}
+
+// A DeploymentProbe checks that a Deployment has all its replicas updated
+// and available.
+type DeploymentProbe struct {
+	// Namespace of the Deployment.
+	// +kubebuilder:default=kube-system
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the Deployment.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// An HTTPGetProbe checks that a URL returns 200.
+type HTTPGetProbe struct {
+	// URL to get, e.g. https://grafana.example.com/api/health.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+}
+
+// A DependencyReference refers to a cluster scoped managed resource the
+// cluster of a Kops depends on.
+type DependencyReference struct {
+	// APIVersion of the referenced resource, e.g. ec2.aws.crossplane.io/v1beta1.
+	// +kubebuilder:validation:MinLength=1
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the referenced resource, e.g. VPC.
+	// +kubebuilder:validation:MinLength=1
+	Kind string `json:"kind"`
+
+	// Name of the referenced resource.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// ConnectionRBACParameters restrict what the kubeconfig published as the
+// connection details of a Kops can do in its cluster.
+type ConnectionRBACParameters struct {
+	// Group the client certificate of the published kubeconfig is issued
+	// into, instead of system:masters.
+	// +kubebuilder:validation:MinLength=1
+	Group string `json:"group"`
+
+	// Rules of the ClusterRole the provider binds to the group in the
+	// cluster. Defaults to reading all resources.
+	// +optional
+	Rules []rbacv1.PolicyRule `json:"rules,omitempty"`
+}
+
+// CloudTagParameters configure the tags kops applies to the cloud resources
+// of a cluster. kops always tags them with KubernetesCluster and
+// kubernetes.io/cluster/<cluster name>, and the resources of instance groups
+// with kops.k8s.io/instancegroup; kops and the cloud provider rely on these
+// tags, so they can't be renamed or removed.
+type CloudTagParameters struct {
+	// Tags are applied to every cloud resource of the cluster, e.g. the tags
+	// an organizational tagging policy requires. They take precedence over
+	// the cloudLabels of clusterSpec and of instance groups.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// ClusterNameKey is a further tag every cloud resource of the cluster is
+	// tagged with the full name of the cluster under.
+	// +optional
+	ClusterNameKey string `json:"clusterNameKey,omitempty"`
+
+	// InstanceGroupKey is a further tag the cloud resources of each instance
+	// group are tagged with the name of the instance group under.
+	// +optional
+	InstanceGroupKey string `json:"instanceGroupKey,omitempty"`
+}
+
+// StateReplicaParameters configure the replication of the state of a cluster
+// to a replica state bucket.
+type StateReplicaParameters struct {
+	// Bucket is the state bucket the state of the cluster is replicated to,
+	// e.g. s3://my-kops-state-dr. It should be in a different region than
+	// stateBucket. The state is replicated below the name of the cluster, so
+	// that kops can be pointed at the bucket to rebuild the cluster's
+	// management plane; the configBase of the replicated cluster still
+	// refers to stateBucket.
+	// +kubebuilder:validation:MinLength=1
+	Bucket string `json:"bucket"`
+}
+
+// NotificationFormat is the format notifications are sent in.
+type NotificationFormat string
+
+// Notification formats.
+const (
+	// NotificationFormatJSON sends notifications as plain JSON objects.
+	NotificationFormatJSON NotificationFormat = "JSON"
+
+	// NotificationFormatCloudEvents sends notifications as CloudEvents in
+	// structured content mode.
+	NotificationFormatCloudEvents NotificationFormat = "CloudEvents"
+)
+
+// NotificationParameters configure where notifications of the lifecycle
+// transitions of a Kops cluster are sent.
+type NotificationParameters struct {
+	// URL notifications are POSTed to.
+	URL string `json:"url"`
+
+	// Format notifications are sent in.
+	// +kubebuilder:validation:Enum=JSON;CloudEvents
+	// +kubebuilder:default=JSON
+	// +optional
+	Format NotificationFormat `json:"format,omitempty"`
+}
+
+// PreDeleteParameters configure what is done to the cluster of a Kops before
+// its cloud resources are deleted.
+type PreDeleteParameters struct {
+	// DrainNodes cordons every node and evicts its pods, other than those of
+	// DaemonSets and static pods, so that workloads shut down gracefully
+	// before their instances are terminated. Evictions respect
+	// PodDisruptionBudgets. Draining is best effort: the cluster is deleted
+	// once the drain timeout passes, even if pods remain or its API server
+	// can't be reached.
+	// +optional
+	DrainNodes bool `json:"drainNodes,omitempty"`
+
+	// DrainTimeout is how long to wait for the pods to be evicted before
+	// deleting the cluster anyway. Defaults to 10m.
+	// +optional
+	DrainTimeout *metav1.Duration `json:"drainTimeout,omitempty"`
+
+	// Webhook is sent a DeletionStarting notification before the cluster is
+	// drained and deleted, e.g. to deregister it from continuous delivery or
+	// monitoring. Deleting the cluster waits until the webhook accepts the
+	// notification.
+	// +optional
+	Webhook *NotificationParameters `json:"webhook,omitempty"`
+}
+
+// SimpleParameters describe a cluster the way the flags of kops create
+// cluster do.
+type SimpleParameters struct {
+	// Zones the cluster runs in. They are expanded like the zones parameter.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=7
+	Zones []string `json:"zones"`
+
+	// KubernetesVersion of the cluster. Required unless
+	// clusterSpec.kubernetesVersion is set.
+	// +optional
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+
+	// MasterCount is the number of control plane instances, each in an
+	// instance group named master-<zone> in the next of the zones. Defaults
+	// to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MasterCount *int32 `json:"masterCount,omitempty"`
+
+	// MasterSize is the machine type of the control plane instances.
+	// +kubebuilder:default="t3.medium"
+	// +optional
+	MasterSize string `json:"masterSize,omitempty"`
+
+	// NodeCount is the number of nodes, spread over instance groups named
+	// nodes-<zone> in each of the zones. Defaults to one node per zone.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	NodeCount *int32 `json:"nodeCount,omitempty"`
+
+	// NodeSize is the machine type of the nodes.
+	// +kubebuilder:default="t3.medium"
+	// +optional
+	NodeSize string `json:"nodeSize,omitempty"`
+
+	// Networking is the networking provider of the cluster.
+	// +kubebuilder:validation:Enum=kubenet;calico;cilium;amazonvpc;canal;flannel;weave
+	// +kubebuilder:default=calico
+	// +optional
+	Networking string `json:"networking,omitempty"`
 }
 
 // A KopsParameters are the parameters of a Kops.
@@ -38,13 +1307,272 @@ type KopsParameters struct {
 	InstanceGroupSpec []kops.InstanceGroupSpec `json:"instanceGroupSpec"`
 	Domain            string                   `json:"domain"`
 	StateBucket       string                   `json:"stateBucket"`
-	Region            string                   `json:"region"`
+
+	// Region of the cluster. It is only used to list the cloud resources of
+	// clusters whose subnets have no zone or region, from which it is derived
+	// otherwise.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// ConnectionSecretFormat controls the keys the kubeconfig is published
+	// under. Use ClusterAPI with a connection secret named
+	// <cluster>-kubeconfig for tooling that consumes Cluster API secrets.
+	// +kubebuilder:validation:Enum=Crossplane;ClusterAPI
+	// +kubebuilder:default=Crossplane
+	// +optional
+	ConnectionSecretFormat ConnectionSecretFormat `json:"connectionSecretFormat,omitempty"`
+
+	// CertificateExpiryThreshold is how long before the cluster CA
+	// certificate expires the CertificatesExpiring condition becomes true.
+	// Kubeconfig client certificates are reissued on every observation and
+	// are not subject to it.
+	// +kubebuilder:default="720h"
+	// +optional
+	CertificateExpiryThreshold *metav1.Duration `json:"certificateExpiryThreshold,omitempty"`
+
+	// KubernetesAPICertificateTTL is the validity of the client certificate
+	// of the kubeconfig published in the connection details. The provider
+	// and the ProviderConfig may cap it. Defaults to 18h.
+	// +optional
+	KubernetesAPICertificateTTL *metav1.Duration `json:"kubernetesApiCertificateTTL,omitempty"`
+
+	// RecordClusterEvents records events in the kube-system namespace of the
+	// cluster when the provider applies changes to it, so that node churn
+	// can be correlated with provider activity from within the cluster.
+	// +optional
+	RecordClusterEvents bool `json:"recordClusterEvents,omitempty"`
+
+	// Target is how changes are applied to the cloud. With terraform the
+	// provider renders Terraform to TerraformOutputPath instead of applying
+	// changes, while still managing the kops state store. Cloud resources are
+	// then left to Terraform, including on deletion.
+	// +kubebuilder:validation:Enum=direct;terraform
+	// +kubebuilder:default=direct
+	// +optional
+	Target ApplyTarget `json:"target,omitempty"`
+
+	// TerraformOutputPath is the VFS path, e.g. s3://bucket/prefix, rendered
+	// Terraform is written to. Defaults to terraform/ in the cluster's state
+	// store.
+	// +optional
+	TerraformOutputPath string `json:"terraformOutputPath,omitempty"`
+
+	// LifecycleOverrides override how kops treats the cloud resources of
+	// kinds of task, keyed by task name like kops update cluster
+	// --lifecycle-overrides, e.g. IAMRole=ExistsAndWarnIfChanges and
+	// Subnet=Ignore where another system owns IAM or networking. Resources
+	// of tasks whose lifecycle is Ignore, ExistsAndValidates or
+	// ExistsAndWarnIfChanges are also kept when the cluster is deleted.
+	// +optional
+	LifecycleOverrides map[string]TaskLifecycle `json:"lifecycleOverrides,omitempty"`
+
+	// RenderedSpecSecretRef is a secret the fully populated cluster and
+	// instance group specs are written to under the cluster.yaml key after
+	// every successful apply, equivalent to kops get -o yaml.
+	// +optional
+	RenderedSpecSecretRef *xpv1.SecretReference `json:"renderedSpecSecretRef,omitempty"`
+
+	// RecreateMissingInfrastructure re-applies the cluster when every one of
+	// its instances is gone from the cloud, e.g. because its autoscaling
+	// groups were deleted by hand. Otherwise the InfrastructureMissing
+	// condition is set and the cluster is left as is.
+	// +optional
+	RecreateMissingInfrastructure bool `json:"recreateMissingInfrastructure,omitempty"`
+
+	// OnDemandFallback runs spot instance groups that can't launch instances
+	// for lack of spot capacity on on-demand instances for an hour, after
+	// which spot instances are tried again. Otherwise only the
+	// InsufficientCapacity condition is set.
+	// +optional
+	OnDemandFallback bool `json:"onDemandFallback,omitempty"`
+
+	// VerifyGPUNodes checks during validation that every node with Nvidia
+	// GPUs advertises them, which it only does once the Nvidia driver is
+	// installed and the device plugin runs. Nodes that don't fail
+	// validation.
+	// +optional
+	VerifyGPUNodes bool `json:"verifyGPUNodes,omitempty"`
+
+	// HealthProbes are checks the cluster must pass, in addition to kops
+	// validation, to be ready, e.g. because addons it relies on are only
+	// functional some time after it validates. Probes that fail fail
+	// validation.
+	// +optional
+	HealthProbes []HealthProbe `json:"healthProbes,omitempty"`
+
+	// InstanceConnectionHints publishes the IDs, SSM targets and private IPs
+	// of the instances of every instance group in the status, so that nodes
+	// of private clusters can be reached without a public bastion.
+	// +optional
+	InstanceConnectionHints bool `json:"instanceConnectionHints,omitempty"`
+
+	// ImageTracking keeps the images of instance groups at the latest image
+	// of a kops channel or SSM parameter, overriding their image.
+	// +optional
+	ImageTracking []ImageTrackingParameters `json:"imageTracking,omitempty"`
+
+	// RecommendUpgrades evaluates the kops channel of the cluster daily
+	// against the Kubernetes version of the cluster and the kops version of
+	// the provider, and reports the upgrades it recommends in the status.
+	// +optional
+	RecommendUpgrades bool `json:"recommendUpgrades,omitempty"`
+
+	// EstimateCost estimates the hourly on-demand cost of the instances and
+	// volumes of the cluster from public cloud pricing, and reports it in
+	// the status. Only AWS is supported.
+	// +optional
+	EstimateCost bool `json:"estimateCost,omitempty"`
+
+	// Preflight configures the optional checks run before the cluster is
+	// first applied.
+	// +optional
+	Preflight *PreflightParameters `json:"preflight,omitempty"`
+
+	// Addons are delivered to the cluster through a kops addon channel in
+	// its state store, which the control plane applies. An addon is applied
+	// again whenever its manifest changes. Objects of a removed addon are
+	// left in the cluster.
+	// +optional
+	Addons []AddonParameters `json:"addons,omitempty"`
+
+	// APICertificateRef refers to a managed resource whose external name is
+	// the ARN of the ACM certificate served by the API load balancer, e.g. a
+	// Certificate of provider-aws. The ARN is resolved into
+	// clusterSpec.api.loadBalancer.sslCertificate once the resource is ready.
+	// The provider must be allowed to get resources of the referenced kind.
+	// +optional
+	APICertificateRef *CertificateReference `json:"apiCertificateRef,omitempty"`
+
+	// DependsOn refers to managed resources the cluster needs before it can
+	// be created, such as its hosted zone, VPC or state bucket. Creating the
+	// cluster waits for all of them to be ready, rather than failing while
+	// they are still being created. The provider must be allowed to get
+	// resources of the referenced kinds.
+	// +optional
+	DependsOn []DependencyReference `json:"dependsOn,omitempty"`
+
+	// EncryptionConfigSecretRef refers to the EncryptionConfiguration the
+	// API servers of the cluster encrypt resources at rest with, which
+	// requires clusterSpec.encryptionConfig to be true. It is written to the
+	// cluster's kops secret store before the cluster is applied. When it
+	// changes the control plane instances are replaced one at a time, each
+	// once the cluster validates, so that the API servers load it.
+	// +optional
+	EncryptionConfigSecretRef *xpv1.SecretKeySelector `json:"encryptionConfigSecretRef,omitempty"`
+
+	// DockerConfigSecretRef refers to a Docker config.json holding the
+	// credentials nodes pull images from private registries with. It is
+	// written to the dockerconfig secret of the cluster's kops secret store
+	// before the cluster is applied, which nodes read when they boot.
+	// +optional
+	DockerConfigSecretRef *xpv1.SecretKeySelector `json:"dockerConfigSecretRef,omitempty"`
+
+	// RollNodesOnDockerConfigChange replaces the nodes of the cluster one at
+	// a time, each once the cluster validates, when the Docker config
+	// changes, so that running nodes pick it up. Otherwise only nodes that
+	// boot later do.
+	// +optional
+	RollNodesOnDockerConfigChange bool `json:"rollNodesOnDockerConfigChange,omitempty"`
+
+	// FileAssets are files written to the instances of the cluster, sourced
+	// from ConfigMaps or Secrets, e.g. the audit policy or OIDC CA the
+	// kube-apiserver config refers to. Their content is added to the
+	// fileAssets of the cluster spec, which is stored in the state store,
+	// before the cluster is applied.
+	// +optional
+	FileAssets []FileAssetParameters `json:"fileAssets,omitempty"`
+
+	// EgressProxy is the HTTP proxy the instances of the cluster reach the
+	// internet through. It is set as clusterSpec.egressProxy, which it can't
+	// be used together with, before the cluster is applied. Changing it
+	// replaces every instance group's instances.
+	// +optional
+	EgressProxy *EgressProxyParameters `json:"egressProxy,omitempty"`
+
+	// RollNodesOnCNIChange replaces the nodes of the cluster one at a time,
+	// each once the cluster validates, when a change to clusterSpec.networking
+	// is applied, so that every CNI agent restarts with the changed config
+	// on a fresh node.
+	// +optional
+	RollNodesOnCNIChange bool `json:"rollNodesOnCNIChange,omitempty"`
+
+	// ConnectionRBAC issues the kubeconfig published as connection details
+	// into a group that is bound to a ClusterRole the provider maintains in
+	// the cluster, rather than into system:masters. The ClusterRole and its
+	// binding are left in the cluster when this is unset again.
+	// +optional
+	ConnectionRBAC *ConnectionRBACParameters `json:"connectionRBAC,omitempty"`
+
+	// Reachability configures how the provider reaches the API server of the
+	// cluster to validate it. Defaults to connecting directly.
+	// +optional
+	Reachability *ReachabilityParameters `json:"reachability,omitempty"`
+
+	// Zones the cluster runs in, like the --zones flag of kops create
+	// cluster. A subnet is added to clusterSpec in each zone, with a CIDR
+	// allocated from clusterSpec.networkCIDR, and main and events etcd
+	// clusters with a member on an instance group named master-<zone> in
+	// each zone, unless clusterSpec sets its own. Can't be set alongside
+	// clusterSpec.subnets.
+	// +kubebuilder:validation:MaxItems=7
+	// +optional
+	Zones []string `json:"zones,omitempty"`
+
+	// Simple expands parameters like the flags of kops create cluster into
+	// the cluster. Fields clusterSpec leaves unset are filled in, and
+	// instance groups are generated if instanceGroupSpec is empty. It can't
+	// be set alongside zones.
+	// +optional
+	Simple *SimpleParameters `json:"simple,omitempty"`
+
+	// Notifications are sent when the cluster becomes ready, fails
+	// validation, has an update applied, and is deleted. Failing to send a
+	// notification is reported as an event; it is not retried.
+	// +optional
+	Notifications *NotificationParameters `json:"notifications,omitempty"`
+
+	// PreDelete configures what is done to the cluster before its cloud
+	// resources are deleted.
+	// +optional
+	PreDelete *PreDeleteParameters `json:"preDelete,omitempty"`
+
+	// CloudTags are applied to the cloud resources of the cluster in addition
+	// to the tags kops always applies.
+	// +optional
+	CloudTags *CloudTagParameters `json:"cloudTags,omitempty"`
+
+	// Overrides set fields of the cluster spec, in order, on top of
+	// clusterSpec and the fields generated from the other parameters, like
+	// kops set cluster does. Each is a dotted path from the cluster and a
+	// value, e.g. spec.kubelet.maxPods=120, so that compositions can tweak a
+	// shared spec without repeating it.
+	// +optional
+	Overrides []string `json:"overrides,omitempty"`
+
+	// InstanceGroupPatches are applied, in order, to the instance groups
+	// they name once all instance groups have been generated and defaulted,
+	// so that compositions can adjust a shared instance group template,
+	// e.g. its taints, labels or size, per environment.
+	// +optional
+	InstanceGroupPatches []InstanceGroupPatch `json:"instanceGroupPatches,omitempty"`
+
+	// StateReplica replicates the state of the cluster to a replica state
+	// bucket after every successful apply, for disaster recovery. Failing to
+	// replicate is reported as an event and retried on the next
+	// observation.
+	// +optional
+	StateReplica *StateReplicaParameters `json:"stateReplica,omitempty"`
 }
 
 // A KopsSpec defines the desired state of a Kops.
 type KopsSpec struct {
 	xpv1.ResourceSpec `json:",inline"`
 	ForProvider       KopsParameters `json:"forProvider"`
+
+	// AdditionalConnectionSecretRefs specifies further secrets the connection
+	// details are mirrored to, e.g. a GitOps namespace.
+	// +optional
+	AdditionalConnectionSecretRefs []xpv1.SecretReference `json:"additionalConnectionSecretRefs,omitempty"`
 }
 
 // A KopsStatus represents the observed state of a Kops.
@@ -58,6 +1586,9 @@ type KopsStatus struct {
 // A Kops is an example API type.
 // +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
 // +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="PHASE",type="string",JSONPath=".status.atProvider.phase"
+// +kubebuilder:printcolumn:name="CONTROL-PLANE",type="string",JSONPath=".status.atProvider.nodes.controlPlane.summary"
+// +kubebuilder:printcolumn:name="WORKERS",type="string",JSONPath=".status.atProvider.nodes.workers.summary"
 // +kubebuilder:printcolumn:name="EXTERNAL-NAME",type="string",JSONPath=".metadata.annotations.crossplane\\.io/external-name"
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:subresource:status
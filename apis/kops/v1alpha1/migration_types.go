@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// LabelKeyMigration is the label a Kops resource created by a KopsMigration
+// carries, set to the name of the KopsMigration.
+const LabelKeyMigration = "kops.crossplane.io/migration"
+
+// A MigrationPhase is the phase of a KopsMigration.
+type MigrationPhase string
+
+// Migration phases.
+const (
+	// MigrationPhaseProvisioning means the replacement cluster is being
+	// created and is not yet ready.
+	MigrationPhaseProvisioning MigrationPhase = "Provisioning"
+
+	// MigrationPhaseReady means the replacement cluster is ready, and the
+	// source cluster is kept.
+	MigrationPhaseReady MigrationPhase = "Ready"
+
+	// MigrationPhaseRetiring means the source cluster is being deleted.
+	MigrationPhaseRetiring MigrationPhase = "Retiring"
+
+	// MigrationPhaseCompleted means the source cluster was deleted.
+	MigrationPhaseCompleted MigrationPhase = "Completed"
+)
+
+// A KopsMigrationSpec defines the desired state of a KopsMigration.
+type KopsMigrationSpec struct {
+	// SourceRef references the Kops resource of the cluster to migrate from.
+	SourceRef xpv1.Reference `json:"sourceRef"`
+
+	// TargetName is the name of the Kops resource of the replacement
+	// cluster, and its external name, i.e. the replacement cluster is named
+	// <targetName>.<domain> with the domain of the source cluster.
+	// +kubebuilder:validation:MinLength=1
+	TargetName string `json:"targetName"`
+
+	// KubernetesVersion overrides the Kubernetes version of the replacement
+	// cluster, e.g. to upgrade by replacing the cluster rather than rolling
+	// its instances.
+	// +optional
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+
+	// WriteConnectionSecretToReference is the secret the connection details
+	// of the replacement cluster are written to. The replacement cluster
+	// publishes no connection details if it is omitted, so that it never
+	// overwrites those of the source cluster.
+	// +optional
+	WriteConnectionSecretToReference *xpv1.SecretReference `json:"writeConnectionSecretToRef,omitempty"`
+
+	// RetireSource deletes the Kops resource of the source cluster, and with
+	// it the cluster subject to its deletion policy, once the replacement
+	// cluster has been ready for RetireAfter.
+	// +optional
+	RetireSource bool `json:"retireSource,omitempty"`
+
+	// RetireAfter is how long the replacement cluster must have been ready
+	// before the source cluster is retired.
+	// +kubebuilder:default="1h"
+	// +optional
+	RetireAfter *metav1.Duration `json:"retireAfter,omitempty"`
+}
+
+// A KopsMigrationStatus represents the observed state of a KopsMigration.
+type KopsMigrationStatus struct {
+	xpv1.ConditionedStatus `json:",inline"`
+
+	// Phase is the phase of the migration.
+	Phase MigrationPhase `json:"phase,omitempty"`
+
+	// SourceReady is whether the source cluster is ready.
+	SourceReady bool `json:"sourceReady,omitempty"`
+
+	// TargetReady is whether the replacement cluster is ready.
+	TargetReady bool `json:"targetReady,omitempty"`
+
+	// TargetReadyTime is the time the replacement cluster first became
+	// ready.
+	TargetReadyTime *metav1.Time `json:"targetReadyTime,omitempty"`
+
+	// RetiredTime is the time the source cluster was deleted.
+	RetiredTime *metav1.Time `json:"retiredTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A KopsMigration moves from a Kops cluster to a replacement cluster created
+// from the same spec under a new name, e.g. for blue/green upgrades. The
+// source cluster is optionally retired once the replacement is ready.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="PHASE",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="SOURCE",type="string",JSONPath=".spec.sourceRef.name"
+// +kubebuilder:printcolumn:name="TARGET",type="string",JSONPath=".spec.targetName"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,kops}
+type KopsMigration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KopsMigrationSpec   `json:"spec"`
+	Status KopsMigrationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KopsMigrationList contains a list of KopsMigration
+type KopsMigrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KopsMigration `json:"items"`
+}
+
+// GetCondition of this KopsMigration.
+func (in *KopsMigration) GetCondition(ct xpv1.ConditionType) xpv1.Condition {
+	return in.Status.GetCondition(ct)
+}
+
+// SetConditions of this KopsMigration.
+func (in *KopsMigration) SetConditions(c ...xpv1.Condition) {
+	in.Status.SetConditions(c...)
+}
+
+// KopsMigration type metadata.
+var (
+	KopsMigrationKind             = reflect.TypeOf(KopsMigration{}).Name()
+	KopsMigrationGroupKind        = schema.GroupKind{Group: Group, Kind: KopsMigrationKind}.String()
+	KopsMigrationKindAPIVersion   = KopsMigrationKind + "." + SchemeGroupVersion.String()
+	KopsMigrationGroupVersionKind = SchemeGroupVersion.WithKind(KopsMigrationKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&KopsMigration{}, &KopsMigrationList{})
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	errGetAPICertificate       = "cannot get referenced API certificate"
+	errAPICertificateNotReady  = "referenced API certificate is not ready"
+	errAPICertificateNoExtName = "referenced API certificate has no external name"
+	errAPICertificateNoLBSpec  = "apiCertificateRef requires clusterSpec.api.loadBalancer"
+	errAPICertificateBadStatus = "cannot read status of referenced API certificate"
+)
+
+// ResolveReferences of this Kops.
+func (mg *Kops) ResolveReferences(ctx context.Context, c client.Reader) error {
+	ref := mg.Spec.ForProvider.APICertificateRef
+	if ref == nil {
+		return nil
+	}
+
+	api := mg.Spec.ForProvider.ClusterSpec.API
+	if api == nil || api.LoadBalancer == nil {
+		return errors.New(errAPICertificateNoLBSpec)
+	}
+
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(ref.APIVersion)
+	u.SetKind(ref.Kind)
+	if err := c.Get(ctx, types.NamespacedName{Name: ref.Name}, u); err != nil {
+		return errors.Wrap(err, errGetAPICertificate)
+	}
+
+	// A certificate that isn't issued yet can't be served, so wait for the
+	// referenced resource to become ready before using it.
+	status := xpv1.ConditionedStatus{}
+	if err := fieldpath.Pave(u.Object).GetValueInto("status", &status); err != nil && !fieldpath.IsNotFound(err) {
+		return errors.Wrap(err, errAPICertificateBadStatus)
+	}
+	if status.GetCondition(xpv1.TypeReady).Status != corev1.ConditionTrue {
+		return errors.New(errAPICertificateNotReady)
+	}
+
+	arn := meta.GetExternalName(u)
+	if arn == "" {
+		return errors.New(errAPICertificateNoExtName)
+	}
+	api.LoadBalancer.SSLCertificate = arn
+	return nil
+}
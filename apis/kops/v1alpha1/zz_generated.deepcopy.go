@@ -22,137 +22,1695 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"github.com/crossplane/crossplane-runtime/apis/common/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/kops/pkg/apis/kops"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Kops) DeepCopyInto(out *Kops) {
+func (in *APIServerSANsObservation) DeepCopyInto(out *APIServerSANsObservation) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	if in.SANs != nil {
+		in, out := &in.SANs, &out.SANs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PendingInstances != nil {
+		in, out := &in.PendingInstances, &out.PendingInstances
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastReplacedTime != nil {
+		in, out := &in.LastReplacedTime, &out.LastReplacedTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Kops.
-func (in *Kops) DeepCopy() *Kops {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APIServerSANsObservation.
+func (in *APIServerSANsObservation) DeepCopy() *APIServerSANsObservation {
 	if in == nil {
 		return nil
 	}
-	out := new(Kops)
+	out := new(APIServerSANsObservation)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Kops) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddonParameters) DeepCopyInto(out *AddonParameters) {
+	*out = *in
+	if in.Namespace != nil {
+		in, out := &in.Namespace, &out.Namespace
+		*out = new(string)
+		**out = **in
 	}
-	return nil
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddonParameters.
+func (in *AddonParameters) DeepCopy() *AddonParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(AddonParameters)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KopsList) DeepCopyInto(out *KopsList) {
+func (in *ApplyProgressObservation) DeepCopyInto(out *ApplyProgressObservation) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Kops, len(*in))
+	in.UpdatedTime.DeepCopyInto(&out.UpdatedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyProgressObservation.
+func (in *ApplyProgressObservation) DeepCopy() *ApplyProgressObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplyProgressObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplyRecord) DeepCopyInto(out *ApplyRecord) {
+	*out = *in
+	in.StartedTime.DeepCopyInto(&out.StartedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyRecord.
+func (in *ApplyRecord) DeepCopy() *ApplyRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplyRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CNIHealth) DeepCopyInto(out *CNIHealth) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CNIHealth.
+func (in *CNIHealth) DeepCopy() *CNIHealth {
+	if in == nil {
+		return nil
+	}
+	out := new(CNIHealth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CNIObservation) DeepCopyInto(out *CNIObservation) {
+	*out = *in
+	if in.PendingInstances != nil {
+		in, out := &in.PendingInstances, &out.PendingInstances
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastReplacedTime != nil {
+		in, out := &in.LastReplacedTime, &out.LastReplacedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CNIObservation.
+func (in *CNIObservation) DeepCopy() *CNIObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(CNIObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateReference) DeepCopyInto(out *CertificateReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateReference.
+func (in *CertificateReference) DeepCopy() *CertificateReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudTagParameters) DeepCopyInto(out *CloudTagParameters) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudTagParameters.
+func (in *CloudTagParameters) DeepCopy() *CloudTagParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudTagParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeySelector) DeepCopyInto(out *ConfigMapKeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeySelector.
+func (in *ConfigMapKeySelector) DeepCopy() *ConfigMapKeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConnectionRBACParameters) DeepCopyInto(out *ConnectionRBACParameters) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]rbacv1.PolicyRule, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsList.
-func (in *KopsList) DeepCopy() *KopsList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectionRBACParameters.
+func (in *ConnectionRBACParameters) DeepCopy() *ConnectionRBACParameters {
 	if in == nil {
 		return nil
 	}
-	out := new(KopsList)
+	out := new(ConnectionRBACParameters)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *KopsList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CostObservation) DeepCopyInto(out *CostObservation) {
+	*out = *in
+	if in.InstanceGroups != nil {
+		in, out := &in.InstanceGroups, &out.InstanceGroups
+		*out = make([]InstanceGroupCost, len(*in))
+		copy(*out, *in)
 	}
-	return nil
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CostObservation.
+func (in *CostObservation) DeepCopy() *CostObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(CostObservation)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KopsObservation) DeepCopyInto(out *KopsObservation) {
+func (in *DNSRecordObservation) DeepCopyInto(out *DNSRecordObservation) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsObservation.
-func (in *KopsObservation) DeepCopy() *KopsObservation {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSRecordObservation.
+func (in *DNSRecordObservation) DeepCopy() *DNSRecordObservation {
 	if in == nil {
 		return nil
 	}
-	out := new(KopsObservation)
+	out := new(DNSRecordObservation)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KopsParameters) DeepCopyInto(out *KopsParameters) {
+func (in *DeletePreviewObservation) DeepCopyInto(out *DeletePreviewObservation) {
 	*out = *in
-	in.ClusterSpec.DeepCopyInto(&out.ClusterSpec)
-	if in.InstanceGroupSpec != nil {
-		in, out := &in.InstanceGroupSpec, &out.InstanceGroupSpec
-		*out = make([]kops.InstanceGroupSpec, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]DeletePreviewResource, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeletePreviewObservation.
+func (in *DeletePreviewObservation) DeepCopy() *DeletePreviewObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(DeletePreviewObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeletePreviewResource) DeepCopyInto(out *DeletePreviewResource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeletePreviewResource.
+func (in *DeletePreviewResource) DeepCopy() *DeletePreviewResource {
+	if in == nil {
+		return nil
+	}
+	out := new(DeletePreviewResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyReference) DeepCopyInto(out *DependencyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyReference.
+func (in *DependencyReference) DeepCopy() *DependencyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeploymentProbe) DeepCopyInto(out *DeploymentProbe) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeploymentProbe.
+func (in *DeploymentProbe) DeepCopy() *DeploymentProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(DeploymentProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DockerConfigObservation) DeepCopyInto(out *DockerConfigObservation) {
+	*out = *in
+	if in.PendingInstances != nil {
+		in, out := &in.PendingInstances, &out.PendingInstances
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastReplacedTime != nil {
+		in, out := &in.LastReplacedTime, &out.LastReplacedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerConfigObservation.
+func (in *DockerConfigObservation) DeepCopy() *DockerConfigObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(DockerConfigObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DumpObservation) DeepCopyInto(out *DumpObservation) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DumpObservation.
+func (in *DumpObservation) DeepCopy() *DumpObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(DumpObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressProxyParameters) DeepCopyInto(out *EgressProxyParameters) {
+	*out = *in
+	if in.Excludes != nil {
+		in, out := &in.Excludes, &out.Excludes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CredentialsSecretRef != nil {
+		in, out := &in.CredentialsSecretRef, &out.CredentialsSecretRef
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressProxyParameters.
+func (in *EgressProxyParameters) DeepCopy() *EgressProxyParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressProxyParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EncryptionConfigObservation) DeepCopyInto(out *EncryptionConfigObservation) {
+	*out = *in
+	if in.PendingInstances != nil {
+		in, out := &in.PendingInstances, &out.PendingInstances
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastReplacedTime != nil {
+		in, out := &in.LastReplacedTime, &out.LastReplacedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EncryptionConfigObservation.
+func (in *EncryptionConfigObservation) DeepCopy() *EncryptionConfigObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(EncryptionConfigObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdBackupObservation) DeepCopyInto(out *EtcdBackupObservation) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsParameters.
-func (in *KopsParameters) DeepCopy() *KopsParameters {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdBackupObservation.
+func (in *EtcdBackupObservation) DeepCopy() *EtcdBackupObservation {
 	if in == nil {
 		return nil
 	}
-	out := new(KopsParameters)
+	out := new(EtcdBackupObservation)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KopsSpec) DeepCopyInto(out *KopsSpec) {
+func (in *EtcdClusterObservation) DeepCopyInto(out *EtcdClusterObservation) {
 	*out = *in
-	in.ResourceSpec.DeepCopyInto(&out.ResourceSpec)
-	in.ForProvider.DeepCopyInto(&out.ForProvider)
+	if in.MemberStatus != nil {
+		in, out := &in.MemberStatus, &out.MemberStatus
+		*out = make([]EtcdMemberObservation, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastBackupTime != nil {
+		in, out := &in.LastBackupTime, &out.LastBackupTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsSpec.
-func (in *KopsSpec) DeepCopy() *KopsSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdClusterObservation.
+func (in *EtcdClusterObservation) DeepCopy() *EtcdClusterObservation {
 	if in == nil {
 		return nil
 	}
-	out := new(KopsSpec)
+	out := new(EtcdClusterObservation)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KopsStatus) DeepCopyInto(out *KopsStatus) {
+func (in *EtcdMemberObservation) DeepCopyInto(out *EtcdMemberObservation) {
 	*out = *in
-	in.ResourceStatus.DeepCopyInto(&out.ResourceStatus)
-	out.AtProvider = in.AtProvider
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsStatus.
-func (in *KopsStatus) DeepCopy() *KopsStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdMemberObservation.
+func (in *EtcdMemberObservation) DeepCopy() *EtcdMemberObservation {
 	if in == nil {
 		return nil
 	}
-	out := new(KopsStatus)
+	out := new(EtcdMemberObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdRestoreObservation) DeepCopyInto(out *EtcdRestoreObservation) {
+	*out = *in
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdRestoreObservation.
+func (in *EtcdRestoreObservation) DeepCopy() *EtcdRestoreObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdRestoreObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileAssetParameters) DeepCopyInto(out *FileAssetParameters) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]kops.InstanceGroupRole, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(ConfigMapKeySelector)
+		**out = **in
+	}
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileAssetParameters.
+func (in *FileAssetParameters) DeepCopy() *FileAssetParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(FileAssetParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FleetClusterObservation) DeepCopyInto(out *FleetClusterObservation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetClusterObservation.
+func (in *FleetClusterObservation) DeepCopy() *FleetClusterObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(FleetClusterObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FleetClusterParameters) DeepCopyInto(out *FleetClusterParameters) {
+	*out = *in
+	if in.Zones != nil {
+		in, out := &in.Zones, &out.Zones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.WriteConnectionSecretToReference != nil {
+		in, out := &in.WriteConnectionSecretToReference, &out.WriteConnectionSecretToReference
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FleetClusterParameters.
+func (in *FleetClusterParameters) DeepCopy() *FleetClusterParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(FleetClusterParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPUPoolObservation) DeepCopyInto(out *GPUPoolObservation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPUPoolObservation.
+func (in *GPUPoolObservation) DeepCopy() *GPUPoolObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(GPUPoolObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPGetProbe) DeepCopyInto(out *HTTPGetProbe) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPGetProbe.
+func (in *HTTPGetProbe) DeepCopy() *HTTPGetProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPGetProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthProbe) DeepCopyInto(out *HealthProbe) {
+	*out = *in
+	if in.Deployment != nil {
+		in, out := &in.Deployment, &out.Deployment
+		*out = new(DeploymentProbe)
+		**out = **in
+	}
+	if in.HTTPGet != nil {
+		in, out := &in.HTTPGet, &out.HTTPGet
+		*out = new(HTTPGetProbe)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthProbe.
+func (in *HealthProbe) DeepCopy() *HealthProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthProbeResult) DeepCopyInto(out *HealthProbeResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthProbeResult.
+func (in *HealthProbeResult) DeepCopy() *HealthProbeResult {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthProbeResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageTrackingParameters) DeepCopyInto(out *ImageTrackingParameters) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageTrackingParameters.
+func (in *ImageTrackingParameters) DeepCopy() *ImageTrackingParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageTrackingParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InfrastructureObservation) DeepCopyInto(out *InfrastructureObservation) {
+	*out = *in
+	if in.SubnetIDs != nil {
+		in, out := &in.SubnetIDs, &out.SubnetIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityGroupIDs != nil {
+		in, out := &in.SecurityGroupIDs, &out.SecurityGroupIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstanceProfileARNs != nil {
+		in, out := &in.InstanceProfileARNs, &out.InstanceProfileARNs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DNSRecords != nil {
+		in, out := &in.DNSRecords, &out.DNSRecords
+		*out = make([]DNSRecordObservation, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InfrastructureObservation.
+func (in *InfrastructureObservation) DeepCopy() *InfrastructureObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(InfrastructureObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceConnection) DeepCopyInto(out *InstanceConnection) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceConnection.
+func (in *InstanceConnection) DeepCopy() *InstanceConnection {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceConnection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceGroupConnectionObservation) DeepCopyInto(out *InstanceGroupConnectionObservation) {
+	*out = *in
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = make([]InstanceConnection, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceGroupConnectionObservation.
+func (in *InstanceGroupConnectionObservation) DeepCopy() *InstanceGroupConnectionObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceGroupConnectionObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceGroupCost) DeepCopyInto(out *InstanceGroupCost) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceGroupCost.
+func (in *InstanceGroupCost) DeepCopy() *InstanceGroupCost {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceGroupCost)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceGroupPatch) DeepCopyInto(out *InstanceGroupPatch) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceGroupPatch.
+func (in *InstanceGroupPatch) DeepCopy() *InstanceGroupPatch {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceGroupPatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Kops) DeepCopyInto(out *Kops) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Kops.
+func (in *Kops) DeepCopy() *Kops {
+	if in == nil {
+		return nil
+	}
+	out := new(Kops)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Kops) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsFleet) DeepCopyInto(out *KopsFleet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsFleet.
+func (in *KopsFleet) DeepCopy() *KopsFleet {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsFleet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KopsFleet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsFleetList) DeepCopyInto(out *KopsFleetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KopsFleet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsFleetList.
+func (in *KopsFleetList) DeepCopy() *KopsFleetList {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsFleetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KopsFleetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsFleetSpec) DeepCopyInto(out *KopsFleetSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]FleetClusterParameters, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsFleetSpec.
+func (in *KopsFleetSpec) DeepCopy() *KopsFleetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsFleetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsFleetStatus) DeepCopyInto(out *KopsFleetStatus) {
+	*out = *in
+	in.ConditionedStatus.DeepCopyInto(&out.ConditionedStatus)
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]FleetClusterObservation, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsFleetStatus.
+func (in *KopsFleetStatus) DeepCopy() *KopsFleetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsFleetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsFleetTemplate) DeepCopyInto(out *KopsFleetTemplate) {
+	*out = *in
+	if in.ProviderConfigReference != nil {
+		in, out := &in.ProviderConfigReference, &out.ProviderConfigReference
+		*out = new(v1.Reference)
+		**out = **in
+	}
+	in.ForProvider.DeepCopyInto(&out.ForProvider)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsFleetTemplate.
+func (in *KopsFleetTemplate) DeepCopy() *KopsFleetTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsFleetTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsList) DeepCopyInto(out *KopsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Kops, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsList.
+func (in *KopsList) DeepCopy() *KopsList {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KopsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsMigration) DeepCopyInto(out *KopsMigration) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsMigration.
+func (in *KopsMigration) DeepCopy() *KopsMigration {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsMigration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KopsMigration) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsMigrationList) DeepCopyInto(out *KopsMigrationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KopsMigration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsMigrationList.
+func (in *KopsMigrationList) DeepCopy() *KopsMigrationList {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsMigrationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KopsMigrationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsMigrationSpec) DeepCopyInto(out *KopsMigrationSpec) {
+	*out = *in
+	out.SourceRef = in.SourceRef
+	if in.WriteConnectionSecretToReference != nil {
+		in, out := &in.WriteConnectionSecretToReference, &out.WriteConnectionSecretToReference
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.RetireAfter != nil {
+		in, out := &in.RetireAfter, &out.RetireAfter
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsMigrationSpec.
+func (in *KopsMigrationSpec) DeepCopy() *KopsMigrationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsMigrationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsMigrationStatus) DeepCopyInto(out *KopsMigrationStatus) {
+	*out = *in
+	in.ConditionedStatus.DeepCopyInto(&out.ConditionedStatus)
+	if in.TargetReadyTime != nil {
+		in, out := &in.TargetReadyTime, &out.TargetReadyTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RetiredTime != nil {
+		in, out := &in.RetiredTime, &out.RetiredTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsMigrationStatus.
+func (in *KopsMigrationStatus) DeepCopy() *KopsMigrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsMigrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsObservation) DeepCopyInto(out *KopsObservation) {
+	*out = *in
+	if in.CACertificateExpiry != nil {
+		in, out := &in.CACertificateExpiry, &out.CACertificateExpiry
+		*out = (*in).DeepCopy()
+	}
+	if in.ClientCertificateExpiry != nil {
+		in, out := &in.ClientCertificateExpiry, &out.ClientCertificateExpiry
+		*out = (*in).DeepCopy()
+	}
+	if in.Validation != nil {
+		in, out := &in.Validation, &out.Validation
+		*out = new(ValidationObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = new(NodeReadiness)
+		**out = **in
+	}
+	if in.Infrastructure != nil {
+		in, out := &in.Infrastructure, &out.Infrastructure
+		*out = new(InfrastructureObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Etcd != nil {
+		in, out := &in.Etcd, &out.Etcd
+		*out = make([]EtcdClusterObservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Dump != nil {
+		in, out := &in.Dump, &out.Dump
+		*out = new(DumpObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeletePreview != nil {
+		in, out := &in.DeletePreview, &out.DeletePreview
+		*out = new(DeletePreviewObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EtcdBackup != nil {
+		in, out := &in.EtcdBackup, &out.EtcdBackup
+		*out = new(EtcdBackupObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EtcdRestore != nil {
+		in, out := &in.EtcdRestore, &out.EtcdRestore
+		*out = new(EtcdRestoreObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastAppliedTime != nil {
+		in, out := &in.LastAppliedTime, &out.LastAppliedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PhaseTransitionTime != nil {
+		in, out := &in.PhaseTransitionTime, &out.PhaseTransitionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.IncompleteApply != nil {
+		in, out := &in.IncompleteApply, &out.IncompleteApply
+		*out = new(ApplyRecord)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WrittenState != nil {
+		in, out := &in.WrittenState, &out.WrittenState
+		*out = new(WrittenStateObservation)
+		**out = **in
+	}
+	if in.ApplyProgress != nil {
+		in, out := &in.ApplyProgress, &out.ApplyProgress
+		*out = new(ApplyProgressObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EncryptionConfig != nil {
+		in, out := &in.EncryptionConfig, &out.EncryptionConfig
+		*out = new(EncryptionConfigObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingReplacements != nil {
+		in, out := &in.PendingReplacements, &out.PendingReplacements
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OnDemandFallbacks != nil {
+		in, out := &in.OnDemandFallbacks, &out.OnDemandFallbacks
+		*out = make([]OnDemandFallbackObservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.GPUPools != nil {
+		in, out := &in.GPUPools, &out.GPUPools
+		*out = make([]GPUPoolObservation, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstanceConnections != nil {
+		in, out := &in.InstanceConnections, &out.InstanceConnections
+		*out = make([]InstanceGroupConnectionObservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DockerConfig != nil {
+		in, out := &in.DockerConfig, &out.DockerConfig
+		*out = new(DockerConfigObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CNI != nil {
+		in, out := &in.CNI, &out.CNI
+		*out = new(CNIObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.APIServerSANs != nil {
+		in, out := &in.APIServerSANs, &out.APIServerSANs
+		*out = new(APIServerSANsObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TrackedImages != nil {
+		in, out := &in.TrackedImages, &out.TrackedImages
+		*out = make([]TrackedImageObservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(UpgradeObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cost != nil {
+		in, out := &in.Cost, &out.Cost
+		*out = new(CostObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StateReplica != nil {
+		in, out := &in.StateReplica, &out.StateReplica
+		*out = new(StateReplicaObservation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PreDelete != nil {
+		in, out := &in.PreDelete, &out.PreDelete
+		*out = new(PreDeleteObservation)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsObservation.
+func (in *KopsObservation) DeepCopy() *KopsObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsParameters) DeepCopyInto(out *KopsParameters) {
+	*out = *in
+	in.ClusterSpec.DeepCopyInto(&out.ClusterSpec)
+	if in.InstanceGroupSpec != nil {
+		in, out := &in.InstanceGroupSpec, &out.InstanceGroupSpec
+		*out = make([]kops.InstanceGroupSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CertificateExpiryThreshold != nil {
+		in, out := &in.CertificateExpiryThreshold, &out.CertificateExpiryThreshold
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.KubernetesAPICertificateTTL != nil {
+		in, out := &in.KubernetesAPICertificateTTL, &out.KubernetesAPICertificateTTL
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.LifecycleOverrides != nil {
+		in, out := &in.LifecycleOverrides, &out.LifecycleOverrides
+		*out = make(map[string]TaskLifecycle, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RenderedSpecSecretRef != nil {
+		in, out := &in.RenderedSpecSecretRef, &out.RenderedSpecSecretRef
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.HealthProbes != nil {
+		in, out := &in.HealthProbes, &out.HealthProbes
+		*out = make([]HealthProbe, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImageTracking != nil {
+		in, out := &in.ImageTracking, &out.ImageTracking
+		*out = make([]ImageTrackingParameters, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Preflight != nil {
+		in, out := &in.Preflight, &out.Preflight
+		*out = new(PreflightParameters)
+		**out = **in
+	}
+	if in.Addons != nil {
+		in, out := &in.Addons, &out.Addons
+		*out = make([]AddonParameters, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.APICertificateRef != nil {
+		in, out := &in.APICertificateRef, &out.APICertificateRef
+		*out = new(CertificateReference)
+		**out = **in
+	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]DependencyReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.EncryptionConfigSecretRef != nil {
+		in, out := &in.EncryptionConfigSecretRef, &out.EncryptionConfigSecretRef
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.DockerConfigSecretRef != nil {
+		in, out := &in.DockerConfigSecretRef, &out.DockerConfigSecretRef
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.FileAssets != nil {
+		in, out := &in.FileAssets, &out.FileAssets
+		*out = make([]FileAssetParameters, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EgressProxy != nil {
+		in, out := &in.EgressProxy, &out.EgressProxy
+		*out = new(EgressProxyParameters)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConnectionRBAC != nil {
+		in, out := &in.ConnectionRBAC, &out.ConnectionRBAC
+		*out = new(ConnectionRBACParameters)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Reachability != nil {
+		in, out := &in.Reachability, &out.Reachability
+		*out = new(ReachabilityParameters)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Zones != nil {
+		in, out := &in.Zones, &out.Zones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Simple != nil {
+		in, out := &in.Simple, &out.Simple
+		*out = new(SimpleParameters)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = new(NotificationParameters)
+		**out = **in
+	}
+	if in.PreDelete != nil {
+		in, out := &in.PreDelete, &out.PreDelete
+		*out = new(PreDeleteParameters)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CloudTags != nil {
+		in, out := &in.CloudTags, &out.CloudTags
+		*out = new(CloudTagParameters)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Overrides != nil {
+		in, out := &in.Overrides, &out.Overrides
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstanceGroupPatches != nil {
+		in, out := &in.InstanceGroupPatches, &out.InstanceGroupPatches
+		*out = make([]InstanceGroupPatch, len(*in))
+		copy(*out, *in)
+	}
+	if in.StateReplica != nil {
+		in, out := &in.StateReplica, &out.StateReplica
+		*out = new(StateReplicaParameters)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsParameters.
+func (in *KopsParameters) DeepCopy() *KopsParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsSpec) DeepCopyInto(out *KopsSpec) {
+	*out = *in
+	in.ResourceSpec.DeepCopyInto(&out.ResourceSpec)
+	in.ForProvider.DeepCopyInto(&out.ForProvider)
+	if in.AdditionalConnectionSecretRefs != nil {
+		in, out := &in.AdditionalConnectionSecretRefs, &out.AdditionalConnectionSecretRefs
+		*out = make([]v1.SecretReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsSpec.
+func (in *KopsSpec) DeepCopy() *KopsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KopsStatus) DeepCopyInto(out *KopsStatus) {
+	*out = *in
+	in.ResourceStatus.DeepCopyInto(&out.ResourceStatus)
+	in.AtProvider.DeepCopyInto(&out.AtProvider)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KopsStatus.
+func (in *KopsStatus) DeepCopy() *KopsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KopsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeReadiness) DeepCopyInto(out *NodeReadiness) {
+	*out = *in
+	out.ControlPlane = in.ControlPlane
+	out.Workers = in.Workers
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeReadiness.
+func (in *NodeReadiness) DeepCopy() *NodeReadiness {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeReadiness)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationParameters) DeepCopyInto(out *NotificationParameters) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationParameters.
+func (in *NotificationParameters) DeepCopy() *NotificationParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OnDemandFallbackObservation) DeepCopyInto(out *OnDemandFallbackObservation) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OnDemandFallbackObservation.
+func (in *OnDemandFallbackObservation) DeepCopy() *OnDemandFallbackObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(OnDemandFallbackObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreDeleteObservation) DeepCopyInto(out *PreDeleteObservation) {
+	*out = *in
+	in.StartedTime.DeepCopyInto(&out.StartedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreDeleteObservation.
+func (in *PreDeleteObservation) DeepCopy() *PreDeleteObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(PreDeleteObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreDeleteParameters) DeepCopyInto(out *PreDeleteParameters) {
+	*out = *in
+	if in.DrainTimeout != nil {
+		in, out := &in.DrainTimeout, &out.DrainTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(NotificationParameters)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreDeleteParameters.
+func (in *PreDeleteParameters) DeepCopy() *PreDeleteParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(PreDeleteParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreflightParameters) DeepCopyInto(out *PreflightParameters) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreflightParameters.
+func (in *PreflightParameters) DeepCopy() *PreflightParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(PreflightParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReachabilityParameters) DeepCopyInto(out *ReachabilityParameters) {
+	*out = *in
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+	if in.KubeconfigSecretRef != nil {
+		in, out := &in.KubeconfigSecretRef, &out.KubeconfigSecretRef
+		*out = new(v1.SecretKeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReachabilityParameters.
+func (in *ReachabilityParameters) DeepCopy() *ReachabilityParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(ReachabilityParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoleReadiness) DeepCopyInto(out *RoleReadiness) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoleReadiness.
+func (in *RoleReadiness) DeepCopy() *RoleReadiness {
+	if in == nil {
+		return nil
+	}
+	out := new(RoleReadiness)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SimpleParameters) DeepCopyInto(out *SimpleParameters) {
+	*out = *in
+	if in.Zones != nil {
+		in, out := &in.Zones, &out.Zones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MasterCount != nil {
+		in, out := &in.MasterCount, &out.MasterCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NodeCount != nil {
+		in, out := &in.NodeCount, &out.NodeCount
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SimpleParameters.
+func (in *SimpleParameters) DeepCopy() *SimpleParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(SimpleParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StateReplicaObservation) DeepCopyInto(out *StateReplicaObservation) {
+	*out = *in
+	if in.LastReplicatedTime != nil {
+		in, out := &in.LastReplicatedTime, &out.LastReplicatedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StateReplicaObservation.
+func (in *StateReplicaObservation) DeepCopy() *StateReplicaObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(StateReplicaObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StateReplicaParameters) DeepCopyInto(out *StateReplicaParameters) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StateReplicaParameters.
+func (in *StateReplicaParameters) DeepCopy() *StateReplicaParameters {
+	if in == nil {
+		return nil
+	}
+	out := new(StateReplicaParameters)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrackedImageObservation) DeepCopyInto(out *TrackedImageObservation) {
+	*out = *in
+	in.LastCheckedTime.DeepCopyInto(&out.LastCheckedTime)
+	in.LastChangedTime.DeepCopyInto(&out.LastChangedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrackedImageObservation.
+func (in *TrackedImageObservation) DeepCopy() *TrackedImageObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(TrackedImageObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeObservation) DeepCopyInto(out *UpgradeObservation) {
+	*out = *in
+	in.LastCheckedTime.DeepCopyInto(&out.LastCheckedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeObservation.
+func (in *UpgradeObservation) DeepCopy() *UpgradeObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValidationFailure) DeepCopyInto(out *ValidationFailure) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValidationFailure.
+func (in *ValidationFailure) DeepCopy() *ValidationFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(ValidationFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValidationObservation) DeepCopyInto(out *ValidationObservation) {
+	*out = *in
+	if in.Failures != nil {
+		in, out := &in.Failures, &out.Failures
+		*out = make([]ValidationFailure, len(*in))
+		copy(*out, *in)
+	}
+	if in.NotReadyNodes != nil {
+		in, out := &in.NotReadyNodes, &out.NotReadyNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CNI != nil {
+		in, out := &in.CNI, &out.CNI
+		*out = new(CNIHealth)
+		**out = **in
+	}
+	if in.HealthProbes != nil {
+		in, out := &in.HealthProbes, &out.HealthProbes
+		*out = make([]HealthProbeResult, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValidationObservation.
+func (in *ValidationObservation) DeepCopy() *ValidationObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(ValidationObservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WrittenStateObservation) DeepCopyInto(out *WrittenStateObservation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WrittenStateObservation.
+func (in *WrittenStateObservation) DeepCopy() *WrittenStateObservation {
+	if in == nil {
+		return nil
+	}
+	out := new(WrittenStateObservation)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -29,6 +29,44 @@ import (
 type ProviderConfigSpec struct {
 	// Credentials required to authenticate to this provider.
 	// Credentials ProviderCredentials `json:"credentials"`
+
+	// CertificatePolicy bounds the client certificates issued for the Kops
+	// resources using this ProviderConfig.
+	// +optional
+	CertificatePolicy *CertificatePolicy `json:"certificatePolicy,omitempty"`
+}
+
+// A CertificatePolicyEnforcement is how a certificate policy treats a Kops
+// resource that requests a certificate exceeding it.
+type CertificatePolicyEnforcement string
+
+// Certificate policy enforcements.
+const (
+	// CertificatePolicyReject fails to reconcile Kops resources exceeding
+	// the policy until they are changed to comply with it.
+	CertificatePolicyReject CertificatePolicyEnforcement = "Reject"
+
+	// CertificatePolicyClamp issues certificates exceeding the policy with
+	// the longest validity the policy allows.
+	CertificatePolicyClamp CertificatePolicyEnforcement = "Clamp"
+)
+
+// A CertificatePolicy bounds the client certificates issued for Kops
+// resources, e.g. for compliance reasons.
+type CertificatePolicy struct {
+	// MaxKubernetesAPICertificateTTL is the longest validity a Kops resource
+	// may request for the client certificate of its connection details. The
+	// provider's --max-kubernetes-api-certificate-ttl flag applies if it is
+	// lower.
+	// +optional
+	MaxKubernetesAPICertificateTTL *metav1.Duration `json:"maxKubernetesApiCertificateTTL,omitempty"`
+
+	// Enforcement is how Kops resources requesting a longer validity are
+	// treated.
+	// +optional
+	// +kubebuilder:validation:Enum=Reject;Clamp
+	// +kubebuilder:default=Reject
+	Enforcement CertificatePolicyEnforcement `json:"enforcement,omitempty"`
 }
 
 // ProviderCredentials required to authenticate.
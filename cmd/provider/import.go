@@ -0,0 +1,45 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"io"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+// importKops writes a Kops resource that manages the supplied existing kops
+// cluster to the supplied writer as YAML.
+func importKops(ctx context.Context, w io.Writer, stateBucket, clusterName string) error {
+	kopsClientset, err := util.GetStateStoreClientset(stateBucket)
+	if err != nil {
+		return err
+	}
+	cr, err := util.ImportKops(ctx, kopsClientset, stateBucket, clusterName)
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(cr)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
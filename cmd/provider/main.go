@@ -18,14 +18,14 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
-	"time"
 
 	"gopkg.in/alecthomas/kingpin.v2"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/apimachinery/pkg/labels"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -38,24 +38,84 @@ import (
 
 	"github.com/crossplane/provider-kops/apis"
 	"github.com/crossplane/provider-kops/apis/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/audit"
 	kops "github.com/crossplane/provider-kops/internal/controller"
 	"github.com/crossplane/provider-kops/internal/controller/features"
+	kopscontroller "github.com/crossplane/provider-kops/internal/controller/kops"
+	"github.com/crossplane/provider-kops/internal/kopslog"
+	"github.com/crossplane/provider-kops/internal/sweeper"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/webhook"
 )
 
 func main() {
 	var (
-		app            = kingpin.New(filepath.Base(os.Args[0]), "Kops support for Crossplane.").DefaultEnvars()
-		debug          = app.Flag("debug", "Run with debug logging.").Short('d').Bool()
-		leaderElection = app.Flag("leader-election", "Use leader election for the controller manager.").Short('l').Default("false").OverrideDefaultFromEnvar("LEADER_ELECTION").Bool()
+		app              = kingpin.New(filepath.Base(os.Args[0]), "Kops support for Crossplane.").DefaultEnvars()
+		debug            = app.Flag("debug", "Run with debug logging.").Short('d').Bool()
+		kopsLogLevel     = app.Flag("kops-log-level", "klog verbosity of the kops libraries. Their output is captured in the provider log.").Default("0").Envar("KOPS_LOG_LEVEL").Int()
+		leaderElection   = app.Flag("leader-election", "Use leader election for the controller manager.").Short('l').Default("false").OverrideDefaultFromEnvar("LEADER_ELECTION").Bool()
+		leaseDuration    = app.Flag("leader-election-lease-duration", "How long standby replicas wait before taking over from a leader that stopped renewing its lease, e.g. because its node failed.").Default("60s").Envar("LEADER_ELECTION_LEASE_DURATION").Duration()
+		renewDeadline    = app.Flag("leader-election-renew-deadline", "How long the leader keeps retrying to renew its lease before giving up leadership. Must be lower than --leader-election-lease-duration.").Default("50s").Envar("LEADER_ELECTION_RENEW_DEADLINE").Duration()
+		leaderElectionID = app.Flag("leader-election-id", "The name of the lease used for leader election. Provider deployments reconciling different shards must use different leases; the --shard-index is appended to it when --shard-count is set.").Default("crossplane-leader-election-provider-kops").Envar("LEADER_ELECTION_ID").String()
 
 		syncInterval     = app.Flag("sync", "How often all resources will be double-checked for drift from the desired state.").Short('s').Default("1h").Duration()
 		pollInterval     = app.Flag("poll", "How often individual resources will be checked for drift from the desired state").Default("1m").Duration()
 		maxReconcileRate = app.Flag("max-reconcile-rate", "The global maximum rate per second at which resources may checked for drift from the desired state.").Default("10").Int()
+		maxConcurrent    = app.Flag("max-concurrent-reconciles", "The maximum number of resources each controller reconciles at once. Defaults to --max-reconcile-rate if zero.").Default("0").Envar("MAX_CONCURRENT_RECONCILES").Int()
 
 		namespace                  = app.Flag("namespace", "Namespace used to set as default scope in default secret store config.").Default("crossplane-system").Envar("POD_NAMESPACE").String()
 		enableExternalSecretStores = app.Flag("enable-external-secret-stores", "Enable support for ExternalSecretStores.").Default("false").Envar("ENABLE_EXTERNAL_SECRET_STORES").Bool()
+
+		maxConcurrentApplies = app.Flag("max-concurrent-applies", "The maximum number of Kops clusters applied or deleted at once. Should be lower than --max-concurrent-reconciles so that other clusters are still observed while they run. Unbounded if zero.").Default("5").Envar("MAX_CONCURRENT_APPLIES").Int()
+		awsAPIQPS            = app.Flag("aws-api-qps", "The maximum rate per second of AWS API requests made in each region, across all clusters. Unlimited if zero.").Default("0").Envar("AWS_API_QPS").Float64()
+		awsAPIBurst          = app.Flag("aws-api-burst", "The number of AWS API requests that may be made in each region at once above --aws-api-qps.").Default("10").Envar("AWS_API_BURST").Int()
+		circuitBreaker       = app.Flag("circuit-breaker-threshold", "The number of identical failures in a row after which a Kops resource stops being reconciled until its spec changes or it is annotated with kops.crossplane.io/resume. Resources are never paused if zero.").Default("10").Envar("CIRCUIT_BREAKER_THRESHOLD").Int()
+		maxFailureBackoff    = app.Flag("max-failure-backoff", "The longest a Kops resource that keeps failing waits before it is reconciled again. Failures are not backed off if zero.").Default("15m").Envar("MAX_FAILURE_BACKOFF").Duration()
+		maxCertificateTTL    = app.Flag("max-kubernetes-api-certificate-ttl", "The longest validity Kops resources may request for the client certificate of their connection details. Longer requests are rejected, or clamped if their ProviderConfig's certificate policy says so. Unbounded if zero.").Default("0").Envar("MAX_KUBERNETES_API_CERTIFICATE_TTL").Duration()
+		validationTimeout    = app.Flag("validation-timeout", "How long validating a Kops cluster, and each request to its API server, may take before the cluster is reported as failing validation. Unbounded if zero.").Default("1m").Envar("VALIDATION_TIMEOUT").Duration()
+		publishWhenPaused    = app.Flag("publish-connection-details-when-paused", "Keep observing Kops resources paused by the crossplane.io/paused annotation, without changing their clusters, so that the client certificates of their connection details are refreshed before they expire.").Default("false").Envar("PUBLISH_CONNECTION_DETAILS_WHEN_PAUSED").Bool()
+
+		stateGCInterval = app.Flag("state-gc-interval", "How often the state buckets of Kops resources are swept for cluster state that no Kops resource manages and that has no cloud resources. Sweeping is disabled if zero.").Default("0").Envar("STATE_GC_INTERVAL").Duration()
+		stateGCClean    = app.Flag("state-gc-clean", "Delete orphaned cluster state found by the sweeper, after backing it up, rather than only reporting it.").Default("false").Envar("STATE_GC_CLEAN").Bool()
+		stateGCMinAge   = app.Flag("state-gc-min-age", "How long ago cluster state must have been registered before the sweeper considers it orphaned, so that clusters that are yet to be applied are left alone.").Default("24h").Envar("STATE_GC_MIN_AGE").Duration()
+
+		upgradeAuditInterval = app.Flag("upgrade-audit-interval", "How often the desired spec of every Kops resource is evaluated against the latest data of its kops channel, reporting the clusters that need a Kubernetes upgrade or run outdated images in metrics and on the /upgrades path of the metrics server. Auditing is disabled if zero.").Default("0").Envar("UPGRADE_AUDIT_INTERVAL").Duration()
+
+		readOnly = app.Flag("read-only", "Only observe Kops clusters. Creating and deleting them is refused and updates are applied as dry runs, whose changes are reported rather than made. State buckets are not provisioned and orphaned cluster state is not cleaned while read-only.").Default("false").Envar("READ_ONLY").Bool()
+
+		provisionStateBuckets = app.Flag("provision-state-buckets", "Create the S3 state bucket of a Kops resource, with versioning, default encryption and all public access blocked, if it doesn't exist. Existing buckets are left as they are.").Default("false").Envar("PROVISION_STATE_BUCKETS").Bool()
+
+		shardCount    = app.Flag("shard-count", "The number of provider deployments Kops resources are spread across by the hash of their external name. Resources are not spread if zero.").Default("0").Envar("SHARD_COUNT").Int()
+		shardIndex    = app.Flag("shard-index", "The shard of Kops resources this deployment reconciles, from zero to --shard-count minus one.").Default("0").Envar("SHARD_INDEX").Int()
+		shardSelector = app.Flag("shard-selector", "A label selector restricting the Kops resources this deployment reconciles.").Envar("SHARD_SELECTOR").String()
+
+		webhookTLSCertDir = app.Flag("webhook-tls-cert-dir", "The directory holding the tls.crt and tls.key that admission webhooks are served with. Crossplane sets it when it installs the provider's webhook configurations. Webhooks are not served if unset.").Envar("WEBHOOK_TLS_CERT_DIR").String()
+
+		otlpEndpoint = app.Flag("otlp-endpoint", "OTLP/HTTP endpoint (host:port) traces are exported to. Tracing is disabled if unset.").Envar("OTLP_ENDPOINT").String()
+		otlpInsecure = app.Flag("otlp-insecure", "Export traces over plain HTTP rather than HTTPS.").Default("false").Envar("OTLP_INSECURE").Bool()
+
+		_                 = app.Command("run", "Run the provider.").Default()
+		importCmd         = app.Command("import", "Print a Kops resource that manages an existing kops cluster, e.g. one created with the kops CLI. AWS credentials are read from the environment.")
+		importStateBucket = importCmd.Flag("state-bucket", "The state store of the cluster, e.g. s3://kops-state.").Required().String()
+		importCluster     = importCmd.Arg("cluster", "The full name of the cluster, e.g. example.foo.com.").Required().String()
 	)
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	if kingpin.MustParse(app.Parse(os.Args[1:])) == importCmd.FullCommand() {
+		kingpin.FatalIfError(importKops(context.Background(), os.Stdout, *importStateBucket, *importCluster), "Cannot import kops cluster")
+		return
+	}
+
+	shard := kopscontroller.Shard{Count: *shardCount, Index: *shardIndex}
+	if *shardCount > 1 {
+		if *shardIndex < 0 || *shardIndex >= *shardCount {
+			kingpin.Fatalf("--shard-index must be between 0 and %d", *shardCount-1)
+		}
+		*leaderElectionID = fmt.Sprintf("%s-shard-%d", *leaderElectionID, *shardIndex)
+	}
+	if *shardSelector != "" {
+		sel, err := labels.Parse(*shardSelector)
+		kingpin.FatalIfError(err, "Cannot parse --shard-selector")
+		shard.Selector = sel
+	}
 
 	zl := zap.New(zap.UseDevMode(*debug))
 	log := logging.NewLogrLogger(zl.WithName("provider-kops"))
@@ -66,31 +126,45 @@ func main() {
 		ctrl.SetLogger(zl)
 	}
 
+	kingpin.FatalIfError(kopslog.Setup(zl, *kopsLogLevel), "Cannot capture kops logs")
+
+	if *readOnly {
+		*stateGCClean = false
+		log.Info("Read-only mode enabled; clusters are observed and their updates dry run")
+	}
+
+	if *otlpEndpoint != "" {
+		shutdown, err := tracing.Setup(context.Background(), *otlpEndpoint, *otlpInsecure)
+		kingpin.FatalIfError(err, "Cannot set up tracing")
+		defer shutdown(context.Background()) //nolint:errcheck
+		log.Info("Tracing enabled", "endpoint", *otlpEndpoint)
+	}
+
 	cfg, err := ctrl.GetConfig()
 	kingpin.FatalIfError(err, "Cannot get API server rest config")
 
-	mgr, err := ctrl.NewManager(ratelimiter.LimitRESTConfig(cfg, *maxReconcileRate), ctrl.Options{
+	mo := ctrl.Options{
 		SyncPeriod: syncInterval,
-
-		// controller-runtime uses both ConfigMaps and Leases for leader
-		// election by default. Leases expire after 15 seconds, with a
-		// 10 second renewal deadline. We've observed leader loss due to
-		// renewal deadlines being exceeded when under high load - i.e.
-		// hundreds of reconciles per second and ~200rps to the API
-		// server. Switching to Leases only and longer leases appears to
-		// alleviate this.
-		LeaderElection:             *leaderElection,
-		LeaderElectionID:           "crossplane-leader-election-provider-kops",
-		LeaderElectionResourceLock: resourcelock.LeasesResourceLock,
-		LeaseDuration:              func() *time.Duration { d := 60 * time.Second; return &d }(),
-		RenewDeadline:              func() *time.Duration { d := 50 * time.Second; return &d }(),
-	})
+		CertDir:    *webhookTLSCertDir,
+	}
+	kingpin.FatalIfError(kops.LeaderElection{
+		Enabled:       *leaderElection,
+		ID:            *leaderElectionID,
+		LeaseDuration: *leaseDuration,
+		RenewDeadline: *renewDeadline,
+	}.Configure(&mo), "Cannot configure leader election")
+
+	mgr, err := ctrl.NewManager(ratelimiter.LimitRESTConfig(cfg, *maxReconcileRate), mo)
 	kingpin.FatalIfError(err, "Cannot create controller manager")
 	kingpin.FatalIfError(apis.AddToScheme(mgr.GetScheme()), "Cannot add Kops APIs to scheme")
 
+	if *maxConcurrent == 0 {
+		*maxConcurrent = *maxReconcileRate
+	}
+
 	o := controller.Options{
 		Logger:                  log,
-		MaxConcurrentReconciles: *maxReconcileRate,
+		MaxConcurrentReconciles: *maxConcurrent,
 		PollInterval:            *pollInterval,
 		GlobalRateLimiter:       ratelimiter.NewGlobal(*maxReconcileRate),
 		Features:                &feature.Flags{},
@@ -115,6 +189,37 @@ func main() {
 		})), "cannot create default store config")
 	}
 
-	kingpin.FatalIfError(kops.Setup(mgr, o), "Cannot setup Kops controllers")
+	kingpin.FatalIfError(kops.Setup(mgr, o, kopscontroller.Options{
+		MaxConcurrentApplies:    *maxConcurrentApplies,
+		AWSAPIQPS:               *awsAPIQPS,
+		AWSAPIBurst:             *awsAPIBurst,
+		Shard:                   shard,
+		MaxCertificateTTL:       *maxCertificateTTL,
+		CircuitBreakerThreshold: *circuitBreaker,
+		MaxFailureBackoff:       *maxFailureBackoff,
+		PublishWhenPaused:       *publishWhenPaused,
+		ValidationTimeout:       *validationTimeout,
+		ProvisionStateBuckets:   *provisionStateBuckets,
+		ReadOnly:                *readOnly,
+	}), "Cannot setup Kops controllers")
+
+	if *webhookTLSCertDir != "" {
+		kingpin.FatalIfError(webhook.Setup(mgr), "Cannot setup Kops webhooks")
+		log.Info("Admission webhooks enabled", "cert-dir", *webhookTLSCertDir)
+	}
+
+	// The sweeper and the upgrade audit cover every Kops resource, so only
+	// the primary shard runs them.
+	if *stateGCInterval > 0 && shard.Primary() {
+		kingpin.FatalIfError(mgr.Add(sweeper.New(mgr.GetClient(), log.WithValues("component", "state-gc"), *stateGCInterval, *stateGCClean, *stateGCMinAge)), "Cannot add state sweeper")
+		log.Info("State garbage collection enabled", "interval", *stateGCInterval, "clean", *stateGCClean, "min-age", *stateGCMinAge)
+	}
+
+	if *upgradeAuditInterval > 0 && shard.Primary() {
+		a := audit.New(mgr.GetClient(), log.WithValues("component", "upgrade-audit"), *upgradeAuditInterval)
+		kingpin.FatalIfError(mgr.Add(a), "Cannot add upgrade auditor")
+		kingpin.FatalIfError(mgr.AddMetricsExtraHandler(audit.Path, a), "Cannot serve upgrade audit reports")
+		log.Info("Upgrade audit enabled", "interval", *upgradeAuditInterval)
+	}
 	kingpin.FatalIfError(mgr.Start(ctrl.SetupSignalHandler()), "Cannot start controller manager")
 }
@@ -0,0 +1,275 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit evaluates the clusters of all Kops resources against their
+// kops channel, finding the ones that need a Kubernetes upgrade or run images
+// the channel no longer lists.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/pkg/errors"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/util/pkg/architectures"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/metrics"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+// Path is the path the last report is served under, on the metrics server.
+const Path = "/upgrades"
+
+const (
+	errListKops    = "cannot list Kops resources"
+	errLoadChannel = "cannot load kops channel %s"
+	errNoReport    = "no upgrade audit has completed yet"
+)
+
+// An OutdatedImage is an image of an instance group that differs from the
+// image its cluster's kops channel lists.
+type OutdatedImage struct {
+	InstanceGroup string `json:"instanceGroup"`
+	Image         string `json:"image"`
+	ChannelImage  string `json:"channelImage"`
+}
+
+// A Finding is a cluster that needs upgrading, or whose channel could not be
+// evaluated.
+type Finding struct {
+	Name                         string                  `json:"name"`
+	Cluster                      string                  `json:"cluster"`
+	Channel                      string                  `json:"channel"`
+	KubernetesVersion            string                  `json:"kubernetesVersion"`
+	RecommendedKubernetesVersion string                  `json:"recommendedKubernetesVersion,omitempty"`
+	Urgency                      v1alpha1.UpgradeUrgency `json:"urgency,omitempty"`
+	OutdatedImages               []OutdatedImage         `json:"outdatedImages,omitempty"`
+	Error                        string                  `json:"error,omitempty"`
+}
+
+// A Report is the outcome of an audit of all Kops resources.
+type Report struct {
+	Time     time.Time `json:"time"`
+	Clusters int       `json:"clusters"`
+	Findings []Finding `json:"findings"`
+}
+
+// An Auditor periodically evaluates the desired spec of every Kops resource
+// against the latest data of its kops channel. Each audit is summarized in
+// metrics and logged, and the last report is served as JSON.
+type Auditor struct {
+	kube     client.Reader
+	log      logging.Logger
+	interval time.Duration
+
+	loadChannel func(location string) (*kopsapi.Channel, error)
+
+	mu   sync.RWMutex
+	last *Report
+}
+
+// New returns an Auditor that audits at the supplied interval.
+func New(kube client.Reader, log logging.Logger, interval time.Duration) *Auditor {
+	return &Auditor{
+		kube:        kube,
+		log:         log,
+		interval:    interval,
+		loadChannel: kopsapi.LoadChannel,
+	}
+}
+
+// NeedLeaderElection is true; only the leader audits.
+func (a *Auditor) NeedLeaderElection() bool {
+	return true
+}
+
+// Start audits until the supplied context is done.
+func (a *Auditor) Start(ctx context.Context) error {
+	t := time.NewTicker(a.interval)
+	defer t.Stop()
+
+	for {
+		if _, err := a.Audit(ctx); err != nil {
+			a.log.Info("Cannot audit kops clusters for upgrades", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Audit evaluates every Kops resource once and returns the report. Each
+// channel is only loaded once per audit.
+func (a *Auditor) Audit(ctx context.Context) (*Report, error) {
+	l := &v1alpha1.KopsList{}
+	if err := a.kube.List(ctx, l); err != nil {
+		return nil, errors.Wrap(err, errListKops)
+	}
+
+	channels := map[string]*kopsapi.Channel{}
+	load := func(location string) (*kopsapi.Channel, error) {
+		if c, ok := channels[location]; ok {
+			return c, nil
+		}
+		c, err := a.loadChannel(location)
+		if err != nil {
+			return nil, errors.Wrapf(err, errLoadChannel, location)
+		}
+		channels[location] = c
+		return c, nil
+	}
+
+	r := &Report{Time: time.Now(), Clusters: len(l.Items), Findings: []Finding{}}
+	var kubernetes, images int
+	for i := range l.Items {
+		f, ok := audit(&l.Items[i], load)
+		if !ok {
+			continue
+		}
+		if f.RecommendedKubernetesVersion != "" {
+			kubernetes++
+		}
+		if len(f.OutdatedImages) > 0 {
+			images++
+		}
+		if f.Error != "" {
+			a.log.Debug("Cannot audit kops cluster for upgrades", "cluster", f.Cluster, "error", f.Error)
+		}
+		r.Findings = append(r.Findings, f)
+	}
+	sort.Slice(r.Findings, func(i, j int) bool { return r.Findings[i].Name < r.Findings[j].Name })
+
+	metrics.RecordUpgradeAudit(kubernetes, images)
+	a.log.Info("Audited kops clusters for upgrades", "clusters", r.Clusters, "kubernetes-upgrades", kubernetes, "outdated-images", images)
+
+	a.mu.Lock()
+	a.last = r
+	a.mu.Unlock()
+	return r, nil
+}
+
+// ServeHTTP serves the report of the last audit.
+func (a *Auditor) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	a.mu.RLock()
+	r := a.last
+	a.mu.RUnlock()
+	if r == nil {
+		http.Error(w, errNoReport, http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(r)
+}
+
+// audit evaluates the desired spec of a Kops resource against its channel. It
+// returns false if the cluster needs no upgrade.
+func audit(cr *v1alpha1.Kops, load func(location string) (*kopsapi.Channel, error)) (Finding, bool) {
+	spec := util.DesiredClusterSpec(cr)
+	f := Finding{
+		Name:              cr.GetName(),
+		Cluster:           fmt.Sprintf("%v.%v", meta.GetExternalName(cr), cr.Spec.ForProvider.Domain),
+		Channel:           spec.Channel,
+		KubernetesVersion: spec.KubernetesVersion,
+	}
+
+	channel, err := load(spec.Channel)
+	if err != nil {
+		f.Error = err.Error()
+		return f, true
+	}
+	to, required, err := util.GetKubernetesUpgrade(channel, spec.KubernetesVersion)
+	if err != nil {
+		f.Error = err.Error()
+		return f, true
+	}
+	if to != "" {
+		f.RecommendedKubernetesVersion = to
+		f.Urgency = v1alpha1.UpgradeUrgencyRecommended
+		if required {
+			f.Urgency = v1alpha1.UpgradeUrgencyRequired
+		}
+	}
+	f.OutdatedImages = outdatedImages(channel, spec, util.DesiredInstanceGroupSpecs(cr), cr.Spec.ForProvider.ImageTracking)
+	return f, f.RecommendedKubernetesVersion != "" || len(f.OutdatedImages) > 0
+}
+
+// outdatedImages returns the instance groups whose image differs from the one
+// the channel lists for their architecture, which is amd64 unless their image
+// tracking says otherwise. Only images published by the owner of the channel's
+// image are compared, so that custom images and images looked up in SSM
+// parameters aren't reported.
+func outdatedImages(channel *kopsapi.Channel, spec kopsapi.ClusterSpec, igs []kopsapi.InstanceGroupSpec, tracking []v1alpha1.ImageTrackingParameters) []OutdatedImage {
+	provider := kopsapi.CloudProviderID(spec.CloudProvider)
+	latest := map[string]string{}
+	for _, arch := range []architectures.Architecture{architectures.ArchitectureAmd64, architectures.ArchitectureArm64} {
+		if image, err := util.GetChannelImage(channel, provider, spec.KubernetesVersion, string(arch)); err == nil {
+			latest[string(arch)] = image
+		}
+	}
+	isLatest := map[string]bool{}
+	for _, image := range latest {
+		isLatest[image] = true
+	}
+
+	archs := map[string]string{}
+	ssm := map[string]bool{}
+	for _, t := range tracking {
+		archs[t.InstanceGroup] = t.Architecture
+		ssm[t.InstanceGroup] = t.Source == v1alpha1.ImageSourceSSMParameter
+	}
+
+	var outdated []OutdatedImage
+	for _, ig := range igs {
+		name := ig.NodeLabels[kopsapi.NodeLabelInstanceGroup]
+		if ig.Image == "" || isLatest[ig.Image] || ssm[name] {
+			continue
+		}
+		arch := archs[name]
+		if arch == "" {
+			arch = string(architectures.ArchitectureAmd64)
+		}
+		image, ok := latest[arch]
+		if !ok || imageOwner(ig.Image) == "" || imageOwner(ig.Image) != imageOwner(image) {
+			continue
+		}
+		outdated = append(outdated, OutdatedImage{InstanceGroup: name, Image: ig.Image, ChannelImage: image})
+	}
+	return outdated
+}
+
+// imageOwner returns the owner of an image named owner/name, or an empty
+// string if the image is an ID.
+func imageOwner(image string) string {
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return ""
+	}
+	return image[:i]
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+func TestAudit(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	channel := &kopsapi.Channel{Spec: kopsapi.ChannelSpec{
+		KubernetesVersions: []kopsapi.KubernetesVersionSpec{
+			{Range: ">=1.23.0", RecommendedVersion: "1.23.17"},
+			{Range: "<1.23.0", RecommendedVersion: "1.23.17", RequiredVersion: "1.23.0"},
+		},
+		Images: []*kopsapi.ChannelImageSpec{
+			{ProviderID: "aws", ArchitectureID: "amd64", Name: "099720109477/ubuntu-focal-amd64-20230101", KubernetesVersion: ">=1.22.0"},
+			{ProviderID: "aws", ArchitectureID: "arm64", Name: "099720109477/ubuntu-focal-arm64-20230101", KubernetesVersion: ">=1.22.0"},
+		},
+	}}
+
+	kops := func(name, channel, version string, images map[string]string, tracking ...v1alpha1.ImageTrackingParameters) v1alpha1.Kops {
+		cr := v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		meta.SetExternalName(&cr, name)
+		cr.Spec.ForProvider.Domain = "example.com"
+		cr.Spec.ForProvider.ClusterSpec = kopsapi.ClusterSpec{CloudProvider: "aws", Channel: channel, KubernetesVersion: version}
+		for ig, image := range images {
+			cr.Spec.ForProvider.InstanceGroupSpec = append(cr.Spec.ForProvider.InstanceGroupSpec, kopsapi.InstanceGroupSpec{
+				Image:      image,
+				NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: ig},
+			})
+		}
+		cr.Spec.ForProvider.ImageTracking = tracking
+		return cr
+	}
+
+	items := []v1alpha1.Kops{
+		kops("current", "stable", "1.23.17", map[string]string{"nodes": "099720109477/ubuntu-focal-amd64-20230101"}),
+		kops("recommended", "stable", "1.23.5", nil),
+		kops("required", "stable", "1.22.4", nil),
+		kops("stale", "stable", "1.23.17", map[string]string{"nodes": "099720109477/ubuntu-focal-amd64-20220404"}),
+		kops("arm", "stable", "1.23.17", map[string]string{"nodes": "099720109477/ubuntu-focal-arm64-20220404"},
+			v1alpha1.ImageTrackingParameters{InstanceGroup: "nodes", Source: v1alpha1.ImageSourceChannel, Architecture: "arm64"}),
+		kops("custom", "stable", "1.23.17", map[string]string{"nodes": "123456789012/golden-20220404", "bastions": "ami-0123456789abcdef0"}),
+		kops("ssm", "stable", "1.23.17", map[string]string{"nodes": "099720109477/ubuntu-focal-amd64-20220404"},
+			v1alpha1.ImageTrackingParameters{InstanceGroup: "nodes", Source: v1alpha1.ImageSourceSSMParameter}),
+		kops("unreachable", "https://example.com/channel", "1.23.17", nil),
+	}
+
+	type want struct {
+		clusters int
+		findings []Finding
+		err      error
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   client.Reader
+		want   want
+	}{
+		"ListError": {
+			reason: "Failing to list Kops resources should return an error.",
+			kube:   &test.MockClient{MockList: test.NewMockListFn(errBoom)},
+			want:   want{err: errors.Wrap(errBoom, errListKops)},
+		},
+		"Findings": {
+			reason: "Only clusters whose channel recommends a newer Kubernetes version or image, or whose channel can't be loaded, should be reported.",
+			kube: &test.MockClient{
+				MockList: test.NewMockListFn(nil, func(obj client.ObjectList) error {
+					obj.(*v1alpha1.KopsList).Items = items
+					return nil
+				}),
+			},
+			want: want{
+				clusters: len(items),
+				findings: []Finding{
+					{
+						Name:              "arm",
+						Cluster:           "arm.example.com",
+						Channel:           "stable",
+						KubernetesVersion: "1.23.17",
+						OutdatedImages:    []OutdatedImage{{InstanceGroup: "nodes", Image: "099720109477/ubuntu-focal-arm64-20220404", ChannelImage: "099720109477/ubuntu-focal-arm64-20230101"}},
+					},
+					{
+						Name:                         "recommended",
+						Cluster:                      "recommended.example.com",
+						Channel:                      "stable",
+						KubernetesVersion:            "1.23.5",
+						RecommendedKubernetesVersion: "1.23.17",
+						Urgency:                      v1alpha1.UpgradeUrgencyRecommended,
+					},
+					{
+						Name:                         "required",
+						Cluster:                      "required.example.com",
+						Channel:                      "stable",
+						KubernetesVersion:            "1.22.4",
+						RecommendedKubernetesVersion: "1.23.17",
+						Urgency:                      v1alpha1.UpgradeUrgencyRequired,
+					},
+					{
+						Name:              "stale",
+						Cluster:           "stale.example.com",
+						Channel:           "stable",
+						KubernetesVersion: "1.23.17",
+						OutdatedImages:    []OutdatedImage{{InstanceGroup: "nodes", Image: "099720109477/ubuntu-focal-amd64-20220404", ChannelImage: "099720109477/ubuntu-focal-amd64-20230101"}},
+					},
+					{
+						Name:              "unreachable",
+						Cluster:           "unreachable.example.com",
+						Channel:           "https://example.com/channel",
+						KubernetesVersion: "1.23.17",
+						Error:             errors.Wrapf(errBoom, errLoadChannel, "https://example.com/channel").Error(),
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			a := &Auditor{
+				kube: tc.kube,
+				log:  logging.NewNopLogger(),
+				loadChannel: func(location string) (*kopsapi.Channel, error) {
+					if location != "stable" {
+						return nil, errBoom
+					}
+					return channel, nil
+				},
+			}
+			got, err := a.Audit(context.Background())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nAudit(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.clusters, got.Clusters); diff != "" {
+				t.Errorf("\n%s\nAudit(...): -want clusters, +got clusters:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.findings, got.Findings); diff != "" {
+				t.Errorf("\n%s\nAudit(...): -want findings, +got findings:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package clients contains the interfaces through which the Kops controller
+// drives kops, so that the controller can be tested without a state store or
+// a cloud.
+package clients
+
+import (
+	"context"
+
+	"k8s.io/client-go/kubernetes"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	kopsClient "k8s.io/kops/pkg/client/simple"
+	"k8s.io/kops/pkg/validation"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+// A ClientsetFactory returns kops clientsets for the state store of a
+// cluster.
+type ClientsetFactory interface {
+	Clientset(stateBucket, clusterName, domain string) (kopsClient.Clientset, error)
+}
+
+// A ClientsetFactoryFn is a function that satisfies the ClientsetFactory
+// interface.
+type ClientsetFactoryFn func(stateBucket, clusterName, domain string) (kopsClient.Clientset, error)
+
+// Clientset returns a kops clientset for the state store of a cluster.
+func (fn ClientsetFactoryFn) Clientset(stateBucket, clusterName, domain string) (kopsClient.Clientset, error) {
+	return fn(stateBucket, clusterName, domain)
+}
+
+// A CloudBuilder builds the cloud a kops cluster runs in.
+type CloudBuilder interface {
+	BuildCloud(cluster *kopsapi.Cluster) (fi.Cloud, error)
+}
+
+// A CloudBuilderFn is a function that satisfies the CloudBuilder interface.
+type CloudBuilderFn func(cluster *kopsapi.Cluster) (fi.Cloud, error)
+
+// BuildCloud builds the cloud a kops cluster runs in.
+func (fn CloudBuilderFn) BuildCloud(cluster *kopsapi.Cluster) (fi.Cloud, error) {
+	return fn(cluster)
+}
+
+// A Validator validates a kops cluster through the supplied client of its API
+// server. Validation is abandoned when the supplied context is done.
+type Validator interface {
+	Validate(ctx context.Context, cluster *kopsapi.Cluster, cloud fi.Cloud, igs *kopsapi.InstanceGroupList, kube kubernetes.Interface) (*validation.ValidationCluster, error)
+}
+
+// A ValidatorFn is a function that satisfies the Validator interface.
+type ValidatorFn func(ctx context.Context, cluster *kopsapi.Cluster, cloud fi.Cloud, igs *kopsapi.InstanceGroupList, kube kubernetes.Interface) (*validation.ValidationCluster, error)
+
+// Validate validates a kops cluster.
+func (fn ValidatorFn) Validate(ctx context.Context, cluster *kopsapi.Cluster, cloud fi.Cloud, igs *kopsapi.InstanceGroupList, kube kubernetes.Interface) (*validation.ValidationCluster, error) {
+	return fn(ctx, cluster, cloud, igs, kube)
+}
+
+// An Applier runs kops apply commands, whether they apply a cluster, render
+// it to Terraform, or only collect its assets.
+type Applier interface {
+	Apply(ctx context.Context, cmd *cloudup.ApplyClusterCmd) error
+}
+
+// An ApplierFn is a function that satisfies the Applier interface.
+type ApplierFn func(ctx context.Context, cmd *cloudup.ApplyClusterCmd) error
+
+// Apply runs a kops apply command.
+func (fn ApplierFn) Apply(ctx context.Context, cmd *cloudup.ApplyClusterCmd) error {
+	return fn(ctx, cmd)
+}
+
+// A Notifier sends notifications of the lifecycle transitions of a cluster.
+type Notifier interface {
+	Notify(ctx context.Context, sink v1alpha1.NotificationParameters, n util.Notification) error
+}
+
+// A NotifierFn is a function that satisfies the Notifier interface.
+type NotifierFn func(ctx context.Context, sink v1alpha1.NotificationParameters, n util.Notification) error
+
+// Notify sends a notification to the supplied sink.
+func (fn NotifierFn) Notify(ctx context.Context, sink v1alpha1.NotificationParameters, n util.Notification) error {
+	return fn(ctx, sink, n)
+}
+
+// The clients that drive kops itself.
+var (
+	KopsClientsetFactory ClientsetFactory = ClientsetFactoryFn(util.GetKopsClientset)
+	KopsCloudBuilder     CloudBuilder     = CloudBuilderFn(cloudup.BuildCloud)
+	KopsValidator        Validator        = ValidatorFn(util.ValidateKopsCluster)
+	KopsApplier          Applier          = ApplierFn(func(ctx context.Context, cmd *cloudup.ApplyClusterCmd) error { return cmd.Run(ctx) })
+)
+
+// HTTPNotifier POSTs notifications to their sink.
+var HTTPNotifier Notifier = NotifierFn(util.SendNotification)
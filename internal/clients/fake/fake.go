@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake contains fakes of the clients through which the Kops
+// controller drives kops.
+package fake
+
+import (
+	"context"
+
+	"k8s.io/client-go/kubernetes"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	kopsClient "k8s.io/kops/pkg/client/simple"
+	"k8s.io/kops/pkg/validation"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/clients"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+var (
+	_ kopsClient.Clientset     = &Clientset{}
+	_ clients.ClientsetFactory = &ClientsetFactory{}
+	_ clients.CloudBuilder     = &CloudBuilder{}
+	_ clients.Validator        = &Validator{}
+	_ clients.Applier          = &Applier{}
+	_ clients.Notifier         = &Notifier{}
+)
+
+// A Clientset is a kops clientset whose cluster operations can be mocked.
+// Operations that are not mocked are passed to the embedded clientset, e.g. a
+// VFS clientset backed by an in-memory filesystem.
+type Clientset struct {
+	kopsClient.Clientset
+
+	MockGetCluster    func(ctx context.Context, name string) (*kopsapi.Cluster, error)
+	MockCreateCluster func(ctx context.Context, cluster *kopsapi.Cluster) (*kopsapi.Cluster, error)
+	MockUpdateCluster func(ctx context.Context, cluster *kopsapi.Cluster, status *kopsapi.ClusterStatus) (*kopsapi.Cluster, error)
+	MockDeleteCluster func(ctx context.Context, cluster *kopsapi.Cluster) error
+}
+
+// GetCluster calls MockGetCluster if set.
+func (c *Clientset) GetCluster(ctx context.Context, name string) (*kopsapi.Cluster, error) {
+	if c.MockGetCluster != nil {
+		return c.MockGetCluster(ctx, name)
+	}
+	return c.Clientset.GetCluster(ctx, name)
+}
+
+// CreateCluster calls MockCreateCluster if set.
+func (c *Clientset) CreateCluster(ctx context.Context, cluster *kopsapi.Cluster) (*kopsapi.Cluster, error) {
+	if c.MockCreateCluster != nil {
+		return c.MockCreateCluster(ctx, cluster)
+	}
+	return c.Clientset.CreateCluster(ctx, cluster)
+}
+
+// UpdateCluster calls MockUpdateCluster if set.
+func (c *Clientset) UpdateCluster(ctx context.Context, cluster *kopsapi.Cluster, status *kopsapi.ClusterStatus) (*kopsapi.Cluster, error) {
+	if c.MockUpdateCluster != nil {
+		return c.MockUpdateCluster(ctx, cluster, status)
+	}
+	return c.Clientset.UpdateCluster(ctx, cluster, status)
+}
+
+// DeleteCluster calls MockDeleteCluster if set.
+func (c *Clientset) DeleteCluster(ctx context.Context, cluster *kopsapi.Cluster) error {
+	if c.MockDeleteCluster != nil {
+		return c.MockDeleteCluster(ctx, cluster)
+	}
+	return c.Clientset.DeleteCluster(ctx, cluster)
+}
+
+// A ClientsetFactory is a fake clients.ClientsetFactory.
+type ClientsetFactory struct {
+	MockClientset func(stateBucket, clusterName, domain string) (kopsClient.Clientset, error)
+}
+
+// Clientset calls MockClientset.
+func (f *ClientsetFactory) Clientset(stateBucket, clusterName, domain string) (kopsClient.Clientset, error) {
+	return f.MockClientset(stateBucket, clusterName, domain)
+}
+
+// A CloudBuilder is a fake clients.CloudBuilder.
+type CloudBuilder struct {
+	MockBuildCloud func(cluster *kopsapi.Cluster) (fi.Cloud, error)
+}
+
+// BuildCloud calls MockBuildCloud.
+func (b *CloudBuilder) BuildCloud(cluster *kopsapi.Cluster) (fi.Cloud, error) {
+	return b.MockBuildCloud(cluster)
+}
+
+// A Validator is a fake clients.Validator.
+type Validator struct {
+	MockValidate func(ctx context.Context, cluster *kopsapi.Cluster, cloud fi.Cloud, igs *kopsapi.InstanceGroupList, kube kubernetes.Interface) (*validation.ValidationCluster, error)
+}
+
+// Validate calls MockValidate.
+func (v *Validator) Validate(ctx context.Context, cluster *kopsapi.Cluster, cloud fi.Cloud, igs *kopsapi.InstanceGroupList, kube kubernetes.Interface) (*validation.ValidationCluster, error) {
+	return v.MockValidate(ctx, cluster, cloud, igs, kube)
+}
+
+// An Applier is a fake clients.Applier.
+type Applier struct {
+	MockApply func(ctx context.Context, cmd *cloudup.ApplyClusterCmd) error
+}
+
+// Apply calls MockApply.
+func (a *Applier) Apply(ctx context.Context, cmd *cloudup.ApplyClusterCmd) error {
+	return a.MockApply(ctx, cmd)
+}
+
+// A Notifier is a fake clients.Notifier.
+type Notifier struct {
+	MockNotify func(ctx context.Context, sink v1alpha1.NotificationParameters, n util.Notification) error
+}
+
+// Notify calls MockNotify.
+func (n *Notifier) Notify(ctx context.Context, sink v1alpha1.NotificationParameters, no util.Notification) error {
+	return n.MockNotify(ctx, sink, no)
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fleet implements the controller of KopsFleets.
+package fleet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errGetFleet        = "cannot get KopsFleet"
+	errUpdateStatus    = "cannot update KopsFleet status"
+	errListClusters    = "cannot list Kops of KopsFleet"
+	errHashSpec        = "cannot hash Kops spec"
+	errCreateCluster   = "cannot create Kops"
+	errUpdateCluster   = "cannot update Kops"
+	errMergeSpec       = "cannot merge the spec of the fleet into Kops"
+	errDeleteCluster   = "cannot delete Kops"
+	errClusterNotOwned = "Kops %s exists but is not controlled by this KopsFleet"
+)
+
+const (
+	reasonReconcile      event.Reason = "CannotReconcileFleet"
+	reasonCreatedCluster event.Reason = "CreatedCluster"
+	reasonUpdatedCluster event.Reason = "UpdatedCluster"
+	reasonDeletedCluster event.Reason = "DeletedCluster"
+)
+
+// pollInterval is how often a fleet whose clusters aren't all available and
+// updated is checked.
+const pollInterval = 30 * time.Second
+
+// Setup adds a controller that reconciles KopsFleets.
+func Setup(mgr ctrl.Manager, o controller.Options) error {
+	name := "fleet/" + strings.ToLower(v1alpha1.KopsFleetGroupKind)
+
+	r := &Reconciler{
+		kube:   mgr.GetClient(),
+		log:    o.Logger.WithValues("controller", name),
+		record: event.NewAPIRecorder(mgr.GetEventRecorderFor(name)),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.KopsFleet{}).
+		Owns(&v1alpha1.Kops{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A Reconciler reconciles KopsFleets by creating, updating and deleting the
+// Kops resources of their clusters.
+type Reconciler struct {
+	kube   client.Client
+	log    logging.Logger
+	record event.Recorder
+}
+
+// Reconcile a KopsFleet.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := r.log.WithValues("request", req)
+
+	f := &v1alpha1.KopsFleet{}
+	if err := r.kube.Get(ctx, req.NamespacedName, f); err != nil {
+		return reconcile.Result{}, errors.Wrap(resource.IgnoreNotFound(err), errGetFleet)
+	}
+	if meta.WasDeleted(f) {
+		// The Kops resources of the fleet are garbage collected.
+		return reconcile.Result{}, nil
+	}
+
+	res, err := r.reconcileClusters(ctx, f)
+	if err != nil {
+		log.Debug("Cannot reconcile fleet", "error", err)
+		r.record.Event(f, event.Warning(reasonReconcile, err))
+		f.SetConditions(xpv1.ReconcileError(err))
+		return reconcile.Result{RequeueAfter: pollInterval}, errors.Wrap(r.kube.Status().Update(ctx, f), errUpdateStatus)
+	}
+	f.SetConditions(xpv1.ReconcileSuccess())
+	return res, errors.Wrap(r.kube.Status().Update(ctx, f), errUpdateStatus)
+}
+
+func (r *Reconciler) reconcileClusters(ctx context.Context, f *v1alpha1.KopsFleet) (reconcile.Result, error) {
+	l := &v1alpha1.KopsList{}
+	if err := r.kube.List(ctx, l, client.MatchingLabels{v1alpha1.LabelKeyFleet: f.GetName()}); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, errListClusters)
+	}
+	existing := map[string]*v1alpha1.Kops{}
+	for i := range l.Items {
+		existing[l.Items[i].GetName()] = &l.Items[i]
+	}
+
+	// Clusters removed from the fleet are deleted.
+	listed := map[string]bool{}
+	for _, c := range f.Spec.Clusters {
+		listed[c.Name] = true
+	}
+	for name, cr := range existing {
+		if listed[name] || !metav1.IsControlledBy(cr, f) || meta.WasDeleted(cr) {
+			continue
+		}
+		if err := r.kube.Delete(ctx, cr); resource.IgnoreNotFound(err) != nil {
+			return reconcile.Result{}, errors.Wrap(err, errDeleteCluster)
+		}
+		r.record.Event(f, event.Normal(reasonDeletedCluster, fmt.Sprintf("Deleted Kops %s removed from the fleet", name)))
+	}
+
+	maxUnavailable := f.Spec.MaxUnavailable
+	if maxUnavailable < 1 {
+		maxUnavailable = 1
+	}
+	unavailable := int32(0)
+	for _, c := range f.Spec.Clusters {
+		if cr, ok := existing[c.Name]; ok && !Available(cr) {
+			unavailable++
+		}
+	}
+
+	f.Status.Clusters = make([]v1alpha1.FleetClusterObservation, 0, len(f.Spec.Clusters))
+	f.Status.Total, f.Status.Ready, f.Status.Updated = int32(len(f.Spec.Clusters)), 0, 0
+	for _, c := range f.Spec.Clusters {
+		desired := NewCluster(f, c)
+		hash, err := HashSpec(desired.Spec)
+		if err != nil {
+			return reconcile.Result{}, errors.Wrap(err, errHashSpec)
+		}
+		meta.AddAnnotations(desired, map[string]string{v1alpha1.AnnotationKeyFleetSpecHash: hash})
+
+		cr, ok := existing[c.Name]
+		switch {
+		case !ok:
+			// New clusters don't make existing clusters unavailable, so they
+			// are created regardless of the rollout.
+			if err := r.kube.Create(ctx, desired); err != nil {
+				return reconcile.Result{}, errors.Wrap(err, errCreateCluster)
+			}
+			r.record.Event(f, event.Normal(reasonCreatedCluster, fmt.Sprintf("Created Kops %s", c.Name)))
+			cr = desired
+		case !metav1.IsControlledBy(cr, f):
+			return reconcile.Result{}, errors.Errorf(errClusterNotOwned, c.Name)
+		case cr.GetAnnotations()[v1alpha1.AnnotationKeyFleetSpecHash] != hash && (!Available(cr) || unavailable < maxUnavailable):
+			// Updating an available cluster makes it unavailable until
+			// the update is applied.
+			if Available(cr) {
+				unavailable++
+			}
+			if err := updateSpec(cr, desired); err != nil {
+				return reconcile.Result{}, errors.Wrap(err, errMergeSpec)
+			}
+			meta.AddAnnotations(cr, desired.GetAnnotations())
+			if err := r.kube.Update(ctx, cr); err != nil {
+				return reconcile.Result{}, errors.Wrap(err, errUpdateCluster)
+			}
+			r.record.Event(f, event.Normal(reasonUpdatedCluster, fmt.Sprintf("Updated Kops %s to the current spec of the fleet", c.Name)))
+		}
+
+		o := v1alpha1.FleetClusterObservation{
+			Name:    c.Name,
+			Ready:   cr.GetCondition(xpv1.TypeReady).Status == corev1.ConditionTrue,
+			Updated: cr.GetAnnotations()[v1alpha1.AnnotationKeyFleetSpecHash] == hash,
+		}
+		if o.Ready {
+			f.Status.Ready++
+		}
+		if o.Updated {
+			f.Status.Updated++
+		}
+		f.Status.Clusters = append(f.Status.Clusters, o)
+	}
+
+	if f.Status.Ready < f.Status.Total {
+		f.SetConditions(xpv1.Unavailable())
+	} else {
+		f.SetConditions(xpv1.Available())
+	}
+	if f.Status.Ready < f.Status.Total || f.Status.Updated < f.Status.Total {
+		return reconcile.Result{RequeueAfter: pollInterval}, nil
+	}
+	return reconcile.Result{}, nil
+}
+
+// NewCluster returns the Kops resource of the supplied cluster of the
+// supplied fleet, rendered from the fleet's template and the cluster's
+// overrides.
+func NewCluster(f *v1alpha1.KopsFleet, c v1alpha1.FleetClusterParameters) *v1alpha1.Kops {
+	cr := &v1alpha1.Kops{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            c.Name,
+			Labels:          map[string]string{v1alpha1.LabelKeyFleet: f.GetName()},
+			OwnerReferences: []metav1.OwnerReference{meta.AsController(meta.TypedReferenceTo(f, v1alpha1.KopsFleetGroupVersionKind))},
+		},
+		Spec: v1alpha1.KopsSpec{
+			ResourceSpec: xpv1.ResourceSpec{
+				DeletionPolicy: f.Spec.Template.DeletionPolicy,
+			},
+			ForProvider: *f.Spec.Template.ForProvider.DeepCopy(),
+		},
+	}
+	meta.SetExternalName(cr, c.Name)
+	if ref := f.Spec.Template.ProviderConfigReference; ref != nil {
+		cr.Spec.ProviderConfigReference = &xpv1.Reference{Name: ref.Name}
+	}
+	if ref := c.WriteConnectionSecretToReference; ref != nil {
+		cr.Spec.WriteConnectionSecretToReference = &xpv1.SecretReference{Name: ref.Name, Namespace: ref.Namespace}
+	}
+
+	fp := &cr.Spec.ForProvider
+	if c.Region != "" {
+		fp.Region = c.Region
+	}
+	if len(c.Zones) > 0 {
+		zones := append([]string{}, c.Zones...)
+		if fp.Simple != nil {
+			fp.Simple.Zones = zones
+		} else {
+			fp.Zones = zones
+		}
+	}
+	if c.NetworkCIDR != "" {
+		fp.ClusterSpec.NetworkCIDR = c.NetworkCIDR
+	}
+	// The Kops webhook only defaults the spec of a Kops resource when it is
+	// created, so the defaults are rendered here too, lest an update of the
+	// template remove them from existing clusters. Simple mode fills in its
+	// own defaults.
+	if fp.Simple == nil {
+		util.DefaultClusterSpec(&fp.ClusterSpec, fp.InstanceGroupSpec)
+	}
+	return cr
+}
+
+// updateSpec updates the supplied existing Kops resource to the spec the
+// fleet rendered for it. Only the fields the fleet renders are updated, so
+// that those set by the managed resource reconciler, or on the resource
+// itself, are kept. The rendered parameters are merged into the existing ones
+// as a JSON merge patch: the fields the template or the cluster's overrides
+// set are updated, lists they set replace the existing ones, and fields they
+// leave unset are kept. A field removed from the template is therefore left
+// as it is on existing clusters.
+func updateSpec(cr, desired *v1alpha1.Kops) error {
+	current, err := json.Marshal(cr.Spec.ForProvider)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(desired.Spec.ForProvider)
+	if err != nil {
+		return err
+	}
+	merged, err := jsonpatch.MergePatch(current, patch)
+	if err != nil {
+		return err
+	}
+	fp := v1alpha1.KopsParameters{}
+	if err := json.Unmarshal(merged, &fp); err != nil {
+		return err
+	}
+	cr.Spec.ForProvider = fp
+	cr.Spec.DeletionPolicy = desired.Spec.DeletionPolicy
+	if ref := desired.Spec.ProviderConfigReference; ref != nil {
+		cr.Spec.ProviderConfigReference = ref
+	}
+	if ref := desired.Spec.WriteConnectionSecretToReference; ref != nil {
+		cr.Spec.WriteConnectionSecretToReference = ref
+	}
+	return nil
+}
+
+// HashSpec returns the SHA-256 hash of the JSON encoding of the supplied spec
+// of a Kops resource.
+func HashSpec(spec v1alpha1.KopsSpec) (string, error) {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Available returns whether the supplied Kops resource is ready and has
+// applied its latest spec.
+func Available(cr *v1alpha1.Kops) bool {
+	return cr.GetCondition(xpv1.TypeReady).Status == corev1.ConditionTrue &&
+		cr.Status.AtProvider.LastAppliedGeneration >= cr.GetGeneration()
+}
@@ -0,0 +1,292 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fleet
+
+import (
+	"context"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+func TestReconcile(t *testing.T) {
+	fleet := func(v string, clusters ...string) *v1alpha1.KopsFleet {
+		f := &v1alpha1.KopsFleet{ObjectMeta: metav1.ObjectMeta{Name: "fleet", UID: "uid"}}
+		f.Spec.MaxUnavailable = 1
+		f.Spec.Template.ForProvider.ClusterSpec.KubernetesVersion = v
+		for _, c := range clusters {
+			f.Spec.Clusters = append(f.Spec.Clusters, v1alpha1.FleetClusterParameters{Name: c})
+		}
+		return f
+	}
+	// cluster returns the Kops resource the fleet rendered for the named
+	// cluster at the supplied Kubernetes version.
+	cluster := func(name, v string, available bool) v1alpha1.Kops {
+		cr := NewCluster(fleet(v), v1alpha1.FleetClusterParameters{Name: name})
+		hash, _ := HashSpec(cr.Spec)
+		meta.AddAnnotations(cr, map[string]string{v1alpha1.AnnotationKeyFleetSpecHash: hash})
+		if available {
+			cr.SetConditions(xpv1.Available())
+		}
+		return *cr
+	}
+
+	type want struct {
+		created         []string
+		updated         []string
+		deleted         []string
+		ready           int32
+		updatedClusters int32
+	}
+
+	cases := map[string]struct {
+		reason   string
+		fleet    *v1alpha1.KopsFleet
+		existing []v1alpha1.Kops
+		want     want
+	}{
+		"CreateClusters": {
+			reason: "A Kops resource should be created for every cluster of the fleet.",
+			fleet:  fleet("1.23.8", "a", "b"),
+			want:   want{created: []string{"a", "b"}, updatedClusters: 2},
+		},
+		"StagedRollout": {
+			reason:   "Template changes should be rolled out to no more than maxUnavailable clusters at once.",
+			fleet:    fleet("1.24.0", "a", "b", "c"),
+			existing: []v1alpha1.Kops{cluster("a", "1.23.8", true), cluster("b", "1.23.8", true), cluster("c", "1.23.8", true)},
+			want:     want{updated: []string{"a"}, ready: 3, updatedClusters: 1},
+		},
+		"RolloutBlocked": {
+			reason:   "Template changes should not be rolled out while maxUnavailable clusters are unavailable.",
+			fleet:    fleet("1.24.0", "a", "b"),
+			existing: []v1alpha1.Kops{cluster("a", "1.24.0", false), cluster("b", "1.23.8", true)},
+			want:     want{ready: 1, updatedClusters: 1},
+		},
+		"RemoveCluster": {
+			reason:   "Kops resources of clusters removed from the fleet should be deleted.",
+			fleet:    fleet("1.23.8", "a"),
+			existing: []v1alpha1.Kops{cluster("a", "1.23.8", true), cluster("b", "1.23.8", true)},
+			want:     want{deleted: []string{"b"}, ready: 1, updatedClusters: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := want{}
+			var status *v1alpha1.KopsFleet
+			kube := &test.MockClient{
+				MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+					tc.fleet.DeepCopyInto(obj.(*v1alpha1.KopsFleet))
+					return nil
+				}),
+				MockList: test.NewMockListFn(nil, func(obj client.ObjectList) error {
+					l := obj.(*v1alpha1.KopsList)
+					for i := range tc.existing {
+						l.Items = append(l.Items, *tc.existing[i].DeepCopy())
+					}
+					return nil
+				}),
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+					got.created = append(got.created, obj.GetName())
+					return nil
+				},
+				MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+					got.updated = append(got.updated, obj.GetName())
+					return nil
+				},
+				MockDelete: func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+					got.deleted = append(got.deleted, obj.GetName())
+					return nil
+				},
+				MockStatusUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+					status = obj.(*v1alpha1.KopsFleet)
+					return nil
+				},
+			}
+			r := &Reconciler{kube: kube, log: logging.NewNopLogger(), record: event.NewNopRecorder()}
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "fleet"}}); err != nil {
+				t.Fatalf("Reconcile(...): %v", err)
+			}
+			if c := status.GetCondition(xpv1.TypeSynced); !c.Equal(xpv1.ReconcileSuccess()) {
+				t.Fatalf("Reconcile(...): want ReconcileSuccess, got %v", c)
+			}
+			got.ready, got.updatedClusters = status.Status.Ready, status.Status.Updated
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestNewCluster(t *testing.T) {
+	f := &v1alpha1.KopsFleet{ObjectMeta: metav1.ObjectMeta{Name: "fleet", UID: "uid"}}
+	f.Spec.Template.ForProvider.Region = "us-east-1"
+	f.Spec.Template.ForProvider.Zones = []string{"us-east-1a"}
+	f.Spec.Template.ForProvider.ClusterSpec.NetworkCIDR = "172.20.0.0/16"
+
+	cr := NewCluster(f, v1alpha1.FleetClusterParameters{
+		Name:        "euw1",
+		Region:      "eu-west-1",
+		Zones:       []string{"eu-west-1a"},
+		NetworkCIDR: "172.21.0.0/16",
+	})
+
+	want := v1alpha1.KopsParameters{Region: "eu-west-1", Zones: []string{"eu-west-1a"}}
+	want.ClusterSpec.NetworkCIDR = "172.21.0.0/16"
+	util.DefaultClusterSpec(&want.ClusterSpec, nil)
+	if diff := cmp.Diff(want, cr.Spec.ForProvider); diff != "" {
+		t.Errorf("\nNewCluster(...): -want, +got:\n%s\n", diff)
+	}
+	if meta.GetExternalName(cr) != "euw1" {
+		t.Errorf("NewCluster(...): want external name euw1, got %q", meta.GetExternalName(cr))
+	}
+	if !metav1.IsControlledBy(cr, f) {
+		t.Errorf("NewCluster(...): want Kops controlled by the fleet")
+	}
+	if f.Spec.Template.ForProvider.Region != "us-east-1" {
+		t.Errorf("NewCluster(...): modified the fleet template")
+	}
+}
+
+func TestUpdateKeepsDefaults(t *testing.T) {
+	f := &v1alpha1.KopsFleet{ObjectMeta: metav1.ObjectMeta{Name: "fleet", UID: "uid"}}
+	f.Spec.MaxUnavailable = 1
+	f.Spec.Template.ForProvider.ClusterSpec.KubernetesVersion = "1.23.8"
+	f.Spec.Template.ForProvider.InstanceGroupSpec = []kopsapi.InstanceGroupSpec{{
+		Role:       kopsapi.InstanceGroupRoleMaster,
+		Subnets:    []string{"us-east-1a"},
+		NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: "master-us-east-1a"},
+	}}
+	f.Spec.Clusters = []v1alpha1.FleetClusterParameters{{Name: "a"}}
+
+	// The existing cluster was created from an earlier template, defaulted by
+	// the webhook and had its provider config defaulted by its reconciler.
+	existing := NewCluster(f, f.Spec.Clusters[0])
+	existing.Spec.ProviderConfigReference = &xpv1.Reference{Name: "default"}
+	existing.SetConditions(xpv1.Available())
+	meta.AddAnnotations(existing, map[string]string{v1alpha1.AnnotationKeyFleetSpecHash: "stale"})
+	f.Spec.Template.ForProvider.ClusterSpec.KubernetesVersion = "1.24.0"
+
+	var updated *v1alpha1.Kops
+	kube := &test.MockClient{
+		MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+			f.DeepCopyInto(obj.(*v1alpha1.KopsFleet))
+			return nil
+		}),
+		MockList: test.NewMockListFn(nil, func(obj client.ObjectList) error {
+			obj.(*v1alpha1.KopsList).Items = []v1alpha1.Kops{*existing.DeepCopy()}
+			return nil
+		}),
+		MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+			updated = obj.(*v1alpha1.Kops)
+			return nil
+		},
+		MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+	}
+	r := &Reconciler{kube: kube, log: logging.NewNopLogger(), record: event.NewNopRecorder()}
+
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "fleet"}}); err != nil {
+		t.Fatalf("Reconcile(...): %v", err)
+	}
+	if updated == nil {
+		t.Fatal("Reconcile(...): want the existing cluster updated")
+	}
+	spec := updated.Spec.ForProvider.ClusterSpec
+	if spec.KubernetesVersion != "1.24.0" {
+		t.Errorf("Reconcile(...): want kubernetesVersion 1.24.0, got %q", spec.KubernetesVersion)
+	}
+	if spec.Topology == nil || spec.Networking == nil || len(spec.EtcdClusters) == 0 {
+		t.Errorf("Reconcile(...): want the defaults of the cluster spec kept, got topology %v, networking %v, etcd clusters %v", spec.Topology, spec.Networking, spec.EtcdClusters)
+	}
+	if diff := cmp.Diff(&xpv1.Reference{Name: "default"}, updated.Spec.ProviderConfigReference); diff != "" {
+		t.Errorf("Reconcile(...): -want provider config reference, +got:\n%s\n", diff)
+	}
+}
+
+func TestUpdateSpec(t *testing.T) {
+	ig := func(name, machineType string) kopsapi.InstanceGroupSpec {
+		return kopsapi.InstanceGroupSpec{MachineType: machineType, NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: name}}
+	}
+
+	cases := map[string]struct {
+		reason   string
+		existing v1alpha1.KopsParameters
+		desired  v1alpha1.KopsParameters
+		want     v1alpha1.KopsParameters
+	}{
+		"TemplateFieldsUpdated": {
+			reason:   "Fields the fleet renders should be updated.",
+			existing: v1alpha1.KopsParameters{Domain: "example.com", Region: "us-east-1", ClusterSpec: kopsapi.ClusterSpec{KubernetesVersion: "1.23.8"}},
+			desired:  v1alpha1.KopsParameters{Domain: "example.com", Region: "us-east-1", ClusterSpec: kopsapi.ClusterSpec{KubernetesVersion: "1.24.0"}},
+			want:     v1alpha1.KopsParameters{Domain: "example.com", Region: "us-east-1", ClusterSpec: kopsapi.ClusterSpec{KubernetesVersion: "1.24.0"}},
+		},
+		"UnrelatedFieldsKept": {
+			reason: "Fields the fleet doesn't render should be kept.",
+			existing: v1alpha1.KopsParameters{
+				Domain:              "example.com",
+				RecordClusterEvents: true,
+				DependsOn:           []v1alpha1.DependencyReference{{Name: "network"}},
+				StateReplica:        &v1alpha1.StateReplicaParameters{Bucket: "s3://replica"},
+				ClusterSpec:         kopsapi.ClusterSpec{KubernetesVersion: "1.23.8", Channel: "alpha", CloudLabels: map[string]string{"team": "a"}},
+			},
+			desired: v1alpha1.KopsParameters{
+				Domain:      "example.com",
+				ClusterSpec: kopsapi.ClusterSpec{KubernetesVersion: "1.24.0", CloudLabels: map[string]string{"fleet": "prod"}},
+			},
+			want: v1alpha1.KopsParameters{
+				Domain:              "example.com",
+				RecordClusterEvents: true,
+				DependsOn:           []v1alpha1.DependencyReference{{Name: "network"}},
+				StateReplica:        &v1alpha1.StateReplicaParameters{Bucket: "s3://replica"},
+				ClusterSpec:         kopsapi.ClusterSpec{KubernetesVersion: "1.24.0", Channel: "alpha", CloudLabels: map[string]string{"team": "a", "fleet": "prod"}},
+			},
+		},
+		"ListsReplaced": {
+			reason:   "Lists the fleet renders should replace the existing ones.",
+			existing: v1alpha1.KopsParameters{InstanceGroupSpec: []kopsapi.InstanceGroupSpec{ig("nodes", "t3.large"), ig("old", "t3.large")}},
+			desired:  v1alpha1.KopsParameters{InstanceGroupSpec: []kopsapi.InstanceGroupSpec{ig("nodes", "m5.large")}},
+			want:     v1alpha1.KopsParameters{InstanceGroupSpec: []kopsapi.InstanceGroupSpec{ig("nodes", "m5.large")}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{Spec: v1alpha1.KopsSpec{ForProvider: tc.existing}}
+			desired := &v1alpha1.Kops{Spec: v1alpha1.KopsSpec{ForProvider: tc.desired}}
+			if err := updateSpec(cr, desired); err != nil {
+				t.Fatalf("updateSpec(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, cr.Spec.ForProvider); diff != "" {
+				t.Errorf("\n%s\nupdateSpec(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -17,23 +17,79 @@ limitations under the License.
 package controller
 
 import (
+	"time"
+
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/crossplane/provider-kops/internal/controller/config"
+	"github.com/crossplane/provider-kops/internal/controller/fleet"
 	"github.com/crossplane/provider-kops/internal/controller/kops"
+	"github.com/crossplane/provider-kops/internal/controller/migration"
 )
 
 // Setup creates all Kops controllers with the supplied logger and adds them to
-// the supplied manager.
-func Setup(mgr ctrl.Manager, o controller.Options) error {
-	for _, setup := range []func(ctrl.Manager, controller.Options) error{
-		config.Setup,
-		kops.Setup,
-	} {
+// the supplied manager. The controllers of resources other than Kops resources
+// aren't sharded, so they are only set up on the primary shard.
+func Setup(mgr ctrl.Manager, o controller.Options, ko kops.Options) error {
+	setups := []func(ctrl.Manager, controller.Options) error{
+		func(mgr ctrl.Manager, o controller.Options) error { return kops.Setup(mgr, o, ko) },
+	}
+	if ko.Shard.Primary() {
+		setups = append(setups, config.Setup, migration.Setup, fleet.Setup)
+	}
+	for _, setup := range setups {
 		if err := setup(mgr, o); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+const errRenewDeadline = "leader election renew deadline must be lower than its lease duration"
+
+// LeaderElection configures how replicas of the provider elect the one that
+// reconciles Kops resources while the others stand by.
+type LeaderElection struct {
+	// Enabled runs the provider with leader election.
+	Enabled bool
+
+	// ID is the name of the lease the replicas compete for.
+	ID string
+
+	// LeaseDuration is how long standby replicas wait before taking over
+	// from a leader that stopped renewing its lease.
+	LeaseDuration time.Duration
+
+	// RenewDeadline is how long the leader keeps retrying to renew its lease
+	// before giving up leadership.
+	RenewDeadline time.Duration
+}
+
+// Configure sets up leader election in the supplied manager options.
+func (l LeaderElection) Configure(o *ctrl.Options) error {
+	if l.Enabled && l.RenewDeadline >= l.LeaseDuration {
+		return errors.New(errRenewDeadline)
+	}
+
+	// controller-runtime uses both ConfigMaps and Leases for leader
+	// election by default. Leases expire after 15 seconds, with a
+	// 10 second renewal deadline. We've observed leader loss due to
+	// renewal deadlines being exceeded when under high load - i.e.
+	// hundreds of reconciles per second and ~200rps to the API
+	// server. Switching to Leases only and longer leases appears to
+	// alleviate this.
+	o.LeaderElection = l.Enabled
+	o.LeaderElectionID = l.ID
+	o.LeaderElectionResourceLock = resourcelock.LeasesResourceLock
+	o.LeaseDuration = &l.LeaseDuration
+	o.RenewDeadline = &l.RenewDeadline
+
+	// A leader that is shut down, e.g. when it is drained from its node
+	// or rolled out, releases its lease so that a standby replica takes
+	// over immediately rather than once the lease expires.
+	o.LeaderElectionReleaseOnCancel = l.Enabled
+	return nil
+}
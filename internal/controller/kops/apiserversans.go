@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errRolloutAPIServerSANs = "cannot roll out Kops cluster API server certificate subject alternative names"
+)
+
+const (
+	reasonAPIServerSANsChanged event.Reason = "ChangedAPIServerSANs"
+	reasonAPIServerSANs        event.Reason = "CannotRollOutAPIServerSANs"
+	reasonAPIServerSANsRolling event.Reason = "RollingOutAPIServerSANs"
+	reasonAPIServerSANsRolled  event.Reason = "RolledOutAPIServerSANs"
+)
+
+// recordAPIServerSANs records the additional subject alternative names of the
+// API server certificate of the supplied cluster as applied. kops updates the
+// API load balancer and the control plane launch templates when they change,
+// but nodeup only issues the API server certificate when an instance boots,
+// so if they replaced different names the cluster's current control plane
+// instances are marked for replacement.
+func (c *external) recordAPIServerSANs(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud) error {
+	sans := util.NormalizeSANs(cluster.Spec.AdditionalSANs)
+	prev := cr.Status.AtProvider.APIServerSANs
+	if prev != nil && reflect.DeepEqual(util.NormalizeSANs(prev.SANs), sans) {
+		return nil
+	}
+	obs := &v1alpha1.APIServerSANsObservation{SANs: sans}
+	if prev == nil {
+		cr.Status.AtProvider.APIServerSANs = obs
+		return nil
+	}
+	c.recorder.Event(cr, event.Normal(reasonAPIServerSANsChanged, fmt.Sprintf("Applied changed API server certificate subject alternative names [%s]", strings.Join(sans, ", "))))
+	ig, err := c.kopsClientset.InstanceGroupsFor(cluster).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, errRolloutAPIServerSANs)
+	}
+	// Instances still pending from an earlier rollout are among them.
+	ids, err := util.GetControlPlaneInstances(cloud, cluster, ig)
+	if err != nil {
+		return errors.Wrap(err, errRolloutAPIServerSANs)
+	}
+	obs.PendingInstances = ids
+	c.recorder.Event(cr, event.Normal(reasonAPIServerSANsRolling, fmt.Sprintf("Replacing %d control plane instances one at a time to reissue their API server certificates", len(ids))))
+	cr.Status.AtProvider.APIServerSANs = obs
+	return nil
+}
+
+// observeAPIServerSANsRollout replaces the next control plane instance whose
+// API server certificate lacks the current subject alternative names, once
+// the cluster validated since the last one was replaced. Failures are reported
+// as events and retried on the next observation.
+func (c *external) observeAPIServerSANsRollout(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, ig *kopsapi.InstanceGroupList) {
+	obs := cr.Status.AtProvider.APIServerSANs
+	if obs == nil || len(obs.PendingInstances) == 0 {
+		return
+	}
+	// Control plane instances are replaced at the pace of encryption config
+	// rollouts.
+	if obs.LastReplacedTime != nil && time.Since(obs.LastReplacedTime.Time) < encryptionConfigSettleTime {
+		return
+	}
+	if !rolloutAllowed(cr) {
+		return
+	}
+
+	_, span := tracing.Start(ctx, "ReplaceControlPlaneInstance")
+	cloud, err := c.buildCloud(cluster)
+	var replaced string
+	var remaining []string
+	if err == nil {
+		replaced, remaining, err = util.ReplaceControlPlaneInstance(cloud, cluster, ig, obs.PendingInstances)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonAPIServerSANs, errors.Wrap(err, errRolloutAPIServerSANs)))
+		return
+	}
+
+	obs.PendingInstances = remaining
+	if replaced != "" {
+		now := metav1.Now()
+		obs.LastReplacedTime = &now
+		c.recorder.Event(cr, event.Normal(reasonAPIServerSANsRolling, fmt.Sprintf("Replaced control plane instance %s to reissue its API server certificate; %d remaining", replaced, len(remaining))))
+		return
+	}
+	c.recorder.Event(cr, event.Normal(reasonAPIServerSANsRolled, "Every control plane instance serves an API server certificate with the changed subject alternative names"))
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/instancegroups"
+	resourceops "k8s.io/kops/pkg/resources/ops"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errRemoveBastion = "cannot remove the bastion of the Kops cluster"
+)
+
+const (
+	reasonBastionRemoved event.Reason = "RemovedBastion"
+)
+
+// bastionConnectionKey is the connection details key the address of the
+// bastion of a cluster is published under.
+const bastionConnectionKey = "bastion"
+
+// bastionAddress returns the address of the bastion of the cluster of a Kops
+// resource: the public name its topology sets, or else the DNS name of its
+// load balancer. It is empty if the cluster has no bastion.
+func bastionAddress(cr *v1alpha1.Kops) string {
+	t := cr.Spec.ForProvider.ClusterSpec.Topology
+	if t == nil || t.Bastion == nil {
+		return ""
+	}
+	if t.Bastion.PublicName != "" {
+		return t.Bastion.PublicName
+	}
+	if infra := cr.Status.AtProvider.Infrastructure; infra != nil {
+		return infra.BastionLoadBalancerDNSName
+	}
+	return ""
+}
+
+// removeBastion deletes the bastion instance groups of a cluster whose
+// topology no longer has a bastion, and the cloud resources that only served
+// them, so that disabling the bastion leaves the rest of the cluster as is.
+// It runs before the cluster is applied, as kops would otherwise keep
+// applying the bastion. Clusters applied through Terraform only have their
+// bastion instance groups removed from the state store.
+func (c *external) removeBastion(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud, removed []kopsapi.InstanceGroup) error {
+	terraform := cr.Spec.ForProvider.Target == v1alpha1.ApplyTargetTerraform
+	for i := range removed {
+		var err error
+		if terraform {
+			err = c.kopsClientset.InstanceGroupsFor(cluster).Delete(ctx, removed[i].Name, metav1.DeleteOptions{})
+		} else {
+			d := &instancegroups.DeleteInstanceGroup{Cluster: cluster, Cloud: cloud, Clientset: c.kopsClientset}
+			err = d.DeleteInstanceGroup(&removed[i])
+		}
+		if err != nil {
+			return errors.Wrap(err, errRemoveBastion)
+		}
+		c.recorder.Event(cr, event.Normal(reasonBastionRemoved, fmt.Sprintf("Deleted bastion instance group %s", removed[i].Name)))
+	}
+
+	// The bastion's load balancer is recorded until it is gone, so that
+	// deleting its resources is retried if it fails.
+	infra := cr.Status.AtProvider.Infrastructure
+	if terraform || (len(removed) == 0 && (infra == nil || infra.BastionLoadBalancerDNSName == "")) {
+		return nil
+	}
+
+	_, span := tracing.Start(ctx, "DeleteBastionResources")
+	res, err := listResources(cloud, cr, cluster)
+	bastion := util.GetBastionResources(res)
+	util.RetainResources(bastion, cr.Spec.ForProvider.LifecycleOverrides)
+	if awsCloud, ok := cloud.(awsup.AWSCloud); ok && err == nil {
+		err = util.RevokeBastionIngress(awsCloud.EC2(), res, bastion)
+	}
+	if err == nil && len(bastion) > 0 {
+		err = resourceops.DeleteResources(cloud, bastion)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		return errors.Wrap(err, errRemoveBastion)
+	}
+	if len(bastion) > 0 {
+		c.recorder.Event(cr, event.Normal(reasonBastionRemoved, fmt.Sprintf("Deleted %d cloud resources of the bastion", len(bastion))))
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+const (
+	errPauseReconcile  = "cannot pause reconciling Kops resource"
+	errResumeReconcile = "cannot resume reconciling Kops resource"
+)
+
+const (
+	reasonPausedReconcile  event.Reason = "PausedReconcile"
+	reasonResumedReconcile event.Reason = "ResumedReconcile"
+)
+
+// failureBackoffBase is how long a Kops resource that failed once waits before
+// it is reconciled again. The wait doubles with every further failure in a
+// row, up to the breaker's maximum backoff.
+const failureBackoffBase = 30 * time.Second
+
+// A streak is a run of identical failures of a Kops resource.
+type streak struct {
+	msg   string
+	count int
+
+	// generation is the generation of the Kops resource when reconciling it
+	// was paused.
+	generation int64
+	paused     bool
+}
+
+// A breaker wraps the reconciler of Kops resources. Resources that keep
+// failing, rather than waiting, are backed off exponentially, and stop being
+// reconciled once they failed the same way threshold times in a row, so that a
+// permanently broken cluster doesn't use reconcile workers and cloud API quota
+// forever. Deleted resources are never paused, and resume if they were, so
+// that deleting a broken cluster isn't blocked. Streaks are kept in memory, so
+// a restarted provider counts them afresh.
+type breaker struct {
+	inner    reconcile.Reconciler
+	kube     client.Client
+	reader   client.Reader
+	recorder event.Recorder
+
+	// threshold is the number of identical failures in a row after which
+	// reconciling is paused. Reconciling is never paused if it is zero.
+	threshold int
+
+	// maxBackoff bounds the failure backoff. Failures aren't backed off
+	// beyond the controller's rate limiting if it is zero.
+	maxBackoff time.Duration
+
+	mu      sync.Mutex
+	streaks map[string]*streak
+}
+
+func newBreaker(inner reconcile.Reconciler, kube client.Client, reader client.Reader, recorder event.Recorder, threshold int, maxBackoff time.Duration) *breaker {
+	return &breaker{inner: inner, kube: kube, reader: reader, recorder: recorder, threshold: threshold, maxBackoff: maxBackoff, streaks: map[string]*streak{}}
+}
+
+// Reconcile the supplied request, unless reconciling it is paused and it
+// wasn't deleted.
+func (b *breaker) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	cr := &v1alpha1.Kops{}
+	if err := b.kube.Get(ctx, req.NamespacedName, cr); err != nil {
+		if kerrors.IsNotFound(err) {
+			b.forget(req.Name)
+		}
+		return b.inner.Reconcile(ctx, req)
+	}
+
+	if cr.Status.GetCondition(v1alpha1.TypeReconcilePaused).Status == corev1.ConditionTrue {
+		if !meta.WasDeleted(cr) && !b.resumable(cr) {
+			return reconcile.Result{}, nil
+		}
+		if err := b.resume(ctx, cr); err != nil {
+			return reconcile.Result{}, errors.Wrap(err, errResumeReconcile)
+		}
+	}
+
+	res, err := b.inner.Reconcile(ctx, req)
+
+	// The cache may not yet reflect the status the inner reconciler wrote.
+	if gerr := b.reader.Get(ctx, req.NamespacedName, cr); gerr != nil {
+		return res, err
+	}
+	synced := cr.Status.GetCondition(xpv1.TypeSynced)
+	if synced.Reason != xpv1.ReasonReconcileError {
+		b.forget(req.Name)
+		return res, err
+	}
+	// Operations waiting to start, e.g. for a free apply worker or for a
+	// certificate to be issued, are expected to start on their own, so they
+	// neither count towards nor break a streak.
+	if cr.Status.GetCondition(v1alpha1.TypeFailing).Status == corev1.ConditionFalse {
+		return res, err
+	}
+
+	n := b.fail(req.Name, synced.Message)
+	if b.threshold > 0 && n >= b.threshold && !meta.WasDeleted(cr) {
+		return reconcile.Result{}, errors.Wrap(b.pause(ctx, cr, n, synced.Message), errPauseReconcile)
+	}
+	if b.maxBackoff > 0 && res.Requeue {
+		return reconcile.Result{RequeueAfter: failureBackoff(n, b.maxBackoff)}, err
+	}
+	return res, err
+}
+
+// resumable returns true if a paused Kops resource was asked to resume, or its
+// spec changed since it was paused.
+func (b *breaker) resumable(cr *v1alpha1.Kops) bool {
+	if _, ok := cr.GetAnnotations()[v1alpha1.AnnotationKeyResume]; ok {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.streaks[cr.GetName()]
+	if !ok || !s.paused {
+		// The resource was paused before the provider restarted.
+		b.streaks[cr.GetName()] = &streak{generation: cr.GetGeneration(), paused: true}
+		return false
+	}
+	return s.generation != cr.GetGeneration()
+}
+
+func (b *breaker) resume(ctx context.Context, cr *v1alpha1.Kops) error {
+	if _, ok := cr.GetAnnotations()[v1alpha1.AnnotationKeyResume]; ok {
+		meta.RemoveAnnotations(cr, v1alpha1.AnnotationKeyResume)
+		if err := b.kube.Update(ctx, cr); err != nil {
+			return err
+		}
+	}
+	cr.Status.SetConditions(v1alpha1.CircuitClosed())
+	if err := b.kube.Status().Update(ctx, cr); err != nil {
+		return err
+	}
+	b.forget(cr.GetName())
+	b.recorder.Event(cr, event.Normal(reasonResumedReconcile, "Resumed reconciling"))
+	return nil
+}
+
+func (b *breaker) pause(ctx context.Context, cr *v1alpha1.Kops, n int, msg string) error {
+	msg = fmt.Sprintf("paused reconciling after failing %d times in a row with: %s; change the spec or set the %s annotation to resume", n, msg, v1alpha1.AnnotationKeyResume)
+	cr.Status.SetConditions(v1alpha1.CircuitOpen(msg))
+	if err := b.kube.Status().Update(ctx, cr); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.streaks[cr.GetName()] = &streak{generation: cr.GetGeneration(), paused: true}
+	b.mu.Unlock()
+	b.recorder.Event(cr, event.Warning(reasonPausedReconcile, errors.New(msg)))
+	return nil
+}
+
+// fail records a failure of the named Kops resource with the supplied message,
+// and returns how many times in a row it failed with it.
+func (b *breaker) fail(name, msg string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.streaks[name]
+	if !ok || s.msg != msg {
+		s = &streak{msg: msg}
+		b.streaks[name] = s
+	}
+	s.count++
+	return s.count
+}
+
+func (b *breaker) forget(name string) {
+	b.mu.Lock()
+	delete(b.streaks, name)
+	b.mu.Unlock()
+}
+
+// failureBackoff returns how long to wait before reconciling a Kops resource
+// that failed n times in a row.
+func failureBackoff(n int, limit time.Duration) time.Duration {
+	d := failureBackoffBase
+	for i := 1; i < n && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		return limit
+	}
+	return d
+}
@@ -0,0 +1,375 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	kopsClient "k8s.io/kops/pkg/client/simple"
+	"k8s.io/kops/upup/pkg/fi"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	// clientsetTTL is how long a kops clientset is reused for.
+	clientsetTTL = 30 * time.Minute
+
+	// cloudTTL is how long a cloud is reused for. Clouds hold cloud API
+	// sessions, so they are rebuilt more often than clientsets in order to
+	// pick up rotated credentials.
+	cloudTTL = 10 * time.Minute
+
+	// kubeClientRenewBefore is how long before its client certificate expires
+	// a cached kube client is replaced.
+	kubeClientRenewBefore = util.KubeconfigCertificateTTL / 2
+)
+
+// A ttlCache shares values across reconciles, building each value the first
+// time it is needed and again once it has expired. Values that fail to build
+// are not cached.
+type ttlCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	pending map[string]*pendingBuild
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// A pendingBuild is a value that is being built. It is done once its done
+// channel is closed.
+type pendingBuild struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func newTTLCache(ttl time.Duration) *ttlCache {
+	return &ttlCache{ttl: ttl, now: time.Now, entries: map[string]cacheEntry{}, pending: map[string]*pendingBuild{}}
+}
+
+// get returns the value cached under the supplied key, building it if there
+// is none. Values are built without the cache locked, as building one may
+// call cloud APIs, so that values of different keys are built concurrently.
+// Concurrent gets of the same key wait for the same build rather than
+// building it twice.
+func (c *ttlCache) get(key string, build func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	now := c.now()
+	if e, ok := c.entries[key]; ok && now.Before(e.expires) {
+		c.mu.Unlock()
+		return e.value, nil
+	}
+	if p, ok := c.pending[key]; ok {
+		c.mu.Unlock()
+		<-p.done
+		return p.value, p.err
+	}
+	// Drop expired entries so that deleted clusters don't linger.
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	p := &pendingBuild{done: make(chan struct{})}
+	c.pending[key] = p
+	c.mu.Unlock()
+
+	p.value, p.err = build()
+
+	c.mu.Lock()
+	// A build whose key was forgotten while it ran is not cached.
+	if c.pending[key] == p {
+		delete(c.pending, key)
+		if p.err == nil {
+			c.entries[key] = cacheEntry{value: p.value, expires: now.Add(c.ttl)}
+		}
+	}
+	c.mu.Unlock()
+	close(p.done)
+	return p.value, p.err
+}
+
+// forget drops the values cached under keys with the supplied prefix.
+func (c *ttlCache) forget(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+		}
+	}
+	for k := range c.pending {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.pending, k)
+		}
+	}
+}
+
+// clientset returns a kops clientset for the supplied state bucket. A
+// clientset only depends on its state bucket, so clusters in the same bucket
+// share one.
+func (c *connector) clientset(stateBucket, clusterName, domain string) (kopsClient.Clientset, error) {
+	if c.clientsets == nil {
+		return c.factory.Clientset(stateBucket, clusterName, domain)
+	}
+	v, err := c.clientsets.get(stateBucket, func() (interface{}, error) {
+		return c.factory.Clientset(stateBucket, clusterName, domain)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(kopsClient.Clientset), nil
+}
+
+// buildCloud returns the cloud of the supplied cluster, reusing the one built
+// for it within the cloud TTL unless the parts of its spec the cloud is built
+// from changed. AWS clouds are rate limited when they are built.
+func (c *external) buildCloud(cluster *kopsapi.Cluster) (fi.Cloud, error) {
+	build := func() (interface{}, error) {
+		cloud, err := c.builder.BuildCloud(cluster)
+		if err != nil {
+			return nil, err
+		}
+		c.limiters.limit(cloud)
+		return cloud, nil
+	}
+	if c.clouds == nil {
+		v, err := build()
+		if err != nil {
+			return nil, err
+		}
+		return v.(fi.Cloud), nil
+	}
+	v, err := c.clouds.get(cloudKey(cluster), build)
+	if err != nil {
+		return nil, err
+	}
+	return v.(fi.Cloud), nil
+}
+
+// cloudKey identifies the cloud of a cluster by the parts of its spec that
+// cloudup.BuildCloud reads. Keys start with the cluster's name and a '|'.
+func cloudKey(cluster *kopsapi.Cluster) string {
+	zones := make([]string, 0, len(cluster.Spec.Subnets))
+	for _, s := range cluster.Spec.Subnets {
+		zones = append(zones, s.Zone+"/"+s.Region)
+	}
+	sort.Strings(zones)
+
+	labels := make([]string, 0, len(cluster.Spec.CloudLabels))
+	for k, v := range cluster.Spec.CloudLabels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+
+	return fmt.Sprintf("%s|%s|%s|%s|%s", cluster.GetName(), cluster.Spec.CloudProvider, cluster.Spec.Project, strings.Join(zones, ","), strings.Join(labels, ","))
+}
+
+// kubeClients shares the clients used to validate clusters across reconciles,
+// so that a client certificate isn't issued, and a TLS connection set up, for
+// every cluster on every poll. A client is reused until its certificate nears
+// expiry, or until the CA or API endpoint of its cluster changes.
+type kubeClients struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]kubeClient
+	pending map[string]*pendingKubeClient
+}
+
+type kubeClient struct {
+	client  kubernetes.Interface
+	server  string
+	ca      string
+	expires time.Time
+}
+
+// A pendingKubeClient is a client that is being built for a server and CA. It
+// is done once its done channel is closed.
+type pendingKubeClient struct {
+	done   chan struct{}
+	server string
+	ca     string
+	client kubernetes.Interface
+	err    error
+}
+
+func newKubeClients() *kubeClients {
+	return &kubeClients{now: time.Now, entries: map[string]kubeClient{}, pending: map[string]*pendingKubeClient{}}
+}
+
+// get returns the client cached for the supplied cluster if it still talks to
+// the supplied server and trusts the supplied CA, otherwise it builds a client
+// from the config returned by build. Like ttlCache, clients are built without
+// the cache locked, as building one may issue a client certificate, and
+// concurrent gets of the same cluster wait for the same build.
+func (k *kubeClients) get(cluster, server, ca string, build func() (*rest.Config, error)) (kubernetes.Interface, error) {
+	k.mu.Lock()
+	now := k.now()
+	for {
+		if e, ok := k.entries[cluster]; ok && e.server == server && e.ca == ca && now.Add(kubeClientRenewBefore).Before(e.expires) {
+			k.mu.Unlock()
+			return e.client, nil
+		}
+		p, ok := k.pending[cluster]
+		if !ok {
+			break
+		}
+		k.mu.Unlock()
+		<-p.done
+		if p.server == server && p.ca == ca {
+			return p.client, p.err
+		}
+		// The client that was being built talks to another server or trusts
+		// another CA, so look again.
+		k.mu.Lock()
+		now = k.now()
+	}
+	// Drop clients that would be replaced anyway, so that clusters that are
+	// no longer validated don't keep theirs.
+	for c, e := range k.entries {
+		if !now.Add(kubeClientRenewBefore).Before(e.expires) {
+			delete(k.entries, c)
+		}
+	}
+	delete(k.entries, cluster)
+	p := &pendingKubeClient{done: make(chan struct{}), server: server, ca: ca}
+	k.pending[cluster] = p
+	k.mu.Unlock()
+
+	var expires time.Time
+	p.client, expires, p.err = newKubeClient(now, build)
+
+	k.mu.Lock()
+	// A client whose cluster was forgotten while it was built is not cached.
+	if k.pending[cluster] == p {
+		delete(k.pending, cluster)
+		if p.err == nil {
+			k.entries[cluster] = kubeClient{client: p.client, server: server, ca: ca, expires: expires}
+		}
+	}
+	k.mu.Unlock()
+	close(p.done)
+	return p.client, p.err
+}
+
+// newKubeClient builds a client from the config returned by build, and returns
+// when it should be replaced.
+func newKubeClient(now time.Time, build func() (*rest.Config, error)) (kubernetes.Interface, time.Time, error) {
+	config, err := build()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	// Clients that don't authenticate with a client certificate are
+	// replaced as often as those that do, in case their credentials were
+	// rotated in place.
+	expires := now.Add(util.KubeconfigCertificateTTL)
+	if len(config.CertData) > 0 {
+		expires, err = util.GetCertificateExpiry(config.CertData)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return client, expires, nil
+}
+
+// validationClient returns a client of the API server of the supplied cluster
+// for validating it, through the proxy of its reachability mode if any. ca is
+// the fingerprint of the cluster's current CA keyset. The client
+// authenticates with the credentials the Kops resource refers to if any,
+// otherwise with a client certificate issued from the cluster's keystore.
+func (c *external) validationClient(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, ca string) (kubernetes.Interface, error) {
+	proxy := proxyURL(cr)
+	creds, err := c.getValidationCredentials(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+	build := func() (*rest.Config, error) {
+		var config *rest.Config
+		var err error
+		switch {
+		case creds != nil && creds.kubeconfig != nil:
+			config, err = clientcmd.RESTConfigFromKubeConfig(creds.kubeconfig)
+		case creds != nil:
+			config, err = util.GetKubeconfigForToken(cluster, c.kopsClientset, string(creds.token))
+		default:
+			commonName := util.KubeconfigCommonName(cluster.GetName(), cr.GetUID())
+			config, err = util.GetKubeconfigFromKopsState(cluster, c.kopsClientset, commonName, util.CertificateReasonValidation)
+			if err == nil {
+				c.recordCertificateIssued(cr, commonName, util.CertificateReasonValidation, util.KubeconfigCertificateTTL)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Requests the kops validator makes can't be cancelled, so they
+		// time out on their own.
+		config.Timeout = c.validationTimeout
+		if proxy != "" {
+			if err := util.SetProxy(config, proxy); err != nil {
+				return nil, err
+			}
+		}
+		return config, nil
+	}
+	if c.kubeClients == nil {
+		config, err := build()
+		if err != nil {
+			return nil, err
+		}
+		return kubernetes.NewForConfig(config)
+	}
+	// Clients are rebuilt when the proxy or credentials change, like when
+	// the server does.
+	server := util.GetAPIEndpoint(cluster)
+	if proxy != "" {
+		server += " via " + proxy
+	}
+	if creds != nil {
+		server += " as " + creds.hash()
+	}
+	return c.kubeClients.get(cluster.ObjectMeta.Name, server, ca, build)
+}
+
+// forget drops the client cached for the supplied cluster.
+func (k *kubeClients) forget(cluster string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.entries, cluster)
+	delete(k.pending, cluster)
+}
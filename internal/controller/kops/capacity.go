@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/cloudinstances"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errGetCapacityFailures = "cannot get Kops cluster instance groups lacking capacity"
+)
+
+const (
+	reasonCapacity             event.Reason = "CannotObserveCapacity"
+	reasonInsufficientCapacity event.Reason = "InsufficientCapacity"
+	reasonOnDemandFallback     event.Reason = "FellBackToOnDemand"
+)
+
+// onDemandFallbackRetry is how long a spot instance group that fell back to
+// on-demand instances runs them before spot instances are tried again.
+const onDemandFallbackRetry = time.Hour
+
+// observeCapacity reports instance groups whose last scaling activity failed
+// for lack of capacity. If the Kops resource opts in, spot instance groups
+// among them fall back to on-demand instances, which the next observation
+// finds out of date and applies. Failures are reported as events.
+func (c *external) observeCapacity(cr *v1alpha1.Kops, cloud fi.Cloud, ig *kopsapi.InstanceGroupList, groups map[string]*cloudinstances.CloudInstanceGroup) {
+	awsCloud, ok := cloud.(awsup.AWSCloud)
+	if !ok {
+		return
+	}
+	failures, err := util.GetCapacityFailures(awsCloud.Autoscaling(), groups)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonCapacity, errors.Wrap(err, errGetCapacityFailures)))
+		return
+	}
+
+	// Fallbacks expire so that spot capacity is tried again.
+	var fallbacks []v1alpha1.OnDemandFallbackObservation
+	fellBack := map[string]bool{}
+	for _, f := range cr.Status.AtProvider.OnDemandFallbacks {
+		if cr.Spec.ForProvider.OnDemandFallback && time.Since(f.Since.Time) < onDemandFallbackRetry {
+			fallbacks = append(fallbacks, f)
+			fellBack[f.InstanceGroup] = true
+		}
+	}
+
+	names := make([]string, 0, len(failures))
+	for name := range failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", name, failures[name]))
+		if !cr.Spec.ForProvider.OnDemandFallback || fellBack[name] || !spotInstanceGroup(ig, name) {
+			continue
+		}
+		fallbacks = append(fallbacks, v1alpha1.OnDemandFallbackObservation{InstanceGroup: name, Since: metav1.Now(), Message: failures[name]})
+		c.recorder.Event(cr, event.Normal(reasonOnDemandFallback, fmt.Sprintf("Falling back to on-demand instances for instance group %s for lack of spot capacity", name)))
+	}
+	cr.Status.AtProvider.OnDemandFallbacks = fallbacks
+
+	if len(msgs) == 0 {
+		cr.Status.SetConditions(v1alpha1.CapacityAvailable())
+		return
+	}
+	msg := "instance groups can't launch instances for lack of capacity: " + strings.Join(msgs, "; ")
+	if cr.Status.GetCondition(v1alpha1.TypeInsufficientCapacity).Status != corev1.ConditionTrue {
+		c.recorder.Event(cr, event.Warning(reasonInsufficientCapacity, errors.New(msg)))
+	}
+	cr.Status.SetConditions(v1alpha1.InsufficientCapacity(msg))
+}
+
+// spotInstanceGroup returns true if the named instance group of the supplied
+// list launches spot instances.
+func spotInstanceGroup(ig *kopsapi.InstanceGroupList, name string) bool {
+	for i := range ig.Items {
+		if ig.Items[i].Name == name {
+			return util.IsSpotInstanceGroup(&ig.Items[i].Spec)
+		}
+	}
+	return false
+}
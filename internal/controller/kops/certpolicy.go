@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-kops/apis/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errCertificateTTLExceeded = "kubernetesApiCertificateTTL %s exceeds the maximum of %s allowed by the certificate policy"
+)
+
+// certificateTTL returns the validity of the client certificate published in
+// the connection details of the supplied Kops resource. The provider's cap
+// and the certificate policy of its ProviderConfig, if any, bound it.
+func (c *connector) certificateTTL(ctx context.Context, cr *v1alpha1.Kops) (time.Duration, error) {
+	limit, enforcement := c.maxCertTTL, apisv1alpha1.CertificatePolicyReject
+	if ref := cr.GetProviderConfigReference(); ref != nil {
+		pc := &apisv1alpha1.ProviderConfig{}
+		if err := c.kube.Get(ctx, types.NamespacedName{Name: ref.Name}, pc); resource.IgnoreNotFound(err) != nil {
+			return 0, errors.Wrap(err, errGetPC)
+		}
+		if p := pc.Spec.CertificatePolicy; p != nil {
+			if p.MaxKubernetesAPICertificateTTL != nil && (limit == 0 || p.MaxKubernetesAPICertificateTTL.Duration < limit) {
+				limit = p.MaxKubernetesAPICertificateTTL.Duration
+			}
+			if p.Enforcement != "" {
+				enforcement = p.Enforcement
+			}
+		}
+	}
+	// A resource being deleted is never rejected, so that it can be deleted.
+	if meta.WasDeleted(cr) {
+		enforcement = apisv1alpha1.CertificatePolicyClamp
+	}
+	return boundCertificateTTL(cr, limit, enforcement)
+}
+
+// boundCertificateTTL returns the certificate validity the supplied Kops
+// resource requests, bounded by the supplied limit. A limit of zero is
+// unbounded. Resources that don't request a validity are never rejected.
+func boundCertificateTTL(cr *v1alpha1.Kops, limit time.Duration, enforcement apisv1alpha1.CertificatePolicyEnforcement) (time.Duration, error) {
+	ttl := util.KubeconfigCertificateTTL
+	t := cr.Spec.ForProvider.KubernetesAPICertificateTTL
+	if t != nil {
+		ttl = t.Duration
+	}
+	if limit == 0 || ttl <= limit {
+		return ttl, nil
+	}
+	if t == nil || enforcement == apisv1alpha1.CertificatePolicyClamp {
+		return limit, nil
+	}
+	return 0, errors.Errorf(errCertificateTTLExceeded, ttl, limit)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errHashNetworking = "cannot hash Kops cluster networking config"
+	errRolloutCNI     = "cannot roll out Kops cluster networking config"
+)
+
+const (
+	reasonCNIChanged event.Reason = "ChangedNetworkingConfig"
+	reasonCNI        event.Reason = "CannotRollOutNetworkingConfig"
+	reasonCNIRolling event.Reason = "RollingOutNetworkingConfig"
+	reasonCNIRolled  event.Reason = "RolledOutNetworkingConfig"
+)
+
+// recordCNI records the networking config of the supplied cluster as
+// applied. kops updates the CNI agent DaemonSet when the config changes, but
+// some changes, e.g. of the Cilium or Calico datapath, only take effect on
+// nodes that boot with them, so if it replaced a different config and the
+// Kops resource asks for it, the cluster's current node instances are marked
+// for replacement.
+func (c *external) recordCNI(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud) error {
+	hash, err := util.HashNetworking(cluster.Spec.Networking)
+	if err != nil {
+		return errors.Wrap(err, errHashNetworking)
+	}
+	prev := cr.Status.AtProvider.CNI
+	if prev != nil && prev.Hash == hash {
+		return nil
+	}
+	obs := &v1alpha1.CNIObservation{Provider: util.GetNetworkingProvider(cluster.Spec.Networking), Hash: hash}
+	if prev == nil {
+		cr.Status.AtProvider.CNI = obs
+		return nil
+	}
+	c.recorder.Event(cr, event.Normal(reasonCNIChanged, fmt.Sprintf("Applied changed %s networking config", obs.Provider)))
+	if cr.Spec.ForProvider.RollNodesOnCNIChange {
+		ig, err := c.kopsClientset.InstanceGroupsFor(cluster).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return errors.Wrap(err, errRolloutCNI)
+		}
+		// Instances still pending from an earlier rollout are among them.
+		ids, err := util.GetNodeInstances(cloud, cluster, ig)
+		if err != nil {
+			return errors.Wrap(err, errRolloutCNI)
+		}
+		obs.PendingInstances = ids
+		c.recorder.Event(cr, event.Normal(reasonCNIRolling, fmt.Sprintf("Replacing %d node instances one at a time to restart their CNI agents", len(ids))))
+	}
+	cr.Status.AtProvider.CNI = obs
+	return nil
+}
+
+// observeCNIRollout replaces the next node instance that still runs with a
+// previous networking config, once the cluster, and so its CNI agents,
+// validated since the last one was replaced. Failures are reported as events
+// and retried on the next observation.
+func (c *external) observeCNIRollout(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, ig *kopsapi.InstanceGroupList) {
+	obs := cr.Status.AtProvider.CNI
+	if obs == nil || len(obs.PendingInstances) == 0 {
+		return
+	}
+	// Nodes are replaced at the pace of docker config rollouts.
+	if obs.LastReplacedTime != nil && time.Since(obs.LastReplacedTime.Time) < dockerConfigSettleTime {
+		return
+	}
+	if !rolloutAllowed(cr) {
+		return
+	}
+
+	_, span := tracing.Start(ctx, "ReplaceNodeInstance")
+	cloud, err := c.buildCloud(cluster)
+	var replaced string
+	var remaining []string
+	if err == nil {
+		replaced, remaining, err = util.ReplaceNodeInstance(cloud, cluster, ig, obs.PendingInstances)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonCNI, errors.Wrap(err, errRolloutCNI)))
+		return
+	}
+
+	obs.PendingInstances = remaining
+	if replaced != "" {
+		now := metav1.Now()
+		obs.LastReplacedTime = &now
+		c.recorder.Event(cr, event.Normal(reasonCNIRolling, fmt.Sprintf("Replaced node instance %s to restart its CNI agent; %d remaining", replaced, len(remaining))))
+		return
+	}
+	c.recorder.Event(cr, event.Normal(reasonCNIRolled, "Every node instance runs with the changed networking config"))
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/cloudinstances"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/metrics"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errNewPricingClient = "cannot create AWS pricing client"
+	errEstimateCost     = "cannot estimate the cost of the Kops cluster"
+)
+
+const (
+	reasonEstimateCost event.Reason = "CannotEstimateCost"
+)
+
+// costEstimateInterval is how often the cost of a cluster is estimated.
+// Prices rarely change, so this mostly bounds how quickly scaling shows.
+const costEstimateInterval = time.Hour
+
+// observeCost estimates the hourly cost of the running instances and volumes
+// of a cluster from AWS on-demand prices, and reports it in the status and
+// as a metric. Other clouds aren't supported.
+func (c *external) observeCost(cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud, groups map[string]*cloudinstances.CloudInstanceGroup) {
+	if !cr.Spec.ForProvider.EstimateCost {
+		cr.Status.AtProvider.Cost = nil
+		return
+	}
+	if cost := cr.Status.AtProvider.Cost; cost != nil && time.Since(cost.Time.Time) < costEstimateInterval {
+		return
+	}
+	awsCloud, ok := cloud.(awsup.AWSCloud)
+	if !ok {
+		return
+	}
+
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(util.AWSPricingRegion)})
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonEstimateCost, errors.Wrap(err, errNewPricingClient)))
+		return
+	}
+	api, region := pricing.New(sess), awsCloud.Region()
+	cost, err := util.EstimateClusterCost(cluster, groups,
+		func(t string) (float64, error) { return util.GetAWSInstancePrice(api, region, t) },
+		func(t string) (float64, error) { return util.GetAWSVolumePrice(api, region, t) })
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonEstimateCost, errors.Wrap(err, errEstimateCost)))
+		return
+	}
+	cost.Time = metav1.Now()
+	cr.Status.AtProvider.Cost = cost
+
+	hourly, _ := strconv.ParseFloat(cost.HourlyUSD, 64)
+	metrics.RecordCost(cluster.ObjectMeta.Name, hourly)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errPreviewDelete = "cannot list cloud resources to preview cluster deletion"
+)
+
+const (
+	reasonPreviewDelete   event.Reason = "CannotPreviewDelete"
+	reasonDeletePreviewed event.Reason = "PreviewedDelete"
+)
+
+// previewDelete lists the cloud resources that deleting the supplied cluster
+// would delete into the status of the supplied Kops resource, so that the
+// blast radius of a teardown can be reviewed before it happens. A failed
+// preview is retried on the next observation.
+func (c *external) previewDelete(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, req string) {
+	_, span := tracing.Start(ctx, "PreviewDelete")
+	preview, err := c.listDeletePreview(cr, cluster)
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonPreviewDelete, errors.Wrap(err, errPreviewDelete)))
+		return
+	}
+	cr.Status.AtProvider.DeletePreview = &v1alpha1.DeletePreviewObservation{Request: req, Time: metav1.Now(), Resources: preview}
+
+	if cr.Spec.ForProvider.Target == v1alpha1.ApplyTargetTerraform {
+		c.recorder.Event(cr, event.Normal(reasonDeletePreviewed, fmt.Sprintf("Deleting the cluster would leave its %d cloud resources to Terraform", len(preview))))
+		return
+	}
+	deleted := 0
+	for _, r := range preview {
+		if !r.Retained {
+			deleted++
+		}
+	}
+	c.recorder.Event(cr, event.Normal(reasonDeletePreviewed, fmt.Sprintf("Deleting the cluster would delete %d of its %d cloud resources", deleted, len(preview))))
+}
+
+func (c *external) listDeletePreview(cr *v1alpha1.Kops, cluster *kopsapi.Cluster) ([]v1alpha1.DeletePreviewResource, error) {
+	cloud, err := c.buildCloud(cluster)
+	if err != nil {
+		return nil, err
+	}
+	res, err := listResources(cloud, cr, cluster)
+	if err != nil {
+		return nil, err
+	}
+	util.RetainResources(res, cr.Spec.ForProvider.LifecycleOverrides)
+	return util.GetDeletePreview(res), nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+const (
+	errGetDependency          = "cannot get dependency %s"
+	errDependencyBadStatus    = "cannot read status of dependency %s"
+	errWaitingForDependencies = "waiting for dependencies to become ready: %s"
+)
+
+// checkDependencies returns an error waiting for the managed resources the
+// supplied Kops resource depends on, if any of them is missing or not ready
+// yet. A missing dependency is taken to still be on its way, e.g. from a
+// composition that is being rendered.
+func (c *external) checkDependencies(ctx context.Context, cr *v1alpha1.Kops) error {
+	var waiting []string
+	for _, ref := range cr.Spec.ForProvider.DependsOn {
+		name := fmt.Sprintf("%s/%s", ref.Kind, ref.Name)
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(ref.APIVersion)
+		u.SetKind(ref.Kind)
+		if err := c.kube.Get(ctx, types.NamespacedName{Name: ref.Name}, u); err != nil {
+			if kerrors.IsNotFound(err) {
+				waiting = append(waiting, name)
+				continue
+			}
+			return errors.Wrapf(err, errGetDependency, name)
+		}
+		status := xpv1.ConditionedStatus{}
+		if err := fieldpath.Pave(u.Object).GetValueInto("status", &status); err != nil && !fieldpath.IsNotFound(err) {
+			return errors.Wrapf(err, errDependencyBadStatus, name)
+		}
+		if status.GetCondition(xpv1.TypeReady).Status != corev1.ConditionTrue {
+			waiting = append(waiting, name)
+		}
+	}
+	if len(waiting) == 0 {
+		return nil
+	}
+	return waitError{error: errors.Errorf(errWaitingForDependencies, strings.Join(waiting, ", ")), reason: v1alpha1.ReasonWaitingForDependencies}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errGetDockerConfig      = "cannot get Kops cluster docker config"
+	errWriteDockerConfig    = "cannot write Kops cluster docker config"
+	errRolloutDockerConfig  = "cannot roll out Kops cluster docker config"
+	errDockerConfigNotFound = "docker config secret has no key %q"
+)
+
+const (
+	reasonDockerConfig        event.Reason = "CannotRollOutDockerConfig"
+	reasonDockerConfigRolling event.Reason = "RollingOutDockerConfig"
+	reasonDockerConfigRolled  event.Reason = "RolledOutDockerConfig"
+)
+
+// dockerConfigSettleTime is how long after a node instance was replaced the
+// next one may be, so that an instance that is still shutting down isn't
+// mistaken for a replaced one by validation.
+const dockerConfigSettleTime = 5 * time.Minute
+
+// getDockerConfig returns the Docker config referenced by the supplied Kops
+// resource, or nil if it references none.
+func (c *external) getDockerConfig(ctx context.Context, cr *v1alpha1.Kops) ([]byte, error) {
+	ref := cr.Spec.ForProvider.DockerConfigSecretRef
+	if ref == nil {
+		return nil, nil
+	}
+	s := &corev1.Secret{}
+	if err := c.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s); err != nil {
+		return nil, errors.Wrap(err, errGetDockerConfig)
+	}
+	config, ok := s.Data[ref.Key]
+	if !ok {
+		return nil, errors.Wrap(errors.Errorf(errDockerConfigNotFound, ref.Key), errGetDockerConfig)
+	}
+	return config, nil
+}
+
+// dockerConfigUpToDate returns false if the Docker config referenced by the
+// supplied Kops resource differs from the one last written to the cluster's
+// secret store.
+func (c *external) dockerConfigUpToDate(ctx context.Context, cr *v1alpha1.Kops) (bool, error) {
+	config, err := c.getDockerConfig(ctx, cr)
+	if err != nil || config == nil {
+		return true, err
+	}
+	obs := cr.Status.AtProvider.DockerConfig
+	return obs != nil && obs.Hash == util.HashDockerConfig(config), nil
+}
+
+// writeDockerConfig writes the Docker config referenced by the supplied Kops
+// resource, if any, to the secret store of the supplied cluster and returns
+// its hash.
+func (c *external) writeDockerConfig(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) (string, error) {
+	config, err := c.getDockerConfig(ctx, cr)
+	if err != nil || config == nil {
+		return "", err
+	}
+	hash, err := util.WriteDockerConfig(c.kopsClientset, cluster, config)
+	return hash, errors.Wrap(err, errWriteDockerConfig)
+}
+
+// recordDockerConfig records the Docker config with the supplied hash as
+// applied to the supplied cluster. Nodes only read it when they boot, so if
+// it replaced a different config and the Kops resource asks for it, the
+// cluster's current node instances are marked for replacement.
+func (c *external) recordDockerConfig(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud, hash string) error {
+	prev := cr.Status.AtProvider.DockerConfig
+	if hash == "" || (prev != nil && prev.Hash == hash) {
+		return nil
+	}
+	obs := &v1alpha1.DockerConfigObservation{Hash: hash}
+	if prev != nil && cr.Spec.ForProvider.RollNodesOnDockerConfigChange {
+		ig, err := c.kopsClientset.InstanceGroupsFor(cluster).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return errors.Wrap(err, errRolloutDockerConfig)
+		}
+		// Instances still pending from an earlier rollout are among them.
+		ids, err := util.GetNodeInstances(cloud, cluster, ig)
+		if err != nil {
+			return errors.Wrap(err, errRolloutDockerConfig)
+		}
+		obs.PendingInstances = ids
+		c.recorder.Event(cr, event.Normal(reasonDockerConfigRolling, fmt.Sprintf("Replacing %d node instances one at a time to load the changed docker config", len(ids))))
+	}
+	cr.Status.AtProvider.DockerConfig = obs
+	return nil
+}
+
+// observeDockerConfigRollout replaces the next node instance that still runs
+// with a previous Docker config, once the cluster validated since the last
+// one was replaced. Failures are reported as events and retried on the next
+// observation.
+func (c *external) observeDockerConfigRollout(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, ig *kopsapi.InstanceGroupList) {
+	obs := cr.Status.AtProvider.DockerConfig
+	if obs == nil || len(obs.PendingInstances) == 0 {
+		return
+	}
+	if obs.LastReplacedTime != nil && time.Since(obs.LastReplacedTime.Time) < dockerConfigSettleTime {
+		return
+	}
+	if !rolloutAllowed(cr) {
+		return
+	}
+
+	_, span := tracing.Start(ctx, "ReplaceNodeInstance")
+	cloud, err := c.buildCloud(cluster)
+	var replaced string
+	var remaining []string
+	if err == nil {
+		replaced, remaining, err = util.ReplaceNodeInstance(cloud, cluster, ig, obs.PendingInstances)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonDockerConfig, errors.Wrap(err, errRolloutDockerConfig)))
+		return
+	}
+
+	obs.PendingInstances = remaining
+	if replaced != "" {
+		now := metav1.Now()
+		obs.LastReplacedTime = &now
+		c.recorder.Event(cr, event.Normal(reasonDockerConfigRolling, fmt.Sprintf("Replaced node instance %s to load the changed docker config; %d remaining", replaced, len(remaining))))
+		return
+	}
+	c.recorder.Event(cr, event.Normal(reasonDockerConfigRolled, "Every node instance runs with the changed docker config"))
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errRecordWrittenState = "cannot record Kops cluster state written to the state store"
+	errHashState          = "cannot hash Kops cluster state"
+)
+
+const (
+	reasonExternallyModified event.Reason = "ExternallyModified"
+)
+
+// recordWrittenState records the state of the supplied cluster as read back
+// from the state store after the provider wrote it, so that changes made to
+// it outside of the provider can be told apart.
+func (c *external) recordWrittenState(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) error {
+	stored, err := c.kopsClientset.GetCluster(ctx, cluster.GetName())
+	if err != nil {
+		return errors.Wrap(err, errRecordWrittenState)
+	}
+	ig, err := c.kopsClientset.InstanceGroupsFor(stored).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, errRecordWrittenState)
+	}
+	hash, err := util.HashClusterState(stored, ig)
+	if err != nil {
+		return errors.Wrap(err, errRecordWrittenState)
+	}
+	specHash, err := util.HashParameters(cr.Spec.ForProvider)
+	if err != nil {
+		return errors.Wrap(err, errHashParameters)
+	}
+	cr.Status.AtProvider.WrittenState = &v1alpha1.WrittenStateObservation{Hash: hash, SpecHash: specHash}
+	cr.Status.SetConditions(v1alpha1.StateAsApplied())
+	return nil
+}
+
+// observeExternalModification raises the ExternallyModified condition if the
+// state of the supplied cluster changed since the provider last wrote it,
+// without the Kops resource having changed since, e.g. because someone ran
+// kops edit cluster. State the provider didn't write for the current
+// parameters is taken as written once the cluster is up to date with them.
+func (c *external) observeExternalModification(cr *v1alpha1.Kops, cluster *kopsapi.Cluster, ig *kopsapi.InstanceGroupList, upToDate bool) error {
+	hash, err := util.HashClusterState(cluster, ig)
+	if err != nil {
+		return errors.Wrap(err, errHashState)
+	}
+	specHash, err := util.HashParameters(cr.Spec.ForProvider)
+	if err != nil {
+		return errors.Wrap(err, errHashParameters)
+	}
+
+	written := cr.Status.AtProvider.WrittenState
+	switch {
+	case written == nil || written.SpecHash != specHash:
+		// The next update writes the state for the current parameters.
+		if !upToDate {
+			return nil
+		}
+		cr.Status.AtProvider.WrittenState = &v1alpha1.WrittenStateObservation{Hash: hash, SpecHash: specHash}
+		cr.Status.SetConditions(v1alpha1.StateAsApplied())
+	case written.Hash == hash:
+		cr.Status.SetConditions(v1alpha1.StateAsApplied())
+	default:
+		msg := fmt.Sprintf("the state of cluster %s was changed outside of the provider since it last wrote it, e.g. with kops edit cluster", cluster.GetName())
+		if cr.Status.GetCondition(v1alpha1.TypeExternallyModified).Status != corev1.ConditionTrue {
+			c.recorder.Event(cr, event.Warning(reasonExternallyModified, errors.New(msg)))
+		}
+		cr.Status.SetConditions(v1alpha1.StateModifiedExternally(msg))
+	}
+	return nil
+}
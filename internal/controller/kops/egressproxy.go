@@ -0,0 +1,57 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errGetEgressProxyCredentials      = "cannot get egress proxy credentials"
+	errEgressProxyCredentialsNotFound = "egress proxy credentials secret has no key %q"
+)
+
+// getEgressProxy returns the egress proxy of the supplied Kops resource, with
+// the credentials it is authenticated to with, or nil if it has none.
+func (c *external) getEgressProxy(ctx context.Context, cr *v1alpha1.Kops) (*kopsapi.EgressProxySpec, error) {
+	p := cr.Spec.ForProvider.EgressProxy
+	if p == nil {
+		return nil, nil
+	}
+	var credentials string
+	if ref := p.CredentialsSecretRef; ref != nil {
+		s := &corev1.Secret{}
+		if err := c.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s); err != nil {
+			return nil, errors.Wrap(err, errGetEgressProxyCredentials)
+		}
+		data, ok := s.Data[ref.Key]
+		if !ok {
+			return nil, errors.Wrap(errors.Errorf(errEgressProxyCredentialsNotFound, ref.Key), errGetEgressProxyCredentials)
+		}
+		credentials = strings.TrimSpace(string(data))
+	}
+	return util.GetEgressProxySpec(p, credentials), nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errGetEncryptionConfig      = "cannot get Kops cluster encryption config"
+	errWriteEncryptionConfig    = "cannot write Kops cluster encryption config"
+	errRolloutEncryptionConfig  = "cannot roll out Kops cluster encryption config"
+	errEncryptionConfigNotFound = "encryption config secret has no key %q"
+)
+
+const (
+	reasonEncryptionConfig        event.Reason = "CannotRollOutEncryptionConfig"
+	reasonEncryptionConfigRolling event.Reason = "RollingOutEncryptionConfig"
+	reasonEncryptionConfigRolled  event.Reason = "RolledOutEncryptionConfig"
+)
+
+// encryptionConfigSettleTime is how long after a control plane instance was
+// replaced the next one may be, so that an instance that is still shutting
+// down isn't mistaken for a replaced one by validation.
+const encryptionConfigSettleTime = 5 * time.Minute
+
+// getEncryptionConfig returns the encryption config referenced by the
+// supplied Kops resource, or nil if it references none.
+func (c *external) getEncryptionConfig(ctx context.Context, cr *v1alpha1.Kops) ([]byte, error) {
+	ref := cr.Spec.ForProvider.EncryptionConfigSecretRef
+	if ref == nil {
+		return nil, nil
+	}
+	s := &corev1.Secret{}
+	if err := c.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s); err != nil {
+		return nil, errors.Wrap(err, errGetEncryptionConfig)
+	}
+	config, ok := s.Data[ref.Key]
+	if !ok {
+		return nil, errors.Wrap(errors.Errorf(errEncryptionConfigNotFound, ref.Key), errGetEncryptionConfig)
+	}
+	return config, nil
+}
+
+// encryptionConfigUpToDate returns false if the encryption config referenced
+// by the supplied Kops resource differs from the one last written to the
+// cluster's secret store.
+func (c *external) encryptionConfigUpToDate(ctx context.Context, cr *v1alpha1.Kops) (bool, error) {
+	config, err := c.getEncryptionConfig(ctx, cr)
+	if err != nil || config == nil {
+		return true, err
+	}
+	obs := cr.Status.AtProvider.EncryptionConfig
+	return obs != nil && obs.Hash == util.HashEncryptionConfig(config), nil
+}
+
+// writeEncryptionConfig writes the encryption config referenced by the
+// supplied Kops resource, if any, to the secret store of the supplied cluster
+// and returns its hash.
+func (c *external) writeEncryptionConfig(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) (string, error) {
+	config, err := c.getEncryptionConfig(ctx, cr)
+	if err != nil || config == nil {
+		return "", err
+	}
+	hash, err := util.WriteEncryptionConfig(c.kopsClientset, cluster, config)
+	return hash, errors.Wrap(err, errWriteEncryptionConfig)
+}
+
+// recordEncryptionConfig records the encryption config with the supplied hash
+// as applied to the supplied cluster. If it replaced a different config the
+// API servers keep running with the old one until they restart, so the
+// cluster's current control plane instances are marked for replacement.
+func (c *external) recordEncryptionConfig(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud, hash string) error {
+	prev := cr.Status.AtProvider.EncryptionConfig
+	if hash == "" || (prev != nil && prev.Hash == hash) {
+		return nil
+	}
+	obs := &v1alpha1.EncryptionConfigObservation{Hash: hash}
+	if prev != nil {
+		ig, err := c.kopsClientset.InstanceGroupsFor(cluster).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return errors.Wrap(err, errRolloutEncryptionConfig)
+		}
+		// Instances still pending from an earlier rollout are among them.
+		ids, err := util.GetControlPlaneInstances(cloud, cluster, ig)
+		if err != nil {
+			return errors.Wrap(err, errRolloutEncryptionConfig)
+		}
+		obs.PendingInstances = ids
+		c.recorder.Event(cr, event.Normal(reasonEncryptionConfigRolling, fmt.Sprintf("Replacing %d control plane instances one at a time to load the changed encryption config", len(ids))))
+	}
+	cr.Status.AtProvider.EncryptionConfig = obs
+	return nil
+}
+
+// observeEncryptionConfigRollout replaces the next control plane instance that
+// still runs with a previous encryption config, once the cluster validated
+// since the last one was replaced. Failures are reported as events and
+// retried on the next observation.
+func (c *external) observeEncryptionConfigRollout(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, ig *kopsapi.InstanceGroupList) {
+	obs := cr.Status.AtProvider.EncryptionConfig
+	if obs == nil || len(obs.PendingInstances) == 0 {
+		return
+	}
+	if obs.LastReplacedTime != nil && time.Since(obs.LastReplacedTime.Time) < encryptionConfigSettleTime {
+		return
+	}
+	if !rolloutAllowed(cr) {
+		return
+	}
+
+	_, span := tracing.Start(ctx, "ReplaceControlPlaneInstance")
+	cloud, err := c.buildCloud(cluster)
+	var replaced string
+	var remaining []string
+	if err == nil {
+		replaced, remaining, err = util.ReplaceControlPlaneInstance(cloud, cluster, ig, obs.PendingInstances)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonEncryptionConfig, errors.Wrap(err, errRolloutEncryptionConfig)))
+		return
+	}
+
+	obs.PendingInstances = remaining
+	if replaced != "" {
+		now := metav1.Now()
+		obs.LastReplacedTime = &now
+		c.recorder.Event(cr, event.Normal(reasonEncryptionConfigRolling, fmt.Sprintf("Replaced control plane instance %s to load the changed encryption config; %d remaining", replaced, len(remaining))))
+		return
+	}
+	c.recorder.Event(cr, event.Normal(reasonEncryptionConfigRolled, "Every control plane instance runs with the changed encryption config"))
+}
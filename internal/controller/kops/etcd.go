@@ -0,0 +1,168 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errEtcdBackup  = "cannot observe requested Kops cluster etcd backup"
+	errEtcdRestore = "cannot restore Kops cluster etcd clusters"
+)
+
+const (
+	reasonEtcdBackup    event.Reason = "CannotObserveEtcdBackup"
+	reasonEtcdBackedUp  event.Reason = "BackedUpEtcd"
+	reasonEtcdRestore   event.Reason = "CannotRestoreEtcd"
+	reasonEtcdRestoring event.Reason = "RestoringEtcd"
+	reasonEtcdRestored  event.Reason = "RestoredEtcd"
+)
+
+// etcdRestoreSettleTime is how long after a restore was started the cluster is
+// first verified, so that a control plane that is still shutting down isn't
+// mistaken for a restored one.
+const etcdRestoreSettleTime = 5 * time.Minute
+
+// observeEtcdBackup tracks the etcd backup requested through the etcd backup
+// annotation, until every etcd cluster has been backed up since the request.
+// Failing to look at the backup stores is reported as an event and retried on
+// the next observation.
+func (c *external) observeEtcdBackup(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) {
+	req := cr.GetAnnotations()[v1alpha1.AnnotationKeyEtcdBackup]
+	if req == "" {
+		return
+	}
+
+	b := cr.Status.AtProvider.EtcdBackup
+	if b == nil || b.Request != req {
+		b = &v1alpha1.EtcdBackupObservation{Request: req, Time: metav1.Now()}
+		cr.Status.AtProvider.EtcdBackup = b
+	}
+	if b.Completed {
+		return
+	}
+
+	_, span := tracing.Start(ctx, "GetEtcdBackups")
+	backups, err := util.GetEtcdBackupsSince(c.kopsClientset, cluster, b.Time.Time)
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonEtcdBackup, errors.Wrap(err, errEtcdBackup)))
+		return
+	}
+
+	b.Backups = backups
+	if len(backups) == len(cluster.Spec.EtcdClusters) {
+		b.Completed = true
+		c.recorder.Event(cr, event.Normal(reasonEtcdBackedUp, "Backed up etcd clusters: "+formatEtcdBackups(backups)))
+	}
+}
+
+// observeEtcdRestore drives the etcd restore requested through the etcd
+// restore annotation. A restore writes etcd-manager restore commands to the
+// backup stores, replaces the control plane so that etcd-manager runs them,
+// then waits for the cluster to validate with healthy etcd clusters. Each step
+// is retried on the next observation if it fails.
+func (c *external) observeEtcdRestore(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, ig *kopsapi.InstanceGroupList) {
+	r := cr.Status.AtProvider.EtcdRestore
+	if req := cr.GetAnnotations()[v1alpha1.AnnotationKeyEtcdRestore]; req != "" && (r == nil || r.Request != req) {
+		_, span := tracing.Start(ctx, "WriteEtcdRestoreCommands")
+		backups, err := util.ParseEtcdRestoreRequest(req, cluster)
+		if err == nil {
+			backups, err = util.WriteEtcdRestoreCommands(c.kopsClientset, cluster, backups, time.Now())
+		}
+		tracing.End(span, err)
+		if err != nil {
+			c.recorder.Event(cr, event.Warning(reasonEtcdRestore, errors.Wrap(err, errEtcdRestore)))
+			return
+		}
+		r = &v1alpha1.EtcdRestoreObservation{
+			Request: req,
+			Phase:   v1alpha1.EtcdRestorePhaseRollingControlPlane,
+			Backups: backups,
+			Time:    metav1.Now(),
+		}
+		cr.Status.AtProvider.EtcdRestore = r
+		c.recorder.Event(cr, event.Normal(reasonEtcdRestoring, "Restoring etcd clusters from backups: "+formatEtcdBackups(backups)))
+	}
+	if r == nil {
+		return
+	}
+
+	switch r.Phase {
+	case v1alpha1.EtcdRestorePhaseRollingControlPlane:
+		_, span := tracing.Start(ctx, "DeleteControlPlaneInstances")
+		cloud, err := c.buildCloud(cluster)
+		var n int
+		if err == nil {
+			n, err = util.DeleteControlPlaneInstances(cloud, cluster, ig)
+		}
+		tracing.End(span, err)
+		if err != nil {
+			c.recorder.Event(cr, event.Warning(reasonEtcdRestore, errors.Wrap(err, errEtcdRestore)))
+			return
+		}
+		r.Phase = v1alpha1.EtcdRestorePhaseVerifying
+		c.recorder.Event(cr, event.Normal(reasonEtcdRestoring, fmt.Sprintf("Replacing %d control plane instances to restore etcd", n)))
+	case v1alpha1.EtcdRestorePhaseVerifying:
+		if time.Since(r.Time.Time) < etcdRestoreSettleTime {
+			return
+		}
+		if cr.Status.GetCondition(v1alpha1.TypeClusterValidated).Status != corev1.ConditionTrue || !etcdHealthy(cluster, cr.Status.AtProvider.Etcd) {
+			return
+		}
+		r.Phase = v1alpha1.EtcdRestorePhaseCompleted
+		c.recorder.Event(cr, event.Normal(reasonEtcdRestored, "Restored etcd clusters from backups: "+formatEtcdBackups(r.Backups)))
+	}
+}
+
+// etcdHealthy returns true if every etcd cluster of the supplied cluster was
+// observed with all of its members healthy.
+func etcdHealthy(cluster *kopsapi.Cluster, obs []v1alpha1.EtcdClusterObservation) bool {
+	if len(obs) != len(cluster.Spec.EtcdClusters) {
+		return false
+	}
+	for _, o := range obs {
+		if o.Members == 0 || o.HealthyMembers != o.Members {
+			return false
+		}
+	}
+	return true
+}
+
+func formatEtcdBackups(backups map[string]string) string {
+	s := make([]string, 0, len(backups))
+	for name, backup := range backups {
+		s = append(s, name+"="+backup)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
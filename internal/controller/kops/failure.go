@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+// Operations of an external client.
+const (
+	opObserve = "Observe"
+	opCreate  = "Create"
+	opUpdate  = "Update"
+	opDelete  = "Delete"
+)
+
+// A stateStoreError is an error reading or writing the kops state store.
+type stateStoreError struct {
+	error
+}
+
+func (e stateStoreError) Unwrap() error {
+	return e.error
+}
+
+// A waitError is an error of an operation that could not start yet, waiting
+// for something that is expected to clear on its own, such as a busy apply
+// worker. The operation is retried by a later reconcile.
+type waitError struct {
+	error
+	reason xpv1.ConditionReason
+}
+
+func (e waitError) Unwrap() error {
+	return e.error
+}
+
+// failureReason classifies an error of the supplied operation.
+func failureReason(op string, err error) xpv1.ConditionReason {
+	if util.IsCloudAuthError(err) {
+		return v1alpha1.ReasonCloudAuthFailure
+	}
+	if errors.As(err, &stateStoreError{}) {
+		return v1alpha1.ReasonStateStoreUnreachable
+	}
+	switch op {
+	case opCreate, opUpdate:
+		return v1alpha1.ReasonApplyFailed
+	case opDelete:
+		return v1alpha1.ReasonDeleteBlocked
+	default:
+		return v1alpha1.ReasonObserveFailed
+	}
+}
+
+// recordFailure sets the Failing condition of the supplied Kops resource
+// from the outcome of an operation. A cluster that failed validation is
+// failing even though the operation succeeded, while one whose operation is
+// waiting to start is not.
+func recordFailure(mg resource.Managed, op string, err error) {
+	cr, ok := mg.(*v1alpha1.Kops)
+	if !ok {
+		return
+	}
+	var w waitError
+	if errors.As(err, &w) {
+		cr.Status.SetConditions(v1alpha1.Waiting(w.reason, err.Error()))
+		return
+	}
+	if err != nil {
+		cr.Status.SetConditions(v1alpha1.Failing(failureReason(op, err), err.Error()))
+		return
+	}
+	if c := cr.Status.GetCondition(v1alpha1.TypeClusterValidated); c.Status == corev1.ConditionFalse && c.Reason == v1alpha1.ReasonValidationFailed {
+		cr.Status.SetConditions(v1alpha1.Failing(v1alpha1.ReasonValidationFailed, c.Message))
+		return
+	}
+	cr.Status.SetConditions(v1alpha1.NotFailing())
+}
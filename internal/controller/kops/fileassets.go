@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"encoding/base64"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+const (
+	errGetFileAsset       = "cannot get Kops cluster file asset %q"
+	errFileAssetNoSource  = "file asset selects neither a ConfigMap nor a Secret"
+	errFileAssetNotFound  = "file asset source has no key %q"
+	errFileAssetConfigMap = "cannot get file asset ConfigMap"
+	errFileAssetSecret    = "cannot get file asset Secret"
+)
+
+// getFileAssets returns the file assets of the supplied Kops resource, with
+// the content of the ConfigMap or Secret key each is sourced from. Content
+// that isn't known to be text is base64 encoded, which nodeup decodes before
+// writing the file.
+func (c *external) getFileAssets(ctx context.Context, cr *v1alpha1.Kops) ([]kopsapi.FileAssetSpec, error) {
+	assets := make([]kopsapi.FileAssetSpec, 0, len(cr.Spec.ForProvider.FileAssets))
+	for _, a := range cr.Spec.ForProvider.FileAssets {
+		spec := kopsapi.FileAssetSpec{Name: a.Name, Path: a.Path, Roles: a.Roles}
+		var err error
+		switch {
+		case a.ConfigMapRef != nil:
+			spec.Content, spec.IsBase64, err = c.getConfigMapKey(ctx, a.ConfigMapRef)
+		case a.SecretRef != nil:
+			spec.Content, err = c.getSecretKey(ctx, a.SecretRef)
+			spec.IsBase64 = true
+		default:
+			err = errors.New(errFileAssetNoSource)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, errGetFileAsset, a.Name)
+		}
+		assets = append(assets, spec)
+	}
+	return assets, nil
+}
+
+func (c *external) getConfigMapKey(ctx context.Context, ref *v1alpha1.ConfigMapKeySelector) (string, bool, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, cm); err != nil {
+		return "", false, errors.Wrap(err, errFileAssetConfigMap)
+	}
+	if content, ok := cm.Data[ref.Key]; ok {
+		return content, false, nil
+	}
+	if content, ok := cm.BinaryData[ref.Key]; ok {
+		return base64.StdEncoding.EncodeToString(content), true, nil
+	}
+	return "", false, errors.Errorf(errFileAssetNotFound, ref.Key)
+}
+
+func (c *external) getSecretKey(ctx context.Context, ref *xpv1.SecretKeySelector) (string, error) {
+	s := &corev1.Secret{}
+	if err := c.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s); err != nil {
+		return "", errors.Wrap(err, errFileAssetSecret)
+	}
+	content, ok := s.Data[ref.Key]
+	if !ok {
+		return "", errors.Errorf(errFileAssetNotFound, ref.Key)
+	}
+	return base64.StdEncoding.EncodeToString(content), nil
+}
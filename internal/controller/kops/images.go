@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errTrackImage   = "cannot look up the latest image of instance group %s"
+	errLoadChannel  = "cannot load Kops channel"
+	errSSMNotOnAWS  = "SSM parameter images are only supported on AWS"
+	errNewSSMClient = "cannot create SSM client"
+)
+
+const (
+	reasonTrackImage   event.Reason = "CannotTrackImage"
+	reasonImageChanged event.Reason = "TrackedImageChanged"
+)
+
+// observeImages looks up the latest image of each instance group whose image
+// is tracked and is due for a check. A newer image is recorded in the status,
+// from where it becomes part of the desired instance group, so that the
+// instance group is updated, and its instances replaced, like after any other
+// change to its image. Failures are reported as events and retried on the
+// next observation.
+func (c *external) observeImages(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) {
+	tracking := cr.Spec.ForProvider.ImageTracking
+	if len(tracking) == 0 {
+		cr.Status.AtProvider.TrackedImages = nil
+		return
+	}
+	prev := map[string]v1alpha1.TrackedImageObservation{}
+	for _, t := range cr.Status.AtProvider.TrackedImages {
+		prev[t.InstanceGroup] = t
+	}
+
+	now := time.Now()
+	var channel *kopsapi.Channel
+	tracked := make([]v1alpha1.TrackedImageObservation, 0, len(tracking))
+	for _, t := range tracking {
+		obs, ok := prev[t.InstanceGroup]
+		interval := util.DefaultImageTrackingInterval
+		if t.Interval != nil {
+			interval = t.Interval.Duration
+		}
+		if ok && now.Sub(obs.LastCheckedTime.Time) < interval {
+			tracked = append(tracked, obs)
+			continue
+		}
+
+		_, span := tracing.Start(ctx, "TrackImage")
+		var image string
+		var err error
+		if t.Source == v1alpha1.ImageSourceSSMParameter {
+			image, err = c.ssmParameterImage(cluster, t.SSMParameter)
+		} else {
+			if channel == nil {
+				channel, err = kopsapi.LoadChannel(cluster.Spec.Channel)
+				err = errors.Wrap(err, errLoadChannel)
+			}
+			if err == nil {
+				image, err = util.GetChannelImage(channel, kopsapi.CloudProviderID(cluster.Spec.CloudProvider), cluster.Spec.KubernetesVersion, t.Architecture)
+			}
+		}
+		tracing.End(span, err)
+		if err != nil {
+			c.recorder.Event(cr, event.Warning(reasonTrackImage, errors.Wrapf(err, errTrackImage, t.InstanceGroup)))
+			if ok {
+				tracked = append(tracked, obs)
+			}
+			continue
+		}
+
+		if !ok || obs.Image != image {
+			obs = v1alpha1.TrackedImageObservation{InstanceGroup: t.InstanceGroup, Image: image, LastChangedTime: metav1.NewTime(now)}
+			c.recorder.Event(cr, event.Normal(reasonImageChanged, fmt.Sprintf("Instance group %s tracks image %s; its instances are replaced once it is applied", t.InstanceGroup, image)))
+		}
+		obs.LastCheckedTime = metav1.NewTime(now)
+		tracked = append(tracked, obs)
+	}
+	cr.Status.AtProvider.TrackedImages = tracked
+}
+
+// ssmParameterImage returns the AMI ID stored in the supplied SSM parameter
+// of the region of a cluster, with the credentials of its cloud.
+func (c *external) ssmParameterImage(cluster *kopsapi.Cluster, name string) (string, error) {
+	cloud, err := c.buildCloud(cluster)
+	if err != nil {
+		return "", errors.Wrap(err, errNewCloud)
+	}
+	awsCloud, ok := cloud.(awsup.AWSCloud)
+	if !ok {
+		return "", errors.New(errSSMNotOnAWS)
+	}
+	sess, err := c.limiters.session(awsCloud)
+	if err != nil {
+		return "", errors.Wrap(err, errNewSSMClient)
+	}
+	return util.GetSSMParameterImage(ssm.New(sess), name)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+// introspectionPath is the path the provider's view of its managed clusters is
+// served under, on the metrics server.
+const introspectionPath = "/clusters"
+
+// An operation is the last operation the provider performed on a cluster.
+type operation struct {
+	Name  string
+	Time  time.Time
+	Error string
+}
+
+// A history records the last operation performed on each Kops resource.
+type history struct {
+	mu  sync.RWMutex
+	ops map[string]operation
+}
+
+func newHistory() *history {
+	return &history{ops: map[string]operation{}}
+}
+
+func (h *history) record(name, op string, err error) {
+	o := operation{Name: op, Time: time.Now()}
+	if err != nil {
+		o.Error = err.Error()
+	}
+	h.mu.Lock()
+	h.ops[name] = o
+	h.mu.Unlock()
+}
+
+func (h *history) forget(name string) {
+	h.mu.Lock()
+	delete(h.ops, name)
+	h.mu.Unlock()
+}
+
+func (h *history) get(name string) (operation, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	o, ok := h.ops[name]
+	return o, ok
+}
+
+// A clusterView is the provider's view of a managed cluster.
+type clusterView struct {
+	Name              string     `json:"name"`
+	Cluster           string     `json:"cluster"`
+	Phase             string     `json:"phase,omitempty"`
+	Ready             string     `json:"ready,omitempty"`
+	Synced            string     `json:"synced,omitempty"`
+	LastOperation     string     `json:"lastOperation,omitempty"`
+	LastReconcileTime *time.Time `json:"lastReconcileTime,omitempty"`
+	LastError         string     `json:"lastError,omitempty"`
+}
+
+// An introspectionHandler serves the provider's view of every Kops resource
+// as JSON, so that fleet operators don't need to query each resource.
+type introspectionHandler struct {
+	kube    client.Reader
+	history *history
+}
+
+func (h *introspectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l := &v1alpha1.KopsList{}
+	if err := h.kube.List(r.Context(), l); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]clusterView, 0, len(l.Items))
+	for i := range l.Items {
+		views = append(views, h.view(&l.Items[i]))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(views)
+}
+
+func (h *introspectionHandler) view(cr *v1alpha1.Kops) clusterView {
+	ready := cr.GetCondition(xpv1.TypeReady)
+	synced := cr.GetCondition(xpv1.TypeSynced)
+	v := clusterView{
+		Name:    cr.GetName(),
+		Cluster: fmt.Sprintf("%v.%v", meta.GetExternalName(cr), cr.Spec.ForProvider.Domain),
+		Phase:   string(ready.Reason),
+		Ready:   string(ready.Status),
+		Synced:  string(synced.Status),
+	}
+	if p := cr.Status.AtProvider.Phase; p != "" {
+		v.Phase = string(p)
+	}
+	if o, ok := h.history.get(cr.GetName()); ok {
+		v.LastOperation = o.Name
+		v.LastReconcileTime = &o.Time
+		v.LastError = o.Error
+	}
+	return v
+}
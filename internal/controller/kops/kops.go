@@ -19,25 +19,47 @@ package kops
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	"github.com/crossplane/crossplane-runtime/pkg/connection"
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
 	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/feature"
 	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
 	apisv1alpha1 "github.com/crossplane/provider-kops/apis/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/clients"
 	"github.com/crossplane/provider-kops/internal/controller/features"
+	"github.com/crossplane/provider-kops/internal/kopslog"
+	"github.com/crossplane/provider-kops/internal/metrics"
+	"github.com/crossplane/provider-kops/internal/tracing"
 	"github.com/crossplane/provider-kops/internal/util"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	kopsversion "k8s.io/kops"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
 	kopsClient "k8s.io/kops/pkg/client/simple"
+	"k8s.io/kops/pkg/cloudinstances"
+	"k8s.io/kops/pkg/resources"
 	resourceops "k8s.io/kops/pkg/resources/ops"
+	"k8s.io/kops/pkg/validation"
+	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -55,43 +77,244 @@ const (
 	errNewCloudAssignment    = "cannot assign Kops cloud"
 	errGetCluster            = "cannot get Kops cluster from API"
 	errGetInstanceGroup      = "cannot get Kops instance group from API"
+	errGetIssuer             = "cannot get Kops cluster service account issuer"
+	errGetCACertificate      = "cannot get Kops cluster CA certificate"
 	errValidateCluster       = "cannot validate Kops cluster"
-	errEvaluateClusterState  = "cannot evaluate Kops cluster state"
 	errGetKubeConfig         = "cannot get KubeConfig"
 	errGetClusterStatus      = "cannot get Kops cluster status"
 	errUpdateCluster         = "cannot update Kops cluster"
 	errUpdateClusterState    = "cannot update Kops cluster state"
 	errDeleteResources       = "cannot delete Kops resources"
+	errListRegionResources   = "cannot list cloud resources in region %s"
+	errHashParameters        = "cannot hash Kops parameters"
+	errListResources         = "cannot list Kops cluster resources"
+	errGetEtcdStatus         = "cannot get Kops cluster etcd status"
+	errDump                  = "cannot collect Kops cluster diagnostic bundle"
+	errGetRollingUpdates     = "cannot get Kops cluster pending rolling updates"
+	errRecordClusterEvent    = "cannot record event in Kops cluster"
+	errRenderTerraform       = "cannot render Kops cluster as Terraform"
+	errPublishRenderedSpec   = "cannot publish rendered Kops cluster spec"
+	errBackupState           = "cannot back up Kops cluster state"
+	errMarkApplied           = "cannot mark Kops cluster as applied"
+	errGetApplied            = "cannot determine whether Kops cluster was ever applied"
+	errGetAddons             = "cannot get Kops cluster addons"
+	errWriteAddons           = "cannot write Kops cluster addons"
+	errGetStateVersion       = "cannot get kops version of Kops cluster state"
+	errVersionSkew           = "refusing to update Kops cluster state written by a newer kops version"
+	errMissingSubnets        = "refusing to apply Kops cluster with instance groups in subnets it doesn't have"
+	errOverrides             = "refusing to apply Kops cluster without its overrides"
+	errInstanceGroupPatches  = "refusing to apply Kops cluster without its instance group patches"
+	errApplyWorkers          = "cannot start applying or deleting Kops cluster"
+	errMarkApplyInProgress   = "cannot record apply of Kops cluster in progress"
+	errClearApplyInProgress  = "cannot record apply of Kops cluster as completed"
+	errGetApplyInProgress    = "cannot get incomplete apply of Kops cluster"
+	errClaimCluster          = "cannot record Kops resource as managing Kops cluster"
+	errOwnedByOther          = "refusing to manage Kops cluster managed by another Kops resource"
 )
 
+const (
+	reasonCertificateIssued     event.Reason = "IssuedClientCertificate"
+	reasonSpecRegistered        event.Reason = "RegisteredClusterSpec"
+	reasonCloudAssigned         event.Reason = "AssignedCloudResources"
+	reasonApplyStarted          event.Reason = "StartedApply"
+	reasonApplyFinished         event.Reason = "FinishedApply"
+	reasonValidationPassed      event.Reason = "ValidationSucceeded"
+	reasonValidationFailed      event.Reason = "ValidationFailed"
+	reasonDeletingResources     event.Reason = "DeletingResources"
+	reasonDeletedResources      event.Reason = "DeletedResources"
+	reasonRetainingResources    event.Reason = "RetainingResources"
+	reasonDeletedState          event.Reason = "DeletedClusterState"
+	reasonListResources         event.Reason = "CannotListResources"
+	reasonEtcdStatus            event.Reason = "CannotGetEtcdStatus"
+	reasonDumped                event.Reason = "CollectedDiagnosticBundle"
+	reasonDump                  event.Reason = "CannotCollectDiagnosticBundle"
+	reasonRollingUpdate         event.Reason = "RollingUpdatePending"
+	reasonClusterEvent          event.Reason = "CannotRecordClusterEvent"
+	reasonRenderedTerraform     event.Reason = "RenderedTerraform"
+	reasonSkippedResources      event.Reason = "SkippedDeletingResources"
+	reasonRenderedSpec          event.Reason = "CannotPublishRenderedSpec"
+	reasonBackedUpState         event.Reason = "BackedUpClusterState"
+	reasonInfrastructureMissing event.Reason = "InfrastructureMissing"
+	reasonVersionSkew           event.Reason = "VersionSkew"
+	reasonIncompleteApply       event.Reason = "ResumingIncompleteApply"
+	reasonAdoptedCluster        event.Reason = "AdoptedCluster"
+	reasonOwnedByOther          event.Reason = "ClusterManagedElsewhere"
+	reasonDeprecatedFields      event.Reason = "DeprecatedFields"
+	reasonMissingSubnets        event.Reason = "MissingSubnets"
+	reasonCARotated             event.Reason = "RotatedCA"
+	reasonReplacingInstances    event.Reason = "ReplacingInstances"
+)
+
+// defaultCertificateExpiryThreshold is used when a Kops resource does not
+// specify a certificate expiry threshold.
+const defaultCertificateExpiryThreshold = 30 * 24 * time.Hour
+
+// capiKubeconfigKey is the key Cluster API stores kubeconfigs under in its
+// <cluster>-kubeconfig secrets.
+const capiKubeconfigKey = "value"
+
+// renderedSpecKey is the key rendered cluster specs are stored under in the
+// rendered spec secret.
+const renderedSpecKey = "cluster.yaml"
+
+// infrastructureGracePeriod is how long after an apply a cluster without
+// instances is not yet considered to be missing its infrastructure.
+const infrastructureGracePeriod = 15 * time.Minute
+
+// healthProbeTimeout bounds each request made by an HTTP health probe.
+const healthProbeTimeout = 10 * time.Second
+
+// Options configure the Kops controller beyond the options shared by every
+// controller.
+type Options struct {
+	// MaxConcurrentApplies bounds how many clusters are applied or deleted at
+	// once. It should be lower than the controller's maximum concurrent
+	// reconciles so that clusters are still observed while others are being
+	// applied. Applies are not bounded if it is zero.
+	MaxConcurrentApplies int
+
+	// AWSAPIQPS and AWSAPIBurst rate limit the AWS API requests made in each
+	// region. Requests are not rate limited if AWSAPIQPS is zero.
+	AWSAPIQPS   float64
+	AWSAPIBurst int
+
+	// Shard selects the Kops resources this controller reconciles.
+	Shard Shard
+
+	// MaxCertificateTTL caps the validity Kops resources may request for
+	// the client certificate of their connection details, in addition to
+	// the certificate policy of their ProviderConfig. Unbounded if zero.
+	MaxCertificateTTL time.Duration
+
+	// CircuitBreakerThreshold is the number of identical failures in a row
+	// after which a Kops resource stops being reconciled until it is resumed.
+	// Resources are never paused if it is zero.
+	CircuitBreakerThreshold int
+
+	// MaxFailureBackoff bounds the exponential backoff of Kops resources
+	// that keep failing. Failures are not backed off if it is zero.
+	MaxFailureBackoff time.Duration
+
+	// PublishWhenPaused keeps observing paused Kops resources, without
+	// changing their clusters, so that the client certificates of their
+	// connection details are refreshed before they expire.
+	PublishWhenPaused bool
+
+	// ValidationTimeout bounds how long validating a cluster, including
+	// each request to its API server, may take. Unbounded if zero.
+	ValidationTimeout time.Duration
+
+	// ProvisionStateBuckets creates the S3 state buckets of Kops resources
+	// that don't exist yet.
+	ProvisionStateBuckets bool
+
+	// ReadOnly only observes clusters. Creating and deleting them is
+	// refused, and updates are applied as dry runs whose changes are
+	// reported rather than made.
+	ReadOnly bool
+}
+
 // Setup adds a controller that reconciles Kops managed resources.
-func Setup(mgr ctrl.Manager, o controller.Options) error {
+func Setup(mgr ctrl.Manager, o controller.Options, ko Options) error {
 	name := managed.ControllerName(v1alpha1.KopsGroupKind)
 
-	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
-	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
-		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
+	recorder := event.NewAPIRecorder(mgr.GetEventRecorderFor(name))
+	h := newHistory()
+
+	if err := mgr.AddMetricsExtraHandler(introspectionPath, &introspectionHandler{kube: mgr.GetClient(), history: h}); err != nil {
+		return err
+	}
+
+	// State buckets aren't created while the provider is read-only.
+	var buckets *stateBuckets
+	if ko.ProvisionStateBuckets && !ko.ReadOnly {
+		buckets = newStateBuckets()
 	}
 
 	r := managed.NewReconciler(mgr,
 		resource.ManagedKind(v1alpha1.KopsGroupVersionKind),
 		managed.WithExternalConnecter(&connector{
-			kube:  mgr.GetClient(),
-			usage: resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{})}),
+			kube:              mgr.GetClient(),
+			usage:             resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+			recorder:          recorder,
+			history:           h,
+			clientsets:        newTTLCache(clientsetTTL),
+			clouds:            newTTLCache(cloudTTL),
+			applies:           newApplyPool(ko.MaxConcurrentApplies),
+			limiters:          newAWSLimiters(ko.AWSAPIQPS, ko.AWSAPIBurst),
+			kubeClients:       newKubeClients(),
+			stateBuckets:      buckets,
+			factory:           clients.KopsClientsetFactory,
+			builder:           clients.KopsCloudBuilder,
+			validator:         clients.KopsValidator,
+			applier:           clients.KopsApplier,
+			notifier:          clients.HTTPNotifier,
+			maxCertTTL:        ko.MaxCertificateTTL,
+			publishWhenPaused: ko.PublishWhenPaused,
+			validationTimeout: ko.ValidationTimeout,
+			readOnly:          ko.ReadOnly}),
 		managed.WithLogger(o.Logger.WithValues("controller", name)),
-		managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
-		managed.WithConnectionPublishers(cps...))
+		managed.WithRecorder(recorder),
+		managed.WithReferenceResolver(managed.NewAPISimpleReferenceResolver(mgr.GetClient())),
+		managed.WithConnectionPublishers(connectionPublishers(mgr.GetClient(), mgr.GetScheme(), o.Features)...))
 
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		WithOptions(o.ForControllerRuntime()).
-		For(&v1alpha1.Kops{}).
-		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+		For(&v1alpha1.Kops{}, builder.WithPredicates(ko.Shard.predicate())).
+		Complete(ratelimiter.NewReconciler(name, newBreaker(r, mgr.GetClient(), mgr.GetAPIReader(), recorder, ko.CircuitBreakerThreshold, ko.MaxFailureBackoff), o.GlobalRateLimiter))
+}
+
+// connectionPublishers returns the publishers connection details of Kops
+// resources are written through.
+func connectionPublishers(kube client.Client, ot runtime.ObjectTyper, f *feature.Flags, o ...connection.DetailsManagerOption) []managed.ConnectionPublisher {
+	cps := []managed.ConnectionPublisher{
+		managed.NewAPISecretPublisher(kube, ot),
+		newSecretMirrorPublisher(kube),
+	}
+	if f.Enabled(features.EnableAlphaExternalSecretStores) {
+		cps = append(cps, &templatedStorePublisher{connection.NewDetailsManager(kube, apisv1alpha1.StoreConfigGroupVersionKind, o...)})
+	}
+	return cps
 }
 
 type connector struct {
-	kube  client.Client
-	usage resource.Tracker
+	kube     client.Client
+	usage    resource.Tracker
+	recorder event.Recorder
+	history  *history
+
+	// The caches, pools and limiters below are shared by the external
+	// clients of every Kops resource.
+	clientsets  *ttlCache
+	clouds      *ttlCache
+	applies     *applyPool
+	limiters    *awsLimiters
+	kubeClients *kubeClients
+
+	// stateBuckets provisions missing state buckets if set.
+	stateBuckets *stateBuckets
+
+	// The clients through which kops is driven.
+	factory   clients.ClientsetFactory
+	builder   clients.CloudBuilder
+	validator clients.Validator
+	applier   clients.Applier
+
+	// The client through which lifecycle notifications are sent.
+	notifier clients.Notifier
+
+	// maxCertTTL caps the validity of connection detail certificates.
+	maxCertTTL time.Duration
+
+	// publishWhenPaused keeps observing paused Kops resources.
+	publishWhenPaused bool
+
+	// validationTimeout bounds how long validating a cluster may take.
+	validationTimeout time.Duration
+
+	// readOnly only observes clusters, and dry runs their updates.
+	readOnly bool
 }
 
 func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
@@ -104,19 +327,154 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		return nil, errors.Wrap(err, errTrackPCUsage)
 	}
 
-	kopsClientset, err := util.GetKopsClientset(cr.Spec.ForProvider.StateBucket, meta.GetExternalName(cr), cr.Spec.ForProvider.Domain)
+	ttl, err := c.certificateTTL(ctx, cr)
 	if err != nil {
-		return nil, errors.Wrap(err, errNewClient)
+		return nil, err
+	}
+
+	if err := c.provisionStateBucket(cr); err != nil {
+		recordFailure(cr, opObserve, err)
+		return nil, err
+	}
+
+	kopsClientset, err := c.clientset(cr.Spec.ForProvider.StateBucket, meta.GetExternalName(cr), cr.Spec.ForProvider.Domain)
+	if err != nil {
+		err = errors.Wrap(stateStoreError{err}, errNewClient)
+		recordFailure(cr, opObserve, err)
+		return nil, err
+	}
+
+	e := &external{kopsClientset: kopsClientset, kube: c.kube, status: c.kube.Status(), recorder: c.recorder, secret: resource.NewAPIPatchingApplicator(c.kube), clouds: c.clouds, applies: c.applies, limiters: c.limiters, kubeClients: c.kubeClients, builder: c.builder, validator: c.validator, applier: c.applier, notifier: c.notifier, certificateTTL: ttl, publishWhenPaused: c.publishWhenPaused, validationTimeout: c.validationTimeout, readOnly: c.readOnly}
+	return &instrumentedExternal{
+		ExternalClient: e,
+		cluster:        fmt.Sprintf("%v.%v", meta.GetExternalName(cr), cr.Spec.ForProvider.Domain),
+		name:           cr.GetName(),
+		history:        c.history,
+		forget:         c.forget,
+		owned:          func() bool { return e.yieldedTo == nil },
+	}, nil
+}
+
+// forget releases everything held for the supplied Kops resource and its
+// cluster across reconciles. It is called once the cluster of a deleted Kops
+// resource is gone, so that the memory used by the provider doesn't grow with
+// the number of clusters it ever managed. What is held for the cluster is
+// only released if the resource owned it, as a resource that left its cluster
+// to another one shares it with that resource.
+func (c *connector) forget(name, cluster string, uid types.UID, owned bool) {
+	c.history.forget(name)
+	if !owned {
+		return
+	}
+	if c.clouds != nil {
+		c.clouds.forget(cluster + "|")
+	}
+	if c.kubeClients != nil {
+		c.kubeClients.forget(cluster)
+	}
+	metrics.ForgetCluster(cluster, util.KubeconfigCommonName(cluster, uid),
+		util.CertificateReasonValidation, util.CertificateReasonConnectionDetails, util.CertificateReasonClusterEvents)
+}
+
+// An instrumentedExternal wraps an ExternalClient, recording a span for each
+// of its operations. Spans are no-ops unless tracing has been set up.
+// Operations are also tracked so that kops library logs can be attributed to
+// them, and recorded in the history served by the introspection endpoint.
+type instrumentedExternal struct {
+	managed.ExternalClient
+	cluster string
+	name    string
+	history *history
+	forget  func(name, cluster string, uid types.UID, owned bool)
+
+	// owned returns false if the observed resource left its cluster to the
+	// resource that owns it. Resources are assumed to own their cluster if
+	// it is nil.
+	owned func() bool
+}
+
+func (t *instrumentedExternal) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	defer kopslog.Track(t.cluster, opObserve)()
+	ctx, span := tracing.Start(ctx, opObserve, tracing.Cluster(t.cluster))
+	o, err := t.ExternalClient.Observe(ctx, mg)
+	tracing.End(span, err)
+	t.history.record(t.name, opObserve, err)
+	recordFailure(mg, opObserve, err)
+	// The managed resource is about to be removed once a deleted resource's
+	// cluster is observed to be gone.
+	if err == nil && !o.ResourceExists && meta.WasDeleted(mg) && t.forget != nil {
+		t.forget(t.name, t.cluster, mg.GetUID(), t.owned == nil || t.owned())
 	}
+	return o, err
+}
 
-	return &external{kopsClientset: kopsClientset}, nil
+func (t *instrumentedExternal) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	defer kopslog.Track(t.cluster, opCreate)()
+	ctx, span := tracing.Start(ctx, opCreate, tracing.Cluster(t.cluster))
+	o, err := t.ExternalClient.Create(ctx, mg)
+	tracing.End(span, err)
+	t.history.record(t.name, opCreate, err)
+	recordFailure(mg, opCreate, err)
+	return o, err
+}
+
+func (t *instrumentedExternal) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	defer kopslog.Track(t.cluster, opUpdate)()
+	ctx, span := tracing.Start(ctx, opUpdate, tracing.Cluster(t.cluster))
+	o, err := t.ExternalClient.Update(ctx, mg)
+	tracing.End(span, err)
+	t.history.record(t.name, opUpdate, err)
+	recordFailure(mg, opUpdate, err)
+	return o, err
+}
+
+func (t *instrumentedExternal) Delete(ctx context.Context, mg resource.Managed) error {
+	defer kopslog.Track(t.cluster, opDelete)()
+	ctx, span := tracing.Start(ctx, opDelete, tracing.Cluster(t.cluster))
+	err := t.ExternalClient.Delete(ctx, mg)
+	tracing.End(span, err)
+	t.history.record(t.name, opDelete, err)
+	recordFailure(mg, opDelete, err)
+	return err
 }
 
 // An ExternalClient observes, then either creates, updates, or deletes an
 // external resource to ensure it reflects the managed resource's desired state.
 type external struct {
-	service       interface{}
 	kopsClientset kopsClient.Clientset
+	kube          client.Reader
+	status        client.StatusWriter
+	recorder      event.Recorder
+	secret        resource.Applicator
+	clouds        *ttlCache
+	applies       *applyPool
+	limiters      *awsLimiters
+	kubeClients   *kubeClients
+	builder       clients.CloudBuilder
+	validator     clients.Validator
+	applier       clients.Applier
+	notifier      clients.Notifier
+
+	// certificateTTL is the validity of the client certificate published in
+	// the connection details, within the certificate policy.
+	certificateTTL time.Duration
+
+	// publishWhenPaused observes paused Kops resources rather than leaving
+	// them alone.
+	publishWhenPaused bool
+
+	// validationTimeout bounds how long validating a cluster may take, so
+	// that an unreachable API server doesn't block a worker. Unbounded if
+	// zero.
+	validationTimeout time.Duration
+
+	// readOnly refuses to create or delete clusters, and dry runs updates,
+	// so that clusters are only observed.
+	readOnly bool
+
+	// yieldedTo is the resource that owns the cluster a deleted Kops
+	// resource was observed to leave to it, if any.
+	yieldedTo *util.ClusterOwner
 }
 
 func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
@@ -125,66 +483,608 @@ func (c *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 		return managed.ExternalObservation{}, errors.New(errNotKops)
 	}
 
-	cluster, err := c.kopsClientset.GetCluster(ctx, fmt.Sprintf("%v.%v", meta.GetExternalName(cr), cr.Spec.ForProvider.Domain))
+	if paused(cr) && !c.publishWhenPaused {
+		return managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true}, nil
+	}
+
+	c.observeDeprecatedFields(cr)
+	c.observeMissingSubnets(cr)
+
+	sctx, span := tracing.Start(ctx, "GetCluster")
+	cluster, err := c.kopsClientset.GetCluster(sctx, fmt.Sprintf("%v.%v", meta.GetExternalName(cr), cr.Spec.ForProvider.Domain))
+	tracing.End(span, resource.Ignore(util.ErrNotFound, err))
 	if err != nil {
 		if util.ErrNotFound(err) {
+			transition(cr, lifecycleNotFound, time.Now())
+			return managed.ExternalObservation{ResourceExists: false}, nil
+		}
+		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(stateStoreError{err}, errGetCluster)
+	}
+
+	owner, err := c.claimCluster(cr, cluster)
+	if err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errClaimCluster)
+	}
+	if owner != nil {
+		// A resource being deleted must not delete the cluster another
+		// resource manages, so it is told the cluster is already gone.
+		if meta.WasDeleted(cr) {
+			c.yieldedTo = owner
+			c.recorder.Event(cr, event.Normal(reasonOwnedByOther, fmt.Sprintf("Left cluster %s to Kops resource %s, which manages it", cluster.ObjectMeta.Name, owner.Name)))
 			return managed.ExternalObservation{ResourceExists: false}, nil
 		}
-		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errGetCluster)
+		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(errors.Errorf("cluster %s is managed by Kops resource %s with UID %s; set the %s annotation to that UID to take it over", cluster.ObjectMeta.Name, owner.Name, owner.UID, v1alpha1.AnnotationKeyAdopt), errOwnedByOther)
+	}
+
+	if err := c.observeVersionSkew(cr, cluster); err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errGetStateVersion)
 	}
 
-	ig, err := c.kopsClientset.InstanceGroupsFor(cluster).List(ctx, metav1.ListOptions{})
+	if err := c.observeIncompleteApply(cr, cluster); err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errGetApplyInProgress)
+	}
+
+	sctx, span = tracing.Start(ctx, "ListInstanceGroups")
+	ig, err := c.kopsClientset.InstanceGroupsFor(cluster).List(sctx, metav1.ListOptions{})
+	tracing.End(span, err)
 	if err != nil {
 		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errGetInstanceGroup)
 	}
 
-	validate, err := util.ValidateKopsCluster(c.kopsClientset, cluster, ig)
+	issuer, err := util.GetServiceAccountIssuer(cluster)
 	if err != nil {
-		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errValidateCluster)
+		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errGetIssuer)
 	}
 
-	ok, res := util.EvaluateKopsValidationResult(validate)
-	if !ok {
-		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(fmt.Errorf("%s", res), errEvaluateClusterState)
+	ca, err := util.GetCACertificate(cluster, c.kopsClientset)
+	if err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errGetCACertificate)
 	}
 
-	kubeconfig, err := util.GenerateKubeConfig(cluster, c.kopsClientset)
+	cr.Status.AtProvider.APIEndpoint = util.GetAPIEndpoint(cluster)
+	cr.Status.AtProvider.ServiceAccountIssuer = issuer
+	cr.Status.AtProvider.OIDCDiscoveryURL = util.GetOIDCDiscoveryURL(issuer)
+	caFingerprint := util.GetCertificateFingerprint(ca)
+	cr.Status.AtProvider.CACertificateFingerprint = caFingerprint
+	cr.Status.AtProvider.CACertificateExpiry = &metav1.Time{Time: ca.Certificate.NotAfter}
+
+	keyset, err := util.GetCAKeysetFingerprint(cluster, c.kopsClientset)
+	if err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errGetCACertificate)
+	}
+	c.observeCAKeyset(cr, keyset)
+
+	var kube kubernetes.Interface
+	switch reachabilityMode(cr) {
+	case v1alpha1.ReachabilitySkipValidation:
+		c.skipValidation(ctx, cr, cluster, "validation is skipped by the reachability mode")
+	case v1alpha1.ReachabilityCloudOnly:
+		c.skipValidation(ctx, cr, cluster, "validation is skipped while the cluster is operated from the cloud only")
+	default:
+		kube = c.observeValidation(ctx, cr, cluster, ig, keyset)
+	}
+
+	missing := c.observeCloudGroups(ctx, cr, cluster, ig)
+	c.observeImages(ctx, cr, cluster)
+	c.observeUpgrades(ctx, cr, cluster)
+	// Rollouts replace instances, so they wait for a paused resource to be
+	// resumed, and for the provider to no longer be read-only.
+	if !paused(cr) && !c.readOnly {
+		c.observeEncryptionConfigRollout(ctx, cr, cluster, ig)
+		c.observeDockerConfigRollout(ctx, cr, cluster, ig)
+		c.observeCNIRollout(ctx, cr, cluster, ig)
+		c.observeAPIServerSANsRollout(ctx, cr, cluster, ig)
+	}
+	// Replicating the state writes to the replica bucket, which a read-only
+	// provider doesn't.
+	if !c.readOnly {
+		c.observeStateReplica(ctx, cr, cluster)
+	}
+
+	if req := cr.GetAnnotations()[v1alpha1.AnnotationKeyDump]; req != "" && (cr.Status.AtProvider.Dump == nil || cr.Status.AtProvider.Dump.Request != req) {
+		c.dump(ctx, cr, cluster, req)
+	}
+	if req := cr.GetAnnotations()[v1alpha1.AnnotationKeyDeletePreview]; req != "" && (cr.Status.AtProvider.DeletePreview == nil || cr.Status.AtProvider.DeletePreview.Request != req) {
+		c.previewDelete(ctx, cr, cluster, req)
+	}
+
+	_, span = tracing.Start(ctx, "IssueCertificate")
+	commonName := util.KubeconfigCommonName(cluster.GetName(), cr.GetUID())
+	config, err := util.GetKubeconfigForGroup(cluster, c.kopsClientset, commonName, util.CertificateReasonConnectionDetails, connectionGroup(cr), c.certificateTTL)
+	tracing.End(span, err)
 	if err != nil {
 		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errGetKubeConfig)
 	}
+	c.recordCertificateIssued(cr, commonName, util.CertificateReasonConnectionDetails, c.certificateTTL)
 
-	conn := managed.ConnectionDetails{
-		xpv1.ResourceCredentialsSecretKubeconfigKey: kubeconfig,
+	expiry, err := util.GetCertificateExpiry(config.CertData)
+	if err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errGetKubeConfig)
+	}
+	cr.Status.AtProvider.ClientCertificateExpiry = &metav1.Time{Time: expiry}
+	cr.Status.SetConditions(certificateExpiryCondition(cr, time.Now()))
+
+	// Only look at etcd once the API server could be reached for validation.
+	if cr.Status.AtProvider.Validation != nil {
+		c.observeEtcd(ctx, cr, cluster, kube)
+		if !c.readOnly {
+			c.observeConnectionRBAC(ctx, cr, kube)
+		}
+	}
+	c.observeEtcdBackup(ctx, cr, cluster)
+	if !paused(cr) && !c.readOnly {
+		c.observeEtcdRestore(ctx, cr, cluster, ig)
 	}
 
-	cr.Status.SetConditions(xpv1.Available())
+	kubeconfig, err := util.GenerateKubeConfig(cluster, config)
+	if err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errGetKubeConfig)
+	}
+
+	addonsUpToDate, err := util.AddonsUpToDate(c.kopsClientset, cluster, cr.Spec.ForProvider.Addons)
+	if err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, errors.Wrap(err, errGetAddons)
+	}
+
+	encryptionConfigUpToDate, err := c.encryptionConfigUpToDate(ctx, cr)
+	if err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, err
+	}
+
+	dockerConfigUpToDate, err := c.dockerConfigUpToDate(ctx, cr)
+	if err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, err
+	}
+
+	fileAssets, err := c.getFileAssets(ctx, cr)
+	if err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, err
+	}
+	egressProxy, err := c.getEgressProxy(ctx, cr)
+	if err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, err
+	}
+
+	desired := util.DesiredClusterSpec(cr)
+	util.MergeFileAssets(&desired, fileAssets)
+	util.MergeEgressProxy(&desired, egressProxy)
+	desiredIGs := util.DesiredInstanceGroupSpecs(cr)
+	cr.Status.AtProvider.PendingReplacements = util.GetInstanceGroupsNeedingReplacement(&desired, desiredIGs, ig)
+	if util.EgressProxyChanged(&cluster.Spec, &desired) {
+		cr.Status.AtProvider.PendingReplacements = nil
+		for _, s := range desiredIGs {
+			cr.Status.AtProvider.PendingReplacements = append(cr.Status.AtProvider.PendingReplacements, s.NodeLabels[kopsapi.NodeLabelInstanceGroup])
+		}
+	}
+	upToDate := util.ClusterResourceUpToDate(&desired, &cluster.Spec) &&
+		util.InstanceGroupListResourceUpToDate(&desired, desiredIGs, ig) &&
+		len(util.GetRemovedBastions(desiredIGs, ig)) == 0 &&
+		addonsUpToDate &&
+		!missing &&
+		encryptionConfigUpToDate &&
+		dockerConfigUpToDate &&
+		cr.Status.AtProvider.IncompleteApply == nil
+	if err := c.observeExternalModification(cr, cluster, ig, upToDate); err != nil {
+		return managed.ExternalObservation{ResourceExists: false}, err
+	}
 	return managed.ExternalObservation{
-		ResourceExists: true,
-		ResourceUpToDate: (util.ClusterResourceUpToDate(&cr.Spec.ForProvider.ClusterSpec, &cluster.Spec) &&
-			util.InstanceGroupListResourceUpToDate(cr.Spec.ForProvider.InstanceGroupSpec, ig)),
-		ConnectionDetails: conn,
+		ResourceExists:    true,
+		ResourceUpToDate:  paused(cr) || upToDate,
+		ConnectionDetails: connectionDetails(cr, config, kubeconfig),
 	}, nil
 }
 
+// observeValidation validates a cluster and returns the client it validated
+// it with. Validation results are reported through the ClusterValidated
+// condition rather than as errors, so that they don't bury the Synced
+// condition.
+func (c *external) observeValidation(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, ig *kopsapi.InstanceGroupList, keyset string) kubernetes.Interface {
+	if c.validationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.validationTimeout)
+		defer cancel()
+	}
+	_, span := tracing.Start(ctx, "ValidateCluster")
+	cloud, err := c.buildCloud(cluster)
+	var kube kubernetes.Interface
+	if err == nil {
+		kube, err = c.validationClient(ctx, cr, cluster, keyset)
+	}
+	var validate *validation.ValidationCluster
+	if err == nil {
+		validate, err = c.validator.Validate(ctx, cluster, cloud, ig, kube)
+	}
+	var gpus []v1alpha1.GPUPoolObservation
+	if err == nil && cr.Spec.ForProvider.VerifyGPUNodes {
+		gpus, err = util.VerifyGPUNodes(ctx, kube, ig, validate)
+	}
+	var cni *v1alpha1.CNIHealth
+	if err == nil {
+		cni, err = util.VerifyCNI(ctx, kube, cluster.Spec.Networking, validate)
+	}
+	var probes []v1alpha1.HealthProbeResult
+	if err == nil {
+		probes, err = util.RunHealthProbes(ctx, kube, &http.Client{Timeout: healthProbeTimeout}, cr.Spec.ForProvider.HealthProbes, validate)
+	}
+	tracing.End(span, err)
+	cr.Status.AtProvider.GPUPools = gpus
+	if err != nil && reachabilityMode(cr) == v1alpha1.ReachabilityAssumeReachable {
+		c.skipValidation(ctx, cr, cluster, errors.Wrap(err, errValidateCluster).Error())
+		return nil
+	}
+	if err != nil {
+		cr.Status.AtProvider.Validation = nil
+		cr.Status.AtProvider.Nodes = nil
+		cr.Status.SetConditions(v1alpha1.ClusterValidationError(errors.Wrap(err, errValidateCluster)), xpv1.Unavailable())
+		transition(cr, lifecycleValidationFailed, time.Now())
+		metrics.ClusterReady.WithLabelValues(cluster.ObjectMeta.Name).Set(0)
+	} else {
+		obs := util.GenerateValidationObservation(validate)
+		obs.CNI = cni
+		obs.HealthProbes = probes
+		cr.Status.AtProvider.Validation = obs
+		cr.Status.AtProvider.Nodes = util.GenerateNodeReadiness(validate, ig)
+		ok, res := util.EvaluateKopsValidationResult(validate)
+		c.recordValidationResult(ctx, cr, cluster, ok, res)
+		metrics.RecordValidation(cluster.ObjectMeta.Name, ok, len(obs.Failures), len(obs.NotReadyNodes))
+	}
+	return kube
+}
+
+// recordValidationResult sets the validated condition of a Kops resource from
+// the result of validating its cluster. Validation runs on every poll, so only
+// transitions are recorded as events.
+func (c *external) recordValidationResult(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, ok bool, failures []string) {
+	prev := cr.Status.GetCondition(v1alpha1.TypeClusterValidated).Reason
+	if ok {
+		if prev != v1alpha1.ReasonValidationSucceeded {
+			c.recorder.Event(cr, event.Normal(reasonValidationPassed, "Kops cluster validation passed"))
+		}
+		cr.Status.SetConditions(v1alpha1.ClusterValidated(), xpv1.Available())
+		if transition(cr, lifecycleValidated, time.Now()) {
+			c.notify(ctx, cr, cluster, util.NotificationClusterReady, "Cluster is ready")
+		}
+		return
+	}
+	msg := strings.Join(failures, "; ")
+	if prev != v1alpha1.ReasonValidationFailed {
+		c.recorder.Event(cr, event.Warning(reasonValidationFailed, errors.New(msg)))
+		c.notify(ctx, cr, cluster, util.NotificationValidationFailed, msg)
+	}
+	cr.Status.SetConditions(v1alpha1.ClusterValidationFailed(msg), xpv1.Unavailable())
+	transition(cr, lifecycleValidationFailed, time.Now())
+}
+
+// observeEtcd records the health of the etcd clusters of a cluster. etcd
+// health is informational, so failing to get it is reported as an event
+// rather than an error.
+func (c *external) observeEtcd(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, kube kubernetes.Interface) {
+	sctx, span := tracing.Start(ctx, "GetEtcdStatus")
+	var err error
+	cr.Status.AtProvider.Etcd, err = util.GetEtcdClusterStatus(sctx, kube, c.kopsClientset, cluster)
+	tracing.End(span, err)
+	if err != nil {
+		cr.Status.AtProvider.Etcd = nil
+		c.recorder.Event(cr, event.Warning(reasonEtcdStatus, errors.Wrap(err, errGetEtcdStatus)))
+	}
+}
+
+// observeCloudGroups looks at the cloud groups backing the instance groups of
+// a cluster, and returns true if the cluster's instances are all missing.
+func (c *external) observeCloudGroups(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, ig *kopsapi.InstanceGroupList) bool {
+	_, span := tracing.Start(ctx, "GetCloudGroups")
+	cloud, err := c.buildCloud(cluster)
+	var groups map[string]*cloudinstances.CloudInstanceGroup
+	if err == nil {
+		groups, err = util.GetCloudGroups(cloud, cluster, ig)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		c.observeRollingUpdate(cr, nil, err)
+		return false
+	}
+
+	c.observeRollingUpdate(cr, util.GetPendingRollingUpdates(groups), nil)
+	c.observeCapacity(cr, cloud, ig, groups)
+	c.observeCost(cr, cluster, cloud, groups)
+	cr.Status.AtProvider.InstanceConnections = nil
+	if cr.Spec.ForProvider.InstanceConnectionHints {
+		cr.Status.AtProvider.InstanceConnections = util.GetInstanceConnections(groups, cloud.ProviderID() == kopsapi.CloudProviderAWS)
+	}
+	return c.observeInfrastructureMissing(cr, util.InfrastructureMissing(ig, groups))
+}
+
+// observeInfrastructureMissing reports a cluster whose instances are all gone,
+// e.g. because its autoscaling groups were deleted by hand. Such a cluster
+// would otherwise exist but never validate. Instances take a while to appear
+// after an apply, so a recently applied cluster is never considered missing.
+// It returns true if the cluster should be re-applied.
+func (c *external) observeInfrastructureMissing(cr *v1alpha1.Kops, missing bool) bool {
+	if applied := cr.Status.AtProvider.LastAppliedTime; !missing || (applied != nil && time.Since(applied.Time) < infrastructureGracePeriod) {
+		cr.Status.SetConditions(v1alpha1.InfrastructurePresent())
+		return false
+	}
+
+	msg := "the cluster has no instances in the cloud"
+	if cr.Spec.ForProvider.RecreateMissingInfrastructure {
+		msg += "; it will be re-applied"
+	}
+	if cr.Status.GetCondition(v1alpha1.TypeInfrastructureMissing).Status != corev1.ConditionTrue {
+		c.recorder.Event(cr, event.Warning(reasonInfrastructureMissing, errors.New(msg)))
+	}
+	cr.Status.SetConditions(v1alpha1.InfrastructureMissing(msg))
+	return cr.Spec.ForProvider.RecreateMissingInfrastructure
+}
+
+// observeVersionSkew reports whether the state of a cluster was written by a
+// newer version of kops than the provider's. Such state may hold fields the
+// provider doesn't know about, which updating it would silently drop, so the
+// provider refuses to update it. Deleting the cluster is still allowed.
+func (c *external) observeVersionSkew(cr *v1alpha1.Kops, cluster *kopsapi.Cluster) error {
+	v, err := util.GetStateKopsVersion(c.kopsClientset, cluster)
+	if err != nil {
+		return err
+	}
+	cr.Status.AtProvider.StateKopsVersion = v
+
+	skewed, err := util.KopsVersionSkewed(v, kopsversion.Version)
+	if !skewed && err == nil {
+		cr.Status.SetConditions(v1alpha1.VersionCompatible())
+		return nil
+	}
+
+	msg := fmt.Sprintf("the cluster was last applied by kops %s, which is newer than the provider's kops %s; upgrade the provider to manage it", v, kopsversion.Version)
+	if err != nil {
+		msg = fmt.Sprintf("the cluster was last applied by an unknown kops version: %s", err)
+	}
+	if cr.Status.GetCondition(v1alpha1.TypeVersionSkew).Status != corev1.ConditionTrue {
+		c.recorder.Event(cr, event.Warning(reasonVersionSkew, errors.New(msg)))
+	}
+	cr.Status.SetConditions(v1alpha1.VersionSkew(msg))
+	return nil
+}
+
+// observeCAKeyset records the fingerprint of the CA keyset of a cluster. The
+// kubeconfig published as connection details is issued again on every
+// observation and trusts the whole keyset, so once the keyset changes it is
+// republished with the new CA, and cached clients of the cluster that trust
+// the old keyset are replaced.
+func (c *external) observeCAKeyset(cr *v1alpha1.Kops, keyset string) {
+	if prev := cr.Status.AtProvider.CAKeysetFingerprint; prev != "" && prev != keyset {
+		c.recorder.Event(cr, event.Normal(reasonCARotated, "The cluster CA keyset changed; republishing the kubeconfig with the new CA"))
+	}
+	cr.Status.AtProvider.CAKeysetFingerprint = keyset
+}
+
+// observeDeprecatedFields warns about fields of the spec of a Kops resource
+// that kops deprecated, or that its Kubernetes version removed, so that they
+// are replaced before they fail an upgrade part way through its apply.
+func (c *external) observeDeprecatedFields(cr *v1alpha1.Kops) {
+	fields := util.GetDeprecatedFields(&cr.Spec.ForProvider.ClusterSpec)
+	if len(fields) == 0 {
+		cr.Status.SetConditions(v1alpha1.SpecCurrent())
+		return
+	}
+	msg := strings.Join(fields, "; ")
+	// The spec is linted on every poll, so only changes are recorded.
+	if cond := cr.Status.GetCondition(v1alpha1.TypeDeprecatedFields); cond.Status != corev1.ConditionTrue || cond.Message != msg {
+		c.recorder.Event(cr, event.Warning(reasonDeprecatedFields, errors.New(msg)))
+	}
+	cr.Status.SetConditions(v1alpha1.DeprecatedFields(msg))
+}
+
+// observeMissingSubnets reports instance groups in subnets the cluster spec
+// doesn't have, which kops would otherwise only refuse once the cluster is
+// applied.
+func (c *external) observeMissingSubnets(cr *v1alpha1.Kops) {
+	desired := util.DesiredClusterSpec(cr)
+	missing := util.GetMissingSubnets(&desired, util.DesiredInstanceGroupSpecs(cr))
+	if len(missing) == 0 {
+		cr.Status.SetConditions(v1alpha1.SubnetsFound())
+		return
+	}
+	msg := "instance groups are in subnets missing from the cluster spec: " + strings.Join(missing, ", ")
+	if cond := cr.Status.GetCondition(v1alpha1.TypeMissingSubnets); cond.Status != corev1.ConditionTrue || cond.Message != msg {
+		c.recorder.Event(cr, event.Warning(reasonMissingSubnets, errors.New(msg)))
+	}
+	cr.Status.SetConditions(v1alpha1.SubnetsMissing(msg))
+}
+
+// observeIncompleteApply records an apply of a cluster that didn't complete.
+// kops registers a cluster's spec in its state store before applying it, so
+// once an apply is interrupted, e.g. by the provider being restarted, the
+// state matches the spec and diffing them would never resume the apply.
+func (c *external) observeIncompleteApply(cr *v1alpha1.Kops, cluster *kopsapi.Cluster) error {
+	record, err := util.GetApplyInProgress(c.kopsClientset, cluster)
+	if err != nil {
+		return err
+	}
+	if record != nil && (cr.Status.AtProvider.IncompleteApply == nil || !cr.Status.AtProvider.IncompleteApply.StartedTime.Equal(&record.StartedTime)) {
+		c.recorder.Event(cr, event.Warning(reasonIncompleteApply, errors.Errorf("%s apply started at %s did not complete; applying the cluster again", record.Operation, record.StartedTime.UTC().Format(time.RFC3339))))
+	}
+	cr.Status.AtProvider.IncompleteApply = record
+	return nil
+}
+
+// observeRollingUpdate reports instances that run an outdated configuration.
+// The provider applies changes to the cloud but never replaces instances, so
+// without this a cluster would appear synced while its nodes run an old
+// configuration. Whether instances are outdated is unknown if the cloud groups
+// of the cluster could not be read.
+func (c *external) observeRollingUpdate(cr *v1alpha1.Kops, pending map[string]int, err error) {
+	if err != nil {
+		err = errors.Wrap(err, errGetRollingUpdates)
+		c.recorder.Event(cr, event.Warning(reasonRollingUpdate, err))
+		cr.Status.SetConditions(v1alpha1.RollingUpdateUnknown(err))
+		return
+	}
+	if len(pending) == 0 {
+		cr.Status.SetConditions(v1alpha1.NodesUpToDate())
+		return
+	}
+
+	groups := make([]string, 0, len(pending))
+	for name, n := range pending {
+		groups = append(groups, fmt.Sprintf("%s (%d)", name, n))
+	}
+	sort.Strings(groups)
+	msg := "instances need a rolling update: " + strings.Join(groups, ", ")
+
+	if cr.Status.GetCondition(v1alpha1.TypeRollingUpdatePending).Message != msg {
+		c.recorder.Event(cr, event.Warning(reasonRollingUpdate, errors.New(msg)))
+	}
+	cr.Status.SetConditions(v1alpha1.RollingUpdatePending(msg))
+}
+
+// dump collects a diagnostic bundle of a cluster and writes it to the state
+// store. Failing to do so is reported as an event and retried on the next
+// observation.
+func (c *external) dump(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, req string) {
+	sctx, span := tracing.Start(ctx, "Dump")
+	path, err := c.writeDump(sctx, cr, cluster)
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonDump, errors.Wrap(err, errDump)))
+		return
+	}
+	c.recorder.Event(cr, event.Normal(reasonDumped, fmt.Sprintf("Wrote diagnostic bundle to %s", path)))
+	cr.Status.AtProvider.Dump = &v1alpha1.DumpObservation{Request: req, Path: path, Time: metav1.Now()}
+}
+
+func (c *external) writeDump(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) (string, error) {
+	cloud, err := c.buildCloud(cluster)
+	if err != nil {
+		return "", err
+	}
+	res, err := listResources(cloud, cr, cluster)
+	if err != nil {
+		return "", err
+	}
+	d, err := resources.BuildDump(ctx, cloud, res)
+	if err != nil {
+		return "", err
+	}
+	return util.WriteDiagnosticBundle(c.kopsClientset, cluster, &util.DiagnosticBundle{
+		Cluster:    cluster.ObjectMeta.Name,
+		Time:       metav1.Now(),
+		Dump:       d,
+		Validation: cr.Status.AtProvider.Validation,
+	})
+}
+
+// certificateExpiryCondition returns whether the cluster CA certificate of the
+// supplied Kops resource expires within its certificate expiry threshold.
+func certificateExpiryCondition(cr *v1alpha1.Kops, now time.Time) xpv1.Condition {
+	threshold := defaultCertificateExpiryThreshold
+	if t := cr.Spec.ForProvider.CertificateExpiryThreshold; t != nil {
+		threshold = t.Duration
+	}
+
+	expiry := cr.Status.AtProvider.CACertificateExpiry
+	if expiry != nil && expiry.Time.Sub(now) < threshold {
+		return v1alpha1.CertificatesExpiring(fmt.Sprintf("cluster CA certificate expires at %s", expiry.UTC().Format(time.RFC3339)))
+	}
+	return v1alpha1.CertificatesValid()
+}
+
+// recordCertificateIssued emits an event auditing a client certificate with the
+// supplied common name issued for the supplied Kops resource.
+func (c *external) recordCertificateIssued(cr *v1alpha1.Kops, commonName, reason string, ttl time.Duration) {
+	c.recorder.Event(cr, event.Normal(reasonCertificateIssued,
+		fmt.Sprintf("Issued client certificate %q valid for %s for %s", commonName, ttl, reason),
+		"commonName", commonName, "reason", reason))
+}
+
+// connectionDetails returns the connection details for a kubeconfig in the
+// format requested by the Kops resource. Every connection publisher receives
+// the same details, so secrets written to external secret stores have the
+// same keys as in-cluster connection secrets.
+func connectionDetails(cr *v1alpha1.Kops, config *rest.Config, kubeconfig []byte) managed.ConnectionDetails {
+	conn := managed.ConnectionDetails{
+		xpv1.ResourceCredentialsSecretKubeconfigKey: kubeconfig,
+		xpv1.ResourceCredentialsSecretEndpointKey:   []byte(config.Host),
+		xpv1.ResourceCredentialsSecretCAKey:         config.CAData,
+		xpv1.ResourceCredentialsSecretClientCertKey: config.CertData,
+		xpv1.ResourceCredentialsSecretClientKeyKey:  config.KeyData,
+	}
+	if cr.Spec.ForProvider.ConnectionSecretFormat == v1alpha1.ConnectionSecretFormatClusterAPI {
+		conn[capiKubeconfigKey] = kubeconfig
+	}
+	if addr := bastionAddress(cr); addr != "" {
+		conn[bastionConnectionKey] = []byte(addr)
+	}
+	return conn
+}
+
 func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
 	cr, ok := mg.(*v1alpha1.Kops)
 	if !ok {
 		return managed.ExternalCreation{}, errors.New(errNotKops)
 	}
 
-	cluster, err := c.kopsClientset.CreateCluster(ctx, util.CreateClusterSpec(cr))
+	if paused(cr) {
+		return managed.ExternalCreation{}, pausedError()
+	}
+	if err := c.checkDependencies(ctx, cr); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	if cond := cr.Status.GetCondition(v1alpha1.TypeMissingSubnets); cond.Status == corev1.ConditionTrue {
+		return managed.ExternalCreation{}, errors.Wrap(errors.New(cond.Message), errMissingSubnets)
+	}
+	if err := util.CheckClusterOverrides(cr); err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errOverrides)
+	}
+	if err := util.CheckInstanceGroupPatches(cr); err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errInstanceGroupPatches)
+	}
+	if c.readOnly {
+		c.recorder.Event(cr, event.Normal(reasonReadOnly, fmt.Sprintf("Would create cluster %s.%s with %d instance groups", meta.GetExternalName(cr), cr.Spec.ForProvider.Domain, len(util.DesiredInstanceGroupSpecs(cr)))))
+		return managed.ExternalCreation{}, readOnlyError("create the cluster")
+	}
+
+	release, err := c.applies.acquire()
 	if err != nil {
-		return managed.ExternalCreation{}, errors.Wrap(err, errNewClusterState)
+		return managed.ExternalCreation{}, errors.Wrap(err, errApplyWorkers)
 	}
+	defer release()
 
-	for _, ig := range cr.Spec.ForProvider.InstanceGroupSpec {
-		_, err := c.kopsClientset.InstanceGroupsFor(cluster).Create(ctx, util.CreateInstanceGroupSpec(ig), metav1.CreateOptions{})
-		if err != nil {
-			return managed.ExternalCreation{}, errors.Wrap(err, errNewInstanceGroupState)
-		}
+	fileAssets, err := c.getFileAssets(ctx, cr)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	egressProxy, err := c.getEgressProxy(ctx, cr)
+	if err != nil {
+		return managed.ExternalCreation{}, err
 	}
+	spec := util.CreateClusterSpec(cr)
+	util.MergeFileAssets(&spec.Spec, fileAssets)
+	util.MergeEgressProxy(&spec.Spec, egressProxy)
+	util.SetProvenance(spec, cr, time.Now())
+	cluster, err := c.kopsClientset.CreateCluster(ctx, spec)
+	if err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(stateStoreError{err}, errNewClusterState)
+	}
+	if err := util.SetClusterOwner(c.kopsClientset, cluster, util.ClusterOwner{Name: cr.GetName(), UID: string(cr.GetUID())}); err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errClaimCluster)
+	}
+	transition(cr, lifecycleCreate, time.Now())
 
-	cloud, err := cloudup.BuildCloud(cluster)
+	igs := util.DesiredInstanceGroupSpecs(cr)
+	err = forEachInstanceGroup(ctx, igs, func(ctx context.Context, ig *kopsapi.InstanceGroup) error {
+		_, err := c.kopsClientset.InstanceGroupsFor(cluster).Create(ctx, ig, metav1.CreateOptions{})
+		return err
+	})
+	if err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errNewInstanceGroupState)
+	}
+	if err := util.WriteAddons(c.kopsClientset, cluster, cr.Spec.ForProvider.Addons); err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, errWriteAddons)
+	}
+	c.recorder.Event(cr, event.Normal(reasonSpecRegistered, fmt.Sprintf("Registered cluster %s with %d instance groups in the state store", cluster.ObjectMeta.Name, len(igs))))
+
+	_, span := tracing.Start(ctx, "BuildCloud")
+	cloud, err := c.buildCloud(cluster)
+	tracing.End(span, err)
 	if err != nil {
 		return managed.ExternalCreation{}, errors.Wrap(err, errNewCloud)
 	}
@@ -192,20 +1092,67 @@ func (c *external) Create(ctx context.Context, mg resource.Managed) (managed.Ext
 	if err := cloudup.PerformAssignments(cluster, cloud); err != nil {
 		return managed.ExternalCreation{}, errors.Wrap(err, errNewCloudAssignment)
 	}
+	c.recorder.Event(cr, event.Normal(reasonCloudAssigned, "Completed cloud assignments"))
+
+	if err := c.preflight(ctx, cr, cluster, cloud); err != nil {
+		// The cluster is unregistered so that it is created, and checked,
+		// again rather than observed as an existing cluster.
+		if derr := c.unregister(ctx, cluster); derr != nil {
+			return managed.ExternalCreation{}, errors.Wrap(derr, errUnregister)
+		}
+		return managed.ExternalCreation{}, err
+	}
+
+	encryptionConfig, err := c.writeEncryptionConfig(ctx, cr, cluster)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	dockerConfig, err := c.writeDockerConfig(ctx, cr, cluster)
+	if err != nil {
+		return managed.ExternalCreation{}, err
+	}
 
 	applyCmd := &cloudup.ApplyClusterCmd{
 		Cloud:      cloud,
 		Cluster:    cluster,
 		Clientset:  c.kopsClientset,
 		TargetName: cloudup.TargetDirect,
-	}
 
-	err = applyCmd.Run(ctx)
+		LifecycleOverrides: util.GetLifecycleOverrides(cr.Spec.ForProvider.LifecycleOverrides),
+	}
 
+	c.recorder.Event(cr, event.Normal(reasonApplyStarted, "Started applying cluster to cloud"))
+	start := time.Now()
+	sctx, span := tracing.Start(ctx, "ApplyCluster")
+	err = c.runApply(sctx, cr, metrics.OperationCreate, applyCmd)
+	tracing.End(span, err)
+	metrics.RecordApply(cluster.ObjectMeta.Name, metrics.OperationCreate, start)
 	if err != nil {
 		return managed.ExternalCreation{}, errors.Wrap(err, errNewCluster)
 	}
+	c.recorder.Event(cr, event.Normal(reasonApplyFinished, fmt.Sprintf("Finished applying cluster to cloud in %s", time.Since(start).Round(time.Second))))
 
+	if err := recordApplied(cr); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	if err := c.recordWrittenState(ctx, cr, cluster); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	if err := c.recordEncryptionConfig(ctx, cr, cluster, cloud, encryptionConfig); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	if err := c.recordDockerConfig(ctx, cr, cluster, cloud, dockerConfig); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	if err := c.recordCNI(ctx, cr, cluster, cloud); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	if err := c.recordAPIServerSANs(ctx, cr, cluster, cloud); err != nil {
+		return managed.ExternalCreation{}, err
+	}
+	c.replicateState(ctx, cr, cluster)
+	c.observeInfrastructure(ctx, cr, cloud, cluster)
+	c.publishRenderedSpec(ctx, cr, applyCmd)
 	cr.Status.SetConditions(xpv1.Creating())
 
 	return managed.ExternalCreation{
@@ -219,9 +1166,53 @@ func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalUpdate{}, errors.New(errNotKops)
 	}
 
+	if paused(cr) {
+		return managed.ExternalUpdate{}, pausedError()
+	}
+	if err := util.CheckClusterOverrides(cr); err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errOverrides)
+	}
+	if err := util.CheckInstanceGroupPatches(cr); err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errInstanceGroupPatches)
+	}
+	if cond := cr.Status.GetCondition(v1alpha1.TypeVersionSkew); cond.Status == corev1.ConditionTrue {
+		return managed.ExternalUpdate{}, errors.Wrap(errors.New(cond.Message), errVersionSkew)
+	}
+	if cond := cr.Status.GetCondition(v1alpha1.TypeMissingSubnets); cond.Status == corev1.ConditionTrue {
+		return managed.ExternalUpdate{}, errors.Wrap(errors.New(cond.Message), errMissingSubnets)
+	}
+
+	if c.readOnly {
+		return managed.ExternalUpdate{}, c.dryRun(ctx, cr)
+	}
+
+	release, err := c.applies.acquire()
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errApplyWorkers)
+	}
+	defer release()
+
+	fileAssets, err := c.getFileAssets(ctx, cr)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	egressProxy, err := c.getEgressProxy(ctx, cr)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	transition(cr, lifecycleUpdate, time.Now())
 	cluster := util.CreateClusterSpec(cr)
+	util.MergeFileAssets(&cluster.Spec, fileAssets)
+	util.MergeEgressProxy(&cluster.Spec, egressProxy)
+	util.SetProvenance(cluster, cr, time.Now())
+
+	if err := c.backupState(ctx, cr, cluster); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
 
-	cloud, err := cloudup.BuildCloud(cluster)
+	_, span := tracing.Start(ctx, "BuildCloud")
+	cloud, err := c.buildCloud(cluster)
+	tracing.End(span, err)
 	if err != nil {
 		return managed.ExternalUpdate{}, errors.Wrap(err, errNewCloud)
 	}
@@ -229,6 +1220,14 @@ func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 	if err := cloudup.PerformAssignments(cluster, cloud); err != nil {
 		return managed.ExternalUpdate{}, errors.Wrap(err, errNewCloudAssignment)
 	}
+	c.recorder.Event(cr, event.Normal(reasonCloudAssigned, "Completed cloud assignments"))
+
+	// Unlike the other preflight checks this one matters on every update, as
+	// a replaced certificate may not have been issued yet. It runs before the
+	// state is updated so that the update is retried.
+	if _, err := c.checkAPICertificate(ctx, cr, cluster, cloud); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
 
 	status, err := util.GetClusterStatus(cluster, cloud)
 	if err != nil {
@@ -237,14 +1236,46 @@ func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 
 	clusterToUpdate, err := c.kopsClientset.UpdateCluster(ctx, cluster, status)
 	if err != nil {
-		return managed.ExternalUpdate{}, errors.Wrap(err, errUpdateClusterState)
+		return managed.ExternalUpdate{}, errors.Wrap(stateStoreError{err}, errUpdateClusterState)
 	}
 
-	for _, ig := range cr.Spec.ForProvider.InstanceGroupSpec {
-		_, err := c.kopsClientset.InstanceGroupsFor(clusterToUpdate).Update(ctx, util.CreateInstanceGroupSpec(ig), metav1.UpdateOptions{})
-		if err != nil {
-			return managed.ExternalUpdate{}, errors.Wrap(err, errNewInstanceGroupState)
+	existing, err := c.kopsClientset.InstanceGroupsFor(clusterToUpdate).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errGetInstanceGroup)
+	}
+	stored := map[string]bool{}
+	for _, ig := range existing.Items {
+		stored[ig.Name] = true
+	}
+	igs := util.DesiredInstanceGroupSpecs(cr)
+	err = forEachInstanceGroup(ctx, igs, func(ctx context.Context, ig *kopsapi.InstanceGroup) error {
+		// Instance groups added to the spec, e.g. a newly enabled bastion,
+		// are created.
+		if !stored[ig.Name] {
+			_, err := c.kopsClientset.InstanceGroupsFor(clusterToUpdate).Create(ctx, ig, metav1.CreateOptions{})
+			return err
 		}
+		_, err := c.kopsClientset.InstanceGroupsFor(clusterToUpdate).Update(ctx, ig, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errNewInstanceGroupState)
+	}
+	if err := c.removeBastion(ctx, cr, clusterToUpdate, cloud, util.GetRemovedBastions(igs, existing)); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	if err := util.WriteAddons(c.kopsClientset, clusterToUpdate, cr.Spec.ForProvider.Addons); err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, errWriteAddons)
+	}
+	c.recorder.Event(cr, event.Normal(reasonSpecRegistered, fmt.Sprintf("Updated cluster %s with %d instance groups in the state store", clusterToUpdate.ObjectMeta.Name, len(igs))))
+
+	encryptionConfig, err := c.writeEncryptionConfig(ctx, cr, clusterToUpdate)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	dockerConfig, err := c.writeDockerConfig(ctx, cr, clusterToUpdate)
+	if err != nil {
+		return managed.ExternalUpdate{}, err
 	}
 
 	applyCmd := &cloudup.ApplyClusterCmd{
@@ -252,48 +1283,342 @@ func (c *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 		Cluster:    clusterToUpdate,
 		Clientset:  c.kopsClientset,
 		TargetName: cloudup.TargetDirect,
+
+		LifecycleOverrides: util.GetLifecycleOverrides(cr.Spec.ForProvider.LifecycleOverrides),
 	}
 
-	err = applyCmd.Run(ctx)
+	clusterEvent := c.clusterEvents(ctx, cr, clusterToUpdate)
+	c.recorder.Event(cr, event.Normal(reasonApplyStarted, "Started applying cluster to cloud"))
+	if pending := cr.Status.AtProvider.PendingReplacements; len(pending) > 0 {
+		c.recorder.Event(cr, event.Normal(reasonReplacingInstances, fmt.Sprintf("Applying changes that replace the instances of instance groups %s", strings.Join(pending, ", "))))
+	}
+	clusterEvent(string(reasonApplyStarted), "provider-kops started applying changes to the cluster; instances may be replaced")
+	start := time.Now()
+	sctx, span := tracing.Start(ctx, "ApplyCluster")
+	err = c.runApply(sctx, cr, metrics.OperationUpdate, applyCmd)
+	tracing.End(span, err)
+	metrics.RecordApply(clusterToUpdate.ObjectMeta.Name, metrics.OperationUpdate, start)
 	if err != nil {
 		return managed.ExternalUpdate{}, errors.Wrap(err, errUpdateCluster)
 	}
+	c.recorder.Event(cr, event.Normal(reasonApplyFinished, fmt.Sprintf("Finished applying cluster to cloud in %s", time.Since(start).Round(time.Second))))
+	clusterEvent(string(reasonApplyFinished), "provider-kops finished applying changes to the cluster")
+	c.notify(ctx, cr, clusterToUpdate, util.NotificationUpdateApplied, fmt.Sprintf("Applied generation %d of the cluster", cr.GetGeneration()))
+
+	if err := recordApplied(cr); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	if err := c.recordWrittenState(ctx, cr, clusterToUpdate); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	if err := c.recordEncryptionConfig(ctx, cr, clusterToUpdate, cloud, encryptionConfig); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	if err := c.recordDockerConfig(ctx, cr, clusterToUpdate, cloud, dockerConfig); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	if err := c.recordCNI(ctx, cr, clusterToUpdate, cloud); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	if err := c.recordAPIServerSANs(ctx, cr, clusterToUpdate, cloud); err != nil {
+		return managed.ExternalUpdate{}, err
+	}
+	c.replicateState(ctx, cr, clusterToUpdate)
+	c.observeInfrastructure(ctx, cr, cloud, clusterToUpdate)
+	c.publishRenderedSpec(ctx, cr, applyCmd)
 
 	return managed.ExternalUpdate{
 		ConnectionDetails: managed.ConnectionDetails{},
 	}, nil
 }
 
+// recordApplied records the spec of the supplied Kops resource as having been
+// applied to the cloud, so that users can tell whether a change has been
+// rolled out.
+func recordApplied(cr *v1alpha1.Kops) error {
+	hash, err := util.HashParameters(cr.Spec.ForProvider)
+	if err != nil {
+		return errors.Wrap(err, errHashParameters)
+	}
+	now := metav1.Now()
+	cr.Status.AtProvider.LastAppliedTime = &now
+	cr.Status.AtProvider.LastAppliedGeneration = cr.GetGeneration()
+	cr.Status.AtProvider.LastAppliedSpecHash = hash
+	cr.Status.AtProvider.KopsVersion = kopsversion.Version
+	cr.Status.AtProvider.IncompleteApply = nil
+	cr.Status.AtProvider.ApplyProgress = nil
+	transition(cr, lifecycleApplied, now.Time)
+	return nil
+}
+
+// runApply runs the supplied apply command as part of the supplied operation.
+// The apply is recorded in the state store until it completes, so that it is
+// resumed if it fails or the provider is stopped while it runs.
+func (c *external) runApply(ctx context.Context, cr *v1alpha1.Kops, op string, applyCmd *cloudup.ApplyClusterCmd) error {
+	now := time.Now()
+	// The mark is written before applying, so that a cluster whose apply
+	// failed part way is known to possibly have cloud resources.
+	if err := util.MarkApplyStarted(c.kopsClientset, applyCmd.Cluster, now); err != nil {
+		return errors.Wrap(err, errMarkApplied)
+	}
+	record := v1alpha1.ApplyRecord{Operation: op, Generation: cr.GetGeneration(), StartedTime: metav1.NewTime(now)}
+	if err := util.MarkApplyInProgress(c.kopsClientset, applyCmd.Cluster, record); err != nil {
+		return errors.Wrap(err, errMarkApplyInProgress)
+	}
+
+	stop := c.watchApplyProgress(ctx, cr, applyCmd.Cluster)
+	err := c.apply(ctx, cr, applyCmd)
+	stop()
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(util.ClearApplyInProgress(c.kopsClientset, applyCmd.Cluster), errClearApplyInProgress)
+}
+
+// apply runs the supplied apply command against the target of the supplied
+// Kops resource. Terraform is rendered to a temporary directory and then
+// copied to the resource's Terraform output path.
+func (c *external) apply(ctx context.Context, cr *v1alpha1.Kops, applyCmd *cloudup.ApplyClusterCmd) error {
+	if cr.Spec.ForProvider.Target != v1alpha1.ApplyTargetTerraform {
+		return c.applier.Apply(ctx, applyCmd)
+	}
+
+	dir, err := os.MkdirTemp("", "provider-kops-terraform-")
+	if err != nil {
+		return errors.Wrap(err, errRenderTerraform)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	applyCmd.TargetName = cloudup.TargetTerraform
+	applyCmd.OutDir = dir
+	if err := c.applier.Apply(ctx, applyCmd); err != nil {
+		return err
+	}
+
+	dest, err := util.GetTerraformOutputPath(c.kopsClientset, applyCmd.Cluster, cr.Spec.ForProvider.TerraformOutputPath)
+	if err != nil {
+		return errors.Wrap(err, errRenderTerraform)
+	}
+	if err := util.CopyDirToVFS(dir, dest); err != nil {
+		return errors.Wrap(err, errRenderTerraform)
+	}
+
+	cr.Status.AtProvider.TerraformOutputPath = dest.Path()
+	c.recorder.Event(cr, event.Normal(reasonRenderedTerraform, fmt.Sprintf("Rendered Terraform to %s", dest.Path())))
+	return nil
+}
+
+// clusterEvents returns a function that records events in the supplied
+// cluster if the supplied Kops resource asks for it. Cluster events are
+// informational, so failing to record them is reported as an event on the
+// Kops resource rather than an error.
+func (c *external) clusterEvents(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) func(reason, message string) {
+	if !cr.Spec.ForProvider.RecordClusterEvents {
+		return func(_, _ string) {}
+	}
+
+	commonName := util.KubeconfigCommonName(cluster.GetName(), cr.GetUID())
+	config, err := util.GetKubeconfigFromKopsState(cluster, c.kopsClientset, commonName, util.CertificateReasonClusterEvents)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonClusterEvent, errors.Wrap(err, errRecordClusterEvent)))
+		return func(_, _ string) {}
+	}
+	c.recordCertificateIssued(cr, commonName, util.CertificateReasonClusterEvents, util.KubeconfigCertificateTTL)
+
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonClusterEvent, errors.Wrap(err, errRecordClusterEvent)))
+		return func(_, _ string) {}
+	}
+
+	return func(reason, message string) {
+		if err := util.RecordClusterEvent(ctx, kube, reason, message); err != nil {
+			c.recorder.Event(cr, event.Warning(reasonClusterEvent, errors.Wrap(err, errRecordClusterEvent)))
+		}
+	}
+}
+
+// observeInfrastructure records the identifiers of the cloud infrastructure of
+// an applied cluster. The cluster has already been applied at this point, so
+// failing to list its resources is reported as an event rather than an error.
+func (c *external) observeInfrastructure(ctx context.Context, cr *v1alpha1.Kops, cloud fi.Cloud, cluster *kopsapi.Cluster) {
+	// Rendered Terraform may not have been applied yet.
+	if cr.Spec.ForProvider.Target == v1alpha1.ApplyTargetTerraform {
+		return
+	}
+
+	_, span := tracing.Start(ctx, "ListResources")
+	res, err := listResources(cloud, cr, cluster)
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonListResources, errors.Wrap(err, errListResources)))
+		return
+	}
+	cr.Status.AtProvider.Infrastructure = util.GenerateInfrastructureObservation(res)
+}
+
+// publishRenderedSpec writes the cluster and instance group specs populated by
+// an apply to the rendered spec secret, if one is configured. The cluster has
+// already been applied at this point, so failing to publish is reported as an
+// event rather than an error.
+func (c *external) publishRenderedSpec(ctx context.Context, cr *v1alpha1.Kops, applyCmd *cloudup.ApplyClusterCmd) {
+	ref := cr.Spec.ForProvider.RenderedSpecSecretRef
+	if ref == nil {
+		return
+	}
+
+	b, err := util.RenderClusterYAML(applyCmd.Cluster, applyCmd.InstanceGroups)
+	if err == nil {
+		s := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       ref.Namespace,
+				Name:            ref.Name,
+				OwnerReferences: []metav1.OwnerReference{meta.AsController(meta.TypedReferenceTo(cr, v1alpha1.KopsGroupVersionKind))},
+			},
+			Data: map[string][]byte{renderedSpecKey: b},
+		}
+		err = c.secret.Apply(ctx, s, resource.MustBeControllableBy(cr.GetUID()))
+	}
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonRenderedSpec, errors.Wrap(err, errPublishRenderedSpec)))
+	}
+}
+
 func (c *external) Delete(ctx context.Context, mg resource.Managed) error {
 	cr, ok := mg.(*v1alpha1.Kops)
 	if !ok {
 		return errors.New(errNotKops)
 	}
+
+	if paused(cr) {
+		return pausedError()
+	}
+	if c.readOnly {
+		c.recorder.Event(cr, event.Normal(reasonReadOnly, fmt.Sprintf("Would delete cluster %s.%s and its cloud resources", meta.GetExternalName(cr), cr.Spec.ForProvider.Domain)))
+		return readOnlyError("delete the cluster")
+	}
+
+	release, err := c.applies.acquire()
+	if err != nil {
+		return errors.Wrap(err, errApplyWorkers)
+	}
+	defer release()
+
+	transition(cr, lifecycleDelete, time.Now())
 	cluster, err := c.kopsClientset.GetCluster(ctx, fmt.Sprintf("%v.%v", meta.GetExternalName(cr), cr.Spec.ForProvider.Domain))
 	if err != nil {
 		return errors.Wrap(err, errGetCluster)
 	}
 
-	cloud, err := cloudup.BuildCloud(cluster)
-	if err != nil {
-		return errors.Wrap(err, errDeleteCluster)
+	if err := c.backupState(ctx, cr, cluster); err != nil {
+		return err
 	}
 
-	allResources, err := resourceops.ListResources(cloud, cluster, cr.Spec.ForProvider.Region)
+	// A cluster that was never applied has no cloud resources, so there's no
+	// need to build its cloud, which may not even be possible, to list them.
+	applied, err := util.ClusterEverApplied(c.kopsClientset, cluster)
 	if err != nil {
+		return errors.Wrap(err, errGetApplied)
+	}
+
+	neverApplied := !applied && cr.Status.AtProvider.LastAppliedTime == nil
+	if err := c.preDelete(ctx, cr, cluster, !neverApplied && cr.Spec.ForProvider.Target != v1alpha1.ApplyTargetTerraform); err != nil {
 		return err
 	}
 
-	err = resourceops.DeleteResources(cloud, allResources)
-	if err != nil {
-		return errors.Wrap(err, errDeleteResources)
+	start := time.Now()
+	switch {
+	case neverApplied:
+		c.recorder.Event(cr, event.Normal(reasonSkippedResources, "Cluster was never applied to the cloud, so it has no cloud resources to delete"))
+	case cr.Spec.ForProvider.Target == v1alpha1.ApplyTargetTerraform:
+		c.recorder.Event(cr, event.Normal(reasonSkippedResources, "Cloud resources are managed by Terraform and were not deleted"))
+	default:
+		if err := c.deleteResources(ctx, cr, cluster); err != nil {
+			return err
+		}
+	}
+
+	// kops refuses to delete state it does not recognise, so the files
+	// written by the provider are removed first. They remain in the backup.
+	if err := util.RemoveProviderState(c.kopsClientset, cluster); err != nil {
+		return errors.Wrap(err, errDeleteCluster)
 	}
 
 	err = c.kopsClientset.DeleteCluster(ctx, cluster)
 	if err != nil {
 		return errors.Wrap(err, errDeleteCluster)
 	}
+	metrics.RecordDelete(cluster.ObjectMeta.Name, start)
+	c.recorder.Event(cr, event.Normal(reasonDeletedState, "Deleted cluster from the state store"))
 	cr.Status.SetConditions(xpv1.Deleting())
+	c.notify(ctx, cr, cluster, util.NotificationDeletionComplete, "Cluster was deleted")
+
+	return nil
+}
 
+// backupState copies the state of the supplied cluster to a backup path before
+// it is changed, recording the path in the status of the Kops resource so that
+// a bad apply or delete can be recovered from.
+func (c *external) backupState(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) error {
+	_, span := tracing.Start(ctx, "BackupState")
+	path, err := util.BackupClusterState(c.kopsClientset, cluster, cr.Spec.ForProvider.StateBucket, time.Now())
+	tracing.End(span, err)
+	if err != nil {
+		return errors.Wrap(err, errBackupState)
+	}
+	cr.Status.AtProvider.StateBackupPath = path
+	c.recorder.Event(cr, event.Normal(reasonBackedUpState, fmt.Sprintf("Backed up cluster state to %s", path)))
+	return nil
+}
+
+// listResources lists the cloud resources of the supplied cluster in every
+// region its subnets are in. The region is taken from the cluster rather than
+// the region parameter, which may be wrong or empty, so that no resources are
+// missed; the parameter is only used for clusters without zoned subnets.
+func listResources(cloud fi.Cloud, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) (map[string]*resources.Resource, error) {
+	regions := util.GetClusterRegions(cluster, cr.Spec.ForProvider.Region)
+	if len(regions) == 0 {
+		return resourceops.ListResources(cloud, cluster, "")
+	}
+	res := map[string]*resources.Resource{}
+	for _, region := range regions {
+		r, err := resourceops.ListResources(cloud, cluster, region)
+		if err != nil {
+			return nil, errors.Wrapf(err, errListRegionResources, region)
+		}
+		for k, v := range r {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
+
+// deleteResources deletes the cloud resources of the supplied cluster.
+func (c *external) deleteResources(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) error {
+	_, span := tracing.Start(ctx, "BuildCloud")
+	cloud, err := c.buildCloud(cluster)
+	tracing.End(span, err)
+	if err != nil {
+		return errors.Wrap(err, errDeleteCluster)
+	}
+
+	_, span = tracing.Start(ctx, "ListResources")
+	allResources, err := listResources(cloud, cr, cluster)
+	tracing.End(span, err)
+	if err != nil {
+		return err
+	}
+
+	if n := util.RetainResources(allResources, cr.Spec.ForProvider.LifecycleOverrides); n > 0 {
+		c.recorder.Event(cr, event.Normal(reasonRetainingResources, fmt.Sprintf("Retaining %d cloud resources owned by another system per lifecycle overrides", n)))
+	}
+	c.recorder.Event(cr, event.Normal(reasonDeletingResources, fmt.Sprintf("Deleting %d cloud resources", len(allResources))))
+	_, span = tracing.Start(ctx, "DeleteResources")
+	err = resourceops.DeleteResources(cloud, allResources)
+	tracing.End(span, err)
+	if err != nil {
+		return errors.Wrap(err, errDeleteResources)
+	}
+	c.recorder.Event(cr, event.Normal(reasonDeletedResources, fmt.Sprintf("Deleted %d cloud resources", len(allResources))))
 	return nil
 }
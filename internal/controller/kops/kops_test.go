@@ -17,14 +17,71 @@ limitations under the License.
 package kops
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	kopsClient "k8s.io/kops/pkg/client/simple"
+	"k8s.io/kops/pkg/client/simple/vfsclientset"
+	"k8s.io/kops/pkg/validation"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/util/pkg/vfs"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection"
+	"github.com/crossplane/crossplane-runtime/pkg/connection/fake"
+	connstore "github.com/crossplane/crossplane-runtime/pkg/connection/store"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/feature"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	resourcefake "github.com/crossplane/crossplane-runtime/pkg/resource/fake"
 	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/provider-kops/apis"
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	apisv1alpha1 "github.com/crossplane/provider-kops/apis/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/clients"
+	clientsfake "github.com/crossplane/provider-kops/internal/clients/fake"
+	"github.com/crossplane/provider-kops/internal/controller/features"
+	"github.com/crossplane/provider-kops/internal/metrics"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
 )
 
 // Unlike many Kubernetes projects Crossplane does not use third party testing
@@ -36,8 +93,10 @@ import (
 // https://github.com/crossplane/crossplane/blob/master/CONTRIBUTING.md#contributing-code
 
 func TestObserve(t *testing.T) {
+	errBoom := errors.New("boom")
+
 	type fields struct {
-		service interface{}
+		clientset kopsClient.Clientset
 	}
 
 	type args struct {
@@ -56,12 +115,67 @@ func TestObserve(t *testing.T) {
 		args   args
 		want   want
 	}{
-		// TODO: Add test cases.
+		"NotKops": {
+			reason: "We should return an error if the managed resource is not a Kops resource.",
+			args: args{
+				ctx: context.Background(),
+				mg:  &resourcefake.Managed{},
+			},
+			want: want{
+				err: errors.New(errNotKops),
+			},
+		},
+		"Paused": {
+			reason: "A paused Kops resource should be left alone unless its connection details are published while paused.",
+			args: args{
+				ctx: context.Background(),
+				mg: &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+					v1alpha1.AnnotationKeyPaused: "true",
+				}}},
+			},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: true, ResourceUpToDate: true},
+			},
+		},
+		"ClusterNotFound": {
+			reason: "A cluster missing from the state store should not exist.",
+			fields: fields{
+				clientset: &clientsfake.Clientset{
+					MockGetCluster: func(_ context.Context, name string) (*kopsapi.Cluster, error) {
+						return nil, errors.Errorf("cluster %q not found", name)
+					},
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				mg:  &v1alpha1.Kops{},
+			},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"GetClusterFailed": {
+			reason: "We should return an error if the cluster can't be read from the state store.",
+			fields: fields{
+				clientset: &clientsfake.Clientset{
+					MockGetCluster: func(_ context.Context, _ string) (*kopsapi.Cluster, error) {
+						return nil, errBoom
+					},
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				mg:  &v1alpha1.Kops{},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errGetCluster),
+			},
+		},
 	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			e := external{service: tc.fields.service}
+			e := external{kopsClientset: tc.fields.clientset, recorder: event.NewNopRecorder()}
 			got, err := e.Observe(tc.args.ctx, tc.args.mg)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("\n%s\ne.Observe(...): -want error, +got error:\n%s\n", tc.reason, diff)
@@ -72,3 +186,2508 @@ func TestObserve(t *testing.T) {
 		})
 	}
 }
+
+func TestCreate(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	// Cluster state that isn't mocked is kept in memory.
+	store := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+
+	type fields struct {
+		clientset kopsClient.Clientset
+		builder   clients.CloudBuilder
+		readOnly  bool
+	}
+
+	type args struct {
+		ctx context.Context
+		mg  resource.Managed
+	}
+
+	type want struct {
+		o      managed.ExternalCreation
+		events []event.Event
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		fields fields
+		args   args
+		want   want
+	}{
+		"NotKops": {
+			reason: "We should return an error if the managed resource is not a Kops resource.",
+			args: args{
+				ctx: context.Background(),
+				mg:  &resourcefake.Managed{},
+			},
+			want: want{
+				err: errors.New(errNotKops),
+			},
+		},
+		"Paused": {
+			reason: "The cluster of a paused Kops resource should not be created.",
+			args: args{
+				ctx: context.Background(),
+				mg: &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+					v1alpha1.AnnotationKeyPaused: "true",
+				}}},
+			},
+			want: want{
+				err: pausedError(),
+			},
+		},
+		"ReadOnly": {
+			reason: "The cluster should not be created while the provider is read-only.",
+			fields: fields{
+				clientset: &clientsfake.Clientset{
+					Clientset: store,
+					MockCreateCluster: func(_ context.Context, _ *kopsapi.Cluster) (*kopsapi.Cluster, error) {
+						return nil, errBoom
+					},
+				},
+				readOnly: true,
+			},
+			args: args{
+				ctx: context.Background(),
+				mg:  &v1alpha1.Kops{},
+			},
+			want: want{
+				events: []event.Event{event.Normal(reasonReadOnly, "Would create cluster . with 0 instance groups")},
+				err:    readOnlyError("create the cluster"),
+			},
+		},
+		"CreateClusterStateFailed": {
+			reason: "We should return an error if the cluster can't be written to the state store.",
+			fields: fields{
+				clientset: &clientsfake.Clientset{
+					Clientset: store,
+					MockCreateCluster: func(_ context.Context, _ *kopsapi.Cluster) (*kopsapi.Cluster, error) {
+						return nil, errBoom
+					},
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				mg:  &v1alpha1.Kops{},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errNewClusterState),
+			},
+		},
+		"BuildCloudFailed": {
+			reason: "We should return an error if the cloud of the cluster can't be built, after recording that its spec was registered.",
+			fields: fields{
+				clientset: &clientsfake.Clientset{
+					Clientset: store,
+					MockCreateCluster: func(_ context.Context, _ *kopsapi.Cluster) (*kopsapi.Cluster, error) {
+						return &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test.example.org"}}, nil
+					},
+				},
+				builder: &clientsfake.CloudBuilder{
+					MockBuildCloud: func(_ *kopsapi.Cluster) (fi.Cloud, error) {
+						return nil, errBoom
+					},
+				},
+			},
+			args: args{
+				ctx: context.Background(),
+				mg:  &v1alpha1.Kops{},
+			},
+			want: want{
+				events: []event.Event{event.Normal(reasonSpecRegistered, "Registered cluster test.example.org with 0 instance groups in the state store")},
+				err:    errors.Wrap(errBoom, errNewCloud),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := &eventRecorder{}
+			e := external{kopsClientset: tc.fields.clientset, builder: tc.fields.builder, recorder: rec, readOnly: tc.fields.readOnly}
+			got, err := e.Create(tc.args.ctx, tc.args.mg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.o, got); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.events); diff != "" {
+				t.Errorf("\n%s\ne.Create(...): -want events, +got events:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConnectionDetails(t *testing.T) {
+	kubeconfig := []byte("kubeconfig")
+	config := &rest.Config{
+		Host: "https://api.foo.example.com",
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData:   []byte("ca"),
+			CertData: []byte("cert"),
+			KeyData:  []byte("key"),
+		},
+	}
+	base := managed.ConnectionDetails{
+		xpv1.ResourceCredentialsSecretKubeconfigKey: kubeconfig,
+		xpv1.ResourceCredentialsSecretEndpointKey:   []byte("https://api.foo.example.com"),
+		xpv1.ResourceCredentialsSecretCAKey:         []byte("ca"),
+		xpv1.ResourceCredentialsSecretClientCertKey: []byte("cert"),
+		xpv1.ResourceCredentialsSecretClientKeyKey:  []byte("key"),
+	}
+	withCAPI := managed.ConnectionDetails{capiKubeconfigKey: kubeconfig}
+	for k, v := range base {
+		withCAPI[k] = v
+	}
+
+	cases := map[string]struct {
+		reason string
+		format v1alpha1.ConnectionSecretFormat
+		want   managed.ConnectionDetails
+	}{
+		"Default": {
+			reason: "The kubeconfig and its endpoint and credentials should be published under the Crossplane keys by default.",
+			want:   base,
+		},
+		"ClusterAPI": {
+			reason: "The kubeconfig should also be published under the Cluster API key.",
+			format: v1alpha1.ConnectionSecretFormatClusterAPI,
+			want:   withCAPI,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{}
+			cr.Spec.ForProvider.ConnectionSecretFormat = tc.format
+			got := connectionDetails(cr, config, kubeconfig)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nconnectionDetails(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConnectionPublishers(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+
+	cr := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "example", UID: "uid"}}
+	cr.SetWriteConnectionSecretToReference(&xpv1.SecretReference{Namespace: "crossplane-system", Name: "example"})
+	cr.SetPublishConnectionDetailsTo(&xpv1.PublishConnectionDetailsTo{
+		Name:                 "example",
+		SecretStoreConfigRef: &xpv1.Reference{Name: "vault"},
+	})
+	cr.Spec.AdditionalConnectionSecretRefs = []xpv1.SecretReference{{Namespace: "gitops", Name: "example"}}
+
+	conn := connectionDetails(cr, &rest.Config{
+		Host:            "https://api.foo.example.com",
+		TLSClientConfig: rest.TLSClientConfig{CAData: []byte("ca"), CertData: []byte("cert"), KeyData: []byte("key")},
+	}, []byte("kubeconfig"))
+
+	published := map[string]map[string][]byte{}
+	kube := &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+			if _, ok := obj.(*corev1.Secret); ok {
+				return kerrors.NewNotFound(corev1.Resource("secrets"), "")
+			}
+			return nil
+		},
+		MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+			sec := obj.(*corev1.Secret)
+			published[sec.Namespace] = sec.Data
+			return nil
+		},
+		MockScheme: test.NewMockSchemeFn(s),
+	}
+	store := &fake.SecretStore{
+		WriteKeyValuesFn: func(_ context.Context, sec *connstore.Secret, _ ...connstore.WriteOption) (bool, error) {
+			published["store"] = sec.Data
+			return true, nil
+		},
+	}
+	f := &feature.Flags{}
+	f.Enable(features.EnableAlphaExternalSecretStores)
+
+	cps := connectionPublishers(kube, s, f, connection.WithStoreBuilder(func(_ context.Context, _ client.Client, _ xpv1.SecretStoreConfig) (connection.Store, error) {
+		return store, nil
+	}))
+	for _, cp := range cps {
+		if _, err := cp.PublishConnection(context.Background(), cr, conn); err != nil {
+			t.Fatalf("PublishConnection(...): %s", err)
+		}
+	}
+
+	want := map[string]map[string][]byte{
+		"crossplane-system": conn,
+		"gitops":            conn,
+		"store":             conn,
+	}
+	if diff := cmp.Diff(want, published); diff != "" {
+		t.Errorf("\nAll publishers should publish the same connection details.\nPublishConnection(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestSecretMirrorPublisher(t *testing.T) {
+	cr := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "example", UID: "uid"}}
+	cr.Spec.AdditionalConnectionSecretRefs = []xpv1.SecretReference{{Namespace: "gitops", Name: "example"}}
+	owner := meta.AsController(meta.TypedReferenceTo(cr, v1alpha1.KopsGroupVersionKind))
+	conn := managed.ConnectionDetails{"kubeconfig": []byte("new")}
+
+	secret := func(data string, owners ...metav1.OwnerReference) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "gitops", Name: "example", OwnerReferences: owners},
+			Type:       resource.SecretTypeConnection,
+			Data:       map[string][]byte{"kubeconfig": []byte(data)},
+		}
+	}
+
+	type want struct {
+		published bool
+		secret    *corev1.Secret
+		err       error
+	}
+
+	cases := map[string]struct {
+		reason   string
+		existing *corev1.Secret
+		want     want
+	}{
+		"Create": {
+			reason: "A missing mirrored secret should be created, controlled by the Kops resource so that it is garbage collected with it.",
+			want: want{
+				published: true,
+				secret:    secret("new", owner),
+			},
+		},
+		"Update": {
+			reason:   "A mirrored secret with stale connection details should be updated.",
+			existing: secret("old", owner),
+			want: want{
+				published: true,
+				secret:    secret("new", owner),
+			},
+		},
+		"Unchanged": {
+			reason:   "A mirrored secret with current connection details should be left alone.",
+			existing: secret("new", owner),
+		},
+		"NotControllable": {
+			reason:   "A secret controlled by another resource should not be overwritten.",
+			existing: secret("old", metav1.OwnerReference{APIVersion: "v1", Kind: "Other", Name: "other", UID: "other", Controller: func() *bool { b := true; return &b }()}),
+			want: want{
+				err: errors.Wrapf(errors.New("existing secret is not controlled by UID \"uid\""), errPublishAdditionalSecret, "gitops", "example"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *corev1.Secret
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+					if tc.existing == nil {
+						return kerrors.NewNotFound(corev1.Resource("secrets"), "example")
+					}
+					tc.existing.DeepCopyInto(obj.(*corev1.Secret))
+					return nil
+				},
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+					got = obj.(*corev1.Secret).DeepCopy()
+					return nil
+				},
+				// The patch holds the desired secret.
+				MockPatch: func(_ context.Context, obj client.Object, patch client.Patch, _ ...client.PatchOption) error {
+					b, err := patch.Data(obj)
+					if err != nil {
+						return err
+					}
+					got = &corev1.Secret{}
+					return json.Unmarshal(b, got)
+				},
+			}
+
+			p := newSecretMirrorPublisher(kube)
+			published, err := p.PublishConnection(context.Background(), cr, conn)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\np.PublishConnection(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.published, published); diff != "" {
+				t.Errorf("\n%s\np.PublishConnection(...): -want published, +got published:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.secret, got); diff != "" {
+				t.Errorf("\n%s\np.PublishConnection(...): -want secret, +got secret:\n%s\n", tc.reason, diff)
+			}
+
+			// Mirrored secrets are removed by garbage collection, not by
+			// unpublishing.
+			got = nil
+			if err := p.UnpublishConnection(context.Background(), cr, conn); err != nil {
+				t.Errorf("\n%s\np.UnpublishConnection(...): %v\n", tc.reason, err)
+			}
+			if got != nil {
+				t.Errorf("\n%s\np.UnpublishConnection(...): want no writes, got %v\n", tc.reason, got)
+			}
+		})
+	}
+}
+
+func TestRenderPublishConnectionDetailsTo(t *testing.T) {
+	type want struct {
+		name string
+		err  error
+	}
+
+	cases := map[string]struct {
+		reason string
+		name   string
+		want   want
+	}{
+		"Plain": {
+			reason: "Names without a template should be left as is.",
+			name:   "example",
+			want:   want{name: "example"},
+		},
+		"Template": {
+			reason: "Templated names should be rendered per cluster.",
+			name:   "kops/{{ .ClusterName }}/kubeconfig",
+			want:   want{name: "kops/foo.example.com/kubeconfig"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+			meta.SetExternalName(cr, "foo")
+			cr.Spec.ForProvider.Domain = "example.com"
+			cr.SetPublishConnectionDetailsTo(&xpv1.PublishConnectionDetailsTo{Name: tc.name})
+
+			got, err := renderPublishConnectionDetailsTo(cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nrenderPublishConnectionDetailsTo(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.name, got.GetPublishConnectionDetailsTo().Name); diff != "" {
+				t.Errorf("\n%s\nrenderPublishConnectionDetailsTo(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if cr.GetPublishConnectionDetailsTo().Name != tc.name {
+				t.Errorf("\n%s\nrenderPublishConnectionDetailsTo(...): must not modify the supplied resource", tc.reason)
+			}
+		})
+	}
+}
+
+func TestCertificateExpiryCondition(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.Kops
+		want   xpv1.Condition
+	}{
+		"Unknown": {
+			reason: "A cluster whose CA expiry is unknown should not be considered expiring.",
+			cr:     &v1alpha1.Kops{},
+			want:   v1alpha1.CertificatesValid(),
+		},
+		"DefaultThreshold": {
+			reason: "A CA expiring within the default threshold should be considered expiring.",
+			cr: &v1alpha1.Kops{Status: v1alpha1.KopsStatus{AtProvider: v1alpha1.KopsObservation{
+				CACertificateExpiry: &metav1.Time{Time: now.Add(7 * 24 * time.Hour)},
+			}}},
+			want: v1alpha1.CertificatesExpiring("cluster CA certificate expires at 2022-06-08T00:00:00Z"),
+		},
+		"CustomThreshold": {
+			reason: "A CA expiring after the configured threshold should not be considered expiring.",
+			cr: &v1alpha1.Kops{
+				Spec: v1alpha1.KopsSpec{ForProvider: v1alpha1.KopsParameters{
+					CertificateExpiryThreshold: &metav1.Duration{Duration: 24 * time.Hour},
+				}},
+				Status: v1alpha1.KopsStatus{AtProvider: v1alpha1.KopsObservation{
+					CACertificateExpiry: &metav1.Time{Time: now.Add(7 * 24 * time.Hour)},
+				}},
+			},
+			want: v1alpha1.CertificatesValid(),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := certificateExpiryCondition(tc.cr, now)
+			if diff := cmp.Diff(tc.want, got, test.EquateConditions()); diff != "" {
+				t.Errorf("\n%s\ncertificateExpiryCondition(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestObserveRollingUpdate(t *testing.T) {
+	errBoom := errors.New("boom")
+	pendingMsg := "instances need a rolling update: master-us-east-1a (1), nodes (2)"
+	with := func(c xpv1.Condition) *v1alpha1.Kops {
+		cr := &v1alpha1.Kops{}
+		cr.Status.SetConditions(c)
+		return cr
+	}
+
+	type want struct {
+		c      xpv1.Condition
+		events int
+	}
+
+	cases := map[string]struct {
+		reason  string
+		cr      *v1alpha1.Kops
+		pending map[string]int
+		err     error
+		want    want
+	}{
+		"UpToDate": {
+			reason: "A cluster without outdated instances should not need a rolling update.",
+			cr:     &v1alpha1.Kops{},
+			want:   want{c: v1alpha1.NodesUpToDate()},
+		},
+		"BecomesPending": {
+			reason:  "Outdated instances should set the condition true and be reported as an event.",
+			cr:      with(v1alpha1.NodesUpToDate()),
+			pending: map[string]int{"nodes": 2, "master-us-east-1a": 1},
+			want:    want{c: v1alpha1.RollingUpdatePending(pendingMsg), events: 1},
+		},
+		"StillPending": {
+			reason:  "The same outdated instances should not be reported again.",
+			cr:      with(v1alpha1.RollingUpdatePending(pendingMsg)),
+			pending: map[string]int{"nodes": 2, "master-us-east-1a": 1},
+			want:    want{c: v1alpha1.RollingUpdatePending(pendingMsg)},
+		},
+		"BecomesUpToDate": {
+			reason: "A pending rolling update should clear once no instances are outdated.",
+			cr:     with(v1alpha1.RollingUpdatePending(pendingMsg)),
+			want:   want{c: v1alpha1.NodesUpToDate()},
+		},
+		"BecomesUnknown": {
+			reason: "Failing to read the cloud groups should make the condition unknown rather than leave it stale.",
+			cr:     with(v1alpha1.RollingUpdatePending(pendingMsg)),
+			err:    errBoom,
+			want:   want{c: v1alpha1.RollingUpdateUnknown(errors.Wrap(errBoom, errGetRollingUpdates)), events: 1},
+		},
+		"UnknownBecomesPending": {
+			reason:  "An unknown condition should become true once outdated instances can be observed again.",
+			cr:      with(v1alpha1.RollingUpdateUnknown(errors.Wrap(errBoom, errGetRollingUpdates))),
+			pending: map[string]int{"nodes": 2, "master-us-east-1a": 1},
+			want:    want{c: v1alpha1.RollingUpdatePending(pendingMsg), events: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := &eventRecorder{}
+			e := external{recorder: rec}
+			e.observeRollingUpdate(tc.cr, tc.pending, tc.err)
+			got := want{c: tc.cr.Status.GetCondition(v1alpha1.TypeRollingUpdatePending), events: len(rec.events)}
+			if diff := cmp.Diff(tc.want, got, test.EquateConditions(), cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nobserveRollingUpdate(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestObserveMissingSubnets(t *testing.T) {
+	missingMsg := "instance groups are in subnets missing from the cluster spec: nodes: us-east-1b"
+	kops := func(igSubnets []string, c *xpv1.Condition) *v1alpha1.Kops {
+		cr := &v1alpha1.Kops{}
+		cr.Spec.ForProvider.ClusterSpec.Subnets = []kopsapi.ClusterSubnetSpec{{Name: "us-east-1a"}}
+		cr.Spec.ForProvider.InstanceGroupSpec = []kopsapi.InstanceGroupSpec{{
+			NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: "nodes"},
+			Subnets:    igSubnets,
+		}}
+		if c != nil {
+			cr.Status.SetConditions(*c)
+		}
+		return cr
+	}
+	missing := v1alpha1.SubnetsMissing(missingMsg)
+
+	type want struct {
+		c      xpv1.Condition
+		events int
+	}
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.Kops
+		want   want
+	}{
+		"SubnetsFound": {
+			reason: "Instance groups in subnets of the cluster spec should not be reported.",
+			cr:     kops([]string{"us-east-1a"}, nil),
+			want:   want{c: v1alpha1.SubnetsFound()},
+		},
+		"SubnetsMissing": {
+			reason: "Instance groups in subnets missing from the cluster spec should be reported as a condition and an event.",
+			cr:     kops([]string{"us-east-1a", "us-east-1b"}, nil),
+			want:   want{c: missing, events: 1},
+		},
+		"StillMissing": {
+			reason: "The same missing subnets should not be reported again.",
+			cr:     kops([]string{"us-east-1b"}, &missing),
+			want:   want{c: missing},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := &eventRecorder{}
+			e := external{recorder: rec}
+			e.observeMissingSubnets(tc.cr)
+			got := want{c: tc.cr.Status.GetCondition(v1alpha1.TypeMissingSubnets), events: len(rec.events)}
+			if diff := cmp.Diff(tc.want, got, test.EquateConditions(), cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nobserveMissingSubnets(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestObserveExternalModification(t *testing.T) {
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "example.foo.com"}}
+	ig := &kopsapi.InstanceGroupList{}
+	hash, err := util.HashClusterState(cluster, ig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	specHash, err := util.HashParameters(v1alpha1.KopsParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kops := func(w *v1alpha1.WrittenStateObservation) *v1alpha1.Kops {
+		cr := &v1alpha1.Kops{}
+		cr.Status.AtProvider.WrittenState = w
+		return cr
+	}
+	modified := v1alpha1.StateModifiedExternally("the state of cluster example.foo.com was changed outside of the provider since it last wrote it, e.g. with kops edit cluster")
+
+	type want struct {
+		c       xpv1.Condition
+		written *v1alpha1.WrittenStateObservation
+		events  int
+	}
+
+	cases := map[string]struct {
+		reason   string
+		cr       *v1alpha1.Kops
+		upToDate bool
+		want     want
+	}{
+		"Baseline": {
+			reason:   "State of an up to date cluster the provider didn't write should be taken as written.",
+			cr:       kops(nil),
+			upToDate: true,
+			want: want{
+				c:       v1alpha1.StateAsApplied(),
+				written: &v1alpha1.WrittenStateObservation{Hash: hash, SpecHash: specHash},
+			},
+		},
+		"SpecChanged": {
+			reason: "State written for other parameters should be left for the next update to replace.",
+			cr:     kops(&v1alpha1.WrittenStateObservation{Hash: "old", SpecHash: "old"}),
+			want: want{
+				c:       xpv1.Condition{Type: v1alpha1.TypeExternallyModified, Status: corev1.ConditionUnknown},
+				written: &v1alpha1.WrittenStateObservation{Hash: "old", SpecHash: "old"},
+			},
+		},
+		"AsApplied": {
+			reason: "State matching the state the provider wrote should not be reported.",
+			cr:     kops(&v1alpha1.WrittenStateObservation{Hash: hash, SpecHash: specHash}),
+			want: want{
+				c:       v1alpha1.StateAsApplied(),
+				written: &v1alpha1.WrittenStateObservation{Hash: hash, SpecHash: specHash},
+			},
+		},
+		"ModifiedExternally": {
+			reason: "State differing from the state the provider wrote should be reported as a condition and an event.",
+			cr:     kops(&v1alpha1.WrittenStateObservation{Hash: "old", SpecHash: specHash}),
+			want: want{
+				c:       modified,
+				written: &v1alpha1.WrittenStateObservation{Hash: "old", SpecHash: specHash},
+				events:  1,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := &eventRecorder{}
+			e := external{recorder: rec}
+			if err := e.observeExternalModification(tc.cr, cluster, ig, tc.upToDate); err != nil {
+				t.Fatal(err)
+			}
+			got := want{
+				c:       tc.cr.Status.GetCondition(v1alpha1.TypeExternallyModified),
+				written: tc.cr.Status.AtProvider.WrittenState,
+				events:  len(rec.events),
+			}
+			if diff := cmp.Diff(tc.want, got, test.EquateConditions(), cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nobserveExternalModification(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestBoundCertificateTTL(t *testing.T) {
+	requesting := func(ttl time.Duration) *v1alpha1.Kops {
+		return &v1alpha1.Kops{Spec: v1alpha1.KopsSpec{ForProvider: v1alpha1.KopsParameters{
+			KubernetesAPICertificateTTL: &metav1.Duration{Duration: ttl},
+		}}}
+	}
+
+	type want struct {
+		ttl time.Duration
+		err error
+	}
+	cases := map[string]struct {
+		reason      string
+		cr          *v1alpha1.Kops
+		limit       time.Duration
+		enforcement apisv1alpha1.CertificatePolicyEnforcement
+		want        want
+	}{
+		"Default": {
+			reason: "Resources that don't request a validity should get the default one.",
+			cr:     &v1alpha1.Kops{},
+			want:   want{ttl: util.KubeconfigCertificateTTL},
+		},
+		"DefaultExceedsLimit": {
+			reason:      "Resources that don't request a validity should be clamped rather than rejected.",
+			cr:          &v1alpha1.Kops{},
+			limit:       time.Hour,
+			enforcement: apisv1alpha1.CertificatePolicyReject,
+			want:        want{ttl: time.Hour},
+		},
+		"WithinLimit": {
+			reason:      "Validities within the limit should be issued as requested.",
+			cr:          requesting(2 * time.Hour),
+			limit:       4 * time.Hour,
+			enforcement: apisv1alpha1.CertificatePolicyReject,
+			want:        want{ttl: 2 * time.Hour},
+		},
+		"Clamp": {
+			reason:      "Validities exceeding the limit should be clamped to it.",
+			cr:          requesting(48 * time.Hour),
+			limit:       4 * time.Hour,
+			enforcement: apisv1alpha1.CertificatePolicyClamp,
+			want:        want{ttl: 4 * time.Hour},
+		},
+		"Reject": {
+			reason:      "Validities exceeding the limit should be rejected.",
+			cr:          requesting(48 * time.Hour),
+			limit:       4 * time.Hour,
+			enforcement: apisv1alpha1.CertificatePolicyReject,
+			want:        want{err: errors.Errorf(errCertificateTTLExceeded, 48*time.Hour, 4*time.Hour)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := boundCertificateTTL(tc.cr, tc.limit, tc.enforcement)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nboundCertificateTTL(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.ttl, got); diff != "" {
+				t.Errorf("\n%s\nboundCertificateTTL(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRecordFailure(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	cases := map[string]struct {
+		reason string
+		cr     *v1alpha1.Kops
+		op     string
+		err    error
+		want   xpv1.Condition
+	}{
+		"Succeeded": {
+			reason: "A successful operation on a validated cluster should not be failing.",
+			cr:     &v1alpha1.Kops{},
+			op:     opObserve,
+			want:   v1alpha1.NotFailing(),
+		},
+		"ValidationFailed": {
+			reason: "A cluster that failed validation should be failing even though the operation succeeded.",
+			cr: func() *v1alpha1.Kops {
+				cr := &v1alpha1.Kops{}
+				cr.Status.SetConditions(v1alpha1.ClusterValidationFailed("node not ready"))
+				return cr
+			}(),
+			op:   opObserve,
+			want: v1alpha1.Failing(v1alpha1.ReasonValidationFailed, "node not ready"),
+		},
+		"StateStoreUnreachable": {
+			reason: "Errors reaching the state store should be classified as such.",
+			cr:     &v1alpha1.Kops{},
+			op:     opObserve,
+			err:    errors.Wrap(stateStoreError{errBoom}, errGetCluster),
+			want:   v1alpha1.Failing(v1alpha1.ReasonStateStoreUnreachable, errGetCluster+": boom"),
+		},
+		"CloudAuthFailure": {
+			reason: "Cloud APIs rejecting credentials should be classified as an auth failure whatever the operation.",
+			cr:     &v1alpha1.Kops{},
+			op:     opUpdate,
+			err:    errors.New("error listing instances: UnauthorizedOperation: You are not authorized"),
+			want:   v1alpha1.Failing(v1alpha1.ReasonCloudAuthFailure, "error listing instances: UnauthorizedOperation: You are not authorized"),
+		},
+		"ApplyFailed": {
+			reason: "Other errors applying a cluster should be classified as apply failures.",
+			cr:     &v1alpha1.Kops{},
+			op:     opCreate,
+			err:    errBoom,
+			want:   v1alpha1.Failing(v1alpha1.ReasonApplyFailed, "boom"),
+		},
+		"ApplyThrottled": {
+			reason: "An apply waiting for a free apply worker should not be failing.",
+			cr:     &v1alpha1.Kops{},
+			op:     opUpdate,
+			err:    errors.Wrap(waitError{error: errBoom, reason: v1alpha1.ReasonApplyThrottled}, errApplyWorkers),
+			want:   v1alpha1.Waiting(v1alpha1.ReasonApplyThrottled, errApplyWorkers+": boom"),
+		},
+		"DeleteBlocked": {
+			reason: "Other errors deleting a cluster should be classified as blocked deletes.",
+			cr:     &v1alpha1.Kops{},
+			op:     opDelete,
+			err:    errBoom,
+			want:   v1alpha1.Failing(v1alpha1.ReasonDeleteBlocked, "boom"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			recordFailure(tc.cr, tc.op, tc.err)
+			got := tc.cr.Status.GetCondition(v1alpha1.TypeFailing)
+			if diff := cmp.Diff(tc.want, got, test.EquateConditions()); diff != "" {
+				t.Errorf("\n%s\nrecordFailure(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	failing := func(cr *v1alpha1.Kops) *v1alpha1.Kops {
+		cr.Status.SetConditions(xpv1.ReconcileError(errors.New("boom")))
+		return cr
+	}
+	paused := func(cr *v1alpha1.Kops) *v1alpha1.Kops {
+		cr.Status.SetConditions(v1alpha1.CircuitOpen("paused"))
+		return cr
+	}
+	now := metav1.Now()
+	waiting := func(cr *v1alpha1.Kops) *v1alpha1.Kops {
+		cr.Status.SetConditions(xpv1.ReconcileError(errors.New("all 2 apply workers are busy")), v1alpha1.Waiting(v1alpha1.ReasonApplyThrottled, "all 2 apply workers are busy"))
+		return cr
+	}
+
+	type want struct {
+		result    reconcile.Result
+		reconcile int
+		paused    corev1.ConditionStatus
+		annotated bool
+	}
+	cases := map[string]struct {
+		reason     string
+		cr         *v1alpha1.Kops
+		runs       int
+		threshold  int
+		maxBackoff time.Duration
+		want       want
+	}{
+		"Succeeding": {
+			reason:     "Resources that don't fail should be reconciled as usual.",
+			cr:         &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+			runs:       3,
+			threshold:  2,
+			maxBackoff: time.Hour,
+			want:       want{result: reconcile.Result{Requeue: true}, reconcile: 3, paused: corev1.ConditionUnknown},
+		},
+		"BackOff": {
+			reason:     "Resources that keep failing should be backed off exponentially.",
+			cr:         failing(&v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}),
+			runs:       3,
+			maxBackoff: time.Hour,
+			want:       want{result: reconcile.Result{RequeueAfter: 4 * failureBackoffBase}, reconcile: 3, paused: corev1.ConditionUnknown},
+		},
+		"Pause": {
+			reason:     "Resources that failed the same way threshold times in a row should be paused.",
+			cr:         failing(&v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}),
+			runs:       3,
+			threshold:  2,
+			maxBackoff: time.Hour,
+			want:       want{reconcile: 2, paused: corev1.ConditionTrue},
+		},
+		"Waiting": {
+			reason:     "Resources waiting for an operation to start should be neither backed off nor paused.",
+			cr:         waiting(&v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}),
+			runs:       3,
+			threshold:  2,
+			maxBackoff: time.Hour,
+			want:       want{result: reconcile.Result{Requeue: true}, reconcile: 3, paused: corev1.ConditionUnknown},
+		},
+		"Resume": {
+			reason:     "Paused resources should resume once annotated, and the annotation removed.",
+			cr:         paused(&v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "foo", Annotations: map[string]string{v1alpha1.AnnotationKeyResume: "now"}}}),
+			runs:       1,
+			threshold:  2,
+			maxBackoff: time.Hour,
+			want:       want{result: reconcile.Result{Requeue: true}, reconcile: 1, paused: corev1.ConditionFalse},
+		},
+		"DeletedWhilePaused": {
+			reason:     "Paused resources should resume once deleted, so that their deletion isn't blocked.",
+			cr:         paused(&v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "foo", DeletionTimestamp: &now}}),
+			runs:       2,
+			threshold:  2,
+			maxBackoff: time.Hour,
+			want:       want{result: reconcile.Result{Requeue: true}, reconcile: 2, paused: corev1.ConditionFalse},
+		},
+		"DeletedFailing": {
+			reason:     "Deleted resources that keep failing should be backed off but never paused.",
+			cr:         failing(&v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "foo", DeletionTimestamp: &now}}),
+			runs:       3,
+			threshold:  2,
+			maxBackoff: time.Hour,
+			want:       want{result: reconcile.Result{RequeueAfter: 4 * failureBackoffBase}, reconcile: 3, paused: corev1.ConditionUnknown},
+		},
+		"StayPaused": {
+			reason:     "Paused resources should not be reconciled until they are resumed.",
+			cr:         paused(&v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}),
+			runs:       2,
+			threshold:  2,
+			maxBackoff: time.Hour,
+			want:       want{paused: corev1.ConditionTrue},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			stored := tc.cr.DeepCopy()
+			get := func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+				stored.DeepCopyInto(obj.(*v1alpha1.Kops))
+				return nil
+			}
+			store := func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+				obj.(*v1alpha1.Kops).DeepCopyInto(stored)
+				return nil
+			}
+			kube := &test.MockClient{MockGet: get, MockUpdate: store, MockStatusUpdate: store}
+			reconciles := 0
+			inner := reconcile.Func(func(_ context.Context, _ reconcile.Request) (reconcile.Result, error) {
+				reconciles++
+				return reconcile.Result{Requeue: true}, nil
+			})
+
+			b := newBreaker(inner, kube, kube, event.NewNopRecorder(), tc.threshold, tc.maxBackoff)
+			var got reconcile.Result
+			for i := 0; i < tc.runs; i++ {
+				var err error
+				got, err = b.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKey{Name: "foo"}})
+				if err != nil {
+					t.Fatalf("\n%s\nb.Reconcile(...): %v", tc.reason, err)
+				}
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nb.Reconcile(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.reconcile, reconciles); diff != "" {
+				t.Errorf("\n%s\nb.Reconcile(...): -want reconciles, +got reconciles:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.paused, stored.Status.GetCondition(v1alpha1.TypeReconcilePaused).Status); diff != "" {
+				t.Errorf("\n%s\nb.Reconcile(...): -want paused, +got paused:\n%s\n", tc.reason, diff)
+			}
+			if _, ok := stored.GetAnnotations()[v1alpha1.AnnotationKeyResume]; ok != tc.want.annotated {
+				t.Errorf("\n%s\nb.Reconcile(...): want annotated %t, got %t", tc.reason, tc.want.annotated, ok)
+			}
+		})
+	}
+}
+
+func TestIntrospectionHandler(t *testing.T) {
+	errBoom := errors.New("boom")
+	at := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	cr := &v1alpha1.Kops{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Annotations: map[string]string{meta.AnnotationKeyExternalName: "foo"}},
+		Spec:       v1alpha1.KopsSpec{ForProvider: v1alpha1.KopsParameters{Domain: "example.com"}},
+	}
+	cr.SetConditions(xpv1.Available(), xpv1.ReconcileSuccess())
+
+	type want struct {
+		code  int
+		views []clusterView
+	}
+
+	cases := map[string]struct {
+		reason  string
+		kube    client.Reader
+		history map[string]operation
+		want    want
+	}{
+		"ListError": {
+			reason: "Errors listing Kops resources should be returned.",
+			kube:   &test.MockClient{MockList: test.NewMockListFn(errBoom)},
+			want:   want{code: http.StatusInternalServerError},
+		},
+		"Clusters": {
+			reason: "Every Kops resource should be listed with its last operation.",
+			kube: &test.MockClient{MockList: test.NewMockListFn(nil, func(obj client.ObjectList) error {
+				obj.(*v1alpha1.KopsList).Items = []v1alpha1.Kops{*cr}
+				return nil
+			})},
+			history: map[string]operation{"foo": {Name: "Update", Time: at, Error: "boom"}},
+			want: want{
+				code: http.StatusOK,
+				views: []clusterView{{
+					Name:              "foo",
+					Cluster:           "foo.example.com",
+					Phase:             string(xpv1.ReasonAvailable),
+					Ready:             string(corev1.ConditionTrue),
+					Synced:            string(corev1.ConditionTrue),
+					LastOperation:     "Update",
+					LastReconcileTime: &at,
+					LastError:         "boom",
+				}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := &introspectionHandler{kube: tc.kube, history: newHistory()}
+			for n, o := range tc.history {
+				h.history.ops[n] = o
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, introspectionPath, nil))
+			if diff := cmp.Diff(tc.want.code, rec.Code); diff != "" {
+				t.Errorf("\n%s\nServeHTTP(...): -want code, +got code:\n%s\n", tc.reason, diff)
+			}
+			if tc.want.code != http.StatusOK {
+				return
+			}
+			var got []clusterView
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.views, got); diff != "" {
+				t.Errorf("\n%s\nServeHTTP(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRolloutAllowed(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		mode   v1alpha1.ReachabilityMode
+		cond   xpv1.Condition
+		want   bool
+	}{
+		"Validated": {
+			reason: "A validated cluster should have its next instance replaced.",
+			cond:   v1alpha1.ClusterValidated(),
+			want:   true,
+		},
+		"NotValidated": {
+			reason: "A cluster that didn't validate since the last replacement should wait for it.",
+			cond:   v1alpha1.ClusterValidationFailed("node not ready"),
+		},
+		"CloudOnly": {
+			reason: "A cluster operated from the cloud only should not wait for validation.",
+			mode:   v1alpha1.ReachabilityCloudOnly,
+			cond:   v1alpha1.ClusterValidationSkipped("skipped"),
+			want:   true,
+		},
+		"SkipValidation": {
+			reason: "A cluster whose validation is skipped should not have instances replaced.",
+			mode:   v1alpha1.ReachabilitySkipValidation,
+			cond:   v1alpha1.ClusterValidationSkipped("skipped"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{}
+			if tc.mode != "" {
+				cr.Spec.ForProvider.Reachability = &v1alpha1.ReachabilityParameters{Mode: tc.mode}
+			}
+			cr.Status.SetConditions(tc.cond)
+			if got := rolloutAllowed(cr); got != tc.want {
+				t.Errorf("\n%s\nrolloutAllowed(...): want %t, got %t", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEtcdHealthy(t *testing.T) {
+	cluster := &kopsapi.Cluster{Spec: kopsapi.ClusterSpec{
+		EtcdClusters: []kopsapi.EtcdClusterSpec{{Name: "main"}, {Name: "events"}},
+	}}
+
+	cases := map[string]struct {
+		reason string
+		obs    []v1alpha1.EtcdClusterObservation
+		want   bool
+	}{
+		"Unobserved": {
+			reason: "etcd clusters that weren't observed should not be considered healthy.",
+			obs:    nil,
+			want:   false,
+		},
+		"UnhealthyMember": {
+			reason: "An etcd cluster with an unhealthy member should not be considered healthy.",
+			obs: []v1alpha1.EtcdClusterObservation{
+				{Name: "main", Members: 3, HealthyMembers: 3},
+				{Name: "events", Members: 3, HealthyMembers: 2},
+			},
+			want: false,
+		},
+		"Healthy": {
+			reason: "etcd clusters whose members are all healthy should be considered healthy.",
+			obs: []v1alpha1.EtcdClusterObservation{
+				{Name: "main", Members: 3, HealthyMembers: 3},
+				{Name: "events", Members: 3, HealthyMembers: 3},
+			},
+			want: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := etcdHealthy(cluster, tc.obs)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\netcdHealthy(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestTTLCache(t *testing.T) {
+	errBoom := errors.New("boom")
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	c := newTTLCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	builds := 0
+	build := func() (interface{}, error) {
+		builds++
+		return builds, nil
+	}
+
+	steps := []struct {
+		reason  string
+		advance time.Duration
+		build   func() (interface{}, error)
+		want    interface{}
+		err     error
+	}{
+		{reason: "A missing value should be built.", build: build, want: 1},
+		{reason: "A cached value should be reused.", advance: 30 * time.Second, build: build, want: 1},
+		{reason: "An expired value should be rebuilt.", advance: time.Minute, build: build, want: 2},
+		{reason: "A failed build should return its error and not be cached.", advance: time.Minute, build: func() (interface{}, error) { return nil, errBoom }, err: errBoom},
+		{reason: "A value should be built after a failed build.", build: build, want: 3},
+	}
+
+	for i, s := range steps {
+		now = now.Add(s.advance)
+		got, err := c.get("key", s.build)
+		if diff := cmp.Diff(s.err, err, test.EquateErrors()); diff != "" {
+			t.Errorf("\n%s\nstep %d: get(...): -want error, +got error:\n%s\n", s.reason, i, diff)
+		}
+		if diff := cmp.Diff(s.want, got); diff != "" {
+			t.Errorf("\n%s\nstep %d: get(...): -want, +got:\n%s\n", s.reason, i, diff)
+		}
+	}
+}
+
+func TestApplyPool(t *testing.T) {
+	p := newApplyPool(2)
+
+	first, err := p.acquire()
+	if err != nil {
+		t.Fatalf("acquire(...): %v", err)
+	}
+	if _, err := p.acquire(); err != nil {
+		t.Fatalf("acquire(...): %v", err)
+	}
+	if _, err := p.acquire(); !errors.As(err, &waitError{}) {
+		t.Errorf("\nA full pool should refuse to start another apply, waiting for a free worker.\nacquire(...): want waitError, got %v\n", err)
+	}
+
+	first()
+	if _, err := p.acquire(); err != nil {
+		t.Errorf("\nA released slot should be available again.\nacquire(...): %v\n", err)
+	}
+
+	var unbounded *applyPool
+	for i := 0; i < 10; i++ {
+		if _, err := unbounded.acquire(); err != nil {
+			t.Errorf("\nA nil pool should not bound applies.\nacquire(...): %v\n", err)
+		}
+	}
+}
+
+func TestUpdateReadOnly(t *testing.T) {
+	// Every apply worker is busy, so an update that needed one would wait
+	// for it rather than dry run.
+	p := newApplyPool(1)
+	if _, err := p.acquire(); err != nil {
+		t.Fatalf("acquire(...): %v", err)
+	}
+	e := external{applies: p, recorder: event.NewNopRecorder(), readOnly: true}
+	cr := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+	cr.Status.AtProvider.DryRunGeneration = 2
+
+	_, err := e.Update(context.Background(), cr)
+	if diff := cmp.Diff(readOnlyError("update the cluster"), err, test.EquateErrors()); diff != "" {
+		t.Errorf("\nA read-only provider should dry run updates without taking an apply worker.\ne.Update(...): -want error, +got error:\n%s\n", diff)
+	}
+}
+
+// certPEM returns a self-signed PEM encoded certificate expiring at the
+// supplied time.
+func certPEM(t *testing.T, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestKubeClients(t *testing.T) {
+	errBoom := errors.New("boom")
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	k := newKubeClients()
+	k.now = func() time.Time { return now }
+
+	builds := 0
+	build := func() (*rest.Config, error) {
+		builds++
+		return &rest.Config{Host: "https://api.example.org", TLSClientConfig: rest.TLSClientConfig{CertData: certPEM(t, now.Add(18*time.Hour))}}, nil
+	}
+	tokenBuild := func() (*rest.Config, error) {
+		builds++
+		return &rest.Config{Host: "https://api.example.org", BearerToken: "t0k3n"}, nil
+	}
+
+	steps := []struct {
+		reason  string
+		advance time.Duration
+		server  string
+		ca      string
+		build   func() (*rest.Config, error)
+		builds  int
+		err     error
+	}{
+		{reason: "A missing client should be built.", server: "a", ca: "a", build: build, builds: 1},
+		{reason: "A cached client should be reused.", advance: time.Hour, server: "a", ca: "a", build: build, builds: 1},
+		{reason: "A client should be rebuilt when the CA changes.", server: "a", ca: "b", build: build, builds: 2},
+		{reason: "A client should be rebuilt when the API endpoint changes.", server: "b", ca: "b", build: build, builds: 3},
+		{reason: "A client should be rebuilt when its certificate nears expiry.", advance: 10 * time.Hour, server: "b", ca: "b", build: build, builds: 4},
+		{reason: "A failed build should return its error.", server: "c", ca: "b", build: func() (*rest.Config, error) { return nil, errBoom }, builds: 4, err: errBoom},
+		{reason: "A client without a certificate should be built.", server: "d", ca: "b", build: tokenBuild, builds: 5},
+		{reason: "A client without a certificate should be reused.", advance: time.Hour, server: "d", ca: "b", build: tokenBuild, builds: 5},
+		{reason: "A client without a certificate should be rebuilt once its credentials may have been rotated.", advance: 18 * time.Hour, server: "d", ca: "b", build: tokenBuild, builds: 6},
+	}
+
+	for i, s := range steps {
+		now = now.Add(s.advance)
+		_, err := k.get("cluster", s.server, s.ca, s.build)
+		if diff := cmp.Diff(s.err, err, test.EquateErrors()); diff != "" {
+			t.Errorf("\n%s\nstep %d: get(...): -want error, +got error:\n%s\n", s.reason, i, diff)
+		}
+		if diff := cmp.Diff(s.builds, builds); diff != "" {
+			t.Errorf("\n%s\nstep %d: get(...): -want builds, +got builds:\n%s\n", s.reason, i, diff)
+		}
+	}
+}
+
+func TestForEachInstanceGroup(t *testing.T) {
+	errBoom := errors.New("boom")
+	igs := []kopsapi.InstanceGroupSpec{}
+	for _, n := range []string{"a", "b", "c", "d", "e", "f"} {
+		igs = append(igs, kopsapi.InstanceGroupSpec{NodeLabels: map[string]string{"kops.k8s.io/instancegroup": n}})
+	}
+
+	mu := sync.Mutex{}
+	called := map[string]bool{}
+	err := forEachInstanceGroup(context.Background(), igs, func(_ context.Context, ig *kopsapi.InstanceGroup) error {
+		mu.Lock()
+		called[ig.ObjectMeta.Name] = true
+		mu.Unlock()
+		if ig.ObjectMeta.Name == "b" || ig.ObjectMeta.Name == "e" {
+			return errBoom
+		}
+		return nil
+	})
+
+	if err == nil {
+		t.Fatalf("\nforEachInstanceGroup(...): want error, got nil\n")
+	}
+	want := `[instance group "b": boom, instance group "e": boom]`
+	if diff := cmp.Diff(want, err.Error()); diff != "" {
+		t.Errorf("\nFailures should be aggregated in order, naming their instance group.\nforEachInstanceGroup(...): -want error, +got error:\n%s\n", diff)
+	}
+	wantCalled := map[string]bool{"a": true, "b": true, "c": true, "d": true, "e": true, "f": true}
+	if diff := cmp.Diff(wantCalled, called); diff != "" {
+		t.Errorf("\nEvery instance group should be attempted despite failures.\nforEachInstanceGroup(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestTTLCacheForget(t *testing.T) {
+	c := newTTLCache(time.Minute)
+	for _, k := range []string{"a.example.org|aws", "a.example.org|gce", "ab.example.org|aws"} {
+		k := k
+		if _, err := c.get(k, func() (interface{}, error) { return k, nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.forget("a.example.org|")
+
+	got := []string{}
+	for k := range c.entries {
+		got = append(got, k)
+	}
+	if diff := cmp.Diff([]string{"ab.example.org|aws"}, got); diff != "" {
+		t.Errorf("\nOnly the values of the forgotten cluster should be dropped.\nforget(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestTTLCacheConcurrentGets(t *testing.T) {
+	c := newTTLCache(time.Minute)
+
+	// The build of a only finishes once b was built, which would never happen
+	// if builds were serialized.
+	built := make(chan struct{})
+	errs := make(chan error, 2)
+	go func() {
+		_, err := c.get("a", func() (interface{}, error) {
+			select {
+			case <-built:
+				return "a", nil
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("b was not built while a was")
+			}
+		})
+		errs <- err
+	}()
+	go func() {
+		_, err := c.get("b", func() (interface{}, error) {
+			close(built)
+			return "b", nil
+		})
+		errs <- err
+	}()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("\nValues of different keys should be built concurrently.\nget(...): %v\n", err)
+		}
+	}
+
+	// Concurrent gets of the same key should share one build.
+	builds := 0
+	release := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = c.get("c", func() (interface{}, error) {
+				builds++
+				<-release
+				return "c", nil
+			})
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if builds != 1 {
+		t.Errorf("\nConcurrent gets of the same key should share one build.\nget(...): want 1 build, got %d\n", builds)
+	}
+}
+
+func TestKubeClientsConcurrentGets(t *testing.T) {
+	k := newKubeClients()
+	config := func() (*rest.Config, error) {
+		return &rest.Config{Host: "https://api.example.org", BearerToken: "t0k3n"}, nil
+	}
+
+	// The client of a is only built once b's was, which would never happen
+	// if builds were serialized.
+	built := make(chan struct{})
+	errs := make(chan error, 2)
+	go func() {
+		_, err := k.get("a", "a", "a", func() (*rest.Config, error) {
+			select {
+			case <-built:
+				return config()
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("b was not built while a was")
+			}
+		})
+		errs <- err
+	}()
+	go func() {
+		_, err := k.get("b", "b", "b", func() (*rest.Config, error) {
+			close(built)
+			return config()
+		})
+		errs <- err
+	}()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("\nClients of different clusters should be built concurrently.\nget(...): %v\n", err)
+		}
+	}
+
+	// Concurrent gets of the same cluster should share one build.
+	builds := 0
+	release := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = k.get("c", "c", "c", func() (*rest.Config, error) {
+				builds++
+				<-release
+				return config()
+			})
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if builds != 1 {
+		t.Errorf("\nConcurrent gets of the same cluster should share one build.\nget(...): want 1 build, got %d\n", builds)
+	}
+}
+
+func TestShardSelects(t *testing.T) {
+	kops := func(name, extName string, l map[string]string) *v1alpha1.Kops {
+		cr := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: l}}
+		if extName != "" {
+			meta.SetExternalName(cr, extName)
+		}
+		return cr
+	}
+
+	cases := map[string]struct {
+		reason string
+		shard  Shard
+		o      *v1alpha1.Kops
+		want   bool
+	}{
+		"Unsharded": {
+			reason: "The zero shard should select every resource.",
+			o:      kops("a", "", nil),
+			want:   true,
+		},
+		"SelectorMatches": {
+			reason: "A resource with matching labels should be selected.",
+			shard:  Shard{Selector: labels.SelectorFromSet(labels.Set{"fleet": "prod"})},
+			o:      kops("a", "", map[string]string{"fleet": "prod"}),
+			want:   true,
+		},
+		"SelectorDoesNotMatch": {
+			reason: "A resource without matching labels should not be selected.",
+			shard:  Shard{Selector: labels.SelectorFromSet(labels.Set{"fleet": "prod"})},
+			o:      kops("a", "", map[string]string{"fleet": "dev"}),
+		},
+		"OwnShard": {
+			reason: "A resource should be selected by the shard its external name hashes to.",
+			shard:  Shard{Count: 3, Index: shardOf(kops("a", "cluster", nil), 3)},
+			o:      kops("a", "cluster", nil),
+			want:   true,
+		},
+		"OtherShard": {
+			reason: "A resource should not be selected by other shards.",
+			shard:  Shard{Count: 3, Index: (shardOf(kops("a", "cluster", nil), 3) + 1) % 3},
+			o:      kops("a", "cluster", nil),
+		},
+		"NameAsExternalName": {
+			reason: "A resource without an external name should stay in the shard of the external name it defaults to.",
+			shard:  Shard{Count: 3, Index: shardOf(kops("b", "cluster", nil), 3)},
+			o:      kops("cluster", "", nil),
+			want:   true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.shard.Selects(tc.o)); diff != "" {
+				t.Errorf("\n%s\nSelects(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestShardPrimary(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		shard  Shard
+		want   bool
+	}{
+		"Unsharded": {
+			reason: "An unsharded deployment should run the controllers that aren't sharded.",
+			want:   true,
+		},
+		"FirstShard": {
+			reason: "The first shard should run the controllers that aren't sharded.",
+			shard:  Shard{Count: 3},
+			want:   true,
+		},
+		"OtherShard": {
+			reason: "Other shards should not run the controllers that aren't sharded, so that they don't run once per shard.",
+			shard:  Shard{Count: 3, Index: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.shard.Primary()); diff != "" {
+				t.Errorf("\n%s\nPrimary(): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestTransition(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	type want struct {
+		phase   v1alpha1.ClusterPhase
+		changed bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		from   v1alpha1.ClusterPhase
+		ev     lifecycleEvent
+		want   want
+	}{
+		"Created": {
+			reason: "A registered cluster should be provisioning.",
+			from:   v1alpha1.ClusterPhasePending,
+			ev:     lifecycleCreate,
+			want:   want{phase: v1alpha1.ClusterPhaseProvisioning, changed: true},
+		},
+		"Applied": {
+			reason: "An applied cluster should be validating.",
+			from:   v1alpha1.ClusterPhaseProvisioning,
+			ev:     lifecycleApplied,
+			want:   want{phase: v1alpha1.ClusterPhaseValidating, changed: true},
+		},
+		"Validated": {
+			reason: "A cluster that passed validation should be ready.",
+			from:   v1alpha1.ClusterPhaseValidating,
+			ev:     lifecycleValidated,
+			want:   want{phase: v1alpha1.ClusterPhaseReady, changed: true},
+		},
+		"Degraded": {
+			reason: "A ready cluster that fails validation should be validating again.",
+			from:   v1alpha1.ClusterPhaseReady,
+			ev:     lifecycleValidationFailed,
+			want:   want{phase: v1alpha1.ClusterPhaseValidating, changed: true},
+		},
+		"UpdatingValidated": {
+			reason: "A cluster whose changes weren't applied yet should stay updating even if it passes validation.",
+			from:   v1alpha1.ClusterPhaseUpdating,
+			ev:     lifecycleValidated,
+			want:   want{phase: v1alpha1.ClusterPhaseUpdating},
+		},
+		"DeletingIsFinal": {
+			reason: "A deleting cluster should stay deleting once it is gone from its state store.",
+			from:   v1alpha1.ClusterPhaseDeleting,
+			ev:     lifecycleNotFound,
+			want:   want{phase: v1alpha1.ClusterPhaseDeleting},
+		},
+		"Adopted": {
+			reason: "A cluster without a phase should be adopted into the phase its first event implies.",
+			ev:     lifecycleValidated,
+			want:   want{phase: v1alpha1.ClusterPhaseReady, changed: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{}
+			cr.Status.AtProvider.Phase = tc.from
+			changed := transition(cr, tc.ev, now)
+			if diff := cmp.Diff(tc.want, want{phase: cr.Status.AtProvider.Phase, changed: changed}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\ntransition(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestClaimCluster(t *testing.T) {
+	other := &util.ClusterOwner{Name: "other", UID: "other-uid"}
+
+	type args struct {
+		owner       *util.ClusterOwner
+		annotations map[string]string
+		readOnly    bool
+	}
+
+	type want struct {
+		other *util.ClusterOwner
+		owner *util.ClusterOwner
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Unowned": {
+			reason: "A cluster no resource manages should be claimed.",
+			want: want{
+				owner: &util.ClusterOwner{Name: "test", UID: "test-uid"},
+			},
+		},
+		"OwnedBySelf": {
+			reason: "A cluster the resource already manages should stay claimed.",
+			args: args{
+				owner: &util.ClusterOwner{Name: "test", UID: "test-uid"},
+			},
+			want: want{
+				owner: &util.ClusterOwner{Name: "test", UID: "test-uid"},
+			},
+		},
+		"OwnedByOther": {
+			reason: "A cluster another resource manages should not be claimed.",
+			args: args{
+				owner: other,
+			},
+			want: want{
+				other: other,
+				owner: other,
+			},
+		},
+		"AdoptOther": {
+			reason: "A cluster another resource manages should be claimed if the adopt annotation names that resource.",
+			args: args{
+				owner:       other,
+				annotations: map[string]string{v1alpha1.AnnotationKeyAdopt: "other-uid"},
+			},
+			want: want{
+				owner: &util.ClusterOwner{Name: "test", UID: "test-uid"},
+			},
+		},
+		"AdoptWrongUID": {
+			reason: "A cluster another resource manages should not be claimed if the adopt annotation names a different resource.",
+			args: args{
+				owner:       other,
+				annotations: map[string]string{v1alpha1.AnnotationKeyAdopt: "stale-uid"},
+			},
+			want: want{
+				other: other,
+				owner: other,
+			},
+		},
+		"ReadOnly": {
+			reason: "A cluster no resource manages should not be claimed while the provider is read-only.",
+			args: args{
+				readOnly: true,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cs := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+			cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"}}
+			if tc.args.owner != nil {
+				if err := util.SetClusterOwner(cs, cluster, *tc.args.owner); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cr := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "test", UID: "test-uid", Annotations: tc.args.annotations}}
+
+			e := external{kopsClientset: cs, recorder: event.NewNopRecorder(), readOnly: tc.args.readOnly}
+			got, err := e.claimCluster(cr, cluster)
+			if err != nil {
+				t.Fatalf("e.claimCluster(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want.other, got); diff != "" {
+				t.Errorf("\n%s\ne.claimCluster(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			owner, err := util.GetClusterOwner(cs, cluster)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.owner, owner); diff != "" {
+				t.Errorf("\n%s\nutil.GetClusterOwner(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestObserveValidationTimeout(t *testing.T) {
+	errBoom := errors.New("boom")
+	kubeconfig := []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://api.test.example.com
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user:
+    token: t0k3n
+`)
+	kube := &test.MockClient{MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+		obj.(*corev1.Secret).Data = map[string][]byte{"kubeconfig": kubeconfig}
+		return nil
+	}}
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"}}
+
+	type want struct {
+		deadline      bool
+		clientTimeout time.Duration
+		condition     xpv1.Condition
+	}
+
+	cases := map[string]struct {
+		reason   string
+		timeout  time.Duration
+		validate func(ctx context.Context) error
+		want     want
+	}{
+		"Unbounded": {
+			reason:   "Validation should not be bounded if there is no validation timeout.",
+			validate: func(_ context.Context) error { return errBoom },
+			want: want{
+				condition: v1alpha1.ClusterValidationError(errors.Wrap(errBoom, errValidateCluster)),
+			},
+		},
+		"Bounded": {
+			reason:   "Validation, and each request of its client, should be bounded by the validation timeout.",
+			timeout:  time.Minute,
+			validate: func(_ context.Context) error { return errBoom },
+			want: want{
+				deadline:      true,
+				clientTimeout: time.Minute,
+				condition:     v1alpha1.ClusterValidationError(errors.Wrap(errBoom, errValidateCluster)),
+			},
+		},
+		"TimedOut": {
+			reason:  "Validation that outlasts the validation timeout should be reported as a validation error.",
+			timeout: 10 * time.Millisecond,
+			validate: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			want: want{
+				deadline:      true,
+				clientTimeout: 10 * time.Millisecond,
+				condition:     v1alpha1.ClusterValidationError(errors.Wrap(context.DeadlineExceeded, errValidateCluster)),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var deadline bool
+			var clientTimeout time.Duration
+			e := external{
+				kube: kube,
+				builder: &clientsfake.CloudBuilder{MockBuildCloud: func(_ *kopsapi.Cluster) (fi.Cloud, error) {
+					return awsup.BuildMockAWSCloud("us-east-1", "a"), nil
+				}},
+				validator: &clientsfake.Validator{MockValidate: func(ctx context.Context, _ *kopsapi.Cluster, _ fi.Cloud, _ *kopsapi.InstanceGroupList, kube kubernetes.Interface) (*validation.ValidationCluster, error) {
+					d, ok := ctx.Deadline()
+					deadline = ok && time.Until(d) <= tc.timeout
+					clientTimeout = kube.(*kubernetes.Clientset).CoreV1().RESTClient().(*rest.RESTClient).Client.Timeout
+					return nil, tc.validate(ctx)
+				}},
+				recorder:          event.NewNopRecorder(),
+				validationTimeout: tc.timeout,
+			}
+			cr := &v1alpha1.Kops{}
+			cr.Spec.ForProvider.Reachability = &v1alpha1.ReachabilityParameters{
+				KubeconfigSecretRef: &xpv1.SecretKeySelector{SecretReference: xpv1.SecretReference{Name: "s"}, Key: "kubeconfig"},
+			}
+			e.observeValidation(context.Background(), cr, cluster, &kopsapi.InstanceGroupList{}, "")
+			if diff := cmp.Diff(tc.want.deadline, deadline); diff != "" {
+				t.Errorf("\n%s\ne.observeValidation(...): -want deadline, +got deadline:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.clientTimeout, clientTimeout); diff != "" {
+				t.Errorf("\n%s\ne.observeValidation(...): -want client timeout, +got client timeout:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.condition, cr.Status.GetCondition(v1alpha1.TypeClusterValidated)); diff != "" {
+				t.Errorf("\n%s\ne.observeValidation(...): -want condition, +got condition:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRecordValidationResult(t *testing.T) {
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"}}
+	failures := []string{"node i-1 is not ready", "pod kube-dns is pending"}
+
+	type want struct {
+		reason xpv1.ConditionReason
+		events []event.Event
+	}
+
+	cases := map[string]struct {
+		reason     string
+		conditions []xpv1.Condition
+		ok         bool
+		want       want
+	}{
+		"FirstPass": {
+			reason: "A cluster that passes validation for the first time should be recorded with an event.",
+			ok:     true,
+			want: want{
+				reason: v1alpha1.ReasonValidationSucceeded,
+				events: []event.Event{event.Normal(reasonValidationPassed, "Kops cluster validation passed")},
+			},
+		},
+		"StillPassing": {
+			reason:     "A cluster that keeps passing validation should not be recorded again.",
+			conditions: []xpv1.Condition{v1alpha1.ClusterValidated()},
+			ok:         true,
+			want: want{
+				reason: v1alpha1.ReasonValidationSucceeded,
+			},
+		},
+		"Recovered": {
+			reason:     "A cluster that passes validation after failing it should be recorded with an event.",
+			conditions: []xpv1.Condition{v1alpha1.ClusterValidationFailed("boom")},
+			ok:         true,
+			want: want{
+				reason: v1alpha1.ReasonValidationSucceeded,
+				events: []event.Event{event.Normal(reasonValidationPassed, "Kops cluster validation passed")},
+			},
+		},
+		"Failed": {
+			reason:     "A cluster that fails validation after passing it should be recorded with a warning listing its failures.",
+			conditions: []xpv1.Condition{v1alpha1.ClusterValidated()},
+			want: want{
+				reason: v1alpha1.ReasonValidationFailed,
+				events: []event.Event{event.Warning(reasonValidationFailed, errors.New("node i-1 is not ready; pod kube-dns is pending"))},
+			},
+		},
+		"FailedAfterError": {
+			reason:     "A cluster that fails validation after it couldn't be validated should be recorded with a warning.",
+			conditions: []xpv1.Condition{v1alpha1.ClusterValidationError(errors.New("boom"))},
+			want: want{
+				reason: v1alpha1.ReasonValidationFailed,
+				events: []event.Event{event.Warning(reasonValidationFailed, errors.New("node i-1 is not ready; pod kube-dns is pending"))},
+			},
+		},
+		"StillFailing": {
+			reason:     "A cluster that keeps failing validation should not be recorded again.",
+			conditions: []xpv1.Condition{v1alpha1.ClusterValidationFailed("boom")},
+			want: want{
+				reason: v1alpha1.ReasonValidationFailed,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{}
+			cr.Status.SetConditions(tc.conditions...)
+			var res []string
+			if !tc.ok {
+				res = failures
+			}
+			rec := &eventRecorder{}
+			e := external{recorder: rec}
+			e.recordValidationResult(context.Background(), cr, cluster, tc.ok, res)
+			if diff := cmp.Diff(tc.want.reason, cr.Status.GetCondition(v1alpha1.TypeClusterValidated).Reason); diff != "" {
+				t.Errorf("\n%s\ne.recordValidationResult(...): -want reason, +got reason:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.events, rec.events); diff != "" {
+				t.Errorf("\n%s\ne.recordValidationResult(...): -want events, +got events:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	sink := &v1alpha1.NotificationParameters{URL: "https://example.com/hook", Format: v1alpha1.NotificationFormatJSON}
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"}}
+
+	cases := map[string]struct {
+		reason string
+		sink   *v1alpha1.NotificationParameters
+		want   []util.Notification
+	}{
+		"NoSink": {
+			reason: "Nothing should be sent if the Kops resource has no notification sink.",
+		},
+		"Sink": {
+			reason: "Notifications should be sent to the sink of the Kops resource.",
+			sink:   sink,
+			want: []util.Notification{{
+				Type:     util.NotificationClusterReady,
+				Cluster:  "test.example.com",
+				Resource: "test",
+				UID:      "uid",
+				Message:  "Cluster is ready",
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []util.Notification
+			n := &clientsfake.Notifier{MockNotify: func(_ context.Context, s v1alpha1.NotificationParameters, n util.Notification) error {
+				if diff := cmp.Diff(*sink, s); diff != "" {
+					t.Errorf("\n%s\nNotify(...): -want sink, +got sink:\n%s\n", tc.reason, diff)
+				}
+				n.Time = time.Time{}
+				got = append(got, n)
+				return errors.New("boom")
+			}}
+			cr := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "test", UID: "uid"}}
+			cr.Spec.ForProvider.Notifications = tc.sink
+
+			e := external{notifier: n, recorder: event.NewNopRecorder()}
+			e.notify(context.Background(), cr, cluster, util.NotificationClusterReady, "Cluster is ready")
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ne.notify(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetFileAssets(t *testing.T) {
+	errBoom := errors.New("boom")
+	kube := &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+		switch o := obj.(type) {
+		case *corev1.ConfigMap:
+			o.Data = map[string]string{"policy.yaml": "rules: []"}
+			o.BinaryData = map[string][]byte{"blob": {0xff}}
+		case *corev1.Secret:
+			if key.Name == "missing" {
+				return errBoom
+			}
+			o.Data = map[string][]byte{"ca.crt": []byte("ca")}
+		}
+		return nil
+	}}
+
+	type want struct {
+		assets []kopsapi.FileAssetSpec
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		assets []v1alpha1.FileAssetParameters
+		want   want
+	}{
+		"ConfigMap": {
+			reason: "Text content of a ConfigMap key should be used as is.",
+			assets: []v1alpha1.FileAssetParameters{{Name: "audit", Path: "/srv/audit.yaml", Roles: []kopsapi.InstanceGroupRole{kopsapi.InstanceGroupRoleMaster}, ConfigMapRef: &v1alpha1.ConfigMapKeySelector{Name: "cm", Key: "policy.yaml"}}},
+			want: want{
+				assets: []kopsapi.FileAssetSpec{{Name: "audit", Path: "/srv/audit.yaml", Roles: []kopsapi.InstanceGroupRole{kopsapi.InstanceGroupRoleMaster}, Content: "rules: []"}},
+			},
+		},
+		"ConfigMapBinary": {
+			reason: "Binary content of a ConfigMap key should be base64 encoded.",
+			assets: []v1alpha1.FileAssetParameters{{Name: "blob", Path: "/srv/blob", ConfigMapRef: &v1alpha1.ConfigMapKeySelector{Name: "cm", Key: "blob"}}},
+			want: want{
+				assets: []kopsapi.FileAssetSpec{{Name: "blob", Path: "/srv/blob", Content: "/w==", IsBase64: true}},
+			},
+		},
+		"Secret": {
+			reason: "Content of a Secret key should be base64 encoded.",
+			assets: []v1alpha1.FileAssetParameters{{Name: "oidc", Path: "/srv/oidc-ca.crt", SecretRef: &xpv1.SecretKeySelector{SecretReference: xpv1.SecretReference{Name: "s"}, Key: "ca.crt"}}},
+			want: want{
+				assets: []kopsapi.FileAssetSpec{{Name: "oidc", Path: "/srv/oidc-ca.crt", Content: "Y2E=", IsBase64: true}},
+			},
+		},
+		"MissingKey": {
+			reason: "A key missing from its source should be an error.",
+			assets: []v1alpha1.FileAssetParameters{{Name: "audit", ConfigMapRef: &v1alpha1.ConfigMapKeySelector{Name: "cm", Key: "other"}}},
+			want: want{
+				err: errors.Wrapf(errors.Errorf(errFileAssetNotFound, "other"), errGetFileAsset, "audit"),
+			},
+		},
+		"GetSecretFailed": {
+			reason: "A Secret that can't be read should be an error.",
+			assets: []v1alpha1.FileAssetParameters{{Name: "oidc", SecretRef: &xpv1.SecretKeySelector{SecretReference: xpv1.SecretReference{Name: "missing"}, Key: "ca.crt"}}},
+			want: want{
+				err: errors.Wrapf(errors.Wrap(errBoom, errFileAssetSecret), errGetFileAsset, "oidc"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{}
+			cr.Spec.ForProvider.FileAssets = tc.assets
+
+			e := external{kube: kube, recorder: event.NewNopRecorder()}
+			got, err := e.getFileAssets(context.Background(), cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.getFileAssets(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.assets, got); diff != "" {
+				t.Errorf("\n%s\ne.getFileAssets(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetValidationCredentials(t *testing.T) {
+	errBoom := errors.New("boom")
+	kube := &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+		if key.Name == "missing" {
+			return errBoom
+		}
+		obj.(*corev1.Secret).Data = map[string][]byte{"token": []byte("t0k3n"), "kubeconfig": []byte("apiVersion: v1")}
+		return nil
+	}}
+	ref := func(name, key string) *xpv1.SecretKeySelector {
+		return &xpv1.SecretKeySelector{SecretReference: xpv1.SecretReference{Name: name}, Key: key}
+	}
+
+	type want struct {
+		creds *validationCredentials
+		err   error
+	}
+
+	cases := map[string]struct {
+		reason string
+		p      *v1alpha1.ReachabilityParameters
+		want   want
+	}{
+		"None": {
+			reason: "A Kops resource referencing no credentials should be validated with an issued certificate.",
+			p:      &v1alpha1.ReachabilityParameters{Mode: v1alpha1.ReachabilityDirect},
+		},
+		"Token": {
+			reason: "A referenced token should be returned as such.",
+			p:      &v1alpha1.ReachabilityParameters{TokenSecretRef: ref("s", "token")},
+			want:   want{creds: &validationCredentials{token: []byte("t0k3n")}},
+		},
+		"Kubeconfig": {
+			reason: "A referenced kubeconfig should be returned as such.",
+			p:      &v1alpha1.ReachabilityParameters{KubeconfigSecretRef: ref("s", "kubeconfig")},
+			want:   want{creds: &validationCredentials{kubeconfig: []byte("apiVersion: v1")}},
+		},
+		"MissingKey": {
+			reason: "A key missing from the secret should be an error.",
+			p:      &v1alpha1.ReachabilityParameters{TokenSecretRef: ref("s", "other")},
+			want:   want{err: errors.Wrap(errors.Errorf(errValidationCredentialsNotFound, "other"), errGetValidationCredentials)},
+		},
+		"GetSecretFailed": {
+			reason: "A secret that can't be read should be an error.",
+			p:      &v1alpha1.ReachabilityParameters{TokenSecretRef: ref("missing", "token")},
+			want:   want{err: errors.Wrap(errBoom, errGetValidationCredentials)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{}
+			cr.Spec.ForProvider.Reachability = tc.p
+
+			e := external{kube: kube}
+			got, err := e.getValidationCredentials(context.Background(), cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.getValidationCredentials(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.creds, got, cmp.AllowUnexported(validationCredentials{})); diff != "" {
+				t.Errorf("\n%s\ne.getValidationCredentials(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	errBoom := errors.New("boom")
+	kube := &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+		ready := "False"
+		switch key.Name {
+		case "missing":
+			return kerrors.NewNotFound(corev1.Resource("vpcs"), key.Name)
+		case "boom":
+			return errBoom
+		case "ready":
+			ready = "True"
+		}
+		obj.(*unstructured.Unstructured).Object["status"] = map[string]interface{}{
+			"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": ready}},
+		}
+		return nil
+	}}
+	ref := func(name string) v1alpha1.DependencyReference {
+		return v1alpha1.DependencyReference{APIVersion: "ec2.aws.crossplane.io/v1beta1", Kind: "VPC", Name: name}
+	}
+
+	cases := map[string]struct {
+		reason string
+		deps   []v1alpha1.DependencyReference
+		want   error
+	}{
+		"NoDependencies": {
+			reason: "A Kops resource without dependencies should be created right away.",
+		},
+		"Ready": {
+			reason: "A Kops resource whose dependencies are ready should be created.",
+			deps:   []v1alpha1.DependencyReference{ref("ready")},
+		},
+		"Waiting": {
+			reason: "Dependencies that are missing or not ready should be waited for.",
+			deps:   []v1alpha1.DependencyReference{ref("ready"), ref("missing"), ref("creating")},
+			want:   waitError{error: errors.Errorf(errWaitingForDependencies, "VPC/missing, VPC/creating"), reason: v1alpha1.ReasonWaitingForDependencies},
+		},
+		"GetFailed": {
+			reason: "A dependency that can't be read should be an error.",
+			deps:   []v1alpha1.DependencyReference{ref("boom")},
+			want:   errors.Wrapf(errBoom, errGetDependency, "VPC/boom"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{}
+			cr.Spec.ForProvider.DependsOn = tc.deps
+
+			e := external{kube: kube}
+			err := e.checkDependencies(context.Background(), cr)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ne.checkDependencies(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			var w waitError
+			if tc.want != nil && errors.As(tc.want, &w) && !errors.As(err, &w) {
+				t.Errorf("\n%s\ne.checkDependencies(...): want a waitError, got %T\n", tc.reason, err)
+			}
+		})
+	}
+}
+
+func TestPreDelete(t *testing.T) {
+	errBoom := errors.New("boom")
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"}}
+	webhook := &v1alpha1.NotificationParameters{URL: "https://example.com/deregister"}
+
+	type want struct {
+		err      error
+		notified bool
+		calls    int
+	}
+	cases := map[string]struct {
+		reason    string
+		preDelete *v1alpha1.PreDeleteParameters
+		obs       *v1alpha1.PreDeleteObservation
+		notify    error
+		want      want
+	}{
+		"NotConfigured": {
+			reason: "A Kops resource without pre-delete behavior should be deleted right away.",
+		},
+		"Notified": {
+			reason:    "The webhook should be notified before the cluster is deleted.",
+			preDelete: &v1alpha1.PreDeleteParameters{Webhook: webhook},
+			want:      want{notified: true, calls: 1},
+		},
+		"AlreadyNotified": {
+			reason:    "The webhook should not be notified again once it accepted the notification.",
+			preDelete: &v1alpha1.PreDeleteParameters{Webhook: webhook},
+			obs:       &v1alpha1.PreDeleteObservation{Notified: true},
+			want:      want{notified: true},
+		},
+		"NotifyFailed": {
+			reason:    "A webhook that doesn't accept the notification should hold up the deletion.",
+			preDelete: &v1alpha1.PreDeleteParameters{Webhook: webhook},
+			notify:    errBoom,
+			want:      want{err: errors.Wrap(errBoom, errPreDeleteWebhook), calls: 1},
+		},
+		"NothingToDrain": {
+			reason:    "A cluster without cloud resources should not be drained.",
+			preDelete: &v1alpha1.PreDeleteParameters{Webhook: webhook, DrainNodes: true},
+			want:      want{notified: true, calls: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			n := &clientsfake.Notifier{MockNotify: func(_ context.Context, s v1alpha1.NotificationParameters, n util.Notification) error {
+				calls++
+				if n.Type != util.NotificationDeletionStarting {
+					t.Errorf("\n%s\nNotify(...): want a %s notification, got %s", tc.reason, util.NotificationDeletionStarting, n.Type)
+				}
+				return tc.notify
+			}}
+			cr := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "test", UID: "uid"}}
+			cr.Spec.ForProvider.PreDelete = tc.preDelete
+			cr.Status.AtProvider.PreDelete = tc.obs
+
+			e := external{notifier: n, recorder: event.NewNopRecorder()}
+			err := e.preDelete(context.Background(), cr, cluster, false)
+			got := want{err: err, calls: calls}
+			if obs := cr.Status.AtProvider.PreDelete; obs != nil {
+				got.notified = obs.Notified
+			}
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors(), cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\ne.preDelete(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	cs := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"}}
+	// Create writes the owner marker before running the preflight checks.
+	if err := util.SetClusterOwner(cs, cluster, util.ClusterOwner{Name: "test", UID: "test-uid"}); err != nil {
+		t.Fatal(err)
+	}
+
+	e := external{kopsClientset: cs, recorder: event.NewNopRecorder()}
+	if err := e.unregister(context.Background(), cluster); err != nil {
+		t.Fatalf("e.unregister(...): %v", err)
+	}
+	base, err := cs.ConfigBaseFor(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, err := base.ReadTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 0 {
+		t.Errorf("e.unregister(...): want an empty state store, got %v", paths)
+	}
+}
+
+func TestObserveStateReplica(t *testing.T) {
+	vfs.Context.ResetMemfsContext(true)
+	cs := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+	cluster := &kopsapi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "replica.example.com"},
+		Spec:       kopsapi.ClusterSpec{ConfigBase: "memfs://state/replica.example.com"},
+	}
+	base, err := cs.ConfigBaseFor(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := base.Join("config").WriteFile(bytes.NewReader([]byte("config")), nil); err != nil {
+		t.Fatal(err)
+	}
+	applied := metav1.NewTime(time.Now().Add(-time.Minute))
+	before := metav1.NewTime(applied.Add(-time.Hour))
+	replica := &v1alpha1.StateReplicaParameters{Bucket: "memfs://replica"}
+
+	cases := map[string]struct {
+		reason     string
+		params     *v1alpha1.StateReplicaParameters
+		applied    *metav1.Time
+		obs        *v1alpha1.StateReplicaObservation
+		replicated bool
+		wantPath   string
+	}{
+		"Disabled": {
+			reason:  "The replica status of a cluster that isn't replicated should be cleared.",
+			applied: &applied,
+			obs:     &v1alpha1.StateReplicaObservation{Path: "memfs://replica/replica.example.com", LastReplicatedTime: &before},
+		},
+		"NeverApplied": {
+			reason: "The state of a cluster that was never applied should not be replicated.",
+			params: replica,
+		},
+		"Lagging": {
+			reason:     "The state of a cluster applied since it was last replicated should be replicated again.",
+			params:     replica,
+			applied:    &applied,
+			obs:        &v1alpha1.StateReplicaObservation{Path: "memfs://replica/replica.example.com", LastReplicatedTime: &before},
+			replicated: true,
+			wantPath:   "memfs://replica/replica.example.com",
+		},
+		"BucketChanged": {
+			reason:     "The state of a cluster should be replicated to a changed replica bucket.",
+			params:     &v1alpha1.StateReplicaParameters{Bucket: "memfs://other"},
+			applied:    &applied,
+			obs:        &v1alpha1.StateReplicaObservation{Path: "memfs://replica/replica.example.com", LastReplicatedTime: &applied},
+			replicated: true,
+			wantPath:   "memfs://other/replica.example.com",
+		},
+		"UpToDate": {
+			reason:   "The state of a cluster replicated since it was last applied should be left alone.",
+			params:   replica,
+			applied:  &applied,
+			obs:      &v1alpha1.StateReplicaObservation{Path: "memfs://replica/replica.example.com", LastReplicatedTime: &applied},
+			wantPath: "memfs://replica/replica.example.com",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{}
+			cr.Spec.ForProvider.StateReplica = tc.params
+			cr.Status.AtProvider.LastAppliedTime = tc.applied
+			cr.Status.AtProvider.StateReplica = tc.obs
+			e := external{kopsClientset: cs, recorder: event.NewNopRecorder()}
+			e.observeStateReplica(context.Background(), cr, cluster)
+
+			got := cr.Status.AtProvider.StateReplica
+			if tc.wantPath == "" {
+				if got != nil {
+					t.Errorf("\n%s\ne.observeStateReplica(...): want no replica status, got %v", tc.reason, got)
+				}
+				return
+			}
+			if got == nil || got.Path != tc.wantPath {
+				t.Fatalf("\n%s\ne.observeStateReplica(...): want replica path %q, got %v", tc.reason, tc.wantPath, got)
+			}
+			if tc.replicated != (got.Files == 1) {
+				t.Errorf("\n%s\ne.observeStateReplica(...): want replicated %t, got %d files", tc.reason, tc.replicated, got.Files)
+			}
+			if lag := testutil.ToFloat64(metrics.StateReplicationLag.WithLabelValues(cluster.Name)); lag != 0 {
+				t.Errorf("\n%s\ne.observeStateReplica(...): want no replication lag, got %v", tc.reason, lag)
+			}
+		})
+	}
+}
+
+// An eventRecorder records the events it is asked to emit.
+type eventRecorder struct {
+	events []event.Event
+}
+
+func (r *eventRecorder) Event(_ runtime.Object, e event.Event) {
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) WithAnnotations(_ ...string) event.Recorder {
+	return r
+}
+
+func TestRecordAPIServerSANs(t *testing.T) {
+	cluster := &kopsapi.Cluster{Spec: kopsapi.ClusterSpec{AdditionalSANs: []string{"b.example.com", "a.example.com", "b.example.com"}}}
+
+	cases := map[string]struct {
+		reason string
+		obs    *v1alpha1.APIServerSANsObservation
+		want   *v1alpha1.APIServerSANsObservation
+	}{
+		"FirstApply": {
+			reason: "The names of a cluster applied for the first time should be recorded without replacing any instance.",
+			want:   &v1alpha1.APIServerSANsObservation{SANs: []string{"a.example.com", "b.example.com"}},
+		},
+		"Unchanged": {
+			reason: "Names that didn't change, in whatever order, should leave an ongoing rollout alone.",
+			obs:    &v1alpha1.APIServerSANsObservation{SANs: []string{"b.example.com", "a.example.com"}, PendingInstances: []string{"i-1"}},
+			want:   &v1alpha1.APIServerSANsObservation{SANs: []string{"b.example.com", "a.example.com"}, PendingInstances: []string{"i-1"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{}
+			cr.Status.AtProvider.APIServerSANs = tc.obs
+			e := external{recorder: event.NewNopRecorder()}
+			if err := e.recordAPIServerSANs(context.Background(), cr, cluster, nil); err != nil {
+				t.Fatalf("recordAPIServerSANs(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, cr.Status.AtProvider.APIServerSANs); diff != "" {
+				t.Errorf("\n%s\nrecordAPIServerSANs(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRecordCertificateIssued(t *testing.T) {
+	rec := &eventRecorder{}
+	e := external{recorder: rec}
+	commonName := util.KubeconfigCommonName("example.foo.com", "uid")
+	e.recordCertificateIssued(&v1alpha1.Kops{}, commonName, util.CertificateReasonConnectionDetails, time.Hour)
+
+	want := []event.Event{event.Normal(reasonCertificateIssued,
+		`Issued client certificate "crossplane:provider-kops:example.foo.com:uid" valid for 1h0m0s for connection-details`,
+		"commonName", commonName, "reason", util.CertificateReasonConnectionDetails)}
+	if diff := cmp.Diff(want, rec.events); diff != "" {
+		t.Errorf("\nIssuing a certificate should be audited with an event.\ne.recordCertificateIssued(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestObserveValidationMetrics(t *testing.T) {
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "metrics.example.com"}}
+	metrics.RecordValidation(cluster.Name, true, 0, 0)
+
+	cr := &v1alpha1.Kops{}
+	e := external{
+		builder: &clientsfake.CloudBuilder{MockBuildCloud: func(_ *kopsapi.Cluster) (fi.Cloud, error) {
+			return nil, errors.New("boom")
+		}},
+		recorder: event.NewNopRecorder(),
+	}
+	e.observeValidation(context.Background(), cr, cluster, &kopsapi.InstanceGroupList{}, "")
+
+	if got := testutil.ToFloat64(metrics.ClusterReady.WithLabelValues(cluster.Name)); got != 0 {
+		t.Errorf("\nA cluster that could not be validated should be reported not ready.\ne.observeValidation(...): want 0, got %v\n", got)
+	}
+	if got := cr.Status.GetCondition(v1alpha1.TypeClusterValidated).Reason; got != v1alpha1.ReasonValidationError {
+		t.Errorf("e.observeValidation(...): want reason %s, got %s", v1alpha1.ReasonValidationError, got)
+	}
+}
+
+func TestInstrumentedExternalSpans(t *testing.T) {
+	errBoom := errors.New("boom")
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	e := &instrumentedExternal{
+		ExternalClient: managed.ExternalClientFns{
+			ObserveFn: func(_ context.Context, _ resource.Managed) (managed.ExternalObservation, error) {
+				return managed.ExternalObservation{ResourceExists: true}, nil
+			},
+			CreateFn: func(ctx context.Context, _ resource.Managed) (managed.ExternalCreation, error) {
+				_, span := tracing.Start(ctx, "ApplyCluster")
+				tracing.End(span, nil)
+				return managed.ExternalCreation{}, nil
+			},
+			UpdateFn: func(_ context.Context, _ resource.Managed) (managed.ExternalUpdate, error) {
+				return managed.ExternalUpdate{}, errBoom
+			},
+		},
+		cluster: "test.example.com",
+		name:    "test",
+		history: newHistory(),
+	}
+	cr := &v1alpha1.Kops{}
+	_, _ = e.Observe(context.Background(), cr)
+	_, _ = e.Create(context.Background(), cr)
+	_, _ = e.Update(context.Background(), cr)
+
+	type span struct {
+		Name       string
+		Parent     string
+		Attributes []attribute.KeyValue
+		Status     codes.Code
+	}
+	stubs := exporter.GetSpans()
+	names := map[string]string{}
+	for _, s := range stubs {
+		names[s.SpanContext.SpanID().String()] = s.Name
+	}
+	got := make([]span, 0, len(stubs))
+	for _, s := range stubs {
+		got = append(got, span{Name: s.Name, Parent: names[s.Parent.SpanID().String()], Attributes: s.Attributes, Status: s.Status.Code})
+	}
+
+	cluster := []attribute.KeyValue{tracing.Cluster("test.example.com")}
+	want := []span{
+		{Name: opObserve, Attributes: cluster, Status: codes.Unset},
+		{Name: "ApplyCluster", Parent: opCreate, Status: codes.Unset},
+		{Name: opCreate, Attributes: cluster, Status: codes.Unset},
+		{Name: opUpdate, Attributes: cluster, Status: codes.Error},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateEmpty(), cmp.Comparer(func(a, b attribute.KeyValue) bool { return a == b })); diff != "" {
+		t.Errorf("\nEvery operation should be traced, with the spans of its steps as children and its error recorded.\n-want, +got:\n%s\n", diff)
+	}
+}
+
+func TestInstrumentedExternalForget(t *testing.T) {
+	type forgotten struct {
+		Name    string
+		Cluster string
+		Owned   bool
+	}
+
+	cases := map[string]struct {
+		reason  string
+		deleted bool
+		exists  bool
+		owned   func() bool
+		want    []forgotten
+	}{
+		"Exists": {
+			reason:  "Nothing should be forgotten while the cluster of a deleted resource exists.",
+			deleted: true,
+			exists:  true,
+		},
+		"NotDeleted": {
+			reason: "Nothing should be forgotten for a resource that isn't deleted.",
+		},
+		"Owned": {
+			reason:  "Everything held for a deleted resource and its cluster should be forgotten once the cluster is gone.",
+			deleted: true,
+			owned:   func() bool { return true },
+			want:    []forgotten{{Name: "test", Cluster: "test.example.com", Owned: true}},
+		},
+		"Yielded": {
+			reason:  "What is held for the cluster a deleted resource left to its owner should not be forgotten.",
+			deleted: true,
+			owned:   func() bool { return false },
+			want:    []forgotten{{Name: "test", Cluster: "test.example.com", Owned: false}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []forgotten
+			e := &instrumentedExternal{
+				ExternalClient: managed.ExternalClientFns{
+					ObserveFn: func(_ context.Context, _ resource.Managed) (managed.ExternalObservation, error) {
+						return managed.ExternalObservation{ResourceExists: tc.exists}, nil
+					},
+				},
+				cluster: "test.example.com",
+				name:    "test",
+				history: newHistory(),
+				forget: func(name, cluster string, _ types.UID, owned bool) {
+					got = append(got, forgotten{Name: name, Cluster: cluster, Owned: owned})
+				},
+				owned: tc.owned,
+			}
+			cr := &v1alpha1.Kops{}
+			if tc.deleted {
+				cr.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+			}
+			_, _ = e.Observe(context.Background(), cr)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ne.Observe(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
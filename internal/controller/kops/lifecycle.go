@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+// A lifecycleEvent is something observed or done to a cluster that may move
+// it to another phase of its lifecycle.
+type lifecycleEvent string
+
+const (
+	// lifecycleNotFound means the cluster was not found in its state store.
+	lifecycleNotFound lifecycleEvent = "NotFound"
+
+	// lifecycleCreate means the cluster was registered in its state store.
+	lifecycleCreate lifecycleEvent = "Create"
+
+	// lifecycleUpdate means changes to the cluster are about to be applied.
+	lifecycleUpdate lifecycleEvent = "Update"
+
+	// lifecycleApplied means the cluster was applied to the cloud.
+	lifecycleApplied lifecycleEvent = "Applied"
+
+	// lifecycleValidated means the cluster passed validation.
+	lifecycleValidated lifecycleEvent = "Validated"
+
+	// lifecycleValidationFailed means the cluster failed validation, or
+	// couldn't be validated.
+	lifecycleValidationFailed lifecycleEvent = "ValidationFailed"
+
+	// lifecycleDelete means the cluster is about to be deleted.
+	lifecycleDelete lifecycleEvent = "Delete"
+)
+
+// transitions are the phases each phase moves to on each event. Events that
+// aren't listed for a phase leave clusters in that phase. Clusters without a
+// phase, e.g. because they were created by an earlier version of the
+// provider, are adopted into the phase their first event implies.
+var transitions = map[v1alpha1.ClusterPhase]map[lifecycleEvent]v1alpha1.ClusterPhase{
+	"": {
+		lifecycleNotFound:         v1alpha1.ClusterPhasePending,
+		lifecycleCreate:           v1alpha1.ClusterPhaseProvisioning,
+		lifecycleUpdate:           v1alpha1.ClusterPhaseUpdating,
+		lifecycleApplied:          v1alpha1.ClusterPhaseValidating,
+		lifecycleValidated:        v1alpha1.ClusterPhaseReady,
+		lifecycleValidationFailed: v1alpha1.ClusterPhaseValidating,
+		lifecycleDelete:           v1alpha1.ClusterPhaseDeleting,
+	},
+	v1alpha1.ClusterPhasePending: {
+		lifecycleCreate: v1alpha1.ClusterPhaseProvisioning,
+		lifecycleDelete: v1alpha1.ClusterPhaseDeleting,
+	},
+	v1alpha1.ClusterPhaseProvisioning: {
+		lifecycleNotFound:  v1alpha1.ClusterPhasePending,
+		lifecycleApplied:   v1alpha1.ClusterPhaseValidating,
+		lifecycleValidated: v1alpha1.ClusterPhaseReady,
+		lifecycleDelete:    v1alpha1.ClusterPhaseDeleting,
+	},
+	v1alpha1.ClusterPhaseValidating: {
+		lifecycleNotFound:  v1alpha1.ClusterPhasePending,
+		lifecycleUpdate:    v1alpha1.ClusterPhaseUpdating,
+		lifecycleValidated: v1alpha1.ClusterPhaseReady,
+		lifecycleDelete:    v1alpha1.ClusterPhaseDeleting,
+	},
+	v1alpha1.ClusterPhaseReady: {
+		lifecycleNotFound:         v1alpha1.ClusterPhasePending,
+		lifecycleUpdate:           v1alpha1.ClusterPhaseUpdating,
+		lifecycleValidationFailed: v1alpha1.ClusterPhaseValidating,
+		lifecycleDelete:           v1alpha1.ClusterPhaseDeleting,
+	},
+	v1alpha1.ClusterPhaseUpdating: {
+		lifecycleNotFound: v1alpha1.ClusterPhasePending,
+		lifecycleApplied:  v1alpha1.ClusterPhaseValidating,
+		lifecycleDelete:   v1alpha1.ClusterPhaseDeleting,
+	},
+	// Deleting is final; the Kops resource goes away with its cluster.
+	v1alpha1.ClusterPhaseDeleting: {},
+}
+
+// transition moves the cluster of the supplied Kops resource to the phase the
+// supplied event leads to from its current phase, recording when it did.
+// It returns true if the phase changed.
+func transition(cr *v1alpha1.Kops, ev lifecycleEvent, now time.Time) bool {
+	from := cr.Status.AtProvider.Phase
+	to, ok := transitions[from][ev]
+	if !ok || to == from {
+		return false
+	}
+	cr.Status.AtProvider.Phase = to
+	cr.Status.AtProvider.PhaseTransitionTime = &metav1.Time{Time: now}
+	return true
+}
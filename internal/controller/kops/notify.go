@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errNotify = "cannot send %s notification"
+)
+
+const (
+	reasonNotification event.Reason = "CannotSendNotification"
+)
+
+// notify sends a notification of a lifecycle transition of the cluster of the
+// supplied Kops resource to the resource's notification sink, if it has one.
+// Notifications are best effort; a sink that can't be reached must not hold
+// up the cluster, so failures are reported as events.
+func (c *external) notify(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, typ, message string) {
+	sink := cr.Spec.ForProvider.Notifications
+	if sink == nil || c.notifier == nil {
+		return
+	}
+	n := util.Notification{
+		Type:     typ,
+		Cluster:  cluster.ObjectMeta.Name,
+		Resource: cr.GetName(),
+		UID:      string(cr.GetUID()),
+		Message:  message,
+		Time:     time.Now(),
+	}
+	sctx, span := tracing.Start(ctx, "Notify")
+	err := c.notifier.Notify(sctx, *sink, n)
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonNotification, errors.Wrapf(err, errNotify, typ)))
+	}
+}
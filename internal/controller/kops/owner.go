@@ -0,0 +1,62 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+// claimCluster records the supplied Kops resource in the state store as the
+// one managing the supplied cluster, unless another resource already manages
+// it, in which case that resource is returned. Two resources with the same
+// external name and state bucket would otherwise keep overwriting each
+// other's spec, and deleting either would delete the cluster of both.
+//
+// Clusters created before ownership was recorded are claimed by the first
+// resource that observes them. A cluster is taken over from another resource
+// only if the adopt annotation names that resource's UID.
+func (c *external) claimCluster(cr *v1alpha1.Kops, cluster *kopsapi.Cluster) (*util.ClusterOwner, error) {
+	owner, err := util.GetClusterOwner(c.kopsClientset, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if owner != nil && owner.UID == string(cr.GetUID()) {
+		return nil, nil
+	}
+	if owner != nil && owner.UID != cr.GetAnnotations()[v1alpha1.AnnotationKeyAdopt] {
+		return owner, nil
+	}
+	// Claiming a cluster writes to its state store, which a read-only
+	// provider doesn't.
+	if c.readOnly {
+		return nil, nil
+	}
+	if err := util.SetClusterOwner(c.kopsClientset, cluster, util.ClusterOwner{Name: cr.GetName(), UID: string(cr.GetUID())}); err != nil {
+		return nil, err
+	}
+	if owner != nil {
+		c.recorder.Event(cr, event.Normal(reasonAdoptedCluster, fmt.Sprintf("Took over cluster %s from Kops resource %s", cluster.ObjectMeta.Name, owner.Name)))
+	}
+	return nil, nil
+}
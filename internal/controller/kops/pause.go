@@ -0,0 +1,41 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+const errPaused = "refusing to change Kops cluster while reconciling is paused by the " + v1alpha1.AnnotationKeyPaused + " annotation"
+
+// paused returns true if reconciling the supplied Kops resource is paused. The
+// version of crossplane-runtime the provider is built with doesn't honour the
+// paused annotation, so the external client does: a paused resource's cluster
+// is never changed, and it is only observed, to refresh its connection
+// details, if the provider is configured to.
+func paused(cr *v1alpha1.Kops) bool {
+	return cr.GetAnnotations()[v1alpha1.AnnotationKeyPaused] == "true"
+}
+
+// pausedError is returned by operations that would change the cluster of a
+// paused Kops resource. It waits for the resource to be resumed rather than
+// failing, so that it doesn't trip the circuit breaker.
+func pausedError() error {
+	return waitError{error: errors.New(errPaused), reason: v1alpha1.ReasonPaused}
+}
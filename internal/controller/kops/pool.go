@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/metrics"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+// instanceGroupParallelism is how many instance groups of a cluster are
+// written to its state store at once.
+const instanceGroupParallelism = 4
+
+// An applyPool bounds how many applies and deletes run at once. Applying or
+// deleting a cluster can take many minutes, during which it holds one of the
+// controller's reconcile workers. Bounding them below the number of workers
+// leaves the remaining workers free to observe every other cluster, and to
+// refresh their kubeconfigs.
+type applyPool struct {
+	slots chan struct{}
+}
+
+// newApplyPool returns a pool of the supplied size, or nil if the size is not
+// positive. A nil pool doesn't bound applies.
+func newApplyPool(size int) *applyPool {
+	if size <= 0 {
+		return nil
+	}
+	return &applyPool{slots: make(chan struct{}, size)}
+}
+
+// acquire takes a slot of the pool, returning a function that gives it back.
+// It never waits for a slot; if the pool is full it returns an error, and the
+// operation is retried by a later reconcile.
+func (p *applyPool) acquire() (func(), error) {
+	if p == nil {
+		return func() {}, nil
+	}
+	select {
+	case p.slots <- struct{}{}:
+		metrics.ApplyWorkersBusy.Set(float64(len(p.slots)))
+		return func() {
+			<-p.slots
+			metrics.ApplyWorkersBusy.Set(float64(len(p.slots)))
+		}, nil
+	default:
+		return nil, waitError{error: errors.Errorf("all %d apply workers are busy", cap(p.slots)), reason: v1alpha1.ReasonApplyThrottled}
+	}
+}
+
+// forEachInstanceGroup calls fn for each of the supplied instance groups,
+// running up to instanceGroupParallelism calls at once. Every instance group
+// is attempted even if some fail, so that one bad node pool doesn't hold back
+// the others; the failures are returned together, each naming its instance
+// group.
+func forEachInstanceGroup(ctx context.Context, igs []kopsapi.InstanceGroupSpec, fn func(ctx context.Context, ig *kopsapi.InstanceGroup) error) error {
+	errs := make([]error, len(igs))
+	sem := make(chan struct{}, instanceGroupParallelism)
+	wg := sync.WaitGroup{}
+	for i := range igs {
+		ig := util.CreateInstanceGroupSpec(igs[i])
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			if err := fn(ctx, ig); err != nil {
+				errs[i] = errors.Wrapf(err, "instance group %q", ig.ObjectMeta.Name)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return kerrors.NewAggregate(errs)
+}
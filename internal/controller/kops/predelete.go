@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errPreDeleteWebhook = "cannot notify pre-delete webhook"
+	errDrainNodes       = "cannot drain nodes"
+	errWaitingForDrain  = "waiting for %d pods to be evicted before deleting the cluster"
+)
+
+const (
+	reasonDrainedNodes     event.Reason = "DrainedNodes"
+	reasonCannotDrainNodes event.Reason = "CannotDrainNodes"
+)
+
+// defaultDrainTimeout is how long nodes are drained for before a cluster is
+// deleted anyway, unless the Kops resource says otherwise.
+const defaultDrainTimeout = 10 * time.Minute
+
+// preDelete notifies the pre-delete webhook of the supplied Kops resource and
+// drains the nodes of its cluster, if it is configured to, before the cloud
+// resources of the cluster are deleted. Draining spans reconciles: it returns
+// an error waiting for pods to be evicted until none remain or the drain
+// times out. Progress is recorded in the status, so that a step that
+// completed isn't repeated.
+func (c *external) preDelete(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, drain bool) error {
+	p := cr.Spec.ForProvider.PreDelete
+	if p == nil {
+		return nil
+	}
+	obs := cr.Status.AtProvider.PreDelete
+	if obs == nil {
+		obs = &v1alpha1.PreDeleteObservation{StartedTime: metav1.Now()}
+		cr.Status.AtProvider.PreDelete = obs
+	}
+
+	if p.Webhook != nil && !obs.Notified && c.notifier != nil {
+		n := util.Notification{
+			Type:     util.NotificationDeletionStarting,
+			Cluster:  cluster.ObjectMeta.Name,
+			Resource: cr.GetName(),
+			UID:      string(cr.GetUID()),
+			Message:  "Cluster is about to be deleted",
+			Time:     time.Now(),
+		}
+		sctx, span := tracing.Start(ctx, "NotifyPreDelete")
+		err := c.notifier.Notify(sctx, *p.Webhook, n)
+		tracing.End(span, err)
+		if err != nil {
+			return errors.Wrap(err, errPreDeleteWebhook)
+		}
+		obs.Notified = true
+	}
+
+	if !p.DrainNodes || !drain || obs.Drained {
+		return nil
+	}
+	if m := reachabilityMode(cr); m == v1alpha1.ReachabilitySkipValidation || m == v1alpha1.ReachabilityCloudOnly {
+		c.recorder.Event(cr, event.Warning(reasonCannotDrainNodes, errors.Errorf("nodes are not drained because the %s reachability mode never connects to the API server", m)))
+		obs.Drained = true
+		return nil
+	}
+	timeout := defaultDrainTimeout
+	if p.DrainTimeout != nil {
+		timeout = p.DrainTimeout.Duration
+	}
+
+	sctx, span := tracing.Start(ctx, "DrainNodes")
+	keyset, err := util.GetCAKeysetFingerprint(cluster, c.kopsClientset)
+	var kube kubernetes.Interface
+	if err == nil {
+		kube, err = c.validationClient(sctx, cr, cluster, keyset)
+	}
+	var remaining int
+	if err == nil {
+		remaining, err = util.DrainNodes(sctx, kube)
+	}
+	tracing.End(span, err)
+
+	switch {
+	case err == nil && remaining == 0:
+		obs.RemainingPods = 0
+		obs.Drained = true
+		c.recorder.Event(cr, event.Normal(reasonDrainedNodes, "Drained the nodes of the cluster"))
+		return nil
+	case time.Since(obs.StartedTime.Time) >= timeout:
+		msg := fmt.Sprintf("deleting the cluster after draining its nodes timed out with %d pods remaining", remaining)
+		if err != nil {
+			msg = fmt.Sprintf("deleting the cluster after draining its nodes timed out: %s", err)
+		}
+		c.recorder.Event(cr, event.Warning(reasonCannotDrainNodes, errors.New(msg)))
+		obs.Drained = true
+		return nil
+	case err != nil:
+		return errors.Wrap(err, errDrainNodes)
+	}
+	obs.RemainingPods = remaining
+	return waitError{error: errors.Errorf(errWaitingForDrain, remaining), reason: v1alpha1.ReasonDrainingNodes}
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/sts"
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errPreflight       = "Kops cluster failed preflight checks"
+	errUnregister      = "cannot remove Kops cluster state after failed preflight checks"
+	errListAssets      = "cannot list Kops cluster assets"
+	errAssetsExclusive = "assets.containerRegistry and assets.containerProxy are mutually exclusive"
+	errStateStoreWrite = "cannot write to Kops state store"
+	errSimulate        = "cannot simulate cloud permissions"
+	errDNS             = "Kops cluster DNS is misconfigured"
+	errQuotas          = "cannot check cloud quotas"
+	errAPICertificate  = "cannot check API load balancer certificate"
+)
+
+const (
+	reasonPreflightFailed event.Reason = "PreflightFailed"
+	reasonQuotaExceeded   event.Reason = "InsufficientQuota"
+)
+
+// assetTimeout bounds each request made to verify an asset mirror.
+const assetTimeout = 10 * time.Second
+
+// A preflightCheck verifies that a cluster can be applied before it first is.
+// It returns the reason the cluster can't be applied and an error describing
+// why, or an empty reason and nil if it can.
+type preflightCheck func(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud) (xpv1.ConditionReason, error)
+
+// preflight runs the preflight checks of a cluster in order, stopping at the
+// first that fails. Failures are reported through the PreflightChecked
+// condition, so that a cluster that can't be applied fails fast rather than
+// being left half applied.
+func (c *external) preflight(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud) error {
+	sctx, span := tracing.Start(ctx, "Preflight")
+	err := c.runPreflightChecks(sctx, cr, cluster, cloud)
+	tracing.End(span, err)
+	return err
+}
+
+func (c *external) runPreflightChecks(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud) error {
+	for _, check := range []preflightCheck{
+		c.checkPermissions,
+		c.checkDNS,
+		c.checkAPICertificate,
+		c.checkQuotas,
+		c.checkAssets,
+	} {
+		reason, err := check(ctx, cr, cluster, cloud)
+		if err != nil {
+			cr.Status.SetConditions(v1alpha1.PreflightFailed(reason, err.Error()))
+			c.recorder.Event(cr, event.Warning(reasonPreflightFailed, err))
+			return errors.Wrap(err, errPreflight)
+		}
+	}
+	cr.Status.SetConditions(v1alpha1.PreflightSucceeded())
+	return nil
+}
+
+// unregister removes a cluster that failed its preflight checks from the
+// state store, including the files the provider wrote below its state such as
+// its owner marker, which kops otherwise refuses to delete.
+func (c *external) unregister(ctx context.Context, cluster *kopsapi.Cluster) error {
+	if err := util.RemoveProviderState(c.kopsClientset, cluster); err != nil {
+		return err
+	}
+	return c.kopsClientset.DeleteCluster(ctx, cluster)
+}
+
+// checkPermissions verifies that the provider's credentials can create the
+// cluster, if the Kops resource asks for it.
+func (c *external) checkPermissions(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud) (xpv1.ConditionReason, error) {
+	if p := cr.Spec.ForProvider.Preflight; p == nil || !p.Permissions {
+		return "", nil
+	}
+
+	if err := util.CheckStateStoreWritable(c.kopsClientset, cluster); err != nil {
+		return v1alpha1.ReasonPermissionsMissing, errors.Wrap(err, errStateStoreWrite)
+	}
+
+	awsCloud, ok := cloud.(awsup.AWSCloud)
+	if !ok {
+		return "", nil
+	}
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(awsCloud.Region())})
+	if err != nil {
+		return v1alpha1.ReasonPermissionsMissing, errors.Wrap(err, errSimulate)
+	}
+	missing, err := util.GetMissingAWSPermissions(sts.New(sess), awsCloud.IAM(), util.AWSClusterActions)
+	if err != nil {
+		return v1alpha1.ReasonPermissionsMissing, errors.Wrap(err, errSimulate)
+	}
+	if len(missing) > 0 {
+		return v1alpha1.ReasonPermissionsMissing, errors.Errorf("cloud credentials are missing permissions: %s", strings.Join(missing, ", "))
+	}
+	return "", nil
+}
+
+// checkDNS verifies that the hosted zone of a cluster that isn't using gossip
+// DNS exists and resolves, without which its nodes could never find the API
+// server.
+func (c *external) checkDNS(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud) (xpv1.ConditionReason, error) {
+	if err := util.CheckClusterDNS(cluster, cr.Spec.ForProvider.Domain, cloud, net.LookupNS); err != nil {
+		return v1alpha1.ReasonDNSMisconfigured, errors.Wrap(err, errDNS)
+	}
+	return "", nil
+}
+
+// checkAPICertificate verifies that the ACM certificate served by the API load
+// balancer, if any, has been issued. A load balancer can't be given a
+// certificate that is still pending validation.
+func (c *external) checkAPICertificate(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud) (xpv1.ConditionReason, error) {
+	arn := util.GetAPICertificate(cluster)
+	if !strings.HasPrefix(arn, "arn:aws:acm:") {
+		return "", nil
+	}
+	awsCloud, ok := cloud.(awsup.AWSCloud)
+	if !ok {
+		return "", nil
+	}
+
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(awsCloud.Region())})
+	if err != nil {
+		return v1alpha1.ReasonCertificateNotIssued, errors.Wrap(err, errAPICertificate)
+	}
+	if err := util.CheckACMCertificateIssued(acm.New(sess), arn); err != nil {
+		err = errors.Wrap(err, errAPICertificate)
+		if util.IsCertificatePending(err) {
+			err = waitError{error: err, reason: v1alpha1.ReasonCertificatePending}
+		}
+		return v1alpha1.ReasonCertificateNotIssued, err
+	}
+	return "", nil
+}
+
+// checkQuotas warns about cloud quotas that are too low to create the cluster,
+// if the Kops resource asks for it. Quotas are only ever warned about; a quota
+// may be raised before it's hit, and cloud providers don't report every limit
+// that applies, so a check can be wrong either way.
+func (c *external) checkQuotas(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud) (xpv1.ConditionReason, error) {
+	if p := cr.Spec.ForProvider.Preflight; p == nil || !p.Quotas {
+		return "", nil
+	}
+	awsCloud, ok := cloud.(awsup.AWSCloud)
+	if !ok {
+		return "", nil
+	}
+
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(awsCloud.Region())})
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonQuotaExceeded, errors.Wrap(err, errQuotas)))
+		return "", nil
+	}
+	shortfalls, err := util.GetAWSQuotaShortfalls(awsCloud.EC2(), awsCloud.Autoscaling(), servicequotas.New(sess), cluster, util.DesiredInstanceGroupSpecs(cr))
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonQuotaExceeded, errors.Wrap(err, errQuotas)))
+		return "", nil
+	}
+	if len(shortfalls) > 0 {
+		c.recorder.Event(cr, event.Warning(reasonQuotaExceeded, errors.Errorf("cloud quotas may be too low to create the cluster: %s", strings.Join(shortfalls, "; "))))
+	}
+	return "", nil
+}
+
+// checkAssets verifies that the asset mirrors of an air-gapped cluster hold
+// every file and image the cluster needs at its Kubernetes version.
+func (c *external) checkAssets(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, cloud fi.Cloud) (xpv1.ConditionReason, error) {
+	a := cluster.Spec.Assets
+	if a == nil || (a.ContainerRegistry == nil && a.ContainerProxy == nil && a.FileRepository == nil) {
+		return "", nil
+	}
+	if a.ContainerRegistry != nil && a.ContainerProxy != nil {
+		return v1alpha1.ReasonAssetsUnavailable, errors.New(errAssetsExclusive)
+	}
+
+	// A dry run that only collects assets is what kops get assets does.
+	applyCmd := &cloudup.ApplyClusterCmd{
+		Cloud:      cloud,
+		Cluster:    cluster.DeepCopy(),
+		Clientset:  c.kopsClientset,
+		TargetName: cloudup.TargetDryRun,
+		GetAssets:  true,
+
+		LifecycleOverrides: util.GetLifecycleOverrides(cr.Spec.ForProvider.LifecycleOverrides),
+	}
+	if err := c.applier.Apply(ctx, applyCmd); err != nil {
+		return v1alpha1.ReasonAssetsUnavailable, errors.Wrap(err, errListAssets)
+	}
+
+	missing := util.GetUnavailableAssets(ctx, &http.Client{Timeout: assetTimeout}, applyCmd.FileAssets, applyCmd.ImageAssets)
+	if len(missing) > 0 {
+		return v1alpha1.ReasonAssetsUnavailable, errors.Errorf("assets missing from the configured mirrors: %s", strings.Join(missing, ", "))
+	}
+	return "", nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/kopslog"
+)
+
+const (
+	errRecordApplyProgress = "cannot record progress of Kops cluster apply"
+)
+
+const (
+	reasonApplyProgress event.Reason = "ApplyProgress"
+)
+
+// applyProgressInterval is how often the progress of an apply is recorded at
+// most.
+const applyProgressInterval = 30 * time.Second
+
+// watchApplyProgress records the progress of the tasks kops runs to apply the
+// supplied cluster in the status of the supplied Kops resource and as events,
+// at most once per applyProgressInterval, until the returned function is
+// called. The status is written as progress is made, because the reconciler
+// only writes it once the apply returns, which may take many minutes. If it
+// can't be written, e.g. because the resource changed meanwhile, it is only
+// recorded in events for the rest of the apply.
+func (c *external) watchApplyProgress(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) func() {
+	var mu sync.Mutex
+	var last time.Time
+	stopped, writeStatus := false, true
+
+	stop := kopslog.WatchProgress(cluster.GetName(), func(p kopslog.Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped || time.Since(last) < applyProgressInterval {
+			return
+		}
+		last = time.Now()
+		cr.Status.AtProvider.ApplyProgress = &v1alpha1.ApplyProgressObservation{
+			DoneTasks:   p.Done,
+			TotalTasks:  p.Total,
+			CurrentTask: p.Task,
+			UpdatedTime: metav1.NewTime(last),
+		}
+		c.recorder.Event(cr, event.Normal(reasonApplyProgress, applyProgressMessage(p)))
+		if !writeStatus {
+			return
+		}
+		if err := c.status.Update(ctx, cr); err != nil {
+			writeStatus = false
+			c.recorder.Event(cr, event.Warning(reasonApplyProgress, errors.Wrap(err, errRecordApplyProgress)))
+		}
+	})
+
+	return func() {
+		stop()
+		// Wait for progress being recorded, so that the caller owns the Kops
+		// resource again once this returns.
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+	}
+}
+
+func applyProgressMessage(p kopslog.Progress) string {
+	msg := fmt.Sprintf("%d/%d tasks done", p.Done, p.Total)
+	if p.Task != "" && p.Done < p.Total {
+		msg += ", currently running " + p.Task
+	}
+	return msg
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"strings"
+	"text/template"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+const (
+	errPublishAdditionalSecret = "cannot create or update additional connection secret %s/%s"
+	errRenderSecretName        = "cannot render publishConnectionDetailsTo name template"
+)
+
+// A secretMirrorPublisher publishes connection details to the additional
+// connection secrets of a Kops resource, alongside the secret referenced by
+// its writeConnectionSecretToRef.
+type secretMirrorPublisher struct {
+	secret resource.Applicator
+}
+
+func newSecretMirrorPublisher(c client.Client) *secretMirrorPublisher {
+	return &secretMirrorPublisher{
+		secret: resource.NewApplicatorWithRetry(resource.NewAPIPatchingApplicator(c),
+			resource.IsAPIErrorWrapped, nil),
+	}
+}
+
+// PublishConnection publishes the supplied ConnectionDetails to every
+// additional connection secret of the supplied Kops resource.
+func (p *secretMirrorPublisher) PublishConnection(ctx context.Context, so resource.ConnectionSecretOwner, c managed.ConnectionDetails) (bool, error) {
+	cr, ok := so.(*v1alpha1.Kops)
+	if !ok {
+		return false, errors.New(errNotKops)
+	}
+
+	published := false
+	for _, ref := range cr.Spec.AdditionalConnectionSecretRefs {
+		s := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       ref.Namespace,
+				Name:            ref.Name,
+				OwnerReferences: []metav1.OwnerReference{meta.AsController(meta.TypedReferenceTo(cr, v1alpha1.KopsGroupVersionKind))},
+			},
+			Type: resource.SecretTypeConnection,
+			Data: c,
+		}
+		err := p.secret.Apply(ctx, s,
+			resource.ConnectionSecretMustBeControllableBy(cr.GetUID()),
+			resource.AllowUpdateIf(func(current, desired runtime.Object) bool {
+				return !cmp.Equal(current.(*corev1.Secret).Data, desired.(*corev1.Secret).Data, cmpopts.EquateEmpty())
+			}),
+		)
+		if resource.IsNotAllowed(err) {
+			continue
+		}
+		if err != nil {
+			return published, errors.Wrapf(err, errPublishAdditionalSecret, ref.Namespace, ref.Name)
+		}
+		published = true
+	}
+
+	return published, nil
+}
+
+// UnpublishConnection is a no-op. Additional connection secrets are
+// controlled by the Kops resource and garbage collected with it.
+func (p *secretMirrorPublisher) UnpublishConnection(_ context.Context, _ resource.ConnectionSecretOwner, _ managed.ConnectionDetails) error {
+	return nil
+}
+
+// A templatedStorePublisher renders the name of a Kops resource's
+// publishConnectionDetailsTo as a Go template before handing the resource to
+// the wrapped publisher. This allows per-cluster secret store paths such as
+// kops/{{ .ClusterName }}/kubeconfig.
+type templatedStorePublisher struct {
+	managed.ConnectionPublisher
+}
+
+// PublishConnection publishes the supplied ConnectionDetails under the
+// rendered secret name.
+func (p *templatedStorePublisher) PublishConnection(ctx context.Context, so resource.ConnectionSecretOwner, c managed.ConnectionDetails) (bool, error) {
+	rendered, err := renderPublishConnectionDetailsTo(so)
+	if err != nil {
+		return false, err
+	}
+	return p.ConnectionPublisher.PublishConnection(ctx, rendered, c)
+}
+
+// UnpublishConnection unpublishes the supplied ConnectionDetails from the
+// rendered secret name.
+func (p *templatedStorePublisher) UnpublishConnection(ctx context.Context, so resource.ConnectionSecretOwner, c managed.ConnectionDetails) error {
+	rendered, err := renderPublishConnectionDetailsTo(so)
+	if err != nil {
+		return err
+	}
+	return p.ConnectionPublisher.UnpublishConnection(ctx, rendered, c)
+}
+
+// secretNameData is the data available to publishConnectionDetailsTo name
+// templates.
+type secretNameData struct {
+	Name         string
+	ExternalName string
+	Domain       string
+	ClusterName  string
+}
+
+// renderPublishConnectionDetailsTo returns a copy of the supplied Kops
+// resource whose publishConnectionDetailsTo name has been rendered.
+func renderPublishConnectionDetailsTo(so resource.ConnectionSecretOwner) (resource.ConnectionSecretOwner, error) {
+	cr, ok := so.(*v1alpha1.Kops)
+	if !ok {
+		return nil, errors.New(errNotKops)
+	}
+
+	p := cr.GetPublishConnectionDetailsTo()
+	if p == nil || !strings.Contains(p.Name, "{{") {
+		return cr, nil
+	}
+
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(p.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, errRenderSecretName)
+	}
+
+	d := secretNameData{
+		Name:         cr.GetName(),
+		ExternalName: meta.GetExternalName(cr),
+		Domain:       cr.Spec.ForProvider.Domain,
+	}
+	d.ClusterName = d.ExternalName + "." + d.Domain
+
+	b := &strings.Builder{}
+	if err := tmpl.Execute(b, d); err != nil {
+		return nil, errors.Wrap(err, errRenderSecretName)
+	}
+
+	rendered := cr.DeepCopy()
+	rendered.GetPublishConnectionDetailsTo().Name = b.String()
+	return rendered, nil
+}
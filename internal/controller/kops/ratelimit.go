@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"golang.org/x/time/rate"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+// awsLimiters rate limits the AWS API requests kops makes on behalf of every
+// Kops resource. AWS throttles requests per account and region, so each
+// region gets its own limiter, shared by all clusters in it.
+type awsLimiters struct {
+	qps   rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// newAWSLimiters returns limiters allowing the supplied requests per second
+// and burst in each region, or nil if the requests per second are not
+// positive. A nil awsLimiters doesn't rate limit requests.
+func newAWSLimiters(qps float64, burst int) *awsLimiters {
+	if qps <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &awsLimiters{qps: rate.Limit(qps), burst: burst, limiters: map[string]*rate.Limiter{}}
+}
+
+// limit rate limits the supplied cloud if it is an AWS cloud.
+func (l *awsLimiters) limit(cloud fi.Cloud) {
+	if l == nil {
+		return
+	}
+	aws, ok := cloud.(awsup.AWSCloud)
+	if !ok {
+		return
+	}
+	util.RateLimitAWSCloud(aws, l.get(aws.Region()))
+}
+
+// session returns a session for calling the AWS APIs kops has no client for,
+// with the region and credentials of the supplied cloud, rate limited like
+// the cloud is.
+func (l *awsLimiters) session(cloud awsup.AWSCloud) (*session.Session, error) {
+	var limiter *rate.Limiter
+	if l != nil {
+		limiter = l.get(cloud.Region())
+	}
+	return util.NewAWSSession(cloud, limiter)
+}
+
+func (l *awsLimiters) get(region string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.limiters[region]; ok {
+		return r
+	}
+	r := rate.NewLimiter(l.qps, l.burst)
+	l.limiters[region] = r
+	return r
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kops/pkg/rbac"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errConnectionRBAC = "cannot grant the group of the published kubeconfig access to the Kops cluster"
+)
+
+const (
+	reasonConnectionRBAC event.Reason = "CannotGrantConnectionRBAC"
+)
+
+// connectionGroup returns the group the client certificate of the kubeconfig
+// published as the connection details of a Kops resource is issued into.
+func connectionGroup(cr *v1alpha1.Kops) string {
+	if p := cr.Spec.ForProvider.ConnectionRBAC; p != nil {
+		return p.Group
+	}
+	return rbac.SystemPrivilegedGroup
+}
+
+// observeConnectionRBAC keeps the ClusterRole granted to the group of the
+// published kubeconfig, and its binding, in line with the spec of a Kops
+// resource. The provider's own clients stay in system:masters, so failing to
+// grant access only affects consumers and is reported as an event.
+func (c *external) observeConnectionRBAC(ctx context.Context, cr *v1alpha1.Kops, kube kubernetes.Interface) {
+	p := cr.Spec.ForProvider.ConnectionRBAC
+	if p == nil {
+		return
+	}
+	sctx, span := tracing.Start(ctx, "EnsureConnectionRBAC")
+	err := util.EnsureConnectionRBAC(sctx, kube, p.Group, p.Rules)
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonConnectionRBAC, errors.Wrap(err, errConnectionRBAC)))
+	}
+}
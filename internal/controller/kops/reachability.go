@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errGetValidationCredentials      = "cannot get credentials to validate Kops cluster with"
+	errValidationCredentialsNotFound = "validation credentials secret has no key %q"
+)
+
+// reachabilityMode returns how the API server of the cluster of a Kops
+// resource is reached.
+func reachabilityMode(cr *v1alpha1.Kops) v1alpha1.ReachabilityMode {
+	if p := cr.Spec.ForProvider.Reachability; p != nil && p.Mode != "" {
+		return p.Mode
+	}
+	return v1alpha1.ReachabilityDirect
+}
+
+// rolloutAllowed returns true if the next instance of a rollout may be
+// replaced: once the cluster validated since the last one was, or right away
+// in cloudOnly mode, whose clusters are never validated.
+func rolloutAllowed(cr *v1alpha1.Kops) bool {
+	if reachabilityMode(cr) == v1alpha1.ReachabilityCloudOnly {
+		return true
+	}
+	return cr.Status.GetCondition(v1alpha1.TypeClusterValidated).Status == corev1.ConditionTrue
+}
+
+// proxyURL returns the URL of the proxy the API server of the cluster of a
+// Kops resource is reached through, if any.
+func proxyURL(cr *v1alpha1.Kops) string {
+	if reachabilityMode(cr) != v1alpha1.ReachabilityViaProxy {
+		return ""
+	}
+	return cr.Spec.ForProvider.Reachability.ProxyURL
+}
+
+// validationCredentials are credentials the API server of the cluster of a
+// Kops resource is authenticated to with instead of an issued client
+// certificate. Exactly one of token and kubeconfig is set.
+type validationCredentials struct {
+	token      []byte
+	kubeconfig []byte
+}
+
+// hash returns the hash of the credentials.
+func (v *validationCredentials) hash() string {
+	if v.token != nil {
+		return util.HashCredentials(v.token)
+	}
+	return util.HashCredentials(v.kubeconfig)
+}
+
+// getValidationCredentials returns the validation credentials referenced by
+// the supplied Kops resource, or nil if it references none.
+func (c *external) getValidationCredentials(ctx context.Context, cr *v1alpha1.Kops) (*validationCredentials, error) {
+	p := cr.Spec.ForProvider.Reachability
+	if p == nil || (p.TokenSecretRef == nil && p.KubeconfigSecretRef == nil) {
+		return nil, nil
+	}
+	ref := p.TokenSecretRef
+	if ref == nil {
+		ref = p.KubeconfigSecretRef
+	}
+	s := &corev1.Secret{}
+	if err := c.kube.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, s); err != nil {
+		return nil, errors.Wrap(err, errGetValidationCredentials)
+	}
+	data, ok := s.Data[ref.Key]
+	if !ok {
+		return nil, errors.Wrap(errors.Errorf(errValidationCredentialsNotFound, ref.Key), errGetValidationCredentials)
+	}
+	if p.TokenSecretRef != nil {
+		return &validationCredentials{token: data}, nil
+	}
+	return &validationCredentials{kubeconfig: data}, nil
+}
+
+// skipValidation reports a cluster whose API server the provider can't reach
+// as available without validating it. Results of earlier validations are
+// dropped, as they can no longer be kept up to date.
+func (c *external) skipValidation(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster, msg string) {
+	cr.Status.AtProvider.Validation = nil
+	cr.Status.AtProvider.Nodes = nil
+	cr.Status.AtProvider.GPUPools = nil
+	cr.Status.SetConditions(v1alpha1.ClusterValidationSkipped(msg), xpv1.Available())
+	if transition(cr, lifecycleValidated, time.Now()) {
+		c.notify(ctx, cr, cluster, util.NotificationClusterReady, "Cluster is ready")
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errReadOnly = "refusing to %s while the provider is read-only"
+	errDryRun   = "cannot dry run Kops cluster"
+)
+
+const (
+	reasonReadOnly event.Reason = "ReadOnly"
+	reasonDryRun   event.Reason = "DryRun"
+)
+
+// readOnlyError is returned by operations that would change the cluster of a
+// Kops resource while the provider is read-only. Like pausedError it waits
+// rather than failing, so that it doesn't trip the circuit breaker.
+func readOnlyError(op string) error {
+	return waitError{error: errors.Errorf(errReadOnly, op), reason: v1alpha1.ReasonReadOnly}
+}
+
+// dryRun applies the desired state of the supplied Kops resource to its
+// cluster as a dry run, without writing it to the state store, so that the
+// changes an update would make are reported rather than made. The changes are
+// written to the provider's log by kops. Each generation is only dry run once,
+// so that a fleet imported while the provider is read-only doesn't pay for a
+// dry run on every poll.
+func (c *external) dryRun(ctx context.Context, cr *v1alpha1.Kops) error {
+	if cr.Status.AtProvider.DryRunGeneration == cr.GetGeneration() {
+		return readOnlyError("update the cluster")
+	}
+
+	fileAssets, err := c.getFileAssets(ctx, cr)
+	if err != nil {
+		return err
+	}
+	egressProxy, err := c.getEgressProxy(ctx, cr)
+	if err != nil {
+		return err
+	}
+	cluster := util.CreateClusterSpec(cr)
+	util.MergeFileAssets(&cluster.Spec, fileAssets)
+	util.MergeEgressProxy(&cluster.Spec, egressProxy)
+
+	_, span := tracing.Start(ctx, "BuildCloud")
+	cloud, err := c.buildCloud(cluster)
+	tracing.End(span, err)
+	if err != nil {
+		return errors.Wrap(err, errNewCloud)
+	}
+	if err := cloudup.PerformAssignments(cluster, cloud); err != nil {
+		return errors.Wrap(err, errNewCloudAssignment)
+	}
+
+	igs := util.DesiredInstanceGroupSpecs(cr)
+	instanceGroups := make([]*kopsapi.InstanceGroup, 0, len(igs))
+	for _, ig := range igs {
+		instanceGroups = append(instanceGroups, util.CreateInstanceGroupSpec(ig))
+	}
+	applyCmd := &cloudup.ApplyClusterCmd{
+		Cloud:          cloud,
+		Cluster:        cluster,
+		InstanceGroups: instanceGroups,
+		Clientset:      c.kopsClientset,
+		TargetName:     cloudup.TargetDryRun,
+		DryRun:         true,
+
+		LifecycleOverrides: util.GetLifecycleOverrides(cr.Spec.ForProvider.LifecycleOverrides),
+	}
+	sctx, span := tracing.Start(ctx, "DryRunCluster")
+	err = c.applier.Apply(sctx, applyCmd)
+	tracing.End(span, err)
+	if err != nil {
+		return errors.Wrap(err, errDryRun)
+	}
+
+	msg := fmt.Sprintf("Dry ran generation %d of cluster %s; the changes it would make are in the provider's log", cr.GetGeneration(), cluster.ObjectMeta.Name)
+	if pending := cr.Status.AtProvider.PendingReplacements; len(pending) > 0 {
+		msg += fmt.Sprintf(", and they replace the instances of instance groups %s", strings.Join(pending, ", "))
+	}
+	c.recorder.Event(cr, event.Normal(reasonDryRun, msg))
+	cr.Status.AtProvider.DryRunGeneration = cr.GetGeneration()
+	return readOnlyError("update the cluster")
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/metrics"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errReplicateState = "cannot replicate Kops cluster state"
+)
+
+const (
+	reasonReplicateState  event.Reason = "CannotReplicateState"
+	reasonReplicatedState event.Reason = "ReplicatedState"
+)
+
+// replicateState copies the state of the supplied cluster to its replica
+// state bucket, if it has one. Failures are reported as events and retried on
+// the next observation, so that a replica bucket that is unavailable doesn't
+// fail an apply that already succeeded.
+func (c *external) replicateState(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) {
+	rp := cr.Spec.ForProvider.StateReplica
+	if rp == nil {
+		return
+	}
+	_, span := tracing.Start(ctx, "ReplicateState")
+	path, err := util.GetStateReplicaPath(rp.Bucket, cluster.GetName())
+	var files int
+	if err == nil {
+		files, err = util.ReplicateClusterState(c.kopsClientset, cluster, rp.Bucket)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonReplicateState, errors.Wrap(err, errReplicateState)))
+		return
+	}
+	now := metav1.Now()
+	cr.Status.AtProvider.StateReplica = &v1alpha1.StateReplicaObservation{
+		Path:                     path.Path(),
+		Files:                    files,
+		LastReplicatedTime:       &now,
+		LastReplicatedGeneration: cr.Status.AtProvider.LastAppliedGeneration,
+	}
+	c.recorder.Event(cr, event.Normal(reasonReplicatedState, fmt.Sprintf("Replicated %d files of cluster state to %s", files, path.Path())))
+}
+
+// observeStateReplica replicates the state of the supplied cluster again if
+// its replica is missing its last apply, and reports how far the replica lags
+// behind.
+func (c *external) observeStateReplica(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) {
+	if cr.Spec.ForProvider.StateReplica == nil {
+		cr.Status.AtProvider.StateReplica = nil
+		metrics.StateReplicationLag.DeleteLabelValues(cluster.GetName())
+		return
+	}
+	if stateReplicaLagging(cr, cluster) {
+		c.replicateState(ctx, cr, cluster)
+	}
+	var lag time.Duration
+	if stateReplicaLagging(cr, cluster) {
+		lag = time.Since(cr.Status.AtProvider.LastAppliedTime.Time)
+	}
+	metrics.RecordStateReplicationLag(cluster.GetName(), lag)
+}
+
+// stateReplicaLagging returns true if the replica of the state of the supplied
+// cluster was last replicated before the cluster was last applied, or to a
+// different bucket. A cluster that was never applied has nothing to replicate.
+func stateReplicaLagging(cr *v1alpha1.Kops, cluster *kopsapi.Cluster) bool {
+	applied := cr.Status.AtProvider.LastAppliedTime
+	if applied == nil {
+		return false
+	}
+	obs := cr.Status.AtProvider.StateReplica
+	if obs == nil || obs.LastReplicatedTime == nil || obs.LastReplicatedTime.Before(applied) {
+		return true
+	}
+	path, err := util.GetStateReplicaPath(cr.Spec.ForProvider.StateReplica.Bucket, cluster.GetName())
+	return err != nil || obs.Path != path.Path()
+}
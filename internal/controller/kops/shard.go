@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"hash/fnv"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// A Shard selects the Kops resources reconciled by one of several provider
+// deployments, so that the reconciles and cloud API requests of a large fleet
+// can be spread across them. The zero value selects every resource.
+type Shard struct {
+	// Count is the number of shards Kops resources are spread across by the
+	// hash of their external name. Resources are not spread if it is zero.
+	Count int
+
+	// Index is the shard selected, from zero to Count - 1.
+	Index int
+
+	// Selector further restricts the shard to Kops resources with matching
+	// labels. Every resource matches if it is nil.
+	Selector labels.Selector
+}
+
+// Selects returns true if the supplied Kops resource belongs to the shard.
+func (s Shard) Selects(o client.Object) bool {
+	if s.Selector != nil && !s.Selector.Matches(labels.Set(o.GetLabels())) {
+		return false
+	}
+	if s.Count <= 1 {
+		return true
+	}
+	return shardOf(o, s.Count) == s.Index
+}
+
+// Primary returns true if the shard runs the controllers and background jobs
+// that aren't spread across shards, such as the fleet and migration
+// controllers and the state sweeper. Only the first shard does, so that they
+// don't run once per shard.
+func (s Shard) Primary() bool {
+	return s.Count <= 1 || s.Index == 0
+}
+
+// shardOf returns the shard a Kops resource is spread to. The external name
+// of a Kops resource defaults to its name, and doesn't change once set, so a
+// resource doesn't move between shards when its external name is set.
+func shardOf(o client.Object, count int) int {
+	key := meta.GetExternalName(o)
+	if key == "" {
+		key = o.GetName()
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(count))
+}
+
+// predicate filters the events of Kops resources to those of the shard.
+func (s Shard) predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(s.Selects)
+}
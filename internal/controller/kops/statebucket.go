@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errProvisionStateBucket = "cannot provision state bucket %s"
+)
+
+const (
+	reasonStateBucketProvisioned event.Reason = "ProvisionedStateBucket"
+)
+
+// defaultStateBucketRegion is the region state buckets are created in when
+// the cluster spec doesn't tell the region of the cluster.
+const defaultStateBucketRegion = "us-east-1"
+
+// stateBuckets creates the S3 state buckets of Kops resources that don't
+// exist yet. A bucket is only looked up until it is known to exist, so that
+// reconciles don't pay for it.
+type stateBuckets struct {
+	newS3 func(region string) (s3iface.S3API, error)
+
+	mu    sync.Mutex
+	known map[string]bool
+}
+
+func newStateBuckets() *stateBuckets {
+	return &stateBuckets{
+		newS3: func(region string) (s3iface.S3API, error) {
+			sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+			if err != nil {
+				return nil, err
+			}
+			return s3.New(sess), nil
+		},
+		known: map[string]bool{},
+	}
+}
+
+// ensure creates the S3 state bucket of the supplied Kops resource in the
+// region of its cluster if it doesn't exist, and returns whether it did.
+func (b *stateBuckets) ensure(cr *v1alpha1.Kops) (bool, error) {
+	bucket := util.GetS3StateBucketName(cr.Spec.ForProvider.StateBucket)
+	if bucket == "" {
+		return false, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.known[bucket] {
+		return false, nil
+	}
+
+	region := defaultStateBucketRegion
+	if r := util.GetClusterRegions(util.CreateClusterSpec(cr), ""); len(r) > 0 {
+		region = r[0]
+	}
+	s3API, err := b.newS3(region)
+	if err != nil {
+		return false, errors.Wrapf(err, errProvisionStateBucket, bucket)
+	}
+	created, err := util.ProvisionStateBucket(s3API, bucket, region)
+	if err != nil {
+		return false, errors.Wrapf(err, errProvisionStateBucket, bucket)
+	}
+	b.known[bucket] = true
+	return created, nil
+}
+
+// provisionStateBucket creates the state bucket of the supplied Kops resource
+// if the provider is configured to and it doesn't exist yet.
+func (c *connector) provisionStateBucket(cr *v1alpha1.Kops) error {
+	if c.stateBuckets == nil {
+		return nil
+	}
+	created, err := c.stateBuckets.ensure(cr)
+	if err != nil {
+		return err
+	}
+	if created {
+		c.recorder.Event(cr, event.Normal(reasonStateBucketProvisioned, fmt.Sprintf("Created state bucket %s", util.GetS3StateBucketName(cr.Spec.ForProvider.StateBucket))))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsversion "k8s.io/kops"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/tracing"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errRecommendUpgrades = "cannot evaluate Kops channel for upgrades"
+)
+
+const (
+	reasonRecommendUpgrades event.Reason = "CannotRecommendUpgrades"
+	reasonUpgradeAvailable  event.Reason = "UpgradeAvailable"
+)
+
+// upgradeCheckInterval is how often the kops channel of a cluster is
+// evaluated for upgrades.
+const upgradeCheckInterval = 24 * time.Hour
+
+// observeUpgrades evaluates the kops channel of a cluster for upgrades, if
+// the Kops resource asks for it and the channel wasn't evaluated within the
+// last day. Upgrades are informational, so failing to evaluate the channel is
+// reported as an event and retried on the next observation.
+func (c *external) observeUpgrades(ctx context.Context, cr *v1alpha1.Kops, cluster *kopsapi.Cluster) {
+	if !cr.Spec.ForProvider.RecommendUpgrades {
+		cr.Status.AtProvider.Upgrade = nil
+		return
+	}
+	prev := cr.Status.AtProvider.Upgrade
+	if prev != nil && prev.Channel == cluster.Spec.Channel && time.Since(prev.LastCheckedTime.Time) < upgradeCheckInterval {
+		return
+	}
+
+	_, span := tracing.Start(ctx, "RecommendUpgrades")
+	channel, err := kopsapi.LoadChannel(cluster.Spec.Channel)
+	var obs *v1alpha1.UpgradeObservation
+	if err == nil {
+		obs, err = util.GetUpgradeRecommendation(channel, cluster.Spec.KubernetesVersion, kopsversion.Version)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		c.recorder.Event(cr, event.Warning(reasonRecommendUpgrades, errors.Wrap(err, errRecommendUpgrades)))
+		return
+	}
+	obs.Channel = cluster.Spec.Channel
+	obs.LastCheckedTime = metav1.Now()
+	cr.Status.AtProvider.Upgrade = obs
+
+	// Only newly recommended upgrades are recorded.
+	if obs.Urgency == v1alpha1.UpgradeUrgencyNone || (prev != nil && prev.KubernetesVersion == obs.KubernetesVersion && prev.KopsVersion == obs.KopsVersion && prev.Urgency == obs.Urgency) {
+		return
+	}
+	var to []string
+	if obs.KubernetesVersion != "" {
+		to = append(to, "Kubernetes "+obs.KubernetesVersion)
+	}
+	if obs.KopsVersion != "" {
+		to = append(to, "kops "+obs.KopsVersion)
+	}
+	c.recorder.Event(cr, event.Normal(reasonUpgradeAvailable, fmt.Sprintf("Upgrade %s: the %s channel recommends %s", strings.ToLower(string(obs.Urgency)), obs.Channel, strings.Join(to, " and "))))
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestLeaderElectionConfigure(t *testing.T) {
+	// options are the leader election fields of the manager options, which
+	// can't be compared as a whole.
+	type options struct {
+		LeaderElection  bool
+		ID              string
+		ResourceLock    string
+		LeaseDuration   *time.Duration
+		RenewDeadline   *time.Duration
+		ReleaseOnCancel bool
+	}
+	duration := func(d time.Duration) *time.Duration { return &d }
+
+	type want struct {
+		o   options
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		l      LeaderElection
+		want   want
+	}{
+		"Disabled": {
+			reason: "A single replica should not elect a leader nor release a lease on shutdown.",
+			l:      LeaderElection{ID: "lease", LeaseDuration: 60 * time.Second, RenewDeadline: 50 * time.Second},
+			want: want{o: options{
+				ID:            "lease",
+				ResourceLock:  resourcelock.LeasesResourceLock,
+				LeaseDuration: duration(60 * time.Second),
+				RenewDeadline: duration(50 * time.Second),
+			}},
+		},
+		"Enabled": {
+			reason: "Standby replicas should compete for the configured lease, which a leader releases when it is shut down.",
+			l:      LeaderElection{Enabled: true, ID: "lease", LeaseDuration: 30 * time.Second, RenewDeadline: 20 * time.Second},
+			want: want{o: options{
+				LeaderElection:  true,
+				ID:              "lease",
+				ResourceLock:    resourcelock.LeasesResourceLock,
+				LeaseDuration:   duration(30 * time.Second),
+				RenewDeadline:   duration(20 * time.Second),
+				ReleaseOnCancel: true,
+			}},
+		},
+		"RenewDeadlineTooLong": {
+			reason: "A leader must give up its lease before standby replicas may take it over.",
+			l:      LeaderElection{Enabled: true, ID: "lease", LeaseDuration: 30 * time.Second, RenewDeadline: 30 * time.Second},
+			want:   want{err: errors.New(errRenewDeadline)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			o := ctrl.Options{}
+			err := tc.l.Configure(&o)
+			got := want{err: err}
+			if err == nil {
+				got.o = options{
+					LeaderElection:  o.LeaderElection,
+					ID:              o.LeaderElectionID,
+					ResourceLock:    o.LeaderElectionResourceLock,
+					LeaseDuration:   o.LeaseDuration,
+					RenewDeadline:   o.RenewDeadline,
+					ReleaseOnCancel: o.LeaderElectionReleaseOnCancel,
+				}
+			}
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors(), cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nConfigure(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
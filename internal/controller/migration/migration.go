@@ -0,0 +1,249 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package migration implements the controller of KopsMigrations.
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/ratelimiter"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+const (
+	errGetMigration     = "cannot get KopsMigration"
+	errUpdateStatus     = "cannot update KopsMigration status"
+	errGetSource        = "cannot get source Kops"
+	errSourceNotFound   = "source Kops does not exist"
+	errGetTarget        = "cannot get target Kops"
+	errCreateTarget     = "cannot create target Kops"
+	errTargetNotOwned   = "target Kops exists but was not created by this KopsMigration"
+	errRetireSource     = "cannot delete source Kops"
+	errSameSourceTarget = "target name must differ from the source Kops"
+)
+
+const (
+	reasonMigrate       event.Reason = "CannotMigrate"
+	reasonCreatedTarget event.Reason = "CreatedTarget"
+	reasonRetiring      event.Reason = "RetiringSource"
+	reasonRetiredSource event.Reason = "RetiredSource"
+)
+
+const (
+	// pollInterval is how often the clusters of a migration that hasn't
+	// completed are checked.
+	pollInterval = 30 * time.Second
+
+	// defaultRetireAfter is how long a replacement cluster must have been
+	// ready before its source cluster is retired, unless configured.
+	defaultRetireAfter = time.Hour
+)
+
+// Setup adds a controller that reconciles KopsMigrations.
+func Setup(mgr ctrl.Manager, o controller.Options) error {
+	name := "migration/" + strings.ToLower(v1alpha1.KopsMigrationGroupKind)
+
+	r := &Reconciler{
+		kube:   mgr.GetClient(),
+		log:    o.Logger.WithValues("controller", name),
+		record: event.NewAPIRecorder(mgr.GetEventRecorderFor(name)),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.KopsMigration{}).
+		Complete(ratelimiter.NewReconciler(name, r, o.GlobalRateLimiter))
+}
+
+// A Reconciler reconciles KopsMigrations by creating the Kops resource of the
+// replacement cluster, and retiring the Kops resource of the source cluster
+// once the replacement is ready.
+type Reconciler struct {
+	kube   client.Client
+	log    logging.Logger
+	record event.Recorder
+}
+
+// Reconcile a KopsMigration.
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := r.log.WithValues("request", req)
+
+	m := &v1alpha1.KopsMigration{}
+	if err := r.kube.Get(ctx, req.NamespacedName, m); err != nil {
+		return reconcile.Result{}, errors.Wrap(resource.IgnoreNotFound(err), errGetMigration)
+	}
+	if meta.WasDeleted(m) {
+		// The source and replacement clusters outlive their migration.
+		return reconcile.Result{}, nil
+	}
+
+	res, err := r.migrate(ctx, m)
+	if err != nil {
+		log.Debug("Cannot migrate", "error", err)
+		r.record.Event(m, event.Warning(reasonMigrate, err))
+		m.SetConditions(xpv1.ReconcileError(err))
+		return reconcile.Result{RequeueAfter: pollInterval}, errors.Wrap(r.kube.Status().Update(ctx, m), errUpdateStatus)
+	}
+	m.SetConditions(xpv1.ReconcileSuccess())
+	return res, errors.Wrap(r.kube.Status().Update(ctx, m), errUpdateStatus)
+}
+
+func (r *Reconciler) migrate(ctx context.Context, m *v1alpha1.KopsMigration) (reconcile.Result, error) {
+	if m.Status.Phase == v1alpha1.MigrationPhaseCompleted {
+		m.SetConditions(xpv1.Available())
+		return reconcile.Result{}, nil
+	}
+	if m.Spec.TargetName == m.Spec.SourceRef.Name {
+		return reconcile.Result{}, errors.New(errSameSourceTarget)
+	}
+
+	source := &v1alpha1.Kops{}
+	err := r.kube.Get(ctx, types.NamespacedName{Name: m.Spec.SourceRef.Name}, source)
+	if resource.IgnoreNotFound(err) != nil {
+		return reconcile.Result{}, errors.Wrap(err, errGetSource)
+	}
+	sourceGone := kerrors.IsNotFound(err)
+
+	target := &v1alpha1.Kops{}
+	err = r.kube.Get(ctx, types.NamespacedName{Name: m.Spec.TargetName}, target)
+	if resource.IgnoreNotFound(err) != nil {
+		return reconcile.Result{}, errors.Wrap(err, errGetTarget)
+	}
+	if kerrors.IsNotFound(err) {
+		if sourceGone {
+			return reconcile.Result{}, errors.New(errSourceNotFound)
+		}
+		target = NewTarget(m, source)
+		if err := r.kube.Create(ctx, target); err != nil {
+			return reconcile.Result{}, errors.Wrap(err, errCreateTarget)
+		}
+		r.record.Event(m, event.Normal(reasonCreatedTarget, fmt.Sprintf("Created Kops %s from Kops %s", target.GetName(), source.GetName())))
+	}
+	if target.GetLabels()[v1alpha1.LabelKeyMigration] != m.GetName() {
+		return reconcile.Result{}, errors.New(errTargetNotOwned)
+	}
+
+	m.Status.SourceReady = !sourceGone && ready(source)
+	m.Status.TargetReady = ready(target)
+	if !m.Status.TargetReady {
+		m.Status.Phase = v1alpha1.MigrationPhaseProvisioning
+		m.SetConditions(xpv1.Creating())
+		return reconcile.Result{RequeueAfter: pollInterval}, nil
+	}
+	if m.Status.TargetReadyTime == nil {
+		now := metav1.Now()
+		m.Status.TargetReadyTime = &now
+	}
+	m.SetConditions(xpv1.Available())
+
+	if !m.Spec.RetireSource {
+		m.Status.Phase = v1alpha1.MigrationPhaseReady
+		return reconcile.Result{RequeueAfter: pollInterval}, nil
+	}
+	if sourceGone {
+		now := metav1.Now()
+		m.Status.RetiredTime = &now
+		m.Status.Phase = v1alpha1.MigrationPhaseCompleted
+		r.record.Event(m, event.Normal(reasonRetiredSource, fmt.Sprintf("Retired Kops %s", m.Spec.SourceRef.Name)))
+		return reconcile.Result{}, nil
+	}
+
+	retireAfter := defaultRetireAfter
+	if m.Spec.RetireAfter != nil {
+		retireAfter = m.Spec.RetireAfter.Duration
+	}
+	if wait := time.Until(m.Status.TargetReadyTime.Add(retireAfter)); wait > 0 {
+		m.Status.Phase = v1alpha1.MigrationPhaseReady
+		return reconcile.Result{RequeueAfter: wait}, nil
+	}
+
+	m.Status.Phase = v1alpha1.MigrationPhaseRetiring
+	if !meta.WasDeleted(source) {
+		if err := r.kube.Delete(ctx, source); resource.IgnoreNotFound(err) != nil {
+			return reconcile.Result{}, errors.Wrap(err, errRetireSource)
+		}
+		r.record.Event(m, event.Normal(reasonRetiring, fmt.Sprintf("Deleting Kops %s now that Kops %s is ready", source.GetName(), target.GetName())))
+	}
+	return reconcile.Result{RequeueAfter: pollInterval}, nil
+}
+
+// NewTarget returns the Kops resource of the replacement cluster of the
+// supplied migration, created from the spec of the supplied source. Outputs
+// of the source that would be overwritten by the replacement, like its API
+// DNS names and rendered spec secret, are not copied. Neither are references
+// that identify the source cluster: the credentials its API server is reached
+// with, the certificate of its API names and its dependencies.
+func NewTarget(m *v1alpha1.KopsMigration, source *v1alpha1.Kops) *v1alpha1.Kops {
+	target := &v1alpha1.Kops{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   m.Spec.TargetName,
+			Labels: map[string]string{v1alpha1.LabelKeyMigration: m.GetName()},
+		},
+		Spec: v1alpha1.KopsSpec{
+			ResourceSpec: xpv1.ResourceSpec{
+				DeletionPolicy: source.Spec.DeletionPolicy,
+			},
+			ForProvider: *source.Spec.ForProvider.DeepCopy(),
+		},
+	}
+	if ref := source.Spec.ProviderConfigReference; ref != nil {
+		target.Spec.ProviderConfigReference = &xpv1.Reference{Name: ref.Name}
+	}
+	if ref := m.Spec.WriteConnectionSecretToReference; ref != nil {
+		target.Spec.WriteConnectionSecretToReference = &xpv1.SecretReference{Name: ref.Name, Namespace: ref.Namespace}
+	}
+	meta.SetExternalName(target, m.Spec.TargetName)
+
+	fp := &target.Spec.ForProvider
+	if m.Spec.KubernetesVersion != "" {
+		fp.ClusterSpec.KubernetesVersion = m.Spec.KubernetesVersion
+	}
+	fp.ClusterSpec.MasterPublicName = ""
+	fp.ClusterSpec.MasterInternalName = ""
+	fp.TerraformOutputPath = ""
+	fp.RenderedSpecSecretRef = nil
+	fp.APICertificateRef = nil
+	fp.DependsOn = nil
+	if r := fp.Reachability; r != nil {
+		r.TokenSecretRef = nil
+		r.KubeconfigSecretRef = nil
+	}
+	return target
+}
+
+func ready(cr *v1alpha1.Kops) bool {
+	return cr.GetCondition(xpv1.TypeReady).Status == corev1.ConditionTrue
+}
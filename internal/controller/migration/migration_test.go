@@ -0,0 +1,210 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+func TestReconcile(t *testing.T) {
+	readySince := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	migration := func(mod func(m *v1alpha1.KopsMigration)) *v1alpha1.KopsMigration {
+		m := &v1alpha1.KopsMigration{
+			ObjectMeta: metav1.ObjectMeta{Name: "blue-green"},
+			Spec: v1alpha1.KopsMigrationSpec{
+				SourceRef:  xpv1.Reference{Name: "blue"},
+				TargetName: "green",
+			},
+		}
+		if mod != nil {
+			mod(m)
+		}
+		return m
+	}
+	kops := func(name string, owner string, ready bool) *v1alpha1.Kops {
+		cr := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if owner != "" {
+			cr.SetLabels(map[string]string{v1alpha1.LabelKeyMigration: owner})
+		}
+		if ready {
+			cr.SetConditions(xpv1.Available())
+		}
+		return cr
+	}
+
+	type want struct {
+		phase   v1alpha1.MigrationPhase
+		synced  bool
+		created string
+		deleted string
+	}
+
+	cases := map[string]struct {
+		reason    string
+		migration *v1alpha1.KopsMigration
+		objects   map[string]*v1alpha1.Kops
+		want      want
+	}{
+		"CreateTarget": {
+			reason:    "The replacement cluster should be created from the source when it doesn't exist.",
+			migration: migration(nil),
+			objects:   map[string]*v1alpha1.Kops{"blue": kops("blue", "", true)},
+			want:      want{phase: v1alpha1.MigrationPhaseProvisioning, synced: true, created: "green"},
+		},
+		"TargetNotOwned": {
+			reason:    "A Kops resource the migration didn't create should never be adopted as its replacement.",
+			migration: migration(nil),
+			objects:   map[string]*v1alpha1.Kops{"blue": kops("blue", "", true), "green": kops("green", "", true)},
+			want:      want{},
+		},
+		"KeepSource": {
+			reason:    "The source cluster should be kept once the replacement is ready unless it is retired.",
+			migration: migration(nil),
+			objects:   map[string]*v1alpha1.Kops{"blue": kops("blue", "", true), "green": kops("green", "blue-green", true)},
+			want:      want{phase: v1alpha1.MigrationPhaseReady, synced: true},
+		},
+		"WaitToRetire": {
+			reason: "The source cluster should not be retired before the replacement has been ready for RetireAfter.",
+			migration: migration(func(m *v1alpha1.KopsMigration) {
+				m.Spec.RetireSource = true
+				m.Spec.RetireAfter = &metav1.Duration{Duration: 24 * time.Hour}
+				m.Status.TargetReadyTime = &readySince
+			}),
+			objects: map[string]*v1alpha1.Kops{"blue": kops("blue", "", true), "green": kops("green", "blue-green", true)},
+			want:    want{phase: v1alpha1.MigrationPhaseReady, synced: true},
+		},
+		"RetireSource": {
+			reason: "The source cluster should be deleted once the replacement has been ready for RetireAfter.",
+			migration: migration(func(m *v1alpha1.KopsMigration) {
+				m.Spec.RetireSource = true
+				m.Status.TargetReadyTime = &readySince
+			}),
+			objects: map[string]*v1alpha1.Kops{"blue": kops("blue", "", true), "green": kops("green", "blue-green", true)},
+			want:    want{phase: v1alpha1.MigrationPhaseRetiring, synced: true, deleted: "blue"},
+		},
+		"Completed": {
+			reason: "The migration should complete once the retired source cluster is gone.",
+			migration: migration(func(m *v1alpha1.KopsMigration) {
+				m.Spec.RetireSource = true
+				m.Status.TargetReadyTime = &readySince
+				m.Status.Phase = v1alpha1.MigrationPhaseRetiring
+			}),
+			objects: map[string]*v1alpha1.Kops{"green": kops("green", "blue-green", true)},
+			want:    want{phase: v1alpha1.MigrationPhaseCompleted, synced: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := want{}
+			var status *v1alpha1.KopsMigration
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					switch o := obj.(type) {
+					case *v1alpha1.KopsMigration:
+						tc.migration.DeepCopyInto(o)
+						return nil
+					case *v1alpha1.Kops:
+						cr, ok := tc.objects[key.Name]
+						if !ok {
+							return kerrors.NewNotFound(v1alpha1.SchemeGroupVersion.WithResource("kops").GroupResource(), key.Name)
+						}
+						cr.DeepCopyInto(o)
+					}
+					return nil
+				},
+				MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+					got.created = obj.GetName()
+					if meta.GetExternalName(obj) != obj.GetName() {
+						t.Errorf("Create(...): want external name %q, got %q", obj.GetName(), meta.GetExternalName(obj))
+					}
+					return nil
+				},
+				MockDelete: func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+					got.deleted = obj.GetName()
+					return nil
+				},
+				MockStatusUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+					status = obj.(*v1alpha1.KopsMigration)
+					return nil
+				},
+			}
+			r := &Reconciler{kube: kube, log: logging.NewNopLogger(), record: event.NewNopRecorder()}
+
+			if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "blue-green"}}); err != nil {
+				t.Fatalf("Reconcile(...): %v", err)
+			}
+			got.phase = status.Status.Phase
+			got.synced = status.GetCondition(xpv1.TypeSynced).Equal(xpv1.ReconcileSuccess())
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nReconcile(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestNewTarget(t *testing.T) {
+	m := &v1alpha1.KopsMigration{
+		ObjectMeta: metav1.ObjectMeta{Name: "blue-green"},
+		Spec:       v1alpha1.KopsMigrationSpec{SourceRef: xpv1.Reference{Name: "blue"}, TargetName: "green", KubernetesVersion: "1.24.0"},
+	}
+	source := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "blue"}}
+	source.Spec.ForProvider.Domain = "example.com"
+	source.Spec.ForProvider.ClusterSpec.KubernetesVersion = "1.23.8"
+	source.Spec.ForProvider.ClusterSpec.MasterPublicName = "api.blue.example.com"
+	source.Spec.ForProvider.RenderedSpecSecretRef = &xpv1.SecretReference{Namespace: "default", Name: "blue-spec"}
+	source.Spec.ForProvider.Reachability = &v1alpha1.ReachabilityParameters{
+		Mode:                v1alpha1.ReachabilityViaProxy,
+		ProxyURL:            "socks5://10.0.0.10:1080",
+		TokenSecretRef:      &xpv1.SecretKeySelector{SecretReference: xpv1.SecretReference{Namespace: "default", Name: "blue-token"}, Key: "token"},
+		KubeconfigSecretRef: &xpv1.SecretKeySelector{SecretReference: xpv1.SecretReference{Namespace: "default", Name: "blue-kubeconfig"}, Key: "kubeconfig"},
+	}
+	source.Spec.ForProvider.APICertificateRef = &v1alpha1.CertificateReference{Name: "api-blue"}
+	source.Spec.ForProvider.DependsOn = []v1alpha1.DependencyReference{{APIVersion: "ec2.aws.crossplane.io/v1beta1", Kind: "VPC", Name: "blue"}}
+	source.SetWriteConnectionSecretToReference(&xpv1.SecretReference{Namespace: "default", Name: "blue"})
+
+	target := NewTarget(m, source)
+
+	want := v1alpha1.KopsParameters{Domain: "example.com"}
+	want.ClusterSpec.KubernetesVersion = "1.24.0"
+	want.Reachability = &v1alpha1.ReachabilityParameters{Mode: v1alpha1.ReachabilityViaProxy, ProxyURL: "socks5://10.0.0.10:1080"}
+	if diff := cmp.Diff(want, target.Spec.ForProvider); diff != "" {
+		t.Errorf("\nNewTarget(...): -want, +got:\n%s\n", diff)
+	}
+	if ref := target.GetWriteConnectionSecretToReference(); ref != nil {
+		t.Errorf("NewTarget(...): want no connection secret, got %v", ref)
+	}
+	if source.Spec.ForProvider.ClusterSpec.KubernetesVersion != "1.23.8" || source.Spec.ForProvider.Reachability.TokenSecretRef == nil {
+		t.Errorf("NewTarget(...): modified the source spec")
+	}
+}
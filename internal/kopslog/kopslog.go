@@ -0,0 +1,206 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kopslog captures the klog output of the kops libraries in the
+// provider's structured log.
+//
+// The kops libraries log through the global klog logger rather than a logger
+// passed to them, so their output cannot be attributed to the reconcile that
+// caused it directly. Instead every log line is annotated with the kops
+// operations in flight when it was written. When only one operation is in
+// flight, e.g. when running with --max-reconcile-rate=1 to debug a cluster,
+// the attribution is exact.
+//
+// The progress of the task graph of a kops apply is parsed from the log lines
+// of the kops task executor, and reported to whoever watches the cluster.
+package kopslog
+
+import (
+	"flag"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+)
+
+// progressVerbosity is the klog verbosity the kops task executor logs the
+// tasks it starts at. klog output is always captured at least at this
+// verbosity, and dropped if it exceeds the configured verbosity once progress
+// has been parsed from it.
+const progressVerbosity = 2
+
+var (
+	mu       sync.Mutex
+	inflight = map[string]int{}
+	watchers = map[string]*watcher{}
+
+	tasksLine     = regexp.MustCompile(`^Tasks: (\d+) done / (\d+) total`)
+	executingLine = regexp.MustCompile(`^Executing task "([^"]+)"`)
+)
+
+// Setup routes the output of klog to the supplied logger and sets the klog
+// verbosity of the kops libraries.
+func Setup(log logr.Logger, verbosity int) error {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	v := verbosity
+	if v < progressVerbosity {
+		v = progressVerbosity
+	}
+	if err := fs.Set("v", strconv.Itoa(v)); err != nil {
+		return err
+	}
+	klog.SetLogger(logr.New(&sink{wrapped: log.WithName("kops").GetSink(), verbosity: verbosity}))
+	return nil
+}
+
+// Track records that the supplied operation is being performed on the
+// supplied cluster until the returned function is called.
+func Track(cluster, operation string) func() {
+	op := cluster + "/" + operation
+	mu.Lock()
+	inflight[op]++
+	mu.Unlock()
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if inflight[op]--; inflight[op] == 0 {
+			delete(inflight, op)
+		}
+	}
+}
+
+// A Progress is the progress of the task graph of a kops apply.
+type Progress struct {
+	// Done is the number of tasks that are done.
+	Done int
+
+	// Total is the number of tasks.
+	Total int
+
+	// Task is the task that was started last, e.g.
+	// AutoScalingGroup/nodes-us-east-1a.example.com.
+	Task string
+}
+
+type watcher struct {
+	progress Progress
+	fn       func(Progress)
+}
+
+// WatchProgress calls the supplied function with the progress of the task
+// graph of an apply of the supplied cluster, until the returned function is
+// called. The function may be called from several goroutines at once. Log
+// lines can't be attributed to a cluster, so progress is only reported while
+// no other cluster's progress is watched.
+func WatchProgress(cluster string, fn func(Progress)) func() {
+	w := &watcher{fn: fn}
+	mu.Lock()
+	watchers[cluster] = w
+	mu.Unlock()
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if watchers[cluster] == w {
+			delete(watchers, cluster)
+		}
+	}
+}
+
+// observe parses the progress of a kops apply from the supplied log line, and
+// reports it if it can be attributed to a watched cluster.
+func observe(msg string) {
+	tasks := tasksLine.FindStringSubmatch(msg)
+	executing := executingLine.FindStringSubmatch(msg)
+	if tasks == nil && executing == nil {
+		return
+	}
+
+	mu.Lock()
+	if len(watchers) != 1 {
+		mu.Unlock()
+		return
+	}
+	var w *watcher
+	for _, w = range watchers {
+		break
+	}
+	if tasks != nil {
+		// The expressions only match digits.
+		w.progress.Done, _ = strconv.Atoi(tasks[1])
+		w.progress.Total, _ = strconv.Atoi(tasks[2])
+	}
+	if executing != nil {
+		w.progress.Task = executing[1]
+	}
+	p, fn := w.progress, w.fn
+	mu.Unlock()
+
+	fn(p)
+}
+
+func operations() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	ops := make([]string, 0, len(inflight))
+	for op := range inflight {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// A sink annotates klog output with the kops operations in flight. klog has
+// already filtered its output by verbosity, so everything it passes on up to
+// the configured verbosity is logged at the wrapped sink's default level,
+// with the klog verbosity recorded alongside it.
+type sink struct {
+	wrapped   logr.LogSink
+	verbosity int
+}
+
+func (s *sink) Init(info logr.RuntimeInfo) {
+	s.wrapped.Init(info)
+}
+
+func (s *sink) Enabled(_ int) bool {
+	return true
+}
+
+func (s *sink) Info(level int, msg string, kv ...interface{}) {
+	observe(msg)
+	if level > s.verbosity {
+		return
+	}
+	s.wrapped.Info(0, msg, append(kv, "v", level, "operations", operations())...)
+}
+
+func (s *sink) Error(err error, msg string, kv ...interface{}) {
+	s.wrapped.Error(err, msg, append(kv, "operations", operations())...)
+}
+
+func (s *sink) WithValues(kv ...interface{}) logr.LogSink {
+	return &sink{wrapped: s.wrapped.WithValues(kv...), verbosity: s.verbosity}
+}
+
+func (s *sink) WithName(name string) logr.LogSink {
+	return &sink{wrapped: s.wrapped.WithName(name), verbosity: s.verbosity}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kopslog
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestTrack(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		track  [][2]string
+		done   int
+		want   []string
+	}{
+		"None": {
+			reason: "No operations should be in flight if none were tracked.",
+			want:   []string{},
+		},
+		"InFlight": {
+			reason: "Tracked operations should be in flight until they are done.",
+			track:  [][2]string{{"b.example.com", "Update"}, {"a.example.com", "Observe"}},
+			want:   []string{"a.example.com/Observe", "b.example.com/Update"},
+		},
+		"Done": {
+			reason: "Operations should not be in flight once they are done.",
+			track:  [][2]string{{"a.example.com", "Observe"}, {"a.example.com", "Observe"}},
+			done:   1,
+			want:   []string{"a.example.com/Observe"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dones := make([]func(), 0, len(tc.track))
+			for _, op := range tc.track {
+				dones = append(dones, Track(op[0], op[1]))
+			}
+			for _, done := range dones[:tc.done] {
+				done()
+			}
+			got := operations()
+			for _, done := range dones[tc.done:] {
+				done()
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\noperations(): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestWatchProgress(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		watch  []string
+		lines  []string
+		want   []Progress
+	}{
+		"Progress": {
+			reason: "The progress of the task graph should be parsed from the executor's log lines.",
+			watch:  []string{"a.example.com"},
+			lines: []string{
+				"Tasks: 0 done / 97 total; 42 can run",
+				`Executing task "AutoScalingGroup/nodes-us-east-1a.a.example.com": *awstasks.AutoscalingGroup {...}`,
+				"unrelated",
+				"Tasks: 42 done / 97 total; 1 can run",
+			},
+			want: []Progress{
+				{Total: 97},
+				{Total: 97, Task: "AutoScalingGroup/nodes-us-east-1a.a.example.com"},
+				{Done: 42, Total: 97, Task: "AutoScalingGroup/nodes-us-east-1a.a.example.com"},
+			},
+		},
+		"Ambiguous": {
+			reason: "Progress should not be reported while several clusters are watched.",
+			watch:  []string{"a.example.com", "b.example.com"},
+			lines:  []string{"Tasks: 0 done / 97 total; 42 can run"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []Progress
+			stops := make([]func(), 0, len(tc.watch))
+			for _, c := range tc.watch {
+				stops = append(stops, WatchProgress(c, func(p Progress) { got = append(got, p) }))
+			}
+			for _, l := range tc.lines {
+				observe(l)
+			}
+			for _, stop := range stops {
+				stop()
+			}
+			observe("Tasks: 97 done / 97 total; 0 can run")
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("\n%s\nWatchProgress(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics contains the Prometheus metrics exported by provider-kops.
+// All metrics are registered with the controller-runtime registry and served
+// from the manager's metrics endpoint.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// CertificatesIssued counts the client certificates issued for kops
+	// clusters.
+	CertificatesIssued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kops_client_certificates_issued_total",
+		Help: "Total number of client certificates issued for kops clusters.",
+	}, []string{"cluster", "common_name", "reason"})
+
+	// CertificateTTL records the validity of the last client certificate
+	// issued for kops clusters.
+	CertificateTTL = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kops_client_certificate_ttl_seconds",
+		Help: "Validity in seconds of the last client certificate issued for kops clusters.",
+	}, []string{"cluster", "common_name"})
+
+	// ClusterReady reports whether kops clusters passed validation.
+	ClusterReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kops_cluster_ready",
+		Help: "Whether the kops cluster passed validation (1) or not (0).",
+	}, []string{"cluster"})
+
+	// ClusterValidationFailures reports the number of validation failures of
+	// kops clusters.
+	ClusterValidationFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kops_cluster_validation_failures",
+		Help: "Number of failures reported by the last validation of the kops cluster.",
+	}, []string{"cluster"})
+
+	// NodesNotReady reports the number of nodes of kops clusters that are not
+	// ready.
+	NodesNotReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kops_nodes_not_ready",
+		Help: "Number of nodes of the kops cluster that are not ready.",
+	}, []string{"cluster"})
+
+	// ClusterCost reports the estimated hourly cost of kops clusters that
+	// opted into cost estimation.
+	ClusterCost = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kops_cluster_estimated_hourly_cost_usd",
+		Help: "Estimated hourly on-demand cost in US dollars of the instances and volumes of the kops cluster.",
+	}, []string{"cluster"})
+
+	// ApplyDuration records how long applying kops clusters took.
+	ApplyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kops_apply_duration_seconds",
+		Help:    "Duration in seconds of kops cluster applies.",
+		Buckets: prometheus.ExponentialBuckets(15, 2, 9),
+	}, []string{"cluster", "operation"})
+
+	// DeleteDuration records how long deleting kops clusters took.
+	DeleteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kops_delete_duration_seconds",
+		Help:    "Duration in seconds of kops cluster deletes.",
+		Buckets: prometheus.ExponentialBuckets(15, 2, 9),
+	}, []string{"cluster"})
+
+	// OrphanedClusters reports the number of clusters in kops state buckets
+	// that no Kops resource manages and that have no cloud resources.
+	OrphanedClusters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kops_orphaned_clusters",
+		Help: "Number of clusters in the kops state bucket that no Kops resource manages and that have no cloud resources.",
+	}, []string{"state_bucket"})
+
+	// StateReplicationLag reports how far the state replicas of kops
+	// clusters lag behind their last apply.
+	StateReplicationLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kops_state_replication_lag_seconds",
+		Help: "Seconds since the last apply of the kops cluster that its state replica is missing, or 0 if it is up to date.",
+	}, []string{"cluster"})
+
+	// ClustersNeedingUpgrade reports the number of kops clusters the last
+	// upgrade audit found running a Kubernetes version or images their kops
+	// channel recommends upgrading.
+	ClustersNeedingUpgrade = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kops_clusters_needing_upgrade",
+		Help: "Number of kops clusters the last upgrade audit found needing an upgrade, by what needs upgrading.",
+	}, []string{"upgrade"})
+
+	// ApplyWorkersBusy reports how many applies and deletes are running.
+	ApplyWorkersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kops_apply_workers_busy",
+		Help: "Number of kops cluster applies and deletes that are running.",
+	})
+)
+
+// Upgrades the upgrade audit looks for.
+const (
+	UpgradeKubernetes = "kubernetes"
+	UpgradeImage      = "image"
+)
+
+// Operations an apply is performed for.
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		CertificatesIssued,
+		CertificateTTL,
+		ClusterReady,
+		ClusterValidationFailures,
+		NodesNotReady,
+		ClusterCost,
+		ApplyDuration,
+		DeleteDuration,
+		OrphanedClusters,
+		StateReplicationLag,
+		ClustersNeedingUpgrade,
+		ApplyWorkersBusy,
+	)
+}
+
+// RecordCertificateIssued records a client certificate issued for a cluster.
+func RecordCertificateIssued(cluster, commonName, reason string, ttl time.Duration) {
+	CertificatesIssued.WithLabelValues(cluster, commonName, reason).Inc()
+	CertificateTTL.WithLabelValues(cluster, commonName).Set(ttl.Seconds())
+}
+
+// RecordValidation records the result of a kops cluster validation.
+func RecordValidation(cluster string, ready bool, failures, nodesNotReady int) {
+	v := 0.0
+	if ready {
+		v = 1
+	}
+	ClusterReady.WithLabelValues(cluster).Set(v)
+	ClusterValidationFailures.WithLabelValues(cluster).Set(float64(failures))
+	NodesNotReady.WithLabelValues(cluster).Set(float64(nodesNotReady))
+}
+
+// RecordCost records the estimated hourly cost of a cluster.
+func RecordCost(cluster string, hourlyUSD float64) {
+	ClusterCost.WithLabelValues(cluster).Set(hourlyUSD)
+}
+
+// RecordStateReplicationLag records how far the state replica of a cluster
+// lags behind its last apply.
+func RecordStateReplicationLag(cluster string, lag time.Duration) {
+	StateReplicationLag.WithLabelValues(cluster).Set(lag.Seconds())
+}
+
+// RecordUpgradeAudit records how many clusters the last upgrade audit found
+// needing a Kubernetes upgrade, and how many running outdated images.
+func RecordUpgradeAudit(kubernetes, images int) {
+	ClustersNeedingUpgrade.WithLabelValues(UpgradeKubernetes).Set(float64(kubernetes))
+	ClustersNeedingUpgrade.WithLabelValues(UpgradeImage).Set(float64(images))
+}
+
+// RecordApply records the duration of an apply that started at the supplied
+// time.
+func RecordApply(cluster, operation string, start time.Time) {
+	ApplyDuration.WithLabelValues(cluster, operation).Observe(time.Since(start).Seconds())
+}
+
+// RecordDelete records the duration of a delete that started at the supplied
+// time, and stops reporting the health of the deleted cluster.
+func RecordDelete(cluster string, start time.Time) {
+	DeleteDuration.WithLabelValues(cluster).Observe(time.Since(start).Seconds())
+	ClusterReady.DeleteLabelValues(cluster)
+	ClusterValidationFailures.DeleteLabelValues(cluster)
+	NodesNotReady.DeleteLabelValues(cluster)
+	ClusterCost.DeleteLabelValues(cluster)
+	StateReplicationLag.DeleteLabelValues(cluster)
+}
+
+// ForgetCluster stops reporting every metric of a cluster that is no longer
+// managed, so that metrics don't grow with the number of clusters ever
+// managed. The certificate metrics are forgotten for the supplied common name
+// and reasons.
+func ForgetCluster(cluster, commonName string, reasons ...string) {
+	ClusterReady.DeleteLabelValues(cluster)
+	ClusterValidationFailures.DeleteLabelValues(cluster)
+	NodesNotReady.DeleteLabelValues(cluster)
+	ClusterCost.DeleteLabelValues(cluster)
+	StateReplicationLag.DeleteLabelValues(cluster)
+	CertificateTTL.DeleteLabelValues(cluster, commonName)
+	for _, r := range reasons {
+		CertificatesIssued.DeleteLabelValues(cluster, commonName, r)
+	}
+	for _, op := range []string{OperationCreate, OperationUpdate} {
+		ApplyDuration.DeleteLabelValues(cluster, op)
+	}
+	DeleteDuration.DeleteLabelValues(cluster)
+}
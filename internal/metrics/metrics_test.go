@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sampleCount returns the number of observations of the supplied histogram.
+func sampleCount(t *testing.T, o prometheus.Observer) uint64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := o.(prometheus.Metric).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestRecordValidation(t *testing.T) {
+	type want struct {
+		ready         float64
+		failures      float64
+		nodesNotReady float64
+	}
+
+	cases := map[string]struct {
+		reason        string
+		ready         bool
+		failures      int
+		nodesNotReady int
+		want          want
+	}{
+		"Passed": {
+			reason: "A cluster that passed validation should be reported ready.",
+			ready:  true,
+			want:   want{ready: 1},
+		},
+		"Failed": {
+			reason:        "A cluster that failed validation should be reported not ready, with its failures.",
+			failures:      2,
+			nodesNotReady: 1,
+			want:          want{failures: 2, nodesNotReady: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cluster := "validation-" + name
+			RecordValidation(cluster, tc.ready, tc.failures, tc.nodesNotReady)
+			got := want{
+				ready:         testutil.ToFloat64(ClusterReady.WithLabelValues(cluster)),
+				failures:      testutil.ToFloat64(ClusterValidationFailures.WithLabelValues(cluster)),
+				nodesNotReady: testutil.ToFloat64(NodesNotReady.WithLabelValues(cluster)),
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nRecordValidation(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRecordCertificateIssued(t *testing.T) {
+	cluster := "certificates.example.com"
+	issued := CertificatesIssued.WithLabelValues(cluster, "kops-operator", "validation")
+
+	RecordCertificateIssued(cluster, "kops-operator", "validation", time.Hour)
+	RecordCertificateIssued(cluster, "kops-operator", "validation", 2*time.Hour)
+
+	if got := testutil.ToFloat64(issued); got != 2 {
+		t.Errorf("\nEvery issued certificate should be counted.\nRecordCertificateIssued(...): want 2, got %v\n", got)
+	}
+	if got := testutil.ToFloat64(CertificateTTL.WithLabelValues(cluster, "kops-operator")); got != (2 * time.Hour).Seconds() {
+		t.Errorf("\nThe validity of the last issued certificate should be reported.\nRecordCertificateIssued(...): want %v, got %v\n", (2 * time.Hour).Seconds(), got)
+	}
+}
+
+func TestRecordApply(t *testing.T) {
+	cluster := "apply.example.com"
+
+	// Applies are recorded whether they succeed or fail.
+	RecordApply(cluster, OperationCreate, time.Now().Add(-time.Minute))
+	RecordApply(cluster, OperationUpdate, time.Now())
+	RecordApply(cluster, OperationUpdate, time.Now())
+
+	if got := sampleCount(t, ApplyDuration.WithLabelValues(cluster, OperationCreate)); got != 1 {
+		t.Errorf("RecordApply(...): want 1 create observed, got %d", got)
+	}
+	if got := sampleCount(t, ApplyDuration.WithLabelValues(cluster, OperationUpdate)); got != 2 {
+		t.Errorf("RecordApply(...): want 2 updates observed, got %d", got)
+	}
+}
+
+func TestRecordDelete(t *testing.T) {
+	cluster := "delete.example.com"
+	RecordValidation(cluster, true, 0, 0)
+	RecordCost(cluster, 1.5)
+	ready := testutil.CollectAndCount(ClusterReady)
+	cost := testutil.CollectAndCount(ClusterCost)
+
+	RecordDelete(cluster, time.Now().Add(-time.Minute))
+
+	if got := sampleCount(t, DeleteDuration.WithLabelValues(cluster)); got != 1 {
+		t.Errorf("RecordDelete(...): want 1 delete observed, got %d", got)
+	}
+	if got := testutil.CollectAndCount(ClusterReady); got != ready-1 {
+		t.Errorf("\nThe health of a deleted cluster should no longer be reported.\nRecordDelete(...): want %d series, got %d\n", ready-1, got)
+	}
+	if got := testutil.CollectAndCount(ClusterCost); got != cost-1 {
+		t.Errorf("\nThe cost of a deleted cluster should no longer be reported.\nRecordDelete(...): want %d series, got %d\n", cost-1, got)
+	}
+}
+
+func TestForgetCluster(t *testing.T) {
+	cluster := "forget.example.com"
+	RecordValidation(cluster, false, 1, 1)
+	RecordCertificateIssued(cluster, "kops-operator", "validation", time.Hour)
+	RecordApply(cluster, OperationUpdate, time.Now())
+
+	collectors := map[string]prometheus.Collector{
+		"kops_cluster_ready":                    ClusterReady,
+		"kops_client_certificates_issued_total": CertificatesIssued,
+		"kops_client_certificate_ttl_seconds":   CertificateTTL,
+		"kops_apply_duration_seconds":           ApplyDuration,
+	}
+	before := map[string]int{}
+	for name, c := range collectors {
+		before[name] = testutil.CollectAndCount(c)
+	}
+
+	ForgetCluster(cluster, "kops-operator", "validation")
+
+	for name, c := range collectors {
+		if got := testutil.CollectAndCount(c); got != before[name]-1 {
+			t.Errorf("ForgetCluster(...): want the %s series of the forgotten cluster dropped, got %d series, had %d", name, got, before[name])
+		}
+	}
+}
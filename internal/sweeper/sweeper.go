@@ -0,0 +1,233 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sweeper finds kops cluster state that no Kops resource manages,
+// typically left behind by a delete that failed part way through.
+package sweeper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	kopsClient "k8s.io/kops/pkg/client/simple"
+	"k8s.io/kops/pkg/resources"
+	resourceops "k8s.io/kops/pkg/resources/ops"
+	"k8s.io/kops/upup/pkg/fi"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/clients"
+	"github.com/crossplane/provider-kops/internal/metrics"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errListKops     = "cannot list Kops resources"
+	errNewClient    = "cannot create kops clientset"
+	errListClusters = "cannot list kops clusters"
+	errNewCloud     = "cannot build cloud"
+	errListRegion   = "cannot list cloud resources in region %s"
+)
+
+// An Orphan is kops cluster state that no Kops resource manages.
+type Orphan struct {
+	StateBucket string
+	Cluster     string
+}
+
+// A Sweeper periodically looks for orphaned kops cluster state in the state
+// buckets of Kops resources. State is only considered orphaned if the cluster
+// was registered longer than the minimum age ago, so that clusters that were
+// just registered and are yet to be applied aren't mistaken for orphans, and
+// if it has no cloud resources left. Orphans are logged and counted, and
+// deleted if the Sweeper was told to clean them up.
+type Sweeper struct {
+	kube     client.Reader
+	log      logging.Logger
+	interval time.Duration
+	clean    bool
+	minAge   time.Duration
+
+	clientset     func(stateBucket string) (kopsClient.Clientset, error)
+	builder       clients.CloudBuilder
+	listResources func(cloud fi.Cloud, cluster *kopsapi.Cluster, region string) (map[string]*resources.Resource, error)
+}
+
+// New returns a Sweeper that sweeps at the supplied interval, considering
+// only clusters older than the supplied minimum age.
+func New(kube client.Reader, log logging.Logger, interval time.Duration, clean bool, minAge time.Duration) *Sweeper {
+	return &Sweeper{
+		kube:          kube,
+		log:           log,
+		interval:      interval,
+		clean:         clean,
+		minAge:        minAge,
+		clientset:     util.GetStateStoreClientset,
+		builder:       clients.KopsCloudBuilder,
+		listResources: resourceops.ListResources,
+	}
+}
+
+// NeedLeaderElection is true; only the leader sweeps.
+func (s *Sweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// Start sweeps until the supplied context is done.
+func (s *Sweeper) Start(ctx context.Context) error {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		if _, err := s.Sweep(ctx); err != nil {
+			s.log.Info("Cannot sweep kops state stores", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Sweep looks for orphaned cluster state once and returns what it found.
+func (s *Sweeper) Sweep(ctx context.Context) ([]Orphan, error) {
+	l := &v1alpha1.KopsList{}
+	if err := s.kube.List(ctx, l); err != nil {
+		return nil, errors.Wrap(err, errListKops)
+	}
+
+	managed := map[string]map[string]bool{}
+	for i := range l.Items {
+		cr := &l.Items[i]
+		bucket := cr.Spec.ForProvider.StateBucket
+		if managed[bucket] == nil {
+			managed[bucket] = map[string]bool{}
+		}
+		managed[bucket][fmt.Sprintf("%v.%v", meta.GetExternalName(cr), cr.Spec.ForProvider.Domain)] = true
+	}
+
+	buckets := make([]string, 0, len(managed))
+	for bucket := range managed {
+		buckets = append(buckets, bucket)
+	}
+	sort.Strings(buckets)
+
+	var orphans []Orphan
+	for _, bucket := range buckets {
+		o, err := s.sweepBucket(ctx, bucket, managed[bucket])
+		if err != nil {
+			return orphans, errors.Wrapf(err, "cannot sweep state bucket %s", bucket)
+		}
+		metrics.OrphanedClusters.WithLabelValues(bucket).Set(float64(len(o)))
+		orphans = append(orphans, o...)
+	}
+	return orphans, nil
+}
+
+func (s *Sweeper) sweepBucket(ctx context.Context, bucket string, managed map[string]bool) ([]Orphan, error) {
+	cs, err := s.clientset(bucket)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewClient)
+	}
+	clusters, err := cs.ListClusters(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, errListClusters)
+	}
+
+	var orphans []Orphan
+	for i := range clusters.Items {
+		cluster := &clusters.Items[i]
+		if managed[cluster.GetName()] {
+			continue
+		}
+
+		log := s.log.WithValues("state-bucket", bucket, "cluster", cluster.GetName())
+		// kops records when a cluster was registered. A cluster without a
+		// record is of unknown age, so it isn't considered either.
+		if created := cluster.GetCreationTimestamp(); created.IsZero() || time.Since(created.Time) < s.minAge {
+			log.Debug("Unmanaged cluster is too recent to be considered orphaned", "created", created)
+			continue
+		}
+		n, err := s.countResources(cluster)
+		if err != nil {
+			// Without knowing whether the cluster still exists it can't
+			// be considered orphaned.
+			log.Debug("Cannot list cloud resources of unmanaged cluster", "error", err)
+			continue
+		}
+		if n > 0 {
+			log.Debug("Unmanaged cluster has cloud resources", "resources", n)
+			continue
+		}
+
+		orphans = append(orphans, Orphan{StateBucket: bucket, Cluster: cluster.GetName()})
+		if !s.clean {
+			log.Info("Found orphaned cluster state")
+			continue
+		}
+		if err := s.delete(ctx, cs, cluster, bucket); err != nil {
+			log.Info("Cannot delete orphaned cluster state", "error", err)
+			continue
+		}
+		log.Info("Deleted orphaned cluster state")
+	}
+	return orphans, nil
+}
+
+// delete backs up and deletes orphaned cluster state.
+func (s *Sweeper) delete(ctx context.Context, cs kopsClient.Clientset, cluster *kopsapi.Cluster, bucket string) error {
+	if _, err := util.BackupClusterState(cs, cluster, bucket, time.Now()); err != nil {
+		return err
+	}
+	if err := util.RemoveProviderState(cs, cluster); err != nil {
+		return err
+	}
+	return cs.DeleteCluster(ctx, cluster)
+}
+
+// countResources counts the cloud resources of the supplied cluster in every
+// region its subnets are in, like the Kops controller lists them before
+// deleting a cluster, so that the resources of a multi-region cluster outside
+// the region of its cloud aren't missed.
+func (s *Sweeper) countResources(cluster *kopsapi.Cluster) (int, error) {
+	cloud, err := s.builder.BuildCloud(cluster)
+	if err != nil {
+		return 0, errors.Wrap(err, errNewCloud)
+	}
+	regions := util.GetClusterRegions(cluster, cloud.Region())
+	if len(regions) == 0 {
+		regions = []string{""}
+	}
+	res := map[string]bool{}
+	for _, region := range regions {
+		r, err := s.listResources(cloud, cluster, region)
+		if err != nil {
+			return 0, errors.Wrapf(err, errListRegion, region)
+		}
+		for k := range r {
+			res[k] = true
+		}
+	}
+	return len(res), nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sweeper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	kopsClient "k8s.io/kops/pkg/client/simple"
+	"k8s.io/kops/pkg/client/simple/vfsclientset"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/util/pkg/vfs"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	clientsfake "github.com/crossplane/provider-kops/internal/clients/fake"
+)
+
+func TestSweep(t *testing.T) {
+	errBoom := errors.New("boom")
+	bucket := "s3://state"
+
+	managed := &v1alpha1.Kops{}
+	meta.SetExternalName(managed, "managed")
+	managed.Spec.ForProvider.StateBucket = bucket
+	managed.Spec.ForProvider.Domain = "example.com"
+
+	kube := &test.MockClient{
+		MockList: test.NewMockListFn(nil, func(obj client.ObjectList) error {
+			obj.(*v1alpha1.KopsList).Items = []v1alpha1.Kops{*managed}
+			return nil
+		}),
+	}
+
+	// Clusters are registered a day ago unless they say otherwise. The
+	// cloud of every cluster is in us-east-1.
+	store := vfs.NewMemFSPath(vfs.NewMemFSContext(), "state")
+	old := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	clusters := map[string]struct {
+		created string
+		spec    string
+	}{
+		"managed.example.com":     {created: old},
+		"orphan.example.com":      {created: old},
+		"running.example.com":     {created: old},
+		"unknown.example.com":     {created: old},
+		"multiregion.example.com": {created: old, spec: "subnets: [{name: a, zone: us-east-1a}, {name: b, zone: eu-west-1a}]"},
+		"recent.example.com":      {created: time.Now().UTC().Format(time.RFC3339)},
+		"undated.example.com":     {},
+	}
+	for name, c := range clusters {
+		created := ""
+		if c.created != "" {
+			created = fmt.Sprintf("  creationTimestamp: %q\n", c.created)
+		}
+		config := fmt.Sprintf("apiVersion: kops.k8s.io/v1alpha2\nkind: Cluster\nmetadata:\n  name: %s\n%sspec: {%s}\n", name, created, c.spec)
+		if err := store.Join(name, "config").WriteFile(bytes.NewReader([]byte(config)), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type want struct {
+		orphans []Orphan
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason string
+		kube   client.Reader
+		want   want
+	}{
+		"ListError": {
+			reason: "Failing to list Kops resources should return an error.",
+			kube:   &test.MockClient{MockList: test.NewMockListFn(errBoom)},
+			want:   want{err: errors.Wrap(errBoom, errListKops)},
+		},
+		"Orphans": {
+			reason: "Only unmanaged clusters registered longer than the minimum age ago and known to have no cloud resources in any of their regions should be considered orphaned.",
+			kube:   kube,
+			want:   want{orphans: []Orphan{{StateBucket: bucket, Cluster: "orphan.example.com"}}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := &Sweeper{
+				kube:   tc.kube,
+				log:    logging.NewNopLogger(),
+				minAge: time.Hour,
+				clientset: func(string) (kopsClient.Clientset, error) {
+					return vfsclientset.NewVFSClientset(store), nil
+				},
+				builder: &clientsfake.CloudBuilder{MockBuildCloud: func(_ *kopsapi.Cluster) (fi.Cloud, error) {
+					return awsup.BuildMockAWSCloud("us-east-1", "a"), nil
+				}},
+				listResources: func(_ fi.Cloud, cluster *kopsapi.Cluster, region string) (map[string]*resources.Resource, error) {
+					switch {
+					case cluster.GetName() == "running.example.com":
+						return map[string]*resources.Resource{"instance:i-1": {}, "instance:i-2": {}}, nil
+					case cluster.GetName() == "multiregion.example.com" && region == "eu-west-1":
+						return map[string]*resources.Resource{"instance:i-3": {}}, nil
+					case cluster.GetName() == "unknown.example.com":
+						return nil, errBoom
+					}
+					return nil, nil
+				},
+			}
+			got, err := s.Sweep(context.Background())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nSweep(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.orphans, got); diff != "" {
+				t.Errorf("\n%s\nSweep(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DrainNodes cordons every node of a cluster and requests the eviction of the
+// pods running on them, other than those of DaemonSets and static pods. It
+// returns the number of such pods that remain, including those just asked to
+// be evicted. Evictions refused by a PodDisruptionBudget are left for the
+// next drain
+func DrainNodes(ctx context.Context, kube kubernetes.Interface) (int, error) {
+	nodes, err := kube.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot list nodes")
+	}
+	for _, n := range nodes.Items {
+		if n.Spec.Unschedulable {
+			continue
+		}
+		if _, err := kube.CoreV1().Nodes().Patch(ctx, n.Name, types.MergePatchType, []byte(`{"spec":{"unschedulable":true}}`), metav1.PatchOptions{}); err != nil {
+			return 0, errors.Wrapf(err, "cannot cordon node %q", n.Name)
+		}
+	}
+
+	pods, err := kube.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot list pods")
+	}
+	remaining := 0
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		if !drainable(p) {
+			continue
+		}
+		remaining++
+		if p.DeletionTimestamp != nil {
+			continue
+		}
+		err := kube.PolicyV1().Evictions(p.Namespace).Evict(ctx, &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: p.Name, Namespace: p.Namespace}})
+		if err != nil && !kerrors.IsNotFound(err) && !kerrors.IsTooManyRequests(err) {
+			return 0, errors.Wrapf(err, "cannot evict pod %s/%s", p.Namespace, p.Name)
+		}
+	}
+	return remaining, nil
+}
+
+// drainable returns true if a pod has to be evicted to drain its node
+func drainable(p *corev1.Pod) bool {
+	if p.Spec.NodeName == "" || p.Status.Phase == corev1.PodSucceeded || p.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, ok := p.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	if c := metav1.GetControllerOf(p); c != nil && c.Kind == "DaemonSet" {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+)
+
+func TestDrainNodes(t *testing.T) {
+	pod := func(name, node string, f func(p *corev1.Pod)) *corev1.Pod {
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		if f != nil {
+			f(p)
+		}
+		return p
+	}
+	isController := true
+	kube := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "a"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+		pod("app", "a", nil),
+		pod("guarded", "b", nil),
+		pod("agent", "a", func(p *corev1.Pod) {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "agent", Controller: &isController}}
+		}),
+		pod("static", "a", func(p *corev1.Pod) { p.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: "hash"} }),
+		pod("done", "b", func(p *corev1.Pod) { p.Status.Phase = corev1.PodSucceeded }),
+		pod("pending", "", nil),
+	)
+	var evicted []string
+	kube.PrependReactor("create", "pods", func(a ktesting.Action) (bool, runtime.Object, error) {
+		ca := a.(ktesting.CreateAction)
+		if ca.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		e := ca.GetObject().(*policyv1.Eviction)
+		evicted = append(evicted, e.Name)
+		if e.Name == "guarded" {
+			return true, nil, kerrors.NewTooManyRequests("disruption budget", 10)
+		}
+		return true, nil, kube.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), e.Namespace, e.Name)
+	})
+
+	remaining, err := DrainNodes(context.Background(), kube)
+	if err != nil {
+		t.Fatalf("DrainNodes(...): %v", err)
+	}
+	if diff := cmp.Diff(2, remaining); diff != "" {
+		t.Errorf("DrainNodes(...): -want remaining pods, +got:\n%s\n", diff)
+	}
+	sort.Strings(evicted)
+	if diff := cmp.Diff([]string{"app", "guarded"}, evicted); diff != "" {
+		t.Errorf("DrainNodes(...): -want evicted pods, +got:\n%s\n", diff)
+	}
+	nodes, _ := kube.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	for _, n := range nodes.Items {
+		if !n.Spec.Unschedulable {
+			t.Errorf("DrainNodes(...): node %q was not cordoned", n.Name)
+		}
+	}
+
+	// Pods whose eviction was refused are retried by the next drain.
+	remaining, err = DrainNodes(context.Background(), kube)
+	if err != nil {
+		t.Fatalf("DrainNodes(...): %v", err)
+	}
+	if diff := cmp.Diff(1, remaining); diff != "" {
+		t.Errorf("DrainNodes(...): -want remaining pods after the second drain, +got:\n%s\n", diff)
+	}
+}
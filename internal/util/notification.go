@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+// Types of the notifications sent on lifecycle transitions of a cluster
+const (
+	NotificationClusterReady     = "ClusterReady"
+	NotificationValidationFailed = "ValidationFailed"
+	NotificationUpdateApplied    = "UpdateApplied"
+	NotificationDeletionStarting = "DeletionStarting"
+	NotificationDeletionComplete = "DeletionComplete"
+)
+
+// NotificationTimeout bounds how long sending a notification may take
+const NotificationTimeout = 10 * time.Second
+
+// A Notification reports a lifecycle transition of a cluster
+type Notification struct {
+	Type     string    `json:"type"`
+	Cluster  string    `json:"cluster"`
+	Resource string    `json:"resource"`
+	UID      string    `json:"uid"`
+	Message  string    `json:"message,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// SendNotification POSTs the supplied notification to the supplied sink, as a
+// CloudEvent in structured content mode if the sink asks for that format
+func SendNotification(ctx context.Context, sink v1alpha1.NotificationParameters, n Notification) error {
+	contentType := "application/json"
+	var body interface{} = n
+	if sink.Format == v1alpha1.NotificationFormatCloudEvents {
+		contentType = "application/cloudevents+json"
+		body = map[string]interface{}{
+			"specversion":     "1.0",
+			"id":              fmt.Sprintf("%s-%s-%d", n.UID, n.Type, n.Time.UnixNano()),
+			"source":          "/apis/" + v1alpha1.KopsGroupVersionKind.GroupVersion().String() + "/kops/" + n.Resource,
+			"type":            "io.crossplane.kops." + n.Type,
+			"subject":         n.Cluster,
+			"time":            n.Time.UTC().Format(time.RFC3339),
+			"datacontenttype": "application/json",
+			"data":            n,
+		}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, NotificationTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("notification sink returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+func TestSendNotification(t *testing.T) {
+	n := Notification{Type: NotificationClusterReady, Cluster: "test.example.com", Resource: "test", UID: "uid", Time: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	cases := map[string]struct {
+		reason      string
+		format      v1alpha1.NotificationFormat
+		status      int
+		contentType string
+		err         bool
+	}{
+		"JSON": {
+			reason:      "Notifications should be POSTed as JSON by default.",
+			status:      http.StatusOK,
+			contentType: "application/json",
+		},
+		"CloudEvents": {
+			reason:      "Notifications should be POSTed as structured CloudEvents if asked for.",
+			format:      v1alpha1.NotificationFormatCloudEvents,
+			status:      http.StatusAccepted,
+			contentType: "application/cloudevents+json",
+		},
+		"Rejected": {
+			reason: "A sink that doesn't accept the notification should return an error.",
+			status: http.StatusInternalServerError,
+			err:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var contentType string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get("Content-Type")
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			err := SendNotification(context.Background(), v1alpha1.NotificationParameters{URL: srv.URL, Format: tc.format}, n)
+			if (err != nil) != tc.err {
+				t.Errorf("\n%s\nSendNotification(...): want error %t, got %v\n", tc.reason, tc.err, err)
+			}
+			if tc.contentType != "" && contentType != tc.contentType {
+				t.Errorf("\n%s\nSendNotification(...): want content type %q, got %q\n", tc.reason, tc.contentType, contentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	"github.com/pkg/errors"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/cloudinstances"
+	"k8s.io/kops/pkg/model/defaults"
+	"k8s.io/kops/upup/pkg/fi"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+// AWSPricingRegion is the region the AWS pricing API is served from
+const AWSPricingRegion = "us-east-1"
+
+const (
+	// hoursPerMonth is the number of hours AWS bills storage for per month
+	hoursPerMonth = 730
+	// defaultVolumeType is the type kops creates root and etcd volumes as
+	defaultVolumeType = ec2.VolumeTypeGp3
+	// defaultEtcdVolumeSize is the size kops creates etcd volumes with, in GB
+	defaultEtcdVolumeSize = 20
+)
+
+// awsPriceListItem is the part of an AWS price list item holding its
+// on-demand prices
+type awsPriceListItem struct {
+	Terms struct {
+		OnDemand map[string]struct {
+			PriceDimensions map[string]struct {
+				PricePerUnit map[string]string `json:"pricePerUnit"`
+			} `json:"priceDimensions"`
+		} `json:"OnDemand"`
+	} `json:"terms"`
+}
+
+// GetAWSInstancePrice returns the hourly on-demand price in US dollars of a
+// shared tenancy Linux EC2 instance type in the supplied region
+func GetAWSInstancePrice(pricingAPI pricingiface.PricingAPI, region, instanceType string) (float64, error) {
+	p, err := getAWSPrice(pricingAPI, map[string]string{
+		"instanceType":    instanceType,
+		"regionCode":      region,
+		"operatingSystem": "Linux",
+		"tenancy":         "Shared",
+		"preInstalledSw":  "NA",
+		"capacitystatus":  "Used",
+	})
+	return p, errors.Wrapf(err, "cannot get price of instance type %s", instanceType)
+}
+
+// GetAWSVolumePrice returns the monthly price in US dollars of a GB of an
+// EBS volume type in the supplied region
+func GetAWSVolumePrice(pricingAPI pricingiface.PricingAPI, region, volumeType string) (float64, error) {
+	p, err := getAWSPrice(pricingAPI, map[string]string{
+		"volumeApiName": volumeType,
+		"regionCode":    region,
+		"productFamily": "Storage",
+	})
+	return p, errors.Wrapf(err, "cannot get price of volume type %s", volumeType)
+}
+
+func getAWSPrice(pricingAPI pricingiface.PricingAPI, attrs map[string]string) (float64, error) {
+	in := &pricing.GetProductsInput{ServiceCode: aws.String("AmazonEC2"), MaxResults: aws.Int64(1)}
+	for k, v := range attrs {
+		in.Filters = append(in.Filters, &pricing.Filter{Type: aws.String(pricing.FilterTypeTermMatch), Field: aws.String(k), Value: aws.String(v)})
+	}
+	out, err := pricingAPI.GetProducts(in)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range out.PriceList {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return 0, err
+		}
+		item := awsPriceListItem{}
+		if err := json.Unmarshal(b, &item); err != nil {
+			return 0, err
+		}
+		for _, term := range item.Terms.OnDemand {
+			for _, d := range term.PriceDimensions {
+				if usd, ok := d.PricePerUnit["USD"]; ok {
+					return strconv.ParseFloat(usd, 64)
+				}
+			}
+		}
+	}
+	return 0, errors.New("no on-demand price found")
+}
+
+// EstimateClusterCost returns the approximate hourly cost of the running
+// instances of the supplied cloud groups, their root volumes and the etcd
+// volumes of the cluster, priced by the supplied functions. Instance prices
+// are hourly, volume prices are per GB and month
+func EstimateClusterCost(kopsCluster *kopsapi.Cluster, groups map[string]*cloudinstances.CloudInstanceGroup, instancePrice, volumePrice func(string) (float64, error)) (*v1alpha1.CostObservation, error) {
+	instancePrice, volumePrice = memoizePrice(instancePrice), memoizePrice(volumePrice)
+	volumeCost := func(size int32, volumeType string) (float64, error) {
+		p, err := volumePrice(volumeType)
+		return float64(size) * p / hoursPerMonth, err
+	}
+
+	obs := &v1alpha1.CostObservation{}
+	total := 0.0
+	for _, g := range groups {
+		if g.InstanceGroup == nil {
+			continue
+		}
+		spec := g.InstanceGroup.Spec
+		size := fi.Int32Value(spec.RootVolumeSize)
+		if spec.RootVolumeSize == nil {
+			size, _ = defaults.DefaultInstanceGroupVolumeSize(spec.Role)
+		}
+		volumeType := fi.StringValue(spec.RootVolumeType)
+		if volumeType == "" {
+			volumeType = defaultVolumeType
+		}
+
+		cost := 0.0
+		instances := append(append([]*cloudinstances.CloudInstance{}, g.Ready...), g.NeedUpdate...)
+		for _, i := range instances {
+			machineType := i.MachineType
+			if machineType == "" {
+				machineType = spec.MachineType
+			}
+			p, err := instancePrice(machineType)
+			if err != nil {
+				return nil, err
+			}
+			v, err := volumeCost(size, volumeType)
+			if err != nil {
+				return nil, err
+			}
+			cost += p + v
+		}
+		total += cost
+		obs.InstanceGroups = append(obs.InstanceGroups, v1alpha1.InstanceGroupCost{
+			Name:      g.InstanceGroup.Name,
+			Instances: int32(len(instances)),
+			HourlyUSD: formatUSD(cost),
+		})
+	}
+	sort.Slice(obs.InstanceGroups, func(i, j int) bool { return obs.InstanceGroups[i].Name < obs.InstanceGroups[j].Name })
+
+	for _, c := range kopsCluster.Spec.EtcdClusters {
+		for _, m := range c.Members {
+			size := fi.Int32Value(m.VolumeSize)
+			if m.VolumeSize == nil {
+				size = defaultEtcdVolumeSize
+			}
+			volumeType := fi.StringValue(m.VolumeType)
+			if volumeType == "" {
+				volumeType = defaultVolumeType
+			}
+			v, err := volumeCost(size, volumeType)
+			if err != nil {
+				return nil, err
+			}
+			total += v
+		}
+	}
+	obs.HourlyUSD = formatUSD(total)
+	return obs, nil
+}
+
+// memoizePrice returns a price function that looks up each type only once
+func memoizePrice(price func(string) (float64, error)) func(string) (float64, error) {
+	prices := map[string]float64{}
+	return func(t string) (float64, error) {
+		if p, ok := prices[t]; ok {
+			return p, nil
+		}
+		p, err := price(t)
+		if err != nil {
+			return 0, err
+		}
+		prices[t] = p
+		return p, nil
+	}
+}
+
+func formatUSD(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
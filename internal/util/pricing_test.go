@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/cloudinstances"
+	"k8s.io/kops/upup/pkg/fi"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+func TestEstimateClusterCost(t *testing.T) {
+	errBoom := errors.New("boom")
+	prices := map[string]float64{"m5.large": 0.1, "t3.medium": 0.0416, ec2.VolumeTypeGp3: 0.08}
+	price := func(t string) (float64, error) { return prices[t], nil }
+
+	cluster := &kopsapi.Cluster{Spec: kopsapi.ClusterSpec{EtcdClusters: []kopsapi.EtcdClusterSpec{
+		{Name: "main", Members: []kopsapi.EtcdMemberSpec{{Name: "a"}}},
+		{Name: "events", Members: []kopsapi.EtcdMemberSpec{{Name: "a"}}},
+	}}}
+	master := &kopsapi.InstanceGroup{ObjectMeta: metav1.ObjectMeta{Name: "master-us-east-1a"}, Spec: kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleMaster, MachineType: "m5.large"}}
+	nodes := &kopsapi.InstanceGroup{ObjectMeta: metav1.ObjectMeta{Name: "nodes"}, Spec: kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleNode, MachineType: "t3.medium", RootVolumeSize: fi.Int32(100)}}
+	groups := map[string]*cloudinstances.CloudInstanceGroup{
+		"master-us-east-1a": {InstanceGroup: master, Ready: []*cloudinstances.CloudInstance{{ID: "i-1", MachineType: "m5.large"}}},
+		"nodes":             {InstanceGroup: nodes, Ready: []*cloudinstances.CloudInstance{{ID: "i-2", MachineType: "t3.medium"}}, NeedUpdate: []*cloudinstances.CloudInstance{{ID: "i-3"}}},
+	}
+
+	type want struct {
+		obs *v1alpha1.CostObservation
+		err error
+	}
+	cases := map[string]struct {
+		reason        string
+		instancePrice func(string) (float64, error)
+		want          want
+	}{
+		"Estimated": {
+			reason:        "Instances, their root volumes and etcd volumes should be priced, defaulting sizes and types as kops does.",
+			instancePrice: price,
+			want: want{obs: &v1alpha1.CostObservation{
+				HourlyUSD: "0.2165",
+				InstanceGroups: []v1alpha1.InstanceGroupCost{
+					{Name: "master-us-east-1a", Instances: 1, HourlyUSD: "0.1070"},
+					{Name: "nodes", Instances: 2, HourlyUSD: "0.1051"},
+				},
+			}},
+		},
+		"PriceError": {
+			reason:        "Errors looking up prices should be returned.",
+			instancePrice: func(string) (float64, error) { return 0, errBoom },
+			want:          want{err: errBoom},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := EstimateClusterCost(cluster, groups, tc.instancePrice, price)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nEstimateClusterCost(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.obs, got); diff != "" {
+				t.Errorf("\n%s\nEstimateClusterCost(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
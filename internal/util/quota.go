@@ -0,0 +1,181 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	"github.com/pkg/errors"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+// AWSStandardVCPUQuota is the code of the AWS quota on the vCPUs of running
+// on-demand standard (A, C, D, H, I, M, R, T and Z) instances
+const AWSStandardVCPUQuota = "L-1216C47A"
+
+// GetAWSQuotaShortfalls returns a description of each AWS quota that has too
+// little headroom left for a kops cluster with the supplied instance groups.
+// The vCPUs of every on-demand instance group are compared against the
+// standard instance quota, so the check errs on the side of warning for
+// clusters using other instance families
+func GetAWSQuotaShortfalls(ec2API ec2iface.EC2API, asAPI autoscalingiface.AutoScalingAPI, sqAPI servicequotasiface.ServiceQuotasAPI, kopsCluster *kopsapi.Cluster, igs []kopsapi.InstanceGroupSpec) ([]string, error) {
+	var shortfalls []string
+
+	limits, err := asAPI.DescribeAccountLimits(&autoscaling.DescribeAccountLimitsInput{})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot describe autoscaling limits")
+	}
+	if avail := aws.Int64Value(limits.MaxNumberOfAutoScalingGroups) - aws.Int64Value(limits.NumberOfAutoScalingGroups); int64(len(igs)) > avail {
+		shortfalls = append(shortfalls, fmt.Sprintf("autoscaling groups: %d needed, %d available", len(igs), avail))
+	}
+
+	if need := natGatewayZones(kopsCluster); need > 0 {
+		avail, err := availableElasticIPs(ec2API)
+		if err != nil {
+			return nil, err
+		}
+		if int64(need) > avail {
+			shortfalls = append(shortfalls, fmt.Sprintf("elastic IPs: %d needed, %d available", need, avail))
+		}
+	}
+
+	need, err := onDemandVCPUs(ec2API, igs)
+	if err != nil {
+		return nil, err
+	}
+	if need > 0 {
+		q, err := sqAPI.GetServiceQuota(&servicequotas.GetServiceQuotaInput{
+			ServiceCode: aws.String("ec2"),
+			QuotaCode:   aws.String(AWSStandardVCPUQuota),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot get vCPU quota")
+		}
+		used, err := runningOnDemandVCPUs(ec2API)
+		if err != nil {
+			return nil, err
+		}
+		if avail := int64(aws.Float64Value(q.Quota.Value)) - used; need > avail {
+			shortfalls = append(shortfalls, fmt.Sprintf("on-demand vCPUs: %d needed, %d available", need, avail))
+		}
+	}
+
+	return shortfalls, nil
+}
+
+// natGatewayZones returns the number of zones kops creates a NAT gateway, and
+// so allocates an elastic IP, in
+func natGatewayZones(kopsCluster *kopsapi.Cluster) int {
+	zones := map[string]bool{}
+	for _, s := range kopsCluster.Spec.Subnets {
+		if s.Type == kopsapi.SubnetTypePrivate && s.Egress == "" {
+			zones[s.Zone] = true
+		}
+	}
+	return len(zones)
+}
+
+func availableElasticIPs(ec2API ec2iface.EC2API) (int64, error) {
+	attrs, err := ec2API.DescribeAccountAttributes(&ec2.DescribeAccountAttributesInput{
+		AttributeNames: aws.StringSlice([]string{"vpc-max-elastic-ips"}),
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot describe elastic IP limits")
+	}
+	var max int64
+	for _, a := range attrs.AccountAttributes {
+		for _, v := range a.AttributeValues {
+			max, _ = strconv.ParseInt(aws.StringValue(v.AttributeValue), 10, 64)
+		}
+	}
+	addrs, err := ec2API.DescribeAddresses(&ec2.DescribeAddressesInput{})
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot describe elastic IPs")
+	}
+	return max - int64(len(addrs.Addresses)), nil
+}
+
+// onDemandVCPUs returns the vCPUs of the supplied instance groups at their
+// maximum size, ignoring spot instance groups
+func onDemandVCPUs(ec2API ec2iface.EC2API, igs []kopsapi.InstanceGroupSpec) (int64, error) {
+	size := map[string]int64{}
+	for _, ig := range igs {
+		if ig.MaxPrice != nil || ig.MachineType == "" {
+			continue
+		}
+		n := fi.Int32Value(ig.MaxSize)
+		if ig.MaxSize == nil {
+			n = fi.Int32Value(ig.MinSize)
+		}
+		size[ig.MachineType] += int64(n)
+	}
+	if len(size) == 0 {
+		return 0, nil
+	}
+
+	types := make([]string, 0, len(size))
+	for t := range size {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	var vcpus int64
+	err := ec2API.DescribeInstanceTypesPages(&ec2.DescribeInstanceTypesInput{
+		InstanceTypes: aws.StringSlice(types),
+	}, func(out *ec2.DescribeInstanceTypesOutput, _ bool) bool {
+		for _, t := range out.InstanceTypes {
+			if t.VCpuInfo != nil {
+				vcpus += aws.Int64Value(t.VCpuInfo.DefaultVCpus) * size[aws.StringValue(t.InstanceType)]
+			}
+		}
+		return true
+	})
+	return vcpus, errors.Wrap(err, "cannot describe instance types")
+}
+
+// runningOnDemandVCPUs returns the vCPUs of every pending or running
+// on-demand instance in the region
+func runningOnDemandVCPUs(ec2API ec2iface.EC2API) (int64, error) {
+	var vcpus int64
+	err := ec2API.DescribeInstancesPages(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("instance-state-name"),
+			Values: aws.StringSlice([]string{ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning}),
+		}},
+	}, func(out *ec2.DescribeInstancesOutput, _ bool) bool {
+		for _, r := range out.Reservations {
+			for _, i := range r.Instances {
+				if i.InstanceLifecycle != nil || i.CpuOptions == nil {
+					continue
+				}
+				vcpus += aws.Int64Value(i.CpuOptions.CoreCount) * aws.Int64Value(i.CpuOptions.ThreadsPerCore)
+			}
+		}
+		return true
+	})
+	return vcpus, errors.Wrap(err, "cannot describe instances")
+}
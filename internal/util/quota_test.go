@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	"github.com/google/go-cmp/cmp"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+type mockEC2 struct {
+	ec2iface.EC2API
+	maxEIPs   string
+	addresses int
+	vcpus     map[string]int64
+	running   []int64
+}
+
+func (m *mockEC2) DescribeAccountAttributes(*ec2.DescribeAccountAttributesInput) (*ec2.DescribeAccountAttributesOutput, error) {
+	return &ec2.DescribeAccountAttributesOutput{AccountAttributes: []*ec2.AccountAttribute{{
+		AttributeName:   aws.String("vpc-max-elastic-ips"),
+		AttributeValues: []*ec2.AccountAttributeValue{{AttributeValue: aws.String(m.maxEIPs)}},
+	}}}, nil
+}
+
+func (m *mockEC2) DescribeAddresses(*ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	return &ec2.DescribeAddressesOutput{Addresses: make([]*ec2.Address, m.addresses)}, nil
+}
+
+func (m *mockEC2) DescribeInstanceTypesPages(in *ec2.DescribeInstanceTypesInput, fn func(*ec2.DescribeInstanceTypesOutput, bool) bool) error {
+	out := &ec2.DescribeInstanceTypesOutput{}
+	for _, t := range in.InstanceTypes {
+		out.InstanceTypes = append(out.InstanceTypes, &ec2.InstanceTypeInfo{
+			InstanceType: t,
+			VCpuInfo:     &ec2.VCpuInfo{DefaultVCpus: aws.Int64(m.vcpus[aws.StringValue(t)])},
+		})
+	}
+	fn(out, true)
+	return nil
+}
+
+func (m *mockEC2) DescribeInstancesPages(_ *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	r := &ec2.Reservation{}
+	for _, cores := range m.running {
+		r.Instances = append(r.Instances, &ec2.Instance{CpuOptions: &ec2.CpuOptions{CoreCount: aws.Int64(cores), ThreadsPerCore: aws.Int64(2)}})
+	}
+	r.Instances = append(r.Instances, &ec2.Instance{InstanceLifecycle: aws.String("spot"), CpuOptions: &ec2.CpuOptions{CoreCount: aws.Int64(64), ThreadsPerCore: aws.Int64(2)}})
+	fn(&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{r}}, true)
+	return nil
+}
+
+type mockAutoscaling struct {
+	autoscalingiface.AutoScalingAPI
+	max, used int64
+}
+
+func (m *mockAutoscaling) DescribeAccountLimits(*autoscaling.DescribeAccountLimitsInput) (*autoscaling.DescribeAccountLimitsOutput, error) {
+	return &autoscaling.DescribeAccountLimitsOutput{
+		MaxNumberOfAutoScalingGroups: aws.Int64(m.max),
+		NumberOfAutoScalingGroups:    aws.Int64(m.used),
+	}, nil
+}
+
+type mockServiceQuotas struct {
+	servicequotasiface.ServiceQuotasAPI
+	vcpus float64
+}
+
+func (m *mockServiceQuotas) GetServiceQuota(*servicequotas.GetServiceQuotaInput) (*servicequotas.GetServiceQuotaOutput, error) {
+	return &servicequotas.GetServiceQuotaOutput{Quota: &servicequotas.ServiceQuota{Value: aws.Float64(m.vcpus)}}, nil
+}
+
+func TestGetAWSQuotaShortfalls(t *testing.T) {
+	cluster := &kopsapi.Cluster{Spec: kopsapi.ClusterSpec{Subnets: []kopsapi.ClusterSubnetSpec{
+		{Name: "us-east-1a", Zone: "us-east-1a", Type: kopsapi.SubnetTypePrivate},
+		{Name: "us-east-1b", Zone: "us-east-1b", Type: kopsapi.SubnetTypePrivate},
+		{Name: "us-east-1c", Zone: "us-east-1c", Type: kopsapi.SubnetTypePrivate, Egress: "nat-0123"},
+		{Name: "utility-us-east-1a", Zone: "us-east-1a", Type: kopsapi.SubnetTypeUtility},
+	}}}
+	igs := []kopsapi.InstanceGroupSpec{
+		{MachineType: "t3.medium", MinSize: fi.Int32(1), MaxSize: fi.Int32(1)},
+		{MachineType: "m5.large", MinSize: fi.Int32(2), MaxSize: fi.Int32(4)},
+		{MachineType: "m5.large", MinSize: fi.Int32(10), MaxSize: fi.Int32(10), MaxPrice: aws.String("0.1")},
+	}
+	vcpus := map[string]int64{"t3.medium": 2, "m5.large": 2}
+
+	cases := map[string]struct {
+		reason string
+		ec2    *mockEC2
+		as     *mockAutoscaling
+		sq     *mockServiceQuotas
+		want   []string
+	}{
+		"Sufficient": {
+			reason: "No shortfalls should be returned if every quota has enough headroom.",
+			ec2:    &mockEC2{maxEIPs: "5", addresses: 3, vcpus: vcpus, running: []int64{2}},
+			as:     &mockAutoscaling{max: 200, used: 197},
+			sq:     &mockServiceQuotas{vcpus: 14},
+		},
+		"Insufficient": {
+			reason: "A shortfall should be returned for every quota without enough headroom, ignoring spot instances.",
+			ec2:    &mockEC2{maxEIPs: "5", addresses: 4, vcpus: vcpus, running: []int64{2}},
+			as:     &mockAutoscaling{max: 200, used: 198},
+			sq:     &mockServiceQuotas{vcpus: 13},
+			want: []string{
+				"autoscaling groups: 3 needed, 2 available",
+				"elastic IPs: 2 needed, 1 available",
+				"on-demand vCPUs: 10 needed, 9 available",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := GetAWSQuotaShortfalls(tc.ec2, tc.as, tc.sq, cluster, igs)
+			if err != nil {
+				t.Fatalf("GetAWSQuotaShortfalls(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nGetAWSQuotaShortfalls(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
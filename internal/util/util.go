@@ -1,30 +1,107 @@
 package util
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/acm/acmiface"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/blang/semver/v4"
 	"github.com/crossplane/crossplane-runtime/pkg/meta"
-	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	kvalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
+	channelsapi "k8s.io/kops/channels/pkg/api"
 	"k8s.io/kops/cmd/kops/util"
 	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/apis/kops/registry"
+	"k8s.io/kops/pkg/assets"
 	kopsClient "k8s.io/kops/pkg/client/simple"
+	"k8s.io/kops/pkg/cloudinstances"
+	kopsdns "k8s.io/kops/pkg/dns"
+	"k8s.io/kops/pkg/kopscodecs"
 	"k8s.io/kops/pkg/kubeconfig"
 	"k8s.io/kops/pkg/pki"
 	"k8s.io/kops/pkg/rbac"
+	"k8s.io/kops/pkg/resources"
+	awsresources "k8s.io/kops/pkg/resources/aws"
+	"k8s.io/kops/pkg/util/subnet"
 	"k8s.io/kops/pkg/validation"
 	"k8s.io/kops/upup/pkg/fi"
-	"k8s.io/kops/upup/pkg/fi/cloudup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"k8s.io/kops/util/pkg/architectures"
+	"k8s.io/kops/util/pkg/reflectutils"
+	"k8s.io/kops/util/pkg/vfs"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/metrics"
+	"github.com/crossplane/provider-kops/internal/version"
+)
+
+const (
+	// KubeconfigCommonNamePrefix prefixes the common name of the client
+	// certificates issued for kubeconfigs
+	KubeconfigCommonNamePrefix = "crossplane:provider-kops:"
+	// KubeconfigCertificateTTL is the validity of the client certificates
+	// issued for kubeconfigs
+	KubeconfigCertificateTTL = 18 * time.Hour
+)
+
+// Reasons a client certificate is issued for.
+const (
+	CertificateReasonValidation        = "validation"
+	CertificateReasonConnectionDetails = "connection-details"
+	CertificateReasonClusterEvents     = "cluster-events"
 )
 
 // GetKopsClientset returns a kops client set for a given configBase
@@ -44,10 +121,99 @@ func GetKopsClientset(stateBucket, clusterName, domain string) (kopsClient.Clien
 	return kopsClientset, nil
 }
 
+// GetStateStoreClientset returns a kops clientset for a state store, for
+// operations that span the clusters in it
+func GetStateStoreClientset(stateBucket string) (kopsClient.Clientset, error) {
+	factory := util.NewFactory(&util.FactoryOptions{
+		RegistryPath: stateBucket,
+	})
+	return factory.Clientset()
+}
+
+// GetS3StateBucketName returns the name of the S3 bucket of a state store, or
+// an empty string if the state store isn't in S3
+func GetS3StateBucketName(stateBucket string) string {
+	if !strings.HasPrefix(stateBucket, "s3://") {
+		return ""
+	}
+	name := strings.TrimPrefix(stateBucket, "s3://")
+	if i := strings.Index(name, "/"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// ProvisionStateBucket creates the S3 bucket of a state store in the supplied
+// region if it doesn't exist, with versioning, default encryption and all
+// public access blocked, and returns whether it created it. Existing buckets
+// are left as they are
+func ProvisionStateBucket(s3API s3iface.S3API, bucket, region string) (bool, error) {
+	_, err := s3API.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		return false, nil
+	}
+	var rf awserr.RequestFailure
+	if !errors.As(err, &rf) || rf.StatusCode() != http.StatusNotFound {
+		return false, errors.Wrap(err, "cannot look up state bucket")
+	}
+
+	in := &s3.CreateBucketInput{Bucket: aws.String(bucket)}
+	// Buckets in us-east-1 must be created without a location constraint.
+	if region != "us-east-1" {
+		in.CreateBucketConfiguration = &s3.CreateBucketConfiguration{LocationConstraint: aws.String(region)}
+	}
+	if _, err := s3API.CreateBucket(in); err != nil {
+		return false, errors.Wrap(err, "cannot create state bucket")
+	}
+	if err := configureStateBucket(s3API, bucket); err != nil {
+		// The bucket is still empty, so it is deleted to be created and
+		// configured again, rather than left behind unconfigured.
+		_, _ = s3API.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(bucket)})
+		return false, err
+	}
+	return true, nil
+}
+
+// configureStateBucket enables versioning and default encryption of a state
+// bucket, and blocks all public access to it. Every version of the state is
+// kept, so that a cluster can be restored after a bad apply or an accidental
+// deletion
+func configureStateBucket(s3API s3iface.S3API, bucket string) error {
+	if _, err := s3API.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket:                  aws.String(bucket),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(s3.BucketVersioningStatusEnabled)},
+	}); err != nil {
+		return errors.Wrap(err, "cannot enable versioning of state bucket")
+	}
+	if _, err := s3API.PutBucketEncryption(&s3.PutBucketEncryptionInput{
+		Bucket: aws.String(bucket),
+		ServerSideEncryptionConfiguration: &s3.ServerSideEncryptionConfiguration{Rules: []*s3.ServerSideEncryptionRule{{
+			ApplyServerSideEncryptionByDefault: &s3.ServerSideEncryptionByDefault{SSEAlgorithm: aws.String(s3.ServerSideEncryptionAes256)},
+		}}},
+	}); err != nil {
+		return errors.Wrap(err, "cannot enable encryption of state bucket")
+	}
+	_, err := s3API.PutPublicAccessBlock(&s3.PutPublicAccessBlockInput{
+		Bucket: aws.String(bucket),
+		PublicAccessBlockConfiguration: &s3.PublicAccessBlockConfiguration{
+			BlockPublicAcls:       aws.Bool(true),
+			BlockPublicPolicy:     aws.Bool(true),
+			IgnorePublicAcls:      aws.Bool(true),
+			RestrictPublicBuckets: aws.Bool(true),
+		},
+	})
+	return errors.Wrap(err, "cannot block public access to state bucket")
+}
+
 // CreateClusterSpec creates a cluster spec from a cluster object
 func CreateClusterSpec(cr *v1alpha1.Kops) *kopsapi.Cluster {
-	clusterSpec := cr.Spec.ForProvider.ClusterSpec
+	clusterSpec := DesiredClusterSpec(cr)
 	clusterSpec.ConfigBase = fmt.Sprintf("%s/%s.%s", cr.Spec.ForProvider.StateBucket, meta.GetExternalName(cr), cr.Spec.ForProvider.Domain)
+	if len(cr.Spec.ForProvider.Addons) > 0 {
+		clusterSpec.Addons = append(append([]kopsapi.AddonSpec{}, clusterSpec.Addons...), kopsapi.AddonSpec{
+			Manifest: clusterSpec.ConfigBase + "/" + AddonsChannelPath,
+		})
+	}
 	return &kopsapi.Cluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: fmt.Sprintf("%v.%v", meta.GetExternalName(cr), cr.Spec.ForProvider.Domain),
@@ -56,6 +222,76 @@ func CreateClusterSpec(cr *v1alpha1.Kops) *kopsapi.Cluster {
 	}
 }
 
+// ImportKops returns a Kops resource that manages the existing kops cluster
+// with the supplied full name, as read from the state store of the supplied
+// clientset, so that clusters created with the kops CLI can be handed over to
+// the provider. The cluster is claimed by the first Kops resource that
+// observes it.
+func ImportKops(ctx context.Context, kopsClientset kopsClient.Clientset, stateBucket, clusterName string) (*v1alpha1.Kops, error) {
+	i := strings.Index(clusterName, ".")
+	if i < 0 {
+		return nil, errors.Errorf("cluster name %q has no domain", clusterName)
+	}
+	name, domain := clusterName[:i], clusterName[i+1:]
+	cluster, err := kopsClientset.GetCluster(ctx, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	igs, err := kopsClientset.InstanceGroupsFor(cluster).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	cr := &v1alpha1.Kops{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.SchemeGroupVersion.String(), Kind: v1alpha1.KopsKind},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}
+	meta.SetExternalName(cr, name)
+	cr.Spec.ForProvider.StateBucket = stateBucket
+	cr.Spec.ForProvider.Domain = domain
+	cr.Spec.ForProvider.ClusterSpec = *cluster.Spec.DeepCopy()
+	// The provider derives the config base from the state bucket.
+	cr.Spec.ForProvider.ClusterSpec.ConfigBase = ""
+	cr.Spec.ForProvider.InstanceGroupSpec = make([]kopsapi.InstanceGroupSpec, 0, len(igs.Items))
+	for _, ig := range igs.Items {
+		spec := *ig.Spec.DeepCopy()
+		// Instance groups are named by their instance group node label.
+		if spec.NodeLabels == nil {
+			spec.NodeLabels = map[string]string{}
+		}
+		spec.NodeLabels[kopsapi.NodeLabelInstanceGroup] = ig.ObjectMeta.Name
+		cr.Spec.ForProvider.InstanceGroupSpec = append(cr.Spec.ForProvider.InstanceGroupSpec, spec)
+	}
+	return cr, nil
+}
+
+// Annotations recording the provenance of a kops cluster on its cluster object
+// in the state store, so that changes to its cloud resources can be traced
+// back to the Kops resource that applied them
+const (
+	AnnotationManagedBy       = "kops.crossplane.io/managed-by"
+	AnnotationResourceName    = "kops.crossplane.io/resource-name"
+	AnnotationResourceUID     = "kops.crossplane.io/resource-uid"
+	AnnotationLastApplyTime   = "kops.crossplane.io/last-apply-time"
+	AnnotationProviderVersion = "kops.crossplane.io/provider-version"
+)
+
+// ManagedByProvider is the value of the managed-by annotation of the kops
+// clusters the provider applies
+const ManagedByProvider = "provider-kops"
+
+// SetProvenance annotates a kops cluster object with the Kops resource that
+// manages it, the version of the provider, and the time it is applied at
+func SetProvenance(kopsCluster *kopsapi.Cluster, cr *v1alpha1.Kops, t time.Time) {
+	meta.AddAnnotations(kopsCluster, map[string]string{
+		AnnotationManagedBy:       ManagedByProvider,
+		AnnotationResourceName:    cr.GetName(),
+		AnnotationResourceUID:     string(cr.GetUID()),
+		AnnotationLastApplyTime:   t.UTC().Format(time.RFC3339),
+		AnnotationProviderVersion: version.Version,
+	})
+}
+
 // CreateInstanceGroupSpec creates an instance group spec from an instance group object
 func CreateInstanceGroupSpec(cr kopsapi.InstanceGroupSpec) *kopsapi.InstanceGroup {
 	return &kopsapi.InstanceGroup{
@@ -66,8 +302,28 @@ func CreateInstanceGroupSpec(cr kopsapi.InstanceGroupSpec) *kopsapi.InstanceGrou
 	}
 }
 
-// GetKubeconfigFromKopsState returns a kubeconfig for a given kops cluster
-func GetKubeconfigFromKopsState(kopsCluster *kopsapi.Cluster, kopsClientset kopsClient.Clientset) (*rest.Config, error) {
+// KubeconfigCommonName returns the common name of the client certificates
+// issued for kubeconfigs of the supplied cluster on behalf of the Kops resource
+// with the supplied UID, so that audit logs of the cluster tell apart the
+// resources, and thus the management flows, acting on it
+func KubeconfigCommonName(cluster string, uid types.UID) string {
+	if uid == "" {
+		return KubeconfigCommonNamePrefix + cluster
+	}
+	return KubeconfigCommonNamePrefix + cluster + ":" + string(uid)
+}
+
+// GetKubeconfigFromKopsState returns a kubeconfig for a given kops cluster,
+// issuing a new client certificate with the supplied common name for the
+// supplied reason
+func GetKubeconfigFromKopsState(kopsCluster *kopsapi.Cluster, kopsClientset kopsClient.Clientset, commonName, reason string) (*rest.Config, error) {
+	return GetKubeconfigForGroup(kopsCluster, kopsClientset, commonName, reason, rbac.SystemPrivilegedGroup, KubeconfigCertificateTTL)
+}
+
+// GetKubeconfigForGroup returns a kubeconfig for a given kops cluster, issuing
+// a new client certificate with the supplied common name, valid for the
+// supplied duration, into the supplied group for the supplied reason
+func GetKubeconfigForGroup(kopsCluster *kopsapi.Cluster, kopsClientset kopsClient.Clientset, commonName, reason, group string, ttl time.Duration) (*rest.Config, error) {
 	builder := kubeconfig.NewKubeconfigBuilder()
 
 	keyStore, err := kopsClientset.KeyStore(kopsCluster)
@@ -76,33 +332,26 @@ func GetKubeconfigFromKopsState(kopsCluster *kopsapi.Cluster, kopsClientset kops
 	}
 
 	builder.Context = kopsCluster.ObjectMeta.Name
-	builder.Server = fmt.Sprintf("https://api.%s", kopsCluster.ObjectMeta.Name)
-	keySet, err := keyStore.FindKeyset(fi.CertificateIDCA)
+	builder.Server = GetAPIEndpoint(kopsCluster)
+	builder.CACerts, err = getCACertificates(keyStore)
 	if err != nil {
 		return nil, err
 	}
-	if keySet != nil {
-		builder.CACerts, err = keySet.ToCertificateBytes()
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		return nil, fmt.Errorf("cannot find CA certificate")
-	}
 
 	req := pki.IssueCertRequest{
 		Signer: fi.CertificateIDCA,
 		Type:   "client",
 		Subject: pkix.Name{
-			CommonName:   "kops-operator",
-			Organization: []string{rbac.SystemPrivilegedGroup},
+			CommonName:   commonName,
+			Organization: []string{group},
 		},
-		Validity: 64800000000000,
+		Validity: ttl,
 	}
 	cert, privateKey, _, err := pki.IssueCert(&req, keyStore)
 	if err != nil {
 		return nil, err
 	}
+	metrics.RecordCertificateIssued(kopsCluster.ObjectMeta.Name, commonName, reason, ttl)
 	builder.ClientCert, err = cert.AsBytes()
 	if err != nil {
 		return nil, err
@@ -116,136 +365,3594 @@ func GetKubeconfigFromKopsState(kopsCluster *kopsapi.Cluster, kopsClientset kops
 	if err != nil {
 		return nil, err
 	}
+	// client-go caches a transport for every distinct TLS config, forever.
+	// Each config returned here has a new client certificate, so setting a
+	// proxy (the default one) keeps its transport out of that cache, and lets
+	// it be released along with the client built from it.
+	config.Proxy = http.ProxyFromEnvironment
 
 	return config, nil
 }
 
-// ValidateKopsCluster validates a kops cluster
-func ValidateKopsCluster(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, igs *kopsapi.InstanceGroupList) (*validation.ValidationCluster, error) {
-	config, err := GetKubeconfigFromKopsState(kopsCluster, kopsClientset)
+// GetKubeconfigForToken returns a kubeconfig for a given kops cluster that
+// authenticates with the supplied bearer token rather than a client
+// certificate
+func GetKubeconfigForToken(kopsCluster *kopsapi.Cluster, kopsClientset kopsClient.Clientset, token string) (*rest.Config, error) {
+	keyStore, err := kopsClientset.KeyStore(kopsCluster)
 	if err != nil {
 		return nil, err
 	}
-
-	k8sClient, err := kubernetes.NewForConfig(config)
+	ca, err := getCACertificates(keyStore)
 	if err != nil {
 		return nil, err
 	}
+	return &rest.Config{
+		Host:            GetAPIEndpoint(kopsCluster),
+		BearerToken:     token,
+		TLSClientConfig: rest.TLSClientConfig{CAData: ca},
+	}, nil
+}
 
-	cloud, err := cloudup.BuildCloud(kopsCluster)
+// getCACertificates returns the PEM encoded certificates of the CA keyset in
+// a keystore
+func getCACertificates(keyStore fi.Keystore) ([]byte, error) {
+	keySet, err := keyStore.FindKeyset(fi.CertificateIDCA)
 	if err != nil {
 		return nil, err
 	}
+	if keySet == nil {
+		return nil, fmt.Errorf("cannot find CA certificate")
+	}
+	return keySet.ToCertificateBytes()
+}
+
+// HashCredentials returns the SHA-256 hash of credentials
+func HashCredentials(credentials []byte) string {
+	sum := sha256.Sum256(credentials)
+	return hex.EncodeToString(sum[:])
+}
+
+// ConnectionRBACName is the name of the ClusterRole, and of its binding, that
+// grant the group of the published kubeconfig access to a cluster
+const ConnectionRBACName = "provider-kops:connection"
+
+// DefaultConnectionRBACRules are the rules granted to the group of the
+// published kubeconfig when none are configured, allowing it to read all
+// resources
+var DefaultConnectionRBACRules = []rbacv1.PolicyRule{{
+	APIGroups: []string{"*"},
+	Resources: []string{"*"},
+	Verbs:     []string{"get", "list", "watch"},
+}}
+
+// EnsureConnectionRBAC creates or updates the ClusterRole granting the
+// supplied rules, and its binding to the supplied group
+func EnsureConnectionRBAC(ctx context.Context, kube kubernetes.Interface, group string, rules []rbacv1.PolicyRule) error {
+	if len(rules) == 0 {
+		rules = DefaultConnectionRBACRules
+	}
+	labels := map[string]string{"app.kubernetes.io/managed-by": "provider-kops"}
 
-	validator, err := validation.NewClusterValidator(kopsCluster, cloud, igs, fmt.Sprintf("https://api.%s:443", kopsCluster.ObjectMeta.Name), k8sClient)
-	if err != nil {
-		return nil, fmt.Errorf("unexpected error creating validator: %v", err)
+	role, err := kube.RbacV1().ClusterRoles().Get(ctx, ConnectionRBACName, metav1.GetOptions{})
+	switch {
+	case kerrors.IsNotFound(err):
+		role = &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: ConnectionRBACName, Labels: labels}, Rules: rules}
+		if _, err := kube.RbacV1().ClusterRoles().Create(ctx, role, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !reflect.DeepEqual(role.Rules, rules):
+		role.Rules = rules
+		if _, err := kube.RbacV1().ClusterRoles().Update(ctx, role, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
 	}
 
-	result, err := validator.Validate()
-	if err != nil {
-		return nil, fmt.Errorf("%v", err)
+	subjects := []rbacv1.Subject{{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: group}}
+	binding, err := kube.RbacV1().ClusterRoleBindings().Get(ctx, ConnectionRBACName, metav1.GetOptions{})
+	switch {
+	case kerrors.IsNotFound(err):
+		binding = &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: ConnectionRBACName, Labels: labels},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: ConnectionRBACName},
+			Subjects:   subjects,
+		}
+		_, err = kube.RbacV1().ClusterRoleBindings().Create(ctx, binding, metav1.CreateOptions{})
+	case err == nil && !reflect.DeepEqual(binding.Subjects, subjects):
+		binding.Subjects = subjects
+		_, err = kube.RbacV1().ClusterRoleBindings().Update(ctx, binding, metav1.UpdateOptions{})
 	}
-	return result, nil
+	return err
 }
 
-// EvaluateKopsValidationResult evaluates a kops validation result
-func EvaluateKopsValidationResult(validation *validation.ValidationCluster) (bool, []string) {
-	result := true
-	var errorMessages []string
+// GetAPIEndpoint returns the Kubernetes API server URL of a kops cluster
+func GetAPIEndpoint(kopsCluster *kopsapi.Cluster) string {
+	if kopsCluster.Spec.MasterPublicName != "" {
+		return httpsURL(kopsCluster.Spec.MasterPublicName)
+	}
+	return fmt.Sprintf("https://api.%s", kopsCluster.ObjectMeta.Name)
+}
 
-	failures := validation.Failures
-	if len(failures) > 0 {
-		result = false
-		for _, failure := range failures {
-			errorMessages = append(errorMessages, failure.Message)
+// httpsURL returns the HTTPS URL of a host, which may be an IP address of a
+// cluster whose API server isn't published under a DNS name. IPv6 addresses
+// are bracketed, as URLs require
+func httpsURL(host string) string {
+	if utils.IsIPv6IP(host) {
+		return fmt.Sprintf("https://[%s]", host)
+	}
+	return fmt.Sprintf("https://%s", host)
+}
+
+// GetServiceAccountIssuer returns the service account issuer URL of a kops
+// cluster, following the same rules kops uses to default it
+func GetServiceAccountIssuer(kopsCluster *kopsapi.Cluster) (string, error) {
+	spec := kopsCluster.Spec
+	if spec.KubeAPIServer != nil && spec.KubeAPIServer.ServiceAccountIssuer != nil {
+		return *spec.KubeAPIServer.ServiceAccountIssuer, nil
+	}
+
+	if said := spec.ServiceAccountIssuerDiscovery; said != nil && said.DiscoveryStore != "" {
+		base, err := vfs.Context.BuildVfsPath(said.DiscoveryStore)
+		if err != nil {
+			return "", err
 		}
+		s3Path, ok := base.(*vfs.S3Path)
+		if !ok {
+			return "", fmt.Errorf("unsupported service account issuer discovery store %q", said.DiscoveryStore)
+		}
+		return s3Path.GetHTTPsUrl(spec.IsIPv6Only())
 	}
 
-	nodes := validation.Nodes
-	for _, node := range nodes {
-		if node.Status == corev1.ConditionFalse {
-			result = false
-			errorMessages = append(errorMessages, fmt.Sprintf("node %s condition is %s", node.Hostname, node.Status))
+	if spec.KubeAPIServer != nil && fi.BoolValue(spec.KubeAPIServer.AnonymousAuth) {
+		for _, cidr := range spec.KubernetesAPIAccess {
+			if cidr == "0.0.0.0/0" || cidr == "::/0" {
+				return GetAPIEndpoint(kopsCluster), nil
+			}
 		}
 	}
 
-	return result, errorMessages
+	if spec.MasterInternalName != "" {
+		return httpsURL(spec.MasterInternalName), nil
+	}
+	return fmt.Sprintf("https://api.internal.%s", kopsCluster.ObjectMeta.Name), nil
+}
+
+// GetOIDCDiscoveryURL returns the OIDC discovery document URL for a service
+// account issuer
+func GetOIDCDiscoveryURL(issuer string) string {
+	return strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
 }
 
-// GenerateKubeConfig generates a kubeconfig for a given kops cluster
-func GenerateKubeConfig(kopsCluster *kopsapi.Cluster, kopsClientset kopsClient.Clientset) ([]byte, error) {
-	config, err := GetKubeconfigFromKopsState(kopsCluster, kopsClientset)
+// GetCACertificate returns the primary CA certificate of a kops cluster
+func GetCACertificate(kopsCluster *kopsapi.Cluster, kopsClientset kopsClient.Clientset) (*pki.Certificate, error) {
+	keyStore, err := kopsClientset.KeyStore(kopsCluster)
 	if err != nil {
 		return nil, err
 	}
 
-	clusterName := kopsCluster.GetName()
+	keySet, err := keyStore.FindKeyset(fi.CertificateIDCA)
+	if err != nil {
+		return nil, err
+	}
+	if keySet == nil || keySet.Primary == nil || keySet.Primary.Certificate == nil {
+		return nil, fmt.Errorf("cannot find CA certificate")
+	}
 
-	kc := api.Config{
-		APIVersion: clusterv1.GroupVersion.String(),
-		Kind:       "Cluster",
-		Clusters: map[string]*api.Cluster{
-			clusterName: {
-				Server:                   config.Host,
-				CertificateAuthorityData: config.CAData,
-			},
-		},
-		Contexts: map[string]*api.Context{
-			clusterName: {
-				Cluster:  clusterName,
-				AuthInfo: clusterName,
-			},
-		},
-		CurrentContext: kopsCluster.ObjectMeta.Name,
-		AuthInfos: map[string]*api.AuthInfo{
-			clusterName: {
-				ClientCertificateData: config.CertData,
-				ClientKeyData:         config.KeyData,
-			},
-		},
+	return keySet.Primary.Certificate, nil
+}
+
+// GetCAKeysetFingerprint returns the SHA-256 fingerprint of the CA keyset of a
+// kops cluster, which changes whenever a CA is added to, promoted in, or
+// distrusted by the keyset, e.g. while the CA is rotated
+func GetCAKeysetFingerprint(kopsCluster *kopsapi.Cluster, kopsClientset kopsClient.Clientset) (string, error) {
+	keyStore, err := kopsClientset.KeyStore(kopsCluster)
+	if err != nil {
+		return "", err
 	}
 
-	out, err := clientcmd.Write(kc)
+	keySet, err := keyStore.FindKeyset(fi.CertificateIDCA)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to serialize config to yaml")
+		return "", err
+	}
+	if keySet == nil || keySet.Primary == nil {
+		return "", fmt.Errorf("cannot find CA certificate")
+	}
+	b, err := keySet.ToCertificateBytes()
+	if err != nil {
+		return "", err
 	}
 
-	return out, nil
+	h := sha256.New()
+	h.Write([]byte(keySet.Primary.Id + "\n"))
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// ClusterResourceUpToDate checks if the cluster resource is up to date
-func ClusterResourceUpToDate(old, new *kopsapi.ClusterSpec) bool {
-	new.ConfigBase = ""
-	new.MasterPublicName = ""
-	return reflect.DeepEqual(old, new)
+// GetCertificateFingerprint returns the SHA-256 fingerprint of a certificate
+func GetCertificateFingerprint(cert *pki.Certificate) string {
+	sum := sha256.Sum256(cert.Certificate.Raw)
+	return hex.EncodeToString(sum[:])
 }
 
-// InstanceGroupListResourceUpToDate checks if the instance group list resource is up to date
-func InstanceGroupListResourceUpToDate(old []kopsapi.InstanceGroupSpec, new *kopsapi.InstanceGroupList) bool {
-	for index := range old {
-		if !InstanceGroupResourceUpToDate(&old[index], &new.Items[index].Spec) {
+// GetCertificateExpiry returns the expiry of a PEM encoded certificate
+func GetCertificateExpiry(pemData []byte) (time.Time, error) {
+	cert, err := pki.ParsePEMCertificate(pemData)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.Certificate.NotAfter, nil
+}
+
+// HashParameters returns the SHA-256 hash of the JSON encoding of the
+// parameters of a Kops resource
+func HashParameters(p v1alpha1.KopsParameters) (string, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// HashClusterState returns the SHA-256 hash of the JSON encoding of the specs
+// of a kops cluster and its instance groups, as read from its state store
+func HashClusterState(kopsCluster *kopsapi.Cluster, igs *kopsapi.InstanceGroupList) (string, error) {
+	state := struct {
+		Cluster        kopsapi.ClusterSpec                  `json:"cluster"`
+		InstanceGroups map[string]kopsapi.InstanceGroupSpec `json:"instanceGroups"`
+	}{Cluster: kopsCluster.Spec, InstanceGroups: map[string]kopsapi.InstanceGroupSpec{}}
+	for _, ig := range igs.Items {
+		state.InstanceGroups[ig.GetName()] = ig.Spec
+	}
+	// Maps are encoded sorted by key.
+	b, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ValidateKopsCluster validates a kops cluster using the supplied client of
+// its API server. The kops validator takes no context, so validation is
+// abandoned rather than cancelled when the supplied context is done; it ends
+// on its own once the requests of the client time out
+func ValidateKopsCluster(ctx context.Context, kopsCluster *kopsapi.Cluster, cloud fi.Cloud, igs *kopsapi.InstanceGroupList, k8sClient kubernetes.Interface) (*validation.ValidationCluster, error) {
+	validator, err := validation.NewClusterValidator(kopsCluster, cloud, igs, fmt.Sprintf("https://api.%s:443", kopsCluster.ObjectMeta.Name), k8sClient)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error creating validator: %v", err)
+	}
+
+	type validated struct {
+		result *validation.ValidationCluster
+		err    error
+	}
+	done := make(chan validated, 1)
+	go func() {
+		result, err := validator.Validate()
+		done <- validated{result: result, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case v := <-done:
+		if v.err != nil {
+			return nil, fmt.Errorf("%v", v.err)
+		}
+		return v.result, nil
+	}
+}
+
+// GetPendingRollingUpdates returns the number of instances of each instance
+// group of a kops cluster that need to be replaced to pick up its current
+// configuration, as kops rolling-update cluster would report them
+func GetPendingRollingUpdates(groups map[string]*cloudinstances.CloudInstanceGroup) map[string]int {
+	pending := map[string]int{}
+	for _, g := range groups {
+		if len(g.NeedUpdate) > 0 {
+			pending[g.InstanceGroup.ObjectMeta.Name] = len(g.NeedUpdate)
+		}
+	}
+	return pending
+}
+
+// GetCloudGroups returns the cloud groups backing the instance groups of a
+// kops cluster
+func GetCloudGroups(cloud fi.Cloud, kopsCluster *kopsapi.Cluster, igs *kopsapi.InstanceGroupList) (map[string]*cloudinstances.CloudInstanceGroup, error) {
+	instanceGroups := make([]*kopsapi.InstanceGroup, len(igs.Items))
+	for i := range igs.Items {
+		instanceGroups[i] = &igs.Items[i]
+	}
+	return cloud.GetCloudGroups(kopsCluster, instanceGroups, false, nil)
+}
+
+// InfrastructureMissing returns true if none of the instance groups of a kops
+// cluster that should have instances has any in the cloud
+func InfrastructureMissing(igs *kopsapi.InstanceGroupList, groups map[string]*cloudinstances.CloudInstanceGroup) bool {
+	desired := 0
+	for _, ig := range igs.Items {
+		if ig.Spec.MinSize != nil {
+			desired += int(*ig.Spec.MinSize)
+		}
+	}
+	if desired == 0 {
+		return false
+	}
+	for _, g := range groups {
+		if len(g.Ready)+len(g.NeedUpdate) > 0 {
 			return false
 		}
 	}
 	return true
 }
 
-// InstanceGroupResourceUpToDate checks if the instance group resource is up to date
-func InstanceGroupResourceUpToDate(old, new *kopsapi.InstanceGroupSpec) bool {
-	return reflect.DeepEqual(old, new)
+// GetInstanceConnections returns how to reach the instances of the supplied
+// cloud groups, by instance group. Instance IDs are SSM targets if ssm is true
+func GetInstanceConnections(groups map[string]*cloudinstances.CloudInstanceGroup, ssm bool) []v1alpha1.InstanceGroupConnectionObservation {
+	obs := make([]v1alpha1.InstanceGroupConnectionObservation, 0, len(groups))
+	for name, g := range groups {
+		o := v1alpha1.InstanceGroupConnectionObservation{InstanceGroup: name}
+		for _, members := range [][]*cloudinstances.CloudInstance{g.Ready, g.NeedUpdate} {
+			for _, m := range members {
+				c := v1alpha1.InstanceConnection{ID: m.ID, PrivateIP: m.PrivateIP}
+				if ssm {
+					c.SSMTarget = m.ID
+				}
+				if m.Node != nil {
+					c.Node = m.Node.Name
+				}
+				o.Instances = append(o.Instances, c)
+			}
+		}
+		sort.Slice(o.Instances, func(i, j int) bool { return o.Instances[i].ID < o.Instances[j].ID })
+		obs = append(obs, o)
+	}
+	sort.Slice(obs, func(i, j int) bool { return obs[i].InstanceGroup < obs[j].InstanceGroup })
+	return obs
 }
 
-// GetClusterStatus returns the cluster status
-func GetClusterStatus(kopsCluster *kopsapi.Cluster, cloud fi.Cloud) (*kopsapi.ClusterStatus, error) {
-	status, err := cloud.FindClusterStatus(kopsCluster)
+// capacityFailures are fragments of the status messages of scaling
+// activities that failed for lack of EC2 capacity
+var capacityFailures = []string{
+	"InsufficientInstanceCapacity",
+	"There is no Spot capacity available",
+	"SpotMaxPriceTooLow",
+}
+
+// GetCapacityFailures returns the status messages of the last scaling
+// activities of the supplied cloud groups that failed for lack of EC2
+// capacity, by instance group name
+func GetCapacityFailures(asAPI autoscalingiface.AutoScalingAPI, groups map[string]*cloudinstances.CloudInstanceGroup) (map[string]string, error) {
+	failures := map[string]string{}
+	for name, g := range groups {
+		out, err := asAPI.DescribeScalingActivities(&autoscaling.DescribeScalingActivitiesInput{
+			AutoScalingGroupName: aws.String(g.HumanName),
+			MaxRecords:           aws.Int64(1),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot describe scaling activities of autoscaling group %s", g.HumanName)
+		}
+		if len(out.Activities) == 0 || aws.StringValue(out.Activities[0].StatusCode) != autoscaling.ScalingActivityStatusCodeFailed {
+			continue
+		}
+		msg := aws.StringValue(out.Activities[0].StatusMessage)
+		for _, f := range capacityFailures {
+			if strings.Contains(msg, f) {
+				failures[name] = msg
+				break
+			}
+		}
+	}
+	return failures, nil
+}
+
+// IsSpotInstanceGroup returns true if an instance group launches spot
+// instances
+func IsSpotInstanceGroup(spec *kopsapi.InstanceGroupSpec) bool {
+	if p := spec.MixedInstancesPolicy; p != nil {
+		return p.OnDemandAboveBase != nil && *p.OnDemandAboveBase < 100
+	}
+	return spec.MaxPrice != nil
+}
+
+// ApplyOnDemandFallback returns a copy of the supplied instance group that
+// launches on-demand instances only
+func ApplyOnDemandFallback(spec *kopsapi.InstanceGroupSpec) *kopsapi.InstanceGroupSpec {
+	out := spec.DeepCopy()
+	out.MaxPrice = nil
+	if p := out.MixedInstancesPolicy; p != nil {
+		p.OnDemandAboveBase = fi.Int64(100)
+	}
+	return out
+}
+
+// GetEtcdClusterStatus returns the health of the etcd clusters of a kops
+// cluster from its etcd-manager pods and backup stores
+func GetEtcdClusterStatus(ctx context.Context, kube kubernetes.Interface, kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster) ([]v1alpha1.EtcdClusterObservation, error) {
+	obs := make([]v1alpha1.EtcdClusterObservation, 0, len(kopsCluster.Spec.EtcdClusters))
+
+	for _, etcdCluster := range kopsCluster.Spec.EtcdClusters {
+		o := v1alpha1.EtcdClusterObservation{
+			Name:    etcdCluster.Name,
+			Members: len(etcdCluster.Members),
+		}
+
+		pods, err := kube.CoreV1().Pods(metav1.NamespaceSystem).List(ctx, metav1.ListOptions{LabelSelector: "k8s-app=etcd-manager-" + etcdCluster.Name})
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			m := v1alpha1.EtcdMemberObservation{Node: pod.Spec.NodeName, Healthy: podReady(pod)}
+			if m.Healthy {
+				o.HealthyMembers++
+			}
+			o.MemberStatus = append(o.MemberStatus, m)
+		}
+
+		store, err := etcdBackupStore(kopsClientset, kopsCluster, etcdCluster)
+		if err != nil {
+			return nil, err
+		}
+		if _, o.LastBackupTime, err = latestEtcdBackup(store); err != nil {
+			return nil, err
+		}
+
+		obs = append(obs, o)
+	}
+
+	return obs, nil
+}
+
+func podReady(pod corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// etcdBackupStore returns the backup store of an etcd cluster, defaulting to
+// backups/etcd/<name> under the cluster's config base like kops does.
+func etcdBackupStore(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, etcdCluster kopsapi.EtcdClusterSpec) (vfs.Path, error) {
+	if etcdCluster.Backups != nil && etcdCluster.Backups.BackupStore != "" {
+		return vfs.Context.BuildVfsPath(etcdCluster.Backups.BackupStore)
+	}
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
 	if err != nil {
 		return nil, err
 	}
-	return status, nil
+	return configBase.Join("backups", "etcd", etcdCluster.Name), nil
+}
+
+// etcdBackups returns the times the backups in an etcd-manager backup store
+// were taken, by name. etcd-manager names each backup directory after the time
+// it was taken followed by a sequence number, e.g. 2006-01-02T15:04:05Z-000001.
+func etcdBackups(store vfs.Path) (map[string]time.Time, error) {
+	files, err := store.ReadTree()
+	if err != nil {
+		return nil, err
+	}
+
+	backups := map[string]time.Time{}
+	for _, f := range files {
+		name := strings.SplitN(strings.TrimPrefix(f.Path(), store.Path()+"/"), "/", 2)[0]
+		i := strings.LastIndex(name, "-")
+		if i < 0 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, name[:i])
+		if err != nil {
+			// Not a backup, e.g. the control directory.
+			continue
+		}
+		backups[name] = t
+	}
+
+	return backups, nil
+}
+
+// latestEtcdBackup returns the name and time of the latest backup in an
+// etcd-manager backup store, or an empty name if it holds no backups.
+func latestEtcdBackup(store vfs.Path) (string, *metav1.Time, error) {
+	backups, err := etcdBackups(store)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var name string
+	var latest *metav1.Time
+	for n, t := range backups {
+		if latest == nil || t.After(latest.Time) || (t.Equal(latest.Time) && n > name) {
+			name, latest = n, &metav1.Time{Time: t}
+		}
+	}
+
+	return name, latest, nil
+}
+
+// GetEtcdBackupsSince returns the name of the first backup of each etcd cluster
+// of a kops cluster taken at or after the supplied time. Clusters that have not
+// been backed up since are omitted
+func GetEtcdBackupsSince(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, since time.Time) (map[string]string, error) {
+	found := map[string]string{}
+	for _, etcdCluster := range kopsCluster.Spec.EtcdClusters {
+		store, err := etcdBackupStore(kopsClientset, kopsCluster, etcdCluster)
+		if err != nil {
+			return nil, err
+		}
+		backups, err := etcdBackups(store)
+		if err != nil {
+			return nil, err
+		}
+
+		var first time.Time
+		for name, t := range backups {
+			if t.Before(since) {
+				continue
+			}
+			if first.IsZero() || t.Before(first) {
+				found[etcdCluster.Name], first = name, t
+			}
+		}
+	}
+	return found, nil
+}
+
+// EtcdBackupLatest restores the latest backup of an etcd cluster
+const EtcdBackupLatest = "latest"
+
+// ParseEtcdRestoreRequest returns the backup to restore for each etcd cluster
+// of a kops cluster from the value of the etcd restore annotation
+func ParseEtcdRestoreRequest(req string, kopsCluster *kopsapi.Cluster) (map[string]string, error) {
+	backups := map[string]string{}
+	for _, entry := range strings.Split(req, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, backup := "", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			name, backup = entry[:i], entry[i+1:]
+		}
+		found := false
+		for _, etcdCluster := range kopsCluster.Spec.EtcdClusters {
+			if name == "" || name == etcdCluster.Name {
+				backups[etcdCluster.Name] = backup
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unknown etcd cluster %q", name)
+		}
+	}
+	if len(backups) == 0 {
+		return nil, errors.New("no backups requested")
+	}
+	return backups, nil
+}
+
+// etcdRestoreCommand is the etcd-manager command restoring an etcd cluster from
+// a backup, as written by etcd-manager-ctl restore-backup
+type etcdRestoreCommand struct {
+	Timestamp     int64                    `json:"timestamp,string"`
+	RestoreBackup etcdRestoreBackupCommand `json:"restoreBackup"`
+}
+
+type etcdRestoreBackupCommand struct {
+	ClusterSpec json.RawMessage `json:"clusterSpec"`
+	Backup      string          `json:"backup"`
+}
+
+// WriteEtcdRestoreCommands writes an etcd-manager command restoring the
+// supplied backup to the backup store of each etcd cluster of a kops cluster,
+// resolving latest to the name of the latest backup, and returns the backups
+// the commands restore. etcd-manager runs the commands when it next starts
+func WriteEtcdRestoreCommands(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, backups map[string]string, t time.Time) (map[string]string, error) {
+	stores := map[string]vfs.Path{}
+	resolved := map[string]string{}
+	for _, etcdCluster := range kopsCluster.Spec.EtcdClusters {
+		backup, ok := backups[etcdCluster.Name]
+		if !ok {
+			continue
+		}
+		store, err := etcdBackupStore(kopsClientset, kopsCluster, etcdCluster)
+		if err != nil {
+			return nil, err
+		}
+		if backup == EtcdBackupLatest {
+			if backup, _, err = latestEtcdBackup(store); err != nil {
+				return nil, err
+			}
+			if backup == "" {
+				return nil, errors.Errorf("etcd cluster %q has no backups", etcdCluster.Name)
+			}
+		}
+		stores[etcdCluster.Name] = store
+		resolved[etcdCluster.Name] = backup
+	}
+
+	// Every backup is resolved before any command is written, so that a
+	// request either restores every etcd cluster or none.
+	for name, store := range stores {
+		spec, err := store.Join("control", "etcd-cluster-spec").ReadFile()
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(&etcdRestoreCommand{
+			Timestamp:     t.UnixNano(),
+			RestoreBackup: etcdRestoreBackupCommand{ClusterSpec: spec, Backup: resolved[name]},
+		})
+		if err != nil {
+			return nil, err
+		}
+		p := store.Join("control", t.UTC().Format(time.RFC3339Nano), "_command.json")
+		if err := p.WriteFile(bytes.NewReader(b), nil); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// DeleteControlPlaneInstances deletes every control plane instance of a kops
+// cluster, to be replaced by its cloud groups, and returns how many were deleted
+func DeleteControlPlaneInstances(cloud fi.Cloud, kopsCluster *kopsapi.Cluster, igs *kopsapi.InstanceGroupList) (int, error) {
+	instances, err := getControlPlaneInstances(cloud, kopsCluster, igs)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, i := range instances {
+		if err := cloud.DeleteInstance(i); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// GetControlPlaneInstances returns the IDs of the control plane instances of a
+// kops cluster
+func GetControlPlaneInstances(cloud fi.Cloud, kopsCluster *kopsapi.Cluster, igs *kopsapi.InstanceGroupList) ([]string, error) {
+	instances, err := getControlPlaneInstances(cloud, kopsCluster, igs)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(instances))
+	for _, i := range instances {
+		ids = append(ids, i.ID)
+	}
+	return ids, nil
+}
+
+// ReplaceControlPlaneInstance deletes the first of the supplied control plane
+// instances of a kops cluster that still exists, to be replaced by its cloud
+// group. It returns the ID of the deleted instance, or an empty string if
+// none exists, and the IDs of the supplied instances that remain
+func ReplaceControlPlaneInstance(cloud fi.Cloud, kopsCluster *kopsapi.Cluster, igs *kopsapi.InstanceGroupList, ids []string) (string, []string, error) {
+	instances, err := getControlPlaneInstances(cloud, kopsCluster, igs)
+	if err != nil {
+		return "", nil, err
+	}
+	return replaceInstance(cloud, instances, ids)
+}
+
+// GetNodeInstances returns the IDs of the instances of the node instance
+// groups of a kops cluster
+func GetNodeInstances(cloud fi.Cloud, kopsCluster *kopsapi.Cluster, igs *kopsapi.InstanceGroupList) ([]string, error) {
+	instances, err := getNodeInstances(cloud, kopsCluster, igs)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(instances))
+	for _, i := range instances {
+		ids = append(ids, i.ID)
+	}
+	return ids, nil
+}
+
+// ReplaceNodeInstance deletes the first of the supplied node instances of a
+// kops cluster that still exists, to be replaced by its cloud group. It
+// returns the ID of the deleted instance, or an empty string if none exists,
+// and the IDs of the supplied instances that remain
+func ReplaceNodeInstance(cloud fi.Cloud, kopsCluster *kopsapi.Cluster, igs *kopsapi.InstanceGroupList, ids []string) (string, []string, error) {
+	instances, err := getNodeInstances(cloud, kopsCluster, igs)
+	if err != nil {
+		return "", nil, err
+	}
+	return replaceInstance(cloud, instances, ids)
+}
+
+func replaceInstance(cloud fi.Cloud, instances []*cloudinstances.CloudInstance, ids []string) (string, []string, error) {
+	byID := map[string]*cloudinstances.CloudInstance{}
+	for _, i := range instances {
+		byID[i.ID] = i
+	}
+
+	replaced := ""
+	remaining := []string{}
+	for _, id := range ids {
+		i, ok := byID[id]
+		switch {
+		case !ok:
+			continue
+		case replaced == "":
+			if err := cloud.DeleteInstance(i); err != nil {
+				return "", ids, err
+			}
+			replaced = id
+		default:
+			remaining = append(remaining, id)
+		}
+	}
+	return replaced, remaining, nil
+}
+
+func getControlPlaneInstances(cloud fi.Cloud, kopsCluster *kopsapi.Cluster, igs *kopsapi.InstanceGroupList) ([]*cloudinstances.CloudInstance, error) {
+	return getInstances(cloud, kopsCluster, igs, func(ig *kopsapi.InstanceGroup) bool { return ig.IsMaster() })
+}
+
+func getNodeInstances(cloud fi.Cloud, kopsCluster *kopsapi.Cluster, igs *kopsapi.InstanceGroupList) ([]*cloudinstances.CloudInstance, error) {
+	return getInstances(cloud, kopsCluster, igs, func(ig *kopsapi.InstanceGroup) bool { return ig.Spec.Role == kopsapi.InstanceGroupRoleNode })
+}
+
+func getInstances(cloud fi.Cloud, kopsCluster *kopsapi.Cluster, igs *kopsapi.InstanceGroupList, match func(ig *kopsapi.InstanceGroup) bool) ([]*cloudinstances.CloudInstance, error) {
+	var instanceGroups []*kopsapi.InstanceGroup
+	for i := range igs.Items {
+		if match(&igs.Items[i]) {
+			instanceGroups = append(instanceGroups, &igs.Items[i])
+		}
+	}
+
+	groups, err := cloud.GetCloudGroups(kopsCluster, instanceGroups, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []*cloudinstances.CloudInstance
+	for _, g := range groups {
+		instances = append(instances, g.Ready...)
+		instances = append(instances, g.NeedUpdate...)
+	}
+	return instances, nil
+}
+
+// EncryptionConfigSecretName is the name of the kops secret the API servers
+// of a cluster read their encryption config from
+const EncryptionConfigSecretName = "encryptionconfig"
+
+// WriteEncryptionConfig writes the supplied encryption config to the secret
+// store of a kops cluster, returning its hash
+func WriteEncryptionConfig(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, config []byte) (string, error) {
+	store, err := kopsClientset.SecretStore(kopsCluster)
+	if err != nil {
+		return "", err
+	}
+	if _, err := store.ReplaceSecret(EncryptionConfigSecretName, &fi.Secret{Data: config}); err != nil {
+		return "", err
+	}
+	return HashEncryptionConfig(config), nil
+}
+
+// DockerConfigSecretName is the name of the kops secret nodes write to their
+// Docker config when they boot, to pull images from private registries
+const DockerConfigSecretName = "dockerconfig"
+
+// WriteDockerConfig writes the supplied Docker config to the secret store of
+// a kops cluster and returns its hash
+func WriteDockerConfig(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, config []byte) (string, error) {
+	if !json.Valid(config) {
+		return "", errors.New("docker config is not valid JSON")
+	}
+	store, err := kopsClientset.SecretStore(kopsCluster)
+	if err != nil {
+		return "", err
+	}
+	if _, err := store.ReplaceSecret(DockerConfigSecretName, &fi.Secret{Data: config}); err != nil {
+		return "", err
+	}
+	return HashDockerConfig(config), nil
+}
+
+// HashDockerConfig returns the SHA-256 hash of a Docker config
+func HashDockerConfig(config []byte) string {
+	sum := sha256.Sum256(config)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashEncryptionConfig returns the SHA-256 hash of an encryption config
+func HashEncryptionConfig(config []byte) string {
+	sum := sha256.Sum256(config)
+	return hex.EncodeToString(sum[:])
+}
+
+// RecordClusterEvent records an event against the kube-system namespace of a
+// kops cluster
+func RecordClusterEvent(ctx context.Context, kube kubernetes.Interface, reason, message string) error {
+	now := metav1.Now()
+	_, err := kube.CoreV1().Events(metav1.NamespaceSystem).Create(ctx, &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "provider-kops-",
+			Namespace:    metav1.NamespaceSystem,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+			Name:       metav1.NamespaceSystem,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           corev1.EventTypeNormal,
+		Source:         corev1.EventSource{Component: "provider-kops"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}, metav1.CreateOptions{})
+	return err
+}
+
+// EvaluateKopsValidationResult evaluates a kops validation result
+func EvaluateKopsValidationResult(validation *validation.ValidationCluster) (bool, []string) {
+	result := true
+	var errorMessages []string
+
+	failures := validation.Failures
+	if len(failures) > 0 {
+		result = false
+		for _, failure := range failures {
+			errorMessages = append(errorMessages, failure.Message)
+		}
+	}
+
+	nodes := validation.Nodes
+	for _, node := range nodes {
+		if node.Status == corev1.ConditionFalse {
+			result = false
+			errorMessages = append(errorMessages, fmt.Sprintf("node %s condition is %s", node.Hostname, node.Status))
+		}
+	}
+
+	return result, errorMessages
+}
+
+// GenerateValidationObservation converts a kops validation result into its
+// observable form
+func GenerateValidationObservation(validation *validation.ValidationCluster) *v1alpha1.ValidationObservation {
+	obs := &v1alpha1.ValidationObservation{
+		Nodes: len(validation.Nodes),
+	}
+
+	for _, failure := range validation.Failures {
+		f := v1alpha1.ValidationFailure{
+			Kind:    failure.Kind,
+			Name:    failure.Name,
+			Message: failure.Message,
+		}
+		if failure.InstanceGroup != nil {
+			f.InstanceGroup = failure.InstanceGroup.ObjectMeta.Name
+		}
+		obs.Failures = append(obs.Failures, f)
+	}
+
+	for _, node := range validation.Nodes {
+		if node.Status == corev1.ConditionFalse {
+			obs.NotReadyNodes = append(obs.NotReadyNodes, node.Name)
+		}
+	}
+
+	return obs
+}
+
+// GenerateNodeReadiness summarizes the ready nodes reported by a kops
+// validation against the nodes desired by the instance groups of the cluster
+func GenerateNodeReadiness(validation *validation.ValidationCluster, igs *kopsapi.InstanceGroupList) *v1alpha1.NodeReadiness {
+	nr := &v1alpha1.NodeReadiness{}
+
+	for _, ig := range igs.Items {
+		switch ig.Spec.Role {
+		case kopsapi.InstanceGroupRoleMaster, kopsapi.InstanceGroupRoleAPIServer:
+			nr.ControlPlane.Desired += int(fi.Int32Value(ig.Spec.MinSize))
+		case kopsapi.InstanceGroupRoleNode:
+			nr.Workers.Desired += int(fi.Int32Value(ig.Spec.MinSize))
+		}
+	}
+
+	// The validator reports the lower-cased instance group role of a node.
+	for _, node := range validation.Nodes {
+		if node.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch node.Role {
+		case "master", "apiserver":
+			nr.ControlPlane.Ready++
+		case "node":
+			nr.Workers.Ready++
+		}
+	}
+
+	nr.ControlPlane.Summary = fmt.Sprintf("%d/%d", nr.ControlPlane.Ready, nr.ControlPlane.Desired)
+	nr.Workers.Summary = fmt.Sprintf("%d/%d", nr.Workers.Ready, nr.Workers.Desired)
+	return nr
+}
+
+const (
+	// GPUNodeLabel is the label nodeup puts on nodes with Nvidia GPUs
+	GPUNodeLabel = "kops.k8s.io/gpu"
+
+	// GPUResourceName is the extended resource the Nvidia device plugin
+	// advertises the GPUs of a node as
+	GPUResourceName corev1.ResourceName = "nvidia.com/gpu"
+
+	// GPUDevicePluginSelector selects the pods of the Nvidia device plugin
+	// addon kops installs
+	GPUDevicePluginSelector = "name=nvidia-device-plugin-ds"
+)
+
+// VerifyGPUNodes adds a failure to a kops validation for every node with
+// Nvidia GPUs that doesn't advertise them, because the Nvidia device plugin
+// doesn't run on it or the Nvidia driver isn't installed, and returns the
+// readiness of those nodes by instance group
+func VerifyGPUNodes(ctx context.Context, kube kubernetes.Interface, igs *kopsapi.InstanceGroupList, v *validation.ValidationCluster) ([]v1alpha1.GPUPoolObservation, error) {
+	nodes, err := kube.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: GPUNodeLabel})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list GPU nodes")
+	}
+	pods, err := kube.CoreV1().Pods(metav1.NamespaceSystem).List(ctx, metav1.ListOptions{LabelSelector: GPUDevicePluginSelector})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list Nvidia device plugin pods")
+	}
+	plugins := map[string]bool{}
+	for _, pod := range pods.Items {
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+				plugins[pod.Spec.NodeName] = true
+			}
+		}
+	}
+
+	pools := map[string]*v1alpha1.GPUPoolObservation{}
+	for _, node := range nodes.Items {
+		name := node.Labels[kopsapi.NodeLabelInstanceGroup]
+		p, ok := pools[name]
+		if !ok {
+			p = &v1alpha1.GPUPoolObservation{InstanceGroup: name}
+			pools[name] = p
+		}
+		p.Nodes++
+
+		gpus := node.Status.Allocatable[GPUResourceName]
+		var msg string
+		switch {
+		case !plugins[node.Name]:
+			msg = fmt.Sprintf("node %q has Nvidia GPUs but the Nvidia device plugin isn't ready on it", node.Name)
+		case gpus.IsZero():
+			msg = fmt.Sprintf("node %q has Nvidia GPUs but none is allocatable; the Nvidia driver may not be installed", node.Name)
+		default:
+			p.Ready++
+			p.GPUs += gpus.Value()
+			continue
+		}
+		f := &validation.ValidationError{Kind: "Node", Name: node.Name, Message: msg}
+		for i := range igs.Items {
+			if igs.Items[i].Name == name {
+				f.InstanceGroup = &igs.Items[i]
+			}
+		}
+		v.Failures = append(v.Failures, f)
+	}
+
+	obs := make([]v1alpha1.GPUPoolObservation, 0, len(pools))
+	for _, p := range pools {
+		p.Summary = fmt.Sprintf("%d/%d", p.Ready, p.Nodes)
+		obs = append(obs, *p)
+	}
+	sort.Slice(obs, func(i, j int) bool { return obs[i].InstanceGroup < obs[j].InstanceGroup })
+	return obs, nil
+}
+
+// DefaultImageTrackingInterval is how often the source of a tracked image is
+// checked for a newer image if its tracking doesn't say
+const DefaultImageTrackingInterval = 24 * time.Hour
+
+// GetChannelImage returns the image a kops channel lists for a cloud,
+// Kubernetes version and architecture
+func GetChannelImage(channel *kopsapi.Channel, provider kopsapi.CloudProviderID, kubernetesVersion, arch string) (string, error) {
+	v, err := semver.ParseTolerant(kubernetesVersion)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot parse Kubernetes version %q", kubernetesVersion)
+	}
+	if arch == "" {
+		arch = string(architectures.ArchitectureAmd64)
+	}
+	image := channel.FindImage(provider, v, architectures.Architecture(arch))
+	if image == nil {
+		return "", errors.Errorf("channel lists no %s image for %s and Kubernetes %s", arch, provider, v)
+	}
+	return image.Name, nil
+}
+
+// GetSSMParameterImage returns the AMI ID stored in an AWS SSM parameter
+func GetSSMParameterImage(ssmAPI ssmiface.SSMAPI, name string) (string, error) {
+	out, err := ssmAPI.GetParameter(&ssm.GetParameterInput{Name: aws.String(name)})
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot get SSM parameter %q", name)
+	}
+	if out.Parameter == nil || aws.StringValue(out.Parameter.Value) == "" {
+		return "", errors.Errorf("SSM parameter %q is empty", name)
+	}
+	return aws.StringValue(out.Parameter.Value), nil
+}
+
+// cniDaemonSets are the names of the agent DaemonSets kops deploys to
+// kube-system for each networking provider
+var cniDaemonSets = map[string]string{
+	"amazonvpc":  "aws-node",
+	"calico":     "calico-node",
+	"canal":      "canal",
+	"cilium":     "cilium",
+	"flannel":    "kube-flannel-ds",
+	"kopeio":     "kopeio-networking-agent",
+	"kuberouter": "kube-router",
+	"weave":      "weave-net",
+}
+
+// GetNetworkingProvider returns the name of the networking provider of a
+// kops networking spec, as in the kops --networking flag
+func GetNetworkingProvider(n *kopsapi.NetworkingSpec) string {
+	switch {
+	case n == nil:
+		return ""
+	case n.Classic != nil:
+		return "classic"
+	case n.Kubenet != nil:
+		return "kubenet"
+	case n.External != nil:
+		return "external"
+	case n.CNI != nil:
+		return "cni"
+	case n.Kopeio != nil:
+		return "kopeio"
+	case n.Weave != nil:
+		return "weave"
+	case n.Flannel != nil:
+		return "flannel"
+	case n.Calico != nil:
+		return "calico"
+	case n.Canal != nil:
+		return "canal"
+	case n.Kuberouter != nil:
+		return "kuberouter"
+	case n.Romana != nil:
+		return "romana"
+	case n.AmazonVPC != nil:
+		return "amazonvpc"
+	case n.Cilium != nil:
+		return "cilium"
+	case n.LyftVPC != nil:
+		return "lyftvpc"
+	case n.GCE != nil:
+		return "gce"
+	}
+	return ""
+}
+
+// HashNetworking returns the SHA-256 hash of a kops networking spec
+func HashNetworking(n *kopsapi.NetworkingSpec) (string, error) {
+	b, err := json.Marshal(n)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyCNI reports the health of the agent DaemonSet of the networking
+// provider of a kops cluster and adds a failure to a kops validation result
+// while it isn't healthy. It returns nil if the provider runs no agent kops
+// knows of
+func VerifyCNI(ctx context.Context, kube kubernetes.Interface, n *kopsapi.NetworkingSpec, v *validation.ValidationCluster) (*v1alpha1.CNIHealth, error) {
+	name, ok := cniDaemonSets[GetNetworkingProvider(n)]
+	if !ok {
+		return nil, nil
+	}
+	ds, err := kube.AppsV1().DaemonSets(metav1.NamespaceSystem).Get(ctx, name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get CNI DaemonSet %q", name)
+	}
+
+	h := cniHealth(ds)
+	if !h.Healthy {
+		v.Failures = append(v.Failures, &validation.ValidationError{
+			Kind:    "DaemonSet",
+			Name:    metav1.NamespaceSystem + "/" + name,
+			Message: fmt.Sprintf("CNI DaemonSet %q has %d/%d ready and %d/%d updated agents", name, h.Ready, h.Desired, h.Updated, h.Desired),
+		})
+	}
+	return h, nil
+}
+
+func cniHealth(ds *appsv1.DaemonSet) *v1alpha1.CNIHealth {
+	s := ds.Status
+	// A DaemonSet whose controller hasn't seen its latest spec yet may
+	// report counts of the previous one.
+	current := s.ObservedGeneration >= ds.Generation
+	return &v1alpha1.CNIHealth{
+		DaemonSet: ds.Name,
+		Desired:   s.DesiredNumberScheduled,
+		Ready:     s.NumberReady,
+		Updated:   s.UpdatedNumberScheduled,
+		Healthy:   current && s.NumberReady == s.DesiredNumberScheduled && s.UpdatedNumberScheduled == s.DesiredNumberScheduled,
+	}
+}
+
+// RunHealthProbes runs the supplied health probes against a cluster, adding
+// a validation failure for each probe that fails
+func RunHealthProbes(ctx context.Context, kube kubernetes.Interface, client *http.Client, probes []v1alpha1.HealthProbe, v *validation.ValidationCluster) ([]v1alpha1.HealthProbeResult, error) {
+	if len(probes) == 0 {
+		return nil, nil
+	}
+	res := make([]v1alpha1.HealthProbeResult, 0, len(probes))
+	for _, p := range probes {
+		var msg string
+		switch {
+		case p.Deployment != nil:
+			ns := p.Deployment.Namespace
+			if ns == "" {
+				ns = metav1.NamespaceSystem
+			}
+			d, err := kube.AppsV1().Deployments(ns).Get(ctx, p.Deployment.Name, metav1.GetOptions{})
+			switch {
+			case kerrors.IsNotFound(err):
+				msg = fmt.Sprintf("Deployment %s/%s doesn't exist", ns, p.Deployment.Name)
+			case err != nil:
+				return nil, errors.Wrapf(err, "cannot get Deployment %s/%s of health probe %q", ns, p.Deployment.Name, p.Name)
+			default:
+				msg = deploymentUnavailable(d)
+			}
+		case p.HTTPGet != nil:
+			msg = httpGetFailure(ctx, client, p.HTTPGet.URL)
+		}
+		res = append(res, v1alpha1.HealthProbeResult{Name: p.Name, Healthy: msg == "", Message: msg})
+		if msg != "" {
+			v.Failures = append(v.Failures, &validation.ValidationError{
+				Kind:    "HealthProbe",
+				Name:    p.Name,
+				Message: fmt.Sprintf("health probe %q failed: %s", p.Name, msg),
+			})
+		}
+	}
+	return res, nil
+}
+
+// deploymentUnavailable returns why a Deployment isn't available, or an
+// empty string if it is
+func deploymentUnavailable(d *appsv1.Deployment) string {
+	want := int32(1)
+	if d.Spec.Replicas != nil {
+		want = *d.Spec.Replicas
+	}
+	s := d.Status
+	if s.ObservedGeneration < d.Generation || s.UpdatedReplicas < want || s.AvailableReplicas < want {
+		return fmt.Sprintf("Deployment %s/%s has %d/%d available and %d/%d updated replicas", d.Namespace, d.Name, s.AvailableReplicas, want, s.UpdatedReplicas, want)
+	}
+	return ""
+}
+
+// httpGetFailure returns why getting a URL didn't return 200, or an empty
+// string if it did
+func httpGetFailure(ctx context.Context, client *http.Client, u string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err.Error()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err.Error()
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Sprintf("GET %s returned %s", u, resp.Status)
+	}
+	return ""
+}
+
+// ValidateHealthProbes validates the health probes of a Kops resource
+func ValidateHealthProbes(probes []v1alpha1.HealthProbe, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	names := map[string]bool{}
+	for i, pr := range probes {
+		p := fldPath.Index(i)
+		if names[pr.Name] {
+			errs = append(errs, field.Duplicate(p.Child("name"), pr.Name))
+		}
+		names[pr.Name] = true
+		if (pr.Deployment == nil) == (pr.HTTPGet == nil) {
+			errs = append(errs, field.Invalid(p, pr.Name, "exactly one of deployment and httpGet must be set"))
+		}
+		if pr.HTTPGet != nil {
+			if u, err := url.Parse(pr.HTTPGet.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, field.Invalid(p.Child("httpGet", "url"), pr.HTTPGet.URL, "must be an http or https URL"))
+			}
+		}
+	}
+	return errs
+}
+
+// GenerateInfrastructureObservation extracts the identifiers of the cloud
+// infrastructure of a cluster from its listed resources
+func GenerateInfrastructureObservation(res map[string]*resources.Resource) *v1alpha1.InfrastructureObservation {
+	obs := &v1alpha1.InfrastructureObservation{}
+
+	for _, r := range res {
+		switch r.Type {
+		case ec2.ResourceTypeVpc:
+			obs.VPCID = r.ID
+		case ec2.ResourceTypeSubnet:
+			obs.SubnetIDs = append(obs.SubnetIDs, r.ID)
+		case ec2.ResourceTypeSecurityGroup:
+			obs.SecurityGroupIDs = append(obs.SecurityGroupIDs, r.ID)
+		case awsresources.TypeLoadBalancer:
+			// kops names the API load balancer api.<cluster> when it is a
+			// classic ELB and api-<cluster> when it is an NLB, and names the
+			// bastion's alike.
+			if strings.HasPrefix(r.Name, "bastion.") || strings.HasPrefix(r.Name, "bastion-") {
+				if lb, ok := r.Obj.(*elb.LoadBalancerDescription); ok {
+					obs.BastionLoadBalancerDNSName = aws.StringValue(lb.DNSName)
+				}
+				continue
+			}
+			if !strings.HasPrefix(r.Name, "api.") && !strings.HasPrefix(r.Name, "api-") {
+				continue
+			}
+			switch lb := r.Obj.(type) {
+			case *elb.LoadBalancerDescription:
+				obs.APILoadBalancerDNSName = aws.StringValue(lb.DNSName)
+				obs.APILoadBalancerHostedZoneID = aws.StringValue(lb.CanonicalHostedZoneNameID)
+			case *elbv2.LoadBalancer:
+				obs.APILoadBalancerDNSName = aws.StringValue(lb.DNSName)
+				obs.APILoadBalancerARN = aws.StringValue(lb.LoadBalancerArn)
+				obs.APILoadBalancerHostedZoneID = aws.StringValue(lb.CanonicalHostedZoneId)
+			}
+		case "route53-record":
+			// Records are grouped by the ID of the hosted zone they are in.
+			obs.DNSZoneID = r.GroupKey
+			if rrs, ok := r.Obj.(*route53.ResourceRecordSet); ok {
+				obs.DNSRecords = append(obs.DNSRecords, v1alpha1.DNSRecordObservation{
+					Name: strings.TrimSuffix(aws.StringValue(rrs.Name), "."),
+					Type: aws.StringValue(rrs.Type),
+				})
+			}
+		case "iam-instance-profile":
+			if p, ok := r.Obj.(*iam.InstanceProfile); ok {
+				obs.InstanceProfileARNs = append(obs.InstanceProfileARNs, aws.StringValue(p.Arn))
+			}
+		}
+	}
+
+	// Resources are listed as a map, so sort to keep the status stable.
+	sort.Strings(obs.SubnetIDs)
+	sort.Strings(obs.SecurityGroupIDs)
+	sort.Strings(obs.InstanceProfileARNs)
+	sort.Slice(obs.DNSRecords, func(i, j int) bool {
+		if obs.DNSRecords[i].Name != obs.DNSRecords[j].Name {
+			return obs.DNSRecords[i].Name < obs.DNSRecords[j].Name
+		}
+		return obs.DNSRecords[i].Type < obs.DNSRecords[j].Type
+	})
+	return obs
+}
+
+// RenderClusterYAML renders a kops cluster and its instance groups as a
+// multi-document YAML stream, like kops get -o yaml does
+func RenderClusterYAML(kopsCluster *kopsapi.Cluster, igs []*kopsapi.InstanceGroup) ([]byte, error) {
+	objs := []runtime.Object{kopsCluster}
+	for _, ig := range igs {
+		objs = append(objs, ig)
+	}
+
+	docs := make([][]byte, 0, len(objs))
+	for _, obj := range objs {
+		b, err := kopscodecs.ToVersionedYaml(obj)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, b)
+	}
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// AddonsChannelPath is the path, relative to the state of a kops cluster, of
+// the addon channel listing the addons declared by a Kops resource
+const AddonsChannelPath = "addons/provider-kops/addons.yaml"
+
+// RenderAddons returns the addon channel listing the supplied addons, and the
+// manifests it refers to by their path relative to the channel. Each addon is
+// listed with the hash of its manifest, so that channels applies it again
+// whenever the manifest changes
+func RenderAddons(addons []v1alpha1.AddonParameters) ([]byte, map[string][]byte, error) {
+	channel := &channelsapi.Addons{
+		TypeMeta:   metav1.TypeMeta{Kind: "Addons"},
+		ObjectMeta: metav1.ObjectMeta{Name: "provider-kops"},
+	}
+	manifests := make(map[string][]byte, len(addons))
+	for _, a := range addons {
+		name := a.Name
+		if _, ok := manifests[name+".yaml"]; ok {
+			return nil, nil, errors.Errorf("addon %q is declared more than once", name)
+		}
+		manifest := name + ".yaml"
+		manifests[manifest] = []byte(a.Manifest)
+
+		selector := a.Selector
+		if len(selector) == 0 {
+			selector = map[string]string{"k8s-addon": name}
+		}
+		sum := sha256.Sum256([]byte(a.Manifest))
+		channel.Spec.Addons = append(channel.Spec.Addons, &channelsapi.AddonSpec{
+			Name:         &name,
+			Namespace:    a.Namespace,
+			Selector:     selector,
+			Manifest:     &manifest,
+			ManifestHash: hex.EncodeToString(sum[:]),
+		})
+	}
+	b, err := yaml.Marshal(channel)
+	return b, manifests, err
+}
+
+// AddonsUpToDate returns true if the addon channel in the state of a kops
+// cluster lists exactly the supplied addons
+func AddonsUpToDate(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, addons []v1alpha1.AddonParameters) (bool, error) {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return false, err
+	}
+	current, err := configBase.Join(AddonsChannelPath).ReadFile()
+	if os.IsNotExist(err) {
+		return len(addons) == 0, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(addons) == 0 {
+		return false, nil
+	}
+	channel, _, err := RenderAddons(addons)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(current, channel), nil
+}
+
+// WriteAddons writes the addon channel listing the supplied addons, and their
+// manifests, to the state of a kops cluster. Manifests of addons that are no
+// longer declared are removed, as is the channel if no addons are declared
+func WriteAddons(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, addons []v1alpha1.AddonParameters) error {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return err
+	}
+	channelPath := configBase.Join(AddonsChannelPath)
+	dir := configBase.Join(filepath.Dir(AddonsChannelPath))
+
+	var manifests map[string][]byte
+	if len(addons) > 0 {
+		var channel []byte
+		channel, manifests, err = RenderAddons(addons)
+		if err != nil {
+			return err
+		}
+		for name, m := range manifests {
+			if err := dir.Join(name).WriteFile(bytes.NewReader(m), nil); err != nil {
+				return err
+			}
+		}
+		// The channel is written last so that it never refers to a manifest
+		// that wasn't written yet.
+		if err := channelPath.WriteFile(bytes.NewReader(channel), nil); err != nil {
+			return err
+		}
+	}
+
+	existing, err := dir.ReadDir()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, p := range existing {
+		name := p.Base()
+		if name == channelPath.Base() && len(addons) > 0 {
+			continue
+		}
+		if _, ok := manifests[name]; ok {
+			continue
+		}
+		if err := p.Remove(); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTerraformOutputPath returns the path Terraform rendered for a kops
+// cluster is written to, defaulting to terraform/ in its state store
+func GetTerraformOutputPath(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, outputPath string) (vfs.Path, error) {
+	if outputPath != "" {
+		return vfs.Context.BuildVfsPath(outputPath)
+	}
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return nil, err
+	}
+	return configBase.Join("terraform"), nil
+}
+
+// CopyDirToVFS copies the files of a local directory to a VFS path
+func CopyDirToVFS(dir string, dest vfs.Path) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		return dest.Join(filepath.ToSlash(rel)).WriteFile(bytes.NewReader(b), nil)
+	})
+}
+
+// StateBackupDir is the directory of a state store that backups of the state
+// of its kops clusters are written to. It sits beside rather than below the
+// cluster prefixes so that backups survive deleting a cluster
+const StateBackupDir = "provider-kops-backups"
+
+// providerStateDirs are the directories below the state of a kops cluster
+// that are written by the provider rather than by kops
+var providerStateDirs = []string{"dumps", "terraform", "provider-kops"}
+
+// ApplyMarkerPath is the path below the state of a kops cluster that the
+// provider writes to before it first applies the cluster to the cloud
+const ApplyMarkerPath = "provider-kops/applied"
+
+// ApplyInProgressPath is the path below the state of a kops cluster that the
+// provider records an apply at until the apply completes
+const ApplyInProgressPath = "provider-kops/apply-in-progress"
+
+// OwnerMarkerPath is the path below the state of a kops cluster that the
+// provider records the Kops resource managing the cluster at
+const OwnerMarkerPath = "provider-kops/owner"
+
+// A ClusterOwner is the Kops resource that manages a kops cluster
+type ClusterOwner struct {
+	Name string `json:"name"`
+	UID  string `json:"uid"`
+}
+
+// GetStateBackupPath returns the path a backup of the state of a kops cluster
+// taken at the supplied time is written to
+func GetStateBackupPath(stateBucket, clusterName string, t time.Time) (vfs.Path, error) {
+	p, err := vfs.Context.BuildVfsPath(stateBucket)
+	if err != nil {
+		return nil, err
+	}
+	return p.Join(StateBackupDir, clusterName, t.UTC().Format("20060102T150405Z")), nil
+}
+
+// CopyVFSTree copies the files below a VFS path to another VFS path
+func CopyVFSTree(src, dest vfs.Path) error {
+	paths, err := src.ReadTree()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		rel, err := vfs.RelativePath(src, p)
+		if err != nil {
+			return err
+		}
+		b, err := p.ReadFile()
+		if err != nil {
+			return err
+		}
+		if err := dest.Join(rel).WriteFile(bytes.NewReader(b), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackupClusterState copies the state of a kops cluster to a backup path and
+// returns the path it was copied to
+func BackupClusterState(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, stateBucket string, t time.Time) (string, error) {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return "", err
+	}
+	dest, err := GetStateBackupPath(stateBucket, kopsCluster.GetName(), t)
+	if err != nil {
+		return "", err
+	}
+	if err := CopyVFSTree(configBase, dest); err != nil {
+		return "", err
+	}
+	return dest.Path(), nil
+}
+
+// GetStateReplicaPath returns the path the state of a kops cluster is
+// replicated to below a replica state bucket. It mirrors the layout of a state
+// store, so that kops can be pointed at the replica bucket to rebuild the
+// cluster's management plane
+func GetStateReplicaPath(replicaBucket, clusterName string) (vfs.Path, error) {
+	p, err := vfs.Context.BuildVfsPath(replicaBucket)
+	if err != nil {
+		return nil, err
+	}
+	return p.Join(clusterName), nil
+}
+
+// ReplicateClusterState copies the state of a kops cluster to a replica state
+// bucket, removing files from the replica that are no longer in the state, and
+// returns the number of files replicated
+func ReplicateClusterState(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, replicaBucket string) (int, error) {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return 0, err
+	}
+	dest, err := GetStateReplicaPath(replicaBucket, kopsCluster.GetName())
+	if err != nil {
+		return 0, err
+	}
+	src, err := configBase.ReadTree()
+	if err != nil {
+		return 0, err
+	}
+	files := make(map[string]bool, len(src))
+	for _, p := range src {
+		rel, err := vfs.RelativePath(configBase, p)
+		if err != nil {
+			return 0, err
+		}
+		files[rel] = true
+	}
+	if err := CopyVFSTree(configBase, dest); err != nil {
+		return 0, err
+	}
+	replicated, err := dest.ReadTree()
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	for _, p := range replicated {
+		rel, err := vfs.RelativePath(dest, p)
+		if err != nil {
+			return 0, err
+		}
+		if files[rel] {
+			continue
+		}
+		if err := p.Remove(); err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
+
+// RemoveProviderState removes the files the provider wrote below the state of
+// a kops cluster, which kops otherwise refuses to delete the state alongside
+func RemoveProviderState(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster) error {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return err
+	}
+	for _, dir := range providerStateDirs {
+		paths, err := configBase.Join(dir).ReadTree()
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, p := range paths {
+			if err := p.Remove(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// A DiagnosticBundle is the diagnostic information collected for a kops
+// cluster, equivalent to the output of kops toolbox dump
+type DiagnosticBundle struct {
+	Cluster    string                          `json:"cluster"`
+	Time       metav1.Time                     `json:"time"`
+	Dump       *resources.Dump                 `json:"dump,omitempty"`
+	Validation *v1alpha1.ValidationObservation `json:"validation,omitempty"`
+}
+
+// WriteDiagnosticBundle writes a diagnostic bundle to the dumps directory of
+// the state store of a kops cluster and returns the path it was written to
+func WriteDiagnosticBundle(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, bundle *DiagnosticBundle) (string, error) {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	p := configBase.Join("dumps", bundle.Time.UTC().Format("20060102T150405Z")+".json")
+	if err := p.WriteFile(bytes.NewReader(b), nil); err != nil {
+		return "", err
+	}
+	return p.Path(), nil
+}
+
+// AWSClusterActions are the AWS actions needed to create a kops cluster
+var AWSClusterActions = []string{
+	"autoscaling:CreateAutoScalingGroup",
+	"autoscaling:UpdateAutoScalingGroup",
+	"ec2:AuthorizeSecurityGroupIngress",
+	"ec2:CreateLaunchTemplate",
+	"ec2:CreateSecurityGroup",
+	"ec2:CreateSubnet",
+	"ec2:CreateTags",
+	"ec2:CreateVpc",
+	"ec2:RunInstances",
+	"elasticloadbalancing:CreateLoadBalancer",
+	"iam:AddRoleToInstanceProfile",
+	"iam:CreateInstanceProfile",
+	"iam:CreateRole",
+	"iam:PassRole",
+	"iam:PutRolePolicy",
+	"route53:ChangeResourceRecordSets",
+	"route53:ListHostedZones",
+}
+
+// GetMissingAWSPermissions returns the supplied actions the caller of the
+// supplied clients isn't allowed to perform, as simulated by IAM
+func GetMissingAWSPermissions(stsAPI stsiface.STSAPI, iamAPI iamiface.IAMAPI, actions []string) ([]string, error) {
+	id, err := stsAPI.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	err = iamAPI.SimulatePrincipalPolicyPages(&iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principalARN(aws.StringValue(id.Arn))),
+		ActionNames:     aws.StringSlice(actions),
+		ResourceArns:    aws.StringSlice([]string{"*"}),
+	}, func(out *iam.SimulatePolicyResponse, _ bool) bool {
+		for _, r := range out.EvaluationResults {
+			if aws.StringValue(r.EvalDecision) != iam.PolicyEvaluationDecisionTypeAllowed {
+				missing = append(missing, aws.StringValue(r.EvalActionName))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// principalARN returns the ARN of the IAM principal a caller ARN belongs to.
+// The caller ARN of an assumed role is that of its session, e.g.
+// arn:aws:sts::123456789012:assumed-role/example/session, which IAM can't
+// simulate.
+func principalARN(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" {
+		return arn
+	}
+	res := strings.Split(parts[5], "/")
+	if len(res) < 2 || res[0] != "assumed-role" {
+		return arn
+	}
+	return fmt.Sprintf("%s:%s:iam::%s:role/%s", parts[0], parts[1], parts[4], res[1])
+}
+
+// CheckStateStoreWritable verifies that a file can be written to and removed
+// from the state of a kops cluster
+func CheckStateStoreWritable(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster) error {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return err
+	}
+	p := configBase.Join(".provider-kops-preflight")
+	if err := p.WriteFile(bytes.NewReader([]byte{}), nil); err != nil {
+		return err
+	}
+	return p.Remove()
+}
+
+// CheckClusterDNS verifies that the hosted zone of a kops cluster exists and
+// that its name servers resolve. Gossip clusters and clusters using a private
+// hosted zone, which can't be resolved from outside the cluster's network,
+// are not checked
+func CheckClusterDNS(kopsCluster *kopsapi.Cluster, domain string, cloud fi.Cloud, lookupNS func(name string) ([]*net.NS, error)) error {
+	if kopsdns.IsGossipHostname(kopsCluster.Name) {
+		return nil
+	}
+	if t := kopsCluster.Spec.Topology; t != nil && t.DNS != nil && t.DNS.Type == kopsapi.DNSTypePrivate {
+		return nil
+	}
+
+	zone := kopsCluster.Spec.DNSZone
+	if zone == "" {
+		zone = domain
+	}
+
+	dns, err := cloud.DNS()
+	if err != nil {
+		return errors.Wrap(err, "cannot build DNS provider")
+	}
+	zones, ok := dns.Zones()
+	if !ok {
+		return errors.New("DNS provider does not support listing zones")
+	}
+	list, err := zones.List()
+	if err != nil {
+		return errors.Wrap(err, "cannot list DNS zones")
+	}
+	name := ""
+	for _, z := range list {
+		if z.ID() == zone || strings.TrimSuffix(z.Name(), ".") == strings.TrimSuffix(zone, ".") {
+			name = strings.TrimSuffix(z.Name(), ".")
+			break
+		}
+	}
+	if name == "" {
+		return errors.Errorf("cannot find DNS zone %q", zone)
+	}
+
+	ns, err := lookupNS(name)
+	if err != nil {
+		return errors.Wrapf(err, "cannot resolve name servers of DNS zone %q", name)
+	}
+	if len(ns) == 0 {
+		return errors.Errorf("DNS zone %q has no name servers", name)
+	}
+	return nil
+}
+
+// MarkApplyStarted records in the state store of a kops cluster that the
+// provider is about to apply the cluster to the cloud, at the supplied time
+func MarkApplyStarted(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, t time.Time) error {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return err
+	}
+	return configBase.Join(ApplyMarkerPath).WriteFile(strings.NewReader(t.UTC().Format(time.RFC3339)), nil)
+}
+
+// MarkApplyInProgress records the supplied apply in the state store of a kops
+// cluster, so that it can be resumed if it doesn't complete
+func MarkApplyInProgress(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, record v1alpha1.ApplyRecord) error {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return configBase.Join(ApplyInProgressPath).WriteFile(bytes.NewReader(b), nil)
+}
+
+// GetApplyInProgress returns the apply recorded in the state store of a kops
+// cluster that has not completed, or nil if there is none
+func GetApplyInProgress(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster) (*v1alpha1.ApplyRecord, error) {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return nil, err
+	}
+	b, err := configBase.Join(ApplyInProgressPath).ReadFile()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := &v1alpha1.ApplyRecord{}
+	if err := json.Unmarshal(b, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ClearApplyInProgress removes the apply recorded in the state store of a
+// kops cluster once it has completed
+func ClearApplyInProgress(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster) error {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return err
+	}
+	err = configBase.Join(ApplyInProgressPath).Remove()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// GetClusterOwner returns the Kops resource recorded in the state store of a
+// kops cluster as managing it, or nil if none is
+func GetClusterOwner(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster) (*ClusterOwner, error) {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return nil, err
+	}
+	b, err := configBase.Join(OwnerMarkerPath).ReadFile()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	owner := &ClusterOwner{}
+	if err := json.Unmarshal(b, owner); err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+// SetClusterOwner records the supplied Kops resource in the state store of a
+// kops cluster as managing it
+func SetClusterOwner(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster, owner ClusterOwner) error {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(owner)
+	if err != nil {
+		return err
+	}
+	return configBase.Join(OwnerMarkerPath).WriteFile(bytes.NewReader(b), nil)
+}
+
+// ClusterEverApplied returns false if a kops cluster was never applied to the
+// cloud, neither by the provider nor by kops itself, in which case it can
+// have no cloud resources
+func ClusterEverApplied(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster) (bool, error) {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range []string{ApplyMarkerPath, registry.PathKopsVersionUpdated} {
+		_, err := configBase.Join(p).ReadFile()
+		if err == nil {
+			return true, nil
+		}
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// GetStateKopsVersion returns the version of kops that last applied a kops
+// cluster, as recorded in its state store, or an empty string if none is
+func GetStateKopsVersion(kopsClientset kopsClient.Clientset, kopsCluster *kopsapi.Cluster) (string, error) {
+	configBase, err := kopsClientset.ConfigBaseFor(kopsCluster)
+	if err != nil {
+		return "", err
+	}
+	b, err := configBase.Join(registry.PathKopsVersionUpdated).ReadFile()
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// KopsVersionSkewed returns true if the supplied state version is newer than
+// the supplied library version. kops refuses to apply state written by a
+// newer version of itself, as it would drop anything it doesn't understand
+func KopsVersionSkewed(stateVersion, libraryVersion string) (bool, error) {
+	if stateVersion == "" {
+		return false, nil
+	}
+	state, err := semver.ParseTolerant(stateVersion)
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot parse kops version %q", stateVersion)
+	}
+	lib, err := semver.ParseTolerant(libraryVersion)
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot parse kops version %q", libraryVersion)
+	}
+	return state.GT(lib), nil
+}
+
+// GetUpgradeRecommendation evaluates a kops channel against the Kubernetes
+// version of a cluster and a kops version the way kops upgrade cluster does,
+// and returns the versions it recommends upgrading to, if any, and how
+// urgently
+func GetUpgradeRecommendation(channel *kopsapi.Channel, kubernetesVersion, kopsVersion string) (*v1alpha1.UpgradeObservation, error) {
+	obs := &v1alpha1.UpgradeObservation{Urgency: v1alpha1.UpgradeUrgencyNone}
+	recommend := func(required bool) {
+		switch {
+		case required:
+			obs.Urgency = v1alpha1.UpgradeUrgencyRequired
+		case obs.Urgency == v1alpha1.UpgradeUrgencyNone:
+			obs.Urgency = v1alpha1.UpgradeUrgencyRecommended
+		}
+	}
+
+	to, required, err := GetKubernetesUpgrade(channel, kubernetesVersion)
+	if err != nil {
+		return nil, err
+	}
+	if to != "" {
+		obs.KubernetesVersion = to
+		recommend(required)
+	}
+
+	kops, err := semver.ParseTolerant(kopsVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse kops version %q", kopsVersion)
+	}
+	if spec := kopsapi.FindKopsVersionSpec(channel.Spec.KopsVersions, kops); spec != nil {
+		to, err := spec.FindRecommendedUpgrade(kops)
+		if err != nil {
+			return nil, err
+		}
+		required, err := spec.IsUpgradeRequired(kops)
+		if err != nil {
+			return nil, err
+		}
+		if to != nil {
+			obs.KopsVersion = to.String()
+			recommend(required)
+		}
+	}
+	return obs, nil
+}
+
+// GetKubernetesUpgrade returns the Kubernetes version a kops channel
+// recommends upgrading a Kubernetes version to, or an empty string if it
+// recommends none, and whether the upgrade is required
+func GetKubernetesUpgrade(channel *kopsapi.Channel, kubernetesVersion string) (string, bool, error) {
+	k8s, err := semver.ParseTolerant(kubernetesVersion)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "cannot parse Kubernetes version %q", kubernetesVersion)
+	}
+	spec := kopsapi.FindKubernetesVersionSpec(channel.Spec.KubernetesVersions, k8s)
+	if spec == nil {
+		return "", false, nil
+	}
+	to, err := spec.FindRecommendedUpgrade(k8s)
+	if err != nil || to == nil {
+		return "", false, err
+	}
+	required, err := spec.IsUpgradeRequired(k8s)
+	if err != nil {
+		return "", false, err
+	}
+	return to.String(), required, nil
+}
+
+// removedAdmissionPlugins are the admission plugins the API server no longer
+// has, by the Kubernetes version they were removed in
+var removedAdmissionPlugins = map[string]semver.Version{
+	"Initializers":      semver.MustParse("1.14.0"),
+	"PodPreset":         semver.MustParse("1.20.0"),
+	"PodSecurityPolicy": semver.MustParse("1.25.0"),
+}
+
+// dockershimRemoved is the Kubernetes version the kubelet stopped supporting
+// Docker, and the API server its insecure port, in
+var dockershimRemoved = semver.MustParse("1.24.0")
+
+// GetDeprecatedFields returns the fields of a cluster spec that kops
+// deprecated or no longer supports, or that the cluster's Kubernetes version
+// removed, each with the reason it should no longer be used. Fields removed by
+// a Kubernetes version are only returned if the cluster's version can be
+// parsed
+func GetDeprecatedFields(spec *kopsapi.ClusterSpec) []string {
+	fields := []string{}
+	add := func(path, reason string) {
+		fields = append(fields, fmt.Sprintf("clusterSpec.%s: %s", path, reason))
+	}
+
+	if n := spec.Networking; n != nil {
+		if n.Classic != nil {
+			add("networking.classic", "classic networking was removed in Kubernetes 1.4")
+		}
+		if n.Romana != nil {
+			add("networking.romana", "Romana networking was removed in kOps 1.19")
+		}
+		if n.LyftVPC != nil {
+			add("networking.lyftvpc", "Lyft VPC networking was removed in kOps 1.23")
+		}
+		if n.Calico != nil && n.Calico.CrossSubnet != nil {
+			add("networking.calico.crossSubnet", "deprecated in kOps 1.22 and has no effect")
+		}
+	}
+	if a := spec.KubeAPIServer; a != nil {
+		if len(a.AdmissionControl) > 0 {
+			add("kubeAPIServer.admissionControl", "deprecated, use kubeAPIServer.enableAdmissionPlugins")
+		}
+		if a.Address != "" {
+			add("kubeAPIServer.address", "deprecated, use kubeAPIServer.bindAddress")
+		}
+	}
+
+	v, err := semver.ParseTolerant(spec.KubernetesVersion)
+	if err != nil {
+		return fields
+	}
+	// Pre-releases of a version have already removed what it removes.
+	v.Pre = nil
+	if a := spec.KubeAPIServer; a != nil {
+		for _, list := range []struct {
+			path    string
+			plugins []string
+		}{
+			{path: "kubeAPIServer.enableAdmissionPlugins", plugins: a.EnableAdmissionPlugins},
+			{path: "kubeAPIServer.admissionControl", plugins: a.AdmissionControl},
+		} {
+			for _, p := range list.plugins {
+				if removed, ok := removedAdmissionPlugins[p]; ok && v.GTE(removed) {
+					add(list.path, fmt.Sprintf("the %s admission plugin was removed in Kubernetes %d.%d", p, removed.Major, removed.Minor))
+				}
+			}
+		}
+		if v.GTE(dockershimRemoved) && a.InsecurePort != 0 {
+			add("kubeAPIServer.insecurePort", "the insecure port was removed in Kubernetes 1.24")
+		}
+		if v.GTE(dockershimRemoved) && a.InsecureBindAddress != "" {
+			add("kubeAPIServer.insecureBindAddress", "the insecure port was removed in Kubernetes 1.24")
+		}
+	}
+	if v.GTE(dockershimRemoved) {
+		if spec.ContainerRuntime == "docker" {
+			add("containerRuntime", "Docker is no longer supported as of Kubernetes 1.24, use containerd")
+		}
+		if spec.Kubelet != nil && spec.Kubelet.NetworkPluginName != "" {
+			add("kubelet.networkPluginName", "the kubelet's network plugin flag was removed in Kubernetes 1.24")
+		}
+	}
+	return fields
+}
+
+// GetAPICertificate returns the certificate served by the API load balancer of
+// a kops cluster, if any
+func GetAPICertificate(kopsCluster *kopsapi.Cluster) string {
+	if api := kopsCluster.Spec.API; api != nil && api.LoadBalancer != nil {
+		return api.LoadBalancer.SSLCertificate
+	}
+	return ""
+}
+
+// CheckACMCertificateIssued returns an error unless the supplied ACM
+// certificate has been issued
+func CheckACMCertificateIssued(acmAPI acmiface.ACMAPI, arn string) error {
+	out, err := acmAPI.DescribeCertificate(&acm.DescribeCertificateInput{CertificateArn: aws.String(arn)})
+	if err != nil {
+		return err
+	}
+	if status := aws.StringValue(out.Certificate.Status); status != acm.CertificateStatusIssued {
+		return certificateStatusError{arn: arn, status: status}
+	}
+	return nil
+}
+
+type certificateStatusError struct {
+	arn    string
+	status string
+}
+
+func (e certificateStatusError) Error() string {
+	return fmt.Sprintf("certificate %s is %s, not %s", e.arn, e.status, acm.CertificateStatusIssued)
+}
+
+// IsCertificatePending returns true if the supplied error is that of an ACM
+// certificate that is pending validation, which it is expected to pass once
+// its validation records resolve
+func IsCertificatePending(err error) bool {
+	var s certificateStatusError
+	return errors.As(err, &s) && s.status == acm.CertificateStatusPendingValidation
+}
+
+// AWSRateLimiterHandlerName is the name of the request handler that rate
+// limits the AWS API clients of a kops cloud
+const AWSRateLimiterHandlerName = "provider-kops.RateLimiter"
+
+// RateLimitAWSCloud makes every request of the AWS API clients of the
+// supplied cloud wait for the supplied limiter. kops shares the clients of a
+// region between all of its clouds, so the limiter replaces any set before
+// rather than being added again
+func RateLimitAWSCloud(cloud awsup.AWSCloud, limiter *rate.Limiter) {
+	for _, handlers := range awsHandlers(cloud) {
+		rateLimitAWSHandlers(handlers, limiter)
+	}
+}
+
+func rateLimitAWSHandlers(handlers *request.Handlers, limiter *rate.Limiter) {
+	h := request.NamedHandler{Name: AWSRateLimiterHandlerName, Fn: func(r *request.Request) {
+		if err := limiter.Wait(r.Context()); err != nil {
+			r.Error = err
+		}
+	}}
+	if !handlers.Send.SwapNamed(h) {
+		handlers.Send.PushFrontNamed(h)
+	}
+}
+
+// NewAWSSession returns a session for calling the AWS APIs kops has no client
+// for. It is configured like the EC2 client of the supplied cloud, so that it
+// uses the cloud's region and credentials rather than those of the
+// provider's environment. Its requests wait for the supplied limiter, if any,
+// like those of the cloud's clients
+func NewAWSSession(cloud awsup.AWSCloud, limiter *rate.Limiter) (*session.Session, error) {
+	c, ok := cloud.EC2().(*ec2.EC2)
+	if !ok {
+		return nil, errors.New("cloud has no AWS API client configuration")
+	}
+	sess, err := session.NewSession(c.Config.Copy())
+	if err != nil {
+		return nil, err
+	}
+	if limiter != nil {
+		rateLimitAWSHandlers(&sess.Handlers, limiter)
+	}
+	return sess, nil
+}
+
+// awsHandlers returns the request handlers of the AWS API clients of the
+// supplied cloud that kops calls while applying and deleting clusters
+func awsHandlers(cloud awsup.AWSCloud) []*request.Handlers {
+	var hs []*request.Handlers
+	if c, ok := cloud.EC2().(*ec2.EC2); ok {
+		hs = append(hs, &c.Handlers)
+	}
+	if c, ok := cloud.Autoscaling().(*autoscaling.AutoScaling); ok {
+		hs = append(hs, &c.Handlers)
+	}
+	if c, ok := cloud.IAM().(*iam.IAM); ok {
+		hs = append(hs, &c.Handlers)
+	}
+	if c, ok := cloud.ELB().(*elb.ELB); ok {
+		hs = append(hs, &c.Handlers)
+	}
+	if c, ok := cloud.ELBV2().(*elbv2.ELBV2); ok {
+		hs = append(hs, &c.Handlers)
+	}
+	if c, ok := cloud.Route53().(*route53.Route53); ok {
+		hs = append(hs, &c.Handlers)
+	}
+	return hs
+}
+
+// GetUnavailableAssets returns the file and image assets of a kops cluster
+// that were remapped to a mirror but can't be found there. Images are looked
+// up through the registry API; a registry that requires authentication is
+// reachable but can't be inspected, so its images are assumed to be present
+func GetUnavailableAssets(ctx context.Context, client *http.Client, files []*assets.FileAsset, images []*assets.ImageAsset) []string {
+	seen := map[string]bool{}
+	var missing []string
+
+	for _, f := range files {
+		u := f.DownloadURL.String()
+		if seen[u] || u == f.CanonicalURL.String() {
+			continue
+		}
+		seen[u] = true
+		if !assetAvailable(ctx, client, u, nil) {
+			missing = append(missing, u)
+		}
+	}
+
+	for _, i := range images {
+		if seen[i.DownloadLocation] || i.DownloadLocation == i.CanonicalLocation {
+			continue
+		}
+		seen[i.DownloadLocation] = true
+		if !assetAvailable(ctx, client, imageManifestURL(i.DownloadLocation), manifestMediaTypes) {
+			missing = append(missing, i.DownloadLocation)
+		}
+	}
+
+	sort.Strings(missing)
+	return missing
+}
+
+// manifestMediaTypes are the manifest media types accepted when looking up an
+// image in a registry
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+func assetAvailable(ctx context.Context, client *http.Client, u string, accept []string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return false
+	}
+	for _, a := range accept {
+		req.Header.Add("Accept", a)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusUnauthorized
+}
+
+// imageManifestURL returns the registry API URL of the manifest of an image,
+// e.g. https://registry.example.com/v2/kube-apiserver/manifests/v1.23.5 for
+// registry.example.com/kube-apiserver:v1.23.5
+func imageManifestURL(image string) string {
+	registry, repo := "registry-1.docker.io", image
+	if i := strings.Index(image, "/"); i >= 0 && strings.ContainsAny(image[:i], ".:") {
+		registry, repo = image[:i], image[i+1:]
+	} else if i < 0 {
+		repo = "library/" + image
+	}
+
+	ref := "latest"
+	if i := strings.Index(repo, "@"); i >= 0 {
+		repo, ref = repo[:i], repo[i+1:]
+	} else if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo, ref = repo[:i], repo[i+1:]
+	}
+	return fmt.Sprintf("https://%s/v2/%s/manifests/%s", registry, repo, ref)
+}
+
+// ParseProxyURL parses the URL of an HTTP or SOCKS5 proxy
+func ParseProxyURL(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse proxy URL")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, errors.Errorf("unsupported proxy scheme %q; must be http, https or socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("proxy URL has no host")
+	}
+	return u, nil
+}
+
+// SetProxy makes the supplied rest config connect through the supplied HTTP
+// or SOCKS5 proxy
+func SetProxy(config *rest.Config, proxy string) error {
+	u, err := ParseProxyURL(proxy)
+	if err != nil {
+		return err
+	}
+	config.Proxy = http.ProxyURL(u)
+	return nil
+}
+
+// ValidateAdditionalSANs validates the additional subject alternative names of
+// the API server certificate of a kops cluster spec
+func ValidateAdditionalSANs(sans []string, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, san := range sans {
+		if net.ParseIP(san) != nil {
+			continue
+		}
+		msgs := kvalidation.IsDNS1123Subdomain(san)
+		if strings.HasPrefix(san, "*.") {
+			msgs = kvalidation.IsWildcardDNS1123Subdomain(san)
+		}
+		for _, msg := range msgs {
+			errs = append(errs, field.Invalid(fldPath.Index(i), san, msg))
+		}
+	}
+	return errs
+}
+
+// NormalizeSANs returns the supplied subject alternative names sorted and
+// without duplicates, so that they can be compared
+func NormalizeSANs(sans []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(sans))
+	for _, san := range sans {
+		if !seen[san] {
+			seen[san] = true
+			out = append(out, san)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ValidateReachability validates the reachability parameters of a Kops
+// resource
+func ValidateReachability(p *v1alpha1.ReachabilityParameters, fldPath *field.Path) field.ErrorList {
+	if p == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	if p.Mode == v1alpha1.ReachabilityViaProxy && p.ProxyURL == "" {
+		errs = append(errs, field.Required(fldPath.Child("proxyURL"), "required in viaProxy mode"))
+	}
+	if p.ProxyURL != "" {
+		if _, err := ParseProxyURL(p.ProxyURL); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("proxyURL"), p.ProxyURL, err.Error()))
+		}
+	}
+	if p.TokenSecretRef != nil && p.KubeconfigSecretRef != nil {
+		errs = append(errs, field.Forbidden(fldPath.Child("kubeconfigSecretRef"), "may not be set together with tokenSecretRef"))
+	}
+	return errs
+}
+
+// ValidateFileAssets validates the file assets of a Kops resource
+func ValidateFileAssets(assets []v1alpha1.FileAssetParameters, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	names := map[string]bool{}
+	for i, a := range assets {
+		p := fldPath.Index(i)
+		if names[a.Name] {
+			errs = append(errs, field.Duplicate(p.Child("name"), a.Name))
+		}
+		names[a.Name] = true
+		if (a.ConfigMapRef == nil) == (a.SecretRef == nil) {
+			errs = append(errs, field.Invalid(p, a.Name, "exactly one of configMapRef and secretRef must be set"))
+		}
+	}
+	return errs
+}
+
+// MergeFileAssets adds the supplied file assets to a cluster spec, replacing
+// those of the spec with the same name
+func MergeFileAssets(clusterSpec *kopsapi.ClusterSpec, assets []kopsapi.FileAssetSpec) {
+	if len(assets) == 0 {
+		return
+	}
+	replaced := map[string]bool{}
+	for _, a := range assets {
+		replaced[a.Name] = true
+	}
+	merged := make([]kopsapi.FileAssetSpec, 0, len(clusterSpec.FileAssets)+len(assets))
+	for _, a := range clusterSpec.FileAssets {
+		if !replaced[a.Name] {
+			merged = append(merged, a)
+		}
+	}
+	clusterSpec.FileAssets = append(merged, assets...)
+}
+
+// GetEgressProxySpec returns the kops egress proxy spec of the supplied egress
+// proxy parameters, authenticating to the proxy with the supplied
+// user:password if any. kops has no field for proxy credentials, so they are
+// put in the host, which nodes prefix with http:// to form their proxy URL
+func GetEgressProxySpec(p *v1alpha1.EgressProxyParameters, credentials string) *kopsapi.EgressProxySpec {
+	if p == nil {
+		return nil
+	}
+	host := p.Host
+	if credentials != "" {
+		user, password := credentials, ""
+		if i := strings.Index(credentials, ":"); i >= 0 {
+			user, password = credentials[:i], credentials[i+1:]
+		}
+		host = url.UserPassword(user, password).String() + "@" + host
+	}
+	return &kopsapi.EgressProxySpec{
+		HTTPProxy:     kopsapi.HTTPProxy{Host: host, Port: p.Port},
+		ProxyExcludes: strings.Join(p.Excludes, ","),
+	}
+}
+
+// MergeEgressProxy sets the supplied egress proxy in a cluster spec
+func MergeEgressProxy(clusterSpec *kopsapi.ClusterSpec, proxy *kopsapi.EgressProxySpec) {
+	if proxy == nil {
+		return
+	}
+	clusterSpec.EgressProxy = proxy
+}
+
+// EgressProxyChanged returns true if the egress proxy of a cluster spec
+// differs from the one it had. The proxy is part of the user data of every
+// instance, so changing it replaces them all
+func EgressProxyChanged(current, desired *kopsapi.ClusterSpec) bool {
+	return !reflect.DeepEqual(current.EgressProxy, desired.EgressProxy)
+}
+
+// ValidateEgressProxy validates the egress proxy of a Kops resource
+func ValidateEgressProxy(cr *v1alpha1.Kops, fldPath *field.Path) field.ErrorList {
+	p := cr.Spec.ForProvider.EgressProxy
+	if p == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	if cr.Spec.ForProvider.ClusterSpec.EgressProxy != nil {
+		errs = append(errs, field.Forbidden(fldPath, "may not be set together with clusterSpec.egressProxy"))
+	}
+	if p.Host == "" || strings.ContainsAny(p.Host, "/@") {
+		errs = append(errs, field.Invalid(fldPath.Child("host"), p.Host, "must be a host name or IP address, without a scheme or credentials"))
+	}
+	if p.Port < 1 || p.Port > 65535 {
+		errs = append(errs, field.Invalid(fldPath.Child("port"), p.Port, "must be between 1 and 65535"))
+	}
+	for i, e := range p.Excludes {
+		if e == "" || strings.ContainsAny(e, ", ") {
+			errs = append(errs, field.Invalid(fldPath.Child("excludes").Index(i), e, "must be a single host, domain or CIDR"))
+		}
+	}
+	return errs
+}
+
+// GenerateKubeConfig generates a kubeconfig for a given kops cluster from its
+// rest config
+func GenerateKubeConfig(kopsCluster *kopsapi.Cluster, config *rest.Config) ([]byte, error) {
+	clusterName := kopsCluster.GetName()
+
+	kc := api.Config{
+		APIVersion: clusterv1.GroupVersion.String(),
+		Kind:       "Cluster",
+		Clusters: map[string]*api.Cluster{
+			clusterName: {
+				Server:                   config.Host,
+				CertificateAuthorityData: config.CAData,
+			},
+		},
+		Contexts: map[string]*api.Context{
+			clusterName: {
+				Cluster:  clusterName,
+				AuthInfo: clusterName,
+			},
+		},
+		CurrentContext: kopsCluster.ObjectMeta.Name,
+		AuthInfos: map[string]*api.AuthInfo{
+			clusterName: {
+				ClientCertificateData: config.CertData,
+				ClientKeyData:         config.KeyData,
+			},
+		},
+	}
+
+	out, err := clientcmd.Write(kc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to serialize config to yaml")
+	}
+
+	return out, nil
+}
+
+// ClusterResourceUpToDate checks if the cluster resource is up to date
+func ClusterResourceUpToDate(old, new *kopsapi.ClusterSpec) bool {
+	new.ConfigBase = ""
+	new.MasterPublicName = ""
+	var addons []kopsapi.AddonSpec
+	for _, a := range new.Addons {
+		if !strings.HasSuffix(a.Manifest, "/"+AddonsChannelPath) {
+			addons = append(addons, a)
+		}
+	}
+	new.Addons = addons
+	return reflect.DeepEqual(old, new)
+}
+
+// InstanceGroupListResourceUpToDate checks if the instance group list resource is up to date
+func InstanceGroupListResourceUpToDate(clusterSpec *kopsapi.ClusterSpec, old []kopsapi.InstanceGroupSpec, new *kopsapi.InstanceGroupList) bool {
+	if len(new.Items) < len(old) {
+		return false
+	}
+	for index := range old {
+		if !InstanceGroupResourceUpToDate(clusterSpec, &old[index], &new.Items[index].Spec) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetInstanceGroupsNeedingReplacement returns the names of the instance
+// groups whose changes replace their instances once applied. Changes to the
+// size, warm pool, mixed instances policy and other settings of the
+// autoscaling group of an instance group are applied to it in place
+func GetInstanceGroupsNeedingReplacement(clusterSpec *kopsapi.ClusterSpec, old []kopsapi.InstanceGroupSpec, new *kopsapi.InstanceGroupList) []string {
+	var names []string
+	for index := range old {
+		if index >= len(new.Items) {
+			break
+		}
+		o := normalizeInstanceGroupSpec(clusterSpec, &old[index])
+		n := normalizeInstanceGroupSpec(clusterSpec, &new.Items[index].Spec)
+		for _, spec := range []*kopsapi.InstanceGroupSpec{o, n} {
+			spec.MinSize, spec.MaxSize, spec.Autoscale = nil, nil, nil
+			spec.WarmPool, spec.MixedInstancesPolicy = nil, nil
+			spec.SuspendProcesses, spec.ExternalLoadBalancers = nil, nil
+			spec.InstanceProtection, spec.RollingUpdate, spec.UpdatePolicy = nil, nil, nil
+		}
+		if !reflect.DeepEqual(o, n) {
+			names = append(names, old[index].NodeLabels[kopsapi.NodeLabelInstanceGroup])
+		}
+	}
+	return names
+}
+
+// DefaultClusterSpec fills in the parts of a cluster spec and its instance
+// groups that most clusters set the same way and that are left unset: public
+// topology, Calico networking rather than kops' kubenet, which is limited by
+// the size of cloud route tables, the stable image channel, the Node role,
+// and main and events etcd clusters with a member on each control plane
+// instance group
+func DefaultClusterSpec(clusterSpec *kopsapi.ClusterSpec, igs []kopsapi.InstanceGroupSpec) {
+	if clusterSpec.Topology == nil {
+		clusterSpec.Topology = &kopsapi.TopologySpec{
+			Masters: kopsapi.TopologyPublic,
+			Nodes:   kopsapi.TopologyPublic,
+			DNS:     &kopsapi.DNSSpec{Type: kopsapi.DNSTypePublic},
+		}
+	}
+	if clusterSpec.Networking == nil {
+		clusterSpec.Networking = &kopsapi.NetworkingSpec{Calico: &kopsapi.CalicoNetworkingSpec{}}
+	}
+	if clusterSpec.Channel == "" {
+		clusterSpec.Channel = kopsapi.DefaultChannel
+	}
+	// kops runs the cloud controller manager of IPv6 clusters outside of
+	// kube-controller-manager, as kops create cluster --ipv6 does.
+	if clusterSpec.IsIPv6Only() && clusterSpec.ExternalCloudControllerManager == nil {
+		clusterSpec.ExternalCloudControllerManager = &kopsapi.CloudControllerManagerConfig{}
+	}
+
+	masters := []string{}
+	for i := range igs {
+		if igs[i].Role == "" {
+			igs[i].Role = kopsapi.InstanceGroupRoleNode
+		}
+		name := igs[i].NodeLabels[kopsapi.NodeLabelInstanceGroup]
+		if igs[i].Role == kopsapi.InstanceGroupRoleMaster && name != "" {
+			masters = append(masters, name)
+		}
+	}
+	if len(clusterSpec.EtcdClusters) != 0 || len(masters) == 0 {
+		return
+	}
+	for _, cluster := range []string{"main", "events"} {
+		etcd := kopsapi.EtcdClusterSpec{Name: cluster}
+		for _, ig := range masters {
+			etcd.Members = append(etcd.Members, kopsapi.EtcdMemberSpec{Name: strings.TrimPrefix(ig, "master-"), InstanceGroup: fi.String(ig)})
+		}
+		clusterSpec.EtcdClusters = append(clusterSpec.EtcdClusters, etcd)
+	}
+}
+
+// DefaultNetworkCIDR is the network CIDR the subnets of zones are allocated
+// from when a cluster doesn't set one, as with kops create cluster
+const DefaultNetworkCIDR = "172.20.0.0/16"
+
+// MaxZones is the number of zones whose subnets can be allocated from a
+// network CIDR
+const MaxZones = 7
+
+// DesiredClusterSpec returns the cluster spec of a Kops resource with the
+// helper fields of its parameters, such as zones, expanded into it
+func DesiredClusterSpec(cr *v1alpha1.Kops) kopsapi.ClusterSpec {
+	clusterSpec, _ := desiredSpecs(cr)
+	return clusterSpec
+}
+
+// DesiredInstanceGroupSpecs returns the instance groups of a Kops resource,
+// including those generated from its simple parameters
+func DesiredInstanceGroupSpecs(cr *v1alpha1.Kops) []kopsapi.InstanceGroupSpec {
+	_, igs := desiredSpecs(cr)
+	return igs
+}
+
+func desiredSpecs(cr *v1alpha1.Kops) (kopsapi.ClusterSpec, []kopsapi.InstanceGroupSpec) {
+	clusterSpec, igs := baseSpecs(cr)
+	// Overrides and patches that can't be applied are left out; Create and
+	// Update refuse to apply the cluster until they are fixed.
+	_ = ApplyClusterOverrides(&clusterSpec, cr.Spec.ForProvider.Overrides)
+	igs, _ = ApplyInstanceGroupPatches(igs, cr.Spec.ForProvider.InstanceGroupPatches)
+	return clusterSpec, igs
+}
+
+// baseSpecs returns the specs of a Kops resource, including those generated
+// from its parameters, without its overrides
+func baseSpecs(cr *v1alpha1.Kops) (kopsapi.ClusterSpec, []kopsapi.InstanceGroupSpec) {
+	clusterSpec := cr.Spec.ForProvider.ClusterSpec
+	igs := cr.Spec.ForProvider.InstanceGroupSpec
+	if simple := cr.Spec.ForProvider.Simple; simple != nil {
+		igs = ExpandSimple(&clusterSpec, igs, simple)
+	}
+	ExpandZones(&clusterSpec, cr.Spec.ForProvider.Zones)
+	igs = ExpandBastion(&clusterSpec, igs)
+	if cr.Spec.ForProvider.OnDemandFallback && len(cr.Status.AtProvider.OnDemandFallbacks) > 0 {
+		igs = applyOnDemandFallbacks(igs, cr.Status.AtProvider.OnDemandFallbacks)
+	}
+	if len(cr.Spec.ForProvider.ImageTracking) > 0 && len(cr.Status.AtProvider.TrackedImages) > 0 {
+		igs = applyTrackedImages(igs, cr.Spec.ForProvider.ImageTracking, cr.Status.AtProvider.TrackedImages)
+	}
+	if tags := cr.Spec.ForProvider.CloudTags; tags != nil {
+		igs = ApplyCloudTags(&clusterSpec, igs, tags, fmt.Sprintf("%v.%v", meta.GetExternalName(cr), cr.Spec.ForProvider.Domain))
+	}
+	return clusterSpec, igs
+}
+
+// ApplyClusterOverrides sets the fields of a cluster spec named by the
+// supplied overrides, in order, as kops set cluster does. Each override is a
+// dotted path from the cluster and a value, e.g. spec.kubelet.maxPods=120. The
+// cluster spec is left unchanged if any of them can't be applied
+func ApplyClusterOverrides(clusterSpec *kopsapi.ClusterSpec, overrides []string) error {
+	if len(overrides) == 0 {
+		return nil
+	}
+	// Fields are set through the pointers, maps and slices of the spec, so
+	// they are set on a copy that doesn't share them with the resource.
+	cluster := &kopsapi.Cluster{Spec: *clusterSpec.DeepCopy()}
+	for _, o := range overrides {
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], "spec.") {
+			return errors.Errorf("override %q is not of the form spec.<path>=<value>", o)
+		}
+		if err := reflectutils.SetString(cluster, kv[0], kv[1]); err != nil {
+			return errors.Wrapf(err, "cannot apply override %q", o)
+		}
+	}
+	*clusterSpec = cluster.Spec
+	return nil
+}
+
+// CheckClusterOverrides returns an error if the overrides of a Kops resource
+// can't be applied to its cluster spec
+func CheckClusterOverrides(cr *v1alpha1.Kops) error {
+	clusterSpec, _ := baseSpecs(cr)
+	return ApplyClusterOverrides(&clusterSpec, cr.Spec.ForProvider.Overrides)
+}
+
+// ValidateClusterOverrides validates the overrides of a Kops resource against
+// its cluster spec
+func ValidateClusterOverrides(cr *v1alpha1.Kops, fldPath *field.Path) field.ErrorList {
+	clusterSpec, _ := baseSpecs(cr)
+	var errs field.ErrorList
+	for i, o := range cr.Spec.ForProvider.Overrides {
+		if err := ApplyClusterOverrides(&clusterSpec, []string{o}); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Index(i), o, err.Error()))
+		}
+	}
+	return errs
+}
+
+// ApplyInstanceGroupPatches returns a copy of the supplied instance groups
+// with the supplied patches applied, in order, to the instance groups they
+// name. The instance groups are returned unpatched if any of the patches
+// can't be applied
+func ApplyInstanceGroupPatches(igs []kopsapi.InstanceGroupSpec, patches []v1alpha1.InstanceGroupPatch) ([]kopsapi.InstanceGroupSpec, error) {
+	if len(patches) == 0 {
+		return igs, nil
+	}
+	patched := make([]kopsapi.InstanceGroupSpec, len(igs))
+	copy(patched, igs)
+	for _, p := range patches {
+		i := -1
+		for j := range patched {
+			if patched[j].NodeLabels[kopsapi.NodeLabelInstanceGroup] == p.InstanceGroup {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return igs, errors.Errorf("no instance group named %q to patch", p.InstanceGroup)
+		}
+		ig, err := patchInstanceGroup(patched[i], p)
+		if err != nil {
+			return igs, errors.Wrapf(err, "cannot patch instance group %q", p.InstanceGroup)
+		}
+		patched[i] = ig
+	}
+	return patched, nil
+}
+
+// patchInstanceGroup returns the supplied instance group with the supplied
+// patch applied to it. Fields the instance group spec doesn't have are an
+// error rather than dropped, so that misspelt fields don't go unnoticed
+func patchInstanceGroup(ig kopsapi.InstanceGroupSpec, p v1alpha1.InstanceGroupPatch) (kopsapi.InstanceGroupSpec, error) {
+	doc, err := json.Marshal(ig)
+	if err != nil {
+		return ig, err
+	}
+	patch, err := yaml.YAMLToJSON([]byte(p.Patch))
+	if err != nil {
+		return ig, errors.Wrap(err, "cannot parse patch")
+	}
+	switch p.Type {
+	case v1alpha1.InstanceGroupPatchJSON6902:
+		jp, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return ig, errors.Wrap(err, "cannot parse patch")
+		}
+		doc, err = jp.Apply(doc)
+		if err != nil {
+			return ig, err
+		}
+	default:
+		doc, err = strategicpatch.StrategicMergePatch(doc, patch, kopsapi.InstanceGroupSpec{})
+		if err != nil {
+			return ig, err
+		}
+	}
+	out := kopsapi.InstanceGroupSpec{}
+	d := json.NewDecoder(bytes.NewReader(doc))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&out); err != nil {
+		return ig, err
+	}
+	return out, nil
+}
+
+// CheckInstanceGroupPatches returns an error if the instance group patches of
+// a Kops resource can't be applied to its instance groups
+func CheckInstanceGroupPatches(cr *v1alpha1.Kops) error {
+	_, igs := baseSpecs(cr)
+	_, err := ApplyInstanceGroupPatches(igs, cr.Spec.ForProvider.InstanceGroupPatches)
+	return err
+}
+
+// ValidateInstanceGroupPatches validates the instance group patches of a Kops
+// resource against its instance groups
+func ValidateInstanceGroupPatches(cr *v1alpha1.Kops, fldPath *field.Path) field.ErrorList {
+	_, igs := baseSpecs(cr)
+	var errs field.ErrorList
+	for i, p := range cr.Spec.ForProvider.InstanceGroupPatches {
+		patched, err := ApplyInstanceGroupPatches(igs, []v1alpha1.InstanceGroupPatch{p})
+		if err != nil {
+			errs = append(errs, field.Invalid(fldPath.Index(i), p.InstanceGroup, err.Error()))
+			continue
+		}
+		igs = patched
+	}
+	return errs
+}
+
+// ApplyCloudTags adds the supplied cloud tags to the cloud labels of a cluster
+// spec and returns a copy of the supplied instance groups with them added to
+// their cloud labels, which kops applies to the cloud resources of the cluster
+// and its instance groups. The tags are added to the instance groups too, since
+// their cloud labels take precedence over those of the cluster
+func ApplyCloudTags(clusterSpec *kopsapi.ClusterSpec, igs []kopsapi.InstanceGroupSpec, tags *v1alpha1.CloudTagParameters, clusterName string) []kopsapi.InstanceGroupSpec {
+	labels := make(map[string]string, len(clusterSpec.CloudLabels)+len(tags.Tags)+1)
+	for k, v := range clusterSpec.CloudLabels {
+		labels[k] = v
+	}
+	for k, v := range tags.Tags {
+		labels[k] = v
+	}
+	if tags.ClusterNameKey != "" {
+		labels[tags.ClusterNameKey] = clusterName
+	}
+	if len(labels) > 0 {
+		clusterSpec.CloudLabels = labels
+	}
+
+	out := make([]kopsapi.InstanceGroupSpec, len(igs))
+	for i := range igs {
+		out[i] = igs[i]
+		if len(tags.Tags) == 0 && tags.InstanceGroupKey == "" {
+			continue
+		}
+		labels := make(map[string]string, len(igs[i].CloudLabels)+len(tags.Tags)+1)
+		for k, v := range igs[i].CloudLabels {
+			labels[k] = v
+		}
+		for k, v := range tags.Tags {
+			labels[k] = v
+		}
+		if tags.InstanceGroupKey != "" {
+			labels[tags.InstanceGroupKey] = igs[i].NodeLabels[kopsapi.NodeLabelInstanceGroup]
+		}
+		out[i].CloudLabels = labels
+	}
+	return out
+}
+
+// reservedTagKeys are the tags kops and the cloud provider rely on
+var reservedTagKeys = map[string]bool{"KubernetesCluster": true, "Name": true, kopsapi.NodeLabelInstanceGroup: true}
+
+// reservedTagPrefixes are the prefixes of the tags kops, the cloud provider
+// and the cloud rely on, e.g. kubernetes.io/cluster/<cluster name>
+var reservedTagPrefixes = []string{"kubernetes.io/cluster/", "k8s.io/", "kops.k8s.io/", "aws:"}
+
+func reservedTagKey(key string) bool {
+	if reservedTagKeys[key] {
+		return true
+	}
+	for _, p := range reservedTagPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateCloudTags validates the cloud tags of a Kops resource. Tags that
+// would override the tags kops applies itself are rejected
+func ValidateCloudTags(p *v1alpha1.CloudTagParameters, fldPath *field.Path) field.ErrorList {
+	if p == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	keys := make([]string, 0, len(p.Tags))
+	for k := range p.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if reservedTagKey(k) {
+			errs = append(errs, field.Invalid(fldPath.Child("tags").Key(k), k, "is a tag kops applies itself"))
+		}
+	}
+	if reservedTagKey(p.ClusterNameKey) {
+		errs = append(errs, field.Invalid(fldPath.Child("clusterNameKey"), p.ClusterNameKey, "is a tag kops applies itself"))
+	}
+	if reservedTagKey(p.InstanceGroupKey) {
+		errs = append(errs, field.Invalid(fldPath.Child("instanceGroupKey"), p.InstanceGroupKey, "is a tag kops applies itself"))
+	}
+	return errs
+}
+
+// applyTrackedImages returns a copy of the supplied instance groups in which
+// those whose image is still tracked use the latest image found for them
+func applyTrackedImages(igs []kopsapi.InstanceGroupSpec, tracking []v1alpha1.ImageTrackingParameters, tracked []v1alpha1.TrackedImageObservation) []kopsapi.InstanceGroupSpec {
+	images := map[string]string{}
+	for _, t := range tracked {
+		images[t.InstanceGroup] = t.Image
+	}
+	out := make([]kopsapi.InstanceGroupSpec, len(igs))
+	copy(out, igs)
+	for _, t := range tracking {
+		for i := range out {
+			if out[i].NodeLabels[kopsapi.NodeLabelInstanceGroup] == t.InstanceGroup && images[t.InstanceGroup] != "" {
+				out[i].Image = images[t.InstanceGroup]
+			}
+		}
+	}
+	return out
+}
+
+// applyOnDemandFallbacks returns a copy of the supplied instance groups in
+// which those that fell back to on-demand instances launch on-demand
+// instances only
+func applyOnDemandFallbacks(igs []kopsapi.InstanceGroupSpec, fallbacks []v1alpha1.OnDemandFallbackObservation) []kopsapi.InstanceGroupSpec {
+	fellBack := map[string]bool{}
+	for _, f := range fallbacks {
+		fellBack[f.InstanceGroup] = true
+	}
+	out := make([]kopsapi.InstanceGroupSpec, len(igs))
+	for i := range igs {
+		out[i] = igs[i]
+		if fellBack[igs[i].NodeLabels[kopsapi.NodeLabelInstanceGroup]] {
+			out[i] = *ApplyOnDemandFallback(&igs[i])
+		}
+	}
+	return out
+}
+
+// DefaultMachineType is the machine type of the instances of clusters in
+// simple mode that don't set one, as with kops create cluster on AWS
+const DefaultMachineType = "t3.medium"
+
+// simpleNetworking returns the networking spec of the supplied networking
+// provider of a cluster in simple mode, defaulting to Calico
+func simpleNetworking(provider string) *kopsapi.NetworkingSpec {
+	switch provider {
+	case "kubenet":
+		return &kopsapi.NetworkingSpec{Kubenet: &kopsapi.KubenetNetworkingSpec{}}
+	case "cilium":
+		return &kopsapi.NetworkingSpec{Cilium: &kopsapi.CiliumNetworkingSpec{}}
+	case "amazonvpc":
+		return &kopsapi.NetworkingSpec{AmazonVPC: &kopsapi.AmazonVPCNetworkingSpec{}}
+	case "canal":
+		return &kopsapi.NetworkingSpec{Canal: &kopsapi.CanalNetworkingSpec{}}
+	case "flannel":
+		return &kopsapi.NetworkingSpec{Flannel: &kopsapi.FlannelNetworkingSpec{Backend: "vxlan"}}
+	case "weave":
+		return &kopsapi.NetworkingSpec{Weave: &kopsapi.WeaveNetworkingSpec{}}
+	default:
+		return &kopsapi.NetworkingSpec{Calico: &kopsapi.CalicoNetworkingSpec{}}
+	}
+}
+
+// ExpandSimple fills the fields a cluster spec leaves unset from the supplied
+// simple parameters, the way kops create cluster does from its flags, and
+// returns the supplied instance groups, or instance groups generated from the
+// parameters if there are none. Pointers and slices of the supplied spec are
+// replaced rather than changed
+func ExpandSimple(clusterSpec *kopsapi.ClusterSpec, igs []kopsapi.InstanceGroupSpec, simple *v1alpha1.SimpleParameters) []kopsapi.InstanceGroupSpec {
+	if clusterSpec.CloudProvider == "" {
+		clusterSpec.CloudProvider = string(kopsapi.CloudProviderAWS)
+	}
+	if clusterSpec.KubernetesVersion == "" {
+		clusterSpec.KubernetesVersion = simple.KubernetesVersion
+	}
+	if clusterSpec.Channel == "" {
+		clusterSpec.Channel = kopsapi.DefaultChannel
+	}
+	if clusterSpec.Topology == nil {
+		clusterSpec.Topology = &kopsapi.TopologySpec{
+			Masters: kopsapi.TopologyPublic,
+			Nodes:   kopsapi.TopologyPublic,
+			DNS:     &kopsapi.DNSSpec{Type: kopsapi.DNSTypePublic},
+		}
+	}
+	if clusterSpec.Networking == nil {
+		clusterSpec.Networking = simpleNetworking(simple.Networking)
+	}
+
+	zones := simple.Zones
+	if len(zones) == 0 {
+		return igs
+	}
+	masters := 1
+	if simple.MasterCount != nil && int(*simple.MasterCount) > 0 {
+		masters = int(*simple.MasterCount)
+	}
+	if masters > len(zones) {
+		masters = len(zones)
+	}
+	masterZones := zones[:masters]
+
+	if len(clusterSpec.EtcdClusters) == 0 {
+		for _, cluster := range []string{"main", "events"} {
+			etcd := kopsapi.EtcdClusterSpec{Name: cluster}
+			for _, zone := range masterZones {
+				etcd.Members = append(etcd.Members, kopsapi.EtcdMemberSpec{Name: zone, InstanceGroup: fi.String("master-" + zone)})
+			}
+			clusterSpec.EtcdClusters = append(clusterSpec.EtcdClusters, etcd)
+		}
+	}
+	ExpandZones(clusterSpec, zones)
+
+	if len(igs) != 0 {
+		return igs
+	}
+	machineType := func(t string) string {
+		if t == "" {
+			return DefaultMachineType
+		}
+		return t
+	}
+	group := func(name, zone, machine string, role kopsapi.InstanceGroupRole, size int32) kopsapi.InstanceGroupSpec {
+		return kopsapi.InstanceGroupSpec{
+			Role:        role,
+			MachineType: machineType(machine),
+			MinSize:     fi.Int32(size),
+			MaxSize:     fi.Int32(size),
+			Subnets:     []string{zone},
+			NodeLabels:  map[string]string{kopsapi.NodeLabelInstanceGroup: name},
+		}
+	}
+	for _, zone := range masterZones {
+		igs = append(igs, group("master-"+zone, zone, simple.MasterSize, kopsapi.InstanceGroupRoleMaster, 1))
+	}
+	nodes := int32(len(zones))
+	if simple.NodeCount != nil {
+		nodes = *simple.NodeCount
+	}
+	for i, zone := range zones {
+		// Nodes are spread evenly, with the remainder in the first zones.
+		size := nodes / int32(len(zones))
+		if int32(i) < nodes%int32(len(zones)) {
+			size++
+		}
+		igs = append(igs, group("nodes-"+zone, zone, simple.NodeSize, kopsapi.InstanceGroupRoleNode, size))
+	}
+	return igs
+}
+
+// ExpandZones adds a subnet in each of the supplied zones to a cluster spec
+// without subnets, and a member in each zone to main and events etcd clusters
+// if it has none. Subnet CIDRs are allocated from the network CIDR the way
+// kops create cluster allocates them: it is split in eight, with the first
+// eighth split in eight again for the utility subnets of private topologies.
+// Slices of the supplied spec are replaced rather than changed
+func ExpandZones(clusterSpec *kopsapi.ClusterSpec, zones []string) {
+	if len(zones) == 0 {
+		return
+	}
+
+	if len(clusterSpec.Subnets) == 0 {
+		if clusterSpec.NetworkCIDR == "" {
+			clusterSpec.NetworkCIDR = DefaultNetworkCIDR
+		}
+		private := clusterSpec.Topology != nil && clusterSpec.Topology.Masters == kopsapi.TopologyPrivate
+		var big, little []*net.IPNet
+		if _, cidr, err := net.ParseCIDR(clusterSpec.NetworkCIDR); err == nil && len(zones) <= MaxZones {
+			big, _ = subnet.SplitInto8(cidr)
+			if len(big) > 0 {
+				little, _ = subnet.SplitInto8(big[0])
+				big = big[1:]
+			}
+		}
+		cidr := func(cidrs []*net.IPNet, i int) string {
+			if i < len(cidrs) {
+				return cidrs[i].String()
+			}
+			// kops allocates the CIDR if it can, or fails the apply.
+			return ""
+		}
+
+		subnets := []kopsapi.ClusterSubnetSpec{}
+		for i, zone := range zones {
+			if !private {
+				subnets = append(subnets, kopsapi.ClusterSubnetSpec{Name: zone, Zone: zone, CIDR: cidr(big, i), Type: kopsapi.SubnetTypePublic})
+				continue
+			}
+			subnets = append(subnets, kopsapi.ClusterSubnetSpec{Name: zone, Zone: zone, CIDR: cidr(big, i), Type: kopsapi.SubnetTypePrivate})
+		}
+		if private {
+			for i, zone := range zones {
+				subnets = append(subnets, kopsapi.ClusterSubnetSpec{Name: "utility-" + zone, Zone: zone, CIDR: cidr(little, i), Type: kopsapi.SubnetTypeUtility})
+			}
+		}
+		// Subnets of IPv6 clusters are allocated a /64 of the VPC's IPv6
+		// CIDR each, as kops create cluster --ipv6 allocates them.
+		if clusterSpec.IsIPv6Only() {
+			for i := range subnets {
+				subnets[i].IPv6CIDR = fmt.Sprintf("/64#%x", i)
+			}
+		}
+		clusterSpec.Subnets = subnets
+	}
+
+	if len(clusterSpec.EtcdClusters) == 0 {
+		for _, cluster := range []string{"main", "events"} {
+			etcd := kopsapi.EtcdClusterSpec{Name: cluster}
+			for _, zone := range zones {
+				etcd.Members = append(etcd.Members, kopsapi.EtcdMemberSpec{Name: zone, InstanceGroup: fi.String("master-" + zone)})
+			}
+			clusterSpec.EtcdClusters = append(clusterSpec.EtcdClusters, etcd)
+		}
+	}
+}
+
+// ValidateZones returns the problems with the zones of a cluster: zones set
+// alongside subnets, repeated zones, and more zones than can be allocated
+// subnets from the network CIDR
+func ValidateZones(clusterSpec *kopsapi.ClusterSpec, zones []string, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if len(zones) == 0 {
+		return errs
+	}
+	if len(clusterSpec.Subnets) != 0 {
+		errs = append(errs, field.Forbidden(fldPath, "zones can't be set alongside clusterSpec.subnets"))
+	}
+	if len(zones) > MaxZones {
+		errs = append(errs, field.TooMany(fldPath, len(zones), MaxZones))
+	}
+	seen := map[string]bool{}
+	for i, zone := range zones {
+		if seen[zone] {
+			errs = append(errs, field.Duplicate(fldPath.Index(i), zone))
+		}
+		seen[zone] = true
+	}
+	if clusterSpec.NetworkCIDR != "" {
+		if _, _, err := net.ParseCIDR(clusterSpec.NetworkCIDR); err != nil {
+			errs = append(errs, field.Invalid(fldPath, clusterSpec.NetworkCIDR, "subnets can't be allocated from an invalid clusterSpec.networkCIDR"))
+		}
+	}
+	return errs
+}
+
+// ipv6Networking are the networking providers kops supports IPv6 clusters
+// with
+var ipv6Networking = map[string]bool{
+	"calico":   true,
+	"cilium":   true,
+	"cni":      true,
+	"external": true,
+}
+
+// ValidateIPv6 returns the problems with the IPv6 and dual-stack settings of
+// a cluster that kops only reports when applying it: subnet IPv6 CIDRs that
+// are neither IPv6 CIDRs nor allocations from the VPC's IPv6 CIDR, and, for
+// IPv6 clusters, a non-masquerade CIDR other than ::/0, a networking provider
+// without IPv6 support, and a missing external cloud controller manager
+func ValidateIPv6(clusterSpec *kopsapi.ClusterSpec, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, s := range clusterSpec.Subnets {
+		if s.IPv6CIDR == "" {
+			continue
+		}
+		p := fldPath.Child("subnets").Index(i).Child("ipv6CIDR")
+		if strings.HasPrefix(s.IPv6CIDR, "/") {
+			if size, _, err := utils.ParseCIDRNotation(s.IPv6CIDR); err != nil || size > 128 {
+				errs = append(errs, field.Invalid(p, s.IPv6CIDR, "must be an IPv6 CIDR or a /<size>#<hex index> allocation from the VPC's IPv6 CIDR"))
+			}
+			continue
+		}
+		if !utils.IsIPv6CIDR(s.IPv6CIDR) {
+			errs = append(errs, field.Invalid(p, s.IPv6CIDR, "must be an IPv6 CIDR or a /<size>#<hex index> allocation from the VPC's IPv6 CIDR"))
+		}
+	}
+
+	if !clusterSpec.IsIPv6Only() {
+		return errs
+	}
+	if clusterSpec.NonMasqueradeCIDR != "::/0" {
+		errs = append(errs, field.Invalid(fldPath.Child("nonMasqueradeCIDR"), clusterSpec.NonMasqueradeCIDR, "must be ::/0 for IPv6 clusters"))
+	}
+	if provider := GetNetworkingProvider(clusterSpec.Networking); provider != "" && !ipv6Networking[provider] {
+		errs = append(errs, field.Forbidden(fldPath.Child("networking", provider), "networking provider doesn't support IPv6 clusters"))
+	}
+	if clusterSpec.ExternalCloudControllerManager == nil {
+		errs = append(errs, field.Required(fldPath.Child("cloudControllerManager"), "required for IPv6 clusters"))
+	}
+	return errs
+}
+
+// ValidateImageTracking returns the problems with the image tracking of the
+// instance groups of a cluster: tracked instance groups that don't exist or
+// are tracked twice, and SSM parameter sources without a parameter
+func ValidateImageTracking(tracking []v1alpha1.ImageTrackingParameters, igs []kopsapi.InstanceGroupSpec, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	names := map[string]bool{}
+	for _, ig := range igs {
+		names[ig.NodeLabels[kopsapi.NodeLabelInstanceGroup]] = true
+	}
+	seen := map[string]bool{}
+	for i, t := range tracking {
+		p := fldPath.Index(i)
+		switch {
+		case !names[t.InstanceGroup]:
+			errs = append(errs, field.NotFound(p.Child("instanceGroup"), t.InstanceGroup))
+		case seen[t.InstanceGroup]:
+			errs = append(errs, field.Duplicate(p.Child("instanceGroup"), t.InstanceGroup))
+		}
+		seen[t.InstanceGroup] = true
+		if t.Source == v1alpha1.ImageSourceSSMParameter && t.SSMParameter == "" {
+			errs = append(errs, field.Required(p.Child("ssmParameter"), "required when the source is ssmParameter"))
+		}
+	}
+	return errs
+}
+
+// ValidateSimple returns the problems with the simple parameters of a
+// cluster: zones that can't be expanded, a missing Kubernetes version, and
+// more control plane instances than zones
+func ValidateSimple(clusterSpec *kopsapi.ClusterSpec, simple *v1alpha1.SimpleParameters, fldPath *field.Path) field.ErrorList {
+	errs := ValidateZones(clusterSpec, simple.Zones, fldPath.Child("zones"))
+	if simple.KubernetesVersion == "" && clusterSpec.KubernetesVersion == "" {
+		errs = append(errs, field.Required(fldPath.Child("kubernetesVersion"), "required unless clusterSpec.kubernetesVersion is set"))
+	}
+	if simple.MasterCount != nil && int(*simple.MasterCount) > len(simple.Zones) {
+		errs = append(errs, field.Invalid(fldPath.Child("masterCount"), *simple.MasterCount, "must not be more than the number of zones"))
+	}
+	return errs
+}
+
+const (
+	// BastionInstanceGroupName is the name of the bastion instance group of
+	// clusters whose instance groups don't include one, as with kops create
+	// cluster --bastion
+	BastionInstanceGroupName = "bastions"
+
+	// DefaultBastionMachineType is the machine type of that instance group
+	DefaultBastionMachineType = "t3.micro"
+)
+
+// ExpandBastion returns the supplied instance groups with a bastion instance
+// group in the utility subnets of the cluster added if the cluster's topology
+// has a bastion and none of the instance groups is one. It is added where the
+// state store lists it, by name
+func ExpandBastion(clusterSpec *kopsapi.ClusterSpec, igs []kopsapi.InstanceGroupSpec) []kopsapi.InstanceGroupSpec {
+	if clusterSpec.Topology == nil || clusterSpec.Topology.Bastion == nil {
+		return igs
+	}
+	for _, ig := range igs {
+		if ig.Role == kopsapi.InstanceGroupRoleBastion {
+			return igs
+		}
+	}
+
+	var subnets []string
+	for _, s := range clusterSpec.Subnets {
+		if s.Type == kopsapi.SubnetTypeUtility {
+			subnets = append(subnets, s.Name)
+		}
+	}
+	bastion := kopsapi.InstanceGroupSpec{
+		Role:        kopsapi.InstanceGroupRoleBastion,
+		MachineType: DefaultBastionMachineType,
+		MinSize:     fi.Int32(1),
+		MaxSize:     fi.Int32(1),
+		Subnets:     subnets,
+		NodeLabels:  map[string]string{kopsapi.NodeLabelInstanceGroup: BastionInstanceGroupName},
+	}
+
+	out := make([]kopsapi.InstanceGroupSpec, 0, len(igs)+1)
+	added := false
+	for _, ig := range igs {
+		if !added && ig.NodeLabels[kopsapi.NodeLabelInstanceGroup] > BastionInstanceGroupName {
+			out = append(out, bastion)
+			added = true
+		}
+		out = append(out, ig)
+	}
+	if !added {
+		out = append(out, bastion)
+	}
+	return out
+}
+
+// GetRemovedBastions returns the bastion instance groups of a cluster that
+// are not among the supplied desired instance groups
+func GetRemovedBastions(desired []kopsapi.InstanceGroupSpec, igs *kopsapi.InstanceGroupList) []kopsapi.InstanceGroup {
+	names := map[string]bool{}
+	for _, ig := range desired {
+		names[ig.NodeLabels[kopsapi.NodeLabelInstanceGroup]] = true
+	}
+	var removed []kopsapi.InstanceGroup
+	for _, ig := range igs.Items {
+		if ig.Spec.Role == kopsapi.InstanceGroupRoleBastion && !names[ig.Name] {
+			removed = append(removed, ig)
+		}
+	}
+	return removed
+}
+
+// GetBastionResources returns the cloud resources of a cluster that only
+// serve its bastion: its load balancer and DNS record, named bastion.<cluster>
+// or bastion-<cluster>, its security groups, and the IAM role and instance
+// profile of its instance group, named bastions.<cluster>
+func GetBastionResources(res map[string]*resources.Resource) map[string]*resources.Resource {
+	bastion := map[string]*resources.Resource{}
+	for k, r := range res {
+		for _, prefix := range []string{"bastion.", "bastion-", BastionInstanceGroupName + "."} {
+			if strings.HasPrefix(r.Name, prefix) {
+				bastion[k] = r
+				break
+			}
+		}
+	}
+	return bastion
+}
+
+// RevokeBastionIngress revokes the ingress rules of the security groups of a
+// cluster that allow traffic from its bastion security groups, which would
+// otherwise keep those from being deleted
+func RevokeBastionIngress(ec2API ec2iface.EC2API, res, bastion map[string]*resources.Resource) error {
+	bastionGroups := map[string]bool{}
+	for _, r := range bastion {
+		if r.Type == ec2.ResourceTypeSecurityGroup {
+			bastionGroups[r.ID] = true
+		}
+	}
+	if len(bastionGroups) == 0 {
+		return nil
+	}
+
+	for k, r := range res {
+		if _, ok := bastion[k]; ok || r.Type != ec2.ResourceTypeSecurityGroup {
+			continue
+		}
+		out, err := ec2API.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{GroupIds: []*string{aws.String(r.ID)}})
+		if err != nil {
+			return errors.Wrapf(err, "cannot describe security group %s", r.ID)
+		}
+		var revoke []*ec2.IpPermission
+		for _, sg := range out.SecurityGroups {
+			for _, perm := range sg.IpPermissions {
+				var pairs []*ec2.UserIdGroupPair
+				for _, pair := range perm.UserIdGroupPairs {
+					if bastionGroups[aws.StringValue(pair.GroupId)] {
+						pairs = append(pairs, pair)
+					}
+				}
+				if len(pairs) == 0 {
+					continue
+				}
+				revoke = append(revoke, &ec2.IpPermission{
+					IpProtocol:       perm.IpProtocol,
+					FromPort:         perm.FromPort,
+					ToPort:           perm.ToPort,
+					UserIdGroupPairs: pairs,
+				})
+			}
+		}
+		if len(revoke) == 0 {
+			continue
+		}
+		if _, err := ec2API.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{GroupId: aws.String(r.ID), IpPermissions: revoke}); err != nil {
+			return errors.Wrapf(err, "cannot revoke bastion ingress of security group %s", r.ID)
+		}
+	}
+	return nil
+}
+
+// GetClusterRegions returns the regions the subnets of a cluster are in,
+// derived from their zones the way kops derives the region of the cloud of a
+// cluster, or the supplied region if no subnet has a zone or region
+func GetClusterRegions(cluster *kopsapi.Cluster, fallback string) []string {
+	found := map[string]bool{}
+	for _, s := range cluster.Spec.Subnets {
+		region := s.Region
+		if region == "" && s.Zone != "" {
+			region = zoneRegion(cluster.Spec.CloudProvider, s.Zone)
+		}
+		if region != "" {
+			found[region] = true
+		}
+	}
+	if len(found) == 0 {
+		if fallback == "" {
+			return nil
+		}
+		return []string{fallback}
+	}
+	regions := make([]string, 0, len(found))
+	for r := range found {
+		regions = append(regions, r)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// zoneRegion returns the region of the supplied zone of a cloud: GCE zones
+// like us-central1-a are their region followed by a suffix, while the zones
+// of other clouds, like us-east-1a, are their region followed by a letter
+func zoneRegion(cloudProvider, zone string) string {
+	if kopsapi.CloudProviderID(cloudProvider) == kopsapi.CloudProviderGCE {
+		if i := strings.LastIndex(zone, "-"); i > 0 {
+			return zone[:i]
+		}
+		return zone
+	}
+	return zone[:len(zone)-1]
+}
+
+// taskResourceTypes are the types of the cloud resources kops lists for a
+// cluster by the name of the task that creates them
+var taskResourceTypes = map[string][]string{
+	"IAMRole":                   {"iam-role"},
+	"IAMInstanceProfile":        {"iam-instance-profile"},
+	"IAMOIDCProvider":           {"oidc-provider"},
+	"VPC":                       {ec2.ResourceTypeVpc},
+	"DHCPOptions":               {"dhcp-options"},
+	"Subnet":                    {ec2.ResourceTypeSubnet},
+	"SecurityGroup":             {ec2.ResourceTypeSecurityGroup},
+	"InternetGateway":           {"internet-gateway"},
+	"EgressOnlyInternetGateway": {"egress-only-internet-gateway"},
+	"NatGateway":                {awsresources.TypeNatGateway},
+	"ElasticIP":                 {awsresources.TypeElasticIp},
+	"RouteTable":                {ec2.ResourceTypeRouteTable},
+	"SSHKey":                    {"keypair"},
+	"ClassicLoadBalancer":       {awsresources.TypeLoadBalancer},
+	"NetworkLoadBalancer":       {awsresources.TypeLoadBalancer},
+	"TargetGroup":               {awsresources.TypeTargetGroup},
+	"AutoscalingGroup":          {"autoscaling-group"},
+	"LaunchTemplate":            {awsresources.TypeAutoscalingLaunchConfig},
+	"EBSVolume":                 {"volume"},
+}
+
+// GetLifecycleOverrides returns the kops lifecycle overrides of the supplied
+// task lifecycles
+func GetLifecycleOverrides(overrides map[string]v1alpha1.TaskLifecycle) map[string]fi.Lifecycle {
+	if len(overrides) == 0 {
+		return nil
+	}
+	out := make(map[string]fi.Lifecycle, len(overrides))
+	for task, l := range overrides {
+		out[task] = fi.Lifecycle(l)
+	}
+	return out
+}
+
+// RetainResources marks the cloud resources of tasks whose lifecycle override
+// leaves them to another system as done, so that kops neither deletes them
+// nor waits for them while deleting the resources that depend on them. It
+// returns the number of resources retained
+func RetainResources(res map[string]*resources.Resource, overrides map[string]v1alpha1.TaskLifecycle) int {
+	retain := map[string]bool{}
+	for task, l := range overrides {
+		if l == v1alpha1.TaskLifecycleSync || l == v1alpha1.TaskLifecycleWarnIfInsufficientAccess {
+			continue
+		}
+		for _, t := range taskResourceTypes[task] {
+			retain[t] = true
+		}
+	}
+	n := 0
+	for _, r := range res {
+		if retain[r.Type] && !r.Done {
+			r.Done = true
+			n++
+		}
+	}
+	return n
+}
+
+// GetDeletePreview returns the supplied cloud resources of a cluster sorted by
+// type and ID, marking those already done as retained
+func GetDeletePreview(res map[string]*resources.Resource) []v1alpha1.DeletePreviewResource {
+	preview := make([]v1alpha1.DeletePreviewResource, 0, len(res))
+	for _, r := range res {
+		preview = append(preview, v1alpha1.DeletePreviewResource{
+			Type:     r.Type,
+			ID:       r.ID,
+			Name:     r.Name,
+			Shared:   r.Shared,
+			Retained: r.Done,
+		})
+	}
+	sort.Slice(preview, func(i, j int) bool {
+		if preview[i].Type != preview[j].Type {
+			return preview[i].Type < preview[j].Type
+		}
+		return preview[i].ID < preview[j].ID
+	})
+	return preview
+}
+
+// ValidateInstanceGroupSpecs returns the problems with the instance groups of
+// a cluster that kops would otherwise only report once the cluster is
+// applied: instance groups without a name or sharing one, a cluster without
+// control plane instance groups, and instance groups in subnets the cluster
+// doesn't have
+func ValidateInstanceGroupSpecs(clusterSpec *kopsapi.ClusterSpec, igs []kopsapi.InstanceGroupSpec, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	subnets := subnetNames(clusterSpec)
+
+	names := map[string]bool{}
+	masters := 0
+	for i, ig := range igs {
+		p := fldPath.Index(i)
+		name := ig.NodeLabels[kopsapi.NodeLabelInstanceGroup]
+		switch {
+		case name == "":
+			errs = append(errs, field.Required(p.Child("nodeLabels").Key(kopsapi.NodeLabelInstanceGroup), "instance groups are named by this label"))
+		case names[name]:
+			errs = append(errs, field.Duplicate(p.Child("nodeLabels").Key(kopsapi.NodeLabelInstanceGroup), name))
+		}
+		names[name] = true
+
+		if ig.Role == kopsapi.InstanceGroupRoleMaster {
+			masters++
+		}
+		for j, s := range ig.Subnets {
+			if !subnets[s] {
+				errs = append(errs, field.NotFound(p.Child("subnets").Index(j), s))
+			}
+		}
+	}
+	if masters == 0 {
+		errs = append(errs, field.Required(fldPath, fmt.Sprintf("at least one instance group must have the %s role", kopsapi.InstanceGroupRoleMaster)))
+	}
+	return errs
+}
+
+// GetMissingSubnets returns the subnets instance groups are in that a cluster
+// spec doesn't have, each as the name of the instance group and the subnet
+func GetMissingSubnets(clusterSpec *kopsapi.ClusterSpec, igs []kopsapi.InstanceGroupSpec) []string {
+	subnets := subnetNames(clusterSpec)
+	var missing []string
+	for _, ig := range igs {
+		for _, s := range ig.Subnets {
+			if !subnets[s] {
+				missing = append(missing, fmt.Sprintf("%s: %s", ig.NodeLabels[kopsapi.NodeLabelInstanceGroup], s))
+			}
+		}
+	}
+	return missing
+}
+
+func subnetNames(clusterSpec *kopsapi.ClusterSpec) map[string]bool {
+	subnets := map[string]bool{}
+	for _, s := range clusterSpec.Subnets {
+		subnets[s.Name] = true
+	}
+	return subnets
+}
+
+// InstanceGroupResourceUpToDate checks if the instance group resource is up to date
+func InstanceGroupResourceUpToDate(clusterSpec *kopsapi.ClusterSpec, old, new *kopsapi.InstanceGroupSpec) bool {
+	return reflect.DeepEqual(normalizeInstanceGroupSpec(clusterSpec, old), normalizeInstanceGroupSpec(clusterSpec, new))
+}
+
+// Defaults AWS applies to the unset fields of a mixed instances policy
+const (
+	mixedInstancesOnDemandAllocationStrategy = "prioritized"
+	mixedInstancesSpotAllocationStrategy     = "lowest-price"
+	mixedInstancesOnDemandAboveBase          = 100
+	mixedInstancesSpotInstancePools          = 2
+)
+
+// normalizeInstanceGroupSpec returns a copy of an instance group spec whose
+// warm pool and mixed instances policy are in a canonical form, so that specs
+// that configure an autoscaling group the same way compare equal
+func normalizeInstanceGroupSpec(clusterSpec *kopsapi.ClusterSpec, spec *kopsapi.InstanceGroupSpec) *kopsapi.InstanceGroupSpec {
+	out := spec.DeepCopy()
+
+	// Warm pools are compared as kops resolves them against the default warm
+	// pool of the cluster. Disabled warm pools are all the same.
+	out.WarmPool = clusterSpec.WarmPool.ResolveDefaults(&kopsapi.InstanceGroup{Spec: *out}).DeepCopy()
+	if !out.WarmPool.IsEnabled() {
+		out.WarmPool = nil
+	}
+
+	p := out.MixedInstancesPolicy
+	if p == nil {
+		return out
+	}
+	if p.OnDemandAllocationStrategy == nil {
+		p.OnDemandAllocationStrategy = fi.String(mixedInstancesOnDemandAllocationStrategy)
+	}
+	if p.SpotAllocationStrategy == nil {
+		p.SpotAllocationStrategy = fi.String(mixedInstancesSpotAllocationStrategy)
+	}
+	if p.OnDemandBase == nil {
+		p.OnDemandBase = fi.Int64(0)
+	}
+	if p.OnDemandAboveBase == nil {
+		p.OnDemandAboveBase = fi.Int64(mixedInstancesOnDemandAboveBase)
+	}
+	if p.SpotInstancePools == nil {
+		p.SpotInstancePools = fi.Int64(mixedInstancesSpotInstancePools)
+	}
+	if len(p.Instances) == 0 {
+		p.Instances = nil
+	}
+	// The order of the instance types is their priority, which only matters
+	// to on-demand capacity and to prioritized spot capacity.
+	allSpot := *p.OnDemandBase == 0 && *p.OnDemandAboveBase == 0
+	if allSpot && *p.SpotAllocationStrategy != "capacity-optimized-prioritized" {
+		sort.Strings(p.Instances)
+	}
+	return out
+}
+
+// GetClusterStatus returns the cluster status
+func GetClusterStatus(kopsCluster *kopsapi.Cluster, cloud fi.Cloud) (*kopsapi.ClusterStatus, error) {
+	status, err := cloud.FindClusterStatus(kopsCluster)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// cloudAuthErrorCodes are the error codes with which cloud APIs reject
+// requests made with missing, invalid or insufficient credentials
+var cloudAuthErrorCodes = []string{
+	"AccessDenied",
+	"AuthFailure",
+	"ExpiredToken",
+	"InvalidClientTokenId",
+	"NoCredentialProviders",
+	"SignatureDoesNotMatch",
+	"UnauthorizedOperation",
+	"UnrecognizedClientException",
+}
+
+// IsCloudAuthError returns true if the supplied error is a cloud API
+// rejecting the provider's credentials. kops often formats the errors of
+// cloud APIs into its own, so their messages are matched rather than types
+func IsCloudAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, c := range cloudAuthErrorCodes {
+		if strings.Contains(err.Error(), c) {
+			return true
+		}
+	}
+	return false
 }
 
 // ErrNotFound is an error indicating that the resource was not found
 func ErrNotFound(err error) bool {
-	return strings.Contains(err.Error(), "not found")
+	return err != nil && strings.Contains(err.Error(), "not found")
 }
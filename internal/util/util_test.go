@@ -0,0 +1,3310 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/acm/acmiface"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/time/rate"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kops/cloudmock/aws/mockroute53"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/assets"
+	"k8s.io/kops/pkg/client/simple/vfsclientset"
+	"k8s.io/kops/pkg/cloudinstances"
+	"k8s.io/kops/pkg/kopscodecs"
+	"k8s.io/kops/pkg/pki"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/pkg/validation"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/util/pkg/vfs"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/metrics"
+)
+
+func TestGetServiceAccountIssuer(t *testing.T) {
+	type want struct {
+		issuer string
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason  string
+		cluster *kopsapi.Cluster
+		want    want
+	}{
+		"ExplicitIssuer": {
+			reason: "An issuer set on the kube-apiserver should be returned as is.",
+			cluster: &kopsapi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo.example.com"},
+				Spec: kopsapi.ClusterSpec{
+					KubeAPIServer: &kopsapi.KubeAPIServerConfig{
+						ServiceAccountIssuer: fi.String("https://issuer.example.com"),
+					},
+				},
+			},
+			want: want{issuer: "https://issuer.example.com"},
+		},
+		"PublicJWKS": {
+			reason: "Clusters with anonymous auth and a public API should use the public API name.",
+			cluster: &kopsapi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo.example.com"},
+				Spec: kopsapi.ClusterSpec{
+					KubeAPIServer: &kopsapi.KubeAPIServerConfig{
+						AnonymousAuth: fi.Bool(true),
+					},
+					KubernetesAPIAccess: []string{"0.0.0.0/0"},
+				},
+			},
+			want: want{issuer: "https://api.foo.example.com"},
+		},
+		"InternalName": {
+			reason: "Clusters without a public JWKS should use the internal API name.",
+			cluster: &kopsapi.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo.example.com"},
+			},
+			want: want{issuer: "https://api.internal.foo.example.com"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := GetServiceAccountIssuer(tc.cluster)
+			if diff := cmp.Diff(tc.want.err, err); diff != "" {
+				t.Errorf("\n%s\nGetServiceAccountIssuer(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.issuer, got); diff != "" {
+				t.Errorf("\n%s\nGetServiceAccountIssuer(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGenerateValidationObservation(t *testing.T) {
+	cases := map[string]struct {
+		reason     string
+		validation *validation.ValidationCluster
+		want       *v1alpha1.ValidationObservation
+	}{
+		"Healthy": {
+			reason: "A healthy cluster should report its nodes and no failures.",
+			validation: &validation.ValidationCluster{
+				Nodes: []*validation.ValidationNode{{Name: "a", Status: corev1.ConditionTrue}},
+			},
+			want: &v1alpha1.ValidationObservation{Nodes: 1},
+		},
+		"Unhealthy": {
+			reason: "Failures and nodes that are not ready should be reported.",
+			validation: &validation.ValidationCluster{
+				Failures: []*validation.ValidationError{{
+					Kind:          "InstanceGroup",
+					Name:          "nodes",
+					Message:       "InstanceGroup \"nodes\" did not have enough nodes 0 vs 1",
+					InstanceGroup: &kopsapi.InstanceGroup{ObjectMeta: metav1.ObjectMeta{Name: "nodes"}},
+				}},
+				Nodes: []*validation.ValidationNode{
+					{Name: "a", Status: corev1.ConditionTrue},
+					{Name: "b", Status: corev1.ConditionFalse},
+				},
+			},
+			want: &v1alpha1.ValidationObservation{
+				Failures: []v1alpha1.ValidationFailure{{
+					Kind:          "InstanceGroup",
+					Name:          "nodes",
+					Message:       "InstanceGroup \"nodes\" did not have enough nodes 0 vs 1",
+					InstanceGroup: "nodes",
+				}},
+				Nodes:         2,
+				NotReadyNodes: []string{"b"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := GenerateValidationObservation(tc.validation)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nGenerateValidationObservation(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestHashParameters(t *testing.T) {
+	base := v1alpha1.KopsParameters{Domain: "example.com", StateBucket: "s3://state", Region: "us-east-1"}
+	changed := base
+	changed.Region = "eu-west-1"
+
+	cases := map[string]struct {
+		reason string
+		a      v1alpha1.KopsParameters
+		b      v1alpha1.KopsParameters
+		equal  bool
+	}{
+		"Same": {
+			reason: "Identical parameters should hash to the same value.",
+			a:      base,
+			b:      base,
+			equal:  true,
+		},
+		"Changed": {
+			reason: "Changed parameters should hash to a different value.",
+			a:      base,
+			b:      changed,
+			equal:  false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			a, err := HashParameters(tc.a)
+			if err != nil {
+				t.Fatalf("\n%s\nHashParameters(...): %v", tc.reason, err)
+			}
+			b, err := HashParameters(tc.b)
+			if err != nil {
+				t.Fatalf("\n%s\nHashParameters(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.equal, a == b); diff != "" {
+				t.Errorf("\n%s\nHashParameters(...): -want equal, +got equal:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGenerateNodeReadiness(t *testing.T) {
+	igs := &kopsapi.InstanceGroupList{Items: []kopsapi.InstanceGroup{
+		{Spec: kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleMaster, MinSize: fi.Int32(1)}},
+		{Spec: kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleNode, MinSize: fi.Int32(3)}},
+		{Spec: kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleBastion, MinSize: fi.Int32(1)}},
+	}}
+
+	cases := map[string]struct {
+		reason     string
+		validation *validation.ValidationCluster
+		want       *v1alpha1.NodeReadiness
+	}{
+		"AllReady": {
+			reason: "Every desired node of each role should be reported as ready.",
+			validation: &validation.ValidationCluster{
+				Nodes: []*validation.ValidationNode{
+					{Name: "a", Role: "master", Status: corev1.ConditionTrue},
+					{Name: "b", Role: "node", Status: corev1.ConditionTrue},
+					{Name: "c", Role: "node", Status: corev1.ConditionTrue},
+					{Name: "d", Role: "node", Status: corev1.ConditionTrue},
+				},
+			},
+			want: &v1alpha1.NodeReadiness{
+				ControlPlane: v1alpha1.RoleReadiness{Ready: 1, Desired: 1, Summary: "1/1"},
+				Workers:      v1alpha1.RoleReadiness{Ready: 3, Desired: 3, Summary: "3/3"},
+			},
+		},
+		"NotReady": {
+			reason: "Nodes that are not ready should not be counted.",
+			validation: &validation.ValidationCluster{
+				Nodes: []*validation.ValidationNode{
+					{Name: "a", Role: "master", Status: corev1.ConditionTrue},
+					{Name: "b", Role: "node", Status: corev1.ConditionFalse},
+				},
+			},
+			want: &v1alpha1.NodeReadiness{
+				ControlPlane: v1alpha1.RoleReadiness{Ready: 1, Desired: 1, Summary: "1/1"},
+				Workers:      v1alpha1.RoleReadiness{Ready: 0, Desired: 3, Summary: "0/3"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := GenerateNodeReadiness(tc.validation, igs)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nGenerateNodeReadiness(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGenerateInfrastructureObservation(t *testing.T) {
+	cases := map[string]struct {
+		reason    string
+		resources map[string]*resources.Resource
+		want      *v1alpha1.InfrastructureObservation
+	}{
+		"Empty": {
+			reason:    "A cluster without resources should have no identifiers.",
+			resources: map[string]*resources.Resource{},
+			want:      &v1alpha1.InfrastructureObservation{},
+		},
+		"AWS": {
+			reason: "Identifiers should be extracted from the resources of an AWS cluster.",
+			resources: map[string]*resources.Resource{
+				"vpc:vpc-1":         {Type: "vpc", ID: "vpc-1"},
+				"subnet:subnet-2":   {Type: "subnet", ID: "subnet-2"},
+				"subnet:subnet-1":   {Type: "subnet", ID: "subnet-1"},
+				"security-group:sg": {Type: "security-group", ID: "sg-1"},
+				"load-balancer:api": {Type: "load-balancer", Name: "api-foo", ID: "arn:api", Obj: &elbv2.LoadBalancer{
+					LoadBalancerArn:       aws.String("arn:api"),
+					DNSName:               aws.String("api.elb.amazonaws.com"),
+					CanonicalHostedZoneId: aws.String("Z26RNL4JYFTOTI"),
+				}},
+				"load-balancer:ing": {Type: "load-balancer", Name: "ingress", ID: "arn:ing", Obj: &elbv2.LoadBalancer{DNSName: aws.String("ingress.elb.amazonaws.com")}},
+				"iam-instance-profile:nodes": {
+					Type: "iam-instance-profile",
+					ID:   "nodes.foo.example.com",
+					Obj:  &iam.InstanceProfile{Arn: aws.String("arn:aws:iam::123456789012:instance-profile/nodes.foo.example.com")},
+				},
+				"route53-record:api.internal": {
+					Type:     "route53-record",
+					GroupKey: "Z0123",
+					Obj:      &route53.ResourceRecordSet{Name: aws.String("api.internal.foo.example.com."), Type: aws.String("A")},
+				},
+				"route53-record:api": {
+					Type:     "route53-record",
+					GroupKey: "Z0123",
+					Obj:      &route53.ResourceRecordSet{Name: aws.String("api.foo.example.com."), Type: aws.String("A")},
+				},
+			},
+			want: &v1alpha1.InfrastructureObservation{
+				VPCID:                       "vpc-1",
+				SubnetIDs:                   []string{"subnet-1", "subnet-2"},
+				SecurityGroupIDs:            []string{"sg-1"},
+				APILoadBalancerDNSName:      "api.elb.amazonaws.com",
+				APILoadBalancerARN:          "arn:api",
+				APILoadBalancerHostedZoneID: "Z26RNL4JYFTOTI",
+				InstanceProfileARNs:         []string{"arn:aws:iam::123456789012:instance-profile/nodes.foo.example.com"},
+				DNSZoneID:                   "Z0123",
+				DNSRecords: []v1alpha1.DNSRecordObservation{
+					{Name: "api.foo.example.com", Type: "A"},
+					{Name: "api.internal.foo.example.com", Type: "A"},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := GenerateInfrastructureObservation(tc.resources)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nGenerateInfrastructureObservation(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLatestEtcdBackup(t *testing.T) {
+	type want struct {
+		name   string
+		latest *metav1.Time
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		files  []string
+		want   want
+	}{
+		"NoBackups": {
+			reason: "A backup store without backups should have no latest backup.",
+			files:  []string{"control/etcd-cluster-spec"},
+			want:   want{},
+		},
+		"Backups": {
+			reason: "The name and time of the most recent backup should be returned.",
+			files: []string{
+				"control/etcd-cluster-spec",
+				"2022-06-01T10:00:00Z-000001/etcd.backup.gz",
+				"2022-06-01T11:00:00Z-000002/etcd.backup.gz",
+				"2022-06-01T11:00:00Z-000002/_etcd_backup.meta",
+			},
+			want: want{
+				name:   "2022-06-01T11:00:00Z-000002",
+				latest: &metav1.Time{Time: time.Date(2022, 6, 1, 11, 0, 0, 0, time.UTC)},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store := vfs.NewMemFSPath(vfs.NewMemFSContext(), "backups/etcd/main")
+			for _, f := range tc.files {
+				if err := store.Join(f).WriteFile(bytes.NewReader([]byte{}), nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			name, got, err := latestEtcdBackup(store)
+			if diff := cmp.Diff(tc.want.err, err); diff != "" {
+				t.Errorf("\n%s\nlatestEtcdBackup(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.name, name); diff != "" {
+				t.Errorf("\n%s\nlatestEtcdBackup(...): -want name, +got name:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.latest, got); diff != "" {
+				t.Errorf("\n%s\nlatestEtcdBackup(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRecordClusterEvent(t *testing.T) {
+	kube := fake.NewSimpleClientset()
+	if err := RecordClusterEvent(context.Background(), kube, "StartedApply", "applying"); err != nil {
+		t.Fatalf("RecordClusterEvent(...): %v", err)
+	}
+
+	l, err := kube.CoreV1().Events(metav1.NamespaceSystem).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(1, len(l.Items)); diff != "" {
+		t.Fatalf("RecordClusterEvent(...): -want events, +got events:\n%s\n", diff)
+	}
+
+	got := l.Items[0]
+	want := corev1.ObjectReference{APIVersion: "v1", Kind: "Namespace", Name: metav1.NamespaceSystem}
+	if diff := cmp.Diff(want, got.InvolvedObject); diff != "" {
+		t.Errorf("RecordClusterEvent(...): -want involved object, +got involved object:\n%s\n", diff)
+	}
+	if diff := cmp.Diff("StartedApply", got.Reason); diff != "" {
+		t.Errorf("RecordClusterEvent(...): -want reason, +got reason:\n%s\n", diff)
+	}
+}
+
+func TestCopyDirToVFS(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"kubernetes.tf":        "provider \"aws\" {}",
+		"data/aws_launch_tmpl": "#!/bin/bash",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dest := vfs.NewMemFSPath(vfs.NewMemFSContext(), "terraform")
+	if err := CopyDirToVFS(dir, dest); err != nil {
+		t.Fatalf("CopyDirToVFS(...): %v", err)
+	}
+
+	for name, want := range files {
+		got, err := dest.Join(name).ReadFile()
+		if err != nil {
+			t.Fatalf("CopyDirToVFS(...): cannot read %s: %v", name, err)
+		}
+		if diff := cmp.Diff(want, string(got)); diff != "" {
+			t.Errorf("CopyDirToVFS(...): -want %s, +got %s:\n%s\n", name, name, diff)
+		}
+	}
+}
+
+func TestRenderClusterYAML(t *testing.T) {
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"}}
+	igs := []*kopsapi.InstanceGroup{
+		{ObjectMeta: metav1.ObjectMeta{Name: "master-us-east-1a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "nodes"}},
+	}
+
+	b, err := RenderClusterYAML(cluster, igs)
+	if err != nil {
+		t.Fatalf("RenderClusterYAML(...): %v", err)
+	}
+
+	var got []string
+	for _, doc := range bytes.Split(b, []byte("---\n")) {
+		for _, line := range bytes.Split(doc, []byte("\n")) {
+			if bytes.HasPrefix(line, []byte("kind: ")) {
+				got = append(got, string(bytes.TrimPrefix(line, []byte("kind: "))))
+			}
+		}
+	}
+	want := []string{"Cluster", "InstanceGroup", "InstanceGroup"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("RenderClusterYAML(...): -want kinds, +got kinds:\n%s\n", diff)
+	}
+}
+
+func TestCopyVFSTree(t *testing.T) {
+	ctx := vfs.NewMemFSContext()
+	src := vfs.NewMemFSPath(ctx, "state/test.example.com")
+	files := map[string]string{
+		"config":                    "apiVersion: kops.k8s.io/v1alpha2",
+		"instancegroup/nodes":       "kind: InstanceGroup",
+		"pki/issued/ca/keyset.yaml": "kind: Keyset",
+	}
+	for name, content := range files {
+		if err := src.Join(name).WriteFile(bytes.NewReader([]byte(content)), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dest := vfs.NewMemFSPath(ctx, "state/"+StateBackupDir+"/test.example.com/20220101T000000Z")
+	if err := CopyVFSTree(src, dest); err != nil {
+		t.Fatalf("CopyVFSTree(...): %v", err)
+	}
+
+	for name, want := range files {
+		got, err := dest.Join(name).ReadFile()
+		if err != nil {
+			t.Fatalf("CopyVFSTree(...): cannot read %s: %v", name, err)
+		}
+		if diff := cmp.Diff(want, string(got)); diff != "" {
+			t.Errorf("CopyVFSTree(...): -want %s, +got %s:\n%s\n", name, name, diff)
+		}
+	}
+}
+
+func TestParseEtcdRestoreRequest(t *testing.T) {
+	cluster := &kopsapi.Cluster{
+		Spec: kopsapi.ClusterSpec{
+			EtcdClusters: []kopsapi.EtcdClusterSpec{{Name: "main"}, {Name: "events"}},
+		},
+	}
+
+	type want struct {
+		backups map[string]string
+		err     error
+	}
+
+	cases := map[string]struct {
+		reason string
+		req    string
+		want   want
+	}{
+		"AllClusters": {
+			reason: "A bare backup name should be restored to every etcd cluster.",
+			req:    "latest",
+			want:   want{backups: map[string]string{"main": "latest", "events": "latest"}},
+		},
+		"PerCluster": {
+			reason: "cluster=backup pairs should be restored to the named etcd clusters.",
+			req:    "main=2022-06-01T11:00:00Z-000002, events=latest",
+			want:   want{backups: map[string]string{"main": "2022-06-01T11:00:00Z-000002", "events": "latest"}},
+		},
+		"UnknownCluster": {
+			reason: "Naming an etcd cluster the kops cluster doesn't have should return an error.",
+			req:    "cilium=latest",
+			want:   want{err: errors.Errorf("unknown etcd cluster %q", "cilium")},
+		},
+		"Empty": {
+			reason: "A request without backups should return an error.",
+			req:    " , ",
+			want:   want{err: errors.New("no backups requested")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseEtcdRestoreRequest(tc.req, cluster)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseEtcdRestoreRequest(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.backups, got); diff != "" {
+				t.Errorf("\n%s\nParseEtcdRestoreRequest(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInfrastructureMissing(t *testing.T) {
+	igs := &kopsapi.InstanceGroupList{Items: []kopsapi.InstanceGroup{
+		{ObjectMeta: metav1.ObjectMeta{Name: "master"}, Spec: kopsapi.InstanceGroupSpec{MinSize: fi.Int32(1)}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "nodes"}, Spec: kopsapi.InstanceGroupSpec{MinSize: fi.Int32(2)}},
+	}}
+
+	cases := map[string]struct {
+		reason string
+		igs    *kopsapi.InstanceGroupList
+		groups map[string]*cloudinstances.CloudInstanceGroup
+		want   bool
+	}{
+		"NoGroups": {
+			reason: "A cluster whose cloud groups are all gone should be missing its infrastructure.",
+			igs:    igs,
+			groups: map[string]*cloudinstances.CloudInstanceGroup{},
+			want:   true,
+		},
+		"EmptyGroups": {
+			reason: "A cluster whose cloud groups have no instances should be missing its infrastructure.",
+			igs:    igs,
+			groups: map[string]*cloudinstances.CloudInstanceGroup{"nodes": {}},
+			want:   true,
+		},
+		"Instances": {
+			reason: "A cluster with an instance should not be missing its infrastructure.",
+			igs:    igs,
+			groups: map[string]*cloudinstances.CloudInstanceGroup{
+				"nodes": {NeedUpdate: []*cloudinstances.CloudInstance{{ID: "i-0123"}}},
+			},
+			want: false,
+		},
+		"ScaledToZero": {
+			reason: "A cluster that should have no instances should not be missing its infrastructure.",
+			igs: &kopsapi.InstanceGroupList{Items: []kopsapi.InstanceGroup{
+				{ObjectMeta: metav1.ObjectMeta{Name: "nodes"}, Spec: kopsapi.InstanceGroupSpec{MinSize: fi.Int32(0)}},
+			}},
+			groups: map[string]*cloudinstances.CloudInstanceGroup{},
+			want:   false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := InfrastructureMissing(tc.igs, tc.groups)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nInfrastructureMissing(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCheckClusterDNS(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	r53 := &mockroute53.MockRoute53{}
+	r53.MockCreateZone(&route53.HostedZone{Id: aws.String("/hostedzone/Z0123"), Name: aws.String("example.com.")}, nil)
+	cloud := awsup.BuildMockAWSCloud("us-east-1", "a")
+	cloud.MockRoute53 = r53
+
+	cluster := func(name string, dns kopsapi.DNSType) *kopsapi.Cluster {
+		c := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if dns != "" {
+			c.Spec.Topology = &kopsapi.TopologySpec{DNS: &kopsapi.DNSSpec{Type: dns}}
+		}
+		return c
+	}
+	resolves := func(string) ([]*net.NS, error) { return []*net.NS{{Host: "ns-1.awsdns-01.com."}}, nil }
+
+	cases := map[string]struct {
+		reason   string
+		cluster  *kopsapi.Cluster
+		domain   string
+		cloud    fi.Cloud
+		lookupNS func(string) ([]*net.NS, error)
+		want     error
+	}{
+		"Gossip": {
+			reason:  "A gossip cluster has no hosted zone to check.",
+			cluster: cluster("test.k8s.local", ""),
+			domain:  "k8s.local",
+		},
+		"Private": {
+			reason:  "A cluster using a private hosted zone can't be resolved from outside its network.",
+			cluster: cluster("test.example.com", kopsapi.DNSTypePrivate),
+			domain:  "example.com",
+		},
+		"ZoneMissing": {
+			reason:   "A cluster whose hosted zone does not exist should return an error.",
+			cluster:  cluster("test.example.org", ""),
+			domain:   "example.org",
+			cloud:    cloud,
+			lookupNS: resolves,
+			want:     errors.New(`cannot find DNS zone "example.org"`),
+		},
+		"Unresolvable": {
+			reason:   "A cluster whose hosted zone does not resolve should return an error.",
+			cluster:  cluster("test.example.com", ""),
+			domain:   "example.com",
+			cloud:    cloud,
+			lookupNS: func(string) ([]*net.NS, error) { return nil, errBoom },
+			want:     errors.Wrap(errBoom, `cannot resolve name servers of DNS zone "example.com"`),
+		},
+		"Resolvable": {
+			reason:   "A cluster whose hosted zone exists and resolves should pass.",
+			cluster:  cluster("test.example.com", kopsapi.DNSTypePublic),
+			domain:   "example.com",
+			cloud:    cloud,
+			lookupNS: resolves,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := CheckClusterDNS(tc.cluster, tc.domain, tc.cloud, tc.lookupNS)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nCheckClusterDNS(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetUnavailableAssets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/release/v1.23.5/bin/linux/amd64/kubelet" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	mustParse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	files := []*assets.FileAsset{
+		{
+			DownloadURL:  mustParse(srv.URL + "/release/v1.23.5/bin/linux/amd64/kubelet"),
+			CanonicalURL: mustParse("https://storage.googleapis.com/kubernetes-release/release/v1.23.5/bin/linux/amd64/kubelet"),
+		},
+		{
+			DownloadURL:  mustParse(srv.URL + "/release/v1.23.5/bin/linux/amd64/kubectl"),
+			CanonicalURL: mustParse("https://storage.googleapis.com/kubernetes-release/release/v1.23.5/bin/linux/amd64/kubectl"),
+		},
+		{
+			// Assets that weren't remapped to a mirror aren't checked.
+			DownloadURL:  mustParse("https://artifacts.k8s.io/binaries/kops/1.23.2/linux/amd64/nodeup"),
+			CanonicalURL: mustParse("https://artifacts.k8s.io/binaries/kops/1.23.2/linux/amd64/nodeup"),
+		},
+	}
+
+	got := GetUnavailableAssets(context.Background(), srv.Client(), files, nil)
+	want := []string{srv.URL + "/release/v1.23.5/bin/linux/amd64/kubectl"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetUnavailableAssets(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestImageManifestURL(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		image  string
+		want   string
+	}{
+		"Registry": {
+			reason: "An image in a registry should be looked up in that registry.",
+			image:  "registry.example.com:5000/kube-apiserver:v1.23.5",
+			want:   "https://registry.example.com:5000/v2/kube-apiserver/manifests/v1.23.5",
+		},
+		"Digest": {
+			reason: "An image pinned to a digest should be looked up by digest.",
+			image:  "registry.example.com/kops/kops-controller@sha256:0123",
+			want:   "https://registry.example.com/v2/kops/kops-controller/manifests/sha256:0123",
+		},
+		"DockerHub": {
+			reason: "An image without a registry should be looked up in Docker Hub.",
+			image:  "calico/node:v3.21.4",
+			want:   "https://registry-1.docker.io/v2/calico/node/manifests/v3.21.4",
+		},
+		"Official": {
+			reason: "An official Docker Hub image should be looked up in the library namespace.",
+			image:  "busybox",
+			want:   "https://registry-1.docker.io/v2/library/busybox/manifests/latest",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := imageManifestURL(tc.image)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nimageManifestURL(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+type mockSTS struct {
+	stsiface.STSAPI
+	arn string
+}
+
+func (m *mockSTS) GetCallerIdentity(*sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Arn: aws.String(m.arn)}, nil
+}
+
+type mockIAM struct {
+	iamiface.IAMAPI
+	allowed map[string]bool
+	source  string
+}
+
+func (m *mockIAM) SimulatePrincipalPolicyPages(in *iam.SimulatePrincipalPolicyInput, fn func(*iam.SimulatePolicyResponse, bool) bool) error {
+	m.source = aws.StringValue(in.PolicySourceArn)
+	out := &iam.SimulatePolicyResponse{}
+	for _, a := range in.ActionNames {
+		d := iam.PolicyEvaluationDecisionTypeImplicitDeny
+		if m.allowed[aws.StringValue(a)] {
+			d = iam.PolicyEvaluationDecisionTypeAllowed
+		}
+		out.EvaluationResults = append(out.EvaluationResults, &iam.EvaluationResult{EvalActionName: a, EvalDecision: aws.String(d)})
+	}
+	fn(out, true)
+	return nil
+}
+
+func TestGetMissingAWSPermissions(t *testing.T) {
+	type want struct {
+		missing []string
+		source  string
+	}
+
+	cases := map[string]struct {
+		reason  string
+		arn     string
+		allowed map[string]bool
+		want    want
+	}{
+		"AssumedRole": {
+			reason:  "The role of an assumed role session should be simulated.",
+			arn:     "arn:aws:sts::123456789012:assumed-role/provider-kops/session",
+			allowed: map[string]bool{"ec2:RunInstances": true},
+			want: want{
+				missing: []string{"iam:PassRole"},
+				source:  "arn:aws:iam::123456789012:role/provider-kops",
+			},
+		},
+		"User": {
+			reason:  "A user should be simulated as is.",
+			arn:     "arn:aws:iam::123456789012:user/provider-kops",
+			allowed: map[string]bool{"ec2:RunInstances": true, "iam:PassRole": true},
+			want: want{
+				source: "arn:aws:iam::123456789012:user/provider-kops",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			i := &mockIAM{allowed: tc.allowed}
+			got, err := GetMissingAWSPermissions(&mockSTS{arn: tc.arn}, i, []string{"ec2:RunInstances", "iam:PassRole"})
+			if err != nil {
+				t.Fatalf("GetMissingAWSPermissions(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want.missing, got); diff != "" {
+				t.Errorf("\n%s\nGetMissingAWSPermissions(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.source, i.source); diff != "" {
+				t.Errorf("\n%s\nGetMissingAWSPermissions(...): -want policy source, +got policy source:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+type mockScalingActivities struct {
+	autoscalingiface.AutoScalingAPI
+	activities map[string]*autoscaling.Activity
+}
+
+func (m *mockScalingActivities) DescribeScalingActivities(in *autoscaling.DescribeScalingActivitiesInput) (*autoscaling.DescribeScalingActivitiesOutput, error) {
+	out := &autoscaling.DescribeScalingActivitiesOutput{}
+	if a, ok := m.activities[aws.StringValue(in.AutoScalingGroupName)]; ok {
+		out.Activities = []*autoscaling.Activity{a}
+	}
+	return out, nil
+}
+
+func TestGetCapacityFailures(t *testing.T) {
+	noCapacity := "Could not launch Spot Instances. InsufficientInstanceCapacity - There is no Spot capacity available that matches your request. Launching EC2 instance failed."
+	groups := map[string]*cloudinstances.CloudInstanceGroup{
+		"nodes-a": {HumanName: "nodes-a.test.example.com"},
+		"nodes-b": {HumanName: "nodes-b.test.example.com"},
+		"nodes-c": {HumanName: "nodes-c.test.example.com"},
+		"nodes-d": {HumanName: "nodes-d.test.example.com"},
+	}
+	as := &mockScalingActivities{activities: map[string]*autoscaling.Activity{
+		"nodes-a.test.example.com": {StatusCode: aws.String(autoscaling.ScalingActivityStatusCodeFailed), StatusMessage: aws.String(noCapacity)},
+		"nodes-b.test.example.com": {StatusCode: aws.String(autoscaling.ScalingActivityStatusCodeSuccessful), StatusMessage: aws.String(noCapacity)},
+		"nodes-c.test.example.com": {StatusCode: aws.String(autoscaling.ScalingActivityStatusCodeFailed), StatusMessage: aws.String("The requested configuration is currently not supported.")},
+	}}
+	want := map[string]string{"nodes-a": noCapacity}
+
+	got, err := GetCapacityFailures(as, groups)
+	if err != nil {
+		t.Fatalf("GetCapacityFailures(...): %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("\nOnly groups whose last activity failed for lack of capacity should be returned.\nGetCapacityFailures(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestApplyOnDemandFallback(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		spec   kopsapi.InstanceGroupSpec
+		spot   bool
+		want   kopsapi.InstanceGroupSpec
+	}{
+		"OnDemand": {
+			reason: "An instance group without a max price should launch on-demand instances.",
+			spec:   kopsapi.InstanceGroupSpec{MachineType: "m5.large"},
+			want:   kopsapi.InstanceGroupSpec{MachineType: "m5.large"},
+		},
+		"MaxPrice": {
+			reason: "An instance group with a max price should fall back by dropping it.",
+			spec:   kopsapi.InstanceGroupSpec{MachineType: "m5.large", MaxPrice: aws.String("0.1")},
+			spot:   true,
+			want:   kopsapi.InstanceGroupSpec{MachineType: "m5.large"},
+		},
+		"MixedInstancesPolicy": {
+			reason: "An instance group with a mixed instances policy should fall back to launching on-demand instances only.",
+			spec: kopsapi.InstanceGroupSpec{MixedInstancesPolicy: &kopsapi.MixedInstancesPolicySpec{
+				Instances:         []string{"m5.large", "m5a.large"},
+				OnDemandAboveBase: fi.Int64(0),
+			}},
+			spot: true,
+			want: kopsapi.InstanceGroupSpec{MixedInstancesPolicy: &kopsapi.MixedInstancesPolicySpec{
+				Instances:         []string{"m5.large", "m5a.large"},
+				OnDemandAboveBase: fi.Int64(100),
+			}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := IsSpotInstanceGroup(&tc.spec); got != tc.spot {
+				t.Errorf("\n%s\nIsSpotInstanceGroup(...): want %t, got %t\n", tc.reason, tc.spot, got)
+			}
+			got := ApplyOnDemandFallback(&tc.spec)
+			if diff := cmp.Diff(&tc.want, got); diff != "" {
+				t.Errorf("\n%s\nApplyOnDemandFallback(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if IsSpotInstanceGroup(got) {
+				t.Errorf("\n%s\nApplyOnDemandFallback(...): want an on-demand instance group\n", tc.reason)
+			}
+		})
+	}
+}
+
+func TestWriteAddons(t *testing.T) {
+	store := vfs.NewMemFSPath(vfs.NewMemFSContext(), "state")
+	cs := vfsclientset.NewVFSClientset(store)
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"}}
+	dir := store.Join("test.example.com", "addons", "provider-kops")
+
+	steps := []struct {
+		reason    string
+		addons    []v1alpha1.AddonParameters
+		manifests map[string]string
+	}{
+		{
+			reason: "Declared addons should be written alongside a channel listing them.",
+			addons: []v1alpha1.AddonParameters{
+				{Name: "foo", Manifest: "kind: ConfigMap"},
+				{Name: "bar", Manifest: "kind: Secret"},
+			},
+			manifests: map[string]string{"foo.yaml": "kind: ConfigMap", "bar.yaml": "kind: Secret"},
+		},
+		{
+			reason: "Addons that are no longer declared should be removed.",
+			addons: []v1alpha1.AddonParameters{
+				{Name: "foo", Manifest: "kind: Namespace"},
+			},
+			manifests: map[string]string{"foo.yaml": "kind: Namespace"},
+		},
+		{
+			reason: "The channel should be removed once no addons are declared.",
+		},
+	}
+
+	for i, s := range steps {
+		if ok, err := AddonsUpToDate(cs, cluster, s.addons); err != nil || ok {
+			t.Fatalf("\n%s\nstep %d: AddonsUpToDate(...) before writing: got %t, %v", s.reason, i, ok, err)
+		}
+		if err := WriteAddons(cs, cluster, s.addons); err != nil {
+			t.Fatalf("\n%s\nstep %d: WriteAddons(...): %v", s.reason, i, err)
+		}
+		if ok, err := AddonsUpToDate(cs, cluster, s.addons); err != nil || !ok {
+			t.Errorf("\n%s\nstep %d: AddonsUpToDate(...) after writing: got %t, %v", s.reason, i, ok, err)
+		}
+
+		got := map[string]string{}
+		paths, _ := dir.ReadDir()
+		for _, p := range paths {
+			if b, err := p.ReadFile(); err == nil && p.Base() != "addons.yaml" {
+				got[p.Base()] = string(b)
+			}
+		}
+		want := s.manifests
+		if want == nil {
+			want = map[string]string{}
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("\n%s\nstep %d: WriteAddons(...): -want, +got:\n%s\n", s.reason, i, diff)
+		}
+	}
+}
+
+func TestRenderAddons(t *testing.T) {
+	channel, _, err := RenderAddons([]v1alpha1.AddonParameters{{Name: "foo", Manifest: "kind: ConfigMap"}})
+	if err != nil {
+		t.Fatalf("RenderAddons(...): %v", err)
+	}
+	want := `kind: Addons
+metadata:
+  creationTimestamp: null
+  name: provider-kops
+spec:
+  addons:
+  - manifest: foo.yaml
+    manifestHash: 033792b59cbccfc2b5b25b845d8d551f8c7dbdae67e823a5d8ca9af515e32d1b
+    name: foo
+    selector:
+      k8s-addon: foo
+`
+	if diff := cmp.Diff(want, string(channel)); diff != "" {
+		t.Errorf("RenderAddons(...): -want, +got:\n%s\n", diff)
+	}
+
+	if _, _, err := RenderAddons([]v1alpha1.AddonParameters{{Name: "foo"}, {Name: "foo"}}); err == nil {
+		t.Errorf("RenderAddons(...): expected an error for an addon declared twice")
+	}
+}
+
+type mockACM struct {
+	acmiface.ACMAPI
+	status string
+}
+
+func (m *mockACM) DescribeCertificate(in *acm.DescribeCertificateInput) (*acm.DescribeCertificateOutput, error) {
+	return &acm.DescribeCertificateOutput{Certificate: &acm.CertificateDetail{CertificateArn: in.CertificateArn, Status: aws.String(m.status)}}, nil
+}
+
+func TestCheckACMCertificateIssued(t *testing.T) {
+	arn := "arn:aws:acm:us-east-1:123456789012:certificate/api"
+
+	cases := map[string]struct {
+		reason  string
+		status  string
+		want    error
+		pending bool
+	}{
+		"Issued": {
+			reason: "An issued certificate should pass.",
+			status: acm.CertificateStatusIssued,
+		},
+		"Pending": {
+			reason:  "A certificate pending validation should return an error that it is pending.",
+			status:  acm.CertificateStatusPendingValidation,
+			want:    errors.Errorf("certificate %s is PENDING_VALIDATION, not ISSUED", arn),
+			pending: true,
+		},
+		"Failed": {
+			reason: "A certificate that failed validation should return an error that it is not pending.",
+			status: acm.CertificateStatusFailed,
+			want:   errors.Errorf("certificate %s is FAILED, not ISSUED", arn),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := CheckACMCertificateIssued(&mockACM{status: tc.status}, arn)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nCheckACMCertificateIssued(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if got := IsCertificatePending(errors.Wrap(err, "cannot check")); got != tc.pending {
+				t.Errorf("\n%s\nIsCertificatePending(...): want %t, got %t\n", tc.reason, tc.pending, got)
+			}
+		})
+	}
+}
+
+type mockS3 struct {
+	s3iface.S3API
+	headErr       error
+	encryptionErr error
+	calls         []string
+	location      string
+}
+
+func (m *mockS3) HeadBucket(*s3.HeadBucketInput) (*s3.HeadBucketOutput, error) {
+	m.calls = append(m.calls, "HeadBucket")
+	return &s3.HeadBucketOutput{}, m.headErr
+}
+
+func (m *mockS3) CreateBucket(in *s3.CreateBucketInput) (*s3.CreateBucketOutput, error) {
+	m.calls = append(m.calls, "CreateBucket")
+	if in.CreateBucketConfiguration != nil {
+		m.location = aws.StringValue(in.CreateBucketConfiguration.LocationConstraint)
+	}
+	return &s3.CreateBucketOutput{}, nil
+}
+
+func (m *mockS3) PutBucketVersioning(*s3.PutBucketVersioningInput) (*s3.PutBucketVersioningOutput, error) {
+	m.calls = append(m.calls, "PutBucketVersioning")
+	return &s3.PutBucketVersioningOutput{}, nil
+}
+
+func (m *mockS3) PutBucketEncryption(*s3.PutBucketEncryptionInput) (*s3.PutBucketEncryptionOutput, error) {
+	m.calls = append(m.calls, "PutBucketEncryption")
+	return &s3.PutBucketEncryptionOutput{}, m.encryptionErr
+}
+
+func (m *mockS3) PutPublicAccessBlock(*s3.PutPublicAccessBlockInput) (*s3.PutPublicAccessBlockOutput, error) {
+	m.calls = append(m.calls, "PutPublicAccessBlock")
+	return &s3.PutPublicAccessBlockOutput{}, nil
+}
+
+func (m *mockS3) DeleteBucket(*s3.DeleteBucketInput) (*s3.DeleteBucketOutput, error) {
+	m.calls = append(m.calls, "DeleteBucket")
+	return &s3.DeleteBucketOutput{}, nil
+}
+
+func TestProvisionStateBucket(t *testing.T) {
+	errBoom := errors.New("boom")
+	notFound := awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), http.StatusNotFound, "")
+	forbidden := awserr.NewRequestFailure(awserr.New("Forbidden", "Forbidden", nil), http.StatusForbidden, "")
+
+	type want struct {
+		created  bool
+		err      error
+		calls    []string
+		location string
+	}
+
+	cases := map[string]struct {
+		reason string
+		s3     *mockS3
+		region string
+		want   want
+	}{
+		"Exists": {
+			reason: "An existing bucket should be left as it is.",
+			s3:     &mockS3{},
+			region: "eu-west-1",
+			want:   want{calls: []string{"HeadBucket"}},
+		},
+		"Forbidden": {
+			reason: "A bucket that can't be looked up should be an error rather than created.",
+			s3:     &mockS3{headErr: forbidden},
+			region: "eu-west-1",
+			want:   want{err: errors.Wrap(forbidden, "cannot look up state bucket"), calls: []string{"HeadBucket"}},
+		},
+		"Created": {
+			reason: "A missing bucket should be created in the supplied region and configured.",
+			s3:     &mockS3{headErr: notFound},
+			region: "eu-west-1",
+			want: want{
+				created:  true,
+				calls:    []string{"HeadBucket", "CreateBucket", "PutBucketVersioning", "PutBucketEncryption", "PutPublicAccessBlock"},
+				location: "eu-west-1",
+			},
+		},
+		"CreatedInUSEast1": {
+			reason: "A bucket in us-east-1 should be created without a location constraint.",
+			s3:     &mockS3{headErr: notFound},
+			region: "us-east-1",
+			want: want{
+				created: true,
+				calls:   []string{"HeadBucket", "CreateBucket", "PutBucketVersioning", "PutBucketEncryption", "PutPublicAccessBlock"},
+			},
+		},
+		"ConfigureFailed": {
+			reason: "A bucket that can't be configured should be deleted so that it is created again.",
+			s3:     &mockS3{headErr: notFound, encryptionErr: errBoom},
+			region: "eu-west-1",
+			want: want{
+				err:      errors.Wrap(errBoom, "cannot enable encryption of state bucket"),
+				calls:    []string{"HeadBucket", "CreateBucket", "PutBucketVersioning", "PutBucketEncryption", "DeleteBucket"},
+				location: "eu-west-1",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			created, err := ProvisionStateBucket(tc.s3, "kops-state", tc.region)
+			got := want{created: created, err: err, calls: tc.s3.calls, location: tc.s3.location}
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors(), cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nProvisionStateBucket(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetS3StateBucketName(t *testing.T) {
+	cases := map[string]string{
+		"s3://kops-state":        "kops-state",
+		"s3://kops-state/prefix": "kops-state",
+		"gs://kops-state":        "",
+		"memfs://state":          "",
+	}
+	for in, want := range cases {
+		if got := GetS3StateBucketName(in); got != want {
+			t.Errorf("GetS3StateBucketName(%q): want %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestKopsVersionSkewed(t *testing.T) {
+	type want struct {
+		skewed bool
+		err    bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		state  string
+		want   want
+	}{
+		"NeverApplied": {
+			reason: "State without a recorded version should not be skewed.",
+		},
+		"Same": {
+			reason: "State written by the same version should not be skewed.",
+			state:  "1.23.2",
+		},
+		"Older": {
+			reason: "State written by an older version should not be skewed.",
+			state:  "1.22.6",
+		},
+		"NewerPatch": {
+			reason: "State written by a newer patch version should be skewed.",
+			state:  "1.23.4",
+			want:   want{skewed: true},
+		},
+		"NewerMinor": {
+			reason: "State written by a newer minor version should be skewed.",
+			state:  "1.24.0-beta.1",
+			want:   want{skewed: true},
+		},
+		"Invalid": {
+			reason: "An unparseable version should return an error.",
+			state:  "unknown",
+			want:   want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := KopsVersionSkewed(tc.state, "1.23.2")
+			if diff := cmp.Diff(tc.want, want{skewed: got, err: err != nil}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nKopsVersionSkewed(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRateLimitAWSCloud(t *testing.T) {
+	client := ec2.New(session.Must(session.NewSession(aws.NewConfig().WithRegion("us-east-1"))))
+	before := client.Handlers.Send.Len()
+
+	cloud := awsup.BuildMockAWSCloud("us-east-1", "a")
+	cloud.MockEC2 = client
+
+	// kops shares clients between the clouds of a region, so limiting a
+	// cloud repeatedly must not stack limiters on its clients.
+	RateLimitAWSCloud(cloud, rate.NewLimiter(1, 1))
+	RateLimitAWSCloud(cloud, rate.NewLimiter(2, 1))
+
+	if diff := cmp.Diff(before+1, client.Handlers.Send.Len()); diff != "" {
+		t.Errorf("\nRateLimitAWSCloud(...): -want send handlers, +got send handlers:\n%s\n", diff)
+	}
+}
+
+func TestNewAWSSession(t *testing.T) {
+	creds := credentials.NewStaticCredentials("id", "secret", "")
+	client := ec2.New(session.Must(session.NewSession(aws.NewConfig().WithRegion("eu-west-1").WithCredentials(creds))))
+
+	cases := map[string]struct {
+		reason   string
+		ec2      ec2iface.EC2API
+		limiter  *rate.Limiter
+		handlers int
+		err      bool
+	}{
+		"NoClient": {
+			reason: "A cloud without a real EC2 client has no configuration to share.",
+			ec2:    &mockEC2{},
+			err:    true,
+		},
+		"Unlimited": {
+			reason: "A session should share the region and credentials of the cloud.",
+			ec2:    client,
+		},
+		"Limited": {
+			reason:   "A session should be rate limited by the supplied limiter.",
+			ec2:      client,
+			limiter:  rate.NewLimiter(1, 1),
+			handlers: 1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cloud := awsup.BuildMockAWSCloud("eu-west-1", "a")
+			cloud.MockEC2 = tc.ec2
+			before := session.Must(session.NewSession()).Handlers.Send.Len()
+
+			sess, err := NewAWSSession(cloud, tc.limiter)
+			if diff := cmp.Diff(tc.err, err != nil); diff != "" {
+				t.Fatalf("\n%s\nNewAWSSession(...): -want error, +got error:\n%s\n", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff("eu-west-1", aws.StringValue(sess.Config.Region)); diff != "" {
+				t.Errorf("\n%s\nNewAWSSession(...): -want region, +got region:\n%s\n", tc.reason, diff)
+			}
+			if sess.Config.Credentials != creds {
+				t.Errorf("\n%s\nNewAWSSession(...): want the credentials of the cloud\n", tc.reason)
+			}
+			if diff := cmp.Diff(before+tc.handlers, sess.Handlers.Send.Len()); diff != "" {
+				t.Errorf("\n%s\nNewAWSSession(...): -want send handlers, +got send handlers:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestClusterEverApplied(t *testing.T) {
+	store := vfs.NewMemFSPath(vfs.NewMemFSContext(), "state")
+	cs := vfsclientset.NewVFSClientset(store)
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"}}
+
+	if applied, err := ClusterEverApplied(cs, cluster); err != nil || applied {
+		t.Errorf("\nA cluster that was only registered should never have been applied.\nClusterEverApplied(...): got %t, %v\n", applied, err)
+	}
+
+	if err := MarkApplyStarted(cs, cluster, time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("MarkApplyStarted(...): %v", err)
+	}
+	if applied, err := ClusterEverApplied(cs, cluster); err != nil || !applied {
+		t.Errorf("\nA cluster the provider started applying should have been applied.\nClusterEverApplied(...): got %t, %v\n", applied, err)
+	}
+
+	imported := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "imported.example.com"}}
+	if err := store.Join("imported.example.com", "kops-version.txt").WriteFile(bytes.NewReader([]byte("1.23.2")), nil); err != nil {
+		t.Fatal(err)
+	}
+	if applied, err := ClusterEverApplied(cs, imported); err != nil || !applied {
+		t.Errorf("\nA cluster applied by kops itself should have been applied.\nClusterEverApplied(...): got %t, %v\n", applied, err)
+	}
+}
+
+func TestApplyInProgress(t *testing.T) {
+	cs := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"}}
+
+	if got, err := GetApplyInProgress(cs, cluster); err != nil || got != nil {
+		t.Errorf("\nA cluster that was never applied should have no apply in progress.\nGetApplyInProgress(...): got %v, %v\n", got, err)
+	}
+
+	want := v1alpha1.ApplyRecord{
+		Operation:   "update",
+		Generation:  3,
+		StartedTime: metav1.NewTime(time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)),
+	}
+	if err := MarkApplyInProgress(cs, cluster, want); err != nil {
+		t.Fatalf("MarkApplyInProgress(...): %v", err)
+	}
+	got, err := GetApplyInProgress(cs, cluster)
+	if err != nil {
+		t.Fatalf("GetApplyInProgress(...): %v", err)
+	}
+	if diff := cmp.Diff(&want, got); diff != "" {
+		t.Errorf("\nGetApplyInProgress(...): -want, +got:\n%s\n", diff)
+	}
+
+	if err := ClearApplyInProgress(cs, cluster); err != nil {
+		t.Fatalf("ClearApplyInProgress(...): %v", err)
+	}
+	if got, err := GetApplyInProgress(cs, cluster); err != nil || got != nil {
+		t.Errorf("\nA completed apply should no longer be in progress.\nGetApplyInProgress(...): got %v, %v\n", got, err)
+	}
+}
+
+func TestValidateInstanceGroupSpecs(t *testing.T) {
+	clusterSpec := &kopsapi.ClusterSpec{Subnets: []kopsapi.ClusterSubnetSpec{{Name: "a"}, {Name: "b"}}}
+	ig := func(name string, role kopsapi.InstanceGroupRole, subnets ...string) kopsapi.InstanceGroupSpec {
+		return kopsapi.InstanceGroupSpec{NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: name}, Role: role, Subnets: subnets}
+	}
+	path := field.NewPath("instanceGroupSpec")
+
+	cases := map[string]struct {
+		reason string
+		igs    []kopsapi.InstanceGroupSpec
+		want   field.ErrorList
+	}{
+		"Valid": {
+			reason: "Named instance groups in the cluster's subnets, with a control plane, should be valid.",
+			igs:    []kopsapi.InstanceGroupSpec{ig("master-a", kopsapi.InstanceGroupRoleMaster, "a"), ig("nodes", kopsapi.InstanceGroupRoleNode, "a", "b")},
+			want:   field.ErrorList{},
+		},
+		"Unnamed": {
+			reason: "An instance group without a name label should be invalid.",
+			igs:    []kopsapi.InstanceGroupSpec{ig("master-a", kopsapi.InstanceGroupRoleMaster, "a"), ig("", kopsapi.InstanceGroupRoleNode, "a")},
+			want: field.ErrorList{
+				field.Required(path.Index(1).Child("nodeLabels").Key(kopsapi.NodeLabelInstanceGroup), "instance groups are named by this label"),
+			},
+		},
+		"Duplicate": {
+			reason: "Instance groups sharing a name should be invalid.",
+			igs:    []kopsapi.InstanceGroupSpec{ig("master-a", kopsapi.InstanceGroupRoleMaster, "a"), ig("master-a", kopsapi.InstanceGroupRoleNode, "a")},
+			want: field.ErrorList{
+				field.Duplicate(path.Index(1).Child("nodeLabels").Key(kopsapi.NodeLabelInstanceGroup), "master-a"),
+			},
+		},
+		"NoControlPlane": {
+			reason: "A cluster without control plane instance groups should be invalid.",
+			igs:    []kopsapi.InstanceGroupSpec{ig("nodes", kopsapi.InstanceGroupRoleNode, "a")},
+			want: field.ErrorList{
+				field.Required(path, "at least one instance group must have the Master role"),
+			},
+		},
+		"UnknownSubnet": {
+			reason: "An instance group in a subnet the cluster doesn't have should be invalid.",
+			igs:    []kopsapi.InstanceGroupSpec{ig("master-a", kopsapi.InstanceGroupRoleMaster, "a"), ig("nodes", kopsapi.InstanceGroupRoleNode, "b", "c")},
+			want: field.ErrorList{
+				field.NotFound(path.Index(1).Child("subnets").Index(1), "c"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ValidateInstanceGroupSpecs(clusterSpec, tc.igs, path)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nValidateInstanceGroupSpecs(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDefaultClusterSpec(t *testing.T) {
+	ig := func(name string, role kopsapi.InstanceGroupRole) kopsapi.InstanceGroupSpec {
+		return kopsapi.InstanceGroupSpec{NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: name}, Role: role}
+	}
+
+	type args struct {
+		clusterSpec *kopsapi.ClusterSpec
+		igs         []kopsapi.InstanceGroupSpec
+	}
+
+	type want struct {
+		clusterSpec *kopsapi.ClusterSpec
+		igs         []kopsapi.InstanceGroupSpec
+	}
+
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"Empty": {
+			reason: "Unset parts of a cluster spec should be defaulted, with etcd members on each control plane instance group.",
+			args: args{
+				clusterSpec: &kopsapi.ClusterSpec{},
+				igs:         []kopsapi.InstanceGroupSpec{ig("master-a", kopsapi.InstanceGroupRoleMaster), ig("master-b", kopsapi.InstanceGroupRoleMaster), ig("nodes", "")},
+			},
+			want: want{
+				clusterSpec: &kopsapi.ClusterSpec{
+					Channel:    "stable",
+					Topology:   &kopsapi.TopologySpec{Masters: "public", Nodes: "public", DNS: &kopsapi.DNSSpec{Type: kopsapi.DNSTypePublic}},
+					Networking: &kopsapi.NetworkingSpec{Calico: &kopsapi.CalicoNetworkingSpec{}},
+					EtcdClusters: []kopsapi.EtcdClusterSpec{
+						{Name: "main", Members: []kopsapi.EtcdMemberSpec{{Name: "a", InstanceGroup: fi.String("master-a")}, {Name: "b", InstanceGroup: fi.String("master-b")}}},
+						{Name: "events", Members: []kopsapi.EtcdMemberSpec{{Name: "a", InstanceGroup: fi.String("master-a")}, {Name: "b", InstanceGroup: fi.String("master-b")}}},
+					},
+				},
+				igs: []kopsapi.InstanceGroupSpec{ig("master-a", kopsapi.InstanceGroupRoleMaster), ig("master-b", kopsapi.InstanceGroupRoleMaster), ig("nodes", kopsapi.InstanceGroupRoleNode)},
+			},
+		},
+		"Set": {
+			reason: "Parts of a cluster spec that are set should be left alone.",
+			args: args{
+				clusterSpec: &kopsapi.ClusterSpec{
+					Channel:      "alpha",
+					Topology:     &kopsapi.TopologySpec{Masters: "private", Nodes: "private"},
+					Networking:   &kopsapi.NetworkingSpec{Cilium: &kopsapi.CiliumNetworkingSpec{}},
+					EtcdClusters: []kopsapi.EtcdClusterSpec{{Name: "main"}},
+				},
+				igs: []kopsapi.InstanceGroupSpec{ig("master-a", kopsapi.InstanceGroupRoleMaster)},
+			},
+			want: want{
+				clusterSpec: &kopsapi.ClusterSpec{
+					Channel:      "alpha",
+					Topology:     &kopsapi.TopologySpec{Masters: "private", Nodes: "private"},
+					Networking:   &kopsapi.NetworkingSpec{Cilium: &kopsapi.CiliumNetworkingSpec{}},
+					EtcdClusters: []kopsapi.EtcdClusterSpec{{Name: "main"}},
+				},
+				igs: []kopsapi.InstanceGroupSpec{ig("master-a", kopsapi.InstanceGroupRoleMaster)},
+			},
+		},
+		"IPv6": {
+			reason: "An IPv6 cluster should run an external cloud controller manager.",
+			args: args{
+				clusterSpec: &kopsapi.ClusterSpec{
+					Channel:           "stable",
+					Topology:          &kopsapi.TopologySpec{Masters: "public", Nodes: "public"},
+					Networking:        &kopsapi.NetworkingSpec{Cilium: &kopsapi.CiliumNetworkingSpec{}},
+					NonMasqueradeCIDR: "::/0",
+					EtcdClusters:      []kopsapi.EtcdClusterSpec{{Name: "main"}},
+				},
+			},
+			want: want{
+				clusterSpec: &kopsapi.ClusterSpec{
+					Channel:                        "stable",
+					Topology:                       &kopsapi.TopologySpec{Masters: "public", Nodes: "public"},
+					Networking:                     &kopsapi.NetworkingSpec{Cilium: &kopsapi.CiliumNetworkingSpec{}},
+					NonMasqueradeCIDR:              "::/0",
+					ExternalCloudControllerManager: &kopsapi.CloudControllerManagerConfig{},
+					EtcdClusters:                   []kopsapi.EtcdClusterSpec{{Name: "main"}},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			DefaultClusterSpec(tc.args.clusterSpec, tc.args.igs)
+			if diff := cmp.Diff(tc.want.clusterSpec, tc.args.clusterSpec); diff != "" {
+				t.Errorf("\n%s\nDefaultClusterSpec(...): -want cluster spec, +got cluster spec:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.igs, tc.args.igs); diff != "" {
+				t.Errorf("\n%s\nDefaultClusterSpec(...): -want instance groups, +got instance groups:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetDeprecatedFields(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		spec   *kopsapi.ClusterSpec
+		want   []string
+	}{
+		"Current": {
+			reason: "A spec using only supported fields should have no deprecated fields.",
+			spec: &kopsapi.ClusterSpec{
+				KubernetesVersion: "1.24.3",
+				ContainerRuntime:  "containerd",
+				Networking:        &kopsapi.NetworkingSpec{Calico: &kopsapi.CalicoNetworkingSpec{}},
+				KubeAPIServer:     &kopsapi.KubeAPIServerConfig{EnableAdmissionPlugins: []string{"NodeRestriction"}},
+			},
+			want: []string{},
+		},
+		"DeprecatedByKops": {
+			reason: "Fields kops deprecated should be returned whatever the Kubernetes version.",
+			spec: &kopsapi.ClusterSpec{
+				KubernetesVersion: "stable",
+				Networking:        &kopsapi.NetworkingSpec{Calico: &kopsapi.CalicoNetworkingSpec{CrossSubnet: fi.Bool(true)}},
+				KubeAPIServer:     &kopsapi.KubeAPIServerConfig{AdmissionControl: []string{"PodSecurityPolicy"}},
+			},
+			want: []string{
+				"clusterSpec.networking.calico.crossSubnet: deprecated in kOps 1.22 and has no effect",
+				"clusterSpec.kubeAPIServer.admissionControl: deprecated, use kubeAPIServer.enableAdmissionPlugins",
+			},
+		},
+		"RemovedByKubernetes": {
+			reason: "Fields the cluster's Kubernetes version removed should be returned.",
+			spec: &kopsapi.ClusterSpec{
+				KubernetesVersion: "v1.25.0-rc.1",
+				ContainerRuntime:  "docker",
+				KubeAPIServer:     &kopsapi.KubeAPIServerConfig{EnableAdmissionPlugins: []string{"NodeRestriction", "PodSecurityPolicy"}, InsecurePort: 8080},
+				Kubelet:           &kopsapi.KubeletConfigSpec{NetworkPluginName: "cni"},
+			},
+			want: []string{
+				"clusterSpec.kubeAPIServer.enableAdmissionPlugins: the PodSecurityPolicy admission plugin was removed in Kubernetes 1.25",
+				"clusterSpec.kubeAPIServer.insecurePort: the insecure port was removed in Kubernetes 1.24",
+				"clusterSpec.containerRuntime: Docker is no longer supported as of Kubernetes 1.24, use containerd",
+				"clusterSpec.kubelet.networkPluginName: the kubelet's network plugin flag was removed in Kubernetes 1.24",
+			},
+		},
+		"NotYetRemoved": {
+			reason: "Fields only removed by a later Kubernetes version should not be returned.",
+			spec: &kopsapi.ClusterSpec{
+				KubernetesVersion: "1.23.9",
+				ContainerRuntime:  "docker",
+				KubeAPIServer:     &kopsapi.KubeAPIServerConfig{EnableAdmissionPlugins: []string{"PodSecurityPolicy"}},
+			},
+			want: []string{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := GetDeprecatedFields(tc.spec)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nGetDeprecatedFields(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestWriteEncryptionConfig(t *testing.T) {
+	vfs.Context.ResetMemfsContext(true)
+	cs := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+	cluster := &kopsapi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"},
+		Spec:       kopsapi.ClusterSpec{ConfigBase: "memfs://state/test.example.com"},
+	}
+	config := []byte("kind: EncryptionConfiguration")
+
+	hash, err := WriteEncryptionConfig(cs, cluster, config)
+	if err != nil {
+		t.Fatalf("WriteEncryptionConfig(...): %v", err)
+	}
+	if diff := cmp.Diff(HashEncryptionConfig(config), hash); diff != "" {
+		t.Errorf("\nWriteEncryptionConfig(...): -want hash, +got hash:\n%s\n", diff)
+	}
+
+	store, err := cs.SecretStore(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.FindSecret(EncryptionConfigSecretName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(config, s.Data); diff != "" {
+		t.Errorf("\nstore.FindSecret(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestWriteDockerConfig(t *testing.T) {
+	vfs.Context.ResetMemfsContext(true)
+	cs := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+	cluster := &kopsapi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"},
+		Spec:       kopsapi.ClusterSpec{ConfigBase: "memfs://state/test.example.com"},
+	}
+	config := []byte(`{"auths":{"registry.example.com":{"auth":"dXNlcjpwYXNz"}}}`)
+
+	if _, err := WriteDockerConfig(cs, cluster, []byte("auths: {}")); err == nil {
+		t.Errorf("WriteDockerConfig(...): want an error writing a config that isn't JSON")
+	}
+	hash, err := WriteDockerConfig(cs, cluster, config)
+	if err != nil {
+		t.Fatalf("WriteDockerConfig(...): %v", err)
+	}
+	if diff := cmp.Diff(HashDockerConfig(config), hash); diff != "" {
+		t.Errorf("\nWriteDockerConfig(...): -want hash, +got hash:\n%s\n", diff)
+	}
+
+	store, err := cs.SecretStore(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.FindSecret(DockerConfigSecretName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(config, s.Data); diff != "" {
+		t.Errorf("\nstore.FindSecret(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestWriteDiagnosticBundle(t *testing.T) {
+	vfs.Context.ResetMemfsContext(true)
+	cs := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+	cluster := &kopsapi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"},
+		Spec:       kopsapi.ClusterSpec{ConfigBase: "memfs://state/test.example.com"},
+	}
+	bundle := &DiagnosticBundle{
+		Cluster:    "test.example.com",
+		Time:       metav1.NewTime(time.Date(2022, 6, 1, 12, 30, 15, 0, time.UTC)),
+		Dump:       &resources.Dump{Instances: []*resources.Instance{{Name: "i-0123456789"}}},
+		Validation: &v1alpha1.ValidationObservation{Nodes: 3, NotReadyNodes: []string{"node-a"}},
+	}
+
+	path, err := WriteDiagnosticBundle(cs, cluster, bundle)
+	if err != nil {
+		t.Fatalf("WriteDiagnosticBundle(...): %v", err)
+	}
+
+	configBase, err := cs.ConfigBaseFor(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := configBase.Join("dumps", "20220601T123015Z.json")
+	if diff := cmp.Diff(p.Path(), path); diff != "" {
+		t.Errorf("\nWriteDiagnosticBundle(...): -want path, +got path:\n%s\n", diff)
+	}
+	b, err := p.ReadFile()
+	if err != nil {
+		t.Fatalf("ReadFile(...): %v", err)
+	}
+	got := &DiagnosticBundle{}
+	if err := json.Unmarshal(b, got); err != nil {
+		t.Fatalf("json.Unmarshal(...): %v", err)
+	}
+	if diff := cmp.Diff(bundle, got); diff != "" {
+		t.Errorf("\nWriteDiagnosticBundle(...): -want bundle, +got bundle:\n%s\n", diff)
+	}
+}
+
+func TestRemoveProviderState(t *testing.T) {
+	vfs.Context.ResetMemfsContext(true)
+	cs := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+	cluster := &kopsapi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"},
+		Spec:       kopsapi.ClusterSpec{ConfigBase: "memfs://state/test.example.com"},
+	}
+	configBase, err := cs.ConfigBaseFor(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveProviderState(cs, cluster); err != nil {
+		t.Errorf("RemoveProviderState(...): want no error without provider state, got %v", err)
+	}
+
+	if _, err := WriteDiagnosticBundle(cs, cluster, &DiagnosticBundle{Cluster: "test.example.com", Time: metav1.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{ApplyMarkerPath, "config"} {
+		if err := configBase.Join(p).WriteFile(bytes.NewReader([]byte("data")), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RemoveProviderState(cs, cluster); err != nil {
+		t.Fatalf("RemoveProviderState(...): %v", err)
+	}
+	for _, dir := range providerStateDirs {
+		paths, err := configBase.Join(dir).ReadTree()
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		if len(paths) != 0 {
+			t.Errorf("RemoveProviderState(...): want %s removed, got %v", dir, paths)
+		}
+	}
+	if _, err := configBase.Join("config").ReadFile(); err != nil {
+		t.Errorf("RemoveProviderState(...): want the state written by kops kept, got %v", err)
+	}
+}
+
+func TestReplicateClusterState(t *testing.T) {
+	vfs.Context.ResetMemfsContext(true)
+	cs := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+	cluster := &kopsapi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"},
+		Spec:       kopsapi.ClusterSpec{ConfigBase: "memfs://state/test.example.com"},
+	}
+	configBase, err := cs.ConfigBaseFor(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica, err := GetStateReplicaPath("memfs://replica", "test.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"config", "instancegroup/nodes"} {
+		if err := configBase.Join(p).WriteFile(bytes.NewReader([]byte(p)), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := replica.Join("instancegroup/removed").WriteFile(bytes.NewReader([]byte("stale")), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := ReplicateClusterState(cs, cluster, "memfs://replica")
+	if err != nil {
+		t.Fatalf("ReplicateClusterState(...): %v", err)
+	}
+	if n != 2 {
+		t.Errorf("ReplicateClusterState(...): want 2 files replicated, got %d", n)
+	}
+	for _, p := range []string{"config", "instancegroup/nodes"} {
+		b, err := replica.Join(p).ReadFile()
+		if err != nil {
+			t.Fatalf("ReplicateClusterState(...): want %s replicated, got %v", p, err)
+		}
+		if string(b) != p {
+			t.Errorf("ReplicateClusterState(...): want %s content %q, got %q", p, p, b)
+		}
+	}
+	if _, err := replica.Join("instancegroup/removed").ReadFile(); !os.IsNotExist(err) {
+		t.Errorf("ReplicateClusterState(...): want files no longer in the state removed from the replica, got %v", err)
+	}
+}
+
+func TestImportKops(t *testing.T) {
+	vfs.Context.ResetMemfsContext(true)
+	base := vfs.NewMemFSPath(vfs.NewMemFSContext(), "state")
+	cs := vfsclientset.NewVFSClientset(base)
+	write := func(path string, obj runtime.Object) {
+		b, err := kopscodecs.ToVersionedYaml(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := base.Join(path).WriteFile(bytes.NewReader(b), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("test.example.com/config", &kopsapi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"},
+		Spec: kopsapi.ClusterSpec{
+			ConfigBase:        "memfs://state/test.example.com",
+			KubernetesVersion: "1.23.8",
+		},
+	})
+	write("test.example.com/instancegroup/nodes", &kopsapi.InstanceGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: "nodes"},
+		Spec:       kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleNode, Subnets: []string{"us-east-1a"}},
+	})
+
+	if _, err := ImportKops(context.Background(), cs, "memfs://state", "test"); err == nil {
+		t.Errorf("ImportKops(...): want an error importing a cluster name without a domain")
+	}
+	cr, err := ImportKops(context.Background(), cs, "memfs://state", "test.example.com")
+	if err != nil {
+		t.Fatalf("ImportKops(...): %v", err)
+	}
+
+	// kops defaults what it reads from the state store, so only the fields
+	// the import sets or that were written are compared.
+	p := cr.Spec.ForProvider
+	got := []string{p.Domain, p.StateBucket, p.ClusterSpec.KubernetesVersion, p.ClusterSpec.ConfigBase}
+	if diff := cmp.Diff([]string{"example.com", "memfs://state", "1.23.8", ""}, got); diff != "" {
+		t.Errorf("\nImportKops(...): -want domain, state bucket, Kubernetes version and config base, +got:\n%s\n", diff)
+	}
+	if len(p.InstanceGroupSpec) != 1 {
+		t.Fatalf("ImportKops(...): want 1 instance group, got %d", len(p.InstanceGroupSpec))
+	}
+	ig := p.InstanceGroupSpec[0]
+	if diff := cmp.Diff([]string{"us-east-1a"}, ig.Subnets); diff != "" {
+		t.Errorf("\nImportKops(...): -want instance group subnets, +got:\n%s\n", diff)
+	}
+	if name := ig.NodeLabels[kopsapi.NodeLabelInstanceGroup]; name != "nodes" {
+		t.Errorf("ImportKops(...): want instance group named nodes by its node label, got %q", name)
+	}
+	if meta.GetExternalName(cr) != "test" || cr.GetName() != "test" {
+		t.Errorf("ImportKops(...): want name and external name test, got %q and %q", cr.GetName(), meta.GetExternalName(cr))
+	}
+}
+
+func TestGetCAKeysetFingerprint(t *testing.T) {
+	vfs.Context.ResetMemfsContext(true)
+	cs := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+	cluster := &kopsapi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test.example.com"},
+		Spec:       kopsapi.ClusterSpec{ConfigBase: "memfs://state/test.example.com"},
+	}
+	issue := func() (*pki.Certificate, *pki.PrivateKey) {
+		cert, key, _, err := pki.IssueCert(&pki.IssueCertRequest{Type: "ca", Subject: pkix.Name{CommonName: fi.CertificateIDCA}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+
+	keyStore, err := cs.KeyStore(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetCAKeysetFingerprint(cluster, cs); err == nil {
+		t.Errorf("GetCAKeysetFingerprint(...): want error without a CA keyset")
+	}
+
+	keyset, err := fi.NewKeyset(issue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyStore.StoreKeyset(fi.CertificateIDCA, keyset); err != nil {
+		t.Fatal(err)
+	}
+	first, err := GetCAKeysetFingerprint(cluster, cs)
+	if err != nil {
+		t.Fatalf("GetCAKeysetFingerprint(...): %v", err)
+	}
+	again, err := GetCAKeysetFingerprint(cluster, cs)
+	if err != nil {
+		t.Fatalf("GetCAKeysetFingerprint(...): %v", err)
+	}
+	if diff := cmp.Diff(first, again); diff != "" {
+		t.Errorf("\nGetCAKeysetFingerprint(...): -want, +got:\n%s\n", diff)
+	}
+
+	// Adding the next CA to the keyset starts a rotation.
+	cert, key := issue()
+	if _, err := keyset.AddItem(cert, key, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyStore.StoreKeyset(fi.CertificateIDCA, keyset); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := GetCAKeysetFingerprint(cluster, cs)
+	if err != nil {
+		t.Fatalf("GetCAKeysetFingerprint(...): %v", err)
+	}
+	if first == rotated {
+		t.Errorf("GetCAKeysetFingerprint(...): want a new fingerprint once the keyset changed")
+	}
+}
+
+func TestGetKubeconfigForGroupRecordsIssuance(t *testing.T) {
+	vfs.Context.ResetMemfsContext(true)
+	cs := vfsclientset.NewVFSClientset(vfs.NewMemFSPath(vfs.NewMemFSContext(), "state"))
+	cluster := &kopsapi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuance.example.com"},
+		Spec:       kopsapi.ClusterSpec{ConfigBase: "memfs://state/issuance.example.com"},
+	}
+	commonName := KubeconfigCommonName(cluster.Name, "uid")
+	issued := metrics.CertificatesIssued.WithLabelValues(cluster.Name, commonName, CertificateReasonConnectionDetails)
+	ttl := metrics.CertificateTTL.WithLabelValues(cluster.Name, commonName)
+
+	// Issuing fails without a CA to sign with.
+	if _, err := GetKubeconfigForGroup(cluster, cs, commonName, CertificateReasonConnectionDetails, "crossplane:consumers", time.Hour); err == nil {
+		t.Fatalf("GetKubeconfigForGroup(...): want error without a CA keyset")
+	}
+	if got := testutil.ToFloat64(issued); got != 0 {
+		t.Errorf("\nA certificate that failed to be issued should not be counted.\nGetKubeconfigForGroup(...): want 0 issued, got %v\n", got)
+	}
+
+	cert, key, _, err := pki.IssueCert(&pki.IssueCertRequest{Type: "ca", Subject: pkix.Name{CommonName: fi.CertificateIDCA}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyset, err := fi.NewKeyset(cert, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyStore, err := cs.KeyStore(cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyStore.StoreKeyset(fi.CertificateIDCA, keyset); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := GetKubeconfigForGroup(cluster, cs, commonName, CertificateReasonConnectionDetails, "crossplane:consumers", time.Hour)
+	if err != nil {
+		t.Fatalf("GetKubeconfigForGroup(...): %v", err)
+	}
+	issuedCert, err := pki.ParsePEMCertificate(config.CertData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := issuedCert.Subject.CommonName; got != commonName {
+		t.Errorf("\nAn issued certificate should have the supplied common name.\nGetKubeconfigForGroup(...): want %q, got %q\n", commonName, got)
+	}
+	if got := testutil.ToFloat64(issued); got != 1 {
+		t.Errorf("\nAn issued certificate should be counted.\nGetKubeconfigForGroup(...): want 1 issued, got %v\n", got)
+	}
+	if got := testutil.ToFloat64(ttl); got != time.Hour.Seconds() {
+		t.Errorf("\nThe validity of an issued certificate should be recorded.\nGetKubeconfigForGroup(...): want TTL %v, got %v\n", time.Hour.Seconds(), got)
+	}
+}
+
+func TestKubeconfigCommonName(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		uid    types.UID
+		want   string
+	}{
+		"WithUID": {
+			reason: "The common name should identify both the cluster and the Kops resource.",
+			uid:    "3f2a",
+			want:   "crossplane:provider-kops:example.foo.com:3f2a",
+		},
+		"WithoutUID": {
+			reason: "The common name should identify the cluster when the Kops resource is unknown.",
+			want:   "crossplane:provider-kops:example.foo.com",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := KubeconfigCommonName("example.foo.com", tc.uid); got != tc.want {
+				t.Errorf("\n%s\nKubeconfigCommonName(...): want %q, got %q\n", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEnsureConnectionRBAC(t *testing.T) {
+	kube := fake.NewSimpleClientset()
+	ctx := context.Background()
+
+	if err := EnsureConnectionRBAC(ctx, kube, "crossplane:consumers", nil); err != nil {
+		t.Fatalf("EnsureConnectionRBAC(...): %v", err)
+	}
+	role, err := kube.RbacV1().ClusterRoles().Get(ctx, ConnectionRBACName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(DefaultConnectionRBACRules, role.Rules); diff != "" {
+		t.Errorf("\nEnsureConnectionRBAC(...): -want rules, +got rules:\n%s\n", diff)
+	}
+
+	rules := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}}
+	if err := EnsureConnectionRBAC(ctx, kube, "crossplane:admins", rules); err != nil {
+		t.Fatalf("EnsureConnectionRBAC(...): %v", err)
+	}
+	role, err = kube.RbacV1().ClusterRoles().Get(ctx, ConnectionRBACName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(rules, role.Rules); diff != "" {
+		t.Errorf("\nEnsureConnectionRBAC(...): -want rules, +got rules:\n%s\n", diff)
+	}
+	binding, err := kube.RbacV1().ClusterRoleBindings().Get(ctx, ConnectionRBACName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []rbacv1.Subject{{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: "crossplane:admins"}}
+	if diff := cmp.Diff(want, binding.Subjects); diff != "" {
+		t.Errorf("\nEnsureConnectionRBAC(...): -want subjects, +got subjects:\n%s\n", diff)
+	}
+}
+
+func TestSetProvenance(t *testing.T) {
+	cr := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "test", UID: "uid"}}
+	cluster := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"other": "kept"}}}
+
+	SetProvenance(cluster, cr, time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	want := map[string]string{
+		"other":                   "kept",
+		AnnotationManagedBy:       ManagedByProvider,
+		AnnotationResourceName:    "test",
+		AnnotationResourceUID:     "uid",
+		AnnotationLastApplyTime:   "2022-01-02T03:04:05Z",
+		AnnotationProviderVersion: "dev",
+	}
+	if diff := cmp.Diff(want, cluster.GetAnnotations()); diff != "" {
+		t.Errorf("\nSetProvenance(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestGetAPIEndpoint(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		name   string
+		host   string
+		want   string
+	}{
+		"Default": {
+			reason: "A cluster without a public name should be reached at api.<cluster>.",
+			name:   "test.example.com",
+			want:   "https://api.test.example.com",
+		},
+		"PublicName": {
+			reason: "A cluster with a public name should be reached at it.",
+			name:   "test.example.com",
+			host:   "k8s.example.com",
+			want:   "https://k8s.example.com",
+		},
+		"IPv6": {
+			reason: "A cluster published at an IPv6 address should be reached at the bracketed address.",
+			name:   "test.example.com",
+			host:   "2001:db8::10",
+			want:   "https://[2001:db8::10]",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := &kopsapi.Cluster{ObjectMeta: metav1.ObjectMeta{Name: tc.name}, Spec: kopsapi.ClusterSpec{MasterPublicName: tc.host}}
+			if diff := cmp.Diff(tc.want, GetAPIEndpoint(c)); diff != "" {
+				t.Errorf("\n%s\nGetAPIEndpoint(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestExpandZones(t *testing.T) {
+	zones := []string{"us-east-1a", "us-east-1b"}
+	etcd := func() []kopsapi.EtcdClusterSpec {
+		members := []kopsapi.EtcdMemberSpec{
+			{Name: "us-east-1a", InstanceGroup: fi.String("master-us-east-1a")},
+			{Name: "us-east-1b", InstanceGroup: fi.String("master-us-east-1b")},
+		}
+		return []kopsapi.EtcdClusterSpec{{Name: "main", Members: members}, {Name: "events", Members: members}}
+	}
+
+	cases := map[string]struct {
+		reason string
+		spec   kopsapi.ClusterSpec
+		zones  []string
+		want   kopsapi.ClusterSpec
+	}{
+		"NoZones": {
+			reason: "A cluster without zones should be left alone.",
+			spec:   kopsapi.ClusterSpec{NetworkCIDR: "10.0.0.0/16"},
+			want:   kopsapi.ClusterSpec{NetworkCIDR: "10.0.0.0/16"},
+		},
+		"Public": {
+			reason: "A public cluster should get a public subnet in each zone, allocated from the default network CIDR.",
+			zones:  zones,
+			want: kopsapi.ClusterSpec{
+				NetworkCIDR: DefaultNetworkCIDR,
+				Subnets: []kopsapi.ClusterSubnetSpec{
+					{Name: "us-east-1a", Zone: "us-east-1a", CIDR: "172.20.32.0/19", Type: kopsapi.SubnetTypePublic},
+					{Name: "us-east-1b", Zone: "us-east-1b", CIDR: "172.20.64.0/19", Type: kopsapi.SubnetTypePublic},
+				},
+				EtcdClusters: etcd(),
+			},
+		},
+		"Private": {
+			reason: "A private cluster should get a private and a utility subnet in each zone.",
+			spec:   kopsapi.ClusterSpec{NetworkCIDR: "10.0.0.0/16", Topology: &kopsapi.TopologySpec{Masters: kopsapi.TopologyPrivate}},
+			zones:  zones,
+			want: kopsapi.ClusterSpec{
+				NetworkCIDR: "10.0.0.0/16",
+				Topology:    &kopsapi.TopologySpec{Masters: kopsapi.TopologyPrivate},
+				Subnets: []kopsapi.ClusterSubnetSpec{
+					{Name: "us-east-1a", Zone: "us-east-1a", CIDR: "10.0.32.0/19", Type: kopsapi.SubnetTypePrivate},
+					{Name: "us-east-1b", Zone: "us-east-1b", CIDR: "10.0.64.0/19", Type: kopsapi.SubnetTypePrivate},
+					{Name: "utility-us-east-1a", Zone: "us-east-1a", CIDR: "10.0.0.0/22", Type: kopsapi.SubnetTypeUtility},
+					{Name: "utility-us-east-1b", Zone: "us-east-1b", CIDR: "10.0.4.0/22", Type: kopsapi.SubnetTypeUtility},
+				},
+				EtcdClusters: etcd(),
+			},
+		},
+		"IPv6": {
+			reason: "The subnets of an IPv6 cluster should be allocated a /64 of the VPC's IPv6 CIDR each.",
+			spec:   kopsapi.ClusterSpec{NetworkCIDR: "10.0.0.0/16", NonMasqueradeCIDR: "::/0"},
+			zones:  zones,
+			want: kopsapi.ClusterSpec{
+				NetworkCIDR:       "10.0.0.0/16",
+				NonMasqueradeCIDR: "::/0",
+				Subnets: []kopsapi.ClusterSubnetSpec{
+					{Name: "us-east-1a", Zone: "us-east-1a", CIDR: "10.0.32.0/19", IPv6CIDR: "/64#0", Type: kopsapi.SubnetTypePublic},
+					{Name: "us-east-1b", Zone: "us-east-1b", CIDR: "10.0.64.0/19", IPv6CIDR: "/64#1", Type: kopsapi.SubnetTypePublic},
+				},
+				EtcdClusters: etcd(),
+			},
+		},
+		"EtcdSet": {
+			reason: "A cluster that sets its own etcd clusters should keep them.",
+			spec:   kopsapi.ClusterSpec{NetworkCIDR: "10.0.0.0/16", EtcdClusters: []kopsapi.EtcdClusterSpec{{Name: "main"}}},
+			zones:  zones[:1],
+			want: kopsapi.ClusterSpec{
+				NetworkCIDR: "10.0.0.0/16",
+				Subnets: []kopsapi.ClusterSubnetSpec{
+					{Name: "us-east-1a", Zone: "us-east-1a", CIDR: "10.0.32.0/19", Type: kopsapi.SubnetTypePublic},
+				},
+				EtcdClusters: []kopsapi.EtcdClusterSpec{{Name: "main"}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ExpandZones(&tc.spec, tc.zones)
+			if diff := cmp.Diff(tc.want, tc.spec); diff != "" {
+				t.Errorf("\n%s\nExpandZones(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestExpandSimple(t *testing.T) {
+	simple := &v1alpha1.SimpleParameters{
+		Zones:             []string{"us-east-1a", "us-east-1b"},
+		KubernetesVersion: "1.23.5",
+		NodeCount:         fi.Int32(3),
+		NodeSize:          "m5.large",
+		Networking:        "cilium",
+	}
+	ig := func(name, zone, machineType string, role kopsapi.InstanceGroupRole, size int32) kopsapi.InstanceGroupSpec {
+		return kopsapi.InstanceGroupSpec{
+			Role:        role,
+			MachineType: machineType,
+			MinSize:     fi.Int32(size),
+			MaxSize:     fi.Int32(size),
+			Subnets:     []string{zone},
+			NodeLabels:  map[string]string{kopsapi.NodeLabelInstanceGroup: name},
+		}
+	}
+	members := []kopsapi.EtcdMemberSpec{{Name: "us-east-1a", InstanceGroup: fi.String("master-us-east-1a")}}
+
+	type want struct {
+		spec kopsapi.ClusterSpec
+		igs  []kopsapi.InstanceGroupSpec
+	}
+
+	cases := map[string]struct {
+		reason string
+		spec   kopsapi.ClusterSpec
+		igs    []kopsapi.InstanceGroupSpec
+		want   want
+	}{
+		"Generated": {
+			reason: "A cluster without instance groups should get a control plane and nodes spread over its zones.",
+			want: want{
+				spec: kopsapi.ClusterSpec{
+					CloudProvider:     "aws",
+					KubernetesVersion: "1.23.5",
+					Channel:           kopsapi.DefaultChannel,
+					Topology: &kopsapi.TopologySpec{
+						Masters: kopsapi.TopologyPublic,
+						Nodes:   kopsapi.TopologyPublic,
+						DNS:     &kopsapi.DNSSpec{Type: kopsapi.DNSTypePublic},
+					},
+					Networking:  &kopsapi.NetworkingSpec{Cilium: &kopsapi.CiliumNetworkingSpec{}},
+					NetworkCIDR: DefaultNetworkCIDR,
+					Subnets: []kopsapi.ClusterSubnetSpec{
+						{Name: "us-east-1a", Zone: "us-east-1a", CIDR: "172.20.32.0/19", Type: kopsapi.SubnetTypePublic},
+						{Name: "us-east-1b", Zone: "us-east-1b", CIDR: "172.20.64.0/19", Type: kopsapi.SubnetTypePublic},
+					},
+					EtcdClusters: []kopsapi.EtcdClusterSpec{{Name: "main", Members: members}, {Name: "events", Members: members}},
+				},
+				igs: []kopsapi.InstanceGroupSpec{
+					ig("master-us-east-1a", "us-east-1a", DefaultMachineType, kopsapi.InstanceGroupRoleMaster, 1),
+					ig("nodes-us-east-1a", "us-east-1a", "m5.large", kopsapi.InstanceGroupRoleNode, 2),
+					ig("nodes-us-east-1b", "us-east-1b", "m5.large", kopsapi.InstanceGroupRoleNode, 1),
+				},
+			},
+		},
+		"Overridden": {
+			reason: "Fields the cluster spec sets, and its instance groups, should be kept.",
+			spec: kopsapi.ClusterSpec{
+				CloudProvider:     "aws",
+				KubernetesVersion: "1.22.0",
+				Channel:           "alpha",
+				Topology:          &kopsapi.TopologySpec{Masters: kopsapi.TopologyPublic},
+				Networking:        &kopsapi.NetworkingSpec{Kubenet: &kopsapi.KubenetNetworkingSpec{}},
+				Subnets:           []kopsapi.ClusterSubnetSpec{{Name: "a"}},
+				EtcdClusters:      []kopsapi.EtcdClusterSpec{{Name: "main"}},
+			},
+			igs: []kopsapi.InstanceGroupSpec{ig("master-a", "a", "t3.small", kopsapi.InstanceGroupRoleMaster, 1)},
+			want: want{
+				spec: kopsapi.ClusterSpec{
+					CloudProvider:     "aws",
+					KubernetesVersion: "1.22.0",
+					Channel:           "alpha",
+					Topology:          &kopsapi.TopologySpec{Masters: kopsapi.TopologyPublic},
+					Networking:        &kopsapi.NetworkingSpec{Kubenet: &kopsapi.KubenetNetworkingSpec{}},
+					Subnets:           []kopsapi.ClusterSubnetSpec{{Name: "a"}},
+					EtcdClusters:      []kopsapi.EtcdClusterSpec{{Name: "main"}},
+				},
+				igs: []kopsapi.InstanceGroupSpec{ig("master-a", "a", "t3.small", kopsapi.InstanceGroupRoleMaster, 1)},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			igs := ExpandSimple(&tc.spec, tc.igs, simple)
+			if diff := cmp.Diff(tc.want.spec, tc.spec); diff != "" {
+				t.Errorf("\n%s\nExpandSimple(...): -want spec, +got spec:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.igs, igs); diff != "" {
+				t.Errorf("\n%s\nExpandSimple(...): -want instance groups, +got instance groups:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInstanceGroupResourceUpToDate(t *testing.T) {
+	mixed := func(instances ...string) *kopsapi.MixedInstancesPolicySpec {
+		return &kopsapi.MixedInstancesPolicySpec{Instances: instances}
+	}
+	spot := func(instances ...string) *kopsapi.MixedInstancesPolicySpec {
+		return &kopsapi.MixedInstancesPolicySpec{Instances: instances, OnDemandAboveBase: fi.Int64(0)}
+	}
+
+	cases := map[string]struct {
+		reason  string
+		cluster kopsapi.ClusterSpec
+		old     kopsapi.InstanceGroupSpec
+		new     kopsapi.InstanceGroupSpec
+		want    bool
+	}{
+		"EmptyInstances": {
+			reason: "A mixed instances policy with no instance types should equal one that omits them.",
+			old:    kopsapi.InstanceGroupSpec{MixedInstancesPolicy: mixed()},
+			new:    kopsapi.InstanceGroupSpec{MixedInstancesPolicy: &kopsapi.MixedInstancesPolicySpec{}},
+			want:   true,
+		},
+		"MixedInstancesDefaults": {
+			reason: "A mixed instances policy that sets the AWS defaults should equal one that omits them.",
+			old: kopsapi.InstanceGroupSpec{MixedInstancesPolicy: &kopsapi.MixedInstancesPolicySpec{
+				OnDemandAllocationStrategy: fi.String("prioritized"),
+				OnDemandBase:               fi.Int64(0),
+				OnDemandAboveBase:          fi.Int64(100),
+				SpotInstancePools:          fi.Int64(2),
+			}},
+			new:  kopsapi.InstanceGroupSpec{MixedInstancesPolicy: mixed()},
+			want: true,
+		},
+		"SpotInstanceOrder": {
+			reason: "The order of the instance types of an all spot policy should not matter.",
+			old:    kopsapi.InstanceGroupSpec{MixedInstancesPolicy: spot("m5.large", "c5.large")},
+			new:    kopsapi.InstanceGroupSpec{MixedInstancesPolicy: spot("c5.large", "m5.large")},
+			want:   true,
+		},
+		"OnDemandInstanceOrder": {
+			reason: "The order of the instance types of a policy with on-demand capacity is their priority.",
+			old:    kopsapi.InstanceGroupSpec{MixedInstancesPolicy: mixed("m5.large", "c5.large")},
+			new:    kopsapi.InstanceGroupSpec{MixedInstancesPolicy: mixed("c5.large", "m5.large")},
+		},
+		"DisabledWarmPool": {
+			reason: "A disabled warm pool should equal no warm pool if the cluster has no default warm pool.",
+			old:    kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleNode, WarmPool: &kopsapi.WarmPoolSpec{MaxSize: fi.Int64(0)}},
+			new:    kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleNode},
+			want:   true,
+		},
+		"InheritedWarmPool": {
+			reason:  "A disabled warm pool should not equal no warm pool if the cluster has a default warm pool.",
+			cluster: kopsapi.ClusterSpec{WarmPool: &kopsapi.WarmPoolSpec{MinSize: 1}},
+			old:     kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleNode, WarmPool: &kopsapi.WarmPoolSpec{MaxSize: fi.Int64(0)}},
+			new:     kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleNode},
+		},
+		"ExplicitDefaultWarmPool": {
+			reason:  "A warm pool that repeats the default warm pool of the cluster should equal one that inherits it.",
+			cluster: kopsapi.ClusterSpec{WarmPool: &kopsapi.WarmPoolSpec{MinSize: 1}},
+			old:     kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleNode, WarmPool: &kopsapi.WarmPoolSpec{MinSize: 1}},
+			new:     kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleNode},
+			want:    true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := InstanceGroupResourceUpToDate(&tc.cluster, &tc.old, &tc.new)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nInstanceGroupResourceUpToDate(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetInstanceGroupsNeedingReplacement(t *testing.T) {
+	ig := func(machineType string, size int32, warmPool *kopsapi.WarmPoolSpec) kopsapi.InstanceGroupSpec {
+		return kopsapi.InstanceGroupSpec{
+			Role:        kopsapi.InstanceGroupRoleNode,
+			MachineType: machineType,
+			MinSize:     fi.Int32(size),
+			MaxSize:     fi.Int32(size),
+			WarmPool:    warmPool,
+			NodeLabels:  map[string]string{kopsapi.NodeLabelInstanceGroup: "nodes"},
+		}
+	}
+	state := &kopsapi.InstanceGroupList{Items: []kopsapi.InstanceGroup{{Spec: ig("m5.large", 2, nil)}}}
+
+	cases := map[string]struct {
+		reason string
+		igs    []kopsapi.InstanceGroupSpec
+		want   []string
+	}{
+		"InPlace": {
+			reason: "Changes to the size and warm pool of an instance group should not replace its instances.",
+			igs:    []kopsapi.InstanceGroupSpec{ig("m5.large", 3, &kopsapi.WarmPoolSpec{MinSize: 1})},
+		},
+		"Replacement": {
+			reason: "Changes to the machine type of an instance group should replace its instances.",
+			igs:    []kopsapi.InstanceGroupSpec{ig("m5.xlarge", 2, nil)},
+			want:   []string{"nodes"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := GetInstanceGroupsNeedingReplacement(&kopsapi.ClusterSpec{}, tc.igs, state)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nGetInstanceGroupsNeedingReplacement(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestVerifyGPUNodes(t *testing.T) {
+	gpuNode := func(name, ig string, gpus int64) *corev1.Node {
+		n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{
+			GPUNodeLabel:                   "1",
+			kopsapi.NodeLabelInstanceGroup: ig,
+		}}}
+		if gpus > 0 {
+			n.Status.Allocatable = corev1.ResourceList{GPUResourceName: *resource.NewQuantity(gpus, resource.DecimalSI)}
+		}
+		return n
+	}
+	plugin := func(node string, ready corev1.ConditionStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "nvidia-device-plugin-" + node, Namespace: metav1.NamespaceSystem, Labels: map[string]string{"name": "nvidia-device-plugin-ds"}},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}}},
+		}
+	}
+	kube := fake.NewSimpleClientset(
+		gpuNode("gpu-a-1", "gpu-a", 4),
+		gpuNode("gpu-a-2", "gpu-a", 0),
+		gpuNode("gpu-b-1", "gpu-b", 1),
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cpu-1", Labels: map[string]string{kopsapi.NodeLabelInstanceGroup: "nodes"}}},
+		plugin("gpu-a-1", corev1.ConditionTrue),
+		plugin("gpu-a-2", corev1.ConditionTrue),
+		plugin("gpu-b-1", corev1.ConditionFalse),
+	)
+	igs := &kopsapi.InstanceGroupList{Items: []kopsapi.InstanceGroup{
+		{ObjectMeta: metav1.ObjectMeta{Name: "gpu-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "gpu-b"}},
+	}}
+	v := &validation.ValidationCluster{}
+
+	got, err := VerifyGPUNodes(context.Background(), kube, igs, v)
+	if err != nil {
+		t.Fatalf("VerifyGPUNodes(...): %v", err)
+	}
+	want := []v1alpha1.GPUPoolObservation{
+		{InstanceGroup: "gpu-a", Nodes: 2, Ready: 1, GPUs: 4, Summary: "1/2"},
+		{InstanceGroup: "gpu-b", Nodes: 1, Summary: "0/1"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("\nVerifyGPUNodes(...): -want, +got:\n%s\n", diff)
+	}
+	failed := map[string]string{}
+	for _, f := range v.Failures {
+		failed[f.Name] = f.InstanceGroup.Name
+	}
+	if diff := cmp.Diff(map[string]string{"gpu-a-2": "gpu-a", "gpu-b-1": "gpu-b"}, failed); diff != "" {
+		t.Errorf("\nVerifyGPUNodes(...): -want failures, +got failures:\n%s\n", diff)
+	}
+}
+
+func TestGetUpgradeRecommendation(t *testing.T) {
+	channel := &kopsapi.Channel{Spec: kopsapi.ChannelSpec{
+		KubernetesVersions: []kopsapi.KubernetesVersionSpec{
+			{Range: ">=1.23.0", RecommendedVersion: "1.23.7", RequiredVersion: "1.23.0"},
+			{Range: ">=1.22.0", RecommendedVersion: "1.22.10", RequiredVersion: "1.22.5"},
+		},
+		KopsVersions: []kopsapi.KopsVersionSpec{
+			{Range: ">=1.23.0-alpha.1", RecommendedVersion: "1.23.2", RequiredVersion: "1.23.0"},
+		},
+	}}
+
+	cases := map[string]struct {
+		reason     string
+		kubernetes string
+		kops       string
+		want       *v1alpha1.UpgradeObservation
+	}{
+		"UpToDate": {
+			reason:     "A cluster running the recommended versions should need no upgrade.",
+			kubernetes: "1.23.7",
+			kops:       "1.23.2",
+			want:       &v1alpha1.UpgradeObservation{Urgency: v1alpha1.UpgradeUrgencyNone},
+		},
+		"Recommended": {
+			reason:     "A cluster running an older but supported Kubernetes version should be recommended the newer one.",
+			kubernetes: "1.23.5",
+			kops:       "1.23.2",
+			want:       &v1alpha1.UpgradeObservation{KubernetesVersion: "1.23.7", Urgency: v1alpha1.UpgradeUrgencyRecommended},
+		},
+		"Required": {
+			reason:     "A cluster running a Kubernetes version older than the required one should be required to upgrade.",
+			kubernetes: "v1.22.2",
+			kops:       "1.23.1",
+			want:       &v1alpha1.UpgradeObservation{KubernetesVersion: "1.22.10", KopsVersion: "1.23.2", Urgency: v1alpha1.UpgradeUrgencyRequired},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := GetUpgradeRecommendation(channel, tc.kubernetes, tc.kops)
+			if err != nil {
+				t.Fatalf("\n%s\nGetUpgradeRecommendation(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nGetUpgradeRecommendation(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetChannelImage(t *testing.T) {
+	channel := &kopsapi.Channel{Spec: kopsapi.ChannelSpec{Images: []*kopsapi.ChannelImageSpec{
+		{ProviderID: "aws", ArchitectureID: "amd64", Name: "ubuntu-focal-amd64", KubernetesVersion: "<1.24.0"},
+		{ProviderID: "aws", ArchitectureID: "amd64", Name: "ubuntu-jammy-amd64", KubernetesVersion: ">=1.24.0"},
+		{ProviderID: "aws", ArchitectureID: "arm64", Name: "ubuntu-jammy-arm64", KubernetesVersion: ">=1.24.0"},
+	}}}
+
+	type want struct {
+		image string
+		err   bool
+	}
+	cases := map[string]struct {
+		reason  string
+		version string
+		arch    string
+		want    want
+	}{
+		"Default": {
+			reason:  "The amd64 image for the Kubernetes version of the cluster should be returned by default.",
+			version: "1.23.5",
+			want:    want{image: "ubuntu-focal-amd64"},
+		},
+		"Architecture": {
+			reason:  "The image of the requested architecture should be returned.",
+			version: "v1.24.2",
+			arch:    "arm64",
+			want:    want{image: "ubuntu-jammy-arm64"},
+		},
+		"NoImage": {
+			reason:  "A channel listing no image for the architecture and version should be an error.",
+			version: "1.23.5",
+			arch:    "arm64",
+			want:    want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := GetChannelImage(channel, kopsapi.CloudProviderAWS, tc.version, tc.arch)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nGetChannelImage(...): -want error, +got error:\n%s\n%v\n", tc.reason, diff, err)
+			}
+			if diff := cmp.Diff(tc.want.image, got); diff != "" {
+				t.Errorf("\n%s\nGetChannelImage(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+type mockSSM struct {
+	ssmiface.SSMAPI
+	params map[string]string
+}
+
+func (m *mockSSM) GetParameter(in *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	v, ok := m.params[aws.StringValue(in.Name)]
+	if !ok {
+		return nil, errors.New("ParameterNotFound")
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String(v)}}, nil
+}
+
+func TestGetSSMParameterImage(t *testing.T) {
+	const param = "/aws/service/canonical/ubuntu/server/22.04/stable/current/amd64/hvm/ebs-gp2/ami-id"
+	m := &mockSSM{params: map[string]string{param: "ami-0123456789abcdef0"}}
+
+	got, err := GetSSMParameterImage(m, param)
+	if err != nil {
+		t.Fatalf("GetSSMParameterImage(...): %v", err)
+	}
+	if got != "ami-0123456789abcdef0" {
+		t.Errorf("GetSSMParameterImage(...): want ami-0123456789abcdef0, got %q", got)
+	}
+	if _, err := GetSSMParameterImage(m, "/missing"); err == nil {
+		t.Errorf("GetSSMParameterImage(...): want error for a missing parameter, got none")
+	}
+}
+
+func TestDesiredInstanceGroupSpecsTrackedImages(t *testing.T) {
+	ig := func(name, image string) kopsapi.InstanceGroupSpec {
+		return kopsapi.InstanceGroupSpec{Image: image, NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: name}}
+	}
+	cr := &v1alpha1.Kops{}
+	cr.Spec.ForProvider.InstanceGroupSpec = []kopsapi.InstanceGroupSpec{ig("nodes-a", "old"), ig("nodes-b", "old"), ig("nodes-c", "old")}
+	cr.Spec.ForProvider.ImageTracking = []v1alpha1.ImageTrackingParameters{
+		{InstanceGroup: "nodes-a", Source: v1alpha1.ImageSourceChannel},
+		{InstanceGroup: "nodes-b", Source: v1alpha1.ImageSourceChannel},
+	}
+	// nodes-b wasn't looked up yet, and nodes-c is no longer tracked.
+	cr.Status.AtProvider.TrackedImages = []v1alpha1.TrackedImageObservation{
+		{InstanceGroup: "nodes-a", Image: "new"},
+		{InstanceGroup: "nodes-c", Image: "new"},
+	}
+
+	want := []kopsapi.InstanceGroupSpec{ig("nodes-a", "new"), ig("nodes-b", "old"), ig("nodes-c", "old")}
+	if diff := cmp.Diff(want, DesiredInstanceGroupSpecs(cr)); diff != "" {
+		t.Errorf("\nDesiredInstanceGroupSpecs(...): -want, +got:\n%s\n", diff)
+	}
+	if cr.Spec.ForProvider.InstanceGroupSpec[0].Image != "old" {
+		t.Errorf("DesiredInstanceGroupSpecs(...): the instance groups of the Kops resource were changed")
+	}
+}
+
+func TestHashNetworking(t *testing.T) {
+	cilium := &kopsapi.NetworkingSpec{Cilium: &kopsapi.CiliumNetworkingSpec{Version: "v1.10.5"}}
+	upgraded := &kopsapi.NetworkingSpec{Cilium: &kopsapi.CiliumNetworkingSpec{Version: "v1.11.1"}}
+
+	if got := GetNetworkingProvider(cilium); got != "cilium" {
+		t.Errorf("GetNetworkingProvider(...): want cilium, got %q", got)
+	}
+	a, err := HashNetworking(cilium)
+	if err != nil {
+		t.Fatalf("HashNetworking(...): %v", err)
+	}
+	b, err := HashNetworking(upgraded)
+	if err != nil {
+		t.Fatalf("HashNetworking(...): %v", err)
+	}
+	if a == b {
+		t.Errorf("HashNetworking(...): want different hashes for different Cilium versions, got %q for both", a)
+	}
+}
+
+func TestVerifyCNI(t *testing.T) {
+	calico := &kopsapi.NetworkingSpec{Calico: &kopsapi.CalicoNetworkingSpec{}}
+	daemonSet := func(gen, observed int64, desired, ready, updated int32) *appsv1.DaemonSet {
+		return &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "calico-node", Namespace: metav1.NamespaceSystem, Generation: gen},
+			Status: appsv1.DaemonSetStatus{
+				ObservedGeneration:     observed,
+				DesiredNumberScheduled: desired,
+				NumberReady:            ready,
+				UpdatedNumberScheduled: updated,
+			},
+		}
+	}
+
+	type want struct {
+		health   *v1alpha1.CNIHealth
+		failures int
+	}
+	cases := map[string]struct {
+		reason     string
+		networking *kopsapi.NetworkingSpec
+		kube       *fake.Clientset
+		want       want
+	}{
+		"Healthy": {
+			reason:     "A DaemonSet whose agents are all ready and updated should be healthy.",
+			networking: calico,
+			kube:       fake.NewSimpleClientset(daemonSet(2, 2, 3, 3, 3)),
+			want: want{
+				health: &v1alpha1.CNIHealth{DaemonSet: "calico-node", Desired: 3, Ready: 3, Updated: 3, Healthy: true},
+			},
+		},
+		"RollingOut": {
+			reason:     "A DaemonSet with agents of a previous spec should be reported as a validation failure.",
+			networking: calico,
+			kube:       fake.NewSimpleClientset(daemonSet(2, 2, 3, 3, 1)),
+			want: want{
+				health:   &v1alpha1.CNIHealth{DaemonSet: "calico-node", Desired: 3, Ready: 3, Updated: 1},
+				failures: 1,
+			},
+		},
+		"NotObserved": {
+			reason:     "A DaemonSet whose latest spec wasn't observed yet should not be healthy.",
+			networking: calico,
+			kube:       fake.NewSimpleClientset(daemonSet(3, 2, 3, 3, 3)),
+			want: want{
+				health:   &v1alpha1.CNIHealth{DaemonSet: "calico-node", Desired: 3, Ready: 3, Updated: 3},
+				failures: 1,
+			},
+		},
+		"NotFound": {
+			reason:     "A missing DaemonSet, e.g. of a CNI installed by other means, should not be reported.",
+			networking: calico,
+			kube:       fake.NewSimpleClientset(),
+		},
+		"NoAgent": {
+			reason:     "A networking provider without an agent should not be reported.",
+			networking: &kopsapi.NetworkingSpec{Kubenet: &kopsapi.KubenetNetworkingSpec{}},
+			kube:       fake.NewSimpleClientset(),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := &validation.ValidationCluster{}
+			got, err := VerifyCNI(context.Background(), tc.kube, tc.networking, v)
+			if err != nil {
+				t.Fatalf("\n%s\nVerifyCNI(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.health, got); diff != "" {
+				t.Errorf("\n%s\nVerifyCNI(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.failures, len(v.Failures)); diff != "" {
+				t.Errorf("\n%s\nVerifyCNI(...): -want failures, +got failures:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRunHealthProbes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	deployment := func(replicas, available int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: metav1.NamespaceSystem},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{UpdatedReplicas: replicas, AvailableReplicas: available},
+		}
+	}
+	coredns := v1alpha1.HealthProbe{Name: "dns", Deployment: &v1alpha1.DeploymentProbe{Name: "coredns"}}
+
+	type want struct {
+		results  []v1alpha1.HealthProbeResult
+		failures int
+	}
+	cases := map[string]struct {
+		reason string
+		probes []v1alpha1.HealthProbe
+		kube   *fake.Clientset
+		want   want
+	}{
+		"Healthy": {
+			reason: "Available Deployments and URLs returning 200 should pass.",
+			probes: []v1alpha1.HealthProbe{coredns, {Name: "api", HTTPGet: &v1alpha1.HTTPGetProbe{URL: srv.URL + "/healthz"}}},
+			kube:   fake.NewSimpleClientset(deployment(2, 2)),
+			want: want{results: []v1alpha1.HealthProbeResult{
+				{Name: "dns", Healthy: true},
+				{Name: "api", Healthy: true},
+			}},
+		},
+		"DeploymentUnavailable": {
+			reason: "A Deployment with unavailable replicas should fail validation.",
+			probes: []v1alpha1.HealthProbe{coredns},
+			kube:   fake.NewSimpleClientset(deployment(2, 1)),
+			want: want{
+				results:  []v1alpha1.HealthProbeResult{{Name: "dns", Message: "Deployment kube-system/coredns has 1/2 available and 2/2 updated replicas"}},
+				failures: 1,
+			},
+		},
+		"DeploymentMissing": {
+			reason: "A missing Deployment should fail validation.",
+			probes: []v1alpha1.HealthProbe{coredns},
+			kube:   fake.NewSimpleClientset(),
+			want: want{
+				results:  []v1alpha1.HealthProbeResult{{Name: "dns", Message: "Deployment kube-system/coredns doesn't exist"}},
+				failures: 1,
+			},
+		},
+		"HTTPGetFailed": {
+			reason: "A URL not returning 200 should fail validation.",
+			probes: []v1alpha1.HealthProbe{{Name: "api", HTTPGet: &v1alpha1.HTTPGetProbe{URL: srv.URL + "/ready"}}},
+			kube:   fake.NewSimpleClientset(),
+			want: want{
+				results:  []v1alpha1.HealthProbeResult{{Name: "api", Message: "GET " + srv.URL + "/ready returned 503 Service Unavailable"}},
+				failures: 1,
+			},
+		},
+		"NoProbes": {
+			reason: "A cluster without probes should report none.",
+			kube:   fake.NewSimpleClientset(),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := &validation.ValidationCluster{}
+			got, err := RunHealthProbes(context.Background(), tc.kube, srv.Client(), tc.probes, v)
+			if err != nil {
+				t.Fatalf("\n%s\nRunHealthProbes(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.results, got); diff != "" {
+				t.Errorf("\n%s\nRunHealthProbes(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.failures, len(v.Failures)); diff != "" {
+				t.Errorf("\n%s\nRunHealthProbes(...): -want failures, +got failures:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestExpandBastion(t *testing.T) {
+	subnets := []kopsapi.ClusterSubnetSpec{
+		{Name: "us-east-1a", Type: kopsapi.SubnetTypePrivate},
+		{Name: "utility-us-east-1a", Type: kopsapi.SubnetTypeUtility},
+	}
+	ig := func(name string, role kopsapi.InstanceGroupRole) kopsapi.InstanceGroupSpec {
+		return kopsapi.InstanceGroupSpec{Role: role, NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: name}}
+	}
+	bastion := kopsapi.InstanceGroupSpec{
+		Role:        kopsapi.InstanceGroupRoleBastion,
+		MachineType: DefaultBastionMachineType,
+		MinSize:     fi.Int32(1),
+		MaxSize:     fi.Int32(1),
+		Subnets:     []string{"utility-us-east-1a"},
+		NodeLabels:  map[string]string{kopsapi.NodeLabelInstanceGroup: BastionInstanceGroupName},
+	}
+
+	cases := map[string]struct {
+		reason   string
+		topology *kopsapi.TopologySpec
+		igs      []kopsapi.InstanceGroupSpec
+		want     []kopsapi.InstanceGroupSpec
+	}{
+		"NoBastion": {
+			reason:   "Instance groups should be left as is if the topology has no bastion.",
+			topology: &kopsapi.TopologySpec{Masters: kopsapi.TopologyPrivate, Nodes: kopsapi.TopologyPrivate},
+			igs:      []kopsapi.InstanceGroupSpec{ig("master-us-east-1a", kopsapi.InstanceGroupRoleMaster)},
+			want:     []kopsapi.InstanceGroupSpec{ig("master-us-east-1a", kopsapi.InstanceGroupRoleMaster)},
+		},
+		"Bastion": {
+			reason:   "A bastion instance group should be added in the utility subnets, in name order, if the topology has a bastion.",
+			topology: &kopsapi.TopologySpec{Masters: kopsapi.TopologyPrivate, Nodes: kopsapi.TopologyPrivate, Bastion: &kopsapi.BastionSpec{}},
+			igs:      []kopsapi.InstanceGroupSpec{ig("master-us-east-1a", kopsapi.InstanceGroupRoleMaster), ig("nodes", kopsapi.InstanceGroupRoleNode)},
+			want:     []kopsapi.InstanceGroupSpec{bastion, ig("master-us-east-1a", kopsapi.InstanceGroupRoleMaster), ig("nodes", kopsapi.InstanceGroupRoleNode)},
+		},
+		"ExistingBastion": {
+			reason:   "No bastion instance group should be added if one is specified.",
+			topology: &kopsapi.TopologySpec{Masters: kopsapi.TopologyPrivate, Nodes: kopsapi.TopologyPrivate, Bastion: &kopsapi.BastionSpec{}},
+			igs:      []kopsapi.InstanceGroupSpec{ig("jump", kopsapi.InstanceGroupRoleBastion)},
+			want:     []kopsapi.InstanceGroupSpec{ig("jump", kopsapi.InstanceGroupRoleBastion)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := &kopsapi.ClusterSpec{Topology: tc.topology, Subnets: subnets}
+			got := ExpandBastion(spec, tc.igs)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nExpandBastion(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetRemovedBastions(t *testing.T) {
+	igs := &kopsapi.InstanceGroupList{Items: []kopsapi.InstanceGroup{
+		{ObjectMeta: metav1.ObjectMeta{Name: BastionInstanceGroupName}, Spec: kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleBastion}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "nodes"}, Spec: kopsapi.InstanceGroupSpec{Role: kopsapi.InstanceGroupRoleNode}},
+	}}
+	desired := []kopsapi.InstanceGroupSpec{{NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: "nodes"}}}
+
+	got := GetRemovedBastions(desired, igs)
+	if len(got) != 1 || got[0].Name != BastionInstanceGroupName {
+		t.Errorf("GetRemovedBastions(...): want [%s], got %v", BastionInstanceGroupName, got)
+	}
+	if got := GetRemovedBastions(append(desired, kopsapi.InstanceGroupSpec{NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: BastionInstanceGroupName}}), igs); len(got) != 0 {
+		t.Errorf("GetRemovedBastions(...): want no bastions, got %v", got)
+	}
+}
+
+func TestGetInstanceConnections(t *testing.T) {
+	groups := map[string]*cloudinstances.CloudInstanceGroup{
+		"nodes": {
+			Ready:      []*cloudinstances.CloudInstance{{ID: "i-2", PrivateIP: "10.0.0.2", Node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}}}},
+			NeedUpdate: []*cloudinstances.CloudInstance{{ID: "i-1", PrivateIP: "10.0.0.1"}},
+		},
+		"master-a": {
+			Ready: []*cloudinstances.CloudInstance{{ID: "i-3", PrivateIP: "10.0.0.3"}},
+		},
+	}
+	want := []v1alpha1.InstanceGroupConnectionObservation{
+		{InstanceGroup: "master-a", Instances: []v1alpha1.InstanceConnection{{ID: "i-3", SSMTarget: "i-3", PrivateIP: "10.0.0.3"}}},
+		{InstanceGroup: "nodes", Instances: []v1alpha1.InstanceConnection{
+			{ID: "i-1", SSMTarget: "i-1", PrivateIP: "10.0.0.1"},
+			{ID: "i-2", SSMTarget: "i-2", PrivateIP: "10.0.0.2", Node: "node-2"},
+		}},
+	}
+	if diff := cmp.Diff(want, GetInstanceConnections(groups, true)); diff != "" {
+		t.Errorf("\nGetInstanceConnections(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestRetainResources(t *testing.T) {
+	type want struct {
+		n    int
+		done []string
+	}
+	cases := map[string]struct {
+		reason    string
+		overrides map[string]v1alpha1.TaskLifecycle
+		want      want
+	}{
+		"NoOverrides": {
+			reason: "Every resource should be deleted without lifecycle overrides.",
+			want:   want{done: []string{}},
+		},
+		"Sync": {
+			reason:    "Resources of tasks kops syncs should be deleted.",
+			overrides: map[string]v1alpha1.TaskLifecycle{"IAMRole": v1alpha1.TaskLifecycleSync},
+			want:      want{done: []string{}},
+		},
+		"OwnedElsewhere": {
+			reason: "Resources of tasks another system owns should be retained.",
+			overrides: map[string]v1alpha1.TaskLifecycle{
+				"IAMRole": v1alpha1.TaskLifecycleExistsAndWarnIfChanges,
+				"Subnet":  v1alpha1.TaskLifecycleIgnore,
+			},
+			want: want{n: 2, done: []string{"role", "subnet"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res := map[string]*resources.Resource{
+				"role":   {Name: "masters.example.com", Type: "iam-role"},
+				"subnet": {Name: "us-east-1a.example.com", Type: ec2.ResourceTypeSubnet},
+				"asg":    {Name: "nodes.example.com", Type: "autoscaling-group"},
+			}
+			n := RetainResources(res, tc.overrides)
+			done := []string{}
+			for k, r := range res {
+				if r.Done {
+					done = append(done, k)
+				}
+			}
+			sort.Strings(done)
+			if diff := cmp.Diff(tc.want, want{n: n, done: done}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nRetainResources(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGetDeletePreview(t *testing.T) {
+	res := map[string]*resources.Resource{
+		"subnet:subnet-2": {Name: "us-east-1b.example.com", Type: ec2.ResourceTypeSubnet, ID: "subnet-2"},
+		"vpc:vpc-1":       {Name: "example.com", Type: ec2.ResourceTypeVpc, ID: "vpc-1", Shared: true, Done: true},
+		"subnet:subnet-1": {Name: "us-east-1a.example.com", Type: ec2.ResourceTypeSubnet, ID: "subnet-1"},
+	}
+	want := []v1alpha1.DeletePreviewResource{
+		{Type: ec2.ResourceTypeSubnet, ID: "subnet-1", Name: "us-east-1a.example.com"},
+		{Type: ec2.ResourceTypeSubnet, ID: "subnet-2", Name: "us-east-1b.example.com"},
+		{Type: ec2.ResourceTypeVpc, ID: "vpc-1", Name: "example.com", Shared: true, Retained: true},
+	}
+	if diff := cmp.Diff(want, GetDeletePreview(res)); diff != "" {
+		t.Errorf("\nGetDeletePreview(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestGetClusterRegions(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		spec     kopsapi.ClusterSpec
+		fallback string
+		want     []string
+	}{
+		"AWS": {
+			reason:   "The region of AWS clusters should be derived from the zones of their subnets rather than the region parameter.",
+			spec:     kopsapi.ClusterSpec{CloudProvider: "aws", Subnets: []kopsapi.ClusterSubnetSpec{{Zone: "eu-west-1a"}, {Zone: "eu-west-1b"}}},
+			fallback: "us-east-1",
+			want:     []string{"eu-west-1"},
+		},
+		"GCE": {
+			reason: "The regions of GCE clusters should be derived from the zones or regions of their subnets.",
+			spec:   kopsapi.ClusterSpec{CloudProvider: "gce", Subnets: []kopsapi.ClusterSubnetSpec{{Zone: "us-central1-a"}, {Region: "europe-west4"}}},
+			want:   []string{"europe-west4", "us-central1"},
+		},
+		"NoZones": {
+			reason:   "The region parameter should be used for clusters whose subnets have no zones.",
+			spec:     kopsapi.ClusterSpec{CloudProvider: "aws"},
+			fallback: "us-east-1",
+			want:     []string{"us-east-1"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := GetClusterRegions(&kopsapi.Cluster{Spec: tc.spec}, tc.fallback)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nGetClusterRegions(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMergeFileAssets(t *testing.T) {
+	spec := &kopsapi.ClusterSpec{FileAssets: []kopsapi.FileAssetSpec{
+		{Name: "audit", Content: "old"},
+		{Name: "motd", Content: "hello"},
+	}}
+	MergeFileAssets(spec, []kopsapi.FileAssetSpec{{Name: "audit", Content: "new"}, {Name: "oidc", Content: "ca"}})
+
+	want := []kopsapi.FileAssetSpec{
+		{Name: "motd", Content: "hello"},
+		{Name: "audit", Content: "new"},
+		{Name: "oidc", Content: "ca"},
+	}
+	if diff := cmp.Diff(want, spec.FileAssets); diff != "" {
+		t.Errorf("MergeFileAssets(...): -want, +got:\n%s\n", diff)
+	}
+}
+
+func TestValidateAdditionalSANs(t *testing.T) {
+	p := field.NewPath("additionalSANs")
+
+	cases := map[string]struct {
+		reason string
+		sans   []string
+		want   []string
+	}{
+		"Valid": {
+			reason: "DNS names, wildcard DNS names and IP addresses should be valid.",
+			sans:   []string{"api.internal.example.com", "*.example.com", "10.0.0.1", "fd00::1"},
+		},
+		"Invalid": {
+			reason: "Names that are neither DNS names nor IP addresses should be invalid.",
+			sans:   []string{"api.example.com", "api_internal", "*.*.example.com"},
+			want:   []string{"additionalSANs[1]", "additionalSANs[2]"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, err := range ValidateAdditionalSANs(tc.sans, p) {
+				got = append(got, err.Field)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nValidateAdditionalSANs(...): -want invalid fields, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateCloudTags(t *testing.T) {
+	p := field.NewPath("cloudTags")
+
+	cases := map[string]struct {
+		reason string
+		tags   *v1alpha1.CloudTagParameters
+		want   []string
+	}{
+		"Valid": {
+			reason: "Tags kops doesn't apply itself should be valid.",
+			tags:   &v1alpha1.CloudTagParameters{Tags: map[string]string{"cost-center": "42"}, ClusterNameKey: "Cluster", InstanceGroupKey: "Pool"},
+		},
+		"Reserved": {
+			reason: "Tags kops applies itself should be invalid.",
+			tags: &v1alpha1.CloudTagParameters{
+				Tags:             map[string]string{"KubernetesCluster": "a", "kubernetes.io/cluster/a": "owned", "team": "b"},
+				InstanceGroupKey: "kops.k8s.io/instancegroup",
+			},
+			want: []string{"cloudTags.tags[KubernetesCluster]", "cloudTags.tags[kubernetes.io/cluster/a]", "cloudTags.instanceGroupKey"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, err := range ValidateCloudTags(tc.tags, p) {
+				got = append(got, err.Field)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nValidateCloudTags(...): -want invalid fields, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestApplyClusterOverrides(t *testing.T) {
+	base := func() kopsapi.ClusterSpec {
+		return kopsapi.ClusterSpec{KubernetesVersion: "1.23.7", Kubelet: &kopsapi.KubeletConfigSpec{MaxPods: fi.Int32(110)}}
+	}
+
+	type want struct {
+		spec kopsapi.ClusterSpec
+		err  bool
+	}
+
+	cases := map[string]struct {
+		reason    string
+		overrides []string
+		want      want
+	}{
+		"None": {
+			reason: "A spec without overrides should be left as it is.",
+			want:   want{spec: base()},
+		},
+		"Applied": {
+			reason:    "Overrides should be applied in order.",
+			overrides: []string{"spec.kubelet.maxPods=100", "spec.kubelet.maxPods=120", "spec.kubernetesVersion=1.23.8"},
+			want:      want{spec: kopsapi.ClusterSpec{KubernetesVersion: "1.23.8", Kubelet: &kopsapi.KubeletConfigSpec{MaxPods: fi.Int32(120)}}},
+		},
+		"NotSpec": {
+			reason:    "Overrides of fields outside the spec should be an error, leaving the spec as it is.",
+			overrides: []string{"spec.kubernetesVersion=1.23.8", "metadata.name=other"},
+			want:      want{spec: base(), err: true},
+		},
+		"NoValue": {
+			reason:    "Overrides without a value should be an error.",
+			overrides: []string{"spec.kubernetesVersion"},
+			want:      want{spec: base(), err: true},
+		},
+		"UnknownField": {
+			reason:    "Overrides of fields the spec doesn't have should be an error.",
+			overrides: []string{"spec.kubelet.maxPodz=120"},
+			want:      want{spec: base(), err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			orig := base()
+			spec := orig
+			err := ApplyClusterOverrides(&spec, tc.overrides)
+			if diff := cmp.Diff(tc.want, want{spec: spec, err: err != nil}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nApplyClusterOverrides(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(base(), orig); diff != "" {
+				t.Errorf("\n%s\nApplyClusterOverrides(...): the supplied spec's fields should not be changed in place:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestApplyInstanceGroupPatches(t *testing.T) {
+	base := func() []kopsapi.InstanceGroupSpec {
+		return []kopsapi.InstanceGroupSpec{
+			{NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: "master"}, MaxSize: fi.Int32(1)},
+			{NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: "nodes"}, MaxSize: fi.Int32(3), Taints: []string{"a=b:NoSchedule"}},
+		}
+	}
+	patched := func(f func(igs []kopsapi.InstanceGroupSpec)) []kopsapi.InstanceGroupSpec {
+		igs := base()
+		f(igs)
+		return igs
+	}
+
+	type want struct {
+		igs []kopsapi.InstanceGroupSpec
+		err bool
+	}
+
+	cases := map[string]struct {
+		reason  string
+		patches []v1alpha1.InstanceGroupPatch
+		want    want
+	}{
+		"None": {
+			reason: "Instance groups without patches should be left as they are.",
+			want:   want{igs: base()},
+		},
+		"StrategicMerge": {
+			reason: "Strategic merge patches should be applied to the instance group they name.",
+			patches: []v1alpha1.InstanceGroupPatch{{
+				InstanceGroup: "nodes",
+				Patch:         "maxSize: 5\ntaints:\n- c=d:NoExecute\n",
+			}},
+			want: want{igs: patched(func(igs []kopsapi.InstanceGroupSpec) {
+				igs[1].MaxSize = fi.Int32(5)
+				igs[1].Taints = []string{"c=d:NoExecute"}
+			})},
+		},
+		"JSON6902": {
+			reason: "JSON patches should be applied in order.",
+			patches: []v1alpha1.InstanceGroupPatch{
+				{InstanceGroup: "master", Type: v1alpha1.InstanceGroupPatchJSON6902, Patch: `[{"op": "replace", "path": "/maxSize", "value": 2}]`},
+				{InstanceGroup: "master", Type: v1alpha1.InstanceGroupPatchJSON6902, Patch: `[{"op": "replace", "path": "/maxSize", "value": 4}]`},
+			},
+			want: want{igs: patched(func(igs []kopsapi.InstanceGroupSpec) {
+				igs[0].MaxSize = fi.Int32(4)
+			})},
+		},
+		"UnknownInstanceGroup": {
+			reason:  "Patches of instance groups that don't exist should be an error, leaving the instance groups as they are.",
+			patches: []v1alpha1.InstanceGroupPatch{{InstanceGroup: "other", Patch: "maxSize: 5"}},
+			want:    want{igs: base(), err: true},
+		},
+		"UnknownField": {
+			reason:  "Patches of fields the instance group spec doesn't have should be an error.",
+			patches: []v1alpha1.InstanceGroupPatch{{InstanceGroup: "nodes", Patch: "maxSise: 5"}},
+			want:    want{igs: base(), err: true},
+		},
+		"BadPatch": {
+			reason: "Patches that can't be applied should be an error, leaving earlier patches unapplied.",
+			patches: []v1alpha1.InstanceGroupPatch{
+				{InstanceGroup: "nodes", Patch: "maxSize: 5"},
+				{InstanceGroup: "nodes", Type: v1alpha1.InstanceGroupPatchJSON6902, Patch: `[{"op": "remove", "path": "/minSize"}]`},
+			},
+			want: want{igs: base(), err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			orig := base()
+			igs, err := ApplyInstanceGroupPatches(orig, tc.patches)
+			if diff := cmp.Diff(tc.want, want{igs: igs, err: err != nil}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nApplyInstanceGroupPatches(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+			if diff := cmp.Diff(base(), orig); diff != "" {
+				t.Errorf("\n%s\nApplyInstanceGroupPatches(...): the supplied instance groups should not be changed in place:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestApplyCloudTags(t *testing.T) {
+	clusterSpec := kopsapi.ClusterSpec{CloudLabels: map[string]string{"team": "a", "env": "dev"}}
+	igs := []kopsapi.InstanceGroupSpec{{
+		NodeLabels:  map[string]string{kopsapi.NodeLabelInstanceGroup: "nodes"},
+		CloudLabels: map[string]string{"env": "test"},
+	}}
+	tags := &v1alpha1.CloudTagParameters{Tags: map[string]string{"env": "prod"}, ClusterNameKey: "Cluster", InstanceGroupKey: "Pool"}
+
+	got := ApplyCloudTags(&clusterSpec, igs, tags, "test.example.com")
+
+	if diff := cmp.Diff(map[string]string{"team": "a", "env": "prod", "Cluster": "test.example.com"}, clusterSpec.CloudLabels); diff != "" {
+		t.Errorf("ApplyCloudTags(...): -want cluster cloud labels, +got:\n%s\n", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"env": "prod", "Pool": "nodes"}, got[0].CloudLabels); diff != "" {
+		t.Errorf("ApplyCloudTags(...): -want instance group cloud labels, +got:\n%s\n", diff)
+	}
+	if igs[0].CloudLabels["env"] != "test" {
+		t.Errorf("ApplyCloudTags(...): modified the supplied instance groups")
+	}
+}
+
+func TestGetEgressProxySpec(t *testing.T) {
+	p := &v1alpha1.EgressProxyParameters{Host: "proxy.corp.example.com", Port: 3128, Excludes: []string{"corp.example.com", "10.0.0.0/8"}}
+
+	cases := map[string]struct {
+		reason      string
+		params      *v1alpha1.EgressProxyParameters
+		credentials string
+		want        *kopsapi.EgressProxySpec
+	}{
+		"None": {
+			reason: "A Kops resource without an egress proxy should not set one.",
+		},
+		"Anonymous": {
+			reason: "An egress proxy without credentials should be reached by its host.",
+			params: p,
+			want: &kopsapi.EgressProxySpec{
+				HTTPProxy:     kopsapi.HTTPProxy{Host: "proxy.corp.example.com", Port: 3128},
+				ProxyExcludes: "corp.example.com,10.0.0.0/8",
+			},
+		},
+		"Credentials": {
+			reason:      "Credentials should be put in the host, escaped.",
+			params:      p,
+			credentials: "svc-kops:p@ss:word",
+			want: &kopsapi.EgressProxySpec{
+				HTTPProxy:     kopsapi.HTTPProxy{Host: "svc-kops:p%40ss%3Aword@proxy.corp.example.com", Port: 3128},
+				ProxyExcludes: "corp.example.com,10.0.0.0/8",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := GetEgressProxySpec(tc.params, tc.credentials)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nGetEgressProxySpec(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateEgressProxy(t *testing.T) {
+	p := field.NewPath("egressProxy")
+
+	cases := map[string]struct {
+		reason string
+		params v1alpha1.KopsParameters
+		want   field.ErrorList
+	}{
+		"Valid": {
+			reason: "An egress proxy with a host and port should be valid.",
+			params: v1alpha1.KopsParameters{
+				EgressProxy: &v1alpha1.EgressProxyParameters{Host: "proxy.corp.example.com", Port: 3128, Excludes: []string{"10.0.0.0/8"}},
+			},
+		},
+		"Invalid": {
+			reason: "An egress proxy with a scheme, a bad port, or combined excludes should be invalid.",
+			params: v1alpha1.KopsParameters{
+				EgressProxy: &v1alpha1.EgressProxyParameters{Host: "http://proxy", Port: 0, Excludes: []string{"a,b"}},
+			},
+			want: field.ErrorList{
+				field.Invalid(p.Child("host"), "http://proxy", "must be a host name or IP address, without a scheme or credentials"),
+				field.Invalid(p.Child("port"), 0, "must be between 1 and 65535"),
+				field.Invalid(p.Child("excludes").Index(0), "a,b", "must be a single host, domain or CIDR"),
+			},
+		},
+		"ClusterSpec": {
+			reason: "An egress proxy should not be set in both places.",
+			params: v1alpha1.KopsParameters{
+				EgressProxy: &v1alpha1.EgressProxyParameters{Host: "proxy", Port: 3128},
+				ClusterSpec: kopsapi.ClusterSpec{EgressProxy: &kopsapi.EgressProxySpec{}},
+			},
+			want: field.ErrorList{field.Forbidden(p, "may not be set together with clusterSpec.egressProxy")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &v1alpha1.Kops{}
+			cr.Spec.ForProvider = tc.params
+			got := ValidateEgressProxy(cr, p)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nValidateEgressProxy(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidateFileAssets(t *testing.T) {
+	cm := &v1alpha1.ConfigMapKeySelector{Name: "cm", Key: "k"}
+	p := field.NewPath("fileAssets")
+
+	cases := map[string]struct {
+		reason string
+		assets []v1alpha1.FileAssetParameters
+		want   field.ErrorList
+	}{
+		"Valid": {
+			reason: "File assets with one source each and unique names should be valid.",
+			assets: []v1alpha1.FileAssetParameters{{Name: "a", ConfigMapRef: cm}, {Name: "b", ConfigMapRef: cm}},
+		},
+		"NoSource": {
+			reason: "A file asset without a source should be invalid.",
+			assets: []v1alpha1.FileAssetParameters{{Name: "a"}},
+			want:   field.ErrorList{field.Invalid(p.Index(0), "a", "exactly one of configMapRef and secretRef must be set")},
+		},
+		"Duplicate": {
+			reason: "File assets with the same name should be invalid.",
+			assets: []v1alpha1.FileAssetParameters{{Name: "a", ConfigMapRef: cm}, {Name: "a", ConfigMapRef: cm}},
+			want:   field.ErrorList{field.Duplicate(p.Index(1).Child("name"), "a")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ValidateFileAssets(tc.assets, p)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nValidateFileAssets(...): -want, +got:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook contains the admission webhooks of Kops resources.
+package webhook
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kops/upup/pkg/fi"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+	"github.com/crossplane/provider-kops/internal/util"
+)
+
+const (
+	errNotKops = "object is not a Kops custom resource"
+)
+
+var (
+	_ admission.CustomValidator = &KopsValidator{}
+	_ admission.CustomDefaulter = &KopsDefaulter{}
+)
+
+// Setup adds the admission webhooks of Kops resources to the supplied
+// manager.
+func Setup(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.Kops{}).
+		WithDefaulter(&KopsDefaulter{}).
+		WithValidator(&KopsValidator{}).
+		Complete()
+}
+
+// A KopsDefaulter fills in the parts of the cluster spec of a Kops resource
+// that most clusters set the same way, reducing the boilerplate each resource
+// needs. Defaults are only filled in when a resource is created; defaulting an
+// existing resource could change a running cluster, e.g. its networking.
+//
+// +kubebuilder:webhook:verbs=create,path=/mutate-kops-kops-crossplane-io-v1alpha1-kops,mutating=true,failurePolicy=fail,groups=kops.kops.crossplane.io,resources=kops,versions=v1alpha1,name=kops.kops.crossplane.io,sideEffects=None,admissionReviewVersions=v1
+type KopsDefaulter struct{}
+
+// Default fills in the defaults of a Kops resource that is being created.
+func (d *KopsDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	cr, ok := obj.(*v1alpha1.Kops)
+	if !ok {
+		return errors.New(errNotKops)
+	}
+	// The API server sets the creation timestamp of a resource after calling
+	// mutating webhooks, so it is only zero while the resource is created.
+	if !cr.GetCreationTimestamp().IsZero() {
+		return nil
+	}
+	// Simple mode fills in its own defaults, which must not be masked.
+	if cr.Spec.ForProvider.Simple != nil {
+		return nil
+	}
+	util.DefaultClusterSpec(&cr.Spec.ForProvider.ClusterSpec, cr.Spec.ForProvider.InstanceGroupSpec)
+	return nil
+}
+
+// A KopsValidator rejects Kops resources that kops would refuse to apply, so
+// that mistakes are reported when a resource is created or updated rather
+// than by a failed apply.
+//
+// +kubebuilder:webhook:verbs=create;update,path=/validate-kops-kops-crossplane-io-v1alpha1-kops,mutating=false,failurePolicy=fail,groups=kops.kops.crossplane.io,resources=kops,versions=v1alpha1,name=kops.kops.crossplane.io,sideEffects=None,admissionReviewVersions=v1
+type KopsValidator struct{}
+
+// ValidateCreate validates a Kops resource that is being created.
+func (v *KopsValidator) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	cr, ok := obj.(*v1alpha1.Kops)
+	if !ok {
+		return errors.New(errNotKops)
+	}
+	return validate(cr)
+}
+
+// ValidateUpdate validates a Kops resource that is being updated. Updates that
+// leave the parameters of a resource unchanged, e.g. of its finalizers, are
+// allowed even if the resource is invalid, so that resources created before
+// the webhook was installed can still be deleted.
+func (v *KopsValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) error {
+	old, ok := oldObj.(*v1alpha1.Kops)
+	if !ok {
+		return errors.New(errNotKops)
+	}
+	cr, ok := newObj.(*v1alpha1.Kops)
+	if !ok {
+		return errors.New(errNotKops)
+	}
+	if meta.WasDeleted(cr) || reflect.DeepEqual(old.Spec.ForProvider, cr.Spec.ForProvider) {
+		return nil
+	}
+	return validate(cr)
+}
+
+// ValidateDelete allows every Kops resource to be deleted.
+func (v *KopsValidator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+func validate(cr *v1alpha1.Kops) error {
+	p := field.NewPath("spec", "forProvider")
+	errs := util.ValidateZones(&cr.Spec.ForProvider.ClusterSpec, cr.Spec.ForProvider.Zones, p.Child("zones"))
+	if simple := cr.Spec.ForProvider.Simple; simple != nil {
+		errs = append(errs, util.ValidateSimple(&cr.Spec.ForProvider.ClusterSpec, simple, p.Child("simple"))...)
+		if len(cr.Spec.ForProvider.Zones) != 0 {
+			errs = append(errs, field.Forbidden(p.Child("zones"), "zones can't be set alongside simple"))
+		}
+	}
+	// Instance groups may be generated, and in the subnets zones expand into.
+	desired := util.DesiredClusterSpec(cr)
+	errs = append(errs, util.ValidateInstanceGroupSpecs(&desired, util.DesiredInstanceGroupSpecs(cr), p.Child("instanceGroupSpec"))...)
+	if enabled := cr.Spec.ForProvider.ClusterSpec.EncryptionConfig; cr.Spec.ForProvider.EncryptionConfigSecretRef != nil && !fi.BoolValue(enabled) {
+		errs = append(errs, field.Invalid(p.Child("clusterSpec", "encryptionConfig"), fi.BoolValue(enabled), "must be true when encryptionConfigSecretRef is set"))
+	}
+	errs = append(errs, util.ValidateImageTracking(cr.Spec.ForProvider.ImageTracking, util.DesiredInstanceGroupSpecs(cr), p.Child("imageTracking"))...)
+	errs = append(errs, util.ValidateIPv6(&cr.Spec.ForProvider.ClusterSpec, p.Child("clusterSpec"))...)
+	errs = append(errs, util.ValidateReachability(cr.Spec.ForProvider.Reachability, p.Child("reachability"))...)
+	errs = append(errs, util.ValidateFileAssets(cr.Spec.ForProvider.FileAssets, p.Child("fileAssets"))...)
+	errs = append(errs, util.ValidateHealthProbes(cr.Spec.ForProvider.HealthProbes, p.Child("healthProbes"))...)
+	errs = append(errs, util.ValidateEgressProxy(cr, p.Child("egressProxy"))...)
+	errs = append(errs, util.ValidateAdditionalSANs(cr.Spec.ForProvider.ClusterSpec.AdditionalSANs, p.Child("clusterSpec", "additionalSANs"))...)
+	errs = append(errs, util.ValidateCloudTags(cr.Spec.ForProvider.CloudTags, p.Child("cloudTags"))...)
+	errs = append(errs, util.ValidateClusterOverrides(cr, p.Child("overrides"))...)
+	errs = append(errs, util.ValidateInstanceGroupPatches(cr, p.Child("instanceGroupPatches"))...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return kerrors.NewInvalid(v1alpha1.KopsGroupVersionKind.GroupKind(), cr.GetName(), errs)
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kopsapi "k8s.io/kops/pkg/apis/kops"
+
+	"github.com/crossplane/provider-kops/apis/kops/v1alpha1"
+)
+
+func kops(igs ...kopsapi.InstanceGroupSpec) *v1alpha1.Kops {
+	cr := &v1alpha1.Kops{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	cr.Spec.ForProvider.ClusterSpec.Subnets = []kopsapi.ClusterSubnetSpec{{Name: "a"}}
+	cr.Spec.ForProvider.InstanceGroupSpec = igs
+	return cr
+}
+
+func ig(name string, role kopsapi.InstanceGroupRole) kopsapi.InstanceGroupSpec {
+	return kopsapi.InstanceGroupSpec{NodeLabels: map[string]string{kopsapi.NodeLabelInstanceGroup: name}, Role: role, Subnets: []string{"a"}}
+}
+
+func TestValidateCreate(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		obj    runtime.Object
+		want   bool
+	}{
+		"Valid": {
+			reason: "A Kops resource with a control plane should be admitted.",
+			obj:    kops(ig("master-a", kopsapi.InstanceGroupRoleMaster), ig("nodes", kopsapi.InstanceGroupRoleNode)),
+		},
+		"NoControlPlane": {
+			reason: "A Kops resource without a control plane should be rejected as invalid.",
+			obj:    kops(ig("nodes", kopsapi.InstanceGroupRoleNode)),
+			want:   true,
+		},
+		"EncryptionConfigDisabled": {
+			reason: "A Kops resource referencing an encryption config without enabling it should be rejected as invalid.",
+			obj: func() runtime.Object {
+				cr := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+				cr.Spec.ForProvider.EncryptionConfigSecretRef = &xpv1.SecretKeySelector{Key: "config.yaml"}
+				return cr
+			}(),
+			want: true,
+		},
+		"Zones": {
+			reason: "A Kops resource with instance groups in the subnets of its zones should be admitted.",
+			obj: func() runtime.Object {
+				cr := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+				cr.Spec.ForProvider.ClusterSpec.Subnets = nil
+				cr.Spec.ForProvider.Zones = []string{"a", "b"}
+				return cr
+			}(),
+		},
+		"Simple": {
+			reason: "A Kops resource in simple mode should be admitted without instance groups of its own.",
+			obj: func() runtime.Object {
+				cr := kops()
+				cr.Spec.ForProvider.ClusterSpec.Subnets = nil
+				cr.Spec.ForProvider.Simple = &v1alpha1.SimpleParameters{Zones: []string{"a"}, KubernetesVersion: "1.23.5"}
+				return cr
+			}(),
+		},
+		"SimpleWithoutVersion": {
+			reason: "A Kops resource in simple mode without a Kubernetes version should be rejected as invalid.",
+			obj: func() runtime.Object {
+				cr := kops()
+				cr.Spec.ForProvider.ClusterSpec.Subnets = nil
+				cr.Spec.ForProvider.Simple = &v1alpha1.SimpleParameters{Zones: []string{"a"}}
+				return cr
+			}(),
+			want: true,
+		},
+		"ZonesAndSubnets": {
+			reason: "A Kops resource with both zones and subnets should be rejected as invalid.",
+			obj: func() runtime.Object {
+				cr := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+				cr.Spec.ForProvider.Zones = []string{"b"}
+				return cr
+			}(),
+			want: true,
+		},
+		"ViaProxy": {
+			reason: "A Kops resource reached through a SOCKS5 proxy should be admitted.",
+			obj: func() runtime.Object {
+				cr := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+				cr.Spec.ForProvider.Reachability = &v1alpha1.ReachabilityParameters{Mode: v1alpha1.ReachabilityViaProxy, ProxyURL: "socks5://10.0.0.10:1080"}
+				return cr
+			}(),
+		},
+		"ViaProxyWithoutURL": {
+			reason: "A Kops resource reached through a proxy without a proxy URL should be rejected as invalid.",
+			obj: func() runtime.Object {
+				cr := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+				cr.Spec.ForProvider.Reachability = &v1alpha1.ReachabilityParameters{Mode: v1alpha1.ReachabilityViaProxy}
+				return cr
+			}(),
+			want: true,
+		},
+		"UnsupportedProxy": {
+			reason: "A Kops resource with a proxy URL of an unsupported scheme should be rejected as invalid.",
+			obj: func() runtime.Object {
+				cr := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+				cr.Spec.ForProvider.Reachability = &v1alpha1.ReachabilityParameters{Mode: v1alpha1.ReachabilityViaProxy, ProxyURL: "ftp://10.0.0.10"}
+				return cr
+			}(),
+			want: true,
+		},
+		"ImageTrackingUnknownInstanceGroup": {
+			reason: "A Kops resource tracking the image of an instance group it doesn't have should be rejected as invalid.",
+			obj: func() runtime.Object {
+				cr := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+				cr.Spec.ForProvider.ImageTracking = []v1alpha1.ImageTrackingParameters{{InstanceGroup: "nodes", Source: v1alpha1.ImageSourceChannel}}
+				return cr
+			}(),
+			want: true,
+		},
+		"ImageTrackingWithoutSSMParameter": {
+			reason: "A Kops resource tracking an SSM parameter image without naming the parameter should be rejected as invalid.",
+			obj: func() runtime.Object {
+				cr := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+				cr.Spec.ForProvider.ImageTracking = []v1alpha1.ImageTrackingParameters{{InstanceGroup: "master-a", Source: v1alpha1.ImageSourceSSMParameter}}
+				return cr
+			}(),
+			want: true,
+		},
+		"IPv6": {
+			reason: "An IPv6 Kops resource with Cilium networking and an external cloud controller manager should be admitted.",
+			obj: func() runtime.Object {
+				cr := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+				cr.Spec.ForProvider.ClusterSpec.NonMasqueradeCIDR = "::/0"
+				cr.Spec.ForProvider.ClusterSpec.Networking = &kopsapi.NetworkingSpec{Cilium: &kopsapi.CiliumNetworkingSpec{}}
+				cr.Spec.ForProvider.ClusterSpec.ExternalCloudControllerManager = &kopsapi.CloudControllerManagerConfig{}
+				cr.Spec.ForProvider.ClusterSpec.Subnets[0].IPv6CIDR = "/64#0"
+				return cr
+			}(),
+		},
+		"IPv6UnsupportedNetworking": {
+			reason: "An IPv6 Kops resource with a networking provider without IPv6 support should be rejected as invalid.",
+			obj: func() runtime.Object {
+				cr := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+				cr.Spec.ForProvider.ClusterSpec.NonMasqueradeCIDR = "::/0"
+				cr.Spec.ForProvider.ClusterSpec.Networking = &kopsapi.NetworkingSpec{Weave: &kopsapi.WeaveNetworkingSpec{}}
+				cr.Spec.ForProvider.ClusterSpec.ExternalCloudControllerManager = &kopsapi.CloudControllerManagerConfig{}
+				return cr
+			}(),
+			want: true,
+		},
+		"DualStackInvalidSubnet": {
+			reason: "A dual-stack Kops resource with a subnet IPv6 CIDR that is an IPv4 CIDR should be rejected as invalid.",
+			obj: func() runtime.Object {
+				cr := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+				cr.Spec.ForProvider.ClusterSpec.Subnets[0].IPv6CIDR = "10.0.0.0/24"
+				return cr
+			}(),
+			want: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := (&KopsValidator{}).ValidateCreate(context.Background(), tc.obj)
+			if diff := cmp.Diff(tc.want, kerrors.IsInvalid(err)); diff != "" {
+				t.Errorf("\n%s\nv.ValidateCreate(...): -want invalid, +got invalid:\n%s\n%v\n", tc.reason, diff, err)
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	invalid := kops(ig("nodes", kopsapi.InstanceGroupRoleNode))
+	deleted := invalid.DeepCopy()
+	deleted.SetDeletionTimestamp(&metav1.Time{})
+	relabelled := invalid.DeepCopy()
+	relabelled.SetLabels(map[string]string{"team": "platform"})
+
+	cases := map[string]struct {
+		reason string
+		old    runtime.Object
+		obj    runtime.Object
+		want   bool
+	}{
+		"BecameInvalid": {
+			reason: "An update that makes a Kops resource invalid should be rejected.",
+			old:    kops(ig("master-a", kopsapi.InstanceGroupRoleMaster), ig("nodes", kopsapi.InstanceGroupRoleNode)),
+			obj:    invalid,
+			want:   true,
+		},
+		"ParametersUnchanged": {
+			reason: "An update that leaves the parameters of an invalid Kops resource unchanged should be admitted.",
+			old:    invalid,
+			obj:    relabelled,
+		},
+		"Deleted": {
+			reason: "An update of an invalid Kops resource that is being deleted should be admitted.",
+			old:    kops(ig("master-a", kopsapi.InstanceGroupRoleMaster)),
+			obj:    deleted,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := (&KopsValidator{}).ValidateUpdate(context.Background(), tc.old, tc.obj)
+			if diff := cmp.Diff(tc.want, kerrors.IsInvalid(err)); diff != "" {
+				t.Errorf("\n%s\nv.ValidateUpdate(...): -want invalid, +got invalid:\n%s\n%v\n", tc.reason, diff, err)
+			}
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+	created := kops(ig("master-a", kopsapi.InstanceGroupRoleMaster))
+	created.SetCreationTimestamp(metav1.Now())
+
+	cases := map[string]struct {
+		reason string
+		obj    *v1alpha1.Kops
+		want   bool
+	}{
+		"Creating": {
+			reason: "A Kops resource that is being created should be defaulted.",
+			obj:    kops(ig("master-a", kopsapi.InstanceGroupRoleMaster)),
+			want:   true,
+		},
+		"Existing": {
+			reason: "A Kops resource that already exists should not be defaulted.",
+			obj:    created,
+		},
+		"Simple": {
+			reason: "A Kops resource in simple mode should be left to fill in its own defaults.",
+			obj: func() *v1alpha1.Kops {
+				cr := kops()
+				cr.Spec.ForProvider.Simple = &v1alpha1.SimpleParameters{Zones: []string{"a"}}
+				return cr
+			}(),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := (&KopsDefaulter{}).Default(context.Background(), tc.obj); err != nil {
+				t.Fatalf("d.Default(...): %v", err)
+			}
+			got := tc.obj.Spec.ForProvider.ClusterSpec.Networking != nil
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nd.Default(...): -want defaulted, +got defaulted:\n%s\n", tc.reason, diff)
+			}
+		})
+	}
+}